@@ -15,6 +15,7 @@ import (
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/harborhealth"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/migrator"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/server"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/joho/godotenv"
 )
 
@@ -25,7 +26,16 @@ func main() {
 		log.Fatalf("failed to load environment: %v", err)
 	}
 
-	err := harborhealth.CheckHealth()
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Enabled:     env.GC.Audit.OTelTracesEnabled && env.GC.Audit.OTelEndpoint != "",
+		Endpoint:    env.GC.Audit.OTelEndpoint,
+		ServiceName: "ground-control",
+	})
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+
+	err = harborhealth.CheckHealth()
 	if err != nil {
 		log.Fatalf("health check failed: %v", err)
 	}
@@ -40,6 +50,7 @@ func main() {
 	cleanupCtx, cleanupCancel := context.WithCancel(context.Background())
 	defer cleanupCancel()
 	go serverResult.AppServer.StartCleanupJob(cleanupCtx, server.NewCleanupConfig())
+	go serverResult.AppServer.StartRolloutWatcher(cleanupCtx, server.DefaultRolloutCheckInterval)
 
 	go func() {
 		var err error
@@ -72,4 +83,7 @@ func main() {
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP shutdown error: %v", err)
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("tracing shutdown error: %v", err)
+	}
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/container-registry/harbor-satellite/internal/env"
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// runSubcommand runs the subcommand named by args[0]. It reports false when
+// args do not start with a subcommand, so the satellite starts.
+func runSubcommand(args []string, envCfg env.HarborSatellite) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "cri":
+		if len(args) < 2 {
+			return true, errors.New("usage: harbor-satellite cri status|restore")
+		}
+		switch args[1] {
+		case "status":
+			return true, runCRIStatus(args[2:], os.Stdout)
+		case "restore":
+			return true, runCRIRestore(args[2:], os.Stdout)
+		}
+		return true, fmt.Errorf("unknown cri command %q, expected status or restore", args[1])
+	case "uninstall":
+		return true, runUninstall(args[1:], envCfg, os.Stdout)
+	}
+	return false, nil
+}
+
+// runCRIStatus prints the registry mirrors each runtime on the node is
+// configured with and the files the satellite changed.
+func runCRIStatus(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("cri status", flag.ContinueOnError)
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid --format %q: must be text or json", *format)
+	}
+
+	status, err := runtime.ReadCRIStatus()
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(status.Wiring) == 0 {
+		fmt.Fprintln(tw, "No registry mirrors configured")
+	} else {
+		fmt.Fprintln(tw, "RUNTIME\tREGISTRY\tMIRRORS\tSATELLITE\tFILE")
+		for _, wiring := range status.Wiring {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", wiring.CRI, wiring.Registry, strings.Join(wiring.Mirrors, ","), wiring.Satellite, wiring.File)
+		}
+	}
+	for _, warning := range status.Warnings {
+		fmt.Fprintf(tw, "warning: %s\n", warning)
+	}
+	if len(status.Journal.Entries) > 0 {
+		fmt.Fprintln(tw, "\nChanged by the satellite:")
+		fmt.Fprintln(tw, "RUNTIME\tFILE\tBACKUP")
+		for _, e := range status.Journal.Entries {
+			backup := e.Backup
+			if backup == "" {
+				backup = "(created)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", e.CRI, e.Path, backup)
+		}
+	}
+	return tw.Flush()
+}
+
+// runCRIRestore puts back the runtime config files the satellite changed as
+// they were before its first change.
+func runCRIRestore(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("cri restore", flag.ContinueOnError)
+	var runtimes mirrorFlags
+	fs.Var(&runtimes, "runtime", "Restore only the files of this runtime (containerd, crio, podman, docker, k3s or rke2), repeatable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var filter []runtime.CRIType
+	for _, r := range runtimes {
+		cri := runtime.CRIType(r)
+		if !slices.Contains(runtime.CRITypes, cri) {
+			return fmt.Errorf("invalid --runtime %q: must be containerd, crio, podman, docker, k3s or rke2", r)
+		}
+		filter = append(filter, cri)
+	}
+	results, err := runtime.RestoreCRIConfigs(filter)
+	if writeErr := writeRestoreResults(w, results); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// runUninstall removes the mirror entries the satellite wrote to the runtime
+// configs and, with --purge, the satellite data.
+func runUninstall(args []string, envCfg env.HarborSatellite, w io.Writer) error {
+	fs := flag.NewFlagSet("uninstall", flag.ContinueOnError)
+	configDir := fs.String("config-dir", envCfg.ConfigDir, "Configuration directory path (default: ~/.config/satellite)")
+	registryDataDir := fs.String("registry-data-dir", envCfg.RegistryDataDir, "Registry data directory, when kept outside the config directory")
+	purge := fs.Bool("purge", false, "Also delete the config directory and the registry data of the satellite")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	results, err := runtime.UninstallCRIConfigs()
+	if writeErr := writeRestoreResults(w, results); writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !*purge {
+		return nil
+	}
+	dirs := []string{*registryDataDir, *configDir}
+	if *configDir == "" {
+		dir, err := config.DefaultConfigDir()
+		if err != nil {
+			return err
+		}
+		dirs[1] = dir
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := removeDataDir(dir); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Removed %s\n", dir)
+	}
+	return nil
+}
+
+// removeDataDir deletes a satellite data directory, refusing paths whose
+// removal would take unrelated data with it.
+func removeDataDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", dir, err)
+	}
+	home, _ := os.UserHomeDir()
+	if abs == filepath.Dir(abs) || abs == home {
+		return fmt.Errorf("refusing to delete %s", abs)
+	}
+	if err := os.RemoveAll(abs); err != nil {
+		return fmt.Errorf("delete %s: %w", abs, err)
+	}
+	return nil
+}
+
+func writeRestoreResults(w io.Writer, results []runtime.RestoreResult) error {
+	if len(results) == 0 {
+		_, err := fmt.Fprintln(w, "No runtime config changes recorded")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	failed := 0
+	affected := map[runtime.CRIType]bool{}
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			fmt.Fprintf(tw, "%s\t%s\terror: %s\n", r.CRI, r.Path, r.Error)
+			continue
+		}
+		affected[r.CRI] = true
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.CRI, r.Path, r.Action)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(affected) > 0 {
+		var names []string
+		for _, cri := range runtime.CRITypes {
+			if affected[cri] {
+				names = append(names, string(cri))
+			}
+		}
+		fmt.Fprintf(w, "Restart %s to apply the changes.\n", strings.Join(names, ", "))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) could not be processed and stay recorded for another attempt", failed)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/satellite/state"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// mirrorVerifyStartupTimeout bounds how long the startup verification
+	// waits for the local registry to accept the probe.
+	mirrorVerifyStartupTimeout = 2 * time.Minute
+	mirrorVerifyRetryInterval  = 5 * time.Second
+)
+
+// verifyCRIMirrors pushes a probe image to the local registry and pulls it
+// through every runtime in results, then deletes it.
+func verifyCRIMirrors(ctx context.Context, cm *config.ConfigManager, results []runtime.CRIConfigResult) ([]runtime.CRIConfigResult, error) {
+	probe, remove, err := state.PushMirrorProbe(ctx, cm)
+	if err != nil {
+		return results, err
+	}
+	defer remove()
+	return runtime.VerifyCRIMirrors(ctx, results, probe), nil
+}
+
+// verifyCRIMirrorsOnStartup is verifyCRIMirrors for the configs applied at
+// startup, before the local registry runs. The probe push is retried until
+// the registry accepts it.
+func verifyCRIMirrorsOnStartup(ctx context.Context, cm *config.ConfigManager, results []runtime.CRIConfigResult) ([]runtime.CRIConfigResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, mirrorVerifyStartupTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(mirrorVerifyRetryInterval)
+	defer ticker.Stop()
+	for {
+		verified, err := verifyCRIMirrors(ctx, cm, results)
+		if err == nil {
+			return verified, nil
+		}
+		select {
+		case <-ctx.Done():
+			return results, fmt.Errorf("local registry not ready: %w", err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// unverifiedCRIResults records err as the verification error of every
+// runtime in results that was configured, for a verification that could not
+// run.
+func unverifiedCRIResults(results []runtime.CRIConfigResult, err error) []runtime.CRIConfigResult {
+	unverified := make([]runtime.CRIConfigResult, len(results))
+	for i, r := range results {
+		unverified[i] = r
+		if r.Success {
+			unverified[i].VerifyError = err.Error()
+		}
+	}
+	return unverified
+}
+
+func logCRIVerification(log zerolog.Logger, results []runtime.CRIConfigResult) {
+	for _, r := range results {
+		switch {
+		case r.Verified:
+			log.Info().Str("cri", string(r.CRI)).Msg("Container runtime pulls through the satellite mirror")
+		case r.VerifyError != "":
+			log.Warn().Str("cri", string(r.CRI)).Str("error", r.VerifyError).Msg("Container runtime mirror unverified")
+		}
+	}
+}
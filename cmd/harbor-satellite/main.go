@@ -13,11 +13,16 @@ import (
 	"github.com/container-registry/harbor-satellite/internal/env"
 	"github.com/container-registry/harbor-satellite/internal/logger"
 	"github.com/container-registry/harbor-satellite/internal/satellite"
+	"github.com/container-registry/harbor-satellite/internal/satellite/admin"
 	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
 	"github.com/container-registry/harbor-satellite/internal/satellite/hotreload"
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/container-registry/harbor-satellite/internal/satellite/nodeconfig"
 	"github.com/container-registry/harbor-satellite/internal/satellite/parsec"
+	"github.com/container-registry/harbor-satellite/internal/satellite/prewarm"
 	"github.com/container-registry/harbor-satellite/internal/satellite/registry"
 	"github.com/container-registry/harbor-satellite/internal/satellite/watcher"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/container-registry/harbor-satellite/internal/utils"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 
@@ -60,9 +65,31 @@ type SatelliteOptions struct {
 	HarborRegistryURL      string
 	DirectDelivery         bool
 	ImageDir               string
+	DirectDeliveryBackend  string
 	// PARSEC hardware-backed identity (optional; requires parsec build tag and running daemon)
 	ParsecEnabled    bool
 	ParsecSocketPath string
+	// AdminAddr enables the local admin API on a loopback address or unix socket
+	AdminAddr string
+	// MetricsAddr overrides metrics.listen_address for the Prometheus endpoint
+	MetricsAddr string
+	// DryRun prints the changes the next sync would make and exits
+	DryRun       bool
+	DryRunFormat string
+	// PrewarmAgentURL runs only the prewarm agent against the satellite
+	// serving its node agent API at this URL
+	PrewarmAgentURL string
+	PrewarmInterval string
+	NodeName        string
+	CRIEndpoint     string
+	// NodeAgentToken is the shared secret node agents authenticate to the
+	// node agent API with. It is read from NODE_AGENT_TOKEN only, to keep it
+	// off the command line.
+	NodeAgentToken string
+	// NodeConfigAgent runs only the node config agent, applying the node
+	// config mounted at this path
+	NodeConfigAgent    string
+	NodeConfigInterval string
 }
 
 func main() {
@@ -73,6 +100,14 @@ func main() {
 	}
 
 	envCfg := env.Satellite.ApplyDefaults()
+	if handled, err := runSubcommand(os.Args[1:], envCfg); handled {
+		if err != nil {
+			fmt.Printf("fatal: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	opts := SatelliteOptions{
 		GroundControlURL:       envCfg.GroundControlURL,
 		UseUnsecure:            envCfg.UseUnsecure,
@@ -88,8 +123,16 @@ func main() {
 		HarborRegistryURL:      envCfg.HarborRegistryURL,
 		DirectDelivery:         envCfg.DirectDelivery,
 		ImageDir:               envCfg.ImageDir,
+		DirectDeliveryBackend:  envCfg.DirectDeliveryBackend,
 		ParsecEnabled:          envCfg.ParsecEnabled,
 		ParsecSocketPath:       envCfg.ParsecSocketPath,
+		AdminAddr:              envCfg.AdminAddr,
+		MetricsAddr:            envCfg.MetricsAddr,
+		PrewarmAgentURL:        envCfg.PrewarmAgentURL,
+		NodeConfigAgent:        envCfg.NodeConfigAgent,
+		NodeName:               envCfg.NodeName,
+		CRIEndpoint:            envCfg.CRIEndpoint,
+		NodeAgentToken:         envCfg.NodeAgentToken,
 	}
 	shutdownTimeout := envCfg.ShutdownTimeout
 
@@ -111,10 +154,21 @@ func main() {
 	flag.BoolVar(&opts.NoRegistryFallback, "no-registry-fallback", opts.NoRegistryFallback, "Disable all CRI registry fallback configuration")
 	flag.BoolVar(&opts.FallbackOnly, "fallback-only", false, "Apply CRI registry fallback configs and exit without starting satellite")
 	flag.StringVar(&opts.HarborRegistryURL, "harbor-registry-url", opts.HarborRegistryURL, "Override Harbor registry URL from Ground Control (e.g., http://10.0.0.1:8080)")
-	flag.BoolVar(&opts.DirectDelivery, "direct-delivery", opts.DirectDelivery, "[Experimental] Write image tarballs directly to k3s/RKE2 agent images directory")
-	flag.StringVar(&opts.ImageDir, "image-dir", opts.ImageDir, "Override image directory for direct delivery (auto-detected if empty)")
+	flag.BoolVar(&opts.DirectDelivery, "direct-delivery", opts.DirectDelivery, "[Experimental] Deliver images directly to the node, without a registry pull")
+	flag.StringVar(&opts.DirectDeliveryBackend, "direct-delivery-backend", opts.DirectDeliveryBackend, "Direct delivery backend: image_dir (k3s/RKE2 agent images directory), containerd, docker or containers_storage (CRI-O, Podman)")
+	flag.StringVar(&opts.ImageDir, "image-dir", opts.ImageDir, "Override image directory for the image_dir direct delivery backend (auto-detected if empty)")
 	flag.BoolVar(&opts.ParsecEnabled, "parsec-enabled", opts.ParsecEnabled, "Enable hardware-backed identity via PARSEC (requires parsec build tag and running PARSEC daemon)")
 	flag.StringVar(&opts.ParsecSocketPath, "parsec-socket", opts.ParsecSocketPath, "PARSEC daemon socket path")
+	flag.StringVar(&opts.MetricsAddr, "metrics-addr", opts.MetricsAddr, "Serve Prometheus metrics on /metrics at this address (e.g., :9464); overrides metrics.listen_address")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "Print the images the next sync would pull, delete and deliver, then exit without touching the local registry or CRI config")
+	flag.StringVar(&opts.DryRunFormat, "dry-run-format", "text", "Output format for --dry-run: text or json")
+	flag.StringVar(&opts.PrewarmAgentURL, "prewarm-agent", opts.PrewarmAgentURL, "Run as a prewarm agent: pull the images of the satellite serving its node agent API at this URL (e.g., http://satellite:9465) into this node's runtime and report back")
+	flag.StringVar(&opts.PrewarmInterval, "prewarm-interval", "1m", "How often the prewarm agent checks the images of the satellite")
+	flag.StringVar(&opts.NodeConfigAgent, "node-config-agent", opts.NodeConfigAgent, "Run as a node config agent: apply the mirror config the satellite publishes to a ConfigMap, mounted at this path (e.g., /etc/harbor-satellite/node-config/node-config.json), to this node's runtimes and report back")
+	flag.StringVar(&opts.NodeConfigInterval, "node-config-interval", "1m", "How often the node config agent checks the node config")
+	flag.StringVar(&opts.NodeName, "node-name", opts.NodeName, "Node name the prewarm and node config agents report under (default: hostname)")
+	flag.StringVar(&opts.CRIEndpoint, "cri-endpoint", opts.CRIEndpoint, "CRI socket of the prewarm agent's runtime (auto-detected if empty)")
+	flag.StringVar(&opts.AdminAddr, "admin-addr", opts.AdminAddr, "Serve the local admin API on a loopback address (e.g., 127.0.0.1:9090) or unix socket (e.g., unix:///run/satellite/admin.sock)")
 
 	flag.Parse()
 	if opts.Token == "" {
@@ -124,6 +178,22 @@ func main() {
 		opts.RegistryPassword = envCfg.RegistryPassword
 	}
 
+	if opts.PrewarmAgentURL != "" {
+		if err := runPrewarmAgent(opts); err != nil {
+			fmt.Printf("fatal: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.NodeConfigAgent != "" {
+		if err := runNodeConfigAgent(opts); err != nil {
+			fmt.Printf("fatal: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Surface PARSEC misconfiguration at startup rather than at the first hardware operation.
 	if err := (parsec.Config{Enabled: opts.ParsecEnabled, SocketPath: opts.ParsecSocketPath}).Validate(); err != nil {
 		fmt.Printf("Invalid PARSEC configuration: %v\n", err)
@@ -151,8 +221,14 @@ func main() {
 		pathConfig.ZotStorageDir = opts.RegistryDataDir
 	}
 
-	// For --fallback-only mode, relax token/gc-url requirements
-	if !opts.FallbackOnly {
+	if opts.DryRun && opts.DryRunFormat != "text" && opts.DryRunFormat != "json" {
+		fmt.Printf("Invalid --dry-run-format %q: must be text or json.\n", opts.DryRunFormat)
+		os.Exit(1)
+	}
+
+	// For --fallback-only and --dry-run modes, relax token/gc-url requirements:
+	// neither talks to Ground Control.
+	if !opts.FallbackOnly && !opts.DryRun {
 		if !opts.SPIFFEEnabled && (opts.Token == "" || opts.GroundControlURL == "") {
 			fmt.Println("Missing required arguments: --token and --ground-control-url or matching env vars (or enable SPIFFE with --spiffe-enabled).")
 			os.Exit(1)
@@ -312,6 +388,10 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 		)
 	}
 
+	if opts.MetricsAddr != "" {
+		cm.With(config.SetMetricsListenAddress(opts.MetricsAddr))
+	}
+
 	if opts.HarborRegistryURL != "" {
 		cm.With(config.SetHarborRegistryURL(opts.HarborRegistryURL))
 
@@ -326,6 +406,12 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 		}
 	}
 
+	// Dry run: report what the next sync would do and exit before any CRI
+	// config is applied or the registry is started.
+	if opts.DryRun {
+		return runPlan(ctx, cm, opts, pathConfig, warnings)
+	}
+
 	// Update Zot config with storage path
 	zotConfigJSON, err := config.BuildZotConfigWithStoragePath(pathConfig.ZotStorageDir)
 	if err != nil {
@@ -333,6 +419,13 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 	}
 	cm.With(config.SetZotConfigRaw(json.RawMessage(zotConfigJSON)))
 
+	// Provision TLS and the registry accounts before the runtimes are pointed
+	// at the registry, so they trust it from the first pull
+	secureReg, err := setupSecureRegistry(ctx, cm, pathConfig)
+	if err != nil {
+		return fmt.Errorf("secure registry setup: %w", err)
+	}
+
 	// Resolve local registry endpoint for CRI mirror config
 	localRegistryEndpoint, err := resolveLocalRegistryEndpoint(cm)
 	if err != nil {
@@ -347,6 +440,17 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 		} else {
 			fmt.Printf("warning: %s config error: %s\n", r.CRI, r.Error)
 		}
+		for _, w := range r.Warnings {
+			fmt.Printf("warning: %s: %s\n", r.CRI, w)
+		}
+		if r.Reloaded {
+			fmt.Printf("CRI %s reloaded\n", r.CRI)
+		} else if r.ReloadError != "" {
+			fmt.Printf("warning: %s reload error: %s\n", r.CRI, r.ReloadError)
+		}
+		if r.RestartRequired {
+			fmt.Printf("warning: restart %s to apply its mirror config\n", r.CRI)
+		}
 	}
 
 	if opts.FallbackOnly {
@@ -358,21 +462,19 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 	// feature shipped in c2dbea8 (#356) and was removed in error during the
 	// PARSEC integration work; restored here.
 	if opts.DirectDelivery {
-		imageDir := opts.ImageDir
-		if imageDir == "" {
-			imageDir = runtime.DetectImageDir()
-		}
-		if imageDir == "" {
-			return fmt.Errorf("--direct-delivery enabled but no k3s/RKE2 image directory found; use --image-dir to specify one")
+		dd, err := directDeliveryFromFlags(cm, opts)
+		if err != nil {
+			return err
 		}
-		if err := os.MkdirAll(imageDir, 0o755); err != nil {
-			return fmt.Errorf("create image directory %s: %w", imageDir, err)
+		if dd.BackendOrDefault() == config.DeliveryBackendImageDir {
+			if err := os.MkdirAll(dd.ImageDir, 0o755); err != nil {
+				return fmt.Errorf("create image directory %s: %w", dd.ImageDir, err)
+			}
+			fmt.Printf("EXPERIMENTAL: direct delivery enabled, images will be written to %s\n", dd.ImageDir)
+		} else {
+			fmt.Printf("EXPERIMENTAL: direct delivery enabled, images will be loaded into %s\n", dd.BackendOrDefault())
 		}
-		cm.With(config.SetDirectDelivery(config.DirectDeliveryConfig{
-			Enabled:  true,
-			ImageDir: imageDir,
-		}))
-		fmt.Printf("EXPERIMENTAL: direct delivery enabled, images will be written to %s\n", imageDir)
+		cm.With(config.SetDirectDelivery(dd))
 	}
 
 	ctx, log := logger.InitLogger(ctx, cm.GetLogLevel(), opts.JSONLogging, warnings)
@@ -393,6 +495,22 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 			Msg("Audit logging enabled")
 	}
 
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Enabled:     auditCfg.Otel.Traces && auditCfg.Otel.Endpoint != "",
+		Endpoint:    auditCfg.Otel.Endpoint,
+		ServiceName: "harbor-satellite",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("Failed to flush traces on shutdown")
+		}
+	}()
+
 	// Write the config to disk, in case any defaults were enforced at runtime
 	if err := cm.WriteConfig(); err != nil {
 		log.Error().Err(err).Msg("Error writing config to disk")
@@ -409,8 +527,33 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 
 	eventChan := make(chan struct{})
 
+	if addr := cm.GetMetricsConfig().ListenAddress; addr != "" {
+		wg.Go(func() error {
+			return metrics.Serve(ctx, addr, log.With().Str("component", "metrics").Logger())
+		})
+	}
+
 	// Handle registry setup
-	wg.Go(func() error { return handleRegistrySetup(ctx, log, cm, pathConfig) })
+	var zotSupervisor *registry.Supervisor
+	if !cm.GetOwnRegistry() {
+		zotConfig := cm.GetRawZotConfig()
+		if secureReg != nil {
+			zotConfig, err = secureReg.zotConfig(zotConfig)
+			if err != nil {
+				return fmt.Errorf("secure zot config: %w", err)
+			}
+			hotReloadManager.SetZotConfigOverlay(secureReg.zotConfig)
+		}
+		zm := registry.NewZotManager(log.With().Str("component", "zot manager").Logger(), zotConfig, pathConfig.ZotTempConfig)
+		zotSupervisor = registry.NewSupervisor(zm, log.With().Str("component", "zot supervisor").Logger())
+		hotReloadManager.SetZotRestarter(zotSupervisor)
+		if secureReg != nil {
+			wg.Go(func() error {
+				return secureReg.renewLoop(ctx, log.With().Str("component", "secure registry").Logger(), zotSupervisor)
+			})
+		}
+	}
+	wg.Go(func() error { return handleRegistrySetup(ctx, log, cm, zotSupervisor) })
 
 	// Watch for changes in the config file
 	wg.Go(func() error {
@@ -444,9 +587,14 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 						// event is emitted before the swap so the disable action is
 						// still captured.
 						currentAuditCfg = reconfigureAuditOnReload(audit, currentAuditCfg, cm.GetAuditConfig(), changedKeys, log)
-						if err := hotReloadManager.ProcessConfigChanges(changes); err != nil {
+						report, err := hotReloadManager.ProcessConfigChanges(changes)
+						if err != nil {
 							log.Error().Err(err).Msg("Error processing configuration changes")
 						}
+						for _, p := range report.PendingRestart {
+							log.Warn().Str("change_type", string(p.Type)).Str("reason", p.Reason).Msg("Configuration change will take effect after a restart")
+						}
+						log.Info().Interface("applied", report.Applied).Int("pending_restart", len(report.PendingRestart)).Msg("Configuration reload applied")
 					}
 				}
 			}
@@ -459,11 +607,92 @@ func run(opts SatelliteOptions, pathConfig *config.PathConfig, shutdownTimeout s
 		return fmt.Errorf("unable to start satellite: %w", err)
 	}
 
-	for _, s := range s.GetSchedulers() {
-		if s.Name() == config.ReplicateStateJobName {
-			hotReloadManager.SetStateReplicationScheduler(s)
+	if cm.GetRegistryFallbackConfig().Verify && len(criResults) > 0 {
+		wg.Go(func() error {
+			verifyLog := log.With().Str("component", "cri verify").Logger()
+			results, err := verifyCRIMirrorsOnStartup(ctx, cm, criResults)
+			if err != nil {
+				verifyLog.Warn().Err(err).Msg("Unable to verify the container runtime mirrors")
+				results = unverifiedCRIResults(results, err)
+			}
+			logCRIVerification(verifyLog, results)
+			s.StatusProcess().SetPendingCRIResults(results)
+			return nil
+		})
+	}
+
+	for _, sched := range s.GetSchedulers() {
+		switch sched.Name() {
+		case config.ReplicateStateJobName:
+			hotReloadManager.SetStateReplicationScheduler(sched)
+		case config.ZTRConfigJobName, config.SPIFFEZTRConfigJobName:
+			hotReloadManager.SetRegistrationScheduler(sched)
+		case config.StatusReportJobName:
+			hotReloadManager.SetStatusReporting(sched, s.StatusProcess())
 		}
 	}
+	if zotSupervisor != nil {
+		s.StatusProcess().SetRegistryStatus(zotSupervisor)
+	}
+	hotReloadManager.SetCRIApplier(func() ([]runtime.CRIConfigResult, error) {
+		endpoint, err := resolveLocalRegistryEndpoint(cm)
+		if err != nil {
+			return nil, fmt.Errorf("resolving local registry endpoint: %w", err)
+		}
+		results := resolveCRIAndApply(cm, opts.Mirrors, opts.NoRegistryFallback, endpoint)
+		if cm.GetRegistryFallbackConfig().Verify && len(results) > 0 {
+			// Verification is advisory: the applied configs are reported
+			// either way.
+			verifyLog := log.With().Str("component", "cri verify").Logger()
+			results, err = verifyCRIMirrors(ctx, cm, results)
+			if err != nil {
+				verifyLog.Warn().Err(err).Msg("Unable to verify the container runtime mirrors")
+				results = unverifiedCRIResults(results, err)
+			}
+			logCRIVerification(verifyLog, results)
+		}
+		return results, nil
+	})
+
+	nodeConfigs := nodeconfig.NewCollector()
+	s.StatusProcess().SetNodeConfigCollector(nodeConfigs)
+	hotReloadManager.SetNodeConfigPublisher(func() error {
+		return publishNodeConfig(ctx, cm, nodeConfigs)
+	})
+	if cm.GetNodeConfigConfig().Enabled {
+		if err := publishNodeConfig(ctx, cm, nodeConfigs); err != nil {
+			log.Warn().Err(err).Msg("Unable to publish node config")
+		} else {
+			log.Info().Msg("Node config published")
+		}
+	}
+
+	if addr := cm.GetPrewarmConfig().ListenAddress; addr != "" {
+		if opts.NodeAgentToken == "" {
+			log.Error().Str("address", addr).Msg("Node agent API not served: NODE_AGENT_TOKEN is not set")
+		} else {
+			handler := nodeAgentHandler(s.StateProcess().Prewarmer(), nodeConfigs, opts.NodeAgentToken)
+			wg.Go(func() error {
+				return prewarm.Serve(ctx, addr, handler, log.With().Str("component", "node agent api").Logger())
+			})
+		}
+	}
+
+	if opts.AdminAddr != "" {
+		adminServer, err := admin.NewServer(admin.Options{
+			Address:        opts.AdminAddr,
+			State:          s.StateProcess(),
+			ZTRDone:        cm.IsZTRDone,
+			RegistryURL:    cm.GetLocalRegistryURL,
+			Schedulers:     s.SchedulerNames,
+			Breakers:       s.Breakers().Status,
+			PendingRestart: hotReloadManager.PendingRestart,
+		}, log.With().Str("component", "admin api").Logger())
+		if err != nil {
+			return fmt.Errorf("configure admin API: %w", err)
+		}
+		wg.Go(func() error { return adminServer.Run(ctx) })
+	}
 
 	return gracefulShutdown(ctx, log, s, wg, shutdownTimeout)
 }
@@ -534,7 +763,7 @@ func resolveCRIAndApply(cm *config.ConfigManager, mirrors mirrorFlags, noFallbac
 			fmt.Printf("warning: failed to resolve CRI configs: %v\n", err)
 			return nil
 		}
-		return runtime.ApplyCRIConfigs(configs, localRegistry)
+		return runtime.ApplyCRIConfigs(configs, localRegistry, mirrorAuth(cm), mirrorOptions(cm.GetRegistryFallbackConfig()))
 	}
 
 	// Explicit --mirrors flag
@@ -544,7 +773,7 @@ func resolveCRIAndApply(cm *config.ConfigManager, mirrors mirrorFlags, noFallbac
 			fmt.Printf("warning: failed to parse mirror flags: %v\n", err)
 			return nil
 		}
-		return runtime.ApplyCRIConfigs(configs, localRegistry)
+		return runtime.ApplyCRIConfigs(configs, localRegistry, mirrorAuth(cm), mirrorOptions(cm.GetRegistryFallbackConfig()))
 	}
 
 	// Disabled via flag or env var
@@ -560,6 +789,9 @@ func resolveLocalRegistryEndpoint(cm *config.ConfigManager) (string, error) {
 	if cm.GetOwnRegistry() {
 		return utils.FormatRegistryURL(cm.GetLocalRegistryURL()), nil
 	}
+	if access, ok := cm.GetSecureRegistryAccess(); ok {
+		return utils.FormatRegistryURL(access.URL), nil
+	}
 	var data map[string]any
 	if err := json.Unmarshal(cm.GetRawZotConfig(), &data); err != nil {
 		return "", fmt.Errorf("unmarshalling zot config: %w", err)
@@ -576,7 +808,7 @@ func resolveLocalRegistryEndpoint(cm *config.ConfigManager) (string, error) {
 	return addr + ":" + port, nil
 }
 
-func handleRegistrySetup(ctx context.Context, log *zerolog.Logger, cm *config.ConfigManager, pathConfig *config.PathConfig) error {
+func handleRegistrySetup(ctx context.Context, log *zerolog.Logger, cm *config.ConfigManager, zotSupervisor *registry.Supervisor) error {
 	log.Debug().Msg("Setting up local registry")
 
 	if cm.GetOwnRegistry() {
@@ -591,11 +823,37 @@ func handleRegistrySetup(ctx context.Context, log *zerolog.Logger, cm *config.Co
 
 	log.Info().Msg("Launching default registry")
 
-	zm := registry.NewZotManager(log.With().Str("component", "zot manager").Logger(), cm.GetRawZotConfig(), pathConfig.ZotTempConfig)
-
-	if err := zm.HandleRegistrySetup(ctx); err != nil {
+	if err := zotSupervisor.Run(ctx); err != nil {
 		return fmt.Errorf("default registry setup failed: %w", err)
 	}
 
 	return nil
 }
+
+// directDeliveryFromFlags applies --direct-delivery-backend and --image-dir on
+// top of the configured direct delivery settings. The image directory of
+// k3s/RKE2 is auto-detected when the backend needs one and none is set.
+func directDeliveryFromFlags(cm *config.ConfigManager, opts SatelliteOptions) (config.DirectDeliveryConfig, error) {
+	dd := cm.GetDirectDeliveryConfig()
+	dd.Enabled = true
+	if opts.DirectDeliveryBackend != "" {
+		dd.Backend = opts.DirectDeliveryBackend
+	}
+
+	switch dd.BackendOrDefault() {
+	case config.DeliveryBackendImageDir:
+		if opts.ImageDir != "" {
+			dd.ImageDir = opts.ImageDir
+		}
+		if dd.ImageDir == "" {
+			dd.ImageDir = runtime.DetectImageDir()
+		}
+		if dd.ImageDir == "" {
+			return dd, fmt.Errorf("--direct-delivery enabled but no k3s/RKE2 image directory found; use --image-dir to specify one or pick another --direct-delivery-backend")
+		}
+	case config.DeliveryBackendContainerd, config.DeliveryBackendDocker, config.DeliveryBackendContainersStorage:
+	default:
+		return dd, fmt.Errorf("invalid --direct-delivery-backend %q, valid values: image_dir, containerd, docker, containers_storage", dd.Backend)
+	}
+	return dd, nil
+}
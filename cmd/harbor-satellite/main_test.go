@@ -2,10 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/container-registry/harbor-satellite/internal/crypto"
+	"github.com/container-registry/harbor-satellite/internal/env"
 	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/stretchr/testify/require"
@@ -191,6 +194,19 @@ func TestResolveCRIAndApply(t *testing.T) {
 	})
 }
 
+func TestUnverifiedCRIResults(t *testing.T) {
+	results := []runtime.CRIConfigResult{
+		{CRI: runtime.CRIContainerd, Success: true},
+		{CRI: runtime.CRIDocker, Success: false, Error: "socket not found"},
+	}
+	unverified := unverifiedCRIResults(results, errors.New("push mirror probe: connection refused"))
+	require.Len(t, unverified, 2)
+	require.Equal(t, "push mirror probe: connection refused", unverified[0].VerifyError)
+	require.False(t, unverified[0].Verified)
+	require.Empty(t, unverified[1].VerifyError, "runtimes that were not configured are not verified")
+	require.Empty(t, results[0].VerifyError, "results are not modified")
+}
+
 func TestMirrorFlags(t *testing.T) {
 	t.Run("Set accumulates values", func(t *testing.T) {
 		var m mirrorFlags
@@ -211,3 +227,38 @@ func TestMirrorFlags(t *testing.T) {
 		require.Equal(t, "[]", m.String())
 	})
 }
+
+func TestRunSubcommand(t *testing.T) {
+	handled, err := runSubcommand(nil, env.HarborSatellite{})
+	require.False(t, handled)
+	require.NoError(t, err)
+
+	handled, err = runSubcommand([]string{"--token", "abc"}, env.HarborSatellite{})
+	require.False(t, handled)
+	require.NoError(t, err)
+
+	handled, err = runSubcommand([]string{"cri"}, env.HarborSatellite{})
+	require.True(t, handled)
+	require.ErrorContains(t, err, "usage")
+
+	handled, err = runSubcommand([]string{"cri", "reset"}, env.HarborSatellite{})
+	require.True(t, handled)
+	require.ErrorContains(t, err, `unknown cri command "reset"`)
+
+	handled, err = runSubcommand([]string{"cri", "status", "--format", "yaml"}, env.HarborSatellite{})
+	require.True(t, handled)
+	require.ErrorContains(t, err, "invalid --format")
+
+	handled, err = runSubcommand([]string{"cri", "restore", "--runtime", "containerd", "--runtime", "cri-o"}, env.HarborSatellite{})
+	require.True(t, handled)
+	require.ErrorContains(t, err, `invalid --runtime "cri-o"`)
+}
+
+func TestRemoveDataDir(t *testing.T) {
+	require.ErrorContains(t, removeDataDir("/"), "refusing")
+
+	dir := filepath.Join(t.TempDir(), "satellite")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "zot"), 0o755))
+	require.NoError(t, removeDataDir(dir))
+	require.NoDirExists(t, dir)
+}
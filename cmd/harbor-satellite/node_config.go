@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/kube"
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/satellite/nodeconfig"
+	"github.com/container-registry/harbor-satellite/internal/satellite/prewarm"
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// runNodeConfigAgent applies the node config mounted at opts.NodeConfigAgent
+// to the runtimes of this node and reports the result back, without
+// starting a satellite. It runs as a DaemonSet on the nodes of a cluster
+// whose satellite publishes the node config.
+func runNodeConfigAgent(opts SatelliteOptions) error {
+	ctx, cancel := utils.SetupContext(context.Background())
+	defer cancel()
+	ctx, log := logger.InitLogger(ctx, "info", opts.JSONLogging, nil)
+
+	interval, err := time.ParseDuration(opts.NodeConfigInterval)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid --node-config-interval %q", opts.NodeConfigInterval)
+	}
+	if opts.NodeAgentToken == "" {
+		return fmt.Errorf("NODE_AGENT_TOKEN is not set")
+	}
+	node := opts.NodeName
+	if node == "" {
+		if node, err = os.Hostname(); err != nil {
+			return fmt.Errorf("resolve node name: %w", err)
+		}
+	}
+
+	log.Info().Str("config", opts.NodeConfigAgent).Str("node", node).Msg("Starting node config agent")
+	agent := &nodeconfig.Agent{
+		ConfigPath: opts.NodeConfigAgent,
+		Node:       node,
+		Interval:   interval,
+		Apply:      applyNodeConfig,
+		Token:      opts.NodeAgentToken,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		Log:        log.With().Str("component", "node config agent").Logger(),
+	}
+	return agent.Run(ctx)
+}
+
+// nodeMirrorCAFile is where node agents install the CA of a secured
+// registry. The state directory is mounted at the same path on the host, so
+// the runtime configs can reference it.
+const nodeMirrorCAFile = "/var/lib/harbor-satellite/node-config/mirror-ca.crt"
+
+// applyNodeConfig points the runtimes of this node at the mirror of cfg.
+// The pull credentials of a secured registry stay with the satellite, the
+// nodes pull anonymously and trust its published CA.
+func applyNodeConfig(cfg nodeconfig.Config) ([]runtime.CRIConfigResult, error) {
+	fbCfg := cfg.RegistryFallback
+	if !fbCfg.Enabled {
+		return nil, nil
+	}
+	configs, err := runtime.ResolveCRIConfigs(nil, true, fbCfg.Registries, fbCfg.Runtimes)
+	if err != nil {
+		return nil, fmt.Errorf("resolve CRI configs: %w", err)
+	}
+	auth, err := cfg.MirrorAuth(nodeMirrorCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.ApplyCRIConfigs(configs, cfg.Mirror, auth, mirrorOptions(fbCfg)), nil
+}
+
+// nodeAgentHandler serves the prewarm and node config agents, which
+// authenticate with token.
+func nodeAgentHandler(p *prewarm.Prewarmer, c *nodeconfig.Collector, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/prewarm/", prewarm.Handler(p))
+	mux.Handle("/v1/nodeconfig/", nodeconfig.Handler(c))
+	return prewarm.RequireToken(token, mux)
+}
+
+// publishNodeConfig publishes the registry fallback settings for the node
+// config agents and records the digest results are matched against.
+func publishNodeConfig(ctx context.Context, cm *config.ConfigManager, collector *nodeconfig.Collector) error {
+	ncCfg := cm.GetNodeConfigConfig()
+	if !ncCfg.Enabled {
+		return nil
+	}
+	client, err := kube.InClusterClient()
+	if err != nil {
+		return err
+	}
+	namespace, name := ncCfg.ConfigMapOrDefault()
+	if namespace == "" {
+		namespace = client.Namespace
+	}
+	nodeCfg := nodeconfig.Config{
+		Mirror:           ncCfg.MirrorAddress,
+		ReportURL:        ncCfg.ReportURL,
+		RegistryFallback: cm.GetRegistryFallbackConfig(),
+	}
+	if access, ok := cm.GetSecureRegistryAccess(); ok {
+		ca, err := os.ReadFile(filepath.Clean(access.CAFile))
+		if err != nil {
+			return fmt.Errorf("read secure registry CA: %w", err)
+		}
+		nodeCfg.MirrorCA = string(ca)
+	}
+	digest, err := nodeconfig.Publish(ctx, client, namespace, name, nodeCfg)
+	if err != nil {
+		return err
+	}
+	collector.SetDigest(digest)
+	return nil
+}
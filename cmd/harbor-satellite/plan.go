@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/state"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// runPlan fetches the upstream state for an already registered satellite and
+// prints the pulls, deletes and direct deliveries the next sync would perform.
+// Logs go to stderr so the plan on stdout can be piped, e.g. into jq.
+func runPlan(ctx context.Context, cm *config.ConfigManager, opts SatelliteOptions, pathConfig *config.PathConfig, warnings []string) error {
+	ctx, log := logger.InitLogger(ctx, cm.GetLogLevel(), false, warnings)
+
+	if !cm.IsZTRDone() {
+		return fmt.Errorf("--dry-run needs a registered satellite: no state URL in %s, run once without --dry-run to complete zero-touch registration", pathConfig.ConfigFile)
+	}
+
+	directDelivery := cm.GetDirectDeliveryConfig()
+	if opts.DirectDelivery {
+		dd, err := directDeliveryFromFlags(cm, opts)
+		if err != nil {
+			return err
+		}
+		directDelivery = dd
+	}
+
+	process := state.NewFetchAndReplicateStateProcess(cm, pathConfig.StateFile, log)
+	plan, err := process.Plan(ctx, directDelivery, log)
+	if err != nil {
+		return fmt.Errorf("build plan: %w", err)
+	}
+
+	if opts.DryRunFormat == "json" {
+		return plan.WriteJSON(os.Stdout)
+	}
+	return plan.WriteText(os.Stdout)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/prewarm"
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// runPrewarmAgent warms the images of the satellite at opts.PrewarmAgentURL
+// in the runtime of this node and reports their status back, without
+// starting a satellite. It runs on nodes that pull from a satellite on
+// another node.
+func runPrewarmAgent(opts SatelliteOptions) error {
+	ctx, cancel := utils.SetupContext(context.Background())
+	defer cancel()
+	ctx, log := logger.InitLogger(ctx, "info", opts.JSONLogging, nil)
+
+	interval, err := time.ParseDuration(opts.PrewarmInterval)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid --prewarm-interval %q", opts.PrewarmInterval)
+	}
+	if opts.NodeAgentToken == "" {
+		return fmt.Errorf("NODE_AGENT_TOKEN is not set")
+	}
+	node := opts.NodeName
+	if node == "" {
+		if node, err = os.Hostname(); err != nil {
+			return fmt.Errorf("resolve node name: %w", err)
+		}
+	}
+	puller, err := prewarm.NewCRIPuller(opts.CRIEndpoint)
+	if err != nil {
+		return fmt.Errorf("connect to the container runtime: %w", err)
+	}
+	defer func() { _ = puller.Close() }()
+
+	log.Info().Str("satellite", opts.PrewarmAgentURL).Str("node", node).Msg("Starting prewarm agent")
+	agent := &prewarm.Agent{
+		SatelliteURL: opts.PrewarmAgentURL,
+		Token:        opts.NodeAgentToken,
+		Node:         node,
+		Puller:       puller,
+		Interval:     interval,
+		PullTimeout:  config.DefaultPrewarmPullTimeout,
+		Client:       &http.Client{Timeout: 30 * time.Second},
+		Log:          log.With().Str("component", "prewarm agent").Logger(),
+	}
+	return agent.Run(ctx)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/satellite/registry"
+	"github.com/container-registry/harbor-satellite/internal/spiffe"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+const (
+	secureRegistryDirName       = "registry-secure"
+	secureRegistryRenewInterval = time.Minute
+	svidWaitTimeout             = 30 * time.Second
+)
+
+// secureRegistry serves the embedded registry over TLS with the accounts of
+// registry.ProvisionSecureRegistry, and keeps its certificate fresh.
+type secureRegistry struct {
+	opts registry.SecureOptions
+	cm   *config.ConfigManager
+
+	mu       sync.Mutex
+	material *registry.SecureMaterial
+}
+
+// setupSecureRegistry provisions the secured embedded registry when
+// secure_registry is enabled, and records how to reach it in cm. It returns
+// nil when the registry is served as configured.
+func setupSecureRegistry(ctx context.Context, cm *config.ConfigManager, pathConfig *config.PathConfig) (*secureRegistry, error) {
+	secureCfg := cm.GetSecureRegistryConfig()
+	if !secureCfg.Enabled || cm.GetOwnRegistry() {
+		return nil, nil
+	}
+
+	endpoint, err := resolveLocalRegistryEndpoint(cm)
+	if err != nil {
+		return nil, err
+	}
+	listenHost, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse local registry endpoint %s: %w", endpoint, err)
+	}
+
+	hosts := append([]string{listenHost}, secureCfg.Hosts...)
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+
+	sr := &secureRegistry{
+		cm: cm,
+		opts: registry.SecureOptions{
+			Dir:           filepath.Join(pathConfig.ConfigDir, secureRegistryDirName),
+			Hosts:         hosts,
+			AnonymousPull: secureCfg.AnonymousPull,
+		},
+	}
+	if secureCfg.CertSourceOrDefault() == config.SecureRegistryCertSourceSPIFFE {
+		id, err := satelliteSPIFFEID(ctx, cm)
+		if err != nil {
+			return nil, err
+		}
+		sr.opts.SPIFFEID = id
+	}
+
+	m, err := registry.ProvisionSecureRegistry(sr.opts)
+	if err != nil {
+		return nil, fmt.Errorf("provision secure registry: %w", err)
+	}
+	sr.material = m
+
+	access := config.SecureRegistryAccess{
+		URL:          "https://" + net.JoinHostPort(dialHost(listenHost), port),
+		CAFile:       m.CAFile,
+		PushUsername: registry.PushUser,
+		PushPassword: m.PushPassword,
+	}
+	if !m.AnonymousPull {
+		access.PullUsername = registry.PullUser
+		access.PullPassword = m.PullPassword
+	}
+	cm.SetSecureRegistryAccess(access)
+	return sr, nil
+}
+
+// dialHost returns the host clients on this node reach a listener on.
+func dialHost(listenHost string) string {
+	if ip := net.ParseIP(listenHost); listenHost == "" || (ip != nil && ip.IsUnspecified()) {
+		return "127.0.0.1"
+	}
+	return listenHost
+}
+
+// satelliteSPIFFEID returns the SPIFFE ID of the SVID of the satellite.
+func satelliteSPIFFEID(ctx context.Context, cm *config.ConfigManager) (string, error) {
+	if !cm.IsSPIFFEEnabled() {
+		return "", fmt.Errorf("secure_registry.cert_source is spiffe but SPIFFE is not enabled")
+	}
+	spiffeCfg := cm.GetSPIFFEConfig()
+	client, err := spiffe.NewClient(spiffe.Config{
+		Enabled:          spiffeCfg.Enabled,
+		EndpointSocket:   spiffeCfg.EndpointSocket,
+		ExpectedServerID: spiffeCfg.ExpectedServerID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create SPIFFE client: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.Connect(ctx); err != nil {
+		return "", fmt.Errorf("connect to SPIFFE Workload API: %w", err)
+	}
+	if err := client.WaitForSVID(ctx, svidWaitTimeout); err != nil {
+		return "", fmt.Errorf("wait for SVID: %w", err)
+	}
+	id, err := client.GetSPIFFEID()
+	if err != nil {
+		return "", fmt.Errorf("get SPIFFE ID: %w", err)
+	}
+	return id.String(), nil
+}
+
+// zotConfig applies the TLS and auth settings of the secured registry to a
+// Zot config.
+func (sr *secureRegistry) zotConfig(zotConfig json.RawMessage) (json.RawMessage, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return registry.SecureZotConfig(zotConfig, sr.material)
+}
+
+// mirrorAuth returns what the container runtimes need to pull from the
+// secured registry, or nil when it is served without TLS.
+func mirrorAuth(cm *config.ConfigManager) *runtime.MirrorAuth {
+	access, ok := cm.GetSecureRegistryAccess()
+	if !ok {
+		return nil
+	}
+	return &runtime.MirrorAuth{
+		CAFile:   access.CAFile,
+		Username: access.PullUsername,
+		Password: access.PullPassword,
+	}
+}
+
+// mirrorOptions returns the TLS settings of the local mirror, the fallback
+// mirrors and whether to reload the runtimes from the registry fallback
+// config.
+func mirrorOptions(fbCfg config.RegistryFallbackConfig) runtime.MirrorOptions {
+	opts := runtime.MirrorOptions{Local: mirrorEndpoint(fbCfg.LocalMirror), Reload: fbCfg.Reload}
+	for _, m := range fbCfg.FallbackMirrors {
+		opts.Fallbacks = append(opts.Fallbacks, mirrorEndpoint(m))
+	}
+	return opts
+}
+
+func mirrorEndpoint(m config.MirrorEndpointConfig) runtime.MirrorEndpoint {
+	return runtime.MirrorEndpoint{
+		URL:            m.URL,
+		PathPrefix:     m.PathPrefix,
+		CAFile:         m.CAFile,
+		ClientCertFile: m.ClientCertFile,
+		ClientKeyFile:  m.ClientKeyFile,
+		SkipVerify:     m.SkipVerify,
+		Header:         m.Headers,
+	}
+}
+
+// renewLoop reissues the serving certificate when it nears expiry and
+// restarts the registry with it. The CA and the accounts are kept, so the
+// runtime configs stay valid.
+func (sr *secureRegistry) renewLoop(ctx context.Context, log zerolog.Logger, zotSupervisor *registry.Supervisor) error {
+	ticker := time.NewTicker(secureRegistryRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			sr.mu.Lock()
+			due := sr.material.NeedsRenewal(time.Now())
+			sr.mu.Unlock()
+			if !due {
+				continue
+			}
+			if err := sr.renew(ctx, zotSupervisor); err != nil {
+				log.Error().Err(err).Msg("Failed to renew the secure registry certificate")
+				continue
+			}
+			log.Info().Msg("Secure registry certificate renewed")
+		}
+	}
+}
+
+func (sr *secureRegistry) renew(ctx context.Context, zotSupervisor *registry.Supervisor) error {
+	m, err := registry.ProvisionSecureRegistry(sr.opts)
+	if err != nil {
+		return err
+	}
+	sr.mu.Lock()
+	sr.material = m
+	sr.mu.Unlock()
+
+	zotConfig, err := sr.zotConfig(sr.cm.GetRawZotConfig())
+	if err != nil {
+		return err
+	}
+	return zotSupervisor.Restart(ctx, zotConfig, "registry serving certificate renewed")
+}
@@ -0,0 +1,60 @@
+// Command satellite-operator reconciles Satellite, SatelliteGroup and
+// SatelliteConfig custom resources against Ground Control.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/container-registry/harbor-satellite/internal/env"
+	"github.com/container-registry/harbor-satellite/internal/kube"
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/operator"
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/gcclient"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := env.LoadOperator(); err != nil {
+		return fmt.Errorf("load environment: %w", err)
+	}
+	cfg := env.Op
+	if cfg.GroundControlURL == "" || cfg.GroundControlUsername == "" || cfg.GroundControlPassword == "" {
+		return errors.New("GROUND_CONTROL_URL, GROUND_CONTROL_USERNAME and GROUND_CONTROL_PASSWORD are required")
+	}
+	if cfg.ReconcileInterval <= 0 {
+		return fmt.Errorf("invalid RECONCILE_INTERVAL %s", cfg.ReconcileInterval)
+	}
+
+	ctx, cancel := utils.SetupContext(context.Background())
+	defer cancel()
+	ctx, log := logger.InitLogger(ctx, "info", cfg.JSONLogging, nil)
+
+	client, err := kube.InClusterClient()
+	if err != nil {
+		return err
+	}
+	namespace := cfg.WatchNamespace
+	if namespace == "" {
+		namespace = client.Namespace
+	}
+
+	log.Info().Str("namespace", namespace).Str("ground_control", cfg.GroundControlURL).Msg("Starting satellite operator")
+	op := &operator.Operator{
+		Kube:      client,
+		GC:        gcclient.New(cfg.GroundControlURL, gcclient.WithCredentials(cfg.GroundControlUsername, cfg.GroundControlPassword)),
+		Namespace: namespace,
+		Interval:  cfg.ReconcileInterval,
+		Log:       log.With().Str("component", "operator").Logger(),
+	}
+	return op.Run(ctx)
+}
@@ -7,6 +7,7 @@ import (
 var (
 	GC        GroundControl
 	Satellite HarborSatellite
+	Op        Operator
 )
 
 func LoadGC() error {
@@ -26,3 +27,12 @@ func LoadSatellite() error {
 	Satellite = cfg
 	return nil
 }
+
+func LoadOperator() error {
+	var cfg Operator
+	if err := envparser.Parse(&cfg); err != nil {
+		return err
+	}
+	Op = cfg
+	return nil
+}
@@ -108,3 +108,21 @@ func TestLoadSatelliteParsesEnvironment(t *testing.T) {
 		t.Fatalf("parsec env was not parsed: %+v", cfg)
 	}
 }
+
+func TestLoadOperatorParsesEnvironment(t *testing.T) {
+	t.Setenv("GROUND_CONTROL_URL", "https://gc.example")
+	t.Setenv("GROUND_CONTROL_USERNAME", "admin")
+	t.Setenv("RECONCILE_INTERVAL", "1m")
+
+	if err := LoadOperator(); err != nil {
+		t.Fatalf("LoadOperator() error = %v", err)
+	}
+	cfg := Op
+
+	if cfg.GroundControlURL != "https://gc.example" || cfg.GroundControlUsername != "admin" {
+		t.Fatalf("operator env was not parsed: %+v", cfg)
+	}
+	if cfg.ReconcileInterval != time.Minute || !cfg.JSONLogging {
+		t.Fatalf("operator defaults were not applied: %+v", cfg)
+	}
+}
@@ -47,6 +47,8 @@ type Server struct {
 	SessionDuration time.Duration `env:"SESSION_DURATION" envDefault:"24h"`
 	LockoutDuration time.Duration `env:"LOCKOUT_DURATION" envDefault:"5m"`
 	StaleThreshold  time.Duration `env:"STALE_THRESHOLD"  envDefault:"1h"`
+	MetricsEnabled  bool          `env:"METRICS_ENABLED"  envDefault:"false"`
+	MetricsToken    string        `env:"METRICS_TOKEN"`
 }
 
 type PasswordPolicy struct {
@@ -94,6 +96,7 @@ func (t TLS) Enabled() bool {
 type Audit struct {
 	SyslogAddress         string  `env:"SYSLOG_ADDRESS"`
 	OTelEndpoint          string  `env:"OTEL_ENDPOINT"`
+	OTelTracesEnabled     bool    `env:"OTEL_TRACES_ENABLED"      envDefault:"false"`
 	SyslogFilePath        *string `env:"SYSLOG_FILE_PATH"`
 	LogEnabled            bool    `env:"LOG_ENABLED"              envDefault:"false"`
 	TrustForwardedHeaders bool    `env:"TRUST_FORWARDED_HEADERS"  envDefault:"false"`
@@ -23,8 +23,16 @@ type HarborSatellite struct {
 	HarborRegistryURL      string `env:"HARBOR_REGISTRY_URL"`
 	DirectDelivery         bool   `env:"DIRECT_DELIVERY"           envDefault:"false"`
 	ImageDir               string `env:"IMAGE_DIR"`
+	DirectDeliveryBackend  string `env:"DIRECT_DELIVERY_BACKEND"`
 	ParsecEnabled          bool   `env:"PARSEC_ENABLED"            envDefault:"false"`
 	ParsecSocketPath       string `env:"PARSEC_SOCKET"             envDefault:"/run/parsec/parsec.sock"`
+	AdminAddr              string `env:"ADMIN_ADDR"`
+	MetricsAddr            string `env:"METRICS_ADDR"`
+	PrewarmAgentURL        string `env:"PREWARM_AGENT_URL"`
+	NodeConfigAgent        string `env:"NODE_CONFIG_AGENT"`
+	NodeName               string `env:"NODE_NAME"`
+	CRIEndpoint            string `env:"CRI_ENDPOINT"`
+	NodeAgentToken         string `env:"NODE_AGENT_TOKEN"`
 }
 
 func (h HarborSatellite) ApplyDefaults() HarborSatellite {
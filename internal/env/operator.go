@@ -0,0 +1,12 @@
+package env
+
+import "time"
+
+type Operator struct {
+	GroundControlURL      string        `env:"GROUND_CONTROL_URL"`
+	GroundControlUsername string        `env:"GROUND_CONTROL_USERNAME"`
+	GroundControlPassword string        `env:"GROUND_CONTROL_PASSWORD"`
+	WatchNamespace        string        `env:"WATCH_NAMESPACE"`
+	ReconcileInterval     time.Duration `env:"RECONCILE_INTERVAL"      envDefault:"30s"`
+	JSONLogging           bool          `env:"JSON_LOGGING"            envDefault:"true"`
+}
@@ -10,13 +10,6 @@ import (
 	"time"
 )
 
-type Artifact struct {
-	ID        int32
-	Reference string
-	SizeBytes int64
-	CreatedAt time.Time
-}
-
 type Config struct {
 	ID          int32
 	ConfigName  string
@@ -26,6 +19,43 @@ type Config struct {
 	UpdatedAt   time.Time
 }
 
+type ConfigRevision struct {
+	ID        int32
+	ConfigID  int32
+	Revision  int32
+	Config    json.RawMessage
+	Author    string
+	Source    string
+	Message   string
+	CreatedAt time.Time
+}
+
+type ConfigRollout struct {
+	ID            int32
+	ConfigID      int32
+	Config        json.RawMessage
+	ConfigDigest  string
+	Status        string
+	Waves         []int32
+	CurrentWave   int32
+	AutoPromote   bool
+	BakeSeconds   int32
+	MaxFailures   int32
+	Message       string
+	CreatedBy     string
+	WaveStartedAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type ConfigRolloutSatellite struct {
+	RolloutID    int32
+	SatelliteID  int32
+	Wave         int32
+	AddedAt      time.Time
+	ConfigDigest sql.NullString
+}
+
 type Group struct {
 	ID          int32
 	GroupName   string
@@ -35,6 +65,28 @@ type Group struct {
 	UpdatedAt   time.Time
 }
 
+type GroupConfigLayer struct {
+	GroupID   int32
+	Patch     json.RawMessage
+	Priority  int32
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+type GroupSelector struct {
+	GroupID   int32
+	Selector  string
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+type ImageManifest struct {
+	ID        int32
+	Digest    string
+	SizeBytes int64
+	CreatedAt time.Time
+}
+
 type LoginAttempt struct {
 	ID          int32
 	Username    string
@@ -68,25 +120,60 @@ type SatelliteConfig struct {
 	ConfigID    int32
 }
 
+type SatelliteConfigOverride struct {
+	SatelliteID int32
+	Patch       json.RawMessage
+	UpdatedBy   string
+	UpdatedAt   time.Time
+}
+
 type SatelliteGroup struct {
 	SatelliteID int32
 	GroupID     int32
 }
 
+type SatelliteImage struct {
+	SatelliteID int32
+	Repository  string
+	Tag         string
+	ManifestID  int32
+	UpdatedAt   time.Time
+}
+
+type SatelliteInventory struct {
+	SatelliteID  int32
+	SnapshotHash string
+	ImageCount   int32
+	UpdatedAt    time.Time
+}
+
+type SatelliteLabel struct {
+	SatelliteID int32
+	Key         string
+	Value       string
+}
+
 type SatelliteStatus struct {
-	ID                 int32
-	SatelliteID        int32
-	Activity           string
-	LatestStateDigest  sql.NullString
-	LatestConfigDigest sql.NullString
-	CpuPercent         sql.NullString
-	MemoryUsedBytes    sql.NullInt64
-	StorageUsedBytes   sql.NullInt64
-	LastSyncDurationMs sql.NullInt64
-	ImageCount         sql.NullInt32
-	ReportedAt         time.Time
-	CreatedAt          time.Time
-	ArtifactIds        []int32
+	ID                    int32
+	SatelliteID           int32
+	Activity              string
+	LatestStateDigest     sql.NullString
+	LatestConfigDigest    sql.NullString
+	CpuPercent            sql.NullString
+	MemoryUsedBytes       sql.NullInt64
+	StorageUsedBytes      sql.NullInt64
+	LastSyncDurationMs    sql.NullInt64
+	ImageCount            sql.NullInt32
+	ReportedAt            time.Time
+	CreatedAt             time.Time
+	Breakers              json.RawMessage
+	RejectedConfigDigest  sql.NullString
+	LastSyncError         sql.NullString
+	ConfigRevision        sql.NullInt32
+	RegistryUptimeSeconds sql.NullInt64
+	RegistryRestarts      sql.NullInt32
+	Prewarm               json.RawMessage
+	ImagesStaged          sql.NullBool
 }
 
 type SatelliteToken struct {
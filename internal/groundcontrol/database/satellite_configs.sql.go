@@ -37,6 +37,42 @@ func (q *Queries) ConfigSatelliteList(ctx context.Context, configID int32) ([]Sa
 	return items, nil
 }
 
+const listConfigSatellites = `-- name: ListConfigSatellites :many
+SELECT s.id, s.name
+FROM satellites s
+JOIN satellite_configs sc ON sc.satellite_id = s.id
+WHERE sc.config_id = $1
+ORDER BY s.name
+`
+
+type ListConfigSatellitesRow struct {
+	ID   int32
+	Name string
+}
+
+func (q *Queries) ListConfigSatellites(ctx context.Context, configID int32) ([]ListConfigSatellitesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConfigSatellites, configID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListConfigSatellitesRow
+	for rows.Next() {
+		var i ListConfigSatellitesRow
+		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const removeSatelliteFromConfig = `-- name: RemoveSatelliteFromConfig :exec
 DELETE FROM satellite_configs
 WHERE satellite_id = $1 AND config_id = $2
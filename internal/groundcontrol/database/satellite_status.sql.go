@@ -8,9 +8,8 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
-
-	"github.com/lib/pq"
 )
 
 const deleteOldSatelliteStatus = `-- name: DeleteOldSatelliteStatus :exec
@@ -87,47 +86,8 @@ func (q *Queries) GetActiveSatellites(ctx context.Context) ([]GetActiveSatellite
 	return items, nil
 }
 
-const getLatestArtifacts = `-- name: GetLatestArtifacts :many
-SELECT a.id, a.reference, a.size_bytes, a.created_at
-FROM artifacts a
-WHERE a.id = ANY(
-    (SELECT artifact_ids FROM satellite_status
-     WHERE satellite_id = $1
-     ORDER BY created_at DESC LIMIT 1)
-)
-ORDER BY a.reference
-`
-
-func (q *Queries) GetLatestArtifacts(ctx context.Context, satelliteID int32) ([]Artifact, error) {
-	rows, err := q.db.QueryContext(ctx, getLatestArtifacts, satelliteID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Artifact
-	for rows.Next() {
-		var i Artifact
-		if err := rows.Scan(
-			&i.ID,
-			&i.Reference,
-			&i.SizeBytes,
-			&i.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
-}
-
 const getLatestSatelliteStatus = `-- name: GetLatestSatelliteStatus :one
-SELECT id, satellite_id, activity, latest_state_digest, latest_config_digest, cpu_percent, memory_used_bytes, storage_used_bytes, last_sync_duration_ms, image_count, reported_at, created_at, artifact_ids FROM satellite_status
+SELECT id, satellite_id, activity, latest_state_digest, latest_config_digest, cpu_percent, memory_used_bytes, storage_used_bytes, last_sync_duration_ms, image_count, reported_at, created_at, breakers, rejected_config_digest, last_sync_error, config_revision, registry_uptime_seconds, registry_restarts, prewarm, images_staged FROM satellite_status
 WHERE satellite_id = $1 ORDER BY created_at DESC LIMIT 1
 `
 
@@ -147,13 +107,20 @@ func (q *Queries) GetLatestSatelliteStatus(ctx context.Context, satelliteID int3
 		&i.ImageCount,
 		&i.ReportedAt,
 		&i.CreatedAt,
-		pq.Array(&i.ArtifactIds),
+		&i.Breakers,
+		&i.RejectedConfigDigest,
+		&i.LastSyncError,
+		&i.ConfigRevision,
+		&i.RegistryUptimeSeconds,
+		&i.RegistryRestarts,
+		&i.Prewarm,
+		&i.ImagesStaged,
 	)
 	return i, err
 }
 
 const getSatelliteStatusHistory = `-- name: GetSatelliteStatusHistory :many
-SELECT id, satellite_id, activity, latest_state_digest, latest_config_digest, cpu_percent, memory_used_bytes, storage_used_bytes, last_sync_duration_ms, image_count, reported_at, created_at, artifact_ids FROM satellite_status
+SELECT id, satellite_id, activity, latest_state_digest, latest_config_digest, cpu_percent, memory_used_bytes, storage_used_bytes, last_sync_duration_ms, image_count, reported_at, created_at, breakers, rejected_config_digest, last_sync_error, config_revision, registry_uptime_seconds, registry_restarts, prewarm, images_staged FROM satellite_status
 WHERE satellite_id = $1
 ORDER BY created_at DESC
 LIMIT $2
@@ -186,7 +153,14 @@ func (q *Queries) GetSatelliteStatusHistory(ctx context.Context, arg GetSatellit
 			&i.ImageCount,
 			&i.ReportedAt,
 			&i.CreatedAt,
-			pq.Array(&i.ArtifactIds),
+			&i.Breakers,
+			&i.RejectedConfigDigest,
+			&i.LastSyncError,
+			&i.ConfigRevision,
+			&i.RegistryUptimeSeconds,
+			&i.RegistryRestarts,
+			&i.Prewarm,
+			&i.ImagesStaged,
 		); err != nil {
 			return nil, err
 		}
@@ -261,24 +235,33 @@ const insertSatelliteStatus = `-- name: InsertSatelliteStatus :one
 INSERT INTO satellite_status (
     satellite_id, activity, latest_state_digest, latest_config_digest,
     cpu_percent, memory_used_bytes, storage_used_bytes,
-    last_sync_duration_ms, image_count, reported_at, artifact_ids
+    last_sync_duration_ms, image_count, reported_at, breakers,
+    rejected_config_digest, last_sync_error, config_revision,
+    registry_uptime_seconds, registry_restarts, prewarm, images_staged
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-RETURNING id, satellite_id, activity, latest_state_digest, latest_config_digest, cpu_percent, memory_used_bytes, storage_used_bytes, last_sync_duration_ms, image_count, reported_at, created_at, artifact_ids
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+RETURNING id, satellite_id, activity, latest_state_digest, latest_config_digest, cpu_percent, memory_used_bytes, storage_used_bytes, last_sync_duration_ms, image_count, reported_at, created_at, breakers, rejected_config_digest, last_sync_error, config_revision, registry_uptime_seconds, registry_restarts, prewarm, images_staged
 `
 
 type InsertSatelliteStatusParams struct {
-	SatelliteID        int32
-	Activity           string
-	LatestStateDigest  sql.NullString
-	LatestConfigDigest sql.NullString
-	CpuPercent         sql.NullString
-	MemoryUsedBytes    sql.NullInt64
-	StorageUsedBytes   sql.NullInt64
-	LastSyncDurationMs sql.NullInt64
-	ImageCount         sql.NullInt32
-	ReportedAt         time.Time
-	ArtifactIds        []int32
+	SatelliteID           int32
+	Activity              string
+	LatestStateDigest     sql.NullString
+	LatestConfigDigest    sql.NullString
+	CpuPercent            sql.NullString
+	MemoryUsedBytes       sql.NullInt64
+	StorageUsedBytes      sql.NullInt64
+	LastSyncDurationMs    sql.NullInt64
+	ImageCount            sql.NullInt32
+	ReportedAt            time.Time
+	Breakers              json.RawMessage
+	RejectedConfigDigest  sql.NullString
+	LastSyncError         sql.NullString
+	ConfigRevision        sql.NullInt32
+	RegistryUptimeSeconds sql.NullInt64
+	RegistryRestarts      sql.NullInt32
+	Prewarm               json.RawMessage
+	ImagesStaged          sql.NullBool
 }
 
 func (q *Queries) InsertSatelliteStatus(ctx context.Context, arg InsertSatelliteStatusParams) (SatelliteStatus, error) {
@@ -293,7 +276,14 @@ func (q *Queries) InsertSatelliteStatus(ctx context.Context, arg InsertSatellite
 		arg.LastSyncDurationMs,
 		arg.ImageCount,
 		arg.ReportedAt,
-		pq.Array(arg.ArtifactIds),
+		arg.Breakers,
+		arg.RejectedConfigDigest,
+		arg.LastSyncError,
+		arg.ConfigRevision,
+		arg.RegistryUptimeSeconds,
+		arg.RegistryRestarts,
+		arg.Prewarm,
+		arg.ImagesStaged,
 	)
 	var i SatelliteStatus
 	err := row.Scan(
@@ -309,11 +299,67 @@ func (q *Queries) InsertSatelliteStatus(ctx context.Context, arg InsertSatellite
 		&i.ImageCount,
 		&i.ReportedAt,
 		&i.CreatedAt,
-		pq.Array(&i.ArtifactIds),
+		&i.Breakers,
+		&i.RejectedConfigDigest,
+		&i.LastSyncError,
+		&i.ConfigRevision,
+		&i.RegistryUptimeSeconds,
+		&i.RegistryRestarts,
+		&i.Prewarm,
+		&i.ImagesStaged,
 	)
 	return i, err
 }
 
+const listLatestSatelliteStatuses = `-- name: ListLatestSatelliteStatuses :many
+SELECT DISTINCT ON (satellite_id) id, satellite_id, activity, latest_state_digest, latest_config_digest, cpu_percent, memory_used_bytes, storage_used_bytes, last_sync_duration_ms, image_count, reported_at, created_at, breakers, rejected_config_digest, last_sync_error, config_revision, registry_uptime_seconds, registry_restarts, prewarm, images_staged FROM satellite_status
+ORDER BY satellite_id, created_at DESC
+`
+
+func (q *Queries) ListLatestSatelliteStatuses(ctx context.Context) ([]SatelliteStatus, error) {
+	rows, err := q.db.QueryContext(ctx, listLatestSatelliteStatuses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SatelliteStatus
+	for rows.Next() {
+		var i SatelliteStatus
+		if err := rows.Scan(
+			&i.ID,
+			&i.SatelliteID,
+			&i.Activity,
+			&i.LatestStateDigest,
+			&i.LatestConfigDigest,
+			&i.CpuPercent,
+			&i.MemoryUsedBytes,
+			&i.StorageUsedBytes,
+			&i.LastSyncDurationMs,
+			&i.ImageCount,
+			&i.ReportedAt,
+			&i.CreatedAt,
+			&i.Breakers,
+			&i.RejectedConfigDigest,
+			&i.LastSyncError,
+			&i.ConfigRevision,
+			&i.RegistryUptimeSeconds,
+			&i.RegistryRestarts,
+			&i.Prewarm,
+			&i.ImagesStaged,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateSatelliteLastSeen = `-- name: UpdateSatelliteLastSeen :exec
 UPDATE satellites SET last_seen = NOW(), heartbeat_interval = $2 WHERE id = $1
 `
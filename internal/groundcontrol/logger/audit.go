@@ -67,6 +67,14 @@ const (
 	ResSession   ResourceType = "session"
 	ResPolicy    ResourceType = "policy"
 	ResRobot     ResourceType = "robot"
+	// ResConfigRollout is a staged rollout of a config change.
+	ResConfigRollout ResourceType = "config_rollout"
+	// ResConfigLayer is a group's config layer or a satellite's config
+	// override.
+	ResConfigLayer ResourceType = "config_layer"
+	// ResGroupSelector is the label selector that manages the members of a
+	// dynamic group.
+	ResGroupSelector ResourceType = "group_selector"
 )
 
 // Outcome records whether the action succeeded.
@@ -109,6 +117,7 @@ const (
 	ReasonForbidden              Reason = "forbidden"
 	ReasonNotFound               Reason = "not_found"
 	ReasonRateLimited            Reason = "rate_limited"
+	ReasonRolloutHalted          Reason = "rollout_halted"
 )
 
 // AuditEvent is a single security-relevant event. Callers populate the semantic
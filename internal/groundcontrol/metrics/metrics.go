@@ -0,0 +1,240 @@
+// Package metrics exposes Ground Control's Prometheus metrics: API request
+// latency, ZTR outcomes and fleet gauges read from the satellite status tables.
+package metrics
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "ground_control"
+
+	// collectTimeout bounds the database queries run on each scrape.
+	collectTimeout = 10 * time.Second
+)
+
+// Outcome label values for ZTR.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+var (
+	// RequestDuration observes API latency per route template.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of API requests by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	// ZTR counts zero-touch registration attempts by flow and outcome.
+	ZTR = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ztr_total",
+		Help:      "Zero-touch registration attempts by flow (token or spiffe) and outcome.",
+	}, []string{"flow", "outcome"})
+)
+
+// FleetQuerier is the subset of database queries read by the fleet collector.
+type FleetQuerier interface {
+	ListSatellites(ctx context.Context) ([]database.Satellite, error)
+	GetActiveSatellites(ctx context.Context) ([]database.GetActiveSatellitesRow, error)
+	GetStaleSatellites(ctx context.Context) ([]database.GetStaleSatellitesRow, error)
+	ListLatestSatelliteStatuses(ctx context.Context) ([]database.SatelliteStatus, error)
+}
+
+// NewRegistry returns a registry holding the process, request and ZTR metrics
+// plus a fleet collector backed by q.
+func NewRegistry(q FleetQuerier) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration,
+		ZTR,
+		NewFleetCollector(q),
+	)
+	return reg
+}
+
+// Handler serves the metrics in reg.
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// Middleware records request latency labelled with the matched mux route
+// template, so path parameters do not explode the label cardinality.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tmpl, err := cur.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		RequestDuration.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ZTRMiddleware counts registration outcomes for a ZTR flow. Any response
+// below 400 counts as a success.
+func ZTRMiddleware(flow string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			outcome := OutcomeSuccess
+			if rec.status >= http.StatusBadRequest {
+				outcome = OutcomeFailure
+			}
+			ZTR.WithLabelValues(flow, outcome).Inc()
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// FleetCollector reads satellite status from the database at scrape time.
+type FleetCollector struct {
+	q FleetQuerier
+
+	active         *prometheus.Desc
+	stale          *prometheus.Desc
+	registered     *prometheus.Desc
+	lastSeenAge    *prometheus.Desc
+	cpu            *prometheus.Desc
+	memory         *prometheus.Desc
+	storage        *prometheus.Desc
+	images         *prometheus.Desc
+	syncDuration   *prometheus.Desc
+	scrapeFailures *prometheus.Desc
+}
+
+// NewFleetCollector creates a collector backed by q.
+func NewFleetCollector(q FleetQuerier) *FleetCollector {
+	sat := []string{"satellite"}
+	desc := func(name, help string, labels []string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+	}
+	return &FleetCollector{
+		q:              q,
+		active:         desc("satellites_active", "Satellites that reported within three heartbeat intervals.", nil),
+		stale:          desc("satellites_stale", "Satellites that missed three heartbeat intervals.", nil),
+		registered:     desc("satellites_registered", "Satellites registered with Ground Control.", nil),
+		lastSeenAge:    desc("satellite_last_seen_age_seconds", "Seconds since the satellite last reported.", sat),
+		cpu:            desc("satellite_cpu_percent", "CPU usage reported in the latest heartbeat.", sat),
+		memory:         desc("satellite_memory_used_bytes", "Memory usage reported in the latest heartbeat.", sat),
+		storage:        desc("satellite_storage_used_bytes", "Storage usage reported in the latest heartbeat.", sat),
+		images:         desc("satellite_image_count", "Cached image count reported in the latest heartbeat.", sat),
+		syncDuration:   desc("satellite_last_sync_duration_seconds", "Duration of the last sync reported in the latest heartbeat.", sat),
+		scrapeFailures: desc("fleet_scrape_errors", "Database queries that failed during this scrape.", nil),
+	}
+}
+
+func (c *FleetCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.active, c.stale, c.registered, c.lastSeenAge, c.cpu,
+		c.memory, c.storage, c.images, c.syncDuration, c.scrapeFailures,
+	} {
+		ch <- d
+	}
+}
+
+func (c *FleetCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	failures := 0
+
+	if active, err := c.q.GetActiveSatellites(ctx); err != nil {
+		log.Printf("metrics: failed to get active satellites: %v", err)
+		failures++
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(len(active)))
+	}
+
+	if stale, err := c.q.GetStaleSatellites(ctx); err != nil {
+		log.Printf("metrics: failed to get stale satellites: %v", err)
+		failures++
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.stale, prometheus.GaugeValue, float64(len(stale)))
+	}
+
+	satellites, err := c.q.ListSatellites(ctx)
+	if err != nil {
+		log.Printf("metrics: failed to list satellites: %v", err)
+		failures++
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.registered, prometheus.GaugeValue, float64(len(satellites)))
+	}
+
+	// The latest status of every satellite is read in one query rather
+	// than one per satellite.
+	latest := map[int32]database.SatelliteStatus{}
+	if len(satellites) > 0 {
+		statuses, err := c.q.ListLatestSatelliteStatuses(ctx)
+		if err != nil {
+			log.Printf("metrics: failed to get latest satellite statuses: %v", err)
+			failures++
+		}
+		for _, status := range statuses {
+			latest[status.SatelliteID] = status
+		}
+	}
+
+	now := time.Now()
+	for _, sat := range satellites {
+		if sat.LastSeen.Valid {
+			ch <- prometheus.MustNewConstMetric(c.lastSeenAge, prometheus.GaugeValue, now.Sub(sat.LastSeen.Time).Seconds(), sat.Name)
+		}
+		if status, ok := latest[sat.ID]; ok {
+			c.collectStatus(ch, sat.Name, status)
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.scrapeFailures, prometheus.GaugeValue, float64(failures))
+}
+
+func (c *FleetCollector) collectStatus(ch chan<- prometheus.Metric, name string, status database.SatelliteStatus) {
+	if status.CpuPercent.Valid {
+		if cpu, err := strconv.ParseFloat(status.CpuPercent.String, 64); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.GaugeValue, cpu, name)
+		}
+	}
+	if status.MemoryUsedBytes.Valid {
+		ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(status.MemoryUsedBytes.Int64), name)
+	}
+	if status.StorageUsedBytes.Valid {
+		ch <- prometheus.MustNewConstMetric(c.storage, prometheus.GaugeValue, float64(status.StorageUsedBytes.Int64), name)
+	}
+	if status.ImageCount.Valid {
+		ch <- prometheus.MustNewConstMetric(c.images, prometheus.GaugeValue, float64(status.ImageCount.Int32), name)
+	}
+	if status.LastSyncDurationMs.Valid {
+		ch <- prometheus.MustNewConstMetric(c.syncDuration, prometheus.GaugeValue, float64(status.LastSyncDurationMs.Int64)/1000, name)
+	}
+}
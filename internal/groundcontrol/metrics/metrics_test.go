@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFleet struct {
+	satellites []database.Satellite
+	active     int
+	stale      int
+	statuses   map[int32]database.SatelliteStatus
+	activeErr  error
+
+	statusQueries int
+}
+
+func (f *fakeFleet) ListSatellites(context.Context) ([]database.Satellite, error) {
+	return f.satellites, nil
+}
+
+func (f *fakeFleet) GetActiveSatellites(context.Context) ([]database.GetActiveSatellitesRow, error) {
+	if f.activeErr != nil {
+		return nil, f.activeErr
+	}
+	return make([]database.GetActiveSatellitesRow, f.active), nil
+}
+
+func (f *fakeFleet) GetStaleSatellites(context.Context) ([]database.GetStaleSatellitesRow, error) {
+	return make([]database.GetStaleSatellitesRow, f.stale), nil
+}
+
+func (f *fakeFleet) ListLatestSatelliteStatuses(context.Context) ([]database.SatelliteStatus, error) {
+	f.statusQueries++
+	var statuses []database.SatelliteStatus
+	for _, st := range f.statuses {
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+func TestFleetCollector(t *testing.T) {
+	fleet := &fakeFleet{
+		satellites: []database.Satellite{
+			{ID: 1, Name: "edge-1", LastSeen: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}},
+			{ID: 2, Name: "edge-2"},
+		},
+		active: 1,
+		stale:  1,
+		statuses: map[int32]database.SatelliteStatus{
+			1: {
+				SatelliteID:        1,
+				CpuPercent:         sql.NullString{String: "12.50", Valid: true},
+				MemoryUsedBytes:    sql.NullInt64{Int64: 2048, Valid: true},
+				StorageUsedBytes:   sql.NullInt64{Int64: 4096, Valid: true},
+				ImageCount:         sql.NullInt32{Int32: 7, Valid: true},
+				LastSyncDurationMs: sql.NullInt64{Int64: 1500, Valid: true},
+			},
+		},
+	}
+
+	expected := `
+# HELP ground_control_satellite_cpu_percent CPU usage reported in the latest heartbeat.
+# TYPE ground_control_satellite_cpu_percent gauge
+ground_control_satellite_cpu_percent{satellite="edge-1"} 12.5
+# HELP ground_control_satellite_image_count Cached image count reported in the latest heartbeat.
+# TYPE ground_control_satellite_image_count gauge
+ground_control_satellite_image_count{satellite="edge-1"} 7
+# HELP ground_control_satellite_last_sync_duration_seconds Duration of the last sync reported in the latest heartbeat.
+# TYPE ground_control_satellite_last_sync_duration_seconds gauge
+ground_control_satellite_last_sync_duration_seconds{satellite="edge-1"} 1.5
+# HELP ground_control_satellites_active Satellites that reported within three heartbeat intervals.
+# TYPE ground_control_satellites_active gauge
+ground_control_satellites_active 1
+# HELP ground_control_satellites_registered Satellites registered with Ground Control.
+# TYPE ground_control_satellites_registered gauge
+ground_control_satellites_registered 2
+# HELP ground_control_satellites_stale Satellites that missed three heartbeat intervals.
+# TYPE ground_control_satellites_stale gauge
+ground_control_satellites_stale 1
+`
+	c := NewFleetCollector(fleet)
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"ground_control_satellite_cpu_percent",
+		"ground_control_satellite_image_count",
+		"ground_control_satellite_last_sync_duration_seconds",
+		"ground_control_satellites_active",
+		"ground_control_satellites_registered",
+		"ground_control_satellites_stale",
+	))
+
+	// edge-2 has never reported: no last-seen age and no status gauges.
+	require.Equal(t, 1, testutil.CollectAndCount(c, "ground_control_satellite_last_seen_age_seconds"))
+	require.Equal(t, 1, testutil.CollectAndCount(c, "ground_control_satellite_memory_used_bytes"))
+	// Three scrapes ran a single status query each, not one per satellite.
+	require.Equal(t, 3, fleet.statusQueries)
+}
+
+func TestFleetCollectorReportsQueryFailures(t *testing.T) {
+	c := NewFleetCollector(&fakeFleet{activeErr: errors.New("db down")})
+
+	expected := `
+# HELP ground_control_fleet_scrape_errors Database queries that failed during this scrape.
+# TYPE ground_control_fleet_scrape_errors gauge
+ground_control_fleet_scrape_errors 1
+`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected), "ground_control_fleet_scrape_errors"))
+	require.Equal(t, 0, testutil.CollectAndCount(c, "ground_control_satellites_active"))
+}
+
+func TestMiddlewareUsesRouteTemplate(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Middleware)
+	r.HandleFunc("/api/satellites/{satellite}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/satellites/edge-1", nil))
+
+	require.Equal(t, 1, testutil.CollectAndCount(RequestDuration))
+	require.Equal(t, uint64(1), histogramCount(t, "GET", "/api/satellites/{satellite}", "404"))
+}
+
+func TestZTRMiddleware(t *testing.T) {
+	handler := func(code int) http.Handler {
+		return ZTRMiddleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+	}
+
+	handler(http.StatusOK).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler(http.StatusUnauthorized).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler(http.StatusTooManyRequests).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(ZTR.WithLabelValues("test", OutcomeSuccess)))
+	require.Equal(t, 2.0, testutil.ToFloat64(ZTR.WithLabelValues("test", OutcomeFailure)))
+}
+
+func histogramCount(t *testing.T, labels ...string) uint64 {
+	t.Helper()
+	reg := NewRegistry(&fakeFleet{})
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != "ground_control_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			got := make([]string, 0, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				got = append(got, l.GetValue())
+			}
+			// Labels are returned sorted by name: code, method, route.
+			if len(got) == 3 && got[0] == labels[2] && got[1] == labels[0] && got[2] == labels[1] {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
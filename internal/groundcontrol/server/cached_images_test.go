@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/container-registry/harbor-satellite/internal/inventory"
 	"github.com/gorilla/mux"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
@@ -34,111 +36,205 @@ func newMockServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
 	}, mock
 }
 
-func TestSyncHandler_WithCachedImages(t *testing.T) {
-	server, mock := newMockServer(t)
-
-	now := time.Now().UTC().Truncate(time.Second)
-
-	// Mock GetSatelliteByName
+func expectSatellite(mock sqlmock.Sqlmock, now time.Time) {
 	satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
 		AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
 	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
 		WithArgs("edge-01").
 		WillReturnRows(satRows)
+}
 
-	// Mock BatchInsertArtifacts
-	mock.ExpectExec("INSERT INTO artifacts").
-		WithArgs(
-			pq.Array([]string{
-				"localhost:8585/library/nginx:latest@sha256:abc",
-				"localhost:8585/library/alpine:3.18@sha256:def",
-			}),
-			pq.Array([]int64{50000, 5000}),
-		).
-		WillReturnResult(sqlmock.NewResult(0, 2))
-
-	// Mock GetArtifactIDsByReferences
-	artifactRows := sqlmock.NewRows([]string{"id", "reference", "size_bytes", "created_at"}).
-		AddRow(int32(10), "localhost:8585/library/nginx:latest@sha256:abc", int64(50000), now).
-		AddRow(int32(11), "localhost:8585/library/alpine:3.18@sha256:def", int64(5000), now)
-	mock.ExpectQuery("SELECT .+ FROM artifacts").
-		WithArgs(pq.Array([]string{
-			"localhost:8585/library/nginx:latest@sha256:abc",
-			"localhost:8585/library/alpine:3.18@sha256:def",
-		})).
-		WillReturnRows(artifactRows)
-
-	// Mock InsertSatelliteStatus (with artifact_ids)
+// expectStatusSaved expects the status insert, with args when given.
+func expectStatusSaved(mock sqlmock.Sqlmock, now time.Time, imageCount int32, args ...driver.Value) {
 	statusRows := sqlmock.NewRows([]string{
 		"id", "satellite_id", "activity", "latest_state_digest", "latest_config_digest",
 		"cpu_percent", "memory_used_bytes", "storage_used_bytes", "last_sync_duration_ms",
-		"image_count", "reported_at", "created_at", "artifact_ids",
+		"image_count", "reported_at", "created_at", "breakers", "rejected_config_digest", "last_sync_error", "config_revision",
+		"registry_uptime_seconds", "registry_restarts", "prewarm", "images_staged",
 	}).AddRow(
 		1, 1, "", sql.NullString{}, sql.NullString{},
 		sql.NullString{}, sql.NullInt64{}, sql.NullInt64{}, sql.NullInt64{},
-		sql.NullInt32{Int32: 2, Valid: true}, now, now, pq.Array([]int32{10, 11}),
+		sql.NullInt32{Int32: imageCount, Valid: true}, now, now, []byte("[]"), sql.NullString{}, sql.NullString{}, sql.NullInt32{},
+		sql.NullInt64{}, sql.NullInt32{}, []byte("[]"), sql.NullBool{},
 	)
-	mock.ExpectQuery("INSERT INTO satellite_status").WillReturnRows(statusRows)
-
-	// Mock UpdateSatelliteLastSeen
+	insert := mock.ExpectQuery("INSERT INTO satellite_status")
+	if len(args) > 0 {
+		insert = insert.WithArgs(args...)
+	}
+	insert.WillReturnRows(statusRows)
 	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+}
 
-	reqBody := SatelliteStatusParams{
-		Name:               "edge-01",
-		ImageCount:         2,
-		RequestCreatedTime: now,
-		CachedImages: []CachedImage{
-			{Reference: "localhost:8585/library/nginx:latest@sha256:abc", SizeBytes: 50000},
-			{Reference: "localhost:8585/library/alpine:3.18@sha256:def", SizeBytes: 5000},
-		},
-	}
-	body := mustMarshalJSON(t, reqBody)
-	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", bytes.NewReader(body))
+func postSync(t *testing.T, server *Server, params SatelliteStatusParams) SyncResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", bytes.NewReader(mustMarshalJSON(t, params)))
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
 	server.syncHandler(rr, req)
 
 	require.Equal(t, http.StatusOK, rr.Code)
-	require.NoError(t, mock.ExpectationsWereMet())
+	var resp SyncResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	return resp
 }
 
-func TestSyncHandler_NoCachedImages(t *testing.T) {
-	server, mock := newMockServer(t)
+var (
+	nginxImage  = inventory.Image{Ref: inventory.Ref{Repository: "library/nginx", Tag: "latest"}, Digest: "sha256:abc", SizeBytes: 50000}
+	alpineImage = inventory.Image{Ref: inventory.Ref{Repository: "library/alpine", Tag: "3.18"}, Digest: "sha256:def", SizeBytes: 5000}
+)
+
+func imageRows(now time.Time, imgs ...inventory.Image) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"repository", "tag", "digest", "size_bytes", "updated_at"})
+	for _, img := range imgs {
+		rows.AddRow(img.Repository, img.Tag, img.Digest, img.SizeBytes, now)
+	}
+	return rows
+}
 
+func TestSyncHandler_FullInventory(t *testing.T) {
+	server, mock := newMockServer(t)
 	now := time.Now().UTC().Truncate(time.Second)
+	hash := inventory.Hash([]inventory.Image{nginxImage, alpineImage})
 
-	satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
-		AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
-	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
-		WithArgs("edge-01").
-		WillReturnRows(satRows)
+	expectSatellite(mock, now)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .+ FROM satellite_inventory").
+		WithArgs(int32(1)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO image_manifests").
+		WithArgs(pq.Array([]string{"sha256:def", "sha256:abc"}), pq.Array([]int64{5000, 50000})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO satellite_images").
+		WithArgs(int32(1),
+			pq.Array([]string{"library/alpine", "library/nginx"}),
+			pq.Array([]string{"3.18", "latest"}),
+			pq.Array([]string{"sha256:def", "sha256:abc"}),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM satellite_images").
+		WithArgs(int32(1), pq.Array([]string{"library/alpine", "library/nginx"}), pq.Array([]string{"3.18", "latest"})).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT .+ FROM satellite_images").
+		WithArgs(int32(1)).
+		WillReturnRows(imageRows(now, alpineImage, nginxImage))
+	mock.ExpectExec("INSERT INTO satellite_inventory").
+		WithArgs(int32(1), hash, int32(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectStatusSaved(mock, now, 2)
+
+	resp := postSync(t, server, SatelliteStatusParams{
+		Name:               "edge-01",
+		ImageCount:         2,
+		RequestCreatedTime: now,
+		Inventory: &inventory.Report{
+			Full:         true,
+			Upserted:     []inventory.Image{alpineImage, nginxImage},
+			SnapshotHash: hash,
+		},
+	})
+	require.False(t, resp.InventoryResync)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
 
-	statusRows := sqlmock.NewRows([]string{
-		"id", "satellite_id", "activity", "latest_state_digest", "latest_config_digest",
-		"cpu_percent", "memory_used_bytes", "storage_used_bytes", "last_sync_duration_ms",
-		"image_count", "reported_at", "created_at", "artifact_ids",
-	}).AddRow(
-		1, 1, "", sql.NullString{}, sql.NullString{},
-		sql.NullString{}, sql.NullInt64{}, sql.NullInt64{}, sql.NullInt64{},
-		sql.NullInt32{Int32: 0, Valid: true}, now, now, pq.Array([]int32(nil)),
-	)
-	mock.ExpectQuery("INSERT INTO satellite_status").WillReturnRows(statusRows)
+func TestSyncHandler_InventoryDelta(t *testing.T) {
+	t.Run("delta without a stored inventory requests a resync", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		now := time.Now().UTC().Truncate(time.Second)
 
-	mock.ExpectExec("UPDATE satellites SET last_seen").WillReturnResult(sqlmock.NewResult(0, 1))
+		expectSatellite(mock, now)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM satellite_inventory").
+			WithArgs(int32(1)).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectCommit()
+		expectStatusSaved(mock, now, 1)
+
+		resp := postSync(t, server, SatelliteStatusParams{
+			Name:               "edge-01",
+			RequestCreatedTime: now,
+			Inventory: &inventory.Report{
+				Upserted:     []inventory.Image{nginxImage},
+				SnapshotHash: inventory.Hash([]inventory.Image{nginxImage}),
+			},
+		})
+		require.True(t, resp.InventoryResync)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
 
-	reqBody := SatelliteStatusParams{
-		Name:               "edge-01",
-		RequestCreatedTime: now,
-	}
-	body := mustMarshalJSON(t, reqBody)
-	req := httptest.NewRequest(http.MethodPost, "/satellites/sync", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	t.Run("unchanged inventory is not written", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		now := time.Now().UTC().Truncate(time.Second)
+		hash := inventory.Hash([]inventory.Image{nginxImage})
 
-	rr := httptest.NewRecorder()
-	server.syncHandler(rr, req)
+		expectSatellite(mock, now)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM satellite_inventory").
+			WithArgs(int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "snapshot_hash", "image_count", "updated_at"}).
+				AddRow(1, hash, 1, now))
+		mock.ExpectCommit()
+		expectStatusSaved(mock, now, 1)
+
+		resp := postSync(t, server, SatelliteStatusParams{
+			Name:               "edge-01",
+			RequestCreatedTime: now,
+			Inventory:          &inventory.Report{SnapshotHash: hash},
+		})
+		require.False(t, resp.InventoryResync)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
 
-	require.Equal(t, http.StatusOK, rr.Code)
+	t.Run("removal that leaves a diverged inventory requests a resync", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		now := time.Now().UTC().Truncate(time.Second)
+		stale := alpineImage
+		stale.Digest = "sha256:old"
+
+		expectSatellite(mock, now)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM satellite_inventory").
+			WithArgs(int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "snapshot_hash", "image_count", "updated_at"}).
+				AddRow(1, "sha256:previous", 2, now))
+		mock.ExpectExec("DELETE FROM satellite_images").
+			WithArgs(pq.Array([]string{"library/nginx"}), pq.Array([]string{"latest"}), int32(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT .+ FROM satellite_images").
+			WithArgs(int32(1)).
+			WillReturnRows(imageRows(now, stale))
+		mock.ExpectExec("INSERT INTO satellite_inventory").
+			WithArgs(int32(1), inventory.Hash([]inventory.Image{stale}), int32(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectStatusSaved(mock, now, 1)
+
+		resp := postSync(t, server, SatelliteStatusParams{
+			Name:               "edge-01",
+			RequestCreatedTime: now,
+			Inventory: &inventory.Report{
+				Removed:      []inventory.Ref{nginxImage.Ref},
+				SnapshotHash: inventory.Hash([]inventory.Image{alpineImage}),
+			},
+		})
+		require.True(t, resp.InventoryResync)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSyncHandler_NoInventory(t *testing.T) {
+	server, mock := newMockServer(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	expectSatellite(mock, now)
+	expectStatusSaved(mock, now, 0)
+
+	resp := postSync(t, server, SatelliteStatusParams{
+		Name:               "edge-01",
+		RequestCreatedTime: now,
+	})
+	require.False(t, resp.InventoryResync)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -167,21 +263,12 @@ func TestSyncHandler_UnknownSatellite(t *testing.T) {
 func TestGetCachedImagesHandler(t *testing.T) {
 	t.Run("returns cached images for satellite", func(t *testing.T) {
 		server, mock := newMockServer(t)
-
 		now := time.Now().UTC().Truncate(time.Second)
 
-		satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
-			AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
-		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
-			WithArgs("edge-01").
-			WillReturnRows(satRows)
-
-		artifactRows := sqlmock.NewRows([]string{"id", "reference", "size_bytes", "created_at"}).
-			AddRow(int32(10), "localhost:8585/library/nginx:latest@sha256:abc", int64(50000), now).
-			AddRow(int32(11), "localhost:8585/library/alpine:3.18@sha256:def", int64(5000), now)
-		mock.ExpectQuery("SELECT .+ FROM artifacts").
+		expectSatellite(mock, now)
+		mock.ExpectQuery("SELECT .+ FROM satellite_images").
 			WithArgs(int32(1)).
-			WillReturnRows(artifactRows)
+			WillReturnRows(imageRows(now, alpineImage, nginxImage))
 
 		req := httptest.NewRequest(http.MethodGet, "/api/satellites/edge-01/images", nil)
 		req = mux.SetURLVars(req, map[string]string{"satellite": "edge-01"})
@@ -191,19 +278,12 @@ func TestGetCachedImagesHandler(t *testing.T) {
 
 		require.Equal(t, http.StatusOK, rr.Code)
 
-		var artifacts []struct {
-			ID        int32     `json:"ID"`
-			Reference string    `json:"Reference"`
-			SizeBytes int64     `json:"SizeBytes"`
-			CreatedAt time.Time `json:"CreatedAt"`
-		}
-		err := json.NewDecoder(rr.Body).Decode(&artifacts)
-		require.NoError(t, err)
-		require.Len(t, artifacts, 2)
-		require.Equal(t, "localhost:8585/library/nginx:latest@sha256:abc", artifacts[0].Reference)
-		require.Equal(t, int64(50000), artifacts[0].SizeBytes)
-		require.Equal(t, "localhost:8585/library/alpine:3.18@sha256:def", artifacts[1].Reference)
-		require.Equal(t, int64(5000), artifacts[1].SizeBytes)
+		var images []CachedImage
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&images))
+		require.Equal(t, []CachedImage{
+			{Repository: "library/alpine", Tag: "3.18", Digest: "sha256:def", SizeBytes: 5000, UpdatedAt: now},
+			{Repository: "library/nginx", Tag: "latest", Digest: "sha256:abc", SizeBytes: 50000, UpdatedAt: now},
+		}, images)
 
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -227,19 +307,12 @@ func TestGetCachedImagesHandler(t *testing.T) {
 
 	t.Run("no cached images returns empty array", func(t *testing.T) {
 		server, mock := newMockServer(t)
-
 		now := time.Now().UTC().Truncate(time.Second)
 
-		satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
-			AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
-		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
-			WithArgs("edge-01").
-			WillReturnRows(satRows)
-
-		emptyRows := sqlmock.NewRows([]string{"id", "reference", "size_bytes", "created_at"})
-		mock.ExpectQuery("SELECT .+ FROM artifacts").
+		expectSatellite(mock, now)
+		mock.ExpectQuery("SELECT .+ FROM satellite_images").
 			WithArgs(int32(1)).
-			WillReturnRows(emptyRows)
+			WillReturnRows(imageRows(now))
 
 		req := httptest.NewRequest(http.MethodGet, "/api/satellites/edge-01/images", nil)
 		req = mux.SetURLVars(req, map[string]string{"satellite": "edge-01"})
@@ -248,6 +321,7 @@ func TestGetCachedImagesHandler(t *testing.T) {
 		server.getCachedImagesHandler(rr, req)
 
 		require.Equal(t, http.StatusOK, rr.Code)
+		require.JSONEq(t, "[]", rr.Body.String())
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 }
@@ -291,29 +365,27 @@ func TestSyncHandler_InvalidHeartbeatInterval(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestSyncHandler_BatchInsertArtifactsFails(t *testing.T) {
+func TestSyncHandler_SaveInventoryFails(t *testing.T) {
 	server, mock := newMockServer(t)
-
 	now := time.Now().UTC().Truncate(time.Second)
 
-	satRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
-		AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{})
-	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
-		WithArgs("edge-01").
-		WillReturnRows(satRows)
-
-	mock.ExpectExec("INSERT INTO artifacts").
-		WithArgs(
-			pq.Array([]string{"localhost:8585/nginx:latest@sha256:abc"}),
-			pq.Array([]int64{50000}),
-		).
+	expectSatellite(mock, now)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .+ FROM satellite_inventory").
+		WithArgs(int32(1)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO image_manifests").
+		WithArgs(pq.Array([]string{"sha256:abc"}), pq.Array([]int64{50000})).
 		WillReturnError(fmt.Errorf("db connection lost"))
+	mock.ExpectRollback()
 
 	reqBody := SatelliteStatusParams{
 		Name:               "edge-01",
 		RequestCreatedTime: now,
-		CachedImages: []CachedImage{
-			{Reference: "localhost:8585/nginx:latest@sha256:abc", SizeBytes: 50000},
+		Inventory: &inventory.Report{
+			Full:         true,
+			Upserted:     []inventory.Image{nginxImage},
+			SnapshotHash: inventory.Hash([]inventory.Image{nginxImage}),
 		},
 	}
 	body := mustMarshalJSON(t, reqBody)
@@ -338,7 +410,7 @@ func TestGetCachedImagesHandler_DBFailure(t *testing.T) {
 		WithArgs("edge-01").
 		WillReturnRows(satRows)
 
-	mock.ExpectQuery("SELECT .+ FROM artifacts").
+	mock.ExpectQuery("SELECT .+ FROM satellite_images").
 		WithArgs(int32(1)).
 		WillReturnError(fmt.Errorf("db timeout"))
 
@@ -352,41 +424,63 @@ func TestGetCachedImagesHandler_DBFailure(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestCachedImageJSON(t *testing.T) {
-	t.Run("serialization roundtrip", func(t *testing.T) {
-		original := SatelliteStatusParams{
-			Name:       "edge-01",
-			ImageCount: 2,
-			CachedImages: []CachedImage{
-				{Reference: "localhost:8585/nginx:latest@sha256:abc", SizeBytes: 50000},
-				{Reference: "localhost:8585/alpine:3.18@sha256:def", SizeBytes: 5000},
-			},
-		}
+func TestLegacyInventory(t *testing.T) {
+	report := legacyInventory([]LegacyCachedImage{
+		{Reference: "localhost:8585/library/nginx:latest@sha256:abc", SizeBytes: 50000},
+		{Reference: "localhost:8585/library/alpine:3.18@sha256:def", SizeBytes: 5000},
+		{Reference: "localhost:8585/library/busybox@sha256:123", SizeBytes: 10},
+		{Reference: "not-a-reference", SizeBytes: 10},
+	})
 
-		data, err := json.Marshal(original)
-		require.NoError(t, err)
+	require.True(t, report.Full)
+	require.Equal(t, []inventory.Image{nginxImage, alpineImage}, report.Upserted)
+	require.Equal(t, inventory.Hash([]inventory.Image{nginxImage, alpineImage}), report.SnapshotHash)
+}
 
+func TestSatelliteStatusParamsJSON(t *testing.T) {
+	t.Run("older satellites still decode", func(t *testing.T) {
 		var decoded SatelliteStatusParams
-		err = json.Unmarshal(data, &decoded)
+		err := json.Unmarshal([]byte(`{"name":"edge-01","cached_images":[{"reference":"localhost:8585/nginx:latest@sha256:abc","size_bytes":50000}]}`), &decoded)
 		require.NoError(t, err)
-
-		require.Equal(t, original.Name, decoded.Name)
-		require.Equal(t, original.ImageCount, decoded.ImageCount)
-		require.Len(t, decoded.CachedImages, 2)
-		require.Equal(t, original.CachedImages[0].Reference, decoded.CachedImages[0].Reference)
-		require.Equal(t, original.CachedImages[0].SizeBytes, decoded.CachedImages[0].SizeBytes)
-		require.Equal(t, original.CachedImages[1].Reference, decoded.CachedImages[1].Reference)
-		require.Equal(t, original.CachedImages[1].SizeBytes, decoded.CachedImages[1].SizeBytes)
+		require.Nil(t, decoded.Inventory)
+		require.Equal(t, []LegacyCachedImage{{Reference: "localhost:8585/nginx:latest@sha256:abc", SizeBytes: 50000}}, decoded.CachedImages)
 	})
 
-	t.Run("omits cached_images when empty", func(t *testing.T) {
-		original := SatelliteStatusParams{
-			Name:       "edge-01",
-			ImageCount: 0,
-		}
-
-		data, err := json.Marshal(original)
+	t.Run("omits inventory when empty", func(t *testing.T) {
+		data, err := json.Marshal(SatelliteStatusParams{Name: "edge-01"})
 		require.NoError(t, err)
+		require.NotContains(t, string(data), "inventory")
 		require.NotContains(t, string(data), "cached_images")
 	})
 }
+
+func TestImagesStaged(t *testing.T) {
+	warm := ImageWarmStatus{Node: "edge-01", Ref: "harbor.example/library/nginx:1.27", Digest: "sha256:abc", State: "warm"}
+	pending := ImageWarmStatus{Node: "edge-02", Ref: "harbor.example/library/nginx:1.27", Digest: "sha256:abc", State: "pending"}
+
+	require.Equal(t, sql.NullBool{}, imagesStaged(nil))
+	require.Equal(t, sql.NullBool{Bool: true, Valid: true}, imagesStaged([]ImageWarmStatus{warm}))
+	require.Equal(t, sql.NullBool{Valid: true}, imagesStaged([]ImageWarmStatus{warm, pending}))
+}
+
+func TestSyncHandler_PrewarmStatus(t *testing.T) {
+	server, mock := newMockServer(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	prewarm := []ImageWarmStatus{{Node: "edge-01", Ref: "harbor.example/library/nginx:1.27", Digest: "sha256:abc", State: "warm"}}
+	expectSatellite(mock, now)
+	args := make([]driver.Value, 18)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	args[16] = mustMarshalJSON(t, prewarm)
+	args[17] = true
+	expectStatusSaved(mock, now, 0, args...)
+
+	postSync(t, server, SatelliteStatusParams{
+		Name:               "edge-01",
+		RequestCreatedTime: now,
+		Prewarm:            prewarm,
+	})
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -70,8 +70,8 @@ func (s *Server) runCleanupWithLock(ctx context.Context, days int) {
 		return
 	}
 
-	if err := s.dbQueries.DeleteOrphanedArtifacts(ctx, days); err != nil {
-		log.Printf("Orphaned artifacts cleanup failed: %v", err)
+	if err := s.dbQueries.DeleteOrphanedImageManifests(ctx, days); err != nil {
+		log.Printf("Orphaned image manifests cleanup failed: %v", err)
 	}
 
 	log.Printf("Status cleanup completed (deleted records older than %d days)", days)
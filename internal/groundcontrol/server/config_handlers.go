@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -21,11 +22,13 @@ import (
 	"github.com/lib/pq"
 )
 
-// SatelliteConfigParams links a satellite to a named configuration.
+// SatelliteConfigParams links a satellite, or every satellite matching a
+// label selector, to a named configuration.
 //
 // swagger:model SatelliteConfigParams
 type SatelliteConfigParams struct {
 	Satellite  string `json:"satellite,omitempty"`
+	Selector   string `json:"selector,omitempty"`
 	ConfigName string `json:"config_name"`
 }
 
@@ -171,6 +174,19 @@ func diffConfigForAudit(oldRaw, newRaw []byte) map[string]any {
 	return out
 }
 
+// loadConfig resolves the config named in the request path.
+func (s *Server) loadConfig(r *http.Request) (database.Config, error) {
+	cfg, err := s.dbQueries.GetConfigByName(r.Context(), mux.Vars(r)["config"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Config{}, &AppError{Message: "error: config not found", Code: http.StatusNotFound}
+		}
+		log.Printf("Error: Failed to get config: %v", err)
+		return database.Config{}, &AppError{Message: "error: failed to get config", Code: http.StatusInternalServerError}
+	}
+	return cfg, nil
+}
+
 func (s *Server) createConfigHandler(w http.ResponseWriter, r *http.Request) {
 	var req models.ConfigObject
 
@@ -234,7 +250,7 @@ func (s *Server) createConfigHandler(w http.ResponseWriter, r *http.Request) {
 		Config:      configJson,
 	}
 
-	_, err = q.CreateConfig(r.Context(), params)
+	created, err := q.CreateConfig(r.Context(), params)
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
@@ -250,8 +266,15 @@ func (s *Server) createConfigHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rev, err := recordConfigRevision(r.Context(), q, created, actorFromContext(r.Context()), revisionCreate, "")
+	if err != nil {
+		log.Println("Error recording config revision: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
 	// Push config as OCI artifact
-	err = utils.CreateAndPushConfigStateArtifact(r.Context(), configJson, req.ConfigName)
+	err = utils.CreateAndPushConfigStateArtifact(r.Context(), configJson, req.ConfigName, rev.Revision)
 	if err != nil {
 		log.Println("Error while creating config state artifact: ", err)
 		HandleAppError(w, err)
@@ -275,7 +298,7 @@ func (s *Server) createConfigHandler(w http.ResponseWriter, r *http.Request) {
 		Actor:        actorFromContext(r.Context()),
 		ActorType:    auditlog.ActorUser,
 		Resource:     req.ConfigName,
-		Details:      map[string]any{"to": redactConfigForAudit(configJson)},
+		Details:      map[string]any{"to": redactConfigForAudit(configJson), "revision": rev.Revision},
 	})
 
 	w.WriteHeader(http.StatusCreated)
@@ -349,6 +372,23 @@ func (s *Server) updateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A rollout replaces the config when it completes, so a direct update
+	// would be lost or would bypass the canary.
+	if _, err := q.GetUnfinishedConfigRollout(r.Context(), existing.ID); err == nil {
+		HandleAppError(w, &AppError{
+			Message: "error: config has a rollout in progress",
+			Code:    http.StatusConflict,
+		})
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error: Failed to check config rollouts: %v", err)
+		HandleAppError(w, &AppError{
+			Message: "error: failed to check config rollouts",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
 	configJson, err := json.Marshal(req)
 	if err != nil {
 		log.Println("Could not marshal JSON: ", err)
@@ -386,14 +426,27 @@ func (s *Server) updateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rev, err := recordConfigRevision(r.Context(), q, result, actorFromContext(r.Context()), revisionUpdate, "")
+	if err != nil {
+		log.Println("Error recording config revision: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
 	// Push config as OCI artifact
-	err = utils.CreateAndPushConfigStateArtifact(r.Context(), patchedJson, configName)
+	err = utils.CreateAndPushConfigStateArtifact(r.Context(), patchedJson, configName, rev.Revision)
 	if err != nil {
 		log.Println("Error while creating config state artifact: ", err)
 		HandleAppError(w, err)
 		return
 	}
 
+	if err := republishLayeredSatellites(r.Context(), q, result); err != nil {
+		log.Println("Error while republishing layered satellite configs: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Printf("Failed to commit transaction: %v", err)
 		HandleAppError(w, &AppError{
@@ -411,12 +464,13 @@ func (s *Server) updateConfigHandler(w http.ResponseWriter, r *http.Request) {
 		Actor:        actorFromContext(r.Context()),
 		ActorType:    auditlog.ActorUser,
 		Resource:     configName,
+		Details:      map[string]any{"revision": rev.Revision},
 	}
 	// Record only the fields that changed (from -> to), with secret values
 	// redacted. Computed from the raw configs so a rotated secret still shows up
 	// as a changed path even though its value is not logged.
 	if changed := diffConfigForAudit(existing.Config, patchedJson); len(changed) > 0 {
-		updateEvent.Details = map[string]any{"changed": changed}
+		updateEvent.Details["changed"] = changed
 	}
 	s.auditEvent(r, updateEvent)
 
@@ -461,6 +515,21 @@ func (s *Server) setSatelliteConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Satellite != "" && req.Selector != "" {
+		HandleAppError(w, &AppError{
+			Message: "error: set either satellite or selector, not both",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	var sel labelSelector
+	if req.Selector != "" {
+		if sel, err = parseLabelSelector(req.Selector); err != nil {
+			HandleAppError(w, &AppError{Message: fmt.Sprintf("error: %v", err), Code: http.StatusBadRequest})
+			return
+		}
+	}
+
 	tx, err := s.db.BeginTx(r.Context(), nil)
 	if err != nil {
 		log.Println("Could not begin transaction:", err)
@@ -485,58 +554,81 @@ func (s *Server) setSatelliteConfig(w http.ResponseWriter, r *http.Request) {
 
 	q := s.dbQueries.WithTx(tx)
 
-	sat, err := setSatelliteConfig(r.Context(), q, req.Satellite, req.ConfigName)
-	if err != nil {
-		log.Printf("Error: Could not set satellite config: %v", err)
-		HandleAppError(w, err)
+	names := []string{req.Satellite}
+	if sel != nil {
+		sats, err := selectSatellites(r.Context(), q, sel)
+		if err != nil {
+			log.Printf("Error: Could not select satellites: %v", err)
+			HandleAppError(w, &AppError{Message: "Error: Failed to select satellites", Code: http.StatusInternalServerError})
+			return
+		}
+		names = make([]string, 0, len(sats))
+		for _, sat := range sats {
+			names = append(names, sat.Name)
+		}
+	}
+
+	for _, name := range names {
+		if err := assignSatelliteConfig(r.Context(), q, name, req.ConfigName); err != nil {
+			log.Printf("Error: Could not set config of satellite %s: %v", name, err)
+			HandleAppError(w, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		HandleAppError(w, &AppError{
+			Message: "Error: Failed to commit transaction",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	committed = true
+
+	if sel != nil {
+		WriteJSONResponse(w, http.StatusOK, map[string][]string{"satellites": names})
 		return
 	}
+	WriteJSONResponse(w, http.StatusOK, map[string]string{})
+}
+
+// assignSatelliteConfig links a satellite to a config and points its state
+// artifact at it.
+func assignSatelliteConfig(ctx context.Context, q *database.Queries, satelliteName, configName string) error {
+	sat, err := setSatelliteConfig(ctx, q, satelliteName, configName)
+	if err != nil {
+		return err
+	}
 
-	groupList, err := q.SatelliteGroupList(r.Context(), sat.ID)
+	groupList, err := q.SatelliteGroupList(ctx, sat.ID)
 	if err != nil {
 		log.Printf("Could not get satellite group list: %v", err)
-		err := &AppError{
+		return &AppError{
 			Message: "Error: Failed to Add satellite to config",
 			Code:    http.StatusInternalServerError,
 		}
-		HandleAppError(w, err)
-		return
 	}
 
 	// TODO: Store the groupStates in memory to survive hot reloads
 	var groupStates []string
 	for _, group := range groupList {
-		grp, err := q.GetGroupByID(r.Context(), group.GroupID)
+		grp, err := q.GetGroupByID(ctx, group.GroupID)
 		if err != nil {
 			log.Printf("Error: Failed: %v", err)
-			err := &AppError{
+			return &AppError{
 				Message: "Error: Failed to Add satellite to config",
 				Code:    http.StatusInternalServerError,
 			}
-			HandleAppError(w, err)
-			return
 		}
 		groupStates = append(groupStates, utils.AssembleGroupState(grp.GroupName))
 	}
 
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, req.ConfigName)
-	if err != nil {
+	if err := pushSatelliteState(ctx, q, sat.ID, sat.Name, groupStates, configName); err != nil {
 		log.Printf("Could not update satellite state artifact: %v", err)
-		HandleAppError(w, err)
-		return
+		return err
 	}
-
-	if err := tx.Commit(); err != nil {
-		log.Printf("Failed to commit transaction: %v", err)
-		HandleAppError(w, &AppError{
-			Message: "Error: Failed to commit transaction",
-			Code:    http.StatusInternalServerError,
-		})
-		return
-	}
-	committed = true
-
-	WriteJSONResponse(w, http.StatusOK, map[string]string{})
+	return nil
 }
 
 // Deletes the config, given that the config is not currently used by any satellite.
@@ -0,0 +1,420 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	auditlog "github.com/container-registry/harbor-satellite/internal/groundcontrol/logger"
+	"github.com/gorilla/mux"
+)
+
+// GroupConfigLayerRequest sets the config layer of a group.
+//
+// swagger:model GroupConfigLayerRequest
+type groupConfigLayerRequest struct {
+	// Patch is a JSON merge patch applied to the config of every satellite
+	// in the group.
+	Patch json.RawMessage `json:"patch"`
+	// Priority orders the layers of a satellite in several groups; higher
+	// priorities apply later and win.
+	Priority int32 `json:"priority"`
+}
+
+// GroupConfigLayer is the config layer of a group.
+//
+// swagger:model GroupConfigLayer
+type groupConfigLayerResponse struct {
+	Group     string          `json:"group"`
+	Patch     json.RawMessage `json:"patch"`
+	Priority  int32           `json:"priority"`
+	UpdatedBy string          `json:"updated_by"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// SatelliteConfigOverride is the merge patch applied last to the config of a
+// satellite.
+//
+// swagger:model SatelliteConfigOverride
+type satelliteConfigOverrideResponse struct {
+	Satellite string          `json:"satellite"`
+	Patch     json.RawMessage `json:"patch"`
+	UpdatedBy string          `json:"updated_by"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// EffectiveConfig is the config a satellite runs: its config, or the candidate
+// of a rollout it takes part in, with its layers applied.
+//
+// swagger:model EffectiveConfig
+type effectiveConfigResponse struct {
+	Satellite  string          `json:"satellite"`
+	ConfigName string          `json:"config_name"`
+	Revision   int32           `json:"revision,omitempty"`
+	RolloutID  int32           `json:"rollout_id,omitempty"`
+	Layers     []configLayer   `json:"layers"`
+	Warnings   []string        `json:"warnings,omitempty"`
+	Config     json.RawMessage `json:"config"`
+}
+
+// validateConfigPatch checks that a layer is a JSON merge patch for a config
+// object.
+func validateConfigPatch(patch json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	if len(bytes.TrimSpace(patch)) == 0 || json.Unmarshal(patch, &fields) != nil || fields == nil {
+		return &AppError{Message: "error: patch must be a JSON object", Code: http.StatusBadRequest}
+	}
+	return nil
+}
+
+func configLayerAuditEvent(op auditlog.Operation, scope, resource string, patch json.RawMessage) auditlog.AuditEvent {
+	e := auditlog.AuditEvent{
+		Operation:    op,
+		ResourceType: auditlog.ResConfigLayer,
+		Outcome:      auditlog.OutcomeSuccess,
+		Resource:     resource,
+		Details:      map[string]any{"scope": scope},
+	}
+	if patch != nil {
+		e.Details["patch"] = redactConfigForAudit(patch)
+	}
+	return e
+}
+
+// loadGroup resolves the group named in the request path.
+func (s *Server) loadGroup(r *http.Request) (database.Group, error) {
+	group, err := s.dbQueries.GetGroupByName(r.Context(), mux.Vars(r)["group"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Group{}, &AppError{Message: "error: group not found", Code: http.StatusNotFound}
+		}
+		log.Printf("Error: Failed to get group: %v", err)
+		return database.Group{}, &AppError{Message: "error: failed to get group", Code: http.StatusInternalServerError}
+	}
+	return group, nil
+}
+
+// loadSatellite resolves the satellite named in the request path.
+func (s *Server) loadSatellite(r *http.Request) (database.Satellite, error) {
+	sat, err := s.dbQueries.GetSatelliteByName(r.Context(), mux.Vars(r)["satellite"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Satellite{}, &AppError{Message: "error: satellite not found", Code: http.StatusNotFound}
+		}
+		log.Printf("Error: Failed to get satellite: %v", err)
+		return database.Satellite{}, &AppError{Message: "error: failed to get satellite", Code: http.StatusInternalServerError}
+	}
+	return sat, nil
+}
+
+// satelliteConfigName returns the name of the config assigned to a satellite,
+// or sql.ErrNoRows when it has none.
+func satelliteConfigName(ctx context.Context, q *database.Queries, satelliteID int32) (string, error) {
+	sc, err := q.SatelliteConfig(ctx, satelliteID)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := q.GetConfigByID(ctx, sc.ConfigID)
+	if err != nil {
+		return "", err
+	}
+	return cfg.ConfigName, nil
+}
+
+// republishGroupSatellites renders the config again for every satellite in a
+// group whose layer changed.
+func republishGroupSatellites(ctx context.Context, q *database.Queries, groupID int32) error {
+	sats, err := q.ListGroupConfiguredSatellites(ctx, groupID)
+	if err != nil {
+		return fmt.Errorf("list satellites of group: %w", err)
+	}
+	for _, sat := range sats {
+		if err := republishSatelliteState(ctx, q, sat.ID, sat.Name, sat.ConfigName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// republishSatellite renders the config again for a satellite whose override
+// changed. A satellite without a config has nothing to render.
+func republishSatellite(ctx context.Context, q *database.Queries, sat database.Satellite) error {
+	configName, err := satelliteConfigName(ctx, q, sat.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get config of satellite %s: %w", sat.Name, err)
+	}
+	return republishSatelliteState(ctx, q, sat.ID, sat.Name, configName)
+}
+
+func (s *Server) getGroupConfigLayerHandler(w http.ResponseWriter, r *http.Request) {
+	group, err := s.loadGroup(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	layer, err := s.dbQueries.GetGroupConfigLayer(r.Context(), group.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			HandleAppError(w, &AppError{Message: "error: group has no config layer", Code: http.StatusNotFound})
+			return
+		}
+		log.Printf("Error: Failed to get group config layer: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get group config layer", Code: http.StatusInternalServerError})
+		return
+	}
+	WriteJSONResponse(w, http.StatusOK, groupConfigLayerResponse{
+		Group:     group.GroupName,
+		Patch:     layer.Patch,
+		Priority:  layer.Priority,
+		UpdatedBy: layer.UpdatedBy,
+		UpdatedAt: layer.UpdatedAt,
+	})
+}
+
+// setGroupConfigLayerHandler creates or replaces the config layer of a group
+// and republishes the config of its satellites. The change is refused when the
+// config of any of them would no longer validate.
+func (s *Server) setGroupConfigLayerHandler(w http.ResponseWriter, r *http.Request) {
+	var req groupConfigLayerRequest
+	if err := DecodeRequestBody(r, &req); err != nil {
+		log.Println("Error decoding request body: ", err)
+		HandleAppError(w, err)
+		return
+	}
+	if err := validateConfigPatch(req.Patch); err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	group, err := s.loadGroup(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	if err := ensureSatelliteProjectExists(r.Context()); err != nil {
+		log.Println("Error while ensuring project satellite: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	var layer database.GroupConfigLayer
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		layer, err = q.UpsertGroupConfigLayer(r.Context(), database.UpsertGroupConfigLayerParams{
+			GroupID:   group.ID,
+			Patch:     req.Patch,
+			Priority:  req.Priority,
+			UpdatedBy: actorFromContext(r.Context()),
+		})
+		if err != nil {
+			return fmt.Errorf("set group config layer: %w", err)
+		}
+		return republishGroupSatellites(r.Context(), q, group.ID)
+	})
+	if err != nil {
+		log.Printf("Error: Failed to set group config layer: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	e := configLayerAuditEvent(auditlog.OpUpdate, "group", group.GroupName, layer.Patch)
+	e.Details["priority"] = layer.Priority
+	s.userAuditEvent(r, e)
+
+	WriteJSONResponse(w, http.StatusOK, groupConfigLayerResponse{
+		Group:     group.GroupName,
+		Patch:     layer.Patch,
+		Priority:  layer.Priority,
+		UpdatedBy: layer.UpdatedBy,
+		UpdatedAt: layer.UpdatedAt,
+	})
+}
+
+func (s *Server) deleteGroupConfigLayerHandler(w http.ResponseWriter, r *http.Request) {
+	group, err := s.loadGroup(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		if _, err := q.GetGroupConfigLayer(r.Context(), group.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &AppError{Message: "error: group has no config layer", Code: http.StatusNotFound}
+			}
+			return fmt.Errorf("get group config layer: %w", err)
+		}
+		if err := q.DeleteGroupConfigLayer(r.Context(), group.ID); err != nil {
+			return fmt.Errorf("delete group config layer: %w", err)
+		}
+		return republishGroupSatellites(r.Context(), q, group.ID)
+	})
+	if err != nil {
+		log.Printf("Error: Failed to delete group config layer: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, configLayerAuditEvent(auditlog.OpDelete, "group", group.GroupName, nil))
+	WriteJSONResponse(w, http.StatusOK, map[string]string{})
+}
+
+func (s *Server) getSatelliteConfigOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	sat, err := s.loadSatellite(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	override, err := s.dbQueries.GetSatelliteConfigOverride(r.Context(), sat.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			HandleAppError(w, &AppError{Message: "error: satellite has no config override", Code: http.StatusNotFound})
+			return
+		}
+		log.Printf("Error: Failed to get satellite config override: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get satellite config override", Code: http.StatusInternalServerError})
+		return
+	}
+	WriteJSONResponse(w, http.StatusOK, satelliteConfigOverrideResponse{
+		Satellite: sat.Name,
+		Patch:     override.Patch,
+		UpdatedBy: override.UpdatedBy,
+		UpdatedAt: override.UpdatedAt,
+	})
+}
+
+// setSatelliteConfigOverrideHandler creates or replaces the override of a
+// satellite, given as a JSON merge patch in the request body, and republishes
+// its config. The override is refused when the config would no longer
+// validate.
+func (s *Server) setSatelliteConfigOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	var patch json.RawMessage
+	if err := DecodeRequestBody(r, &patch); err != nil {
+		log.Println("Error decoding request body: ", err)
+		HandleAppError(w, err)
+		return
+	}
+	if err := validateConfigPatch(patch); err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	sat, err := s.loadSatellite(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	if err := ensureSatelliteProjectExists(r.Context()); err != nil {
+		log.Println("Error while ensuring project satellite: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	var override database.SatelliteConfigOverride
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		override, err = q.UpsertSatelliteConfigOverride(r.Context(), database.UpsertSatelliteConfigOverrideParams{
+			SatelliteID: sat.ID,
+			Patch:       patch,
+			UpdatedBy:   actorFromContext(r.Context()),
+		})
+		if err != nil {
+			return fmt.Errorf("set satellite config override: %w", err)
+		}
+		return republishSatellite(r.Context(), q, sat)
+	})
+	if err != nil {
+		log.Printf("Error: Failed to set satellite config override: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, configLayerAuditEvent(auditlog.OpUpdate, "satellite", sat.Name, override.Patch))
+	WriteJSONResponse(w, http.StatusOK, satelliteConfigOverrideResponse{
+		Satellite: sat.Name,
+		Patch:     override.Patch,
+		UpdatedBy: override.UpdatedBy,
+		UpdatedAt: override.UpdatedAt,
+	})
+}
+
+func (s *Server) deleteSatelliteConfigOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	sat, err := s.loadSatellite(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		if _, err := q.GetSatelliteConfigOverride(r.Context(), sat.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &AppError{Message: "error: satellite has no config override", Code: http.StatusNotFound}
+			}
+			return fmt.Errorf("get satellite config override: %w", err)
+		}
+		if err := q.DeleteSatelliteConfigOverride(r.Context(), sat.ID); err != nil {
+			return fmt.Errorf("delete satellite config override: %w", err)
+		}
+		return republishSatellite(r.Context(), q, sat)
+	})
+	if err != nil {
+		log.Printf("Error: Failed to delete satellite config override: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, configLayerAuditEvent(auditlog.OpDelete, "satellite", sat.Name, nil))
+	WriteJSONResponse(w, http.StatusOK, map[string]string{})
+}
+
+// getEffectiveConfigHandler renders the config of a satellite with its layers
+// without publishing it.
+func (s *Server) getEffectiveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	sat, err := s.loadSatellite(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	configName, err := satelliteConfigName(r.Context(), s.dbQueries, sat.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			HandleAppError(w, &AppError{Message: "error: satellite has no config", Code: http.StatusNotFound})
+			return
+		}
+		log.Printf("Error: Failed to get satellite config: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get satellite config", Code: http.StatusInternalServerError})
+		return
+	}
+
+	rendered, err := renderSatelliteConfig(r.Context(), s.dbQueries, sat.ID, sat.Name, configName)
+	if err != nil {
+		log.Printf("Error: Failed to render satellite config: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	resp := effectiveConfigResponse{
+		Satellite:  sat.Name,
+		ConfigName: configName,
+		Revision:   rendered.Revision,
+		Layers:     rendered.Layers,
+		Warnings:   rendered.Warnings,
+		Config:     rendered.Config,
+	}
+	if rendered.Rollout != nil {
+		resp.RolloutID = rendered.Rollout.ID
+	}
+	WriteJSONResponse(w, http.StatusOK, resp)
+}
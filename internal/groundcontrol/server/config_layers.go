@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+// overrideLayerSource is the source of a satellite's own override layer.
+const overrideLayerSource = "satellite"
+
+// ConfigLayer is a JSON merge patch applied on top of a satellite's config.
+// Group layers apply first, in ascending priority and then by group name; the
+// satellite's own override applies last.
+//
+// swagger:model ConfigLayer
+type configLayer struct {
+	// Source is "group:<name>" for a group layer and "satellite" for the
+	// satellite's override.
+	Source   string          `json:"source"`
+	Priority int32           `json:"priority,omitempty"`
+	Patch    json.RawMessage `json:"patch"`
+}
+
+// renderedConfig is the config a satellite runs, built from its config, or the
+// candidate of a rollout it takes part in, and its layers.
+type renderedConfig struct {
+	ConfigName string
+	// Revision is the config revision the base was published as. It is the
+	// upcoming revision for a rollout candidate.
+	Revision int32
+	// Rollout is the unfinished rollout the satellite is a member of, if any.
+	Rollout  *database.GetSatelliteRolloutRow
+	Layers   []configLayer
+	Config   json.RawMessage
+	Warnings []string
+}
+
+// satelliteConfigLayers returns the layers of a satellite in the order they
+// apply.
+func satelliteConfigLayers(ctx context.Context, q *database.Queries, satelliteID int32) ([]configLayer, error) {
+	groupLayers, err := q.ListSatelliteConfigLayers(ctx, satelliteID)
+	if err != nil {
+		return nil, fmt.Errorf("list config layers of satellite: %w", err)
+	}
+	layers := make([]configLayer, 0, len(groupLayers)+1)
+	for _, l := range groupLayers {
+		layers = append(layers, configLayer{Source: "group:" + l.GroupName, Priority: l.Priority, Patch: l.Patch})
+	}
+	override, err := q.GetSatelliteConfigOverride(ctx, satelliteID)
+	switch {
+	case err == nil:
+		layers = append(layers, configLayer{Source: overrideLayerSource, Patch: override.Patch})
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("get config override of satellite: %w", err)
+	}
+	return layers, nil
+}
+
+// renderSatelliteConfig builds the config of a satellite. Without layers the
+// satellite follows the shared config artifact and the base is returned
+// unvalidated, as it always was. A rendering that fails validation is reported
+// as a 400 AppError, since it is the change that led to it that is refused.
+func renderSatelliteConfig(ctx context.Context, q *database.Queries, satelliteID int32, satelliteName, configName string) (renderedConfig, error) {
+	rc := renderedConfig{ConfigName: configName}
+
+	rollout, err := q.GetSatelliteRollout(ctx, satelliteID)
+	switch {
+	case err == nil:
+		rc.Rollout = &rollout
+	case !errors.Is(err, sql.ErrNoRows):
+		return renderedConfig{}, fmt.Errorf("get rollout of satellite: %w", err)
+	}
+
+	if rc.Rollout != nil {
+		rc.Config = rollout.Config
+		// Updates are refused while a rollout is unfinished, so the
+		// candidate becomes the next revision.
+		if rc.Revision, err = nextConfigRevision(ctx, q, rollout.ConfigID); err != nil {
+			return renderedConfig{}, err
+		}
+	} else {
+		cfg, err := q.GetConfigByName(ctx, configName)
+		if err != nil {
+			return renderedConfig{}, fmt.Errorf("get config %s: %w", configName, err)
+		}
+		rc.Config = cfg.Config
+		latest, err := q.GetLatestConfigRevision(ctx, cfg.ID)
+		switch {
+		case err == nil:
+			rc.Revision = latest.Revision
+		case !errors.Is(err, sql.ErrNoRows):
+			return renderedConfig{}, fmt.Errorf("get latest config revision: %w", err)
+		}
+	}
+
+	if rc.Layers, err = satelliteConfigLayers(ctx, q, satelliteID); err != nil {
+		return renderedConfig{}, err
+	}
+	if len(rc.Layers) == 0 {
+		return rc, nil
+	}
+	if rc.Config, rc.Warnings, err = renderConfig(rc.Config, rc.Layers); err != nil {
+		return renderedConfig{}, &AppError{
+			Message: fmt.Sprintf("error: config of satellite %s: %v", satelliteName, err),
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return rc, nil
+}
+
+// renderConfig applies layers to base as JSON merge patches and validates the
+// result.
+func renderConfig(base json.RawMessage, layers []configLayer) (json.RawMessage, []string, error) {
+	rendered := []byte(base)
+	for _, l := range layers {
+		merged, err := jsonpatch.MergePatch(rendered, l.Patch)
+		if err != nil {
+			return nil, nil, fmt.Errorf("apply %s layer: %w", l.Source, err)
+		}
+		rendered = merged
+	}
+	warnings, err := validateRenderedConfig(rendered)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rendered, warnings, nil
+}
+
+// validateRenderedConfig checks a rendered config the way the satellite will
+// when it applies it. The config itself is published as rendered; defaults are
+// left for the satellite to enforce.
+func validateRenderedConfig(data []byte) ([]string, error) {
+	var cfg config.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	// The ground control URL usually comes from the satellite's environment.
+	var gcURL string
+	if cfg.AppConfig.GroundControlURL == "" {
+		gcURL = config.DefaultGroundControlURL
+	}
+	// TLS files live on the satellite, not here.
+	cfg.AppConfig.TLS.CertFile, cfg.AppConfig.TLS.KeyFile, cfg.AppConfig.TLS.CAFile = "", "", ""
+	_, warnings, err := config.ValidateAndEnforceDefaults(&cfg, gcURL)
+	if err != nil {
+		return warnings, fmt.Errorf("invalid config: %w", err)
+	}
+	return warnings, nil
+}
+
+// republishLayeredSatellites renders cfg again for its satellites that have
+// layers, after the config itself changed.
+func republishLayeredSatellites(ctx context.Context, q *database.Queries, cfg database.Config) error {
+	sats, err := q.ListLayeredConfigSatellites(ctx, cfg.ID)
+	if err != nil {
+		return fmt.Errorf("list layered satellites of config: %w", err)
+	}
+	for _, sat := range sats {
+		if err := republishSatelliteState(ctx, q, sat.ID, sat.Name, cfg.ConfigName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderConfig(t *testing.T) {
+	base := json.RawMessage(`{"app_config":{"log_level":"info","heartbeat_interval":"@every 00h00m30s"},"state_config":{"state":"harbor/edge"}}`)
+
+	t.Run("layers apply in order", func(t *testing.T) {
+		rendered, _, err := renderConfig(base, []configLayer{
+			{Source: "group:region", Patch: json.RawMessage(`{"app_config":{"log_level":"warn","heartbeat_interval":"@every 00h01m00s"}}`)},
+			{Source: "group:edge", Priority: 10, Patch: json.RawMessage(`{"app_config":{"log_level":"debug"}}`)},
+			{Source: overrideLayerSource, Patch: json.RawMessage(`{"app_config":{"heartbeat_interval":"@every 00h00m10s"}}`)},
+		})
+		require.NoError(t, err)
+
+		var got map[string]map[string]any
+		require.NoError(t, json.Unmarshal(rendered, &got))
+		require.Equal(t, "debug", got["app_config"]["log_level"])
+		require.Equal(t, "@every 00h00m10s", got["app_config"]["heartbeat_interval"])
+		require.Equal(t, "harbor/edge", got["state_config"]["state"])
+		require.NotContains(t, got["app_config"], "ground_control_url", "defaults are left for the satellite")
+	})
+
+	t.Run("null removes a field", func(t *testing.T) {
+		rendered, _, err := renderConfig(base, []configLayer{
+			{Source: overrideLayerSource, Patch: json.RawMessage(`{"app_config":{"log_level":null}}`)},
+		})
+		require.NoError(t, err)
+		require.NotContains(t, string(rendered), "log_level")
+	})
+
+	t.Run("invalid result is rejected", func(t *testing.T) {
+		for _, patch := range []string{
+			`{"app_config":{"ground_control_url":"not a url"}}`,
+			`{"app_config":{"heartbeat_interval":30}}`,
+		} {
+			_, _, err := renderConfig(base, []configLayer{{Source: overrideLayerSource, Patch: json.RawMessage(patch)}})
+			require.Error(t, err, patch)
+		}
+	})
+}
+
+func TestValidateConfigPatch(t *testing.T) {
+	require.NoError(t, validateConfigPatch(json.RawMessage(`{"app_config":{"log_level":"debug"}}`)))
+	for _, patch := range []string{``, `null`, `[]`, `"debug"`} {
+		require.Error(t, validateConfigPatch(json.RawMessage(patch)), patch)
+	}
+}
+
+func TestSetGroupConfigLayerHandler(t *testing.T) {
+	t.Run("patch must be an object", func(t *testing.T) {
+		server, mock := newMockServer(t)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/groups/edge/config-layer", bytes.NewBufferString(`{"patch":[1]}`))
+		req = mux.SetURLVars(req, map[string]string{"group": "edge"})
+		rr := httptest.NewRecorder()
+		server.setGroupConfigLayerHandler(rr, req)
+
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown group returns 404", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		mock.ExpectQuery("SELECT .+ FROM groups").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/groups/missing/config-layer", bytes.NewBufferString(`{"patch":{}}`))
+		req = mux.SetURLVars(req, map[string]string{"group": "missing"})
+		rr := httptest.NewRecorder()
+		server.setGroupConfigLayerHandler(rr, req)
+
+		require.Equal(t, http.StatusNotFound, rr.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetEffectiveConfigHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+		WithArgs("edge-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+			AddRow(3, "edge-1", now, now, nil, nil))
+	mock.ExpectQuery("SELECT .+ FROM satellite_configs").
+		WithArgs(int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "config_id"}).AddRow(3, 1))
+	mock.ExpectQuery("SELECT .+ FROM configs WHERE id").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "config_name", "registry_url", "config", "created_at", "updated_at"}).
+			AddRow(1, "edge", "http://harbor:8080", json.RawMessage(`{"app_config":{}}`), now, now))
+	mock.ExpectQuery("SELECT .+ FROM config_rollouts r").
+		WithArgs(int32(3)).
+		WillReturnError(sql.ErrNoRows)
+	expectConfigByName(mock, "edge")
+	mock.ExpectQuery("SELECT .+ FROM config_revisions").
+		WithArgs(int32(1)).
+		WillReturnRows(configRevisionRow(4, `{"app_config":{}}`))
+	mock.ExpectQuery("SELECT .+ FROM group_config_layers").
+		WithArgs(int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"group_name", "patch", "priority"}).
+			AddRow("region", json.RawMessage(`{"app_config":{"log_level":"warn"}}`), 0))
+	mock.ExpectQuery("SELECT .+ FROM satellite_config_overrides").
+		WithArgs(int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "patch", "updated_by", "updated_at"}).
+			AddRow(3, json.RawMessage(`{"app_config":{"log_level":"debug"}}`), "admin", now))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/satellites/edge-1/config", nil)
+	req = mux.SetURLVars(req, map[string]string{"satellite": "edge-1"})
+	rr := httptest.NewRecorder()
+	server.getEffectiveConfigHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var got effectiveConfigResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Equal(t, "edge", got.ConfigName)
+	require.Equal(t, int32(4), got.Revision)
+	require.Len(t, got.Layers, 2)
+	require.Equal(t, "group:region", got.Layers[0].Source)
+	require.Equal(t, overrideLayerSource, got.Layers[1].Source)
+	require.JSONEq(t, `{"app_config":{"log_level":"debug"}}`, string(got.Config))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
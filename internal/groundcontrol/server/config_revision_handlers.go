@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/env"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	auditlog "github.com/container-registry/harbor-satellite/internal/groundcontrol/logger"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/utils"
+	"github.com/gorilla/mux"
+)
+
+// Sources of a config revision.
+const (
+	revisionCreate   = "create"
+	revisionUpdate   = "update"
+	revisionRollout  = "rollout"
+	revisionRollback = "rollback"
+)
+
+// ConfigRevision is one immutable version of a config. Every change to a
+// config, through the API, a rollout or a rollback, adds a revision.
+//
+// swagger:model ConfigRevision
+type configRevisionResponse struct {
+	Revision  int32           `json:"revision"`
+	Author    string          `json:"author"`
+	Source    string          `json:"source"`
+	Message   string          `json:"message,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Config    json.RawMessage `json:"config,omitempty"`
+}
+
+func newConfigRevisionResponse(rev database.ConfigRevision, withConfig bool) configRevisionResponse {
+	resp := configRevisionResponse{
+		Revision:  rev.Revision,
+		Author:    rev.Author,
+		Source:    rev.Source,
+		Message:   rev.Message,
+		CreatedAt: rev.CreatedAt,
+	}
+	if withConfig {
+		resp.Config = rev.Config
+	}
+	return resp
+}
+
+// ConfigRevisionDiff lists the fields that differ between two revisions of a
+// config, with secret values redacted.
+//
+// swagger:model ConfigRevisionDiff
+type configRevisionDiff struct {
+	From    int32          `json:"from"`
+	To      int32          `json:"to"`
+	Changed map[string]any `json:"changed"`
+}
+
+// ConfigRollbackRequest optionally explains a rollback.
+//
+// swagger:model ConfigRollbackRequest
+type configRollbackRequest struct {
+	Message string `json:"message,omitempty"`
+}
+
+// recordConfigRevision adds the config's current content as its next revision.
+func recordConfigRevision(ctx context.Context, q *database.Queries, cfg database.Config, author, source, message string) (database.ConfigRevision, error) {
+	rev, err := q.CreateConfigRevision(ctx, database.CreateConfigRevisionParams{
+		ConfigID: cfg.ID,
+		Config:   cfg.Config,
+		Author:   author,
+		Source:   source,
+		Message:  message,
+	})
+	if err != nil {
+		return database.ConfigRevision{}, fmt.Errorf("record config revision: %w", err)
+	}
+	return rev, nil
+}
+
+// nextConfigRevision returns the number the config's next revision will get.
+func nextConfigRevision(ctx context.Context, q *database.Queries, configID int32) (int32, error) {
+	latest, err := q.GetLatestConfigRevision(ctx, configID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get latest config revision: %w", err)
+	}
+	return latest.Revision + 1, nil
+}
+
+// loadConfigRevision resolves a revision of cfg given as a path or query
+// value.
+func (s *Server) loadConfigRevision(r *http.Request, cfg database.Config, value string) (database.ConfigRevision, error) {
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 1 {
+		return database.ConfigRevision{}, &AppError{Message: fmt.Sprintf("error: invalid revision %q", value), Code: http.StatusBadRequest}
+	}
+	rev, err := s.dbQueries.GetConfigRevision(r.Context(), database.GetConfigRevisionParams{ConfigID: cfg.ID, Revision: int32(n)})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.ConfigRevision{}, &AppError{Message: fmt.Sprintf("error: revision %d not found", n), Code: http.StatusNotFound}
+		}
+		log.Printf("Error: Failed to get config revision: %v", err)
+		return database.ConfigRevision{}, &AppError{Message: "error: failed to get config revision", Code: http.StatusInternalServerError}
+	}
+	return rev, nil
+}
+
+func (s *Server) listConfigRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := s.loadConfig(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	revisions, err := s.dbQueries.ListConfigRevisions(r.Context(), cfg.ID)
+	if err != nil {
+		log.Printf("Error: Failed to list config revisions: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to list config revisions", Code: http.StatusInternalServerError})
+		return
+	}
+	result := make([]configRevisionResponse, 0, len(revisions))
+	for _, rev := range revisions {
+		result = append(result, newConfigRevisionResponse(rev, false))
+	}
+	WriteJSONResponse(w, http.StatusOK, result)
+}
+
+func (s *Server) getConfigRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := s.loadConfig(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	rev, err := s.loadConfigRevision(r, cfg, mux.Vars(r)["revision"])
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	WriteJSONResponse(w, http.StatusOK, newConfigRevisionResponse(rev, true))
+}
+
+// diffConfigRevisionsHandler compares two revisions given as the from and to
+// query parameters. to defaults to the latest revision.
+func (s *Server) diffConfigRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := s.loadConfig(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := s.loadConfigRevision(r, cfg, query.Get("from"))
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	var to database.ConfigRevision
+	if v := query.Get("to"); v != "" {
+		to, err = s.loadConfigRevision(r, cfg, v)
+		if err != nil {
+			HandleAppError(w, err)
+			return
+		}
+	} else {
+		to, err = s.dbQueries.GetLatestConfigRevision(r.Context(), cfg.ID)
+		if err != nil {
+			log.Printf("Error: Failed to get latest config revision: %v", err)
+			HandleAppError(w, &AppError{Message: "error: failed to get latest config revision", Code: http.StatusInternalServerError})
+			return
+		}
+	}
+
+	changed := diffConfigForAudit(from.Config, to.Config)
+	if changed == nil {
+		changed = map[string]any{}
+	}
+	WriteJSONResponse(w, http.StatusOK, configRevisionDiff{From: from.Revision, To: to.Revision, Changed: changed})
+}
+
+// rollbackConfigHandler restores an earlier revision of a config. The restored
+// content becomes a new revision and is republished to satellites.
+func (s *Server) rollbackConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var req configRollbackRequest
+	if r.ContentLength != 0 {
+		if err := DecodeRequestBody(r, &req); err != nil {
+			log.Println("Error decoding request body: ", err)
+			HandleAppError(w, err)
+			return
+		}
+	}
+
+	cfg, err := s.loadConfig(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	target, err := s.loadConfigRevision(r, cfg, mux.Vars(r)["revision"])
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	if _, err := s.dbQueries.GetUnfinishedConfigRollout(r.Context(), cfg.ID); err == nil {
+		HandleAppError(w, &AppError{Message: "error: config has a rollout in progress", Code: http.StatusConflict})
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error: Failed to check config rollouts: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to check config rollouts", Code: http.StatusInternalServerError})
+		return
+	}
+
+	if err := ensureSatelliteProjectExists(r.Context()); err != nil {
+		log.Println("Error while ensuring project satellite: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	message := req.Message
+	if message == "" {
+		message = fmt.Sprintf("rollback to revision %d", target.Revision)
+	}
+
+	var rev database.ConfigRevision
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		updated, err := q.UpdateConfig(r.Context(), database.UpdateConfigParams{
+			ConfigName:  cfg.ConfigName,
+			RegistryUrl: env.GC.Harbor.URL,
+			Config:      target.Config,
+		})
+		if err != nil {
+			return fmt.Errorf("update config: %w", err)
+		}
+		rev, err = recordConfigRevision(r.Context(), q, updated, actorFromContext(r.Context()), revisionRollback, message)
+		if err != nil {
+			return err
+		}
+		if err := utils.CreateAndPushConfigStateArtifact(r.Context(), target.Config, cfg.ConfigName, rev.Revision); err != nil {
+			return fmt.Errorf("push config state artifact: %w", err)
+		}
+		return republishLayeredSatellites(r.Context(), q, updated)
+	})
+	if err != nil {
+		log.Printf("Error: Failed to roll back config: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	e := auditlog.AuditEvent{
+		Operation:    auditlog.OpUpdate,
+		ResourceType: auditlog.ResConfig,
+		Outcome:      auditlog.OutcomeSuccess,
+		Resource:     cfg.ConfigName,
+		Details: map[string]any{
+			"revision":          rev.Revision,
+			"restored_revision": target.Revision,
+			"message":           message,
+		},
+	}
+	if changed := diffConfigForAudit(cfg.Config, target.Config); len(changed) > 0 {
+		e.Details["changed"] = changed
+	}
+	s.userAuditEvent(r, e)
+
+	WriteJSONResponse(w, http.StatusOK, newConfigRevisionResponse(rev, true))
+}
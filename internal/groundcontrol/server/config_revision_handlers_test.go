@@ -0,0 +1,124 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+var configRevisionColumns = []string{"id", "config_id", "revision", "config", "author", "source", "message", "created_at"}
+
+func configRevisionRow(revision int32, cfg string) *sqlmock.Rows {
+	return sqlmock.NewRows(configRevisionColumns).
+		AddRow(revision, 1, revision, json.RawMessage(cfg), "admin", revisionUpdate, "", time.Now().UTC().Truncate(time.Second))
+}
+
+func TestListConfigRevisionsHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	expectConfigByName(mock, "edge")
+	mock.ExpectQuery("SELECT .+ FROM config_revisions").
+		WithArgs(int32(1)).
+		WillReturnRows(sqlmock.NewRows(configRevisionColumns).
+			AddRow(2, 1, 2, json.RawMessage(`{"app_config":{"log_level":"debug"}}`), "admin", revisionUpdate, "", now).
+			AddRow(1, 1, 1, json.RawMessage(`{"app_config":{}}`), "admin", revisionCreate, "", now))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/configs/edge/revisions", nil)
+	req = mux.SetURLVars(req, map[string]string{"config": "edge"})
+	rr := httptest.NewRecorder()
+	server.listConfigRevisionsHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got []configRevisionResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Len(t, got, 2)
+	require.Equal(t, int32(2), got[0].Revision)
+	require.Equal(t, revisionCreate, got[1].Source)
+	require.Empty(t, got[0].Config, "listings leave out the config content")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetConfigRevisionHandler(t *testing.T) {
+	t.Run("invalid revision returns 400", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectConfigByName(mock, "edge")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/configs/edge/revisions/latest", nil)
+		req = mux.SetURLVars(req, map[string]string{"config": "edge", "revision": "latest"})
+		rr := httptest.NewRecorder()
+		server.getConfigRevisionHandler(rr, req)
+
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown revision returns 404", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectConfigByName(mock, "edge")
+		mock.ExpectQuery("SELECT .+ FROM config_revisions").
+			WithArgs(int32(1), int32(9)).
+			WillReturnError(sql.ErrNoRows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/configs/edge/revisions/9", nil)
+		req = mux.SetURLVars(req, map[string]string{"config": "edge", "revision": "9"})
+		rr := httptest.NewRecorder()
+		server.getConfigRevisionHandler(rr, req)
+
+		require.Equal(t, http.StatusNotFound, rr.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDiffConfigRevisionsHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	expectConfigByName(mock, "edge")
+	mock.ExpectQuery("SELECT .+ FROM config_revisions").
+		WithArgs(int32(1), int32(1)).
+		WillReturnRows(configRevisionRow(1, `{"app_config":{"log_level":"info"},"state_config":{"auth":{"password":"old"}}}`))
+	mock.ExpectQuery("SELECT .+ FROM config_revisions").
+		WithArgs(int32(1)).
+		WillReturnRows(configRevisionRow(3, `{"app_config":{"log_level":"debug"},"state_config":{"auth":{"password":"new"}}}`))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/configs/edge/diff?from=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"config": "edge"})
+	rr := httptest.NewRecorder()
+	server.diffConfigRevisionsHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got configRevisionDiff
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Equal(t, int32(1), got.From)
+	require.Equal(t, int32(3), got.To)
+	require.Equal(t, map[string]any{"from": "info", "to": "debug"}, got.Changed["app_config.log_level"])
+	require.Equal(t, map[string]any{"from": auditRedacted, "to": auditRedacted}, got.Changed["state_config.auth.password"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRollbackConfigHandler_RolloutInProgress(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	expectConfigByName(mock, "edge")
+	mock.ExpectQuery("SELECT .+ FROM config_revisions").
+		WithArgs(int32(1), int32(1)).
+		WillReturnRows(configRevisionRow(1, `{"app_config":{}}`))
+	mock.ExpectQuery("SELECT .+ FROM config_rollouts").
+		WithArgs(int32(1)).
+		WillReturnRows(configRolloutRow(rolloutInProgress))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/configs/edge/revisions/1/rollback", nil)
+	req = mux.SetURLVars(req, map[string]string{"config": "edge", "revision": "1"})
+	rr := httptest.NewRecorder()
+	server.rollbackConfigHandler(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
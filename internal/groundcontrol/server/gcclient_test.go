@@ -0,0 +1,459 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/container-registry/harbor-satellite/internal/crypto"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/auth"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/middleware"
+	"github.com/container-registry/harbor-satellite/pkg/gcclient"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// newClientTestServer serves the routes of a mock server in process.
+func newClientTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, sqlmock.Sqlmock) {
+	t.Helper()
+	server, mock := newMockServerWithAuth(t)
+	server.rateLimiter = middleware.NewRateLimiter(100, time.Minute)
+	handler := server.RegisterRoutes()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return ts, mock
+}
+
+// expectLogin expects the login of testuser, whose session token the server
+// generates.
+func expectLogin(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+	hash, err := auth.HashPassword("SecurePass1")
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT .+ FROM login_attempts").
+		WithArgs("testuser").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT .+ FROM users WHERE username").
+		WithArgs("testuser").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "created_at", "updated_at"}).
+			AddRow(1, "testuser", hash, "admin", now, now))
+	mock.ExpectExec("UPDATE login_attempts").
+		WithArgs("testuser").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO sessions").
+		WithArgs(int32(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "created_at"}).
+			AddRow(1, 1, "token", now.Add(time.Hour), now))
+}
+
+func expectSession(mock sqlmock.Sqlmock, token any) {
+	expectSessionRole(mock, token, roleAdmin)
+}
+
+func expectSessionRole(mock sqlmock.Sqlmock, token any, role string) {
+	now := time.Now().UTC().Truncate(time.Second)
+	mock.ExpectQuery("SELECT .+ FROM sessions s").
+		WithArgs(token).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at", "created_at", "username", "role"}).
+			AddRow(1, 1, "token", now.Add(time.Hour), now, "testuser", role))
+}
+
+// expectRobot expects the basic auth of the robot account of edge-01.
+func expectRobot(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	expectRobotAccount(t, mock)
+	now := time.Now().UTC().Truncate(time.Second)
+	mock.ExpectQuery("SELECT .+ FROM satellites WHERE id").
+		WithArgs(int32(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+			AddRow(10, "edge-01", now, now, sql.NullTime{}, sql.NullString{}))
+}
+
+func expectRobotAccount(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+	hashed, err := crypto.HashSecret("robot-secret")
+	require.NoError(t, err)
+	mock.ExpectQuery("SELECT .+ FROM robot_accounts WHERE robot_name").
+		WithArgs("robot$satellite-edge-01").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "robot_name", "robot_secret_hash", "robot_id", "satellite_id", "robot_expiry", "created_at", "updated_at"}).
+			AddRow(1, "robot$satellite-edge-01", hashed, "100", 10, nil, now, now))
+}
+
+func expectGroup(mock sqlmock.Sqlmock, name string) {
+	now := time.Now().UTC().Truncate(time.Second)
+	mock.ExpectQuery("SELECT .+ FROM groups WHERE group_name").
+		WithArgs(name).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "group_name", "registry_url", "projects", "created_at", "updated_at"}).
+			AddRow(7, name, "http://harbor:8080", pq.Array([]string{"edge"}), now, now))
+}
+
+func TestGCClientLogsInAndAgainWhenTheSessionExpires(t *testing.T) {
+	ts, mock := newClientTestServer(t, nil)
+	client := gcclient.New(ts.URL, gcclient.WithCredentials("testuser", "SecurePass1"))
+
+	expectLogin(t, mock)
+	expectSession(mock, sqlmock.AnyArg())
+	expectGroup(mock, "edge-group")
+
+	group, err := client.GetGroup(context.Background(), "edge-group")
+	require.NoError(t, err)
+	require.Equal(t, int32(7), group.ID)
+	require.Equal(t, "http://harbor:8080", group.RegistryURL)
+	require.Equal(t, []string{"edge"}, group.Projects)
+	expired := client.Token()
+	require.NotEmpty(t, expired)
+
+	mock.ExpectQuery("SELECT .+ FROM sessions s").
+		WithArgs(expired).
+		WillReturnError(sql.ErrNoRows)
+	expectLogin(t, mock)
+	expectSession(mock, sqlmock.AnyArg())
+	expectGroup(mock, "edge-group")
+
+	_, err = client.GetGroup(context.Background(), "edge-group")
+	require.NoError(t, err)
+	require.NotEqual(t, expired, client.Token())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGCClientErrors(t *testing.T) {
+	ts, mock := newClientTestServer(t, nil)
+
+	t.Run("app error", func(t *testing.T) {
+		client := gcclient.New(ts.URL, gcclient.WithBearerToken("session-1"))
+		expectSession(mock, "session-1")
+		mock.ExpectQuery("SELECT .+ FROM groups WHERE group_name").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := client.GetGroup(context.Background(), "missing")
+		require.ErrorIs(t, err, gcclient.ErrNotFound)
+		var apiErr *gcclient.Error
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		require.Equal(t, "Group not found", apiErr.Message)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		client := gcclient.New(ts.URL)
+		_, err := client.ListGroups(context.Background())
+		require.ErrorIs(t, err, gcclient.ErrUnauthorized)
+		require.NotErrorIs(t, err, gcclient.ErrNotFound)
+	})
+}
+
+func TestGCClientGetSatellite(t *testing.T) {
+	ts, mock := newClientTestServer(t, nil)
+	client := gcclient.New(ts.URL, gcclient.WithBearerToken("session-1"))
+	now := time.Now().UTC().Truncate(time.Second)
+	ctx := context.Background()
+
+	t.Run("with groups and config", func(t *testing.T) {
+		expectSession(mock, "session-1")
+		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+			WithArgs("edge-01").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+				AddRow(10, "edge-01", now, now, sql.NullTime{}, sql.NullString{}))
+		mock.ExpectQuery("SELECT .+ FROM satellite_labels").
+			WithArgs(int32(10)).
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "key", "value"}).AddRow(10, "region", "eu"))
+		mock.ExpectQuery("SELECT .+ FROM satellite_groups").
+			WithArgs(int32(10)).
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "group_id"}).AddRow(10, 7))
+		mock.ExpectQuery("SELECT .+ FROM groups WHERE id").
+			WithArgs(int32(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "group_name", "registry_url", "projects", "created_at", "updated_at"}).
+				AddRow(7, "edge-group", "http://harbor:8080", "{library}", now, now))
+		mock.ExpectQuery("SELECT .+ FROM satellite_configs").
+			WithArgs(int32(10)).
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "config_id"}).AddRow(10, 1))
+		mock.ExpectQuery("SELECT .+ FROM configs WHERE id").
+			WithArgs(int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "config_name", "registry_url", "config", "created_at", "updated_at"}).
+				AddRow(1, "edge-config", "http://harbor:8080", []byte(`{}`), now, now))
+
+		sat, err := client.GetSatellite(ctx, "edge-01")
+		require.NoError(t, err)
+		require.Equal(t, int32(10), sat.ID)
+		require.Equal(t, map[string]string{"region": "eu"}, sat.Labels)
+		require.Equal(t, []string{"edge-group"}, sat.Groups)
+		require.Equal(t, "edge-config", sat.ConfigName)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		expectSession(mock, "session-1")
+		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := client.GetSatellite(ctx, "missing")
+		require.ErrorIs(t, err, gcclient.ErrNotFound)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGCClientRobotRoutes(t *testing.T) {
+	var authorization atomic.Value
+	ts, mock := newClientTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization.Store(r.Header.Get("Authorization"))
+			next.ServeHTTP(w, r)
+		})
+	})
+	robot := gcclient.New(ts.URL, gcclient.WithBasicAuth("robot$satellite-edge-01", "robot-secret"))
+
+	t.Run("sync authenticates with the robot account", func(t *testing.T) {
+		expectRobot(t, mock)
+		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+			WithArgs("edge-01").
+			WillReturnError(sql.ErrNoRows)
+
+		// The handler answering past the auth middleware shows the robot
+		// was accepted.
+		_, err := robot.Sync(context.Background(), gcclient.SatelliteStatusParams{Name: "edge-01"})
+		require.ErrorIs(t, err, gcclient.ErrForbidden)
+		require.ErrorContains(t, err, "unknown satellite entity")
+		require.Contains(t, authorization.Load(), "Basic ")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("sync with a wrong secret", func(t *testing.T) {
+		wrong := gcclient.New(ts.URL, gcclient.WithBasicAuth("robot$satellite-edge-01", "wrong"))
+		expectRobotAccount(t, mock)
+		_, err := wrong.Sync(context.Background(), gcclient.SatelliteStatusParams{Name: "edge-01"})
+		require.ErrorIs(t, err, gcclient.ErrUnauthorized)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ZTR sends the token in the path and no credentials", func(t *testing.T) {
+		mock.ExpectQuery("SELECT .+ FROM satellite_token").
+			WithArgs("ztr-token").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := robot.ZeroTouchRegister(context.Background(), "ztr-token")
+		require.ErrorIs(t, err, gcclient.ErrBadRequest)
+		require.ErrorContains(t, err, "Invalid Token")
+		require.Equal(t, "", authorization.Load())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TestGCClientWriteRoutes sends a write of every resource through the routes.
+func TestGCClientWriteRoutes(t *testing.T) {
+	ts, mock := newClientTestServer(t, nil)
+	client := gcclient.New(ts.URL, gcclient.WithBearerToken("session-1"))
+	now := time.Now().UTC().Truncate(time.Second)
+	ctx := context.Background()
+
+	t.Run("users", func(t *testing.T) {
+		expectSessionRole(mock, "session-1", roleSystemAdmin)
+		mock.ExpectQuery("INSERT INTO users").
+			WithArgs("operator", sqlmock.AnyArg(), roleAdmin).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role", "created_at", "updated_at"}).
+				AddRow(2, "operator", "hashed", roleAdmin, now, now))
+
+		user, err := client.CreateUser(ctx, gcclient.CreateUserRequest{Username: "operator", Password: "SecurePass1"})
+		require.NoError(t, err)
+		require.Equal(t, "operator", user.Username)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("groups", func(t *testing.T) {
+		expectSession(mock, "session-1")
+		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+			WithArgs("edge-01").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+				AddRow(10, "edge-01", now, now, sql.NullTime{}, sql.NullString{}))
+		expectGroup(mock, "edge-group")
+		mock.ExpectQuery("SELECT .+ FROM group_selectors").
+			WithArgs(int32(7)).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(int32(10), int32(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		resp, err := client.AddSatelliteToGroup(ctx, gcclient.SatelliteGroupParams{Satellite: "edge-01", Group: "edge-group"})
+		require.NoError(t, err)
+		require.Equal(t, "Satellite is already in the group", resp.Message)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("configs", func(t *testing.T) {
+		expectSession(mock, "session-1")
+		mock.ExpectQuery("SELECT .+ FROM configs WHERE config_name").
+			WithArgs("edge-config").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "config_name", "registry_url", "config", "created_at", "updated_at"}).
+				AddRow(1, "edge-config", "http://harbor:8080", []byte(`{}`), now, now))
+		mock.ExpectQuery("SELECT .+ FROM satellite_configs").
+			WithArgs(int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "config_id"}).AddRow(10, 1))
+
+		err := client.DeleteConfig(ctx, "edge-config")
+		require.ErrorIs(t, err, gcclient.ErrBadRequest)
+		require.ErrorContains(t, err, "Cannot delete config that is in use")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("satellites", func(t *testing.T) {
+		expectSession(mock, "session-1")
+		mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+			WithArgs("edge-01").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+				AddRow(10, "edge-01", now, now, sql.NullTime{}, sql.NullString{}))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM satellite_labels").
+			WithArgs(int32(10)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO satellite_labels").
+			WithArgs(int32(10), "region", "eu").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT .+ FROM group_selectors").
+			WillReturnRows(sqlmock.NewRows([]string{"group_id", "selector"}))
+		mock.ExpectQuery("SELECT .+ FROM satellite_groups").
+			WithArgs(int32(10)).
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "group_id"}))
+		mock.ExpectCommit()
+
+		labels, err := client.SetSatelliteLabels(ctx, "edge-01", map[string]string{"region": "eu"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"region": "eu"}, labels)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGCClientRetries(t *testing.T) {
+	var calls, failures atomic.Int32
+	ts, _ := newClientTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			if failures.Add(-1) >= 0 {
+				WriteJSONError(w, "Service Unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	client := gcclient.New(ts.URL, gcclient.WithRetry(gcclient.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}))
+
+	t.Run("idempotent requests", func(t *testing.T) {
+		calls.Store(0)
+		failures.Store(2)
+		require.NoError(t, client.Ping(context.Background()))
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		calls.Store(0)
+		failures.Store(3)
+		err := client.Ping(context.Background())
+		require.ErrorIs(t, err, gcclient.ErrUnavailable)
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("not other requests", func(t *testing.T) {
+		calls.Store(0)
+		failures.Store(1)
+		_, err := client.Login(context.Background(), "testuser", "SecurePass1")
+		require.ErrorIs(t, err, gcclient.ErrUnavailable)
+		require.Equal(t, int32(1), calls.Load())
+	})
+}
+
+// TestGCClientTypesMatchServer keeps the types of gcclient in sync with the
+// request and response types of the handlers.
+func TestGCClientTypesMatchServer(t *testing.T) {
+	pairs := []struct {
+		server, client any
+	}{
+		{loginRequest{}, gcclient.LoginRequest{}},
+		{loginResponse{}, gcclient.LoginResponse{}},
+		{userResponse{}, gcclient.User{}},
+		{createUserRequest{}, gcclient.CreateUserRequest{}},
+		{changePasswordRequest{}, gcclient.ChangePasswordRequest{}},
+		{changeUserPasswordRequest{}, gcclient.ChangeUserPasswordRequest{}},
+		{database.Group{}, gcclient.Group{}},
+		{database.GetSatellitesByGroupNameRow{}, gcclient.GroupMember{}},
+		{SatelliteGroupParams{}, gcclient.SatelliteGroupParams{}},
+		{groupConfigLayerRequest{}, gcclient.GroupConfigLayerRequest{}},
+		{groupConfigLayerResponse{}, gcclient.GroupConfigLayer{}},
+		{groupSelectorRequest{}, gcclient.GroupSelectorRequest{}},
+		{groupSelectorResponse{}, gcclient.GroupSelector{}},
+		{database.Config{}, gcclient.Config{}},
+		{SatelliteConfigParams{}, gcclient.SatelliteConfigParams{}},
+		{configRevisionResponse{}, gcclient.ConfigRevision{}},
+		{configRevisionDiff{}, gcclient.ConfigRevisionDiff{}},
+		{configRollbackRequest{}, gcclient.ConfigRollbackRequest{}},
+		{configRolloutRequest{}, gcclient.ConfigRolloutRequest{}},
+		{rolloutCanary{}, gcclient.RolloutCanary{}},
+		{configRolloutStatus{}, gcclient.ConfigRollout{}},
+		{rolloutSummary{}, gcclient.RolloutSummary{}},
+		{rolloutSatelliteStatus{}, gcclient.RolloutSatellite{}},
+		{labeledSatellite{}, gcclient.Satellite{}},
+		{satelliteResponse{}, gcclient.SatelliteDetails{}},
+		{database.GetActiveSatellitesRow{}, gcclient.ActiveSatellite{}},
+		{database.GetStaleSatellitesRow{}, gcclient.StaleSatellite{}},
+		{RegisterSatelliteParams{}, gcclient.RegisterSatelliteParams{}},
+		{RegisterSatelliteResponse{}, gcclient.RegisterSatelliteResponse{}},
+		{database.SatelliteStatus{}, gcclient.SatelliteStatus{}},
+		{CachedImage{}, gcclient.CachedImage{}},
+		{effectiveConfigResponse{}, gcclient.EffectiveConfig{}},
+		{configLayer{}, gcclient.ConfigLayer{}},
+		{satelliteConfigOverrideResponse{}, gcclient.SatelliteConfigOverride{}},
+		{satelliteLabelsRequest{}, gcclient.SatelliteLabelsRequest{}},
+		{satelliteLabelsResponse{}, gcclient.SatelliteLabels{}},
+		{SPIREStatusResponse{}, gcclient.SPIREStatus{}},
+		{AgentInfoResponse{}, gcclient.SpireAgent{}},
+		{RegisterSatelliteRequest{}, gcclient.RegisterSatelliteWithSPIFFERequest{}},
+		{RegisterSatelliteWithSPIFFEResponse{}, gcclient.RegisterSatelliteWithSPIFFEResponse{}},
+		{SatelliteStatusParams{}, gcclient.SatelliteStatusParams{}},
+		{DependencyBreaker{}, gcclient.DependencyBreaker{}},
+		{ImageWarmStatus{}, gcclient.ImageWarmStatus{}},
+		{LegacyCachedImage{}, gcclient.LegacyCachedImage{}},
+		{SyncResponse{}, gcclient.SyncResponse{}},
+	}
+	for _, p := range pairs {
+		server, client := reflect.TypeOf(p.server), reflect.TypeOf(p.client)
+		require.Equal(t, jsonFields(server), jsonFields(client), "%s and %s", server, client)
+	}
+}
+
+// jsonFields returns the kind and omitempty option of the JSON fields of a
+// struct by name. Named string types such as swaggerDateTime hold times.
+func jsonFields(t reflect.Type) map[string]string {
+	fields := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			for name, kind := range jsonFields(f.Type) {
+				fields[name] = kind
+			}
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		kind := f.Type.Kind().String()
+		if f.Type.Name() == "swaggerDateTime" || f.Type == reflect.TypeOf(time.Time{}) {
+			kind = "time"
+		}
+		fields[name] = kind + "," + opts
+	}
+	return fields
+}
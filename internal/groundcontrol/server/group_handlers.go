@@ -282,7 +282,7 @@ func (s *Server) deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Update state artifact
-		err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName)
+		err = pushSatelliteState(r.Context(), q, sat.ID, sat.Name, groupStates, configObject.ConfigName)
 		if err != nil {
 			log.Println(err)
 			err := &AppError{
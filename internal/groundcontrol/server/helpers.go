@@ -289,6 +289,20 @@ func toNullInt32(n int32) sql.NullInt32 {
 	return sql.NullInt32{Int32: n, Valid: true}
 }
 
+func toNullInt64Ptr(n *int64) sql.NullInt64 {
+	if n == nil {
+		return sql.NullInt64{}
+	}
+	return toNullInt64(*n)
+}
+
+func toNullInt32Ptr(n *int32) sql.NullInt32 {
+	if n == nil {
+		return sql.NullInt32{}
+	}
+	return toNullInt32(*n)
+}
+
 // normalizeHeartbeatInterval validates and normalizes the heartbeat interval to a canonical format.
 // Expected input format: "@every HHhMMmSSs" (e.g., "@every 00h01m30s")
 // Returns the normalized interval string or an error if the format is invalid.
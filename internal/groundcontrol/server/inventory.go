@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/container-registry/harbor-satellite/internal/inventory"
+)
+
+// applyInventory stores the inventory section of a heartbeat. It reports
+// whether the satellite must send every image next time, because there is
+// no inventory to apply a delta to or the stored one no longer matches the
+// snapshot hash of the satellite.
+func (s *Server) applyInventory(ctx context.Context, satelliteID int32, report *inventory.Report) (bool, error) {
+	var resync bool
+	err := s.inTx(ctx, func(q *database.Queries) error {
+		stored, err := q.GetSatelliteInventory(ctx, satelliteID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if !report.Full {
+				resync = true
+				return nil
+			}
+		case err != nil:
+			return fmt.Errorf("get inventory: %w", err)
+		case !report.Full && len(report.Upserted) == 0 && len(report.Removed) == 0 &&
+			stored.SnapshotHash == report.SnapshotHash:
+			return nil
+		}
+
+		repos, tags := splitRefs(imageRefs(report.Upserted))
+		if len(report.Upserted) > 0 {
+			digests := make([]string, len(report.Upserted))
+			sizes := make([]int64, len(report.Upserted))
+			for i, img := range report.Upserted {
+				digests[i] = img.Digest
+				sizes[i] = img.SizeBytes
+			}
+			if err := q.UpsertImageManifests(ctx, database.UpsertImageManifestsParams{
+				Digests: digests,
+				Sizes:   sizes,
+			}); err != nil {
+				return fmt.Errorf("save manifests: %w", err)
+			}
+			if err := q.UpsertSatelliteImages(ctx, database.UpsertSatelliteImagesParams{
+				SatelliteID:  satelliteID,
+				Repositories: repos,
+				Tags:         tags,
+				Digests:      digests,
+			}); err != nil {
+				return fmt.Errorf("save images: %w", err)
+			}
+		}
+
+		if report.Full {
+			if err := q.DeleteSatelliteImagesExcept(ctx, database.DeleteSatelliteImagesExceptParams{
+				SatelliteID:  satelliteID,
+				Repositories: repos,
+				Tags:         tags,
+			}); err != nil {
+				return fmt.Errorf("delete images: %w", err)
+			}
+		} else if len(report.Removed) > 0 {
+			removedRepos, removedTags := splitRefs(report.Removed)
+			if err := q.DeleteSatelliteImages(ctx, database.DeleteSatelliteImagesParams{
+				Repositories: removedRepos,
+				Tags:         removedTags,
+				SatelliteID:  satelliteID,
+			}); err != nil {
+				return fmt.Errorf("delete images: %w", err)
+			}
+		}
+
+		rows, err := q.ListSatelliteImages(ctx, satelliteID)
+		if err != nil {
+			return fmt.Errorf("list images: %w", err)
+		}
+		current := make([]inventory.Image, len(rows))
+		for i, row := range rows {
+			current[i] = inventory.Image{
+				Ref:       inventory.Ref{Repository: row.Repository, Tag: row.Tag},
+				Digest:    row.Digest,
+				SizeBytes: row.SizeBytes,
+			}
+		}
+		hash := inventory.Hash(current)
+		if hash != report.SnapshotHash {
+			log.Printf("Inventory of satellite %d does not match its snapshot hash, requesting a resync", satelliteID)
+			resync = true
+		}
+
+		if err := q.UpsertSatelliteInventory(ctx, database.UpsertSatelliteInventoryParams{
+			SatelliteID:  satelliteID,
+			SnapshotHash: hash,
+			ImageCount:   int32(len(current)),
+		}); err != nil {
+			return fmt.Errorf("save inventory: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return resync, nil
+}
+
+func imageRefs(imgs []inventory.Image) []inventory.Ref {
+	refs := make([]inventory.Ref, len(imgs))
+	for i, img := range imgs {
+		refs[i] = img.Ref
+	}
+	return refs
+}
+
+func splitRefs(refs []inventory.Ref) ([]string, []string) {
+	repos := make([]string, len(refs))
+	tags := make([]string, len(refs))
+	for i, ref := range refs {
+		repos[i] = ref.Repository
+		tags[i] = ref.Tag
+	}
+	return repos, tags
+}
+
+// legacyInventory turns the cached image list of satellites predating
+// inventory reports into a full report. References have the form
+// "host/repository:tag@digest"; those that do not parse are skipped.
+func legacyInventory(cached []LegacyCachedImage) *inventory.Report {
+	report := &inventory.Report{Full: true, Upserted: []inventory.Image{}}
+	for _, img := range cached {
+		name, digest, ok := strings.Cut(img.Reference, "@")
+		if !ok {
+			continue
+		}
+		if _, path, ok := strings.Cut(name, "/"); ok {
+			name = path
+		}
+		i := strings.LastIndex(name, ":")
+		if i <= 0 || strings.Contains(name[i:], "/") {
+			continue
+		}
+		report.Upserted = append(report.Upserted, inventory.Image{
+			Ref:       inventory.Ref{Repository: name[:i], Tag: name[i+1:]},
+			Digest:    digest,
+			SizeBytes: img.SizeBytes,
+		})
+	}
+	report.SnapshotHash = inventory.Hash(report.Upserted)
+	return report
+}
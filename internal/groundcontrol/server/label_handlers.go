@@ -0,0 +1,323 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	auditlog "github.com/container-registry/harbor-satellite/internal/groundcontrol/logger"
+)
+
+// SatelliteLabelsRequest replaces the labels of a satellite.
+//
+// swagger:model SatelliteLabelsRequest
+type satelliteLabelsRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// SatelliteLabels are the key/value labels of a satellite.
+//
+// swagger:model SatelliteLabels
+type satelliteLabelsResponse struct {
+	Satellite string            `json:"satellite"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// GroupSelectorRequest makes a group dynamic.
+//
+// swagger:model GroupSelectorRequest
+type groupSelectorRequest struct {
+	// Selector picks the members of the group by their labels, e.g.
+	// "tier=prod,region in (eu,us)".
+	Selector string `json:"selector"`
+}
+
+// GroupSelector is the label selector of a dynamic group. Added and Removed
+// list the satellites whose membership changed when it was set.
+//
+// swagger:model GroupSelector
+type groupSelectorResponse struct {
+	Group     string    `json:"group"`
+	Selector  string    `json:"selector"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Added     []string  `json:"added,omitempty"`
+	Removed   []string  `json:"removed,omitempty"`
+}
+
+// labeledSatellite is a satellite as listed, with its labels.
+type labeledSatellite struct {
+	database.Satellite
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// checkStaticGroup refuses manual membership changes to a dynamic group.
+func checkStaticGroup(ctx context.Context, q *database.Queries, group database.Group) error {
+	_, err := q.GetGroupSelector(ctx, group.ID)
+	switch {
+	case err == nil:
+		return &AppError{
+			Message: fmt.Sprintf("error: membership of group %s is managed by its selector", group.GroupName),
+			Code:    http.StatusConflict,
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	default:
+		log.Printf("Error: Failed to get group selector: %v", err)
+		return &AppError{Message: "error: failed to get group selector", Code: http.StatusInternalServerError}
+	}
+}
+
+func (s *Server) getSatelliteLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	sat, err := s.loadSatellite(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	labels, err := satelliteLabels(r.Context(), s.dbQueries, sat.ID)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get satellite labels", Code: http.StatusInternalServerError})
+		return
+	}
+	WriteJSONResponse(w, http.StatusOK, satelliteLabelsResponse{Satellite: sat.Name, Labels: labels})
+}
+
+// setSatelliteLabelsHandler replaces the labels of a satellite and moves it
+// into and out of the dynamic groups whose selectors it now matches.
+func (s *Server) setSatelliteLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	var req satelliteLabelsRequest
+	if err := DecodeRequestBody(r, &req); err != nil {
+		log.Println("Error decoding request body: ", err)
+		HandleAppError(w, err)
+		return
+	}
+	if err := validateLabels(req.Labels); err != nil {
+		HandleAppError(w, &AppError{Message: fmt.Sprintf("error: %v", err), Code: http.StatusBadRequest})
+		return
+	}
+	if req.Labels == nil {
+		req.Labels = map[string]string{}
+	}
+
+	sat, err := s.loadSatellite(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		if err := setSatelliteLabels(r.Context(), q, sat.ID, req.Labels); err != nil {
+			return err
+		}
+		changed, err := applyDynamicGroups(r.Context(), q, sat.ID, req.Labels)
+		if err != nil || !changed {
+			return err
+		}
+		return refreshSatelliteGroups(r.Context(), q, sat)
+	})
+	if err != nil {
+		log.Printf("Error: Failed to set satellite labels: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, auditlog.AuditEvent{
+		Operation:    auditlog.OpUpdate,
+		ResourceType: auditlog.ResSatellite,
+		Outcome:      auditlog.OutcomeSuccess,
+		SatelliteID:  sat.Name,
+		Details:      map[string]any{"labels": req.Labels},
+	})
+	WriteJSONResponse(w, http.StatusOK, satelliteLabelsResponse{Satellite: sat.Name, Labels: req.Labels})
+}
+
+func (s *Server) getGroupSelectorHandler(w http.ResponseWriter, r *http.Request) {
+	group, err := s.loadGroup(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	gs, err := s.dbQueries.GetGroupSelector(r.Context(), group.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			HandleAppError(w, &AppError{Message: "error: group has no selector", Code: http.StatusNotFound})
+			return
+		}
+		log.Printf("Error: Failed to get group selector: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get group selector", Code: http.StatusInternalServerError})
+		return
+	}
+	WriteJSONResponse(w, http.StatusOK, groupSelectorResponse{
+		Group:     group.GroupName,
+		Selector:  gs.Selector,
+		UpdatedBy: gs.UpdatedBy,
+		UpdatedAt: gs.UpdatedAt,
+	})
+}
+
+// setGroupSelectorHandler makes a group dynamic: its members become exactly
+// the satellites matching the selector, and satellites labelled later join or
+// leave it as they match.
+func (s *Server) setGroupSelectorHandler(w http.ResponseWriter, r *http.Request) {
+	var req groupSelectorRequest
+	if err := DecodeRequestBody(r, &req); err != nil {
+		log.Println("Error decoding request body: ", err)
+		HandleAppError(w, err)
+		return
+	}
+	sel, err := parseLabelSelector(req.Selector)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: fmt.Sprintf("error: %v", err), Code: http.StatusBadRequest})
+		return
+	}
+
+	group, err := s.loadGroup(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	if err := ensureSatelliteProjectExists(r.Context()); err != nil {
+		log.Println("Error while ensuring project satellite: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	var gs database.GroupSelector
+	var added, removed []string
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		gs, err = q.SetGroupSelector(r.Context(), database.SetGroupSelectorParams{
+			GroupID:   group.ID,
+			Selector:  sel.String(),
+			UpdatedBy: actorFromContext(r.Context()),
+		})
+		if err != nil {
+			return fmt.Errorf("set group selector: %w", err)
+		}
+		added, removed, err = reconcileDynamicGroup(r.Context(), q, group.ID, sel)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error: Failed to set group selector: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, auditlog.AuditEvent{
+		Operation:    auditlog.OpUpdate,
+		ResourceType: auditlog.ResGroupSelector,
+		Outcome:      auditlog.OutcomeSuccess,
+		Resource:     group.GroupName,
+		Details:      map[string]any{"selector": gs.Selector, "added": added, "removed": removed},
+	})
+	WriteJSONResponse(w, http.StatusOK, groupSelectorResponse{
+		Group:     group.GroupName,
+		Selector:  gs.Selector,
+		UpdatedBy: gs.UpdatedBy,
+		UpdatedAt: gs.UpdatedAt,
+		Added:     added,
+		Removed:   removed,
+	})
+}
+
+// deleteGroupSelectorHandler makes a dynamic group static again. Its current
+// members stay in the group.
+func (s *Server) deleteGroupSelectorHandler(w http.ResponseWriter, r *http.Request) {
+	group, err := s.loadGroup(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		if _, err := q.GetGroupSelector(r.Context(), group.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &AppError{Message: "error: group has no selector", Code: http.StatusNotFound}
+			}
+			return fmt.Errorf("get group selector: %w", err)
+		}
+		if err := q.DeleteGroupSelector(r.Context(), group.ID); err != nil {
+			return fmt.Errorf("delete group selector: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error: Failed to delete group selector: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, auditlog.AuditEvent{
+		Operation:    auditlog.OpDelete,
+		ResourceType: auditlog.ResGroupSelector,
+		Outcome:      auditlog.OutcomeSuccess,
+		Resource:     group.GroupName,
+	})
+	WriteJSONResponse(w, http.StatusOK, map[string]string{})
+}
+
+// addSelectedSatellitesToGroup adds the satellites matching a selector to a
+// static group, once. Satellites labelled later do not join it.
+func (s *Server) addSelectedSatellitesToGroup(w http.ResponseWriter, r *http.Request, req SatelliteGroupParams) {
+	sel, err := parseLabelSelector(req.Selector)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: fmt.Sprintf("error: %v", err), Code: http.StatusBadRequest})
+		return
+	}
+
+	grp, err := s.dbQueries.GetGroupByName(r.Context(), req.Group)
+	if err != nil {
+		log.Printf("Error: Group Not Found: %v", err)
+		HandleAppError(w, &AppError{Message: "Error: Group Not Found", Code: http.StatusBadRequest})
+		return
+	}
+	if err := checkStaticGroup(r.Context(), s.dbQueries, grp); err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	added := []string{}
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		sats, err := selectSatellites(r.Context(), q, sel)
+		if err != nil {
+			return err
+		}
+		for _, sat := range sats {
+			member, err := q.CheckSatelliteInGroup(r.Context(), database.CheckSatelliteInGroupParams{
+				SatelliteID: sat.ID,
+				GroupID:     grp.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("check satellite %s in group: %w", sat.Name, err)
+			}
+			if member {
+				continue
+			}
+			if err := q.AddSatelliteToGroup(r.Context(), database.AddSatelliteToGroupParams{
+				SatelliteID: sat.ID,
+				GroupID:     grp.ID,
+			}); err != nil {
+				return fmt.Errorf("add satellite %s to group: %w", sat.Name, err)
+			}
+			if err := refreshSatelliteGroups(r.Context(), q, sat); err != nil {
+				return err
+			}
+			added = append(added, sat.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error: Failed to add satellites to group: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	WriteJSONResponse(w, http.StatusOK, map[string]any{"group": grp.GroupName, "added": added})
+}
@@ -0,0 +1,463 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/utils"
+)
+
+var (
+	// Label keys may carry a DNS-style prefix, as in "example.com/site".
+	labelKeyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?)?$`)
+	setRequirement    = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// validateLabels checks the keys and values of a satellite's labels.
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !labelKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if !labelValuePattern.MatchString(v) {
+			return fmt.Errorf("invalid value %q for label %s", v, k)
+		}
+	}
+	return nil
+}
+
+type selectorOp string
+
+const (
+	opEquals    selectorOp = "="
+	opNotEquals selectorOp = "!="
+	opIn        selectorOp = "in"
+	opNotIn     selectorOp = "notin"
+	opExists    selectorOp = "exists"
+	opNotExists selectorOp = "!"
+)
+
+type labelRequirement struct {
+	key    string
+	op     selectorOp
+	values []string
+}
+
+func (req labelRequirement) matches(labels map[string]string) bool {
+	v, ok := labels[req.key]
+	switch req.op {
+	case opEquals:
+		return ok && v == req.values[0]
+	case opNotEquals:
+		return !ok || v != req.values[0]
+	case opIn:
+		return ok && slices.Contains(req.values, v)
+	case opNotIn:
+		return !ok || !slices.Contains(req.values, v)
+	case opExists:
+		return ok
+	case opNotExists:
+		return !ok
+	}
+	return false
+}
+
+func (req labelRequirement) String() string {
+	switch req.op {
+	case opEquals, opNotEquals:
+		return req.key + string(req.op) + req.values[0]
+	case opIn, opNotIn:
+		return fmt.Sprintf("%s %s (%s)", req.key, req.op, strings.Join(req.values, ","))
+	case opNotExists:
+		return "!" + req.key
+	}
+	return req.key
+}
+
+// labelSelector selects satellites by their labels. It is a comma-separated
+// list of requirements that all have to hold:
+//
+//	tier=prod, site!=berlin, hw in (jetson,nuc), hw notin (pi), gpu, !legacy
+type labelSelector []labelRequirement
+
+// parseLabelSelector parses a selector. An empty selector is an error, since
+// it would select every satellite.
+func parseLabelSelector(s string) (labelSelector, error) {
+	terms, err := splitSelector(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("empty label selector")
+	}
+	sel := make(labelSelector, 0, len(terms))
+	for _, term := range terms {
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// splitSelector splits a selector at the commas that are not inside a value
+// list.
+func splitSelector(s string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in label selector %q", s)
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in label selector %q", s)
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(terms) > 0 {
+		terms = append(terms, last)
+	}
+	for _, term := range terms {
+		if term == "" {
+			return nil, fmt.Errorf("empty requirement in label selector %q", s)
+		}
+	}
+	return terms, nil
+}
+
+func parseRequirement(term string) (labelRequirement, error) {
+	var req labelRequirement
+	switch {
+	case setRequirement.MatchString(term):
+		m := setRequirement.FindStringSubmatch(term)
+		req = labelRequirement{key: m[1], op: selectorOp(m[2])}
+		for _, v := range strings.Split(m[3], ",") {
+			if v = strings.TrimSpace(v); v == "" {
+				return labelRequirement{}, fmt.Errorf("empty value in requirement %q", term)
+			}
+			req.values = append(req.values, v)
+		}
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		req = labelRequirement{key: strings.TrimSpace(term[1:]), op: opNotExists}
+	case strings.Contains(term, "!="):
+		k, v, _ := strings.Cut(term, "!=")
+		req = labelRequirement{key: strings.TrimSpace(k), op: opNotEquals, values: []string{strings.TrimSpace(v)}}
+	case strings.Contains(term, "="):
+		k, v, _ := strings.Cut(term, "=")
+		v = strings.TrimPrefix(v, "=")
+		req = labelRequirement{key: strings.TrimSpace(k), op: opEquals, values: []string{strings.TrimSpace(v)}}
+	default:
+		req = labelRequirement{key: term, op: opExists}
+	}
+
+	if !labelKeyPattern.MatchString(req.key) {
+		return labelRequirement{}, fmt.Errorf("invalid label key %q in requirement %q", req.key, term)
+	}
+	for _, v := range req.values {
+		if !labelValuePattern.MatchString(v) {
+			return labelRequirement{}, fmt.Errorf("invalid label value %q in requirement %q", v, term)
+		}
+	}
+	return req, nil
+}
+
+// Matches reports whether labels satisfy every requirement of the selector.
+func (sel labelSelector) Matches(labels map[string]string) bool {
+	for _, req := range sel {
+		if !req.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the selector in its canonical form, as it is stored.
+func (sel labelSelector) String() string {
+	terms := make([]string, 0, len(sel))
+	for _, req := range sel {
+		terms = append(terms, req.String())
+	}
+	return strings.Join(terms, ",")
+}
+
+// selectorParam parses the optional selector query parameter of a listing.
+func selectorParam(value string) (labelSelector, error) {
+	if value == "" {
+		return nil, nil
+	}
+	sel, err := parseLabelSelector(value)
+	if err != nil {
+		return nil, &AppError{Message: fmt.Sprintf("error: %v", err), Code: 400}
+	}
+	return sel, nil
+}
+
+// satelliteLabels returns the labels of one satellite.
+func satelliteLabels(ctx context.Context, q *database.Queries, satelliteID int32) (map[string]string, error) {
+	rows, err := q.ListSatelliteLabels(ctx, satelliteID)
+	if err != nil {
+		return nil, fmt.Errorf("list satellite labels: %w", err)
+	}
+	labels := make(map[string]string, len(rows))
+	for _, l := range rows {
+		labels[l.Key] = l.Value
+	}
+	return labels, nil
+}
+
+// allSatelliteLabels returns the labels of every satellite, by satellite ID.
+func allSatelliteLabels(ctx context.Context, q *database.Queries) (map[int32]map[string]string, error) {
+	rows, err := q.ListAllSatelliteLabels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list satellite labels: %w", err)
+	}
+	labels := make(map[int32]map[string]string)
+	for _, l := range rows {
+		if labels[l.SatelliteID] == nil {
+			labels[l.SatelliteID] = make(map[string]string)
+		}
+		labels[l.SatelliteID][l.Key] = l.Value
+	}
+	return labels, nil
+}
+
+// setSatelliteLabels replaces the labels of a satellite.
+func setSatelliteLabels(ctx context.Context, q *database.Queries, satelliteID int32, labels map[string]string) error {
+	if err := q.DeleteSatelliteLabels(ctx, satelliteID); err != nil {
+		return fmt.Errorf("clear satellite labels: %w", err)
+	}
+	for k, v := range labels {
+		err := q.AddSatelliteLabel(ctx, database.AddSatelliteLabelParams{SatelliteID: satelliteID, Key: k, Value: v})
+		if err != nil {
+			return fmt.Errorf("add satellite label %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// selectSatellites returns the satellites whose labels match sel.
+func selectSatellites(ctx context.Context, q *database.Queries, sel labelSelector) ([]database.Satellite, error) {
+	sats, err := q.ListSatellites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list satellites: %w", err)
+	}
+	labels, err := allSatelliteLabels(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	var selected []database.Satellite
+	for _, sat := range sats {
+		if sel.Matches(labels[sat.ID]) {
+			selected = append(selected, sat)
+		}
+	}
+	return selected, nil
+}
+
+// filterBySelector keeps the items of a listing whose satellite matches sel.
+func filterBySelector[T any](items []T, satelliteID func(T) int32, labels map[int32]map[string]string, sel labelSelector) []T {
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if sel.Matches(labels[satelliteID(item)]) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// dynamicGroupNames returns the names of the dynamic groups whose selector
+// matches labels.
+func dynamicGroupNames(ctx context.Context, q *database.Queries, labels map[string]string) ([]string, error) {
+	selectors, err := q.ListGroupSelectors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list group selectors: %w", err)
+	}
+	var names []string
+	for _, gs := range selectors {
+		sel, err := parseLabelSelector(gs.Selector)
+		if err != nil {
+			log.Printf("Skipping invalid selector of group %d: %v", gs.GroupID, err)
+			continue
+		}
+		if !sel.Matches(labels) {
+			continue
+		}
+		grp, err := q.GetGroupByID(ctx, gs.GroupID)
+		if err != nil {
+			return nil, fmt.Errorf("get group %d: %w", gs.GroupID, err)
+		}
+		names = append(names, grp.GroupName)
+	}
+	return names, nil
+}
+
+// applyDynamicGroups adds a satellite to the dynamic groups its labels match
+// and removes it from those they no longer match. It reports whether the
+// satellite's groups changed.
+func applyDynamicGroups(ctx context.Context, q *database.Queries, satelliteID int32, labels map[string]string) (bool, error) {
+	selectors, err := q.ListGroupSelectors(ctx)
+	if err != nil {
+		return false, fmt.Errorf("list group selectors: %w", err)
+	}
+	groups, err := q.SatelliteGroupList(ctx, satelliteID)
+	if err != nil {
+		return false, fmt.Errorf("list groups of satellite: %w", err)
+	}
+	member := make(map[int32]bool, len(groups))
+	for _, g := range groups {
+		member[g.GroupID] = true
+	}
+
+	changed := false
+	for _, gs := range selectors {
+		sel, err := parseLabelSelector(gs.Selector)
+		if err != nil {
+			log.Printf("Skipping invalid selector of group %d: %v", gs.GroupID, err)
+			continue
+		}
+		switch match := sel.Matches(labels); {
+		case match && !member[gs.GroupID]:
+			err = q.AddSatelliteToGroup(ctx, database.AddSatelliteToGroupParams{SatelliteID: satelliteID, GroupID: gs.GroupID})
+		case !match && member[gs.GroupID]:
+			err = q.RemoveSatelliteFromGroup(ctx, database.RemoveSatelliteFromGroupParams{SatelliteID: satelliteID, GroupID: gs.GroupID})
+		default:
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("update dynamic group %d: %w", gs.GroupID, err)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// reconcileDynamicGroup makes the members of a group exactly the satellites
+// matching sel, and returns the names of the satellites added and removed.
+func reconcileDynamicGroup(ctx context.Context, q *database.Queries, groupID int32, sel labelSelector) ([]string, []string, error) {
+	sats, err := q.ListSatellites(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list satellites: %w", err)
+	}
+	labels, err := allSatelliteLabels(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	members, err := q.GroupSatelliteList(ctx, groupID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list group satellites: %w", err)
+	}
+	member := make(map[int32]bool, len(members))
+	for _, m := range members {
+		member[m.SatelliteID] = true
+	}
+
+	var added, removed []string
+	for _, sat := range sats {
+		switch match := sel.Matches(labels[sat.ID]); {
+		case match && !member[sat.ID]:
+			err = q.AddSatelliteToGroup(ctx, database.AddSatelliteToGroupParams{SatelliteID: sat.ID, GroupID: groupID})
+			added = append(added, sat.Name)
+		case !match && member[sat.ID]:
+			err = q.RemoveSatelliteFromGroup(ctx, database.RemoveSatelliteFromGroupParams{SatelliteID: sat.ID, GroupID: groupID})
+			removed = append(removed, sat.Name)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("update membership of satellite %s: %w", sat.Name, err)
+		}
+		if err := refreshSatelliteGroups(ctx, q, sat); err != nil {
+			return nil, nil, err
+		}
+	}
+	return added, removed, nil
+}
+
+// refreshSatelliteGroups brings the robot account permissions and the state
+// artifact of a satellite in line with its groups.
+func refreshSatelliteGroups(ctx context.Context, q *database.Queries, sat database.Satellite) error {
+	groups, err := q.SatelliteGroupList(ctx, sat.ID)
+	if err != nil {
+		return fmt.Errorf("list groups of satellite %s: %w", sat.Name, err)
+	}
+	var projects, states []string
+	for _, g := range groups {
+		grp, err := q.GetGroupByID(ctx, g.GroupID)
+		if err != nil {
+			return fmt.Errorf("get group %d: %w", g.GroupID, err)
+		}
+		projects = append(projects, grp.Projects...)
+		states = append(states, utils.AssembleGroupState(grp.GroupName))
+	}
+
+	robot, err := q.GetRobotAccBySatelliteID(ctx, sat.ID)
+	switch {
+	case err == nil:
+		if _, err := utils.UpdateRobotProjects(ctx, projects, robot.RobotID); err != nil {
+			return fmt.Errorf("update robot account permissions of satellite %s: %w", sat.Name, err)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("get robot account of satellite %s: %w", sat.Name, err)
+	}
+
+	configName, err := satelliteConfigName(ctx, q, sat.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get config of satellite %s: %w", sat.Name, err)
+	}
+	if err := pushSatelliteState(ctx, q, sat.ID, sat.Name, states, configName); err != nil {
+		return fmt.Errorf("update state artifact of satellite %s: %w", sat.Name, err)
+	}
+	return nil
+}
+
+// labelNewSatellite sets the labels of a newly registered satellite and adds
+// it to the dynamic groups they select.
+func labelNewSatellite(ctx context.Context, q *database.Queries, sat database.Satellite, labels map[string]string) error {
+	if err := setSatelliteLabels(ctx, q, sat.ID, labels); err != nil {
+		return err
+	}
+	changed, err := applyDynamicGroups(ctx, q, sat.ID, labels)
+	if err != nil || !changed {
+		return err
+	}
+	return refreshSatelliteGroups(ctx, q, sat)
+}
+
+// mergeGroupNames adds the names in extra that groups does not list yet.
+func mergeGroupNames(groups *[]string, extra []string) *[]string {
+	if len(extra) == 0 {
+		return groups
+	}
+	var merged []string
+	if groups != nil {
+		merged = append(merged, *groups...)
+	}
+	for _, name := range extra {
+		if !slices.Contains(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+	return &merged
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		selector  string
+		canonical string
+	}{
+		{"tier=prod", "tier=prod"},
+		{"tier==prod", "tier=prod"},
+		{" tier = prod , site!=berlin ", "tier=prod,site!=berlin"},
+		{"hw in (jetson, nuc),hw notin(pi)", "hw in (jetson,nuc),hw notin (pi)"},
+		{"gpu,!legacy", "gpu,!legacy"},
+		{"example.com/site=fra-1", "example.com/site=fra-1"},
+		{"tier=", "tier="},
+	}
+	for _, tt := range tests {
+		sel, err := parseLabelSelector(tt.selector)
+		require.NoError(t, err, tt.selector)
+		require.Equal(t, tt.canonical, sel.String(), tt.selector)
+
+		again, err := parseLabelSelector(sel.String())
+		require.NoError(t, err)
+		require.Equal(t, sel, again, "canonical form parses back")
+	}
+
+	for _, selector := range []string{"", " ", "tier=prod,", ",tier=prod", "tier in (prod", "tier in prod)", "-tier=prod", "tier=pr od", "tier in (prod,)"} {
+		_, err := parseLabelSelector(selector)
+		require.Error(t, err, selector)
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"tier": "prod", "site": "fra", "gpu": ""}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"tier=prod", true},
+		{"tier=dev", false},
+		{"tier!=dev", true},
+		{"zone!=a", true},
+		{"site in (fra,ber)", true},
+		{"site notin (fra,ber)", false},
+		{"zone notin (a)", true},
+		{"zone in (a)", false},
+		{"gpu", true},
+		{"!gpu", false},
+		{"!legacy", true},
+		{"gpu=", true},
+		{"tier=prod,site=ber", false},
+		{"tier=prod,site=fra,!legacy", true},
+	}
+	for _, tt := range tests {
+		sel, err := parseLabelSelector(tt.selector)
+		require.NoError(t, err, tt.selector)
+		require.Equal(t, tt.want, sel.Matches(labels), tt.selector)
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	require.NoError(t, validateLabels(map[string]string{"tier": "prod", "example.com/site": "fra-1", "gpu": ""}))
+	require.NoError(t, validateLabels(nil))
+	for _, labels := range []map[string]string{
+		{"": "prod"},
+		{"-tier": "prod"},
+		{"tier": "pr od"},
+		{"tier": "prod,dev"},
+	} {
+		require.Error(t, validateLabels(labels), labels)
+	}
+}
+
+func TestSetSatelliteLabelsHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/satellites/edge-1/labels", bytes.NewBufferString(`{"labels":{"tier":"pr od"}}`))
+	req = mux.SetURLVars(req, map[string]string{"satellite": "edge-1"})
+	rr := httptest.NewRecorder()
+	server.setSatelliteLabelsHandler(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetGroupSelectorHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/groups/prod/selector", bytes.NewBufferString(`{"selector":""}`))
+	req = mux.SetURLVars(req, map[string]string{"group": "prod"})
+	rr := httptest.NewRecorder()
+	server.setGroupSelectorHandler(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddSatelliteToDynamicGroup(t *testing.T) {
+	server, mock := newMockServer(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery("SELECT .+ FROM satellites WHERE name").
+		WithArgs("edge-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+			AddRow(3, "edge-1", now, now, nil, nil))
+	mock.ExpectQuery("SELECT .+ FROM groups").
+		WithArgs("prod").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "group_name", "registry_url", "projects", "created_at", "updated_at"}).
+			AddRow(7, "prod", "http://harbor:8080", pq.Array([]string{"library"}), now, now))
+	mock.ExpectQuery("SELECT .+ FROM group_selectors").
+		WithArgs(int32(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"group_id", "selector", "updated_by", "updated_at"}).
+			AddRow(7, "tier=prod", "admin", now))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/groups/satellite", bytes.NewBufferString(`{"satellite":"edge-1","group":"prod"}`))
+	rr := httptest.NewRecorder()
+	server.addSatelliteToGroup(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -10,7 +10,10 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/container-registry/harbor-satellite/internal/crypto"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/metrics"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/middleware"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/spiffe"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -233,3 +236,60 @@ func TestSatelliteAuthMiddleware_ExpiredBasicAuth(t *testing.T) {
 	require.Equal(t, http.StatusUnauthorized, rec.Code)
 	require.Contains(t, rec.Body.String(), "Unauthorized")
 }
+
+func TestMetricsAuth(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name, token, header string
+		want                int
+	}{
+		{name: "no token configured", header: "Bearer ", want: http.StatusUnauthorized},
+		{name: "missing token", token: "scrape", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "scrape", header: "Bearer other", want: http.StatusUnauthorized},
+		{name: "valid token", token: "scrape", header: "Bearer scrape", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{metricsToken: tt.token}
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			s.metricsAuth(next).ServeHTTP(rec, req)
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestZTRMetricsSkipRateLimitedRequests(t *testing.T) {
+	server, _ := newMockServerWithAuth(t)
+	server.rateLimiter = middleware.NewRateLimiter(1, time.Minute)
+	handler := server.RegisterRoutes()
+	failures := metrics.ZTR.WithLabelValues("token", metrics.OutcomeFailure)
+	before := testutil.ToFloat64(failures)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/satellites/ztr/bogus", nil))
+	require.NotEqual(t, http.StatusTooManyRequests, rec.Code)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/satellites/ztr/bogus", nil))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	require.Equal(t, before+1, testutil.ToFloat64(failures), "only the first attempt is a ZTR failure")
+}
+
+func TestZTRMetricsSkipUnauthenticatedSPIFFERequests(t *testing.T) {
+	server, _ := newMockServerWithAuth(t)
+	handler := server.RegisterRoutes()
+	failures := metrics.ZTR.WithLabelValues("spiffe", metrics.OutcomeFailure)
+	before := testutil.ToFloat64(failures)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/satellites/spiffe-ztr", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	require.Equal(t, before, testutil.ToFloat64(failures))
+}
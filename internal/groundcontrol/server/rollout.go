@@ -0,0 +1,447 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/env"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/utils"
+)
+
+// Rollout statuses. A rollout is unfinished while in progress or halted; its
+// members keep following the candidate config until it completes or is
+// aborted.
+const (
+	rolloutInProgress = "in_progress"
+	rolloutHalted     = "halted"
+	rolloutCompleted  = "completed"
+	rolloutAborted    = "aborted"
+)
+
+const (
+	rolloutLockID          = 12346
+	defaultRolloutBakeTime = 10 * time.Minute
+	// DefaultRolloutCheckInterval is how often in-progress rollouts are
+	// evaluated against the satellites' heartbeats.
+	DefaultRolloutCheckInterval = 30 * time.Second
+)
+
+// rolloutMemberState is the state of one satellite in a rollout, derived from
+// its latest heartbeat.
+type rolloutMemberState string
+
+const (
+	// memberPending has not reported the rollout's config yet.
+	memberPending rolloutMemberState = "pending"
+	// memberHealthy runs the rollout's config and its last sync succeeded.
+	memberHealthy rolloutMemberState = "healthy"
+	// memberFailing runs the rollout's config but its last sync failed. It
+	// holds the rollout back; the satellite's own probation turns persistent
+	// failures into a rejection.
+	memberFailing rolloutMemberState = "failing"
+	// memberRejected rolled the rollout's config back.
+	memberRejected rolloutMemberState = "rejected"
+)
+
+func rolloutMemberStateOf(m database.ListConfigRolloutSatellitesRow) rolloutMemberState {
+	digest := m.ExpectedConfigDigest
+	switch {
+	case m.RejectedConfigDigest.String == digest:
+		return memberRejected
+	case m.LatestConfigDigest.String != digest:
+		return memberPending
+	case m.LastSyncError.String != "":
+		return memberFailing
+	default:
+		return memberHealthy
+	}
+}
+
+// rolloutSummary counts the satellites of a rollout by state.
+type rolloutSummary struct {
+	Pending  int `json:"pending"`
+	Healthy  int `json:"healthy"`
+	Failing  int `json:"failing"`
+	Rejected int `json:"rejected"`
+}
+
+// ready reports whether every satellite in the rollout is healthy.
+func (s rolloutSummary) ready() bool {
+	return s.Pending == 0 && s.Failing == 0 && s.Rejected == 0
+}
+
+// summarizeRollout counts the members by state and returns the names of those
+// that rejected the config.
+func summarizeRollout(members []database.ListConfigRolloutSatellitesRow) (rolloutSummary, []string) {
+	var summary rolloutSummary
+	var rejected []string
+	for _, m := range members {
+		switch rolloutMemberStateOf(m) {
+		case memberPending:
+			summary.Pending++
+		case memberHealthy:
+			summary.Healthy++
+		case memberFailing:
+			summary.Failing++
+		case memberRejected:
+			summary.Rejected++
+			rejected = append(rejected, m.Name)
+		}
+	}
+	return summary, rejected
+}
+
+// normalizeRolloutWaves validates the cumulative percentages of a rollout's
+// waves and appends the final 100% wave when it is missing.
+func normalizeRolloutWaves(waves []int32) ([]int32, error) {
+	var prev int32
+	for _, pct := range waves {
+		if pct <= prev || pct > 100 {
+			return nil, fmt.Errorf("waves must be increasing percentages between 1 and 100, got %v", waves)
+		}
+		prev = pct
+	}
+	if prev < 100 {
+		waves = append(slices.Clone(waves), 100)
+	}
+	return waves, nil
+}
+
+// waveTarget is the number of satellites out of population that pct percent
+// covers. Any positive percentage covers at least one satellite.
+func waveTarget(population int, pct int32) int {
+	return (population*int(pct) + 99) / 100
+}
+
+// pickRolloutSatellites returns the satellites of population to add so that at
+// least target of them are members. Satellites are taken in an order seeded by
+// the rollout, so later waves extend earlier ones and rollouts of different
+// configs do not always start with the same satellites.
+func pickRolloutSatellites(population []database.ListConfigSatellitesRow, members map[int32]bool, target int, seed string) []database.ListConfigSatellitesRow {
+	var candidates []database.ListConfigSatellitesRow
+	count := 0
+	for _, sat := range population {
+		if members[sat.ID] {
+			count++
+		} else {
+			candidates = append(candidates, sat)
+		}
+	}
+	need := target - count
+	if need <= 0 {
+		return nil
+	}
+	slices.SortFunc(candidates, func(a, b database.ListConfigSatellitesRow) int {
+		return cmp.Or(cmp.Compare(rolloutRank(seed, a.Name), rolloutRank(seed, b.Name)), cmp.Compare(a.Name, b.Name))
+	})
+	return candidates[:min(need, len(candidates))]
+}
+
+func rolloutRank(seed, name string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(seed + "/" + name))
+	return h.Sum64()
+}
+
+// configStateForSatellite returns the config state artifact a satellite
+// follows: the rollout candidate while it is a member of an unfinished rollout
+// of its config, the config's latest state otherwise. A satellite with layers
+// follows its own rendering of that config instead, which is pushed here.
+func configStateForSatellite(ctx context.Context, q *database.Queries, satelliteID int32, satelliteName, configName string) (string, error) {
+	rendered, err := renderSatelliteConfig(ctx, q, satelliteID, satelliteName, configName)
+	if err != nil {
+		return "", err
+	}
+	if len(rendered.Layers) == 0 {
+		if rendered.Rollout != nil {
+			return utils.AssembleConfigStateCandidate(configName, rendered.Rollout.ConfigDigest), nil
+		}
+		return utils.AssembleConfigState(configName), nil
+	}
+
+	digest, err := utils.PushSatelliteConfigState(ctx, rendered.Config, satelliteName, rendered.Revision)
+	if err != nil {
+		return "", fmt.Errorf("push config of satellite %s: %w", satelliteName, err)
+	}
+	if rendered.Rollout != nil {
+		err := q.SetConfigRolloutSatelliteDigest(ctx, database.SetConfigRolloutSatelliteDigestParams{
+			RolloutID:    rendered.Rollout.ID,
+			SatelliteID:  satelliteID,
+			ConfigDigest: sql.NullString{String: digest, Valid: true},
+		})
+		if err != nil {
+			return "", fmt.Errorf("record rollout digest of satellite %s: %w", satelliteName, err)
+		}
+	}
+	return utils.AssembleSatelliteConfigState(satelliteName), nil
+}
+
+// pushSatelliteState pushes a satellite's state artifact with the given group
+// states, pointing it at the config state from configStateForSatellite.
+func pushSatelliteState(ctx context.Context, q *database.Queries, satelliteID int32, satelliteName string, states []string, configName string) error {
+	configState, err := configStateForSatellite(ctx, q, satelliteID, satelliteName, configName)
+	if err != nil {
+		return err
+	}
+	return utils.CreateOrUpdateSatStateArtifact(ctx, satelliteName, states, configState)
+}
+
+// republishSatelliteState rebuilds the state artifact of a satellite whose
+// config changed, through a rollout or its layers.
+func republishSatelliteState(ctx context.Context, q *database.Queries, satelliteID int32, satelliteName, configName string) error {
+	groups, err := q.SatelliteGroupList(ctx, satelliteID)
+	if err != nil {
+		return fmt.Errorf("list groups of satellite %s: %w", satelliteName, err)
+	}
+	states, err := getGroupStates(ctx, groups, q)
+	if err != nil {
+		return err
+	}
+	if err := pushSatelliteState(ctx, q, satelliteID, satelliteName, states, configName); err != nil {
+		return fmt.Errorf("update state artifact of satellite %s: %w", satelliteName, err)
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction and commits it when fn succeeds.
+func (s *Server) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(s.dbQueries.WithTx(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Error: Failed to rollback rollout transaction: %v", rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// promoteRollout moves a rollout to its next wave, or completes it once the
+// last wave has been reached. It returns the updated rollout.
+func (s *Server) promoteRollout(ctx context.Context, ro database.ConfigRollout, cfg database.Config) (database.ConfigRollout, error) {
+	var updated database.ConfigRollout
+	err := s.inTx(ctx, func(q *database.Queries) error {
+		var err error
+		if int(ro.CurrentWave) >= len(ro.Waves) {
+			updated, err = completeRollout(ctx, q, ro, cfg)
+		} else {
+			updated, err = advanceRollout(ctx, q, ro, cfg)
+		}
+		return err
+	})
+	return updated, err
+}
+
+func advanceRollout(ctx context.Context, q *database.Queries, ro database.ConfigRollout, cfg database.Config) (database.ConfigRollout, error) {
+	population, err := q.ListConfigSatellites(ctx, cfg.ID)
+	if err != nil {
+		return database.ConfigRollout{}, fmt.Errorf("list satellites of config: %w", err)
+	}
+	members, err := q.ListConfigRolloutSatellites(ctx, ro.ID)
+	if err != nil {
+		return database.ConfigRollout{}, fmt.Errorf("list rollout satellites: %w", err)
+	}
+	isMember := make(map[int32]bool, len(members))
+	for _, m := range members {
+		isMember[m.SatelliteID] = true
+	}
+
+	wave := ro.CurrentWave + 1
+	added := pickRolloutSatellites(population, isMember, waveTarget(len(population), ro.Waves[ro.CurrentWave]), ro.ConfigDigest)
+	for _, sat := range added {
+		err := q.AddConfigRolloutSatellite(ctx, database.AddConfigRolloutSatelliteParams{
+			RolloutID:   ro.ID,
+			SatelliteID: sat.ID,
+			Wave:        wave,
+		})
+		if err != nil {
+			return database.ConfigRollout{}, fmt.Errorf("add satellite %s to rollout: %w", sat.Name, err)
+		}
+	}
+
+	updated, err := q.AdvanceConfigRollout(ctx, database.AdvanceConfigRolloutParams{ID: ro.ID, CurrentWave: wave})
+	if err != nil {
+		return database.ConfigRollout{}, fmt.Errorf("advance rollout: %w", err)
+	}
+	for _, sat := range added {
+		if err := republishSatelliteState(ctx, q, sat.ID, sat.Name, cfg.ConfigName); err != nil {
+			return database.ConfigRollout{}, err
+		}
+	}
+	return updated, nil
+}
+
+// completeRollout makes the rollout's config the config's latest version and
+// points its members back at it.
+func completeRollout(ctx context.Context, q *database.Queries, ro database.ConfigRollout, cfg database.Config) (database.ConfigRollout, error) {
+	updated, err := q.UpdateConfig(ctx, database.UpdateConfigParams{
+		ConfigName:  cfg.ConfigName,
+		RegistryUrl: env.GC.Harbor.URL,
+		Config:      ro.Config,
+	})
+	if err != nil {
+		return database.ConfigRollout{}, fmt.Errorf("update config: %w", err)
+	}
+	// The candidate was published with the revision this creates, so members
+	// see the same digest again.
+	rev, err := recordConfigRevision(ctx, q, updated, ro.CreatedBy, revisionRollout, fmt.Sprintf("rollout %d", ro.ID))
+	if err != nil {
+		return database.ConfigRollout{}, err
+	}
+	if err := utils.CreateAndPushConfigStateArtifact(ctx, ro.Config, cfg.ConfigName, rev.Revision); err != nil {
+		return database.ConfigRollout{}, fmt.Errorf("push config state artifact: %w", err)
+	}
+	finished, err := finishRollout(ctx, q, ro, cfg, rolloutCompleted, "")
+	if err != nil {
+		return database.ConfigRollout{}, err
+	}
+	// Satellites with layers outside the rollout render the new version too.
+	if err := republishLayeredSatellites(ctx, q, updated); err != nil {
+		return database.ConfigRollout{}, err
+	}
+	return finished, nil
+}
+
+// finishRollout sets a final status and points the rollout's members back at
+// the config's latest state.
+func finishRollout(ctx context.Context, q *database.Queries, ro database.ConfigRollout, cfg database.Config, status, message string) (database.ConfigRollout, error) {
+	updated, err := q.SetConfigRolloutStatus(ctx, database.SetConfigRolloutStatusParams{
+		ID:      ro.ID,
+		Status:  status,
+		Message: message,
+	})
+	if err != nil {
+		return database.ConfigRollout{}, fmt.Errorf("set rollout status: %w", err)
+	}
+	members, err := q.ListConfigRolloutSatellites(ctx, ro.ID)
+	if err != nil {
+		return database.ConfigRollout{}, fmt.Errorf("list rollout satellites: %w", err)
+	}
+	for _, m := range members {
+		if err := republishSatelliteState(ctx, q, m.SatelliteID, m.Name, cfg.ConfigName); err != nil {
+			return database.ConfigRollout{}, err
+		}
+	}
+	return updated, nil
+}
+
+// abortRollout stops a rollout and points its members back at the config's
+// latest state, which still holds the previous version.
+func (s *Server) abortRollout(ctx context.Context, ro database.ConfigRollout, cfg database.Config, message string) (database.ConfigRollout, error) {
+	var updated database.ConfigRollout
+	err := s.inTx(ctx, func(q *database.Queries) error {
+		var err error
+		updated, err = finishRollout(ctx, q, ro, cfg, rolloutAborted, message)
+		return err
+	})
+	return updated, err
+}
+
+// StartRolloutWatcher evaluates in-progress config rollouts every interval. A
+// rollout is halted once more of its satellites reject the config than it
+// tolerates, and promoted to its next wave once every satellite in it is
+// healthy and the wave has baked, unless it waits for manual promotion.
+func (s *Server) StartRolloutWatcher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRolloutCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Config rollout watcher started (interval: %v)", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Config rollout watcher stopped")
+			return
+		case <-ticker.C:
+			s.reconcileRolloutsWithLock(ctx)
+		}
+	}
+}
+
+func (s *Server) reconcileRolloutsWithLock(ctx context.Context) {
+	acquired, err := s.tryAcquireAdvisoryLock(ctx, rolloutLockID)
+	if err != nil {
+		log.Printf("Failed to check advisory lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	defer s.releaseAdvisoryLock(ctx, rolloutLockID)
+
+	rollouts, err := s.dbQueries.ListInProgressConfigRollouts(ctx)
+	if err != nil {
+		log.Printf("Failed to list config rollouts: %v", err)
+		return
+	}
+	for _, ro := range rollouts {
+		if err := s.reconcileRollout(ctx, ro); err != nil {
+			log.Printf("Failed to evaluate config rollout %d: %v", ro.ID, err)
+		}
+	}
+}
+
+func (s *Server) reconcileRollout(ctx context.Context, ro database.ConfigRollout) error {
+	cfg, err := s.dbQueries.GetConfigByID(ctx, ro.ConfigID)
+	if err != nil {
+		return fmt.Errorf("get config: %w", err)
+	}
+	members, err := s.dbQueries.ListConfigRolloutSatellites(ctx, ro.ID)
+	if err != nil {
+		return fmt.Errorf("list rollout satellites: %w", err)
+	}
+
+	summary, rejected := summarizeRollout(members)
+	if summary.Rejected > int(ro.MaxFailures) {
+		message := fmt.Sprintf("%d satellites rejected the config: %s", summary.Rejected, strings.Join(rejected, ", "))
+		updated, err := s.dbQueries.SetConfigRolloutStatus(ctx, database.SetConfigRolloutStatusParams{
+			ID:      ro.ID,
+			Status:  rolloutHalted,
+			Message: message,
+		})
+		if err != nil {
+			return fmt.Errorf("halt rollout: %w", err)
+		}
+		log.Printf("Config rollout %d of %s halted: %s", ro.ID, cfg.ConfigName, message)
+		s.audit.Log(systemAuditEvent(rolloutAuditEvent(cfg, updated, "halt")))
+		return nil
+	}
+
+	if !ro.AutoPromote || !summary.ready() {
+		return nil
+	}
+	baked, err := s.dbQueries.ConfigRolloutBaked(ctx, ro.ID)
+	if err != nil {
+		return fmt.Errorf("check bake time: %w", err)
+	}
+	if !baked {
+		return nil
+	}
+
+	updated, err := s.promoteRollout(ctx, ro, cfg)
+	if err != nil {
+		return fmt.Errorf("promote rollout: %w", err)
+	}
+	log.Printf("Config rollout %d of %s promoted to wave %d (%s)", ro.ID, cfg.ConfigName, updated.CurrentWave, updated.Status)
+	for _, e := range promotionAuditEvents(cfg, updated) {
+		s.audit.Log(systemAuditEvent(e))
+	}
+	return nil
+}
@@ -0,0 +1,531 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	auditlog "github.com/container-registry/harbor-satellite/internal/groundcontrol/logger"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/gorilla/mux"
+)
+
+// ConfigRolloutRequest starts a staged rollout of a config change. The change
+// first reaches the canary satellites, then each wave in turn.
+//
+// swagger:model ConfigRolloutRequest
+type configRolloutRequest struct {
+	// Config is merged into the current config as a JSON merge patch, like
+	// PATCH /api/configs/{config}.
+	// required: true
+	Config config.Config `json:"config"`
+	// Canary selects the satellites of the first wave. Selectors add up.
+	// required: true
+	Canary rolloutCanary `json:"canary"`
+	// Waves are increasing percentages of the config's satellites reached by
+	// each promotion after the canary. A final 100% wave is implied.
+	Waves []int32 `json:"waves,omitempty"`
+	// AutoPromote promotes to the next wave once every satellite in the
+	// rollout is healthy and the bake time has passed. Defaults to true.
+	AutoPromote *bool `json:"auto_promote,omitempty"`
+	// BakeTime is how long a wave must run before it is promoted, as a Go
+	// duration. Defaults to 10m.
+	BakeTime string `json:"bake_time,omitempty"`
+	// MaxFailures is how many satellites may reject the config before the
+	// rollout halts.
+	MaxFailures int32 `json:"max_failures,omitempty"`
+}
+
+// rolloutCanary selects the satellites of a rollout's first wave among those
+// using the config.
+type rolloutCanary struct {
+	Satellites []string `json:"satellites,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	// Selector is a label selector such as "tier=edge,site in (fra,ber)".
+	Selector   string `json:"selector,omitempty"`
+	Percentage int32  `json:"percentage,omitempty"`
+}
+
+// ConfigRolloutStatus describes a config rollout. When a single rollout is
+// fetched it also lists the state of every satellite in it.
+//
+// swagger:model ConfigRolloutStatus
+type configRolloutStatus struct {
+	ID            int32                    `json:"id"`
+	ConfigName    string                   `json:"config_name"`
+	ConfigDigest  string                   `json:"config_digest"`
+	Status        string                   `json:"status"`
+	Message       string                   `json:"message,omitempty"`
+	Wave          int32                    `json:"wave"`
+	Waves         []int32                  `json:"waves"`
+	AutoPromote   bool                     `json:"auto_promote"`
+	BakeTime      string                   `json:"bake_time"`
+	MaxFailures   int32                    `json:"max_failures"`
+	CreatedBy     string                   `json:"created_by"`
+	WaveStartedAt time.Time                `json:"wave_started_at"`
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+	Summary       *rolloutSummary          `json:"summary,omitempty"`
+	Satellites    []rolloutSatelliteStatus `json:"satellites,omitempty"`
+}
+
+// rolloutSatelliteStatus is the state of one satellite in a rollout, taken
+// from its latest heartbeat.
+type rolloutSatelliteStatus struct {
+	Name          string             `json:"name"`
+	Wave          int32              `json:"wave"`
+	State         rolloutMemberState `json:"state"`
+	ConfigDigest  string             `json:"config_digest,omitempty"`
+	LastSyncError string             `json:"last_sync_error,omitempty"`
+	ReportedAt    *time.Time         `json:"reported_at,omitempty"`
+}
+
+func newConfigRolloutStatus(configName string, ro database.ConfigRollout) configRolloutStatus {
+	return configRolloutStatus{
+		ID:            ro.ID,
+		ConfigName:    configName,
+		ConfigDigest:  ro.ConfigDigest,
+		Status:        ro.Status,
+		Message:       ro.Message,
+		Wave:          ro.CurrentWave,
+		Waves:         ro.Waves,
+		AutoPromote:   ro.AutoPromote,
+		BakeTime:      (time.Duration(ro.BakeSeconds) * time.Second).String(),
+		MaxFailures:   ro.MaxFailures,
+		CreatedBy:     ro.CreatedBy,
+		WaveStartedAt: ro.WaveStartedAt,
+		CreatedAt:     ro.CreatedAt,
+		UpdatedAt:     ro.UpdatedAt,
+	}
+}
+
+// withMembers adds the state of every satellite in the rollout.
+func (st configRolloutStatus) withMembers(members []database.ListConfigRolloutSatellitesRow) configRolloutStatus {
+	summary, _ := summarizeRollout(members)
+	st.Summary = &summary
+	st.Satellites = make([]rolloutSatelliteStatus, 0, len(members))
+	for _, m := range members {
+		sat := rolloutSatelliteStatus{
+			Name:          m.Name,
+			Wave:          m.Wave,
+			State:         rolloutMemberStateOf(m),
+			ConfigDigest:  m.LatestConfigDigest.String,
+			LastSyncError: m.LastSyncError.String,
+		}
+		if m.ReportedAt.Valid {
+			reportedAt := m.ReportedAt.Time
+			sat.ReportedAt = &reportedAt
+		}
+		st.Satellites = append(st.Satellites, sat)
+	}
+	return st
+}
+
+// rolloutAuditEvent describes an action on a rollout. The caller sets the
+// actor.
+func rolloutAuditEvent(cfg database.Config, ro database.ConfigRollout, action string) auditlog.AuditEvent {
+	e := auditlog.AuditEvent{
+		Operation:    auditlog.OpUpdate,
+		ResourceType: auditlog.ResConfigRollout,
+		Outcome:      auditlog.OutcomeSuccess,
+		Resource:     cfg.ConfigName,
+		Details: map[string]any{
+			"rollout_id":    ro.ID,
+			"action":        action,
+			"wave":          ro.CurrentWave,
+			"status":        ro.Status,
+			"config_digest": ro.ConfigDigest,
+		},
+	}
+	if ro.Message != "" {
+		e.Details["message"] = ro.Message
+	}
+	switch action {
+	case "start":
+		e.Operation = auditlog.OpCreate
+	case "halt":
+		e.Outcome = auditlog.OutcomeFailure
+		e.Reason = auditlog.ReasonRolloutHalted
+	}
+	return e
+}
+
+// promotionAuditEvents describes a promotion. Completing a rollout also
+// changes the config, which is recorded like an update through the API.
+func promotionAuditEvents(before database.Config, ro database.ConfigRollout) []auditlog.AuditEvent {
+	events := []auditlog.AuditEvent{rolloutAuditEvent(before, ro, "promote")}
+	if ro.Status == rolloutCompleted {
+		e := auditlog.AuditEvent{
+			Operation:    auditlog.OpUpdate,
+			ResourceType: auditlog.ResConfig,
+			Outcome:      auditlog.OutcomeSuccess,
+			Resource:     before.ConfigName,
+			Details:      map[string]any{"rollout_id": ro.ID},
+		}
+		if changed := diffConfigForAudit(before.Config, ro.Config); len(changed) > 0 {
+			e.Details["changed"] = changed
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func systemAuditEvent(e auditlog.AuditEvent) auditlog.AuditEvent {
+	e.Actor = "ground-control"
+	e.ActorType = auditlog.ActorSystem
+	return e
+}
+
+func (s *Server) userAuditEvent(r *http.Request, e auditlog.AuditEvent) {
+	e.Actor = actorFromContext(r.Context())
+	e.ActorType = auditlog.ActorUser
+	s.auditEvent(r, e)
+}
+
+// loadConfigRollout resolves the config and rollout named in the request path.
+func (s *Server) loadConfigRollout(r *http.Request) (database.Config, database.ConfigRollout, error) {
+	cfg, err := s.loadConfig(r)
+	if err != nil {
+		return database.Config{}, database.ConfigRollout{}, err
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["rollout"], 10, 32)
+	if err != nil {
+		return database.Config{}, database.ConfigRollout{}, &AppError{Message: "error: invalid rollout id", Code: http.StatusBadRequest}
+	}
+	ro, err := s.dbQueries.GetConfigRollout(r.Context(), database.GetConfigRolloutParams{ID: int32(id), ConfigID: cfg.ID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Config{}, database.ConfigRollout{}, &AppError{Message: "error: rollout not found", Code: http.StatusNotFound}
+		}
+		log.Printf("Error: Failed to get rollout: %v", err)
+		return database.Config{}, database.ConfigRollout{}, &AppError{Message: "error: failed to get rollout", Code: http.StatusInternalServerError}
+	}
+	return cfg, ro, nil
+}
+
+// writeConfigRolloutStatus responds with the rollout and its satellites.
+func (s *Server) writeConfigRolloutStatus(w http.ResponseWriter, r *http.Request, code int, cfg database.Config, ro database.ConfigRollout) {
+	members, err := s.dbQueries.ListConfigRolloutSatellites(r.Context(), ro.ID)
+	if err != nil {
+		log.Printf("Error: Failed to list rollout satellites: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to list rollout satellites", Code: http.StatusInternalServerError})
+		return
+	}
+	WriteJSONResponse(w, code, newConfigRolloutStatus(cfg.ConfigName, ro).withMembers(members))
+}
+
+// selectCanary resolves the canary selectors against the satellites using the
+// config. Percentages are applied later, once the rollout's digest is known.
+func (s *Server) selectCanary(r *http.Request, canary rolloutCanary, sel labelSelector, cfg database.Config, population []database.ListConfigSatellitesRow) (map[int32]bool, error) {
+	byName := make(map[string]int32, len(population))
+	for _, sat := range population {
+		byName[sat.Name] = sat.ID
+	}
+
+	selected := make(map[int32]bool)
+	for _, name := range canary.Satellites {
+		id, ok := byName[name]
+		if !ok {
+			return nil, &AppError{
+				Message: fmt.Sprintf("error: satellite %q does not use config %q", name, cfg.ConfigName),
+				Code:    http.StatusBadRequest,
+			}
+		}
+		selected[id] = true
+	}
+
+	if canary.Group != "" {
+		if _, err := s.dbQueries.GetGroupByName(r.Context(), canary.Group); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, &AppError{Message: fmt.Sprintf("error: group %q not found", canary.Group), Code: http.StatusBadRequest}
+			}
+			log.Printf("Error: Failed to get group: %v", err)
+			return nil, &AppError{Message: "error: failed to get group", Code: http.StatusInternalServerError}
+		}
+		sats, err := s.dbQueries.GetSatellitesByGroupName(r.Context(), canary.Group)
+		if err != nil {
+			log.Printf("Error: Failed to list group satellites: %v", err)
+			return nil, &AppError{Message: "error: failed to list group satellites", Code: http.StatusInternalServerError}
+		}
+		for _, sat := range sats {
+			if id, ok := byName[sat.Name]; ok {
+				selected[id] = true
+			}
+		}
+	}
+
+	if sel != nil {
+		labels, err := allSatelliteLabels(r.Context(), s.dbQueries)
+		if err != nil {
+			log.Printf("Error: Failed to list satellite labels: %v", err)
+			return nil, &AppError{Message: "error: failed to list satellite labels", Code: http.StatusInternalServerError}
+		}
+		for _, sat := range population {
+			if sel.Matches(labels[sat.ID]) {
+				selected[sat.ID] = true
+			}
+		}
+	}
+	return selected, nil
+}
+
+func (s *Server) createConfigRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	configName := mux.Vars(r)["config"]
+	if !utils.IsValidName(configName) {
+		HandleAppError(w, &AppError{Message: "invalid or empty config_name", Code: http.StatusBadRequest})
+		return
+	}
+
+	var req configRolloutRequest
+	if err := DecodeRequestBody(r, &req); err != nil {
+		log.Println("Error decoding request body: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	waves, err := normalizeRolloutWaves(req.Waves)
+	if err != nil {
+		HandleAppError(w, &AppError{Message: "error: " + err.Error(), Code: http.StatusBadRequest})
+		return
+	}
+	bake := defaultRolloutBakeTime
+	if req.BakeTime != "" {
+		bake, err = time.ParseDuration(req.BakeTime)
+		if err != nil || bake < 0 {
+			HandleAppError(w, &AppError{Message: "error: invalid bake_time", Code: http.StatusBadRequest})
+			return
+		}
+	}
+	if req.MaxFailures < 0 {
+		HandleAppError(w, &AppError{Message: "error: max_failures must not be negative", Code: http.StatusBadRequest})
+		return
+	}
+	if req.Canary.Percentage < 0 || req.Canary.Percentage > 100 {
+		HandleAppError(w, &AppError{Message: "error: canary percentage must be between 0 and 100", Code: http.StatusBadRequest})
+		return
+	}
+	if len(req.Canary.Satellites) == 0 && req.Canary.Group == "" && req.Canary.Selector == "" && req.Canary.Percentage == 0 {
+		HandleAppError(w, &AppError{Message: "error: canary must select satellites, a group, a selector or a percentage", Code: http.StatusBadRequest})
+		return
+	}
+	var sel labelSelector
+	if req.Canary.Selector != "" {
+		if sel, err = parseLabelSelector(req.Canary.Selector); err != nil {
+			HandleAppError(w, &AppError{Message: fmt.Sprintf("error: canary selector: %v", err), Code: http.StatusBadRequest})
+			return
+		}
+	}
+	autoPromote := req.AutoPromote == nil || *req.AutoPromote
+
+	cfg, err := s.dbQueries.GetConfigByName(r.Context(), configName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			HandleAppError(w, &AppError{Message: "error: config not found", Code: http.StatusNotFound})
+			return
+		}
+		log.Printf("Error: Failed to get config: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to get config", Code: http.StatusInternalServerError})
+		return
+	}
+
+	if _, err := s.dbQueries.GetUnfinishedConfigRollout(r.Context(), cfg.ID); err == nil {
+		HandleAppError(w, &AppError{Message: "error: config already has an unfinished rollout", Code: http.StatusConflict})
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error: Failed to check config rollouts: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to check config rollouts", Code: http.StatusInternalServerError})
+		return
+	}
+
+	patchJSON, err := json.Marshal(req.Config)
+	if err != nil {
+		log.Println("Could not marshal JSON: ", err)
+		HandleAppError(w, err)
+		return
+	}
+	candidate, err := jsonpatch.MergePatch(cfg.Config, patchJSON)
+	if err != nil {
+		log.Printf("error: unable to apply patch %v", err)
+		HandleAppError(w, &AppError{Message: "error: unable to apply patch", Code: http.StatusBadRequest})
+		return
+	}
+
+	population, err := s.dbQueries.ListConfigSatellites(r.Context(), cfg.ID)
+	if err != nil {
+		log.Printf("Error: Failed to list config satellites: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to list config satellites", Code: http.StatusInternalServerError})
+		return
+	}
+	if len(population) == 0 {
+		HandleAppError(w, &AppError{Message: "error: config is not used by any satellite", Code: http.StatusBadRequest})
+		return
+	}
+	canary, err := s.selectCanary(r, req.Canary, sel, cfg, population)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	// The satellites picked by percentage depend on the candidate's digest,
+	// but whether any are picked does not, so the request is checked before
+	// the candidate is pushed.
+	canaryTarget := waveTarget(len(population), req.Canary.Percentage)
+	if len(canary) == 0 && canaryTarget == 0 {
+		HandleAppError(w, &AppError{Message: "error: canary selects no satellites", Code: http.StatusBadRequest})
+		return
+	}
+
+	if err := ensureSatelliteProjectExists(r.Context()); err != nil {
+		log.Println("Error while ensuring project satellite: ", err)
+		HandleAppError(w, err)
+		return
+	}
+	// Updates and rollbacks are refused while the rollout is unfinished, so
+	// the candidate becomes the next revision when the rollout completes.
+	revision, err := nextConfigRevision(r.Context(), s.dbQueries, cfg.ID)
+	if err != nil {
+		log.Printf("Error: Failed to get config revision: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+	digest, err := utils.PushConfigStateCandidate(r.Context(), candidate, configName, revision)
+	if err != nil {
+		log.Println("Error while pushing rollout config artifact: ", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	for _, sat := range pickRolloutSatellites(population, canary, canaryTarget, digest) {
+		canary[sat.ID] = true
+	}
+
+	var ro database.ConfigRollout
+	err = s.inTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		ro, err = q.CreateConfigRollout(r.Context(), database.CreateConfigRolloutParams{
+			ConfigID:     cfg.ID,
+			Config:       candidate,
+			ConfigDigest: digest,
+			Waves:        waves,
+			AutoPromote:  autoPromote,
+			BakeSeconds:  int32(bake / time.Second),
+			MaxFailures:  req.MaxFailures,
+			CreatedBy:    actorFromContext(r.Context()),
+		})
+		if err != nil {
+			return fmt.Errorf("create rollout: %w", err)
+		}
+		for _, sat := range population {
+			if !canary[sat.ID] {
+				continue
+			}
+			err := q.AddConfigRolloutSatellite(r.Context(), database.AddConfigRolloutSatelliteParams{
+				RolloutID:   ro.ID,
+				SatelliteID: sat.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("add satellite %s to rollout: %w", sat.Name, err)
+			}
+			if err := republishSatelliteState(r.Context(), q, sat.ID, sat.Name, configName); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error: Failed to start config rollout: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, rolloutAuditEvent(cfg, ro, "start"))
+	s.writeConfigRolloutStatus(w, r, http.StatusCreated, cfg, ro)
+}
+
+func (s *Server) listConfigRolloutsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := s.loadConfig(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
+	rollouts, err := s.dbQueries.ListConfigRollouts(r.Context(), cfg.ID)
+	if err != nil {
+		log.Printf("Error: Failed to list config rollouts: %v", err)
+		HandleAppError(w, &AppError{Message: "error: failed to list config rollouts", Code: http.StatusInternalServerError})
+		return
+	}
+	result := make([]configRolloutStatus, 0, len(rollouts))
+	for _, ro := range rollouts {
+		result = append(result, newConfigRolloutStatus(cfg.ConfigName, ro))
+	}
+	WriteJSONResponse(w, http.StatusOK, result)
+}
+
+func (s *Server) getConfigRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, ro, err := s.loadConfigRollout(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	s.writeConfigRolloutStatus(w, r, http.StatusOK, cfg, ro)
+}
+
+// promoteConfigRolloutHandler moves a rollout to its next wave regardless of
+// the health of its satellites, or completes it from its last wave. A halted
+// rollout resumes.
+func (s *Server) promoteConfigRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, ro, err := s.loadConfigRollout(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	if ro.Status != rolloutInProgress && ro.Status != rolloutHalted {
+		HandleAppError(w, &AppError{Message: fmt.Sprintf("error: rollout is %s", ro.Status), Code: http.StatusConflict})
+		return
+	}
+
+	updated, err := s.promoteRollout(r.Context(), ro, cfg)
+	if err != nil {
+		log.Printf("Error: Failed to promote config rollout: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	for _, e := range promotionAuditEvents(cfg, updated) {
+		s.userAuditEvent(r, e)
+	}
+	s.writeConfigRolloutStatus(w, r, http.StatusOK, cfg, updated)
+}
+
+// abortConfigRolloutHandler stops a rollout and returns its satellites to the
+// config's current version.
+func (s *Server) abortConfigRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, ro, err := s.loadConfigRollout(r)
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+	if ro.Status != rolloutInProgress && ro.Status != rolloutHalted {
+		HandleAppError(w, &AppError{Message: fmt.Sprintf("error: rollout is %s", ro.Status), Code: http.StatusConflict})
+		return
+	}
+
+	updated, err := s.abortRollout(r.Context(), ro, cfg, "aborted by "+actorFromContext(r.Context()))
+	if err != nil {
+		log.Printf("Error: Failed to abort config rollout: %v", err)
+		HandleAppError(w, err)
+		return
+	}
+
+	s.userAuditEvent(r, rolloutAuditEvent(cfg, updated, "abort"))
+	s.writeConfigRolloutStatus(w, r, http.StatusOK, cfg, updated)
+}
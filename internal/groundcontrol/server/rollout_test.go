@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+var configRolloutColumns = []string{
+	"id", "config_id", "config", "config_digest", "status", "waves", "current_wave", "auto_promote",
+	"bake_seconds", "max_failures", "message", "created_by", "wave_started_at", "created_at", "updated_at",
+}
+
+func expectConfigByName(mock sqlmock.Sqlmock, name string) {
+	now := time.Now().UTC().Truncate(time.Second)
+	mock.ExpectQuery("SELECT .+ FROM configs WHERE config_name").
+		WithArgs(name).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "config_name", "registry_url", "config", "created_at", "updated_at"}).
+			AddRow(1, name, "http://harbor:8080", json.RawMessage(`{"app_config":{}}`), now, now))
+}
+
+func configRolloutRow(status string) *sqlmock.Rows {
+	now := time.Now().UTC().Truncate(time.Second)
+	return sqlmock.NewRows(configRolloutColumns).
+		AddRow(7, 1, json.RawMessage(`{"app_config":{}}`), "sha256:new", status, "{50,100}", 1, true,
+			600, 0, "", "admin", now, now, now)
+}
+
+func TestNormalizeRolloutWaves(t *testing.T) {
+	tests := []struct {
+		name    string
+		waves   []int32
+		want    []int32
+		wantErr bool
+	}{
+		{name: "empty means one final wave", waves: nil, want: []int32{100}},
+		{name: "appends final wave", waves: []int32{10, 50}, want: []int32{10, 50, 100}},
+		{name: "keeps explicit final wave", waves: []int32{25, 100}, want: []int32{25, 100}},
+		{name: "rejects decreasing", waves: []int32{50, 20}, wantErr: true},
+		{name: "rejects duplicates", waves: []int32{50, 50}, wantErr: true},
+		{name: "rejects zero", waves: []int32{0, 50}, wantErr: true},
+		{name: "rejects over 100", waves: []int32{150}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeRolloutWaves(tt.waves)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWaveTarget(t *testing.T) {
+	require.Equal(t, 0, waveTarget(10, 0))
+	require.Equal(t, 1, waveTarget(10, 1))
+	require.Equal(t, 5, waveTarget(10, 50))
+	require.Equal(t, 4, waveTarget(7, 50))
+	require.Equal(t, 7, waveTarget(7, 100))
+}
+
+func TestPickRolloutSatellites(t *testing.T) {
+	var population []database.ListConfigSatellitesRow
+	for i := range 10 {
+		population = append(population, database.ListConfigSatellitesRow{ID: int32(i + 1), Name: fmt.Sprintf("edge-%02d", i)})
+	}
+
+	first := pickRolloutSatellites(population, map[int32]bool{}, 3, "sha256:abc")
+	require.Len(t, first, 3)
+	require.Equal(t, first, pickRolloutSatellites(population, map[int32]bool{}, 3, "sha256:abc"), "selection must be deterministic")
+
+	members := map[int32]bool{}
+	for _, sat := range first {
+		members[sat.ID] = true
+	}
+	next := pickRolloutSatellites(population, members, 6, "sha256:abc")
+	require.Len(t, next, 3, "existing members count towards the target")
+	for _, sat := range next {
+		require.False(t, members[sat.ID], "a wave must only add new satellites")
+	}
+
+	// A wider wave picked from scratch extends the narrower one.
+	wide := pickRolloutSatellites(population, map[int32]bool{}, 6, "sha256:abc")
+	require.Equal(t, first, wide[:3])
+
+	require.Empty(t, pickRolloutSatellites(population, members, 2, "sha256:abc"))
+	require.Len(t, pickRolloutSatellites(population, members, 100, "sha256:abc"), 7)
+}
+
+func TestSummarizeRollout(t *testing.T) {
+	str := func(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
+	members := []database.ListConfigRolloutSatellitesRow{
+		{Name: "pending", ExpectedConfigDigest: "sha256:new", LatestConfigDigest: str("sha256:old")},
+		{Name: "unreported", ExpectedConfigDigest: "sha256:new"},
+		{Name: "healthy", ExpectedConfigDigest: "sha256:new", LatestConfigDigest: str("sha256:new")},
+		{Name: "failing", ExpectedConfigDigest: "sha256:new", LatestConfigDigest: str("sha256:new"), LastSyncError: str("pull failed")},
+		{Name: "rejected", ExpectedConfigDigest: "sha256:new", LatestConfigDigest: str("sha256:old"), RejectedConfigDigest: str("sha256:new")},
+		// A satellite with layers reports the digest of its own rendering.
+		{Name: "layered", ExpectedConfigDigest: "sha256:layered", LatestConfigDigest: str("sha256:layered")},
+	}
+
+	summary, rejected := summarizeRollout(members)
+	require.Equal(t, rolloutSummary{Pending: 2, Healthy: 2, Failing: 1, Rejected: 1}, summary)
+	require.Equal(t, []string{"rejected"}, rejected)
+	require.False(t, summary.ready())
+
+	summary, _ = summarizeRollout(members[2:3])
+	require.True(t, summary.ready())
+}
+
+func TestCreateConfigRolloutHandler(t *testing.T) {
+	t.Run("config not found returns 404", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		mock.ExpectQuery("SELECT .+ FROM configs WHERE config_name").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		body := `{"config":{},"canary":{"percentage":10}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/configs/missing/rollouts", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"config": "missing"})
+		rr := httptest.NewRecorder()
+		server.createConfigRolloutHandler(rr, req)
+
+		require.Equal(t, http.StatusNotFound, rr.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unfinished rollout returns 409", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectConfigByName(mock, "edge")
+		mock.ExpectQuery("SELECT .+ FROM config_rollouts").
+			WithArgs(int32(1)).
+			WillReturnRows(configRolloutRow(rolloutInProgress))
+
+		body := `{"config":{},"canary":{"percentage":10}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/configs/edge/rollouts", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"config": "edge"})
+		rr := httptest.NewRecorder()
+		server.createConfigRolloutHandler(rr, req)
+
+		require.Equal(t, http.StatusConflict, rr.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("satellite outside the config returns 400", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectConfigByName(mock, "edge")
+		mock.ExpectQuery("SELECT .+ FROM config_rollouts").
+			WithArgs(int32(1)).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT .+ FROM satellites .+ satellite_configs").
+			WithArgs(int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "edge-1"))
+
+		body := `{"config":{},"canary":{"satellites":["other"]}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/configs/edge/rollouts", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"config": "edge"})
+		rr := httptest.NewRecorder()
+		server.createConfigRolloutHandler(rr, req)
+
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Contains(t, rr.Body.String(), "does not use config")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("canary selector matching no satellite returns 400", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		expectConfigByName(mock, "edge")
+		mock.ExpectQuery("SELECT .+ FROM config_rollouts").
+			WithArgs(int32(1)).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT .+ FROM satellites .+ satellite_configs").
+			WithArgs(int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "edge-1").AddRow(2, "edge-2"))
+		mock.ExpectQuery("SELECT .+ FROM satellite_labels").
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "key", "value"}).
+				AddRow(1, "tier", "edge").
+				AddRow(2, "tier", "edge"))
+
+		body := `{"config":{},"canary":{"selector":"tier=core"}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/configs/edge/rollouts", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"config": "edge"})
+		rr := httptest.NewRecorder()
+		server.createConfigRolloutHandler(rr, req)
+
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Contains(t, rr.Body.String(), "canary selects no satellites")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid requests return 400", func(t *testing.T) {
+		for _, body := range []string{
+			`{"config":{},"canary":{}}`,
+			`{"config":{},"canary":{"percentage":150}}`,
+			`{"config":{},"canary":{"selector":"tier in (prod"}}`,
+			`{"config":{},"canary":{"percentage":10},"waves":[50,20]}`,
+			`{"config":{},"canary":{"percentage":10},"bake_time":"soon"}`,
+			`{"config":{},"canary":{"percentage":10},"max_failures":-1}`,
+		} {
+			server, mock := newMockServer(t)
+			req := httptest.NewRequest(http.MethodPost, "/api/configs/edge/rollouts", bytes.NewBufferString(body))
+			req = mux.SetURLVars(req, map[string]string{"config": "edge"})
+			rr := httptest.NewRecorder()
+			server.createConfigRolloutHandler(rr, req)
+
+			require.Equal(t, http.StatusBadRequest, rr.Code, body)
+			require.NoError(t, mock.ExpectationsWereMet())
+		}
+	})
+}
+
+func TestGetConfigRolloutHandler(t *testing.T) {
+	server, mock := newMockServer(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	expectConfigByName(mock, "edge")
+	mock.ExpectQuery("SELECT .+ FROM config_rollouts").
+		WithArgs(int32(7), int32(1)).
+		WillReturnRows(configRolloutRow(rolloutInProgress))
+	mock.ExpectQuery("SELECT .+ FROM config_rollout_satellites").
+		WithArgs(int32(7)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"satellite_id", "name", "wave", "added_at", "expected_config_digest",
+			"latest_config_digest", "rejected_config_digest", "last_sync_error", "reported_at",
+		}).
+			AddRow(1, "edge-1", 0, now, "sha256:new", "sha256:new", nil, nil, now).
+			AddRow(2, "edge-2", 1, now, "sha256:new", "sha256:old", nil, nil, now))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/configs/edge/rollouts/7", nil)
+	req = mux.SetURLVars(req, map[string]string{"config": "edge", "rollout": "7"})
+	rr := httptest.NewRecorder()
+	server.getConfigRolloutHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got configRolloutStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Equal(t, []int32{50, 100}, got.Waves)
+	require.Equal(t, "10m0s", got.BakeTime)
+	require.Equal(t, &rolloutSummary{Pending: 1, Healthy: 1}, got.Summary)
+	require.Len(t, got.Satellites, 2)
+	require.Equal(t, memberHealthy, got.Satellites[0].State)
+	require.Equal(t, memberPending, got.Satellites[1].State)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPromoteConfigRolloutHandler_Finished(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	expectConfigByName(mock, "edge")
+	mock.ExpectQuery("SELECT .+ FROM config_rollouts").
+		WithArgs(int32(7), int32(1)).
+		WillReturnRows(configRolloutRow(rolloutCompleted))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/configs/edge/rollouts/7/promote", nil)
+	req = mux.SetURLVars(req, map[string]string{"config": "edge", "rollout": "7"})
+	rr := httptest.NewRecorder()
+	server.promoteConfigRolloutHandler(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateConfigHandler_RolloutInProgress(t *testing.T) {
+	server, mock := newMockServer(t)
+
+	mock.ExpectBegin()
+	expectConfigByName(mock, "edge")
+	mock.ExpectQuery("SELECT .+ FROM config_rollouts").
+		WithArgs(int32(1)).
+		WillReturnRows(configRolloutRow(rolloutHalted))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/configs/edge", bytes.NewBufferString(`{}`))
+	req = mux.SetURLVars(req, map[string]string{"config": "edge"})
+	rr := httptest.NewRecorder()
+	server.updateConfigHandler(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
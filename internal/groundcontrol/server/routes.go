@@ -1,10 +1,13 @@
 package server
 
 import (
+	"crypto/subtle"
 	"net/http"
 
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/metrics"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/middleware"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/spiffe"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/gorilla/mux"
 )
 
@@ -14,11 +17,18 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Attach a request ID to every request (all routes, including /login and
 	// /ztr) so the audit events from one request share a correlation ID.
 	r.Use(s.RequestIDMiddleware)
+	r.Use(tracing.Middleware("ground-control"))
+	r.Use(metrics.Middleware)
 
 	// Public routes
 	r.HandleFunc("/ping", s.Ping).Methods("GET")
 	r.HandleFunc("/health", s.healthHandler).Methods("GET")
 
+	// Prometheus metrics (bearer-token protected)
+	if s.metricsRegistry != nil {
+		r.Handle("/metrics", s.metricsAuth(metrics.Handler(s.metricsRegistry))).Methods("GET")
+	}
+
 	// Login (rate limited, public)
 	loginRouter := r.PathPrefix("/login").Subrouter()
 	loginRouter.Use(middleware.RateLimitMiddleware(s.rateLimiter))
@@ -44,6 +54,12 @@ func (s *Server) RegisterRoutes() http.Handler {
 	api.HandleFunc("/groups/sync", s.groupsSyncHandler).Methods("POST")
 	api.HandleFunc("/groups/{group}", s.getGroupHandler).Methods("GET")
 	api.HandleFunc("/groups/{group}/satellites", s.groupSatelliteHandler).Methods("GET")
+	api.HandleFunc("/groups/{group}/config-layer", s.getGroupConfigLayerHandler).Methods("GET")
+	api.HandleFunc("/groups/{group}/config-layer", s.setGroupConfigLayerHandler).Methods("PUT")
+	api.HandleFunc("/groups/{group}/config-layer", s.deleteGroupConfigLayerHandler).Methods("DELETE")
+	api.HandleFunc("/groups/{group}/selector", s.getGroupSelectorHandler).Methods("GET")
+	api.HandleFunc("/groups/{group}/selector", s.setGroupSelectorHandler).Methods("PUT")
+	api.HandleFunc("/groups/{group}/selector", s.deleteGroupSelectorHandler).Methods("DELETE")
 	api.HandleFunc("/groups/satellite", s.addSatelliteToGroup).Methods("POST")
 	api.HandleFunc("/groups/satellite", s.removeSatelliteFromGroup).Methods("DELETE")
 	api.HandleFunc("/groups/{group}", s.RequireRole(roleSystemAdmin, s.deleteGroupHandler)).Methods("DELETE")
@@ -55,6 +71,15 @@ func (s *Server) RegisterRoutes() http.Handler {
 	api.HandleFunc("/configs/{config}", s.getConfigHandler).Methods("GET")
 	api.HandleFunc("/configs/{config}", s.deleteConfigHandler).Methods("DELETE")
 	api.HandleFunc("/configs/satellite", s.setSatelliteConfig).Methods("POST")
+	api.HandleFunc("/configs/{config}/revisions", s.listConfigRevisionsHandler).Methods("GET")
+	api.HandleFunc("/configs/{config}/revisions/{revision}", s.getConfigRevisionHandler).Methods("GET")
+	api.HandleFunc("/configs/{config}/revisions/{revision}/rollback", s.rollbackConfigHandler).Methods("POST")
+	api.HandleFunc("/configs/{config}/diff", s.diffConfigRevisionsHandler).Methods("GET")
+	api.HandleFunc("/configs/{config}/rollouts", s.listConfigRolloutsHandler).Methods("GET")
+	api.HandleFunc("/configs/{config}/rollouts", s.createConfigRolloutHandler).Methods("POST")
+	api.HandleFunc("/configs/{config}/rollouts/{rollout}", s.getConfigRolloutHandler).Methods("GET")
+	api.HandleFunc("/configs/{config}/rollouts/{rollout}/promote", s.promoteConfigRolloutHandler).Methods("POST")
+	api.HandleFunc("/configs/{config}/rollouts/{rollout}/abort", s.abortConfigRolloutHandler).Methods("POST")
 
 	// Satellite management (human only)
 	api.HandleFunc("/satellites", s.listSatelliteHandler).Methods("GET")
@@ -65,6 +90,12 @@ func (s *Server) RegisterRoutes() http.Handler {
 	api.HandleFunc("/satellites/{satellite}", s.DeleteSatelliteByName).Methods("DELETE")
 	api.HandleFunc("/satellites/{satellite}/status", s.getSatelliteStatusHandler).Methods("GET")
 	api.HandleFunc("/satellites/{satellite}/images", s.getCachedImagesHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/config", s.getEffectiveConfigHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/config-override", s.getSatelliteConfigOverrideHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/config-override", s.setSatelliteConfigOverrideHandler).Methods("PUT")
+	api.HandleFunc("/satellites/{satellite}/config-override", s.deleteSatelliteConfigOverrideHandler).Methods("DELETE")
+	api.HandleFunc("/satellites/{satellite}/labels", s.getSatelliteLabelsHandler).Methods("GET")
+	api.HandleFunc("/satellites/{satellite}/labels", s.setSatelliteLabelsHandler).Methods("PUT")
 
 	// SPIRE management (admin only)
 	api.HandleFunc("/spire/status", s.RequireRole(roleSystemAdmin, s.spireStatusHandler)).Methods("GET")
@@ -74,15 +105,19 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Satellite routes (robot creds or SPIFFE)
 	satellites := r.PathPrefix("/satellites").Subrouter()
 
-	// Token-based ZTR (rate limited)
+	// Token-based ZTR (rate limited). Outcomes are counted after the rate
+	// limiter, so throttled requests are not registration failures.
 	ztr := satellites.PathPrefix("/ztr").Subrouter()
 	ztr.Use(middleware.RateLimitMiddleware(s.rateLimiter))
+	ztr.Use(metrics.ZTRMiddleware("token"))
 	ztr.HandleFunc("/{token}", s.ztrHandler).Methods("GET")
 
-	// SPIFFE-based ZTR (rate limited)
+	// SPIFFE-based ZTR (rate limited). Outcomes are counted after
+	// authentication, so requests without a SPIFFE ID are not ZTR failures.
 	spiffeZtr := satellites.PathPrefix("/spiffe-ztr").Subrouter()
 	spiffeZtr.Use(spiffe.RequireSPIFFEAuth)
 	spiffeZtr.Use(middleware.RateLimitMiddleware(s.rateLimiter))
+	spiffeZtr.Use(metrics.ZTRMiddleware("spiffe"))
 	spiffeZtr.HandleFunc("", s.spiffeZtrHandler).Methods("GET")
 
 	// Sync (dual auth: robot credentials or SPIFFE)
@@ -94,3 +129,16 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	return r
 }
+
+// metricsAuth requires the configured metrics token as a bearer token. Without
+// a configured token every request is refused.
+func (s *Server) metricsAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := extractBearerToken(r)
+		if s.metricsToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.metricsToken)) != 1 {
+			WriteJSONError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,6 +17,7 @@ import (
 	auditlog "github.com/container-registry/harbor-satellite/internal/groundcontrol/logger"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/spiffe"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/utils"
+	"github.com/container-registry/harbor-satellite/internal/inventory"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/gorilla/mux"
 )
@@ -24,15 +28,19 @@ import (
 type SatelliteGroupParams struct {
 	Satellite string `json:"satellite"`
 	Group     string `json:"group"`
+	// Selector adds every satellite matching the label selector instead of
+	// the named satellite.
+	Selector string `json:"selector,omitempty"`
 }
 
 // RegisterSatelliteParams registers a token-managed satellite.
 //
 // swagger:model RegisterSatelliteParams
 type RegisterSatelliteParams struct {
-	Name       string    `json:"name"`
-	Groups     *[]string `json:"groups,omitempty"`
-	ConfigName string    `json:"config_name"`
+	Name       string            `json:"name"`
+	Groups     *[]string         `json:"groups,omitempty"`
+	ConfigName string            `json:"config_name"`
+	Labels     map[string]string `json:"labels,omitempty"`
 }
 
 // RegisterSatelliteResponse contains a single-use ZTR token.
@@ -46,26 +54,102 @@ type RegisterSatelliteResponse struct {
 //
 // swagger:model CachedImage
 type CachedImage struct {
+	Repository string    `json:"repository"`
+	Tag        string    `json:"tag"`
+	Digest     string    `json:"digest"`
+	SizeBytes  int64     `json:"size_bytes"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// LegacyCachedImage is how satellites predating inventory reports list their
+// cached images, by "host/repository:tag@digest" reference.
+//
+// swagger:model LegacyCachedImage
+type LegacyCachedImage struct {
 	Reference string `json:"reference"`
 	SizeBytes int64  `json:"size_bytes"`
 }
 
+// SyncResponse answers a satellite status report.
+//
+// swagger:model SyncResponse
+type SyncResponse struct {
+	// InventoryResync asks the satellite to report its whole inventory in the
+	// next heartbeat.
+	InventoryResync bool `json:"inventory_resync,omitempty"`
+}
+
+// DependencyBreaker reports the circuit breaker state a satellite keeps for
+// one of its upstreams (ground_control, harbor or local_registry). An open
+// breaker means the satellite is alive but that dependency is failing.
+//
+// swagger:model DependencyBreaker
+type DependencyBreaker struct {
+	Dependency          string     `json:"dependency"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// ImageWarmStatus reports whether an image of the satellite's state is in the
+// image store of the container runtime on one node: "warm", "pending" or
+// "failed".
+//
+// swagger:model ImageWarmStatus
+type ImageWarmStatus struct {
+	Node      string     `json:"node"`
+	Ref       string     `json:"ref"`
+	Digest    string     `json:"digest"`
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
 // SatelliteStatusParams reports the current satellite status and cache metrics.
 //
 // swagger:model SatelliteStatusParams
 type SatelliteStatusParams struct {
-	Name                string        `json:"name"`
-	Activity            string        `json:"activity"`
-	StateReportInterval string        `json:"state_report_interval"`
-	LatestStateDigest   string        `json:"latest_state_digest"`
-	LatestConfigDigest  string        `json:"latest_config_digest"`
-	MemoryUsedBytes     uint64        `json:"memory_used_bytes"`
-	StorageUsedBytes    uint64        `json:"storage_used_bytes"`
-	CPUPercent          float64       `json:"cpu_percent"`
-	RequestCreatedTime  time.Time     `json:"request_created_time"`
-	LastSyncDurationMs  int64         `json:"last_sync_duration_ms"`
-	ImageCount          int           `json:"image_count"`
-	CachedImages        []CachedImage `json:"cached_images,omitempty"`
+	Name                string              `json:"name"`
+	Activity            string              `json:"activity"`
+	StateReportInterval string              `json:"state_report_interval"`
+	LatestStateDigest   string              `json:"latest_state_digest"`
+	LatestConfigDigest  string              `json:"latest_config_digest"`
+	MemoryUsedBytes     uint64              `json:"memory_used_bytes"`
+	StorageUsedBytes    uint64              `json:"storage_used_bytes"`
+	CPUPercent          float64             `json:"cpu_percent"`
+	RequestCreatedTime  time.Time           `json:"request_created_time"`
+	LastSyncDurationMs  int64               `json:"last_sync_duration_ms"`
+	ImageCount          int                 `json:"image_count"`
+	Breakers            []DependencyBreaker `json:"breakers,omitempty"`
+	// RejectedConfigDigest is the config the satellite rolled back after it
+	// failed probation. The satellite keeps its previous config until a newer
+	// one is published.
+	RejectedConfigDigest string `json:"rejected_config_digest,omitempty"`
+	// LastSyncError is the error of the satellite's last replication cycle,
+	// empty when it succeeded.
+	LastSyncError string `json:"last_sync_error,omitempty"`
+	// ConfigRevision is the revision of the config the satellite runs, when
+	// the config artifact carries one.
+	ConfigRevision int32 `json:"config_revision,omitempty"`
+	// RegistryUptimeSeconds is how long the embedded registry has been up
+	// since it last started. Satellites bringing their own registry leave it
+	// and RegistryRestarts out.
+	RegistryUptimeSeconds *int64 `json:"registry_uptime_seconds,omitempty"`
+	// RegistryRestarts counts the restarts of the embedded registry since the
+	// satellite started, after crashes, failed health probes or config changes.
+	RegistryRestarts *int32 `json:"registry_restarts,omitempty"`
+	// Inventory lists the images the satellite cached or removed since its
+	// last accepted report, or all of them when Full is set.
+	Inventory *inventory.Report `json:"inventory,omitempty"`
+	// CachedImages is sent instead of Inventory by older satellites.
+	//
+	// Deprecated: use Inventory.
+	CachedImages []LegacyCachedImage `json:"cached_images,omitempty"`
+	// Prewarm lists the warm status of every image on every node the
+	// satellite prewarms, omitted when prewarming is off.
+	Prewarm []ImageWarmStatus `json:"prewarm,omitempty"`
 }
 
 func (s *Server) registerSatelliteHandler(w http.ResponseWriter, r *http.Request) {
@@ -101,6 +185,11 @@ func (s *Server) registerSatelliteHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if err := validateLabels(req.Labels); err != nil {
+		HandleAppError(w, &AppError{Message: fmt.Sprintf("error: %v", err), Code: http.StatusBadRequest})
+		return
+	}
+
 	// If the robot account is already present, we need to check if the robot account
 	// permissions need to be updated.
 	// i.e, check if the satellite is already connected to the groups in the request body.
@@ -168,6 +257,21 @@ func (s *Server) registerSatelliteHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if err := setSatelliteLabels(r.Context(), q, satellite.ID, req.Labels); err != nil {
+		log.Printf("Error setting labels of satellite %s: %v", req.Name, err)
+		HandleAppError(w, &AppError{Message: "Error: failed to set satellite labels", Code: http.StatusInternalServerError})
+		return
+	}
+
+	// Join the dynamic groups the labels select, next to the requested ones.
+	dynamicGroups, err := dynamicGroupNames(r.Context(), q, req.Labels)
+	if err != nil {
+		log.Printf("Error resolving dynamic groups of satellite %s: %v", req.Name, err)
+		HandleAppError(w, &AppError{Message: "Error: failed to resolve dynamic groups", Code: http.StatusInternalServerError})
+		return
+	}
+	req.Groups = mergeGroupNames(req.Groups, dynamicGroups)
+
 	groupStates, err := addSatelliteToGroups(r.Context(), q, req.Groups, satellite.ID)
 	if err != nil {
 		log.Println("Error adding satellite to groups:", err)
@@ -236,7 +340,7 @@ func (s *Server) registerSatelliteHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// Create the satellite's state artifact
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), req.Name, groupStates, req.ConfigName)
+	err = pushSatelliteState(r.Context(), q, satellite.ID, satellite.Name, groupStates, req.ConfigName)
 	if err != nil {
 		log.Println(err)
 		HandleAppError(w, err)
@@ -286,6 +390,7 @@ func (s *Server) registerSatelliteHandler(w http.ResponseWriter, r *http.Request
 		Details: map[string]any{
 			"config_name": req.ConfigName,
 			"groups":      req.Groups,
+			"labels":      req.Labels,
 			"flow":        "token",
 		},
 	})
@@ -404,7 +509,7 @@ func (s *Server) ztrHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// For sanity, create (update) the state artifact during the registration process as well.
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), satellite.Name, states, configObject.ConfigName)
+	err = pushSatelliteState(r.Context(), s.dbQueries, satellite.ID, satellite.Name, states, configObject.ConfigName)
 	if err != nil {
 		log.Println(err)
 		HandleAppError(w, err)
@@ -592,7 +697,7 @@ func (s *Server) spiffeZtrHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = utils.CreateOrUpdateSatStateArtifact(r.Context(), satellite.Name, states, configObject.ConfigName)
+		err = pushSatelliteState(r.Context(), s.dbQueries, satellite.ID, satellite.Name, states, configObject.ConfigName)
 		if err != nil {
 			log.Printf("SPIFFE ZTR: Failed to create state artifact: %v", err)
 			HandleAppError(w, err)
@@ -632,7 +737,15 @@ func (s *Server) spiffeZtrHandler(w http.ResponseWriter, r *http.Request) {
 	WriteJSONResponse(w, http.StatusOK, result)
 }
 
+// listSatelliteHandler lists the satellites with their labels, optionally
+// only those matching the selector query parameter.
 func (s *Server) listSatelliteHandler(w http.ResponseWriter, r *http.Request) {
+	sel, err := selectorParam(r.URL.Query().Get("selector"))
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
 	result, err := s.dbQueries.ListSatellites(r.Context())
 	if err != nil {
 		log.Printf("Error: Failed to List Satellites: %v", err)
@@ -644,7 +757,21 @@ func (s *Server) listSatelliteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, result)
+	labels, err := allSatelliteLabels(r.Context(), s.dbQueries)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		HandleAppError(w, &AppError{Message: "Error: Failed to List Satellites", Code: http.StatusInternalServerError})
+		return
+	}
+
+	satellites := make([]labeledSatellite, 0, len(result))
+	for _, sat := range result {
+		if sel.Matches(labels[sat.ID]) {
+			satellites = append(satellites, labeledSatellite{Satellite: sat, Labels: labels[sat.ID]})
+		}
+	}
+
+	WriteJSONResponse(w, http.StatusOK, satellites)
 }
 
 func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
@@ -680,51 +807,61 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var artifactIDs []int32
-	if len(req.CachedImages) > 0 {
-		refs := make([]string, len(req.CachedImages))
-		sizes := make([]int64, len(req.CachedImages))
-		for i, img := range req.CachedImages {
-			refs[i] = img.Reference
-			sizes[i] = img.SizeBytes
-		}
-
-		err := s.dbQueries.BatchInsertArtifacts(r.Context(), database.BatchInsertArtifactsParams{
-			Refs:  refs,
-			Sizes: sizes,
-		})
+	report := req.Inventory
+	if report == nil && len(req.CachedImages) > 0 {
+		report = legacyInventory(req.CachedImages)
+	}
+	var resp SyncResponse
+	if report != nil {
+		resp.InventoryResync, err = s.applyInventory(r.Context(), sat.ID, report)
 		if err != nil {
-			log.Printf("Failed to batch insert artifacts: %v", err)
-			HandleAppError(w, &AppError{Message: "failed to save artifacts", Code: http.StatusInternalServerError})
+			log.Printf("Failed to save inventory of satellite %s: %v", satelliteName, err)
+			HandleAppError(w, &AppError{Message: "failed to save inventory", Code: http.StatusInternalServerError})
 			return
 		}
+	}
 
-		artifacts, err := s.dbQueries.GetArtifactIDsByReferences(r.Context(), refs)
-		if err != nil {
-			log.Printf("Failed to resolve artifact IDs: %v", err)
-			HandleAppError(w, &AppError{Message: "failed to resolve artifact IDs", Code: http.StatusInternalServerError})
-			return
-		}
+	breakers := req.Breakers
+	if breakers == nil {
+		breakers = []DependencyBreaker{}
+	}
+	breakersJSON, err := json.Marshal(breakers)
+	if err != nil {
+		log.Printf("Failed to encode breakers: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to save status", Code: http.StatusInternalServerError})
+		return
+	}
 
-		artifactIDs = make([]int32, len(artifacts))
-		for i, a := range artifacts {
-			artifactIDs[i] = a.ID
-		}
-		log.Printf("Stored %d artifacts for satellite %s", len(artifactIDs), satelliteName)
+	prewarm := req.Prewarm
+	if prewarm == nil {
+		prewarm = []ImageWarmStatus{}
+	}
+	prewarmJSON, err := json.Marshal(prewarm)
+	if err != nil {
+		log.Printf("Failed to encode prewarm status: %v", err)
+		HandleAppError(w, &AppError{Message: "failed to save status", Code: http.StatusInternalServerError})
+		return
 	}
 
 	_, err = s.dbQueries.InsertSatelliteStatus(r.Context(), database.InsertSatelliteStatusParams{
-		SatelliteID:        sat.ID,
-		Activity:           req.Activity,
-		LatestStateDigest:  toNullString(req.LatestStateDigest),
-		LatestConfigDigest: toNullString(req.LatestConfigDigest),
-		CpuPercent:         toNullString(fmt.Sprintf("%.2f", req.CPUPercent)),
-		MemoryUsedBytes:    toNullInt64(int64(req.MemoryUsedBytes)),
-		StorageUsedBytes:   toNullInt64(int64(req.StorageUsedBytes)),
-		LastSyncDurationMs: toNullInt64(req.LastSyncDurationMs),
-		ImageCount:         toNullInt32(int32(req.ImageCount)),
-		ReportedAt:         req.RequestCreatedTime,
-		ArtifactIds:        artifactIDs,
+		SatelliteID:           sat.ID,
+		Activity:              req.Activity,
+		LatestStateDigest:     toNullString(req.LatestStateDigest),
+		LatestConfigDigest:    toNullString(req.LatestConfigDigest),
+		CpuPercent:            toNullString(fmt.Sprintf("%.2f", req.CPUPercent)),
+		MemoryUsedBytes:       toNullInt64(int64(req.MemoryUsedBytes)),
+		StorageUsedBytes:      toNullInt64(int64(req.StorageUsedBytes)),
+		LastSyncDurationMs:    toNullInt64(req.LastSyncDurationMs),
+		ImageCount:            toNullInt32(int32(req.ImageCount)),
+		ReportedAt:            req.RequestCreatedTime,
+		Breakers:              breakersJSON,
+		RejectedConfigDigest:  toNullString(req.RejectedConfigDigest),
+		LastSyncError:         toNullString(req.LastSyncError),
+		ConfigRevision:        sql.NullInt32{Int32: req.ConfigRevision, Valid: req.ConfigRevision > 0},
+		RegistryUptimeSeconds: toNullInt64Ptr(req.RegistryUptimeSeconds),
+		RegistryRestarts:      toNullInt32Ptr(req.RegistryRestarts),
+		Prewarm:               prewarmJSON,
+		ImagesStaged:          imagesStaged(req.Prewarm),
 	})
 	if err != nil {
 		log.Printf("Failed to insert status: %v", err)
@@ -742,7 +879,22 @@ func (s *Server) syncHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+// imagesStaged reports whether every image is warm on every node, so a release
+// counts as staged only once it is on disk at the runtimes. It is null for
+// satellites that do not prewarm.
+func imagesStaged(prewarm []ImageWarmStatus) sql.NullBool {
+	if len(prewarm) == 0 {
+		return sql.NullBool{}
+	}
+	for _, s := range prewarm {
+		if s.State != "warm" {
+			return sql.NullBool{Valid: true}
+		}
+	}
+	return sql.NullBool{Bool: true, Valid: true}
 }
 
 func (s *Server) getSatelliteStatusHandler(w http.ResponseWriter, r *http.Request) {
@@ -765,22 +917,50 @@ func (s *Server) getSatelliteStatusHandler(w http.ResponseWriter, r *http.Reques
 }
 
 func (s *Server) getActiveSatellitesHandler(w http.ResponseWriter, r *http.Request) {
+	sel, err := selectorParam(r.URL.Query().Get("selector"))
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
 	satellites, err := s.dbQueries.GetActiveSatellites(r.Context())
 	if err != nil {
 		log.Printf("Failed to get active satellites: %v", err)
 		HandleAppError(w, &AppError{Message: "failed to get active satellites", Code: http.StatusInternalServerError})
 		return
 	}
+	if sel != nil {
+		labels, err := allSatelliteLabels(r.Context(), s.dbQueries)
+		if err != nil {
+			log.Printf("Failed to get active satellites: %v", err)
+			HandleAppError(w, &AppError{Message: "failed to get active satellites", Code: http.StatusInternalServerError})
+			return
+		}
+		satellites = filterBySelector(satellites, func(sat database.GetActiveSatellitesRow) int32 { return sat.ID }, labels, sel)
+	}
 	WriteJSONResponse(w, http.StatusOK, satellites)
 }
 
 func (s *Server) getStaleSatellitesHandler(w http.ResponseWriter, r *http.Request) {
+	sel, err := selectorParam(r.URL.Query().Get("selector"))
+	if err != nil {
+		HandleAppError(w, err)
+		return
+	}
 	satellites, err := s.dbQueries.GetStaleSatellites(r.Context())
 	if err != nil {
 		log.Printf("Failed to get stale satellites: %v", err)
 		HandleAppError(w, &AppError{Message: "failed to get stale satellites", Code: http.StatusInternalServerError})
 		return
 	}
+	if sel != nil {
+		labels, err := allSatelliteLabels(r.Context(), s.dbQueries)
+		if err != nil {
+			log.Printf("Failed to get stale satellites: %v", err)
+			HandleAppError(w, &AppError{Message: "failed to get stale satellites", Code: http.StatusInternalServerError})
+			return
+		}
+		satellites = filterBySelector(satellites, func(sat database.GetStaleSatellitesRow) int32 { return sat.ID }, labels, sel)
+	}
 	WriteJSONResponse(w, http.StatusOK, satellites)
 }
 
@@ -931,7 +1111,12 @@ func ensureSatelliteConfig(r *http.Request, q *database.Queries, satellite datab
 			return fmt.Errorf("create default config: %w", err)
 		}
 
-		if pushErr := utils.CreateAndPushConfigStateArtifact(r.Context(), defaultConfigJSON, "default"); pushErr != nil {
+		rev, err := recordConfigRevision(r.Context(), q, defaultConfig, "ground-control", revisionCreate, "")
+		if err != nil {
+			return err
+		}
+
+		if pushErr := utils.CreateAndPushConfigStateArtifact(r.Context(), defaultConfigJSON, "default", rev.Revision); pushErr != nil {
 			log.Printf("SPIFFE ZTR: Warning - failed to create config-state artifact: %v", pushErr)
 		}
 	}
@@ -996,11 +1181,27 @@ func (s *Server) autoRegisterSatellite(r *http.Request, name string) (database.S
 	return satellite, nil
 }
 
+// satelliteResponse is a satellite with its labels, the groups it is a
+// member of and the name of its config.
+type satelliteResponse struct {
+	labeledSatellite
+	Groups     []string `json:"groups"`
+	ConfigName string   `json:"config_name,omitempty"`
+}
+
 func (s *Server) GetSatelliteByName(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	satellite := vars["satellite"]
 
 	result, err := s.dbQueries.GetSatelliteByName(r.Context(), satellite)
+	if errors.Is(err, sql.ErrNoRows) {
+		HandleAppError(w, &AppError{Message: "satellite not found", Code: http.StatusNotFound})
+		return
+	}
+	var resp satelliteResponse
+	if err == nil {
+		resp, err = satelliteDetails(r.Context(), s.dbQueries, result)
+	}
 	if err != nil {
 		log.Printf("error: failed to get satellite: %v", err)
 		err := &AppError{
@@ -1011,7 +1212,43 @@ func (s *Server) GetSatelliteByName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, result)
+	WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+// satelliteDetails looks up the labels, groups and config of sat.
+func satelliteDetails(ctx context.Context, q *database.Queries, sat database.Satellite) (satelliteResponse, error) {
+	resp := satelliteResponse{labeledSatellite: labeledSatellite{Satellite: sat}, Groups: []string{}}
+	labels, err := satelliteLabels(ctx, q, sat.ID)
+	if err != nil {
+		return resp, err
+	}
+	resp.Labels = labels
+
+	groups, err := q.SatelliteGroupList(ctx, sat.ID)
+	if err != nil {
+		return resp, fmt.Errorf("list groups: %w", err)
+	}
+	for _, g := range groups {
+		grp, err := q.GetGroupByID(ctx, g.GroupID)
+		if err != nil {
+			return resp, fmt.Errorf("get group %d: %w", g.GroupID, err)
+		}
+		resp.Groups = append(resp.Groups, grp.GroupName)
+	}
+
+	sc, err := q.SatelliteConfig(ctx, sat.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return resp, nil
+	}
+	if err != nil {
+		return resp, fmt.Errorf("get satellite config: %w", err)
+	}
+	cfg, err := q.GetConfigByID(ctx, sc.ConfigID)
+	if err != nil {
+		return resp, fmt.Errorf("get config %d: %w", sc.ConfigID, err)
+	}
+	resp.ConfigName = cfg.ConfigName
+	return resp, nil
 }
 
 // The state artifact corresponding to the satellite must be deleted.
@@ -1104,6 +1341,12 @@ func (s *Server) DeleteSatelliteByName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only satellites that ever had config layers have a config artifact of
+	// their own, so a missing one is not an error.
+	if err := utils.DeleteArtifact(utils.ConstructHarborDeleteURL(sat.Name, "satellite-config")); err != nil {
+		log.Printf("Could not delete satellite config artifact: %v", err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Printf("Commit failed: %v", err)
 		HandleAppError(w, &AppError{
@@ -1138,6 +1381,11 @@ func (s *Server) addSatelliteToGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Selector != "" {
+		s.addSelectedSatellitesToGroup(w, r, req)
+		return
+	}
+
 	// Validate satellite and group
 	if !utils.IsValidName(req.Satellite) {
 		HandleAppError(w, &AppError{
@@ -1171,6 +1419,11 @@ func (s *Server) addSatelliteToGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := checkStaticGroup(r.Context(), s.dbQueries, grp); err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
 	// Check if satellite is already in the group
 	alreadyInGroup, err := s.dbQueries.CheckSatelliteInGroup(r.Context(), database.CheckSatelliteInGroupParams{
 		SatelliteID: sat.ID,
@@ -1299,7 +1552,7 @@ func (s *Server) addSatelliteToGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update the state artifact to also track the new group state artifact
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName)
+	err = pushSatelliteState(r.Context(), s.dbQueries, sat.ID, sat.Name, groupStates, configObject.ConfigName)
 	if err != nil {
 		log.Printf("Error: Failed to update satellite state artifact: %v", err)
 		HandleAppError(w, err)
@@ -1374,6 +1627,11 @@ func (s *Server) removeSatelliteFromGroup(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if err := checkStaticGroup(r.Context(), q, grp); err != nil {
+		HandleAppError(w, err)
+		return
+	}
+
 	params := database.RemoveSatelliteFromGroupParams{
 		SatelliteID: sat.ID,
 		GroupID:     grp.ID,
@@ -1452,7 +1710,7 @@ func (s *Server) removeSatelliteFromGroup(w http.ResponseWriter, r *http.Request
 	}
 
 	// Update the state artifact to also track the new group state artifact
-	err = utils.CreateOrUpdateSatStateArtifact(r.Context(), sat.Name, groupStates, configObject.ConfigName)
+	err = pushSatelliteState(r.Context(), q, sat.ID, sat.Name, groupStates, configObject.ConfigName)
 	if err != nil {
 		log.Println(err)
 		HandleAppError(w, err)
@@ -1482,11 +1740,21 @@ func (s *Server) getCachedImagesHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	artifacts, err := s.dbQueries.GetLatestArtifacts(r.Context(), sat.ID)
+	rows, err := s.dbQueries.ListSatelliteImages(r.Context(), sat.ID)
 	if err != nil {
 		HandleAppError(w, &AppError{Message: "failed to get cached images", Code: http.StatusInternalServerError})
 		return
 	}
 
-	WriteJSONResponse(w, http.StatusOK, artifacts)
+	images := make([]CachedImage, len(rows))
+	for i, row := range rows {
+		images[i] = CachedImage{
+			Repository: row.Repository,
+			Tag:        row.Tag,
+			Digest:     row.Digest,
+			SizeBytes:  row.SizeBytes,
+			UpdatedAt:  row.UpdatedAt,
+		}
+	}
+	WriteJSONResponse(w, http.StatusOK, images)
 }
@@ -10,7 +10,6 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gorilla/mux"
-	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,6 +22,8 @@ func TestListSatelliteHandler(t *testing.T) {
 			AddRow(1, "edge-01", now, now, sql.NullTime{Time: now, Valid: true}, sql.NullString{String: "30s", Valid: true}).
 			AddRow(2, "edge-02", now, now, sql.NullTime{}, sql.NullString{})
 		mock.ExpectQuery("SELECT .+ FROM satellites").WillReturnRows(rows)
+		mock.ExpectQuery("SELECT .+ FROM satellite_labels").
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "key", "value"}).AddRow(1, "tier", "prod"))
 
 		req := httptest.NewRequest(http.MethodGet, "/api/satellites", nil)
 		rr := httptest.NewRecorder()
@@ -31,6 +32,41 @@ func TestListSatelliteHandler(t *testing.T) {
 		require.Equal(t, http.StatusOK, rr.Code)
 		require.Contains(t, rr.Body.String(), "edge-01")
 		require.Contains(t, rr.Body.String(), "edge-02")
+		require.Contains(t, rr.Body.String(), `"labels":{"tier":"prod"}`)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("filters by selector", func(t *testing.T) {
+		server, mock := newMockServer(t)
+		now := time.Now().UTC().Truncate(time.Second)
+
+		rows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"}).
+			AddRow(1, "edge-01", now, now, sql.NullTime{}, sql.NullString{}).
+			AddRow(2, "edge-02", now, now, sql.NullTime{}, sql.NullString{})
+		mock.ExpectQuery("SELECT .+ FROM satellites").WillReturnRows(rows)
+		mock.ExpectQuery("SELECT .+ FROM satellite_labels").
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "key", "value"}).
+				AddRow(1, "tier", "prod").
+				AddRow(2, "tier", "dev"))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/satellites?selector=tier%3Dprod", nil)
+		rr := httptest.NewRecorder()
+		server.listSatelliteHandler(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Contains(t, rr.Body.String(), "edge-01")
+		require.NotContains(t, rr.Body.String(), "edge-02")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid selector returns 400", func(t *testing.T) {
+		server, mock := newMockServer(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/satellites?selector=tier+in+(prod", nil)
+		rr := httptest.NewRecorder()
+		server.listSatelliteHandler(rr, req)
+
+		require.Equal(t, http.StatusBadRequest, rr.Code)
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -39,6 +75,8 @@ func TestListSatelliteHandler(t *testing.T) {
 
 		rows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "last_seen", "heartbeat_interval"})
 		mock.ExpectQuery("SELECT .+ FROM satellites").WillReturnRows(rows)
+		mock.ExpectQuery("SELECT .+ FROM satellite_labels").
+			WillReturnRows(sqlmock.NewRows([]string{"satellite_id", "key", "value"}))
 
 		req := httptest.NewRequest(http.MethodGet, "/api/satellites", nil)
 		rr := httptest.NewRecorder()
@@ -154,12 +192,18 @@ func TestGetSatelliteStatusHandler(t *testing.T) {
 		statusRows := sqlmock.NewRows([]string{
 			"id", "satellite_id", "activity", "latest_state_digest", "latest_config_digest",
 			"cpu_percent", "memory_used_bytes", "storage_used_bytes", "last_sync_duration_ms",
-			"image_count", "reported_at", "created_at", "artifact_ids",
+			"image_count", "reported_at", "created_at", "breakers", "rejected_config_digest", "last_sync_error", "config_revision",
+			"registry_uptime_seconds", "registry_restarts", "prewarm", "images_staged",
 		}).AddRow(
 			1, 1, "syncing", sql.NullString{String: "sha256:abc", Valid: true}, sql.NullString{},
 			sql.NullString{String: "12.50", Valid: true}, sql.NullInt64{Int64: 1024, Valid: true},
 			sql.NullInt64{}, sql.NullInt64{},
-			sql.NullInt32{Int32: 3, Valid: true}, now, now, pq.Array([]int32{1, 2, 3}),
+			sql.NullInt32{Int32: 3, Valid: true}, now, now,
+			[]byte(`[{"dependency":"harbor","state":"open","consecutive_failures":3}]`),
+			sql.NullString{String: "sha256:bad", Valid: true}, sql.NullString{}, sql.NullInt32{Int32: 4, Valid: true},
+			sql.NullInt64{Int64: 3600, Valid: true}, sql.NullInt32{Int32: 2, Valid: true},
+			[]byte(`[{"node":"edge-01","ref":"harbor.example/library/nginx:1.27","digest":"sha256:aaa","state":"warm"}]`),
+			sql.NullBool{Bool: true, Valid: true},
 		)
 		mock.ExpectQuery("SELECT .+ FROM satellite_status").
 			WithArgs(int32(1)).
@@ -173,6 +217,12 @@ func TestGetSatelliteStatusHandler(t *testing.T) {
 
 		require.Equal(t, http.StatusOK, rr.Code)
 		require.Contains(t, rr.Body.String(), "syncing")
+		require.Contains(t, rr.Body.String(), `"state":"open"`)
+		require.Contains(t, rr.Body.String(), "sha256:bad")
+		require.Contains(t, rr.Body.String(), `"ConfigRevision":{"Int32":4`)
+		require.Contains(t, rr.Body.String(), `"RegistryRestarts":{"Int32":2`)
+		require.Contains(t, rr.Body.String(), `"state":"warm"`)
+		require.Contains(t, rr.Body.String(), `"ImagesStaged":{"Bool":true`)
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 
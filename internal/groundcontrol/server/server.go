@@ -12,11 +12,13 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/container-registry/harbor-satellite/internal/env"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/auth"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/database"
 	auditlog "github.com/container-registry/harbor-satellite/internal/groundcontrol/logger"
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/metrics"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/middleware"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/spiffe"
 )
@@ -51,6 +53,11 @@ type Server struct {
 	// from spoofing the audit source_ip. Enable only when GC sits behind a
 	// trusted reverse proxy.
 	trustForwardedHeaders bool
+
+	// Prometheus registry served on /metrics; nil disables the endpoint.
+	// metricsToken is required as a bearer token to scrape.
+	metricsRegistry *prometheus.Registry
+	metricsToken    string
 }
 
 // TLSConfig holds TLS settings for the server.
@@ -172,6 +179,16 @@ func NewServer() *ServerResult {
 		trustForwardedHeaders: cfg.Audit.TrustForwardedHeaders,
 	}
 
+	// The metrics name every satellite and group, so they are never served
+	// without a token.
+	switch {
+	case cfg.Server.MetricsEnabled && cfg.Server.MetricsToken == "":
+		log.Printf("METRICS_ENABLED is set without METRICS_TOKEN, /metrics is not served")
+	case cfg.Server.MetricsEnabled:
+		newServer.metricsRegistry = metrics.NewRegistry(dbQueries)
+		newServer.metricsToken = cfg.Server.MetricsToken
+	}
+
 	// Bootstrap system admin user if not exists
 	if err := newServer.BootstrapSystemAdmin(context.Background()); err != nil {
 		log.Fatalf("Failed to bootstrap system admin: %v", err)
@@ -28,6 +28,8 @@ type RegisterSatelliteRequest struct {
 	// maximum: 86400
 	TTLSeconds    int    `json:"ttl_seconds,omitempty"`
 	ParentAgentID string `json:"parent_agent_id,omitempty"`
+	// Labels of a new satellite. It joins the dynamic groups they select.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // RegisterSatelliteWithSPIFFEResponse contains satellite registration details.
@@ -138,6 +140,14 @@ func (s *Server) registerSatelliteWithSPIFFEHandler(w http.ResponseWriter, r *ht
 		}
 	}
 
+	if err := validateLabels(req.Labels); err != nil {
+		HandleAppError(w, &AppError{
+			Message: fmt.Sprintf("error: %v", err),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
 	validMethods := map[string]bool{"join_token": true, "x509pop": true, "sshpop": true}
 	if !validMethods[req.AttestationMethod] {
 		HandleAppError(w, &AppError{
@@ -325,6 +335,14 @@ func (s *Server) registerSatelliteWithSPIFFEHandler(w http.ResponseWriter, r *ht
 			})
 			return
 		}
+		if labelErr := labelNewSatellite(r.Context(), txQueries, satellite, req.Labels); labelErr != nil {
+			log.Printf("Register: Failed to label %s: %v", req.SatelliteName, labelErr)
+			HandleAppError(w, &AppError{
+				Message: fmt.Sprintf("Failed to set satellite labels: %v", labelErr),
+				Code:    http.StatusInternalServerError,
+			})
+			return
+		}
 
 		if commitErr := tx.Commit(); commitErr != nil {
 			log.Printf("Register: Failed to commit transaction for %s: %v", req.SatelliteName, commitErr)
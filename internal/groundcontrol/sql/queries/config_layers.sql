@@ -0,0 +1,68 @@
+-- name: UpsertGroupConfigLayer :one
+INSERT INTO group_config_layers (group_id, patch, priority, updated_by, updated_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT (group_id)
+DO UPDATE SET
+  patch = EXCLUDED.patch,
+  priority = EXCLUDED.priority,
+  updated_by = EXCLUDED.updated_by,
+  updated_at = NOW()
+RETURNING *;
+
+-- name: GetGroupConfigLayer :one
+SELECT * FROM group_config_layers
+WHERE group_id = $1;
+
+-- name: DeleteGroupConfigLayer :exec
+DELETE FROM group_config_layers
+WHERE group_id = $1;
+
+-- name: ListSatelliteConfigLayers :many
+SELECT g.group_name, l.patch, l.priority
+FROM group_config_layers l
+JOIN groups g ON g.id = l.group_id
+JOIN satellite_groups sg ON sg.group_id = l.group_id
+WHERE sg.satellite_id = $1
+ORDER BY l.priority, g.group_name;
+
+-- name: UpsertSatelliteConfigOverride :one
+INSERT INTO satellite_config_overrides (satellite_id, patch, updated_by, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (satellite_id)
+DO UPDATE SET
+  patch = EXCLUDED.patch,
+  updated_by = EXCLUDED.updated_by,
+  updated_at = NOW()
+RETURNING *;
+
+-- name: GetSatelliteConfigOverride :one
+SELECT * FROM satellite_config_overrides
+WHERE satellite_id = $1;
+
+-- name: DeleteSatelliteConfigOverride :exec
+DELETE FROM satellite_config_overrides
+WHERE satellite_id = $1;
+
+-- name: ListGroupConfiguredSatellites :many
+SELECT s.id, s.name, c.config_name
+FROM satellite_groups sg
+JOIN satellites s ON s.id = sg.satellite_id
+JOIN satellite_configs sc ON sc.satellite_id = s.id
+JOIN configs c ON c.id = sc.config_id
+WHERE sg.group_id = $1
+ORDER BY s.name;
+
+-- name: ListLayeredConfigSatellites :many
+SELECT s.id, s.name
+FROM satellites s
+JOIN satellite_configs sc ON sc.satellite_id = s.id
+WHERE sc.config_id = $1
+  AND (
+    EXISTS (SELECT 1 FROM satellite_config_overrides o WHERE o.satellite_id = s.id)
+    OR EXISTS (
+      SELECT 1 FROM satellite_groups sg
+      JOIN group_config_layers l ON l.group_id = sg.group_id
+      WHERE sg.satellite_id = s.id
+    )
+  )
+ORDER BY s.name;
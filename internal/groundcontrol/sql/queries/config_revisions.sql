@@ -0,0 +1,23 @@
+-- name: CreateConfigRevision :one
+INSERT INTO config_revisions (config_id, revision, config, author, source, message)
+VALUES (
+  $1,
+  (SELECT COALESCE(MAX(revision), 0) + 1 FROM config_revisions WHERE config_id = $1),
+  $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetConfigRevision :one
+SELECT * FROM config_revisions
+WHERE config_id = $1 AND revision = $2;
+
+-- name: GetLatestConfigRevision :one
+SELECT * FROM config_revisions
+WHERE config_id = $1
+ORDER BY revision DESC
+LIMIT 1;
+
+-- name: ListConfigRevisions :many
+SELECT * FROM config_revisions
+WHERE config_id = $1
+ORDER BY revision DESC;
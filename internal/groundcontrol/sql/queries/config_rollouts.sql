@@ -0,0 +1,85 @@
+-- name: CreateConfigRollout :one
+INSERT INTO config_rollouts (
+    config_id, config, config_digest, status, waves, auto_promote,
+    bake_seconds, max_failures, created_by, wave_started_at, created_at, updated_at
+)
+VALUES ($1, $2, $3, 'in_progress', $4, $5, $6, $7, $8, NOW(), NOW(), NOW())
+RETURNING *;
+
+-- name: GetConfigRollout :one
+SELECT * FROM config_rollouts
+WHERE id = $1 AND config_id = $2;
+
+-- name: ListConfigRollouts :many
+SELECT * FROM config_rollouts
+WHERE config_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetUnfinishedConfigRollout :one
+SELECT * FROM config_rollouts
+WHERE config_id = $1 AND status IN ('in_progress', 'halted')
+LIMIT 1;
+
+-- name: ListInProgressConfigRollouts :many
+SELECT * FROM config_rollouts
+WHERE status = 'in_progress'
+ORDER BY id;
+
+-- name: SetConfigRolloutStatus :one
+UPDATE config_rollouts
+SET status = $2,
+    message = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: AdvanceConfigRollout :one
+UPDATE config_rollouts
+SET current_wave = $2,
+    status = 'in_progress',
+    message = '',
+    wave_started_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ConfigRolloutBaked :one
+SELECT wave_started_at <= NOW() - bake_seconds * INTERVAL '1 second' AS baked
+FROM config_rollouts
+WHERE id = $1;
+
+-- name: AddConfigRolloutSatellite :exec
+INSERT INTO config_rollout_satellites (rollout_id, satellite_id, wave, added_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: SetConfigRolloutSatelliteDigest :exec
+UPDATE config_rollout_satellites
+SET config_digest = $3
+WHERE rollout_id = $1 AND satellite_id = $2;
+
+-- name: ListConfigRolloutSatellites :many
+SELECT rs.satellite_id, s.name, rs.wave, rs.added_at,
+       COALESCE(rs.config_digest, r.config_digest)::VARCHAR AS expected_config_digest,
+       ss.latest_config_digest, ss.rejected_config_digest, ss.last_sync_error,
+       ss.created_at AS reported_at
+FROM config_rollout_satellites rs
+JOIN config_rollouts r ON r.id = rs.rollout_id
+JOIN satellites s ON s.id = rs.satellite_id
+JOIN satellite_configs sc ON sc.satellite_id = rs.satellite_id AND sc.config_id = r.config_id
+LEFT JOIN LATERAL (
+    SELECT latest_config_digest, rejected_config_digest, last_sync_error, created_at
+    FROM satellite_status
+    WHERE satellite_id = rs.satellite_id
+    ORDER BY created_at DESC LIMIT 1
+) ss ON true
+WHERE rs.rollout_id = $1
+ORDER BY rs.wave, s.name;
+
+-- name: GetSatelliteRollout :one
+SELECT r.id, r.config_id, r.config, r.config_digest
+FROM config_rollouts r
+JOIN config_rollout_satellites rs ON rs.rollout_id = r.id
+JOIN satellite_configs sc ON sc.satellite_id = rs.satellite_id AND sc.config_id = r.config_id
+WHERE rs.satellite_id = $1 AND r.status IN ('in_progress', 'halted')
+LIMIT 1;
@@ -0,0 +1,21 @@
+-- name: SetGroupSelector :one
+INSERT INTO group_selectors (group_id, selector, updated_by, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (group_id)
+DO UPDATE SET
+  selector = EXCLUDED.selector,
+  updated_by = EXCLUDED.updated_by,
+  updated_at = NOW()
+RETURNING *;
+
+-- name: GetGroupSelector :one
+SELECT * FROM group_selectors
+WHERE group_id = $1;
+
+-- name: DeleteGroupSelector :exec
+DELETE FROM group_selectors
+WHERE group_id = $1;
+
+-- name: ListGroupSelectors :many
+SELECT * FROM group_selectors
+ORDER BY group_id;
@@ -0,0 +1,50 @@
+-- name: UpsertImageManifests :exec
+INSERT INTO image_manifests (digest, size_bytes)
+SELECT unnest(@digests::TEXT[]), unnest(@sizes::BIGINT[])
+ON CONFLICT (digest) DO NOTHING;
+
+-- name: UpsertSatelliteImages :exec
+INSERT INTO satellite_images (satellite_id, repository, tag, manifest_id, updated_at)
+SELECT @satellite_id::INT, u.repository, u.tag, m.id, NOW()
+FROM unnest(@repositories::TEXT[], @tags::TEXT[], @digests::TEXT[]) AS u(repository, tag, digest)
+JOIN image_manifests m ON m.digest = u.digest
+ON CONFLICT (satellite_id, repository, tag) DO UPDATE
+SET manifest_id = EXCLUDED.manifest_id, updated_at = NOW()
+WHERE satellite_images.manifest_id <> EXCLUDED.manifest_id;
+
+-- name: DeleteSatelliteImages :exec
+DELETE FROM satellite_images si
+USING unnest(@repositories::TEXT[], @tags::TEXT[]) AS u(repository, tag)
+WHERE si.satellite_id = @satellite_id::INT
+  AND si.repository = u.repository
+  AND si.tag = u.tag;
+
+-- name: DeleteSatelliteImagesExcept :exec
+DELETE FROM satellite_images si
+WHERE si.satellite_id = @satellite_id::INT
+  AND NOT EXISTS (
+    SELECT 1 FROM unnest(@repositories::TEXT[], @tags::TEXT[]) AS u(repository, tag)
+    WHERE u.repository = si.repository AND u.tag = si.tag
+  );
+
+-- name: ListSatelliteImages :many
+SELECT si.repository, si.tag, m.digest, m.size_bytes, si.updated_at
+FROM satellite_images si
+JOIN image_manifests m ON m.id = si.manifest_id
+WHERE si.satellite_id = $1
+ORDER BY si.repository, si.tag;
+
+-- name: GetSatelliteInventory :one
+SELECT * FROM satellite_inventory
+WHERE satellite_id = $1;
+
+-- name: UpsertSatelliteInventory :exec
+INSERT INTO satellite_inventory (satellite_id, snapshot_hash, image_count, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (satellite_id) DO UPDATE
+SET snapshot_hash = EXCLUDED.snapshot_hash, image_count = EXCLUDED.image_count, updated_at = NOW();
+
+-- name: DeleteOrphanedImageManifests :exec
+DELETE FROM image_manifests m
+WHERE NOT EXISTS (SELECT 1 FROM satellite_images si WHERE si.manifest_id = m.id)
+AND m.created_at < NOW() - INTERVAL '1 day' * @retention_days;
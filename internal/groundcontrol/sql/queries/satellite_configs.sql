@@ -17,3 +17,10 @@ DELETE FROM satellite_configs
 WHERE satellite_id = $1 AND config_id = $2;
 
 
+
+-- name: ListConfigSatellites :many
+SELECT s.id, s.name
+FROM satellites s
+JOIN satellite_configs sc ON sc.satellite_id = s.id
+WHERE sc.config_id = $1
+ORDER BY s.name;
@@ -0,0 +1,18 @@
+-- name: AddSatelliteLabel :exec
+INSERT INTO satellite_labels (satellite_id, key, value)
+VALUES ($1, $2, $3)
+ON CONFLICT (satellite_id, key)
+DO UPDATE SET value = EXCLUDED.value;
+
+-- name: DeleteSatelliteLabels :exec
+DELETE FROM satellite_labels
+WHERE satellite_id = $1;
+
+-- name: ListSatelliteLabels :many
+SELECT * FROM satellite_labels
+WHERE satellite_id = $1
+ORDER BY key;
+
+-- name: ListAllSatelliteLabels :many
+SELECT * FROM satellite_labels
+ORDER BY satellite_id, key;
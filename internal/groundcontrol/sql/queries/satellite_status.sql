@@ -2,9 +2,11 @@
 INSERT INTO satellite_status (
     satellite_id, activity, latest_state_digest, latest_config_digest,
     cpu_percent, memory_used_bytes, storage_used_bytes,
-    last_sync_duration_ms, image_count, reported_at, artifact_ids
+    last_sync_duration_ms, image_count, reported_at, breakers,
+    rejected_config_digest, last_sync_error, config_revision,
+    registry_uptime_seconds, registry_restarts, prewarm, images_staged
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 RETURNING *;
 
 -- name: UpdateSatelliteLastSeen :exec
@@ -52,18 +54,12 @@ WHERE created_at < NOW() - INTERVAL '1 day' * $1;
 SELECT * FROM satellite_status
 WHERE satellite_id = $1 ORDER BY created_at DESC LIMIT 1;
 
+-- name: ListLatestSatelliteStatuses :many
+SELECT DISTINCT ON (satellite_id) * FROM satellite_status
+ORDER BY satellite_id, created_at DESC;
+
 -- name: GetSatelliteStatusHistory :many
 SELECT * FROM satellite_status
 WHERE satellite_id = $1
 ORDER BY created_at DESC
 LIMIT $2;
-
--- name: GetLatestArtifacts :many
-SELECT a.id, a.reference, a.size_bytes, a.created_at
-FROM artifacts a
-WHERE a.id = ANY(
-    (SELECT artifact_ids FROM satellite_status
-     WHERE satellite_id = $1
-     ORDER BY created_at DESC LIMIT 1)
-)
-ORDER BY a.reference;
@@ -0,0 +1,6 @@
+-- +goose Up
+
+ALTER TABLE satellite_status ADD COLUMN breakers JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- +goose Down
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS breakers;
@@ -0,0 +1,6 @@
+-- +goose Up
+
+ALTER TABLE satellite_status ADD COLUMN rejected_config_digest VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS rejected_config_digest;
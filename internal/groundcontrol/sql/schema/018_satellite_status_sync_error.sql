@@ -0,0 +1,6 @@
+-- +goose Up
+
+ALTER TABLE satellite_status ADD COLUMN last_sync_error TEXT;
+
+-- +goose Down
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS last_sync_error;
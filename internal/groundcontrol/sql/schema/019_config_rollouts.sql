@@ -0,0 +1,35 @@
+-- +goose Up
+
+CREATE TABLE config_rollouts (
+  id SERIAL PRIMARY KEY,
+  config_id INT NOT NULL REFERENCES configs(id) ON DELETE CASCADE,
+  config JSONB NOT NULL,
+  config_digest VARCHAR(255) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  waves INT[] NOT NULL,
+  current_wave INT NOT NULL DEFAULT 0,
+  auto_promote BOOLEAN NOT NULL,
+  bake_seconds INT NOT NULL,
+  max_failures INT NOT NULL,
+  message TEXT NOT NULL DEFAULT '',
+  created_by VARCHAR(255) NOT NULL,
+  wave_started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- A config has at most one rollout that is not finished.
+CREATE UNIQUE INDEX idx_config_rollouts_unfinished ON config_rollouts(config_id)
+  WHERE status IN ('in_progress', 'halted');
+
+CREATE TABLE config_rollout_satellites (
+  rollout_id INT NOT NULL REFERENCES config_rollouts(id) ON DELETE CASCADE,
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  wave INT NOT NULL,
+  added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (rollout_id, satellite_id)
+);
+
+-- +goose Down
+DROP TABLE config_rollout_satellites;
+DROP TABLE config_rollouts;
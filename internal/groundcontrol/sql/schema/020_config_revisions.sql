@@ -0,0 +1,23 @@
+-- +goose Up
+
+CREATE TABLE config_revisions (
+  id SERIAL PRIMARY KEY,
+  config_id INT NOT NULL REFERENCES configs(id) ON DELETE CASCADE,
+  revision INT NOT NULL,
+  config JSONB NOT NULL,
+  author VARCHAR(255) NOT NULL,
+  source VARCHAR(32) NOT NULL,
+  message TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (config_id, revision)
+);
+
+-- The history of existing configs starts at their current version.
+INSERT INTO config_revisions (config_id, revision, config, author, source, created_at)
+SELECT id, 1, config, '', 'create', updated_at FROM configs;
+
+ALTER TABLE satellite_status ADD COLUMN config_revision INT;
+
+-- +goose Down
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS config_revision;
+DROP TABLE config_revisions;
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- A group layer is a JSON merge patch applied to the config of every satellite
+-- in the group. Layers apply in ascending priority, ties broken by group name.
+CREATE TABLE group_config_layers (
+  group_id INT PRIMARY KEY REFERENCES groups(id) ON DELETE CASCADE,
+  patch JSONB NOT NULL,
+  priority INT NOT NULL DEFAULT 0,
+  updated_by VARCHAR(255) NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- A satellite override is a JSON merge patch applied after the group layers.
+CREATE TABLE satellite_config_overrides (
+  satellite_id INT PRIMARY KEY REFERENCES satellites(id) ON DELETE CASCADE,
+  patch JSONB NOT NULL,
+  updated_by VARCHAR(255) NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Satellites with layers get their own rendering of a rollout's config, so
+-- each member records the digest it is expected to report.
+ALTER TABLE config_rollout_satellites ADD COLUMN config_digest VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE config_rollout_satellites DROP COLUMN IF EXISTS config_digest;
+DROP TABLE satellite_config_overrides;
+DROP TABLE group_config_layers;
@@ -0,0 +1,23 @@
+-- +goose Up
+
+CREATE TABLE satellite_labels (
+  satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+  key VARCHAR(255) NOT NULL,
+  value VARCHAR(255) NOT NULL,
+  PRIMARY KEY (satellite_id, key)
+);
+
+CREATE INDEX idx_satellite_labels_key_value ON satellite_labels(key, value);
+
+-- A group with a selector is dynamic: its members are exactly the satellites
+-- whose labels match the selector.
+CREATE TABLE group_selectors (
+  group_id INT PRIMARY KEY REFERENCES groups(id) ON DELETE CASCADE,
+  selector TEXT NOT NULL,
+  updated_by VARCHAR(255) NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE group_selectors;
+DROP TABLE satellite_labels;
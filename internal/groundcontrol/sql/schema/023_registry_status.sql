@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE satellite_status ADD COLUMN registry_uptime_seconds BIGINT;
+ALTER TABLE satellite_status ADD COLUMN registry_restarts INT;
+
+-- +goose Down
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS registry_restarts;
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS registry_uptime_seconds;
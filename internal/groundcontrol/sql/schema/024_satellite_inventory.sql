@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE image_manifests (
+    id         SERIAL PRIMARY KEY,
+    digest     VARCHAR(150) UNIQUE NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE satellite_images (
+    satellite_id INT NOT NULL REFERENCES satellites(id) ON DELETE CASCADE,
+    repository   VARCHAR(255) NOT NULL,
+    tag          VARCHAR(128) NOT NULL,
+    manifest_id  INT NOT NULL REFERENCES image_manifests(id),
+    updated_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (satellite_id, repository, tag)
+);
+CREATE INDEX idx_satellite_images_manifest_id ON satellite_images(manifest_id);
+
+CREATE TABLE satellite_inventory (
+    satellite_id  INT PRIMARY KEY REFERENCES satellites(id) ON DELETE CASCADE,
+    snapshot_hash VARCHAR(100) NOT NULL,
+    image_count   INT NOT NULL,
+    updated_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS artifact_ids;
+DROP TABLE IF EXISTS artifacts;
+
+-- +goose Down
+CREATE TABLE artifacts (
+    id         SERIAL PRIMARY KEY,
+    reference  VARCHAR(512) UNIQUE NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+ALTER TABLE satellite_status ADD COLUMN artifact_ids INT[];
+
+DROP TABLE IF EXISTS satellite_inventory;
+DROP TABLE IF EXISTS satellite_images;
+DROP TABLE IF EXISTS image_manifests;
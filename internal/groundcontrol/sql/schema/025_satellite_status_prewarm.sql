@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE satellite_status ADD COLUMN prewarm JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE satellite_status ADD COLUMN images_staged BOOLEAN;
+
+-- +goose Down
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS images_staged;
+ALTER TABLE satellite_status DROP COLUMN IF EXISTS prewarm;
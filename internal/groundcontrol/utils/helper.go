@@ -15,11 +15,13 @@ import (
 	"github.com/container-registry/harbor-satellite/internal/env"
 	"github.com/container-registry/harbor-satellite/internal/groundcontrol/harbor"
 	m "github.com/container-registry/harbor-satellite/internal/groundcontrol/models"
+	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/goharbor/go-client/pkg/sdk/v2.0/client/robot"
 	"github.com/goharbor/go-client/pkg/sdk/v2.0/models"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 )
 
 // GetProjectNames parses artifacts & returns project names
@@ -143,31 +145,18 @@ func CreateStateArtifact(ctx context.Context, stateArtifact *m.StateArtifact) er
 }
 
 // Create and Push State Artifact for Config
-func CreateAndPushConfigStateArtifact(ctx context.Context, configData []byte, configName string) error {
-	// func CreateAndPushConfigStateArtifact(ctx context.Context, configObject *m.ConfigObject) error {
-	// Marshal the state artifact to JSON format
-	// configData, err := json.Marshal(configObject.Config)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to marshal state artifact to JSON: %v", err)
-	// }
-
+func CreateAndPushConfigStateArtifact(ctx context.Context, configData []byte, configName string, revision int32) error {
 	// Create the image with the state artifact JSON
-	img, err := crane.Image(map[string][]byte{"artifacts.json": configData})
+	img, err := configStateImage(configData, revision)
 	if err != nil {
-		return fmt.Errorf("failed to create image: %w", err)
+		return err
 	}
 
 	if err := env.GC.Harbor.Validate(); err != nil {
 		return err
 	}
 
-	cfg := env.GC.Harbor
-	auth := authn.FromConfig(authn.AuthConfig{Username: cfg.Username, Password: cfg.Password})
-	options := []crane.Option{crane.WithAuth(auth), crane.WithContext(ctx)}
-
-	if strings.HasPrefix(cfg.URL, "http://") {
-		options = append(options, crane.Insecure)
-	}
+	options := harborCraneOptions(ctx)
 	// Construct the destination repository and strip protocol, if present
 	destinationRepo := AssembleConfigState(configName)
 	destinationRepo = stripProtocol(destinationRepo)
@@ -180,6 +169,88 @@ func CreateAndPushConfigStateArtifact(ctx context.Context, configData []byte, co
 	return tagImage(destinationRepo, options)
 }
 
+// PushConfigStateCandidate pushes a config under a rollout tag of the config's
+// state repository, leaving the latest tag untouched, and returns the digest
+// satellites will report once they apply it.
+func PushConfigStateCandidate(ctx context.Context, configData []byte, configName string, revision int32) (string, error) {
+	img, err := configStateImage(configData, revision)
+	if err != nil {
+		return "", err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute image digest: %w", err)
+	}
+
+	if err := env.GC.Harbor.Validate(); err != nil {
+		return "", err
+	}
+
+	options := harborCraneOptions(ctx)
+
+	destination := stripProtocol(AssembleConfigStateCandidate(configName, digest.String()))
+	if err := pushImage(img, destination, options); err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// PushSatelliteConfigState pushes the config rendered for one satellite from
+// its config and layers, and returns the digest the satellite will report
+// once it applies it.
+func PushSatelliteConfigState(ctx context.Context, configData []byte, satelliteName string, revision int32) (string, error) {
+	img, err := configStateImage(configData, revision)
+	if err != nil {
+		return "", err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute image digest: %w", err)
+	}
+
+	if err := env.GC.Harbor.Validate(); err != nil {
+		return "", err
+	}
+
+	options := harborCraneOptions(ctx)
+
+	destinationRepo := stripProtocol(AssembleSatelliteConfigState(satelliteName))
+	if err := pushImage(img, destinationRepo, options); err != nil {
+		return "", err
+	}
+	if err := tagImage(destinationRepo, options); err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// harborCraneOptions returns the crane options for pushing to Harbor with the
+// Ground Control credentials.
+func harborCraneOptions(ctx context.Context) []crane.Option {
+	cfg := env.GC.Harbor
+	auth := authn.FromConfig(authn.AuthConfig{Username: cfg.Username, Password: cfg.Password})
+	options := []crane.Option{crane.WithAuth(auth), crane.WithContext(ctx)}
+	if strings.HasPrefix(cfg.URL, "http://") {
+		options = append(options, crane.Insecure)
+	}
+	return options
+}
+
+// configStateImage builds the config state artifact. The revision is recorded
+// as a manifest annotation so satellites can report which revision they run.
+func configStateImage(configData []byte, revision int32) (v1.Image, error) {
+	img, err := crane.Image(map[string][]byte{"artifacts.json": configData})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image: %w", err)
+	}
+	if revision > 0 {
+		img = mutate.Annotations(img, map[string]string{
+			config.ConfigRevisionAnnotation: strconv.Itoa(int(revision)),
+		}).(v1.Image)
+	}
+	return img, nil
+}
+
 func AssembleSatelliteState(satelliteName string) string {
 	return fmt.Sprintf("%s/satellite/satellite-state/%s/state:latest", env.GC.Harbor.URL, satelliteName)
 }
@@ -188,7 +259,26 @@ func AssembleConfigState(configName string) string {
 	return fmt.Sprintf("%s/satellite/config-state/%s/state:latest", env.GC.Harbor.URL, configName)
 }
 
-func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, states []string, config string) error {
+// AssembleSatelliteConfigState returns the reference of the config rendered
+// for a satellite with group layers or an override.
+func AssembleSatelliteConfigState(satelliteName string) string {
+	return fmt.Sprintf("%s/satellite/satellite-config-state/%s/state:latest", env.GC.Harbor.URL, satelliteName)
+}
+
+// AssembleConfigStateCandidate returns the reference of a config pushed for a
+// rollout, tagged after its digest.
+func AssembleConfigStateCandidate(configName, digest string) string {
+	hex := strings.TrimPrefix(digest, "sha256:")
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return fmt.Sprintf("%s/satellite/config-state/%s/state:rollout-%s", env.GC.Harbor.URL, configName, hex)
+}
+
+// CreateOrUpdateSatStateArtifact pushes the state artifact of a satellite.
+// configState is the reference of the config state artifact the satellite
+// follows, usually AssembleConfigState of its config.
+func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, states []string, configState string) error {
 	if satelliteName == "" {
 		return fmt.Errorf("the satellite name must be atleast one character long")
 	}
@@ -201,7 +291,7 @@ func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, s
 		return err
 	}
 
-	satelliteState := &m.SatelliteStateArtifact{States: states, Config: AssembleConfigState(config)}
+	satelliteState := &m.SatelliteStateArtifact{States: states, Config: configState}
 	data, err := json.Marshal(satelliteState)
 	if err != nil {
 		return fmt.Errorf("failed to marshal satellite state artifact to JSON: %w", err)
@@ -212,12 +302,7 @@ func CreateOrUpdateSatStateArtifact(ctx context.Context, satelliteName string, s
 		return fmt.Errorf("failed to create image: %w", err)
 	}
 
-	cfg := env.GC.Harbor
-	auth := authn.FromConfig(authn.AuthConfig{Username: cfg.Username, Password: cfg.Password})
-	options := []crane.Option{crane.WithAuth(auth), crane.WithContext(ctx)}
-	if strings.HasPrefix(cfg.URL, "http://") {
-		options = append(options, crane.Insecure)
-	}
+	options := harborCraneOptions(ctx)
 
 	destinationRepo := AssembleSatelliteState(satelliteName)
 	destinationRepo = stripProtocol(destinationRepo)
@@ -0,0 +1,82 @@
+// Package inventory defines the cached image inventory a satellite reports to
+// Ground Control, and the snapshot hash both sides compute to agree on it.
+package inventory
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// Ref names a tag of a repository in the local registry.
+type Ref struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+}
+
+func (r Ref) String() string {
+	return r.Repository + ":" + r.Tag
+}
+
+// Image is a tag cached by a satellite and the manifest it points to.
+// SizeBytes sums the config and layers of the manifest; for an image index,
+// the blobs of every child manifest, each counted once.
+type Image struct {
+	Ref
+	Digest    string `json:"digest"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// Report is the inventory section of a heartbeat. A full report lists every
+// image; otherwise it lists the changes since the last report Ground Control
+// accepted. SnapshotHash is the hash of the whole inventory after the report
+// is applied.
+type Report struct {
+	Full         bool    `json:"full,omitempty"`
+	Upserted     []Image `json:"upserted,omitempty"`
+	Removed      []Ref   `json:"removed,omitempty"`
+	SnapshotHash string  `json:"snapshot_hash"`
+}
+
+// Sort orders images by repository and tag.
+func Sort(images []Image) {
+	slices.SortFunc(images, func(a, b Image) int {
+		return cmp.Or(cmp.Compare(a.Repository, b.Repository), cmp.Compare(a.Tag, b.Tag))
+	})
+}
+
+// Hash returns the snapshot hash of an inventory. It does not depend on the
+// order of images.
+func Hash(images []Image) string {
+	sorted := slices.Clone(images)
+	Sort(sorted)
+	h := sha256.New()
+	for _, img := range sorted {
+		_, _ = fmt.Fprintf(h, "%s@%s %d\n", img.Ref, img.Digest, img.SizeBytes)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Diff returns the images of to that are new or changed since from, and the
+// refs of from that are gone, both sorted.
+func Diff(from, to map[Ref]Image) ([]Image, []Ref) {
+	var upserted []Image
+	for ref, img := range to {
+		if old, ok := from[ref]; !ok || old != img {
+			upserted = append(upserted, img)
+		}
+	}
+	var removed []Ref
+	for ref := range from {
+		if _, ok := to[ref]; !ok {
+			removed = append(removed, ref)
+		}
+	}
+	Sort(upserted)
+	slices.SortFunc(removed, func(a, b Ref) int {
+		return cmp.Or(cmp.Compare(a.Repository, b.Repository), cmp.Compare(a.Tag, b.Tag))
+	})
+	return upserted, removed
+}
@@ -0,0 +1,39 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	nginx := Image{Ref: Ref{Repository: "library/nginx", Tag: "1.27"}, Digest: "sha256:aaa", SizeBytes: 100}
+	alpine := Image{Ref: Ref{Repository: "library/alpine", Tag: "3.20"}, Digest: "sha256:bbb", SizeBytes: 10}
+
+	require.Equal(t, Hash([]Image{nginx, alpine}), Hash([]Image{alpine, nginx}))
+	require.NotEqual(t, Hash([]Image{nginx}), Hash([]Image{nginx, alpine}))
+
+	moved := nginx
+	moved.Digest = "sha256:ccc"
+	require.NotEqual(t, Hash([]Image{nginx}), Hash([]Image{moved}))
+	require.Equal(t, Hash(nil), Hash([]Image{}))
+}
+
+func TestDiff(t *testing.T) {
+	nginx := Image{Ref: Ref{Repository: "library/nginx", Tag: "1.27"}, Digest: "sha256:aaa", SizeBytes: 100}
+	alpine := Image{Ref: Ref{Repository: "library/alpine", Tag: "3.20"}, Digest: "sha256:bbb", SizeBytes: 10}
+	redis := Image{Ref: Ref{Repository: "library/redis", Tag: "7"}, Digest: "sha256:ddd", SizeBytes: 50}
+	retagged := nginx
+	retagged.Digest = "sha256:ccc"
+
+	upserted, removed := Diff(
+		map[Ref]Image{nginx.Ref: nginx, alpine.Ref: alpine},
+		map[Ref]Image{nginx.Ref: retagged, redis.Ref: redis},
+	)
+	require.Equal(t, []Image{retagged, redis}, upserted)
+	require.Equal(t, []Ref{alpine.Ref}, removed)
+
+	upserted, removed = Diff(map[Ref]Image{nginx.Ref: nginx}, map[Ref]Image{nginx.Ref: nginx})
+	require.Empty(t, upserted)
+	require.Empty(t, removed)
+}
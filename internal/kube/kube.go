@@ -0,0 +1,189 @@
+// Package kube is a minimal client for the Kubernetes API, enough for the
+// satellite to publish ConfigMaps and for the operator to reconcile its
+// custom resources, without pulling in client-go.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir is where Kubernetes mounts the token, CA and namespace
+// of the service account of a pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// FieldManager owns the fields the satellite and the operator apply.
+const FieldManager = "harbor-satellite"
+
+// Content types of the patches the API server takes.
+const (
+	ApplyPatch = "application/apply-patch+yaml"
+	MergePatch = "application/merge-patch+json"
+)
+
+// Client talks to the Kubernetes API with the token of a service account.
+type Client struct {
+	// Server is the base URL of the API server.
+	Server string
+	// TokenFile holds the bearer token, read on every request as projected
+	// tokens rotate.
+	TokenFile string
+	// Namespace is the namespace of the pod the client runs in.
+	Namespace string
+	Client    *http.Client
+}
+
+// StatusError is an error status returned by the API server.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the API server.
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 from the API server, returned when
+// an update raced another one.
+func IsConflict(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusConflict
+}
+
+// InClusterClient returns a client authenticated with the service account
+// of the pod it runs in.
+func InClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes pod: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("service account CA holds no certificate")
+	}
+	namespace, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("read pod namespace: %w", err)
+	}
+	return &Client{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		TokenFile: filepath.Join(serviceAccountDir, "token"),
+		Namespace: strings.TrimSpace(string(namespace)),
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}, nil
+}
+
+// Do sends body, JSON encoded with contentType, to path and decodes the
+// response into out when it is not nil. Error statuses are returned as
+// *StatusError.
+func (c *Client) Do(ctx context.Context, method, path, contentType string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	token, err := os.ReadFile(filepath.Clean(c.TokenFile))
+	if err != nil {
+		return fmt.Errorf("read service account token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.Server, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(msg, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(msg))
+		}
+		return &StatusError{Code: resp.StatusCode, Message: status.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Apply creates or updates the object at path with a server-side apply, as
+// the owner of the fields set in obj.
+func (c *Client) Apply(ctx context.Context, path string, obj any) error {
+	// JSON is YAML, which the apply patch type takes.
+	return c.Do(ctx, http.MethodPatch, path+"?fieldManager="+FieldManager+"&force=true", ApplyPatch, obj, nil)
+}
+
+// ApplyConfigMap creates the ConfigMap namespace/name with data, or replaces
+// the data applied before.
+func (c *Client) ApplyConfigMap(ctx context.Context, namespace, name string, data map[string]string) error {
+	err := c.Apply(ctx, ObjectPath("", "v1", "configmaps", namespace, name), map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": namespace,
+			"labels":    map[string]string{"app.kubernetes.io/managed-by": FieldManager},
+		},
+		"data": data,
+	})
+	if err != nil {
+		return fmt.Errorf("apply ConfigMap %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// ObjectPath returns the API path of the named object of resource in
+// namespace, or of the whole resource when name is empty. An empty group
+// is the core API group.
+func ObjectPath(group, version, resource, namespace, name string) string {
+	path := "/apis/" + group + "/" + version
+	if group == "" {
+		path = "/api/" + version
+	}
+	path += "/namespaces/" + url.PathEscape(namespace) + "/" + resource
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
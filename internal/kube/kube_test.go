@@ -0,0 +1,47 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer sa-token", r.Header.Get("Authorization"))
+		if r.URL.Path == "/api/v1/namespaces/edge/configmaps/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","message":"configmaps \"missing\" not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"metadata":{"name":"present"}}`))
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600))
+	c := &Client{Server: srv.URL, TokenFile: tokenFile, Client: srv.Client()}
+
+	var obj struct {
+		Metadata struct{ Name string }
+	}
+	require.NoError(t, c.Do(context.Background(), http.MethodGet, ObjectPath("", "v1", "configmaps", "edge", "present"), "", nil, &obj))
+	require.Equal(t, "present", obj.Metadata.Name)
+
+	err := c.Do(context.Background(), http.MethodGet, ObjectPath("", "v1", "configmaps", "edge", "missing"), "", nil, nil)
+	require.True(t, IsNotFound(err))
+	require.False(t, IsConflict(err))
+	require.ErrorContains(t, err, `configmaps "missing" not found`)
+}
+
+func TestObjectPath(t *testing.T) {
+	require.Equal(t, "/apis/satellite.goharbor.io/v1alpha1/namespaces/edge/satellites",
+		ObjectPath("satellite.goharbor.io", "v1alpha1", "satellites", "edge", ""))
+	require.Equal(t, "/api/v1/namespaces/edge/secrets/store-1-ztr-token",
+		ObjectPath("", "v1", "secrets", "edge", "store-1-ztr-token"))
+}
@@ -107,6 +107,7 @@ const (
 	ReasonInvalidStateAuthConfig Reason = "invalid_state_auth_config"
 	ReasonRegistrationFailed     Reason = "registration_failed"
 	ReasonReconfigureFailed      Reason = "reconfigure_failed"
+	ReasonConfigRolledBack       Reason = "config_rolled_back"
 	ReasonForbidden              Reason = "forbidden"
 	ReasonNotFound               Reason = "not_found"
 	ReasonRateLimited            Reason = "rate_limited"
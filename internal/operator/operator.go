@@ -0,0 +1,447 @@
+package operator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/kube"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/container-registry/harbor-satellite/pkg/gcclient"
+	"github.com/rs/zerolog"
+)
+
+// jsonPatch is the content type of a JSON patch.
+const jsonPatch = "application/json-patch+json"
+
+// Operator reconciles the custom resources of one namespace. Ground Control
+// names are global, so one operator owns them; objects of other namespaces
+// are not looked at.
+type Operator struct {
+	Kube      *kube.Client
+	GC        *gcclient.Client
+	Namespace string
+	Interval  time.Duration
+	Log       zerolog.Logger
+
+	// tokens holds the ZTR tokens of registrations whose Secret could not be
+	// written yet, by satellite UID. A token is only handed out once.
+	tokens map[string]string
+	now    func() time.Time
+}
+
+// Run reconciles every Interval until the context is cancelled. Failed
+// rounds are logged and retried on the next tick.
+func (o *Operator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	for {
+		if err := o.RunOnce(ctx); err != nil {
+			o.Log.Warn().Err(err).Msg("Reconcile round failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce reconciles all custom resources once. Configs and groups go first
+// as satellites reference them.
+func (o *Operator) RunOnce(ctx context.Context) error {
+	configs, err := listObjects[SatelliteConfig](ctx, o, ResourceConfigs)
+	if err != nil {
+		return err
+	}
+	groups, err := listObjects[SatelliteGroup](ctx, o, ResourceSatelliteGroups)
+	if err != nil {
+		return err
+	}
+	satellites, err := listObjects[Satellite](ctx, o, ResourceSatellites)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range configs {
+		errs = append(errs, o.reconcileConfig(ctx, &configs[i]))
+	}
+	for i := range groups {
+		errs = append(errs, o.reconcileGroup(ctx, &groups[i]))
+	}
+	if len(satellites) > 0 {
+		active, stale, err := o.heartbeats(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get satellite heartbeats: %w", err))
+		}
+		for i := range satellites {
+			errs = append(errs, o.reconcileSatellite(ctx, &satellites[i], active, stale))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o *Operator) reconcileConfig(ctx context.Context, c *SatelliteConfig) error {
+	name := c.Metadata.Name
+	if c.Metadata.DeletionTimestamp != nil {
+		return o.finalize(ctx, ResourceConfigs, &c.Metadata, func() error {
+			return o.deleteConfig(ctx, name)
+		})
+	}
+	if err := o.addFinalizer(ctx, ResourceConfigs, &c.Metadata); err != nil {
+		return err
+	}
+	if c.Status.Synced && c.Status.ObservedGeneration == c.Metadata.Generation {
+		return nil
+	}
+	err := o.applyConfig(ctx, name, c.Spec.Config)
+	if err != nil {
+		err = fmt.Errorf("sync config %s: %w", name, err)
+	}
+	c.Status.SyncStatus = o.syncStatus(c.Status.SyncStatus, c.Metadata.Generation, err)
+	return errors.Join(err, o.patchStatus(ctx, ResourceConfigs, name, c.Status))
+}
+
+func (o *Operator) reconcileGroup(ctx context.Context, g *SatelliteGroup) error {
+	name := g.Metadata.Name
+	if g.Metadata.DeletionTimestamp != nil {
+		return o.finalize(ctx, ResourceSatelliteGroups, &g.Metadata, func() error {
+			return ignoreNotFound(o.GC.DeleteGroup(ctx, name))
+		})
+	}
+	if err := o.addFinalizer(ctx, ResourceSatelliteGroups, &g.Metadata); err != nil {
+		return err
+	}
+	if g.Status.Synced && g.Status.ObservedGeneration == g.Metadata.Generation {
+		return nil
+	}
+	_, err := o.GC.SyncGroup(ctx, gcclient.StateArtifact{
+		Group:     name,
+		Registry:  g.Spec.Registry,
+		Artifacts: g.Spec.Artifacts,
+	})
+	if err != nil {
+		err = fmt.Errorf("sync group %s: %w", name, err)
+	}
+	g.Status.SyncStatus = o.syncStatus(g.Status.SyncStatus, g.Metadata.Generation, err)
+	return errors.Join(err, o.patchStatus(ctx, ResourceSatelliteGroups, name, g.Status))
+}
+
+func (o *Operator) reconcileSatellite(ctx context.Context, s *Satellite, active, stale map[string]sql.NullTime) error {
+	name := s.Metadata.Name
+	if s.Metadata.DeletionTimestamp != nil {
+		// The token Secret is owned by the satellite and garbage collected
+		// with it.
+		return o.finalize(ctx, ResourceSatellites, &s.Metadata, func() error {
+			delete(o.tokens, s.Metadata.UID)
+			return o.deleteSatellite(ctx, name)
+		})
+	}
+	if err := o.addFinalizer(ctx, ResourceSatellites, &s.Metadata); err != nil {
+		return err
+	}
+
+	status := s.Status
+	status.Groups = slices.Clone(status.Groups)
+	var errs []error
+	if !s.Status.Synced || s.Status.ObservedGeneration != s.Metadata.Generation || o.tokens[s.Metadata.UID] != "" {
+		err := o.syncSatellite(ctx, s)
+		if err != nil {
+			err = fmt.Errorf("sync satellite %s: %w", name, err)
+			errs = append(errs, err)
+		}
+		s.Status.SyncStatus = o.syncStatus(s.Status.SyncStatus, s.Metadata.Generation, err)
+	}
+	if s.Status.Registered && active != nil {
+		errs = append(errs, o.refreshHeartbeat(ctx, s, active, stale))
+	}
+	if !reflect.DeepEqual(status, s.Status) {
+		errs = append(errs, o.patchStatus(ctx, ResourceSatellites, name, s.Status))
+	}
+	return errors.Join(errs...)
+}
+
+// syncSatellite registers the satellite, or brings its registration in line
+// with the spec.
+func (o *Operator) syncSatellite(ctx context.Context, s *Satellite) error {
+	name, spec := s.Metadata.Name, s.Spec
+	if !s.Status.Registered {
+		registered, err := o.GC.GetSatellite(ctx, name)
+		switch {
+		case errors.Is(err, gcclient.ErrNotFound):
+			// The token is handed out once, so its Secret must be writable
+			// before the satellite is registered.
+			if err := o.applyTokenSecret(ctx, s, ""); err != nil {
+				return fmt.Errorf("create ZTR token secret: %w", err)
+			}
+			params := gcclient.RegisterSatelliteParams{Name: name, ConfigName: spec.ConfigName, Labels: spec.Labels}
+			if len(spec.Groups) > 0 {
+				params.Groups = &spec.Groups
+			}
+			token, err := o.GC.RegisterSatellite(ctx, params)
+			if err != nil {
+				return err
+			}
+			s.Status.Registered = true
+			s.Status.ConfigName = spec.ConfigName
+			s.Status.Groups = slices.Clone(spec.Groups)
+			if o.tokens == nil {
+				o.tokens = make(map[string]string)
+			}
+			o.tokens[s.Metadata.UID] = token
+		case err != nil:
+			return err
+		default:
+			// Registered by hand or before the status was lost. The token
+			// is not issued again, the satellite keeps its credentials.
+			o.Log.Info().Str("satellite", name).Msg("Adopting satellite registered in Ground Control")
+			s.Status.Registered = true
+			s.Status.ConfigName = registered.ConfigName
+			s.Status.Groups = slices.Clone(registered.Groups)
+		}
+	}
+
+	if token := o.tokens[s.Metadata.UID]; token != "" {
+		if err := o.applyTokenSecret(ctx, s, token); err != nil {
+			return fmt.Errorf("write ZTR token secret: %w", err)
+		}
+		delete(o.tokens, s.Metadata.UID)
+		s.Status.TokenSecret = s.TokenSecret()
+	}
+
+	if s.Status.ConfigName != spec.ConfigName {
+		params := gcclient.SatelliteConfigParams{Satellite: name, ConfigName: spec.ConfigName}
+		if _, err := o.GC.SetSatelliteConfig(ctx, params); err != nil {
+			return err
+		}
+		s.Status.ConfigName = spec.ConfigName
+	}
+	for _, group := range spec.Groups {
+		if slices.Contains(s.Status.Groups, group) {
+			continue
+		}
+		if _, err := o.GC.AddSatelliteToGroup(ctx, gcclient.SatelliteGroupParams{Satellite: name, Group: group}); err != nil {
+			return err
+		}
+		s.Status.Groups = append(s.Status.Groups, group)
+	}
+	for _, group := range slices.Clone(s.Status.Groups) {
+		if slices.Contains(spec.Groups, group) {
+			continue
+		}
+		if err := o.GC.RemoveSatelliteFromGroup(ctx, gcclient.SatelliteGroupParams{Satellite: name, Group: group}); err != nil {
+			return err
+		}
+		s.Status.Groups = slices.DeleteFunc(s.Status.Groups, func(g string) bool { return g == group })
+	}
+	_, err := o.GC.SetSatelliteLabels(ctx, name, spec.Labels)
+	return err
+}
+
+// refreshHeartbeat copies the heartbeat state and the last sync of the
+// satellite from Ground Control into its status.
+func (o *Operator) refreshHeartbeat(ctx context.Context, s *Satellite, active, stale map[string]sql.NullTime) error {
+	name := s.Metadata.Name
+	s.Status.Heartbeat, s.Status.LastSeen = HeartbeatUnknown, nil
+	if h, ok := active[name]; ok {
+		s.Status.Heartbeat = HeartbeatActive
+		s.Status.LastSeen = lastSeen(h)
+	} else if h, ok := stale[name]; ok {
+		s.Status.Heartbeat = HeartbeatStale
+		s.Status.LastSeen = lastSeen(h)
+	}
+
+	report, err := o.GC.GetSatelliteStatus(ctx, name)
+	if errors.Is(err, gcclient.ErrNotFound) {
+		// The satellite has not reported yet.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get status of satellite %s: %w", name, err)
+	}
+	reportedAt := report.ReportedAt.UTC()
+	s.Status.LastSync = &reportedAt
+	s.Status.Activity = report.Activity
+	s.Status.LastSyncError = ""
+	if report.LastSyncError.Valid {
+		s.Status.LastSyncError = report.LastSyncError.String
+	}
+	return nil
+}
+
+func lastSeen(seen sql.NullTime) *time.Time {
+	if !seen.Valid {
+		return nil
+	}
+	t := seen.Time.UTC()
+	return &t
+}
+
+// heartbeats returns when the active and the stale satellites were last seen,
+// by name.
+func (o *Operator) heartbeats(ctx context.Context) (active, stale map[string]sql.NullTime, err error) {
+	activeList, err := o.GC.ListActiveSatellites(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	staleList, err := o.GC.ListStaleSatellites(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	active, stale = make(map[string]sql.NullTime), make(map[string]sql.NullTime)
+	for _, s := range activeList {
+		active[s.Name] = s.LastSeen
+	}
+	for _, s := range staleList {
+		stale[s.Name] = s.LastSeen
+	}
+	return active, stale, nil
+}
+
+// applyConfig creates the named config, or updates it when it exists.
+func (o *Operator) applyConfig(ctx context.Context, name string, cfg config.Config) error {
+	_, err := o.GC.GetConfig(ctx, name)
+	switch {
+	case errors.Is(err, gcclient.ErrNotFound):
+		return o.GC.CreateConfig(ctx, gcclient.ConfigObject{ConfigName: name, Config: cfg})
+	case err != nil:
+		return err
+	}
+	_, err = o.GC.UpdateConfig(ctx, name, cfg)
+	return err
+}
+
+// deleteConfig deletes a config. Deleting a missing config is not an error.
+func (o *Operator) deleteConfig(ctx context.Context, name string) error {
+	// Ground Control answers the deletion of a missing config with a 500.
+	if _, err := o.GC.GetConfig(ctx, name); err != nil {
+		return ignoreNotFound(err)
+	}
+	return o.GC.DeleteConfig(ctx, name)
+}
+
+// deleteSatellite deletes a satellite with its robot account and state
+// artifact. Deleting a missing satellite is not an error.
+func (o *Operator) deleteSatellite(ctx context.Context, name string) error {
+	if _, err := o.GC.GetSatellite(ctx, name); err != nil {
+		return ignoreNotFound(err)
+	}
+	return o.GC.DeleteSatellite(ctx, name)
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, gcclient.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// applyTokenSecret writes the ZTR token of the satellite to its Secret,
+// owned by the satellite so it goes away with it. An empty token creates the
+// Secret without data.
+func (o *Operator) applyTokenSecret(ctx context.Context, s *Satellite, token string) error {
+	name := s.TokenSecret()
+	secret := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": o.Namespace,
+			"labels":    map[string]string{"app.kubernetes.io/managed-by": kube.FieldManager},
+			"ownerReferences": []map[string]any{{
+				"apiVersion": Group + "/" + Version,
+				"kind":       "Satellite",
+				"name":       s.Metadata.Name,
+				"uid":        s.Metadata.UID,
+			}},
+		},
+		"type": "Opaque",
+	}
+	if token != "" {
+		secret["data"] = map[string][]byte{TokenKey: []byte(token)}
+	}
+	return o.Kube.Apply(ctx, kube.ObjectPath("", "v1", "secrets", o.Namespace, name), secret)
+}
+
+// syncStatus returns the status after a sync of generation that failed with
+// err, or succeeded when err is nil.
+func (o *Operator) syncStatus(prev SyncStatus, generation int64, err error) SyncStatus {
+	status := SyncStatus{ObservedGeneration: generation, Synced: err == nil, SyncedAt: prev.SyncedAt}
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	now := o.clock().UTC()
+	status.SyncedAt = &now
+	return status
+}
+
+func (o *Operator) clock() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
+
+func (o *Operator) path(resource, name string) string {
+	return kube.ObjectPath(Group, Version, resource, o.Namespace, name)
+}
+
+func listObjects[T any](ctx context.Context, o *Operator, resource string) ([]T, error) {
+	var l list[T]
+	if err := o.Kube.Do(ctx, http.MethodGet, o.path(resource, ""), "", nil, &l); err != nil {
+		return nil, fmt.Errorf("list %s: %w", resource, err)
+	}
+	return l.Items, nil
+}
+
+// patchStatus replaces the status of an object.
+func (o *Operator) patchStatus(ctx context.Context, resource, name string, status any) error {
+	// An add replaces the whole status, where a merge patch would keep the
+	// fields the new status leaves empty.
+	patch := []map[string]any{{"op": "add", "path": "/status", "value": status}}
+	if err := o.Kube.Do(ctx, http.MethodPatch, o.path(resource, name)+"/status", jsonPatch, patch, nil); err != nil {
+		return fmt.Errorf("update status of %s %s: %w", resource, name, err)
+	}
+	return nil
+}
+
+// setFinalizers replaces the finalizers of an object, failing with a
+// conflict when the object changed since it was read.
+func (o *Operator) setFinalizers(ctx context.Context, resource string, meta *ObjectMeta, finalizers []string) error {
+	patch := map[string]any{"metadata": map[string]any{
+		"finalizers":      finalizers,
+		"resourceVersion": meta.ResourceVersion,
+	}}
+	if err := o.Kube.Do(ctx, http.MethodPatch, o.path(resource, meta.Name), kube.MergePatch, patch, nil); err != nil {
+		return fmt.Errorf("update finalizers of %s %s: %w", resource, meta.Name, err)
+	}
+	meta.Finalizers = finalizers
+	return nil
+}
+
+func (o *Operator) addFinalizer(ctx context.Context, resource string, meta *ObjectMeta) error {
+	if slices.Contains(meta.Finalizers, Finalizer) {
+		return nil
+	}
+	return o.setFinalizers(ctx, resource, meta, append(slices.Clone(meta.Finalizers), Finalizer))
+}
+
+// finalize runs cleanup for an object being deleted and releases it.
+func (o *Operator) finalize(ctx context.Context, resource string, meta *ObjectMeta, cleanup func() error) error {
+	if !slices.Contains(meta.Finalizers, Finalizer) {
+		return nil
+	}
+	if err := cleanup(); err != nil {
+		return fmt.Errorf("delete %s %s from Ground Control: %w", resource, meta.Name, err)
+	}
+	o.Log.Info().Str("resource", resource).Str("name", meta.Name).Msg("Deleted from Ground Control")
+	finalizers := slices.DeleteFunc(slices.Clone(meta.Finalizers), func(f string) bool { return f == Finalizer })
+	return o.setFinalizers(ctx, resource, meta, finalizers)
+}
@@ -0,0 +1,483 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/models"
+	"github.com/container-registry/harbor-satellite/internal/kube"
+	"github.com/container-registry/harbor-satellite/pkg/gcclient"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKube is an API server holding the custom resources and Secrets of one
+// namespace.
+type fakeKube struct {
+	mu      sync.Mutex
+	objects map[string]map[string]any // by "resource/name"
+	version int
+	// failSecrets fails that many Secret applies, failTokens that many
+	// applies carrying a token.
+	failSecrets, failTokens int
+}
+
+func (f *fakeKube) put(resource string, obj map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	meta := obj["metadata"].(map[string]any)
+	f.version++
+	meta["resourceVersion"] = strconv.Itoa(f.version)
+	if _, ok := meta["generation"]; !ok {
+		meta["generation"] = 1
+	}
+	f.objects[resource+"/"+meta["name"].(string)] = obj
+}
+
+func (f *fakeKube) get(resource, name string) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj := f.objects[resource+"/"+name]
+	if obj == nil {
+		return nil
+	}
+	// Round-trip to hand out a copy with JSON types.
+	data, _ := json.Marshal(obj)
+	var out map[string]any
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// update changes the spec of an object, bumping its generation.
+func (f *fakeKube) update(resource, name string, spec map[string]any) {
+	obj := f.get(resource, name)
+	meta := obj["metadata"].(map[string]any)
+	meta["generation"] = meta["generation"].(float64) + 1
+	obj["spec"] = spec
+	f.put(resource, obj)
+}
+
+func (f *fakeKube) markDeleted(resource, name string) {
+	obj := f.get(resource, name)
+	obj["metadata"].(map[string]any)["deletionTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	f.put(resource, obj)
+}
+
+func (f *fakeKube) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	crPrefix := "/apis/" + Group + "/" + Version + "/namespaces/edge/"
+	parts := strings.Split(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, crPrefix), "/api/v1/namespaces/edge/"), "/")
+	key := strings.Join(parts[:min(2, len(parts))], "/")
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1:
+		items := []map[string]any{}
+		for k, obj := range f.objects {
+			if strings.HasPrefix(k, parts[0]+"/") {
+				items = append(items, obj)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i]["metadata"].(map[string]any)["name"].(string) < items[j]["metadata"].(map[string]any)["name"].(string)
+		})
+		_ = json.NewEncoder(w).Encode(map[string]any{"items": items})
+
+	case r.Method == http.MethodPatch && parts[0] == "secrets":
+		if r.Header.Get("Content-Type") != kube.ApplyPatch {
+			http.Error(w, "bad patch type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var obj map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&obj)
+		if f.failSecrets > 0 || (f.failTokens > 0 && obj["data"] != nil) {
+			if f.failSecrets > 0 {
+				f.failSecrets--
+			} else {
+				f.failTokens--
+			}
+			http.Error(w, `{"message":"secrets is forbidden"}`, http.StatusForbidden)
+			return
+		}
+		f.objects[key] = obj
+
+	case r.Method == http.MethodPatch && len(parts) == 3 && parts[2] == "status":
+		obj := f.objects[key]
+		if obj == nil {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		var patch []struct {
+			Op, Path string
+			Value    any
+		}
+		_ = json.NewDecoder(r.Body).Decode(&patch)
+		obj["status"] = patch[0].Value
+
+	case r.Method == http.MethodPatch && len(parts) == 2:
+		obj := f.objects[key]
+		if obj == nil {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		var patch struct {
+			Metadata struct {
+				Finalizers      []string `json:"finalizers"`
+				ResourceVersion string   `json:"resourceVersion"`
+			} `json:"metadata"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&patch)
+		meta := obj["metadata"].(map[string]any)
+		if patch.Metadata.ResourceVersion != meta["resourceVersion"] {
+			http.Error(w, `{"message":"the object has been modified"}`, http.StatusConflict)
+			return
+		}
+		f.version++
+		meta["resourceVersion"] = strconv.Itoa(f.version)
+		meta["finalizers"] = patch.Metadata.Finalizers
+		if meta["deletionTimestamp"] != nil && len(patch.Metadata.Finalizers) == 0 {
+			delete(f.objects, key)
+		}
+
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+	}
+}
+
+// fakeGC is a Ground Control with one admin user.
+type fakeGC struct {
+	mu         sync.Mutex
+	sessions   map[string]bool
+	logins     int
+	groups     map[string]models.StateArtifact
+	configs    map[string]json.RawMessage
+	satellites map[string]*fakeSatellite
+	active     []string
+	reports    map[string]string // last sync error by satellite
+}
+
+type fakeSatellite struct {
+	Config string
+	Groups []string
+	Labels map[string]string
+}
+
+func newFakeGC() *fakeGC {
+	return &fakeGC{
+		sessions:   map[string]bool{},
+		groups:     map[string]models.StateArtifact{},
+		configs:    map[string]json.RawMessage{},
+		satellites: map[string]*fakeSatellite{},
+		reports:    map[string]string{},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (g *fakeGC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fail := func(code int, msg string) { writeJSON(w, code, map[string]any{"code": code, "message": msg}) }
+
+	if r.URL.Path == "/login" {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["username"] != "admin" || req["password"] != "Harbor12345" {
+			fail(http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+		g.logins++
+		token := "session-" + strconv.Itoa(g.logins)
+		g.sessions[token] = true
+		writeJSON(w, http.StatusOK, map[string]string{"token": token})
+		return
+	}
+	if !g.sessions[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		fail(http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	switch {
+	case r.Method == http.MethodPost && path == "/groups/sync":
+		var req models.StateArtifact
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		g.groups[req.Group] = req
+		writeJSON(w, http.StatusOK, map[string]string{"group_name": req.Group})
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/groups/") && path != "/groups/satellite":
+		name := strings.TrimPrefix(path, "/groups/")
+		if _, ok := g.groups[name]; !ok {
+			fail(http.StatusNotFound, "Group not found")
+			return
+		}
+		delete(g.groups, name)
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/configs/"):
+		name := strings.TrimPrefix(path, "/configs/")
+		if _, ok := g.configs[name]; !ok {
+			fail(http.StatusNotFound, "config not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"config_name": name})
+	case r.Method == http.MethodPost && path == "/configs":
+		var req struct {
+			ConfigName string          `json:"config_name"`
+			Config     json.RawMessage `json:"config"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		g.configs[req.ConfigName] = req.Config
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "/configs/"):
+		var cfg json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&cfg)
+		name := strings.TrimPrefix(path, "/configs/")
+		g.configs[name] = cfg
+		writeJSON(w, http.StatusOK, map[string]any{"ConfigName": name, "Config": cfg})
+	case r.Method == http.MethodPost && path == "/configs/satellite":
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		g.satellites[req["satellite"]].Config = req["config_name"]
+		writeJSON(w, http.StatusOK, map[string]any{"satellites": []string{req["satellite"]}})
+
+	case r.Method == http.MethodPost && path == "/satellites":
+		var req struct {
+			Name       string            `json:"name"`
+			Groups     []string          `json:"groups"`
+			ConfigName string            `json:"config_name"`
+			Labels     map[string]string `json:"labels"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if _, ok := g.configs[req.ConfigName]; !ok {
+			fail(http.StatusInternalServerError, "sql: no rows in result set")
+			return
+		}
+		g.satellites[req.Name] = &fakeSatellite{Config: req.ConfigName, Groups: req.Groups, Labels: req.Labels}
+		writeJSON(w, http.StatusOK, map[string]string{"token": "ztr-" + req.Name})
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/satellites/"):
+		name := strings.TrimPrefix(path, "/satellites/")
+		if _, ok := g.satellites[name]; !ok {
+			fail(http.StatusBadRequest, "Error: Satellite Not Found")
+			return
+		}
+		delete(g.satellites, name)
+	case r.Method == http.MethodPut && strings.HasSuffix(path, "/labels"):
+		var req struct {
+			Labels map[string]string `json:"labels"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		g.satellites[strings.TrimSuffix(strings.TrimPrefix(path, "/satellites/"), "/labels")].Labels = req.Labels
+		writeJSON(w, http.StatusOK, req)
+	case r.Method == http.MethodPost && path == "/groups/satellite":
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sat := g.satellites[req["satellite"]]
+		if !slices.Contains(sat.Groups, req["group"]) {
+			sat.Groups = append(sat.Groups, req["group"])
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Satellite added to group"})
+	case r.Method == http.MethodDelete && path == "/groups/satellite":
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sat := g.satellites[req["satellite"]]
+		sat.Groups = slices.DeleteFunc(sat.Groups, func(s string) bool { return s == req["group"] })
+
+	case r.Method == http.MethodGet && (path == "/satellites/active" || path == "/satellites/stale"):
+		list := []map[string]any{}
+		for name := range g.satellites {
+			if slices.Contains(g.active, name) == (path == "/satellites/active") {
+				list = append(list, map[string]any{
+					"Name":     name,
+					"LastSeen": map[string]any{"Time": "2026-10-01T12:00:00Z", "Valid": true},
+				})
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/status"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/satellites/"), "/status")
+		syncErr, ok := g.reports[name]
+		if !ok {
+			fail(http.StatusNotFound, "no status available")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"Activity":      "sync",
+			"ReportedAt":    "2026-10-01T12:00:00Z",
+			"LastSyncError": map[string]any{"String": syncErr, "Valid": syncErr != ""},
+		})
+	case r.Method == http.MethodGet && strings.Count(path, "/") == 2 && strings.HasPrefix(path, "/satellites/"):
+		name := strings.TrimPrefix(path, "/satellites/")
+		sat, ok := g.satellites[name]
+		if !ok {
+			fail(http.StatusNotFound, "satellite not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ID": 1, "Name": name, "groups": sat.Groups, "config_name": sat.Config})
+
+	default:
+		fail(http.StatusNotFound, "unexpected "+r.Method+" "+r.URL.Path)
+	}
+}
+
+func newTestOperator(t *testing.T) (*Operator, *fakeKube, *fakeGC) {
+	t.Helper()
+	k := &fakeKube{objects: map[string]map[string]any{}}
+	kubeSrv := httptest.NewServer(k)
+	t.Cleanup(kubeSrv.Close)
+	gc := newFakeGC()
+	gcSrv := httptest.NewServer(gc)
+	t.Cleanup(gcSrv.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token"), 0o600))
+	op := &Operator{
+		Kube: &kube.Client{Server: kubeSrv.URL, TokenFile: tokenFile, Namespace: "edge", Client: kubeSrv.Client()},
+		GC: gcclient.New(gcSrv.URL,
+			gcclient.WithCredentials("admin", "Harbor12345"),
+			gcclient.WithHTTPClient(gcSrv.Client()),
+			gcclient.WithRetry(gcclient.RetryPolicy{})),
+		Namespace: "edge",
+		Log:       zerolog.Nop(),
+		now:       func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) },
+	}
+	return op, k, gc
+}
+
+func object(name string, spec map[string]any) map[string]any {
+	return map[string]any{
+		"metadata": map[string]any{"name": name, "namespace": "edge", "uid": "uid-" + name},
+		"spec":     spec,
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	op, k, gc := newTestOperator(t)
+	ctx := context.Background()
+
+	k.put(ResourceConfigs, object("edge-config", map[string]any{
+		"config": map[string]any{"app_config": map[string]any{"log_level": "debug"}},
+	}))
+	for _, group := range []string{"group-a", "group-b"} {
+		k.put(ResourceSatelliteGroups, object(group, map[string]any{
+			"artifacts": []any{map[string]any{"repository": "library/alpine", "tag": []string{"latest"}}},
+		}))
+	}
+	k.put(ResourceSatellites, object("store-1", map[string]any{
+		"configName": "edge-config",
+		"groups":     []string{"group-a"},
+		"labels":     map[string]string{"region": "eu"},
+	}))
+
+	// No satellite is registered while its Secret can not be written.
+	k.failSecrets = 1
+	require.ErrorContains(t, op.RunOnce(ctx), "secrets is forbidden")
+	require.NotContains(t, gc.satellites, "store-1")
+	status := k.get(ResourceSatellites, "store-1")["status"].(map[string]any)
+	require.Equal(t, false, status["registered"])
+
+	// A token that can not be written is kept for the next round.
+	k.failTokens = 1
+	require.ErrorContains(t, op.RunOnce(ctx), "secrets is forbidden")
+	require.Contains(t, gc.satellites, "store-1")
+	require.NotNil(t, k.get("secrets", "store-1-ztr-token"), "created before registering")
+	status = k.get(ResourceSatellites, "store-1")["status"].(map[string]any)
+	require.Equal(t, true, status["registered"])
+	require.Equal(t, false, status["synced"])
+
+	require.NoError(t, op.RunOnce(ctx))
+	require.Contains(t, gc.configs, "edge-config")
+	require.Equal(t, "library/alpine", gc.groups["group-a"].Artifacts[0].Repository)
+	require.Equal(t, &fakeSatellite{Config: "edge-config", Groups: []string{"group-a"}, Labels: map[string]string{"region": "eu"}}, gc.satellites["store-1"])
+
+	secret := k.get("secrets", "store-1-ztr-token")
+	require.NotNil(t, secret)
+	require.Equal(t, "enRyLXN0b3JlLTE=", secret["data"].(map[string]any)[TokenKey], "base64 of the ZTR token")
+	owner := secret["metadata"].(map[string]any)["ownerReferences"].([]any)[0].(map[string]any)
+	require.Equal(t, "uid-store-1", owner["uid"])
+
+	sat := k.get(ResourceSatellites, "store-1")
+	require.Equal(t, []any{Finalizer}, sat["metadata"].(map[string]any)["finalizers"])
+	status = sat["status"].(map[string]any)
+	require.Equal(t, true, status["synced"])
+	require.Equal(t, "store-1-ztr-token", status["tokenSecret"])
+	require.Equal(t, HeartbeatStale, status["heartbeat"])
+	require.Nil(t, status["message"])
+	require.Equal(t, true, k.get(ResourceConfigs, "edge-config")["status"].(map[string]any)["synced"])
+	require.Equal(t, true, k.get(ResourceSatelliteGroups, "group-a")["status"].(map[string]any)["synced"])
+
+	// Heartbeats are refreshed on every round.
+	gc.active = []string{"store-1"}
+	gc.reports["store-1"] = "pull library/alpine: unauthorized"
+	require.NoError(t, op.RunOnce(ctx))
+	status = k.get(ResourceSatellites, "store-1")["status"].(map[string]any)
+	require.Equal(t, HeartbeatActive, status["heartbeat"])
+	require.Equal(t, "2026-10-01T12:00:00Z", status["lastSync"])
+	require.Equal(t, "pull library/alpine: unauthorized", status["lastSyncError"])
+
+	// A new spec moves the satellite between groups.
+	k.update(ResourceSatellites, "store-1", map[string]any{
+		"configName": "edge-config",
+		"groups":     []string{"group-b"},
+	})
+	require.NoError(t, op.RunOnce(ctx))
+	require.Equal(t, []string{"group-b"}, gc.satellites["store-1"].Groups)
+	require.Empty(t, gc.satellites["store-1"].Labels)
+	require.Equal(t, float64(2), k.get(ResourceSatellites, "store-1")["status"].(map[string]any)["observedGeneration"])
+
+	// Deleted objects are removed from Ground Control before they go.
+	k.markDeleted(ResourceSatellites, "store-1")
+	k.markDeleted(ResourceSatelliteGroups, "group-a")
+	require.NoError(t, op.RunOnce(ctx))
+	require.NotContains(t, gc.satellites, "store-1")
+	require.NotContains(t, gc.groups, "group-a")
+	require.Nil(t, k.get(ResourceSatellites, "store-1"))
+	require.Nil(t, k.get(ResourceSatelliteGroups, "group-a"))
+}
+
+func TestRunOnceAdoptsSatellite(t *testing.T) {
+	op, k, gc := newTestOperator(t)
+	gc.configs["edge-config"] = json.RawMessage(`{}`)
+	gc.satellites["store-1"] = &fakeSatellite{Config: "old-config", Groups: []string{"group-a", "group-b"}}
+	k.put(ResourceSatellites, object("store-1", map[string]any{"configName": "edge-config", "groups": []string{"group-a"}}))
+
+	require.NoError(t, op.RunOnce(context.Background()))
+	require.Equal(t, "edge-config", gc.satellites["store-1"].Config)
+	require.Equal(t, []string{"group-a"}, gc.satellites["store-1"].Groups, "groups found in Ground Control are reconciled")
+	status := k.get(ResourceSatellites, "store-1")["status"].(map[string]any)
+	require.Equal(t, true, status["registered"])
+	require.Equal(t, "edge-config", status["configName"])
+	require.Equal(t, []any{"group-a"}, status["groups"])
+	require.Nil(t, status["tokenSecret"], "no token is issued for an adopted satellite")
+	require.Nil(t, k.get("secrets", "store-1-ztr-token"))
+}
+
+func TestRunOnceLogsInAgain(t *testing.T) {
+	op, k, gc := newTestOperator(t)
+	k.put(ResourceSatelliteGroups, object("group-a", map[string]any{
+		"artifacts": []any{map[string]any{"repository": "library/alpine", "tag": []string{"latest"}}},
+	}))
+	require.NoError(t, op.RunOnce(context.Background()))
+
+	// The session expires.
+	gc.sessions = map[string]bool{}
+	k.update(ResourceSatelliteGroups, "group-a", map[string]any{
+		"artifacts": []any{map[string]any{"repository": "library/nginx", "tag": []string{"latest"}}},
+	})
+	require.NoError(t, op.RunOnce(context.Background()))
+	require.Equal(t, 2, gc.logins)
+	require.Equal(t, "library/nginx", gc.groups["group-a"].Artifacts[0].Repository)
+}
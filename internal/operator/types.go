@@ -0,0 +1,144 @@
+// Package operator reconciles Satellite, SatelliteGroup and SatelliteConfig
+// custom resources against the Ground Control API, so satellites can be
+// managed declaratively next to the rest of a cluster.
+package operator
+
+import (
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/models"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// API group and version of the custom resources.
+const (
+	Group   = "satellite.goharbor.io"
+	Version = "v1alpha1"
+)
+
+// Resources, by the plural the API serves them under.
+const (
+	ResourceSatellites      = "satellites"
+	ResourceSatelliteGroups = "satellitegroups"
+	ResourceConfigs         = "satelliteconfigs"
+)
+
+// Finalizer holds custom resources back from deletion until the operator
+// removed them from Ground Control.
+const Finalizer = "satellite.goharbor.io/ground-control"
+
+// TokenKey is the key of the ZTR token in the Secret of a satellite.
+const TokenKey = "token"
+
+// ObjectMeta is the part of the metadata of an object the operator uses.
+type ObjectMeta struct {
+	Name              string     `json:"name"`
+	Namespace         string     `json:"namespace,omitempty"`
+	UID               string     `json:"uid,omitempty"`
+	ResourceVersion   string     `json:"resourceVersion,omitempty"`
+	Generation        int64      `json:"generation,omitempty"`
+	Finalizers        []string   `json:"finalizers,omitempty"`
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+}
+
+// SyncStatus is the status shared by all custom resources.
+type SyncStatus struct {
+	// ObservedGeneration is the generation of the spec last pushed to
+	// Ground Control.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Synced is set when the spec of ObservedGeneration is in Ground Control.
+	Synced bool `json:"synced"`
+	// Message is the error of the last failed sync.
+	Message  string     `json:"message,omitempty"`
+	SyncedAt *time.Time `json:"syncedAt,omitempty"`
+}
+
+// SatelliteGroup is a group of artifacts satellites replicate.
+type SatelliteGroup struct {
+	Metadata ObjectMeta           `json:"metadata"`
+	Spec     SatelliteGroupSpec   `json:"spec"`
+	Status   SatelliteGroupStatus `json:"status,omitempty"`
+}
+
+type SatelliteGroupSpec struct {
+	// Registry defaults to the Harbor of Ground Control.
+	Registry  string            `json:"registry,omitempty"`
+	Artifacts []models.Artifact `json:"artifacts"`
+}
+
+type SatelliteGroupStatus struct {
+	SyncStatus
+}
+
+// SatelliteConfig is a named satellite config.
+type SatelliteConfig struct {
+	Metadata ObjectMeta            `json:"metadata"`
+	Spec     SatelliteConfigSpec   `json:"spec"`
+	Status   SatelliteConfigStatus `json:"status,omitempty"`
+}
+
+type SatelliteConfigSpec struct {
+	Config config.Config `json:"config"`
+}
+
+type SatelliteConfigStatus struct {
+	SyncStatus
+}
+
+// Satellite is a satellite registered with Ground Control.
+type Satellite struct {
+	Metadata ObjectMeta      `json:"metadata"`
+	Spec     SatelliteSpec   `json:"spec"`
+	Status   SatelliteStatus `json:"status,omitempty"`
+}
+
+type SatelliteSpec struct {
+	// ConfigName names the SatelliteConfig the satellite runs.
+	ConfigName string            `json:"configName"`
+	Groups     []string          `json:"groups,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	// TokenSecretName is the Secret the ZTR token is written to, by default
+	// "<name>-ztr-token".
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+}
+
+// TokenSecret returns the name of the Secret of the ZTR token.
+func (s *Satellite) TokenSecret() string {
+	if s.Spec.TokenSecretName != "" {
+		return s.Spec.TokenSecretName
+	}
+	return s.Metadata.Name + "-ztr-token"
+}
+
+type SatelliteStatus struct {
+	SyncStatus
+	// Registered is set once the satellite exists in Ground Control.
+	Registered bool `json:"registered"`
+	// TokenSecret is the Secret holding the ZTR token of the registration.
+	// The token is single use and expires after 24 hours.
+	TokenSecret string `json:"tokenSecret,omitempty"`
+	// ConfigName and Groups are what Ground Control was last told, to
+	// compute the changes of a new spec.
+	ConfigName string   `json:"configName,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	// Heartbeat is "active" when the satellite reported within its
+	// heartbeat interval, "stale" when it stopped reporting and "unknown"
+	// before its first report.
+	Heartbeat     string     `json:"heartbeat,omitempty"`
+	LastSeen      *time.Time `json:"lastSeen,omitempty"`
+	LastSync      *time.Time `json:"lastSync,omitempty"`
+	LastSyncError string     `json:"lastSyncError,omitempty"`
+	Activity      string     `json:"activity,omitempty"`
+}
+
+// Heartbeat states of a satellite.
+const (
+	HeartbeatActive  = "active"
+	HeartbeatStale   = "stale"
+	HeartbeatUnknown = "unknown"
+)
+
+// list is the response of the API server to a list of custom resources.
+type list[T any] struct {
+	Items []T `json:"items"`
+}
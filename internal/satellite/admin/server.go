@@ -0,0 +1,309 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/satellite/hotreload"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/satellite/state"
+	"github.com/rs/zerolog"
+)
+
+const (
+	unixPrefix        = "unix://"
+	probeTimeout      = 2 * time.Second
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// StateSource exposes the replication state served by the admin API.
+// It is implemented by state.FetchAndReplicateStateProcess.
+type StateSource interface {
+	Snapshot() state.PersistedState
+	LastSync() state.SyncResult
+	GroupStatus(name string) (state.GroupStatus, bool)
+}
+
+// Options configures the admin server.
+type Options struct {
+	// Address is either a loopback host:port or a unix socket path of the
+	// form unix:///path/to/admin.sock.
+	Address string
+	// State provides the persisted groups, entities and sync results.
+	State StateSource
+	// ZTRDone reports whether zero-touch registration has completed.
+	ZTRDone func() bool
+	// RegistryURL returns the local registry URL probed for readiness.
+	RegistryURL func() string
+	// Schedulers returns the names of the running schedulers.
+	Schedulers func() []string
+	// Breakers returns the state of the dependency circuit breakers.
+	Breakers func() []scheduler.BreakerStatus
+	// Pending returns the config changes waiting for a restart.
+	PendingRestart func() []hotreload.PendingChange
+}
+
+// Server is the satellite's local admin HTTP API.
+type Server struct {
+	opts   Options
+	log    zerolog.Logger
+	client *http.Client
+}
+
+// CheckResult is the outcome of a single health or readiness check.
+type CheckResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HealthResponse is returned by /healthz and /readyz.
+type HealthResponse struct {
+	Status     string                 `json:"status"`
+	Checks     map[string]CheckResult `json:"checks"`
+	Schedulers []string               `json:"schedulers,omitempty"`
+}
+
+// StateResponse is returned by /v1/state.
+type StateResponse struct {
+	ConfigDigest string                      `json:"config_digest,omitempty"`
+	Groups       []state.PersistedGroupState `json:"groups"`
+	LastSync     state.SyncResult            `json:"last_sync"`
+	Breakers     []scheduler.BreakerStatus   `json:"breakers,omitempty"`
+	// PendingRestart lists reloaded config changes that need a restart.
+	PendingRestart []hotreload.PendingChange `json:"pending_restart,omitempty"`
+}
+
+// NewServer creates an admin server. The address must be a unix socket or a
+// loopback address so the API is never reachable from outside the host.
+func NewServer(opts Options, log zerolog.Logger) (*Server, error) {
+	if err := validateAddress(opts.Address); err != nil {
+		return nil, err
+	}
+	if opts.State == nil {
+		return nil, errors.New("admin server requires a state source")
+	}
+	return &Server{
+		opts:   opts,
+		log:    log,
+		client: &http.Client{Timeout: probeTimeout},
+	}, nil
+}
+
+// Handler returns the HTTP handler serving the admin API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.healthzHandler)
+	mux.HandleFunc("GET /readyz", s.readyzHandler)
+	mux.HandleFunc("GET /v1/state", s.stateHandler)
+	mux.HandleFunc("GET /v1/groups/{name}/synced", s.groupSyncedHandler)
+	return mux
+}
+
+// Run serves the admin API until the context is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := listen(s.opts.Address)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.log.Info().Str("address", s.opts.Address).Msg("Admin API listening")
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			s.log.Warn().Err(err).Msg("Admin API shutdown failed")
+		}
+		return nil
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("admin API server: %w", err)
+	}
+}
+
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]CheckResult{
+		"registry": s.checkRegistry(r.Context()),
+	}
+	s.writeHealth(w, checks)
+}
+
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]CheckResult{
+		"registry":  s.checkRegistry(r.Context()),
+		"ztr":       s.checkZTR(),
+		"last_sync": s.checkLastSync(),
+	}
+	s.writeHealth(w, checks)
+}
+
+func (s *Server) stateHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot := s.opts.State.Snapshot()
+	resp := StateResponse{
+		ConfigDigest: snapshot.ConfigDigest,
+		Groups:       snapshot.Groups,
+		LastSync:     s.opts.State.LastSync(),
+	}
+	if s.opts.Breakers != nil {
+		resp.Breakers = s.opts.Breakers()
+	}
+	if s.opts.PendingRestart != nil {
+		resp.PendingRestart = s.opts.PendingRestart()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) groupSyncedHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	status, ok := s.opts.State.GroupStatus(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("group %q not found", name)})
+		return
+	}
+	code := http.StatusOK
+	if !status.Synced {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+func (s *Server) writeHealth(w http.ResponseWriter, checks map[string]CheckResult) {
+	resp := HealthResponse{Status: "ok", Checks: checks}
+	if s.opts.Schedulers != nil {
+		resp.Schedulers = s.opts.Schedulers()
+	}
+	code := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, code, resp)
+}
+
+func (s *Server) checkRegistry(ctx context.Context) CheckResult {
+	if s.opts.RegistryURL == nil {
+		return CheckResult{OK: true}
+	}
+	registryURL := s.opts.RegistryURL()
+	if registryURL == "" {
+		return CheckResult{Error: "local registry URL is not configured"}
+	}
+	if !strings.HasPrefix(registryURL, "http://") && !strings.HasPrefix(registryURL, "https://") {
+		registryURL = "http://" + registryURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(registryURL, "/")+"/v2/", nil)
+	if err != nil {
+		return CheckResult{Error: err.Error()}
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return CheckResult{Error: err.Error()}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// Any non-5xx answer (including 401 from an authenticated registry)
+	// means the registry is up and serving the distribution API.
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return CheckResult{Error: fmt.Sprintf("registry returned status %d", resp.StatusCode)}
+	}
+	return CheckResult{OK: true}
+}
+
+func (s *Server) checkZTR() CheckResult {
+	if s.opts.ZTRDone == nil || s.opts.ZTRDone() {
+		return CheckResult{OK: true}
+	}
+	return CheckResult{Error: "zero-touch registration not completed"}
+}
+
+func (s *Server) checkLastSync() CheckResult {
+	last := s.opts.State.LastSync()
+	switch {
+	case last.Time.IsZero():
+		return CheckResult{Error: "no sync completed yet"}
+	case last.Error != "":
+		return CheckResult{Error: last.Error}
+	default:
+		return CheckResult{OK: true}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func validateAddress(address string) error {
+	if address == "" {
+		return errors.New("admin address is empty")
+	}
+	if path, ok := strings.CutPrefix(address, unixPrefix); ok {
+		if path == "" {
+			return errors.New("admin unix socket path is empty")
+		}
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid admin address %q: %w", address, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("admin address %q must be a loopback address or a unix socket", address)
+	}
+	return nil
+}
+
+func listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixPrefix)
+	if !ok {
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("listen on %s: %w", address, err)
+		}
+		return ln, nil
+	}
+
+	// Remove a stale socket left behind by an unclean shutdown.
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale admin socket %s: %w", path, err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen on unix socket %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("chmod admin socket %s: %w", path, err)
+	}
+	return ln, nil
+}
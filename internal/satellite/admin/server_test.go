@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/satellite/state"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeState struct {
+	snapshot state.PersistedState
+	lastSync state.SyncResult
+	groups   map[string]state.GroupStatus
+}
+
+func (f *fakeState) Snapshot() state.PersistedState { return f.snapshot }
+func (f *fakeState) LastSync() state.SyncResult     { return f.lastSync }
+func (f *fakeState) GroupStatus(name string) (state.GroupStatus, bool) {
+	g, ok := f.groups[name]
+	return g, ok
+}
+
+func newTestServer(t *testing.T, st *fakeState, registryURL string, ztrDone bool) *httptest.Server {
+	t.Helper()
+	s, err := NewServer(Options{
+		Address:     "127.0.0.1:0",
+		State:       st,
+		ZTRDone:     func() bool { return ztrDone },
+		RegistryURL: func() string { return registryURL },
+		Schedulers:  func() []string { return []string{"replicate_state"} },
+	}, zerolog.Nop())
+	require.NoError(t, err)
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newRegistry(t *testing.T, code int) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v2/", r.URL.Path)
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{"127.0.0.1:9090", false},
+		{"[::1]:9090", false},
+		{"localhost:9090", false},
+		{"unix:///run/satellite/admin.sock", false},
+		{"0.0.0.0:9090", true},
+		{"10.0.0.1:9090", true},
+		{"unix://", true},
+		{"", true},
+		{"not-an-address", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := validateAddress(tt.address)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	okSync := state.SyncResult{Time: time.Now()}
+
+	tests := []struct {
+		name     string
+		regCode  int
+		ztrDone  bool
+		lastSync state.SyncResult
+		wantCode int
+		failing  string
+	}{
+		{"all ready", http.StatusOK, true, okSync, http.StatusOK, ""},
+		{"registry requires auth", http.StatusUnauthorized, true, okSync, http.StatusOK, ""},
+		{"registry down", http.StatusBadGateway, true, okSync, http.StatusServiceUnavailable, "registry"},
+		{"ztr pending", http.StatusOK, false, okSync, http.StatusServiceUnavailable, "ztr"},
+		{"no sync yet", http.StatusOK, true, state.SyncResult{}, http.StatusServiceUnavailable, "last_sync"},
+		{"last sync failed", http.StatusOK, true, state.SyncResult{Time: time.Now(), Error: "boom"}, http.StatusServiceUnavailable, "last_sync"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, &fakeState{lastSync: tt.lastSync}, newRegistry(t, tt.regCode), tt.ztrDone)
+
+			resp, err := http.Get(srv.URL + "/readyz")
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+			require.Equal(t, tt.wantCode, resp.StatusCode)
+
+			var body HealthResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			require.Equal(t, []string{"replicate_state"}, body.Schedulers)
+			if tt.failing != "" {
+				require.False(t, body.Checks[tt.failing].OK)
+				require.Equal(t, "unavailable", body.Status)
+			}
+		})
+	}
+}
+
+func TestHealthzIgnoresSyncAndZTR(t *testing.T) {
+	srv := newTestServer(t, &fakeState{}, newRegistry(t, http.StatusOK), false)
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestStateEndpoint(t *testing.T) {
+	st := &fakeState{
+		snapshot: state.PersistedState{
+			ConfigDigest: "sha256:abc",
+			Groups: []state.PersistedGroupState{{
+				URL:      "harbor/satellite/group-state/edge/state:latest",
+				Entities: []state.Entity{{Name: "nginx", Repository: "library", Tag: "latest", Digest: "sha256:1"}},
+			}},
+		},
+	}
+	srv := newTestServer(t, st, newRegistry(t, http.StatusOK), true)
+
+	resp, err := http.Get(srv.URL + "/v1/state")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body StateResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "sha256:abc", body.ConfigDigest)
+	require.Len(t, body.Groups, 1)
+	require.Equal(t, "nginx", body.Groups[0].Entities[0].Name)
+}
+
+func TestGroupSyncedEndpoint(t *testing.T) {
+	st := &fakeState{groups: map[string]state.GroupStatus{
+		"edge":    {Name: "edge", Synced: true},
+		"pending": {Name: "pending", Synced: false, LastError: "pull failed"},
+	}}
+	srv := newTestServer(t, st, newRegistry(t, http.StatusOK), true)
+
+	tests := []struct {
+		group    string
+		wantCode int
+	}{
+		{"edge", http.StatusOK},
+		{"pending", http.StatusServiceUnavailable},
+		{"missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.group, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + "/v1/groups/" + tt.group + "/synced")
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+			require.Equal(t, tt.wantCode, resp.StatusCode)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package runtime
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,15 +16,17 @@ const (
 	containerdConfigPath = "/etc/containerd/config.toml"
 )
 
-// setContainerdConfig writes hosts.toml for multiple upstream registries and updates containerd registry plugin
-func setContainerdConfig(upstreamRegistries []string, localMirror string) (string, error) {
-	backupPath, err := configureContainerd(containerdCertsDir)
+// setContainerdConfig writes hosts.toml for multiple upstream registries and
+// updates containerd registry plugin. owned reports the mirror locations the
+// satellite wrote before, which are replaced.
+func setContainerdConfig(paths criPaths, upstreamRegistries []string, endpoints []MirrorEndpoint, owned func(string) bool) (string, error) {
+	backupPath, err := configureContainerd(paths.containerdConfig, paths.containerdCertsDir)
 	if err != nil {
 		return backupPath, fmt.Errorf("failed to configure registry plugin: %w", err)
 	}
 
 	for _, registryURL := range upstreamRegistries {
-		if err := writeContainerdHostToml(registryURL, localMirror); err != nil {
+		if err := writeContainerdHostToml(paths.containerdCertsDir, registryURL, endpoints, owned); err != nil {
 			return backupPath, fmt.Errorf("failed to configure containerd for %s: %w", registryURL, err)
 		}
 	}
@@ -30,9 +34,11 @@ func setContainerdConfig(upstreamRegistries []string, localMirror string) (strin
 	return backupPath, nil
 }
 
-// writeContainerdHostToml creates or updates hosts.toml for a registry
-func writeContainerdHostToml(registryURL, localMirror string) error {
-	dir := filepath.Join(containerdCertsDir, registryURL)
+// writeContainerdHostToml creates or updates hosts.toml for a registry.
+// containerd tries the hosts in file order, so the mirrors are written first,
+// in order, followed by the hosts added by hand.
+func writeContainerdHostToml(certsDir, registryURL string, endpoints []MirrorEndpoint, owned func(string) bool) error {
+	dir := filepath.Join(certsDir, registryURL)
 	if err := os.MkdirAll(dir, 0o750); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
@@ -44,30 +50,57 @@ func writeContainerdHostToml(registryURL, localMirror string) error {
 	path := filepath.Join(dir, "hosts.toml")
 
 	// backup existing hosts.toml before overwrite
-	bkPath, err := backupFile(path)
-	if err != nil {
+	if _, err := backupFile(path); err != nil {
 		return fmt.Errorf("failed to backup %s: %w", path, err)
 	}
 
-	var cfg ContainerdHosts
-
-	if _, err := os.Stat(path); err == nil {
-		if _, err := toml.DecodeFile(path, &cfg); err != nil {
+	cfg := map[string]any{}
+	var order []string
+	data, err := os.ReadFile(filepath.Clean(path))
+	switch {
+	case err == nil:
+		md, err := toml.Decode(string(data), &cfg)
+		if err != nil {
 			return fmt.Errorf("failed to parse %s: %w", path, err)
 		}
+		for _, key := range md.Keys() {
+			if len(key) == 2 && key[0] == "host" {
+				order = append(order, key[1])
+			}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	existing, _ := cfg["host"].(map[string]any)
+	delete(cfg, "host")
+	cfg["server"] = registryURL
+
+	var b bytes.Buffer
+	enc := toml.NewEncoder(&b)
+	enc.Indent = ""
+	if err := enc.Encode(cfg); err != nil {
+		return fmt.Errorf("failed to encode hosts.toml: %w", err)
+	}
+	written := make(map[string]bool)
+	credentials := false
+	for _, m := range endpoints {
+		key := m.apiURL()
+		if written[key] {
+			continue
+		}
+		written[key] = true
+		credentials = credentials || len(m.Header) > 0
+		if err := encodeHostTable(&b, key, containerdHost(m)); err != nil {
+			return fmt.Errorf("failed to encode hosts.toml: %w", err)
+		}
 	}
-
-	if cfg.Host == nil {
-		cfg.Host = make(map[string]Host)
-	}
-	cfg.Server = registryURL
-
-	if !strings.HasPrefix(localMirror, "http://") && !strings.HasPrefix(localMirror, "https://") {
-		localMirror = "http://" + localMirror
-	}
-
-	cfg.Host[localMirror] = Host{
-		Capabilities: []string{"pull", "resolve"},
+	for _, key := range order {
+		if written[key] || owned(mirrorHost(key)) {
+			continue
+		}
+		if err := encodeHostTable(&b, key, existing[key]); err != nil {
+			return fmt.Errorf("failed to encode hosts.toml: %w", err)
+		}
 	}
 
 	f, err := os.Create(filepath.Clean(path))
@@ -77,27 +110,60 @@ func writeContainerdHostToml(registryURL, localMirror string) error {
 	defer func() {
 		_ = f.Close()
 	}()
-
-	if err := toml.NewEncoder(f).Encode(cfg); err != nil {
-		if bkPath != "" {
-			if restoreErr := restoreBackup(bkPath, path); restoreErr != nil {
-				return fmt.Errorf("failed to encode hosts.toml and rollback failed: %w", restoreErr)
-			}
+	if credentials {
+		// The file now carries headers, which may hold credentials.
+		if err := f.Chmod(0o600); err != nil {
+			return fmt.Errorf("failed to restrict permissions of %s: %w", path, err)
 		}
-		return fmt.Errorf("failed to encode hosts.toml, rolled back: %w", err)
+	}
+	if _, err := f.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
 	}
 
 	return nil
 }
 
+// containerdHost is the hosts.toml entry of a mirror.
+func containerdHost(m MirrorEndpoint) Host {
+	host := Host{
+		Capabilities: []string{"pull", "resolve"},
+		SkipVerify:   m.SkipVerify,
+		Header:       m.Header,
+		OverridePath: m.PathPrefix != "",
+	}
+	if m.CAFile != "" {
+		host.CA = m.CAFile
+	}
+	switch {
+	case m.ClientCertFile != "" && m.ClientKeyFile != "":
+		host.Client = [][]string{{m.ClientCertFile, m.ClientKeyFile}}
+	case m.ClientCertFile != "":
+		host.Client = m.ClientCertFile
+	}
+	return host
+}
+
+// encodeHostTable appends the [host."key"] table to b.
+func encodeHostTable(b *bytes.Buffer, key string, host any) error {
+	var table bytes.Buffer
+	enc := toml.NewEncoder(&table)
+	enc.Indent = ""
+	if err := enc.Encode(map[string]any{"host": map[string]any{key: host}}); err != nil {
+		return err
+	}
+	b.WriteString("\n")
+	b.Write(bytes.TrimPrefix(table.Bytes(), []byte("[host]\n")))
+	return nil
+}
+
 // configureContainerd updates only the registry config path in containerd main config
-func configureContainerd(certDir string) (string, error) {
-	bkPath, err := backupFile(containerdConfigPath)
+func configureContainerd(configPath, certDir string) (string, error) {
+	bkPath, err := backupFile(configPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to backup containerd config: %w", err)
 	}
 
-	cfg, err := loadToml(containerdConfigPath)
+	cfg, err := loadToml(configPath)
 	if err != nil {
 		return bkPath, err
 	}
@@ -109,9 +175,9 @@ func configureContainerd(certDir string) (string, error) {
 
 	registryMap["config_path"] = certDir
 
-	f, err := os.Create(containerdConfigPath)
+	f, err := os.Create(configPath)
 	if err != nil {
-		return bkPath, fmt.Errorf("failed to open %s for writing: %w", containerdConfigPath, err)
+		return bkPath, fmt.Errorf("failed to open %s for writing: %w", configPath, err)
 	}
 	defer func() {
 		_ = f.Close()
@@ -119,7 +185,7 @@ func configureContainerd(certDir string) (string, error) {
 
 	if err := toml.NewEncoder(f).Encode(cfg); err != nil {
 		if bkPath != "" {
-			if restoreErr := restoreBackup(bkPath, containerdConfigPath); restoreErr != nil {
+			if restoreErr := restoreBackup(bkPath, configPath); restoreErr != nil {
 				return bkPath, fmt.Errorf("failed to write containerd config and rollback failed: %w", restoreErr)
 			}
 		}
@@ -129,6 +195,17 @@ func configureContainerd(certDir string) (string, error) {
 	return bkPath, nil
 }
 
+// registryConfigPath returns the registry config_path set in the
+// containerd config at configPath, or "" when it is unset or unreadable.
+func registryConfigPath(configPath string) string {
+	cfg, err := loadToml(configPath)
+	if err != nil {
+		return ""
+	}
+	path, _ := nestedValue(cfg, "plugins", "io.containerd.cri.v1.images", "registry", "config_path").(string)
+	return path
+}
+
 // loadToml loads existing TOML into a flexible type
 func loadToml(path string) (map[string]any, error) {
 	cfg := make(map[string]any)
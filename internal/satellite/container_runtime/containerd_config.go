@@ -3,13 +3,14 @@ package runtime
 // Host represents a registry host entry in a hosts.toml file.
 type Host struct {
 	Capabilities []string `toml:"capabilities"`
-}
-
-// ContainerdHosts represents the structure of a hosts.toml file.
-type ContainerdHosts struct {
-	Server  string          `toml:"server"`
-	Host    map[string]Host `toml:"host"`
-	Unknown map[string]any  `toml:",remain"` // do not touch parts we do not deal with
+	// CA is a CA bundle path, or a list of them.
+	CA any `toml:"ca,omitempty"`
+	// Client is a client certificate path holding the key too, or a list of
+	// [certificate, key] path pairs.
+	Client       any                 `toml:"client,omitempty"`
+	SkipVerify   bool                `toml:"skip_verify,omitempty"`
+	Header       map[string][]string `toml:"header,omitempty"`
+	OverridePath bool                `toml:"override_path,omitempty"`
 }
 
 type ContainerdConfig struct {
@@ -12,32 +12,37 @@ import (
 	"github.com/spf13/viper"
 )
 
-const (
-	tempRegistriesConfigPath = "/etc/containers/registries.toml"
-	registriesConfigPath     = "/etc/containers/registries.conf"
-)
-
-func setCrioConfig(upstreamRegistries []string, localMirror string) (string, error) {
-	if _, err := os.Stat(registriesConfigPath); os.IsNotExist(err) {
-		f, err := os.Create(registriesConfigPath)
+const registriesConfigPath = "/etc/containers/registries.conf"
+
+// setCrioConfig adds the mirrors to registries.conf, ahead of the mirrors
+// added by hand, in the order CRI-O tries them. owned reports the mirror
+// locations the satellite wrote before, which are replaced. Mirrors get
+// their CA and client certificate installed where containers/image looks
+// for them, and a secured local registry its pull credentials written to
+// the containers auth file CRI-O is pointed at.
+func setCrioConfig(paths criPaths, upstreamRegistries []string, endpoints []MirrorEndpoint, auth *MirrorAuth, owned func(string) bool) (string, error) {
+	registriesConf := paths.registriesConf
+	if _, err := os.Stat(registriesConf); os.IsNotExist(err) {
+		f, err := os.Create(registriesConf)
 		if err != nil {
 			return "", fmt.Errorf("error creating registries.conf: %w", err)
 		}
 		_ = f.Close()
 	}
 
-	bkPath, err := backupFile(registriesConfigPath)
+	bkPath, err := backupFile(registriesConf)
 	if err != nil {
 		return "", fmt.Errorf("failed to backup registries.conf: %w", err)
 	}
 
 	// viper fails to recognise .conf file extension, so copy into a temporary .toml file
-	if err := copyFile(registriesConfigPath, tempRegistriesConfigPath); err != nil {
+	tempRegistriesConf := strings.TrimSuffix(registriesConf, filepath.Ext(registriesConf)) + ".toml"
+	if err := copyFile(registriesConf, tempRegistriesConf); err != nil {
 		return bkPath, fmt.Errorf("failed to copy registries.conf file to temporary .toml file: %w", err)
 	}
 
 	v := viper.New()
-	v.SetConfigFile(tempRegistriesConfigPath)
+	v.SetConfigFile(tempRegistriesConf)
 	v.SetConfigType("toml")
 
 	if err := v.ReadInConfig(); err != nil {
@@ -52,43 +57,51 @@ func setCrioConfig(upstreamRegistries []string, localMirror string) (string, err
 		return bkPath, fmt.Errorf("failed to unmarshal registries.conf: %w", err)
 	}
 
-	insecure := !strings.HasPrefix(localMirror, "https://")
+	var mirrors []Mirror
+	for _, m := range endpoints {
+		if err := installCerts(paths.containersCertsDir, m); err != nil {
+			return bkPath, err
+		}
+		mirrors = append(mirrors, Mirror{Location: m.location(), Insecure: !m.https() || m.SkipVerify})
+	}
+	if auth.hasCredentials() {
+		if err := writeContainersAuth(paths.containersAuthFile, endpoints[0].host(), auth); err != nil {
+			return bkPath, err
+		}
+		if err := writeCrioAuthDropIn(paths.crioAuthDropIn, paths.containersAuthFile); err != nil {
+			return bkPath, err
+		}
+	}
 
 	for _, upstream := range upstreamRegistries {
 		idx := slices.IndexFunc(cfg.Registries, func(r Registry) bool {
 			return r.Location == upstream
 		})
-
-		if idx >= 0 {
-			r := &cfg.Registries[idx]
-			hasMirror := slices.ContainsFunc(r.Mirrors, func(m Mirror) bool {
-				return m.Location == localMirror
-			})
-			if !hasMirror {
-				r.Mirrors = append(r.Mirrors, Mirror{Location: localMirror, Insecure: insecure})
-			}
-		} else {
-			cfg.Registries = append(cfg.Registries, Registry{
-				Location: upstream,
-				Mirrors:  []Mirror{{Location: localMirror, Insecure: insecure}},
-			})
+		if idx < 0 {
+			cfg.Registries = append(cfg.Registries, Registry{Location: upstream})
+			idx = len(cfg.Registries) - 1
 		}
+		r := &cfg.Registries[idx]
+		handAdded := slices.DeleteFunc(r.Mirrors, func(m Mirror) bool {
+			return owned(m.Location) || slices.ContainsFunc(mirrors, func(own Mirror) bool { return own.Location == m.Location })
+		})
+		r.Mirrors = append(slices.Clone(mirrors), handAdded...)
 	}
 
 	v.Set("registry", cfg.Registries)
 
-	if err := v.WriteConfigAs(tempRegistriesConfigPath); err != nil {
+	if err := v.WriteConfigAs(tempRegistriesConf); err != nil {
 		return bkPath, fmt.Errorf("failed to write registries.conf: %w", err)
 	}
 
 	// validate TOML before committing
-	data, err := os.ReadFile(tempRegistriesConfigPath)
+	data, err := os.ReadFile(tempRegistriesConf)
 	if err != nil {
 		return bkPath, fmt.Errorf("failed to read temp registries file: %w", err)
 	}
 	if err := validateTOML(data); err != nil {
 		if bkPath != "" {
-			if restoreErr := restoreBackup(bkPath, registriesConfigPath); restoreErr != nil {
+			if restoreErr := restoreBackup(bkPath, registriesConf); restoreErr != nil {
 				return bkPath, fmt.Errorf("registries.conf validation failed and rollback failed: %w", restoreErr)
 			}
 		}
@@ -96,12 +109,12 @@ func setCrioConfig(upstreamRegistries []string, localMirror string) (string, err
 	}
 
 	// copy contents of temp file back into actual path
-	if err := copyFile(tempRegistriesConfigPath, registriesConfigPath); err != nil {
+	if err := copyFile(tempRegistriesConf, registriesConf); err != nil {
 		return bkPath, fmt.Errorf("failed to copy temporary .toml file to registries.conf: %w", err)
 	}
 
 	// cleanup: delete temporary file (non-fatal)
-	_ = os.Remove(tempRegistriesConfigPath)
+	_ = os.Remove(tempRegistriesConf)
 
 	return bkPath, nil
 }
@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -14,9 +15,23 @@ type CRIConfig struct {
 // CRIConfigResult holds the outcome of applying a single CRI config.
 type CRIConfigResult struct {
 	CRI        CRIType
+	Registries []string
 	BackupPath string
 	Success    bool
 	Error      string
+	// Warnings lists mirror settings the runtime does not support, which
+	// were left out.
+	Warnings []string
+	// Reloaded is set when the runtime was reloaded to pick up the config,
+	// ReloadError when that failed. RestartRequired is set when the runtime
+	// only picks up the config once it is restarted, which was not done.
+	Reloaded        bool
+	ReloadError     string
+	RestartRequired bool
+	// Verified is set when a probe image pulled through the runtime came
+	// from the local registry, VerifyError when the pull failed.
+	Verified    bool
+	VerifyError string
 }
 
 // ResolveCRIConfigs determines which CRI configs to apply.
@@ -74,23 +89,42 @@ func parseMirrorFlags(mirrors []string) ([]CRIConfig, error) {
 }
 
 // ApplyCRIConfigs applies the given CRI configs and returns results.
-// Errors are collected per-CRI rather than failing on the first error.
-func ApplyCRIConfigs(configs []CRIConfig, localRegistry string) []CRIConfigResult {
+// Errors are collected per-CRI rather than failing on the first error. auth
+// is nil for a local registry served over plain HTTP. The files are recorded
+// in the CRI journal, with a backup of their content from before the first
+// change, so they can be restored.
+func ApplyCRIConfigs(configs []CRIConfig, localRegistry string, auth *MirrorAuth, opts MirrorOptions) []CRIConfigResult {
+	return applyCRIConfigs(criJournalPath, systemCRIPaths, configs, localRegistry, auth, opts)
+}
+
+func applyCRIConfigs(journalPath string, paths criPaths, configs []CRIConfig, localRegistry string, auth *MirrorAuth, opts MirrorOptions) []CRIConfigResult {
 	var results []CRIConfigResult
+	endpoints := mirrorEndpoints(localRegistry, auth, opts)
 
 	for _, cfg := range configs {
-		result := CRIConfigResult{CRI: cfg.CRI}
+		result := CRIConfigResult{CRI: cfg.CRI, Registries: cfg.Registries, Warnings: unsupportedMirrorSettings(cfg.CRI, endpoints, auth)}
 
-		var backupPath string
-		var err error
+		j, err := recordCRIChanges(journalPath, paths, cfg, endpoints, auth)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to record the original config: %v", err)
+			results = append(results, result)
+			continue
+		}
 
+		var backupPath string
+		// restart is set when the runtime reads the changed config only at
+		// startup.
+		restart := false
 		switch cfg.CRI {
 		case CRIDocker:
-			backupPath, err = setDockerdConfig(cfg.Registries, localRegistry)
+			backupPath, err = setDockerdConfig(paths, cfg.Registries, endpoints, auth, j.isMirror, !opts.Reload)
 		case CRICrio, CRIPodman:
-			backupPath, err = setCrioConfig(cfg.Registries, localRegistry)
+			backupPath, err = setCrioConfig(paths, cfg.Registries, endpoints, auth, j.isMirror)
 		case CRIContainerd:
-			backupPath, err = setContainerdConfig(cfg.Registries, localRegistry)
+			restart = registryConfigPath(paths.containerdConfig) != paths.containerdCertsDir
+			backupPath, err = setContainerdConfig(paths, cfg.Registries, endpoints, j.isMirror)
+		case CRIK3s, CRIRKE2:
+			backupPath, restart, err = setRancherRegistries(paths.registriesPath(cfg.CRI), cfg.Registries, endpoints, auth, j.isMirror)
 		default:
 			err = fmt.Errorf("unsupported CRI: %s", cfg.CRI)
 		}
@@ -101,9 +135,32 @@ func ApplyCRIConfigs(configs []CRIConfig, localRegistry string) []CRIConfigResul
 		} else {
 			result.Success = true
 		}
+		if result.Success && opts.Reload {
+			result.Reloaded, err = reloadRuntime(context.Background(), cfg.CRI, restart)
+			if err != nil {
+				result.ReloadError = err.Error()
+			}
+		}
+		result.RestartRequired = result.Success && restart && !result.Reloaded
 
 		results = append(results, result)
 	}
 
 	return results
 }
+
+// unsupportedMirrorSettings lists the mirror settings cri cannot apply.
+func unsupportedMirrorSettings(cri CRIType, endpoints []MirrorEndpoint, auth *MirrorAuth) []string {
+	var warnings []string
+	for i, m := range endpoints {
+		// The credentials of the secured local registry go to the auth
+		// file of CRI-O and are refused for Docker.
+		if cri != CRIContainerd && m.customHeaders(i == 0 && auth.hasCredentials()) {
+			warnings = append(warnings, fmt.Sprintf("%s does not send custom headers to mirrors, headers of %s are ignored", cri, m.location()))
+		}
+		if cri == CRIDocker && m.PathPrefix != "" {
+			warnings = append(warnings, fmt.Sprintf("docker does not accept mirrors under a path, %s is skipped", m.location()))
+		}
+	}
+	return warnings
+}
@@ -13,8 +13,15 @@ const (
 	CRIContainerd CRIType = "containerd"
 	CRICrio       CRIType = "crio"
 	CRIPodman     CRIType = "podman"
+	// CRIK3s and CRIRKE2 are the containerd embedded in k3s and RKE2, whose
+	// config is generated from registries.yaml.
+	CRIK3s  CRIType = "k3s"
+	CRIRKE2 CRIType = "rke2"
 )
 
+// CRITypes lists every supported runtime.
+var CRITypes = []CRIType{CRIContainerd, CRICrio, CRIPodman, CRIDocker, CRIK3s, CRIRKE2}
+
 // DetectedCRI holds the detected CRI type and the reason it was detected.
 type DetectedCRI struct {
 	Type   CRIType
@@ -25,13 +32,18 @@ type criCheck struct {
 	criType CRIType
 	socket  string
 	binary  string
+	// dir is a directory only the runtime creates. k3s and RKE2 share the
+	// socket of their embedded containerd.
+	dir string
 }
 
 var criChecks = []criCheck{
-	{CRIDocker, "/var/run/docker.sock", "docker"},
-	{CRIContainerd, "/run/containerd/containerd.sock", "containerd"},
-	{CRICrio, "/var/run/crio/crio.sock", "crio"},
-	{CRIPodman, "", "podman"},
+	{CRIDocker, "/var/run/docker.sock", "docker", ""},
+	{CRIContainerd, "/run/containerd/containerd.sock", "containerd", ""},
+	{CRICrio, "/var/run/crio/crio.sock", "crio", ""},
+	{CRIPodman, "", "podman", ""},
+	{CRIK3s, "", "k3s", "/var/lib/rancher/k3s"},
+	{CRIRKE2, "", "rke2", "/var/lib/rancher/rke2"},
 }
 
 // statFunc matches os.Stat signature.
@@ -60,6 +72,17 @@ func detectWithCheckers(statFn statFunc, lookPathFn lookPathFunc) []DetectedCRI
 			}
 		}
 
+		if check.dir != "" {
+			if _, err := statFn(check.dir); err == nil {
+				detected = append(detected, DetectedCRI{
+					Type:   check.criType,
+					Reason: "found directory " + check.dir,
+				})
+
+				continue
+			}
+		}
+
 		if _, err := lookPathFn(check.binary); err == nil {
 			detected = append(detected, DetectedCRI{
 				Type:   check.criType,
@@ -41,6 +41,17 @@ func TestDetectWithCheckers(t *testing.T) {
 			wantTypes:   []CRIType{CRIDocker},
 			wantReasons: []string{"found socket /var/run/docker.sock"},
 		},
+		{
+			name:        "detects k3s and rke2 via their data directories",
+			sockets:     map[string]bool{"/var/lib/rancher/k3s": true, "/var/lib/rancher/rke2": true},
+			wantTypes:   []CRIType{CRIK3s, CRIRKE2},
+			wantReasons: []string{"found directory /var/lib/rancher/k3s", "found directory /var/lib/rancher/rke2"},
+		},
+		{
+			name:      "detects k3s via binary",
+			binaries:  map[string]bool{"k3s": true},
+			wantTypes: []CRIType{CRIK3s},
+		},
 		{
 			name:      "no CRIs detected",
 			wantTypes: nil,
@@ -8,7 +8,6 @@ import (
 	"os/exec"
 	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -19,7 +18,16 @@ const (
 	dockerRestartTimeout = 30 * time.Second
 )
 
-func setDockerdConfig(mirrors []string, localRegistry string) (string, error) {
+// setDockerdConfig adds the mirrors to the Docker daemon, ahead of the
+// mirrors added by hand, in the order it tries them. owned reports the
+// mirror locations the satellite wrote before, which are replaced. The
+// daemon cannot authenticate to mirrors, so a secured registry must allow
+// anonymous pulls. Mirrors get their CA and client certificate installed for
+// the daemon, and those whose certificate is not verified are listed as
+// insecure registries. Mirrors serving under a path are skipped, the daemon
+// rejects them. With restart set the daemon is restarted to pick up the
+// config, otherwise it is left to reloadRuntime.
+func setDockerdConfig(paths criPaths, mirrors []string, endpoints []MirrorEndpoint, auth *MirrorAuth, owned func(string) bool, restart bool) (string, error) {
 	if len(mirrors) == 0 {
 		return "", nil
 	}
@@ -31,58 +39,74 @@ func setDockerdConfig(mirrors []string, localRegistry string) (string, error) {
 		return "", nil
 	}
 
-	if !strings.HasPrefix(localRegistry, "http://") && !strings.HasPrefix(localRegistry, "https://") {
-		localRegistry = "http://" + localRegistry
+	if auth.hasCredentials() {
+		return "", errors.New("the Docker daemon cannot authenticate to registry mirrors, set secure_registry.anonymous_pull to mirror a secured registry")
+	}
+	var urls, insecure []string
+	for _, m := range endpoints {
+		if m.PathPrefix != "" {
+			continue
+		}
+		if err := installCerts(paths.dockerCertsDir, m); err != nil {
+			return "", err
+		}
+		urls = append(urls, m.URL)
+		if m.SkipVerify {
+			insecure = append(insecure, m.host())
+		}
 	}
 
-	backupPath, err := backupFile(dockerConfigPath)
+	dockerConfig := paths.dockerConfig
+	backupPath, err := backupFile(dockerConfig)
 	if err != nil {
 		return "", fmt.Errorf("failed to backup docker config: %w", err)
 	}
 
 	v := viper.New()
-	v.SetConfigFile(dockerConfigPath)
+	v.SetConfigFile(dockerConfig)
 	v.SetConfigType("json")
 
-	if err := ensureDockerConfigFileExists(dockerConfigPath); err != nil {
+	if err := ensureDockerConfigFileExists(dockerConfig); err != nil {
 		return backupPath, fmt.Errorf("failed to create default docker config: %w", err)
 	}
 
 	if err := v.ReadInConfig(); err != nil {
 		return backupPath, fmt.Errorf("failed to read docker config: %w", err)
 	}
-	currentMirrors := v.GetStringSlice("registry-mirrors")
-
-	if !slices.Contains(currentMirrors, localRegistry) {
-		currentMirrors = append(currentMirrors, localRegistry)
-		v.Set("registry-mirrors", currentMirrors)
+	v.Set("registry-mirrors", prependOwned(v.GetStringSlice("registry-mirrors"), urls, owned))
+	if registries := prependOwned(v.GetStringSlice("insecure-registries"), insecure, owned); len(registries) > 0 {
+		v.Set("insecure-registries", registries)
 	}
 
-	if err := v.WriteConfigAs(dockerConfigPath); err != nil {
+	if err := v.WriteConfigAs(dockerConfig); err != nil {
 		return backupPath, fmt.Errorf("failed to write docker config: %w", err)
 	}
 
 	// validate written config
-	data, err := os.ReadFile(dockerConfigPath)
+	data, err := os.ReadFile(dockerConfig)
 	if err != nil {
 		return backupPath, fmt.Errorf("failed to read back docker config: %w", err)
 	}
 	if err := validateJSON(data); err != nil {
 		if backupPath != "" {
-			if restoreErr := restoreBackup(backupPath, dockerConfigPath); restoreErr != nil {
+			if restoreErr := restoreBackup(backupPath, dockerConfig); restoreErr != nil {
 				return backupPath, fmt.Errorf("docker config validation failed and rollback failed: %w", errors.Join(err, restoreErr))
 			}
 		}
 		return backupPath, fmt.Errorf("docker config validation failed, rolled back: %w", err)
 	}
 
+	if !restart {
+		return backupPath, nil
+	}
+
 	// restart docker safely
 	ctx, cancel := context.WithTimeout(context.Background(), dockerRestartTimeout)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, "systemctl", "restart", "docker")
 	if err := cmd.Run(); err != nil {
 		if backupPath != "" {
-			if restoreErr := restoreBackup(backupPath, dockerConfigPath); restoreErr != nil {
+			if restoreErr := restoreBackup(backupPath, dockerConfig); restoreErr != nil {
 				return backupPath, fmt.Errorf("failed to restart Docker and rollback failed: %w", errors.Join(err, restoreErr))
 			}
 		}
@@ -101,3 +125,12 @@ func ensureDockerConfigFileExists(path string) error {
 	}
 	return nil
 }
+
+// prependOwned puts the entries the satellite writes ahead of those added by
+// hand, dropping the ones it wrote before.
+func prependOwned(current, own []string, owned func(string) bool) []string {
+	handAdded := slices.DeleteFunc(current, func(entry string) bool {
+		return owned(mirrorHost(entry)) || slices.Contains(own, entry)
+	})
+	return append(slices.Clone(own), handAdded...)
+}
@@ -1,6 +1,9 @@
 package runtime
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+)
 
 // Well-known image directories for Kubernetes distributions that support
 // automatic tarball import via filesystem watching.
@@ -10,7 +13,9 @@ var imageDirectories = []string{
 }
 
 // DetectImageDir returns the first existing well-known image directory
-// for k3s or RKE2. Returns an empty string if none is found.
+// for k3s or RKE2. When none exists yet, it returns the image directory of
+// the first k3s or RKE2 agent installed, which imports the tarballs once the
+// directory is created. Returns an empty string if none is found.
 func DetectImageDir() string {
 	return detectImageDir(os.Stat)
 }
@@ -21,6 +26,11 @@ func detectImageDir(stat statFunc) string {
 			return dir
 		}
 	}
+	for _, dir := range imageDirectories {
+		if info, err := stat(filepath.Dir(dir)); err == nil && info.IsDir() {
+			return dir
+		}
+	}
 
 	return ""
 }
@@ -30,6 +30,19 @@ func TestDetectImageDir(t *testing.T) {
 			},
 			want: "/var/lib/rancher/k3s/agent/images",
 		},
+		{
+			name: "image dir of an installed agent not created yet",
+			dirs: map[string]bool{"/var/lib/rancher/rke2/agent": true},
+			want: "/var/lib/rancher/rke2/agent/images",
+		},
+		{
+			name: "prefers an existing image dir",
+			dirs: map[string]bool{
+				"/var/lib/rancher/k3s/agent":         true,
+				"/var/lib/rancher/rke2/agent/images": true,
+			},
+			want: "/var/lib/rancher/rke2/agent/images",
+		},
 		{
 			name: "returns empty when none found",
 			dirs: nil,
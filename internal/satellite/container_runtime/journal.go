@@ -0,0 +1,214 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// criJournalPath is where the files changed to point the runtimes at the
+// local mirror are recorded. It lives outside the satellite config directory
+// because the runtime files it refers to are host-wide.
+const criJournalPath = "/var/lib/harbor-satellite/cri-journal.json"
+
+// JournalEntry is a file the satellite changed to point a runtime at the
+// local mirror.
+type JournalEntry struct {
+	CRI  CRIType `json:"cri"`
+	Path string  `json:"path"`
+	// Backup is a copy of the file taken before the satellite first changed
+	// it, empty when the satellite created the file.
+	Backup     string    `json:"backup,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Journal records every file the satellite changed in the runtime configs,
+// with the content it had before, and the mirror hosts it wrote, so the
+// changes can be restored or removed when the node is decommissioned.
+type Journal struct {
+	Mirrors []string       `json:"mirrors"`
+	Entries []JournalEntry `json:"entries"`
+}
+
+// LoadJournal reads the journal at path, or returns an empty one when the
+// satellite never changed a runtime config.
+func LoadJournal(path string) (*Journal, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Journal{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read CRI journal: %w", err)
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("parse CRI journal %s: %w", path, err)
+	}
+	return &j, nil
+}
+
+// Save writes the journal to path, or removes it once it is empty.
+func (j *Journal) Save(path string) error {
+	if len(j.Entries) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove CRI journal: %w", err)
+		}
+		// Fails, as intended, while the directory holds other files.
+		_ = os.Remove(filepath.Dir(path))
+		return nil
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode CRI journal: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create directory for CRI journal: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write CRI journal: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Entry returns the entry recorded for path.
+func (j *Journal) Entry(path string) (JournalEntry, bool) {
+	i := slices.IndexFunc(j.Entries, func(e JournalEntry) bool { return e.Path == path })
+	if i < 0 {
+		return JournalEntry{}, false
+	}
+	return j.Entries[i], true
+}
+
+// record backs up path before the satellite first changes it. Later changes
+// keep the first backup, which holds the content from before the satellite.
+func (j *Journal) record(cri CRIType, path string) (bool, error) {
+	if _, ok := j.Entry(path); ok {
+		return false, nil
+	}
+	backup, err := backupFile(path)
+	if err != nil {
+		return false, err
+	}
+	j.Entries = append(j.Entries, JournalEntry{CRI: cri, Path: path, Backup: backup, RecordedAt: time.Now().UTC()})
+	return true, nil
+}
+
+// addMirror records a mirror host the runtimes were pointed at.
+func (j *Journal) addMirror(host string) bool {
+	if slices.Contains(j.Mirrors, host) {
+		return false
+	}
+	j.Mirrors = append(j.Mirrors, host)
+	return true
+}
+
+// isMirror reports whether location, with or without a scheme, is a mirror
+// the satellite wrote.
+func (j *Journal) isMirror(location string) bool {
+	return slices.Contains(j.Mirrors, mirrorHost(location))
+}
+
+// criPaths are the files the mirror config of each runtime lives in.
+type criPaths struct {
+	containerdConfig   string
+	containerdCertsDir string
+	registriesConf     string
+	containersCertsDir string
+	containersAuthFile string
+	crioAuthDropIn     string
+	dockerConfig       string
+	dockerCertsDir     string
+	k3sRegistries      string
+	rke2Registries     string
+}
+
+var systemCRIPaths = criPaths{
+	containerdConfig:   containerdConfigPath,
+	containerdCertsDir: containerdCertsDir,
+	registriesConf:     registriesConfigPath,
+	containersCertsDir: containersCertsDir,
+	containersAuthFile: containersAuthFile,
+	crioAuthDropIn:     crioAuthDropIn,
+	dockerConfig:       dockerConfigPath,
+	dockerCertsDir:     dockerCertsDir,
+	k3sRegistries:      k3sRegistriesPath,
+	rke2Registries:     rke2RegistriesPath,
+}
+
+// files lists the files applying cfg changes.
+func (p criPaths) files(cfg CRIConfig, endpoints []MirrorEndpoint, auth *MirrorAuth) []string {
+	// certFiles are the CA and client certificates installed for mirrors.
+	certFiles := func(certsDir string) []string {
+		var files []string
+		for _, m := range endpoints {
+			if m.CAFile != "" {
+				files = append(files, filepath.Join(certsDir, m.host(), "ca.crt"))
+			}
+			if m.ClientCertFile != "" {
+				files = append(files, filepath.Join(certsDir, m.host(), "client.cert"), filepath.Join(certsDir, m.host(), "client.key"))
+			}
+		}
+		return files
+	}
+
+	switch cfg.CRI {
+	case CRIContainerd:
+		files := []string{p.containerdConfig}
+		for _, registry := range cfg.Registries {
+			files = append(files, filepath.Join(p.containerdCertsDir, registry, "hosts.toml"))
+		}
+		return files
+	case CRICrio, CRIPodman:
+		files := append([]string{p.registriesConf}, certFiles(p.containersCertsDir)...)
+		if auth.hasCredentials() {
+			files = append(files, p.containersAuthFile, p.crioAuthDropIn)
+		}
+		return files
+	case CRIDocker:
+		if len(cfg.Registries) == 0 {
+			return nil
+		}
+		if enabled, err := strconv.ParseBool(cfg.Registries[0]); err != nil || !enabled {
+			return nil
+		}
+		return append([]string{p.dockerConfig}, certFiles(p.dockerCertsDir)...)
+	case CRIK3s, CRIRKE2:
+		return []string{p.registriesPath(cfg.CRI)}
+	}
+	return nil
+}
+
+// recordCRIChanges adds the files applying cfg changes and the mirrors to
+// the journal at journalPath, backing up the files it has not seen yet. It
+// returns the journal, whose mirrors include those written before.
+func recordCRIChanges(journalPath string, paths criPaths, cfg CRIConfig, endpoints []MirrorEndpoint, auth *MirrorAuth) (*Journal, error) {
+	files := paths.files(cfg, endpoints, auth)
+	if len(files) == 0 {
+		return &Journal{}, nil
+	}
+	j, err := LoadJournal(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	for _, m := range endpoints {
+		changed = j.addMirror(m.location()) || changed
+	}
+	for _, path := range files {
+		added, err := j.record(cfg.CRI, path)
+		if err != nil {
+			return nil, err
+		}
+		changed = changed || added
+	}
+	if !changed {
+		return j, nil
+	}
+	return j, j.Save(journalPath)
+}
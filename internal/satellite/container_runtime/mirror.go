@@ -0,0 +1,98 @@
+package runtime
+
+import (
+	"maps"
+	"strings"
+)
+
+// MirrorEndpoint is a registry mirror and how runtimes reach it.
+type MirrorEndpoint struct {
+	// URL is the scheme and host of the mirror. Without a scheme the mirror
+	// is reached over plain HTTP.
+	URL string
+	// PathPrefix is the namespace the mirror serves the upstream registry
+	// under, below /v2, e.g. "/docker.io" for /v2/docker.io/library/nginx.
+	PathPrefix     string
+	CAFile         string
+	ClientCertFile string
+	ClientKeyFile  string
+	SkipVerify     bool
+	Header         map[string][]string
+}
+
+// MirrorOptions are the settings of the local mirror and the mirrors
+// runtimes try after it.
+type MirrorOptions struct {
+	// Local is merged with the settings of the secured local registry. Its
+	// URL is ignored.
+	Local     MirrorEndpoint
+	Fallbacks []MirrorEndpoint
+	// Reload reloads the runtimes once their configs are written. Without
+	// it Docker is restarted and the other runtimes are left alone.
+	Reload bool
+}
+
+// host returns the host of the mirror, without scheme and path.
+func (m MirrorEndpoint) host() string {
+	return mirrorHost(m.URL)
+}
+
+// location returns the host and path prefix, as registries.conf and the
+// CRI journal refer to the mirror.
+func (m MirrorEndpoint) location() string {
+	return m.host() + m.PathPrefix
+}
+
+// apiURL returns the URL the registry API of the mirror is rooted at, for
+// runtimes that replace /v2 with the path of the mirror.
+func (m MirrorEndpoint) apiURL() string {
+	if m.PathPrefix == "" {
+		return m.URL
+	}
+	return m.URL + "/v2" + m.PathPrefix
+}
+
+// https reports whether the mirror is reached over TLS.
+func (m MirrorEndpoint) https() bool {
+	return strings.HasPrefix(m.URL, "https://")
+}
+
+// mirrorEndpoints returns the mirrors runtimes try, in order: the local
+// registry, then the fallback mirrors. The local registry is reached over
+// HTTPS when it is secured, its URL says so or TLS settings are given for
+// it.
+func mirrorEndpoints(localMirror string, auth *MirrorAuth, opts MirrorOptions) []MirrorEndpoint {
+	local := opts.Local
+	local.Header = maps.Clone(local.Header)
+	tls := auth != nil || strings.HasPrefix(localMirror, "https://") ||
+		local.CAFile != "" || local.ClientCertFile != "" || local.SkipVerify
+	if tls {
+		local.URL = "https://" + mirrorHost(localMirror)
+	} else {
+		local.URL = "http://" + mirrorHost(localMirror)
+	}
+	if auth != nil {
+		if auth.CAFile != "" {
+			local.CAFile = auth.CAFile
+		}
+		if auth.hasCredentials() {
+			if local.Header == nil {
+				local.Header = map[string][]string{}
+			}
+			local.Header["Authorization"] = []string{"Basic " + auth.basicAuth()}
+		}
+	}
+
+	return append([]MirrorEndpoint{local}, opts.Fallbacks...)
+}
+
+// customHeaders reports whether m sends headers other than the credentials
+// of the secured local registry, which only containerd supports.
+func (m MirrorEndpoint) customHeaders(credentials bool) bool {
+	for name := range m.Header {
+		if name != "Authorization" || !credentials {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+	"github.com/containerd/containerd/v2/core/remotes/docker/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+func TestMirrorEndpoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       *MirrorAuth
+		opts       MirrorOptions
+		wantURL    string
+		wantCA     string
+		wantHeader bool
+	}{
+		{
+			name:    "plain local registry",
+			wantURL: "http://" + testMirror,
+		},
+		{
+			name:       "secured local registry",
+			auth:       &MirrorAuth{CAFile: "/ca.pem", Username: "pull", Password: "secret"},
+			opts:       MirrorOptions{Local: MirrorEndpoint{CAFile: "/other.pem"}},
+			wantURL:    "https://" + testMirror,
+			wantCA:     "/ca.pem",
+			wantHeader: true,
+		},
+		{
+			name:    "TLS settings for the local mirror",
+			opts:    MirrorOptions{Local: MirrorEndpoint{URL: "http://ignored:1", SkipVerify: true}},
+			wantURL: "https://" + testMirror,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := MirrorEndpoint{URL: "https://mirror.example.com", PathPrefix: "/docker.io"}
+			tt.opts.Fallbacks = []MirrorEndpoint{fallback}
+
+			endpoints := mirrorEndpoints(testMirror, tt.auth, tt.opts)
+			if len(endpoints) != 2 {
+				t.Fatalf("got %d endpoints, want 2", len(endpoints))
+			}
+			local := endpoints[0]
+			if local.URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", local.URL, tt.wantURL)
+			}
+			if local.CAFile != tt.wantCA {
+				t.Errorf("CAFile = %q, want %q", local.CAFile, tt.wantCA)
+			}
+			if _, ok := local.Header["Authorization"]; ok != tt.wantHeader {
+				t.Errorf("Authorization header set = %t, want %t", ok, tt.wantHeader)
+			}
+			if endpoints[1].location() != "mirror.example.com/docker.io" {
+				t.Errorf("fallback location = %q", endpoints[1].location())
+			}
+		})
+	}
+}
+
+func TestWriteContainerdHostToml(t *testing.T) {
+	certsDir := t.TempDir()
+	path := filepath.Join(certsDir, "docker.io", "hosts.toml")
+	writeTestFile(t, path, `server = "https://registry-1.docker.io"
+
+[host."https://hand.example.com"]
+capabilities = ["pull"]
+
+[host."http://stale.example.com:5000"]
+capabilities = ["pull", "resolve"]
+`)
+
+	endpoints := mirrorEndpoints(testMirror, nil, MirrorOptions{
+		Local: MirrorEndpoint{CAFile: "/ca.pem", ClientCertFile: "/client.pem", ClientKeyFile: "/client.key"},
+		Fallbacks: []MirrorEndpoint{{
+			URL:        "https://mirror.example.com",
+			PathPrefix: "/docker.io",
+			SkipVerify: true,
+			Header:     map[string][]string{"X-Tenant": {"edge"}},
+		}},
+	})
+	owned := func(location string) bool { return location == "stale.example.com:5000" }
+	if err := writeContainerdHostToml(certsDir, "docker.io", endpoints, owned); err != nil {
+		t.Fatalf("writeContainerdHostToml: %v", err)
+	}
+
+	data := readTestFile(t, path)
+	var cfg struct {
+		Server string          `toml:"server"`
+		Host   map[string]Host `toml:"host"`
+	}
+	md, err := toml.Decode(data, &cfg)
+	if err != nil {
+		t.Fatalf("decode hosts.toml: %v\n%s", err, data)
+	}
+	if cfg.Server != "https://docker.io" {
+		t.Errorf("server = %q", cfg.Server)
+	}
+
+	var order []string
+	for _, key := range md.Keys() {
+		if len(key) == 2 && key[0] == "host" {
+			order = append(order, key[1])
+		}
+	}
+	want := []string{"https://" + testMirror, "https://mirror.example.com/v2/docker.io", "https://hand.example.com"}
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Fatalf("host order = %v, want %v", order, want)
+	}
+
+	local := cfg.Host["https://"+testMirror]
+	if local.CA != "/ca.pem" {
+		t.Errorf("local ca = %v", local.CA)
+	}
+	if client, ok := local.Client.([]any); !ok || len(client) != 1 {
+		t.Errorf("local client = %#v, want one cert and key pair", local.Client)
+	}
+	fallback := cfg.Host["https://mirror.example.com/v2/docker.io"]
+	if !fallback.SkipVerify || !fallback.OverridePath {
+		t.Errorf("fallback skip_verify = %t, override_path = %t, want both set", fallback.SkipVerify, fallback.OverridePath)
+	}
+	if got := fallback.Header["X-Tenant"]; len(got) != 1 || got[0] != "edge" {
+		t.Errorf("fallback header = %v", fallback.Header)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600 with headers", info.Mode().Perm())
+	}
+}
+
+// TestMirrorPathPrefixRequests pulls through a mirror that serves docker.io
+// under /docker.io, the way containerd reads hosts.toml and the way CRI-O
+// rewrites an image to the location of registries.conf.
+func TestMirrorPathPrefixRequests(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	m := MirrorEndpoint{URL: srv.URL, PathPrefix: "/docker.io"}
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crane.Push(img, m.location()+"/library/nginx:latest", crane.Insecure); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	const want = "/v2/docker.io/library/nginx/manifests/latest"
+	requested := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		ok := len(paths) > 0 && paths[len(paths)-1] == want
+		paths = nil
+		return ok
+	}
+	requested()
+
+	t.Run("containerd", func(t *testing.T) {
+		certsDir := t.TempDir()
+		if err := writeContainerdHostToml(certsDir, "docker.io", []MirrorEndpoint{m}, func(string) bool { return false }); err != nil {
+			t.Fatalf("writeContainerdHostToml: %v", err)
+		}
+		resolver := docker.NewResolver(docker.ResolverOptions{
+			Hosts: config.ConfigureHosts(context.Background(), config.HostOptions{HostDir: config.HostDirFromRoot(certsDir)}),
+		})
+		if _, _, err := resolver.Resolve(context.Background(), "docker.io/library/nginx:latest"); err != nil {
+			t.Fatalf("resolve through the mirror: %v", err)
+		}
+		if !requested() {
+			t.Errorf("containerd did not request %s", want)
+		}
+	})
+
+	t.Run("CRI-O", func(t *testing.T) {
+		if _, err := crane.Digest(m.location()+"/library/nginx:latest", crane.Insecure); err != nil {
+			t.Fatalf("pull through the mirror: %v", err)
+		}
+		if !requested() {
+			t.Errorf("CRI-O location %s did not request %s", m.location(), want)
+		}
+	})
+}
+
+func TestSetCrioConfig_FallbackMirrors(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	writeTestFile(t, paths.registriesConf, `[[registry]]
+location = "docker.io"
+
+[[registry.mirror]]
+location = "hand.example.com"
+`)
+	certFile := filepath.Join(dir, "client.pem")
+	writeTestFile(t, certFile, "cert and key")
+
+	endpoints := mirrorEndpoints(testMirror, nil, MirrorOptions{
+		Fallbacks: []MirrorEndpoint{{URL: "https://mirror.example.com", ClientCertFile: certFile}},
+	})
+	if _, err := setCrioConfig(paths, []string{"docker.io"}, endpoints, nil, func(string) bool { return false }); err != nil {
+		t.Fatalf("setCrioConfig: %v", err)
+	}
+
+	var cfg RegistriesConf
+	if _, err := toml.Decode(readTestFile(t, paths.registriesConf), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Registries) != 1 {
+		t.Fatalf("got %d registries, want 1", len(cfg.Registries))
+	}
+	want := []Mirror{
+		{Location: testMirror, Insecure: true},
+		{Location: "mirror.example.com"},
+		{Location: "hand.example.com"},
+	}
+	got := cfg.Registries[0].Mirrors
+	if len(got) != len(want) {
+		t.Fatalf("mirrors = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Location != want[i].Location || got[i].Insecure != want[i].Insecure {
+			t.Errorf("mirror %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	for _, name := range []string{"client.cert", "client.key"} {
+		if got := readTestFile(t, filepath.Join(paths.containersCertsDir, "mirror.example.com", name)); got != "cert and key" {
+			t.Errorf("%s = %q", name, got)
+		}
+	}
+}
+
+func TestUnsupportedMirrorSettings(t *testing.T) {
+	auth := &MirrorAuth{Username: "pull", Password: "secret"}
+	endpoints := mirrorEndpoints(testMirror, auth, MirrorOptions{
+		Fallbacks: []MirrorEndpoint{{URL: "https://mirror.example.com", PathPrefix: "/docker"}},
+	})
+
+	if warnings := unsupportedMirrorSettings(CRIContainerd, endpoints, auth); len(warnings) != 0 {
+		t.Errorf("containerd warnings = %v, want none", warnings)
+	}
+	if warnings := unsupportedMirrorSettings(CRICrio, endpoints, auth); len(warnings) != 0 {
+		t.Errorf("crio warnings = %v, want none for the local registry credentials", warnings)
+	}
+	if warnings := unsupportedMirrorSettings(CRIDocker, endpoints, auth); len(warnings) != 1 {
+		t.Errorf("docker warnings = %v, want the path prefix", warnings)
+	}
+
+	endpoints[1].Header = map[string][]string{"X-Tenant": {"edge"}}
+	if warnings := unsupportedMirrorSettings(CRICrio, endpoints, auth); len(warnings) != 1 {
+		t.Errorf("crio warnings = %v, want the custom header", warnings)
+	}
+}
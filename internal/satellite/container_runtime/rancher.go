@@ -0,0 +1,164 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	k3sRegistriesPath  = "/etc/rancher/k3s/registries.yaml"
+	rke2RegistriesPath = "/etc/rancher/rke2/registries.yaml"
+)
+
+// rancherUnits are the systemd units k3s and RKE2 run as, the server first.
+var rancherUnits = map[CRIType][]string{
+	CRIK3s:  {"k3s", "k3s-agent"},
+	CRIRKE2: {"rke2-server", "rke2-agent"},
+}
+
+// registriesPath returns the registries.yaml of cri.
+func (p criPaths) registriesPath(cri CRIType) string {
+	if cri == CRIRKE2 {
+		return p.rke2Registries
+	}
+	return p.k3sRegistries
+}
+
+// setRancherRegistries merges the mirrors into the registries.yaml k3s and
+// RKE2 generate the config of their embedded containerd from. The mirrors
+// go first in the endpoint list of every upstream registry, followed by the
+// endpoints added by hand, and their TLS settings and the pull credentials
+// of a secured local registry go to the configs of their host. owned
+// reports the mirror locations the satellite wrote before, which are
+// replaced. The file is only read when k3s or RKE2 starts, so it reports
+// whether the file changed and the service needs a restart.
+func setRancherRegistries(path string, upstreamRegistries []string, endpoints []MirrorEndpoint, auth *MirrorAuth, owned func(string) bool) (string, bool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", false, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	bkPath, err := backupFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to backup %s: %w", path, err)
+	}
+
+	original, err := os.ReadFile(filepath.Clean(path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return bkPath, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	cfg := map[string]any{}
+	if err := yaml.Unmarshal(original, &cfg); err != nil {
+		return bkPath, false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if cfg == nil {
+		cfg = map[string]any{}
+	}
+
+	var urls []any
+	current := map[string]bool{}
+	for _, m := range endpoints {
+		urls = append(urls, m.apiURL())
+		current[m.host()] = true
+	}
+
+	mirrors := yamlMap(cfg, "mirrors")
+	for _, upstream := range upstreamRegistries {
+		mirror := yamlMap(mirrors, upstream)
+		handAdded := slices.DeleteFunc(anySlice(mirror["endpoint"]), func(e any) bool {
+			s, _ := e.(string)
+			return owned(s) || slices.Contains(urls, e)
+		})
+		mirror["endpoint"] = append(slices.Clone(urls), handAdded...)
+	}
+
+	configs := yamlMap(cfg, "configs")
+	for host := range configs {
+		if owned(host) && !current[host] {
+			delete(configs, host)
+		}
+	}
+	for i, m := range endpoints {
+		var credentials *MirrorAuth
+		if i == 0 && auth.hasCredentials() {
+			credentials = auth
+		}
+		if c := rancherRegistryConfig(m, credentials); len(c) > 0 {
+			configs[m.host()] = c
+		} else {
+			delete(configs, m.host())
+		}
+	}
+	if len(configs) == 0 {
+		delete(cfg, "configs")
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return bkPath, false, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if bytes.Equal(b.Bytes(), original) {
+		return bkPath, false, nil
+	}
+	// The configs may hold the pull credentials of the local registry.
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		return bkPath, false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	// validate written config
+	var check map[string]any
+	if err := yaml.Unmarshal(b.Bytes(), &check); err != nil {
+		if bkPath != "" {
+			if restoreErr := restoreBackup(bkPath, path); restoreErr != nil {
+				return bkPath, false, fmt.Errorf("%s validation failed and rollback failed: %w", path, errors.Join(err, restoreErr))
+			}
+		}
+		return bkPath, false, fmt.Errorf("%s validation failed, rolled back: %w", path, err)
+	}
+	return bkPath, true, nil
+}
+
+// rancherRegistryConfig is the configs entry of a mirror in registries.yaml,
+// empty when the mirror needs no TLS settings or credentials.
+func rancherRegistryConfig(m MirrorEndpoint, auth *MirrorAuth) map[string]any {
+	c := map[string]any{}
+	tls := map[string]any{}
+	if m.CAFile != "" {
+		tls["ca_file"] = m.CAFile
+	}
+	if m.ClientCertFile != "" {
+		keyFile := m.ClientKeyFile
+		if keyFile == "" {
+			keyFile = m.ClientCertFile
+		}
+		tls["cert_file"] = m.ClientCertFile
+		tls["key_file"] = keyFile
+	}
+	if m.SkipVerify {
+		tls["insecure_skip_verify"] = true
+	}
+	if len(tls) > 0 {
+		c["tls"] = tls
+	}
+	if auth != nil {
+		c["auth"] = map[string]any{"username": auth.Username, "password": auth.Password}
+	}
+	return c
+}
+
+// yamlMap returns the mapping at key of parent, adding an empty one when
+// there is none.
+func yamlMap(parent map[string]any, key string) map[string]any {
+	if m, ok := parent[key].(map[string]any); ok {
+		return m
+	}
+	m := map[string]any{}
+	parent[key] = m
+	return m
+}
@@ -0,0 +1,177 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type testRegistriesYAML struct {
+	Mirrors map[string]struct {
+		Endpoint []string `yaml:"endpoint"`
+	} `yaml:"mirrors"`
+	Configs map[string]struct {
+		Auth *struct {
+			Username string `yaml:"username"`
+			Password string `yaml:"password"`
+		} `yaml:"auth"`
+		TLS *struct {
+			CAFile   string `yaml:"ca_file"`
+			CertFile string `yaml:"cert_file"`
+			KeyFile  string `yaml:"key_file"`
+		} `yaml:"tls"`
+	} `yaml:"configs"`
+}
+
+func readRegistriesYAML(t *testing.T, path string) testRegistriesYAML {
+	t.Helper()
+	var cfg testRegistriesYAML
+	if err := yaml.Unmarshal([]byte(readTestFile(t, path)), &cfg); err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+	return cfg
+}
+
+func TestSetRancherRegistries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registries.yaml")
+	writeTestFile(t, path, `mirrors:
+  docker.io:
+    endpoint:
+      - https://hand.example.com
+      - http://stale.example.com:5000
+configs:
+  hand.example.com:
+    tls:
+      insecure_skip_verify: true
+  stale.example.com:5000:
+    auth:
+      username: old
+      password: old
+`)
+
+	auth := &MirrorAuth{CAFile: "/ca.pem", Username: "pull", Password: "secret"}
+	endpoints := mirrorEndpoints(testMirror, auth, MirrorOptions{
+		Fallbacks: []MirrorEndpoint{{
+			URL:            "https://mirror.example.com",
+			PathPrefix:     "/docker.io",
+			ClientCertFile: "/client.pem",
+			ClientKeyFile:  "/client.key",
+		}},
+	})
+	owned := func(location string) bool { return mirrorHost(location) == "stale.example.com:5000" }
+
+	backup, changed, err := setRancherRegistries(path, []string{"docker.io", "quay.io"}, endpoints, auth, owned)
+	if err != nil {
+		t.Fatalf("setRancherRegistries() error = %v", err)
+	}
+	if !changed || backup == "" {
+		t.Errorf("changed = %t, backup = %q, want a changed file with a backup", changed, backup)
+	}
+
+	cfg := readRegistriesYAML(t, path)
+	wantDocker := []string{"https://" + testMirror, "https://mirror.example.com/v2/docker.io", "https://hand.example.com"}
+	if got := cfg.Mirrors["docker.io"].Endpoint; !slices.Equal(got, wantDocker) {
+		t.Errorf("docker.io endpoints = %q, want %q", got, wantDocker)
+	}
+	wantQuay := []string{"https://" + testMirror, "https://mirror.example.com/v2/docker.io"}
+	if got := cfg.Mirrors["quay.io"].Endpoint; !slices.Equal(got, wantQuay) {
+		t.Errorf("quay.io endpoints = %q, want %q", got, wantQuay)
+	}
+
+	local := cfg.Configs[testMirror]
+	if local.Auth == nil || local.Auth.Username != "pull" || local.Auth.Password != "secret" {
+		t.Errorf("local registry auth = %+v, want the pull credentials", local.Auth)
+	}
+	if local.TLS == nil || local.TLS.CAFile != "/ca.pem" {
+		t.Errorf("local registry tls = %+v, want the CA", local.TLS)
+	}
+	fallback := cfg.Configs["mirror.example.com"]
+	if fallback.Auth != nil || fallback.TLS == nil || fallback.TLS.CertFile != "/client.pem" || fallback.TLS.KeyFile != "/client.key" {
+		t.Errorf("fallback config = %+v, want only the client certificate", fallback)
+	}
+	if _, ok := cfg.Configs["hand.example.com"]; !ok {
+		t.Error("hand-added config was dropped")
+	}
+	if _, ok := cfg.Configs["stale.example.com:5000"]; ok {
+		t.Error("stale satellite config was kept")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("mode = %o, want 600", perm)
+	}
+
+	// Applying the same mirrors again leaves k3s running.
+	if _, changed, err := setRancherRegistries(path, []string{"docker.io", "quay.io"}, endpoints, auth, owned); err != nil || changed {
+		t.Errorf("second apply: changed = %t, err = %v, want an unchanged file", changed, err)
+	}
+}
+
+func TestSetRancherRegistries_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registries.yaml")
+	original := "mirrors: [unterminated\n"
+	writeTestFile(t, path, original)
+
+	if _, _, err := setRancherRegistries(path, []string{"docker.io"}, testEndpoints, nil, func(string) bool { return false }); err == nil {
+		t.Fatal("expected an error for an unparsable registries.yaml")
+	}
+	if got := readTestFile(t, path); got != original {
+		t.Errorf("registries.yaml was changed to\n%s", got)
+	}
+}
+
+func TestApplyCRIConfigs_Rancher(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	journalPath := filepath.Join(dir, "journal.json")
+	writeTestFile(t, paths.rke2Registries, "mirrors:\n  docker.io:\n    endpoint:\n      - https://hand.example.com\n")
+
+	configs := []CRIConfig{
+		{CRI: CRIK3s, Registries: []string{"docker.io"}},
+		{CRI: CRIRKE2, Registries: []string{"docker.io"}},
+	}
+	results := applyCRIConfigs(journalPath, paths, configs, testMirror, nil, MirrorOptions{})
+	for _, r := range results {
+		if !r.Success || !r.RestartRequired || r.Reloaded {
+			t.Errorf("%s result = %+v, want success with a restart required", r.CRI, r)
+		}
+	}
+
+	status, err := readCRIStatus(journalPath, paths)
+	if err != nil {
+		t.Fatalf("readCRIStatus() error = %v", err)
+	}
+	if len(status.Wiring) != 2 {
+		t.Fatalf("wiring = %+v, want k3s and rke2", status.Wiring)
+	}
+	for _, w := range status.Wiring {
+		if w.Registry != "docker.io" || !w.Satellite {
+			t.Errorf("wiring = %+v", w)
+		}
+	}
+
+	h := newFakeHost(t)
+	results = applyCRIConfigs(journalPath, paths, configs[:1], testMirror, nil, MirrorOptions{Reload: true})
+	if results[0].RestartRequired || results[0].Reloaded {
+		t.Errorf("unchanged registries.yaml: %+v, want k3s left running", results[0])
+	}
+	if len(h.commands) != 0 {
+		t.Errorf("commands = %q, want none", h.commands)
+	}
+
+	if _, err := uninstallCRIConfigs(journalPath, paths); err != nil {
+		t.Fatalf("uninstallCRIConfigs() error = %v", err)
+	}
+	requireNotExist(t, paths.k3sRegistries)
+	rke2 := readTestFile(t, paths.rke2Registries)
+	if strings.Contains(rke2, testMirror) || !strings.Contains(rke2, "hand.example.com") {
+		t.Errorf("rke2 registries.yaml = \n%s\nwant only the hand-added endpoint", rke2)
+	}
+}
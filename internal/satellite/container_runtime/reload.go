@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const runtimeReloadTimeout = 30 * time.Second
+
+// runtimeDaemons are the systemd units of the runtime daemons and the names
+// of their processes, signalled when systemd does not manage them.
+var runtimeDaemons = map[CRIType]struct {
+	unit    string
+	process string
+}{
+	CRIContainerd: {unit: "containerd", process: "containerd"},
+	CRICrio:       {unit: "crio", process: "crio"},
+	CRIDocker:     {unit: "docker", process: "dockerd"},
+}
+
+// runCommand runs a command on the host. Replaced in tests.
+var runCommand = func(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// procDir is where processes are looked up to be signalled. Replaced in
+// tests.
+var procDir = "/proc"
+
+// signalProcess sends sig to the processes named name. Replaced in tests.
+var signalProcess = func(name string, sig syscall.Signal) error {
+	pids, err := findProcesses(procDir, name)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return fmt.Errorf("no %s process found", name)
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, sig); err != nil {
+			return fmt.Errorf("signal %s (pid %d): %w", name, pid, err)
+		}
+	}
+	return nil
+}
+
+// findProcesses returns the pids of the processes whose command name is
+// name.
+func findProcesses(procDir, name string) ([]int, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procDir, e.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(comm)) == name {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// reloadRuntime makes cri pick up the mirror configs written for it.
+// containerd reads hosts.toml on every pull and is only restarted when its
+// registry config_path changed, which it reads at startup. k3s and RKE2 only
+// read registries.yaml at startup and are restarted when it changed. CRI-O
+// and Docker re-read their registry settings on SIGHUP, sent through systemd
+// when it manages them. Podman has no daemon, every podman command reads the
+// configs. restart reports whether a config read at startup changed. It
+// reports whether the runtime was reloaded.
+func reloadRuntime(ctx context.Context, cri CRIType, restart bool) (bool, error) {
+	if units, ok := rancherUnits[cri]; ok {
+		if !restart {
+			return false, nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, runtimeReloadTimeout)
+		defer cancel()
+		return restartRancher(ctx, cri, units)
+	}
+
+	daemon, ok := runtimeDaemons[cri]
+	if !ok || (cri == CRIContainerd && !restart) {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runtimeReloadTimeout)
+	defer cancel()
+	if cri == CRIContainerd {
+		if err := runCommand(ctx, "systemctl", "restart", daemon.unit); err != nil {
+			return false, fmt.Errorf("restart containerd for its new registry config_path: %w", err)
+		}
+		return true, nil
+	}
+
+	err := runCommand(ctx, "systemctl", "reload", daemon.unit)
+	if err == nil {
+		return true, nil
+	}
+	if sigErr := signalProcess(daemon.process, syscall.SIGHUP); sigErr != nil {
+		return false, fmt.Errorf("reload %s: %w", cri, errors.Join(err, sigErr))
+	}
+	return true, nil
+}
+
+// restartRancher restarts the first of the k3s or RKE2 units that is
+// active, the server or the agent.
+func restartRancher(ctx context.Context, cri CRIType, units []string) (bool, error) {
+	for _, unit := range units {
+		if runCommand(ctx, "systemctl", "is-active", "--quiet", unit) != nil {
+			continue
+		}
+		if err := runCommand(ctx, "systemctl", "restart", unit); err != nil {
+			return false, fmt.Errorf("restart %s for its new registries.yaml: %w", cri, err)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("restart %s: none of the units %s is active", cri, strings.Join(units, ", "))
+}
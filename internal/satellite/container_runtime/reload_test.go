@@ -0,0 +1,199 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// fakeHost replaces the commands and signals sent to the host for a test
+// and records them.
+type fakeHost struct {
+	commands    []string
+	signals     []string
+	commandErr  error
+	commandErrs map[string]error
+	signalErr   error
+}
+
+func newFakeHost(t *testing.T) *fakeHost {
+	t.Helper()
+	h := &fakeHost{}
+	origCommand, origSignal := runCommand, signalProcess
+	runCommand = func(_ context.Context, name string, args ...string) error {
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		h.commands = append(h.commands, cmd)
+		if err, ok := h.commandErrs[cmd]; ok {
+			return err
+		}
+		return h.commandErr
+	}
+	signalProcess = func(name string, sig syscall.Signal) error {
+		h.signals = append(h.signals, name+" "+sig.String())
+		return h.signalErr
+	}
+	t.Cleanup(func() { runCommand, signalProcess = origCommand, origSignal })
+	return h
+}
+
+func TestReloadRuntime(t *testing.T) {
+	tests := []struct {
+		name         string
+		cri          CRIType
+		restart      bool
+		commandErr   error
+		commandErrs  map[string]error
+		signalErr    error
+		wantReloaded bool
+		wantErr      bool
+		wantCommands []string
+		wantSignals  []string
+	}{
+		{
+			name:         "containerd reads hosts.toml on every pull",
+			cri:          CRIContainerd,
+			wantCommands: nil,
+		},
+		{
+			name:         "containerd restarts for a new config_path",
+			cri:          CRIContainerd,
+			restart:      true,
+			wantReloaded: true,
+			wantCommands: []string{"systemctl restart containerd"},
+		},
+		{
+			name:         "containerd restart fails",
+			cri:          CRIContainerd,
+			restart:      true,
+			commandErr:   errors.New("unit not found"),
+			wantErr:      true,
+			wantCommands: []string{"systemctl restart containerd"},
+		},
+		{
+			name:         "crio reloads through systemd",
+			cri:          CRICrio,
+			wantReloaded: true,
+			wantCommands: []string{"systemctl reload crio"},
+		},
+		{
+			name:         "docker is signalled without systemd",
+			cri:          CRIDocker,
+			commandErr:   errors.New("systemctl not found"),
+			wantReloaded: true,
+			wantCommands: []string{"systemctl reload docker"},
+			wantSignals:  []string{"dockerd hangup"},
+		},
+		{
+			name:         "crio reload fails",
+			cri:          CRICrio,
+			commandErr:   errors.New("systemctl not found"),
+			signalErr:    errors.New("no crio process found"),
+			wantErr:      true,
+			wantCommands: []string{"systemctl reload crio"},
+			wantSignals:  []string{"crio hangup"},
+		},
+		{
+			name: "podman has no daemon",
+			cri:  CRIPodman,
+		},
+		{
+			name: "k3s keeps running without registries.yaml changes",
+			cri:  CRIK3s,
+		},
+		{
+			name:         "k3s server restarts for a new registries.yaml",
+			cri:          CRIK3s,
+			restart:      true,
+			wantReloaded: true,
+			wantCommands: []string{"systemctl is-active --quiet k3s", "systemctl restart k3s"},
+		},
+		{
+			name:         "rke2 agent restarts when the server is not running",
+			cri:          CRIRKE2,
+			restart:      true,
+			commandErrs:  map[string]error{"systemctl is-active --quiet rke2-server": errors.New("inactive")},
+			wantReloaded: true,
+			wantCommands: []string{
+				"systemctl is-active --quiet rke2-server",
+				"systemctl is-active --quiet rke2-agent",
+				"systemctl restart rke2-agent",
+			},
+		},
+		{
+			name:         "k3s not run by systemd",
+			cri:          CRIK3s,
+			restart:      true,
+			commandErr:   errors.New("systemctl not found"),
+			wantErr:      true,
+			wantCommands: []string{"systemctl is-active --quiet k3s", "systemctl is-active --quiet k3s-agent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeHost(t)
+			h.commandErr, h.commandErrs, h.signalErr = tt.commandErr, tt.commandErrs, tt.signalErr
+
+			reloaded, err := reloadRuntime(context.Background(), tt.cri, tt.restart)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if reloaded != tt.wantReloaded {
+				t.Errorf("reloaded = %t, want %t", reloaded, tt.wantReloaded)
+			}
+			if !slices.Equal(h.commands, tt.wantCommands) {
+				t.Errorf("commands = %q, want %q", h.commands, tt.wantCommands)
+			}
+			if !slices.Equal(h.signals, tt.wantSignals) {
+				t.Errorf("signals = %q, want %q", h.signals, tt.wantSignals)
+			}
+		})
+	}
+}
+
+func TestFindProcesses(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "12", "comm"), "dockerd\n")
+	writeTestFile(t, filepath.Join(dir, "34", "comm"), "containerd\n")
+	writeTestFile(t, filepath.Join(dir, "56", "comm"), "dockerd\n")
+	writeTestFile(t, filepath.Join(dir, "self", "comm"), "dockerd\n")
+
+	pids, err := findProcesses(dir, "dockerd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pids, []int{12, 56}) {
+		t.Errorf("pids = %v, want [12 56]", pids)
+	}
+}
+
+func TestApplyCRIConfigs_Reload(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	journalPath := filepath.Join(dir, "journal.json")
+	writeTestFile(t, paths.containerdConfig, "version = 3\n")
+	h := newFakeHost(t)
+
+	configs := []CRIConfig{{CRI: CRIContainerd, Registries: []string{"docker.io"}}}
+	results := applyCRIConfigs(journalPath, paths, configs, testMirror, nil, MirrorOptions{Reload: true})
+	if len(results) != 1 || !results[0].Success {
+		t.Fatalf("results = %+v", results)
+	}
+	if !results[0].Reloaded {
+		t.Errorf("containerd not restarted for its new config_path: %+v", results[0])
+	}
+
+	// config_path already points at the certs dir, the new hosts.toml is
+	// picked up without a restart.
+	results = applyCRIConfigs(journalPath, paths, configs, testMirror, nil, MirrorOptions{Reload: true})
+	if results[0].Reloaded || results[0].ReloadError != "" {
+		t.Errorf("containerd restarted again: %+v", results[0])
+	}
+	if want := []string{"systemctl restart containerd"}; !slices.Equal(h.commands, want) {
+		t.Errorf("commands = %q, want %q", h.commands, want)
+	}
+}
@@ -0,0 +1,406 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Actions taken on a recorded file by a restore or an uninstall.
+const (
+	ActionRestored = "restored"
+	ActionRemoved  = "removed"
+	ActionCleaned  = "cleaned"
+)
+
+// RestoreResult is the outcome of restoring or cleaning up one recorded file.
+type RestoreResult struct {
+	CRI    CRIType `json:"cri"`
+	Path   string  `json:"path"`
+	Action string  `json:"action,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// RestoreCRIConfigs puts back the content every recorded file had before the
+// satellite first changed it, and removes the files it created. With
+// runtimes set, only the files of those runtimes are restored. Restored files
+// leave the journal; failed ones stay for another attempt.
+func RestoreCRIConfigs(runtimes []CRIType) ([]RestoreResult, error) {
+	return restoreCRIConfigs(criJournalPath, runtimes)
+}
+
+func restoreCRIConfigs(journalPath string, runtimes []CRIType) ([]RestoreResult, error) {
+	return processJournal(journalPath, runtimes, func(_ *Journal, e JournalEntry) (string, error) {
+		return restoreEntry(e)
+	})
+}
+
+// UninstallCRIConfigs removes the mirror entries the satellite wrote from
+// every recorded file and keeps the rest of their content, including edits
+// made after the satellite changed them. Files the satellite created are
+// removed once nothing else is left in them.
+func UninstallCRIConfigs() ([]RestoreResult, error) {
+	return uninstallCRIConfigs(criJournalPath, systemCRIPaths)
+}
+
+func uninstallCRIConfigs(journalPath string, paths criPaths) ([]RestoreResult, error) {
+	return processJournal(journalPath, nil, func(j *Journal, e JournalEntry) (string, error) {
+		switch {
+		case e.Path == paths.containerdConfig:
+			return cleanContainerdConfig(e, paths.containerdCertsDir)
+		case strings.HasPrefix(e.Path, paths.containerdCertsDir+string(filepath.Separator)) && filepath.Base(e.Path) == "hosts.toml":
+			return cleanTOML(e, func(cfg map[string]any) {
+				hosts, _ := cfg["host"].(map[string]any)
+				for location := range hosts {
+					if j.isMirror(location) {
+						delete(hosts, location)
+					}
+				}
+				if len(hosts) == 0 {
+					delete(cfg, "host")
+				}
+				if _, ok := cfg["host"]; !ok && e.Backup == "" {
+					// Only the server line of a file the satellite created is left.
+					delete(cfg, "server")
+				}
+			})
+		case e.Path == paths.registriesConf:
+			return cleanRegistriesConf(j, e)
+		case e.Path == paths.dockerConfig:
+			return cleanJSON(e, func(cfg map[string]any) {
+				for _, key := range []string{"registry-mirrors", "insecure-registries"} {
+					cfg[key] = slices.DeleteFunc(anySlice(cfg[key]), func(m any) bool {
+						s, _ := m.(string)
+						return j.isMirror(s)
+					})
+					if len(anySlice(cfg[key])) == 0 {
+						delete(cfg, key)
+					}
+				}
+			})
+		case e.Path == paths.containersAuthFile:
+			return cleanJSON(e, func(cfg map[string]any) {
+				auths, _ := cfg["auths"].(map[string]any)
+				for host := range auths {
+					if j.isMirror(host) {
+						delete(auths, host)
+					}
+				}
+				if len(auths) == 0 {
+					delete(cfg, "auths")
+				}
+			})
+		case e.Path == paths.k3sRegistries || e.Path == paths.rke2Registries:
+			return cleanYAML(e, func(cfg map[string]any) {
+				mirrors, _ := cfg["mirrors"].(map[string]any)
+				for upstream, v := range mirrors {
+					mirror, _ := v.(map[string]any)
+					endpoints := slices.DeleteFunc(anySlice(mirror["endpoint"]), func(m any) bool {
+						s, _ := m.(string)
+						return j.isMirror(s)
+					})
+					if len(endpoints) == 0 {
+						delete(mirrors, upstream)
+						continue
+					}
+					mirror["endpoint"] = endpoints
+				}
+				if len(mirrors) == 0 {
+					delete(cfg, "mirrors")
+				}
+				configs, _ := cfg["configs"].(map[string]any)
+				for host := range configs {
+					if j.isMirror(host) {
+						delete(configs, host)
+					}
+				}
+				if len(configs) == 0 {
+					delete(cfg, "configs")
+				}
+			})
+		default:
+			// Certificates and drop-ins hold nothing but what the satellite
+			// wrote.
+			return restoreEntry(e)
+		}
+	})
+}
+
+// processJournal applies fn to the entries of the journal at journalPath
+// that belong to runtimes, or to all of them when runtimes is empty, and
+// drops the entries fn succeeded on.
+func processJournal(journalPath string, runtimes []CRIType, fn func(*Journal, JournalEntry) (string, error)) ([]RestoreResult, error) {
+	j, err := LoadJournal(journalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []RestoreResult
+	var kept []JournalEntry
+	for _, e := range j.Entries {
+		if len(runtimes) > 0 && !slices.Contains(runtimes, e.CRI) {
+			kept = append(kept, e)
+			continue
+		}
+		result := RestoreResult{CRI: e.CRI, Path: e.Path}
+		action, err := fn(j, e)
+		if err != nil {
+			result.Error = err.Error()
+			kept = append(kept, e)
+		} else {
+			result.Action = action
+		}
+		results = append(results, result)
+	}
+
+	j.Entries = kept
+	if len(kept) == 0 {
+		j.Mirrors = nil
+	}
+	if err := j.Save(journalPath); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// restoreEntry copies the backup of a recorded file back, or removes the
+// file when the satellite created it.
+func restoreEntry(e JournalEntry) (string, error) {
+	if e.Backup != "" {
+		if err := restoreBackup(e.Backup, e.Path); err != nil {
+			return "", fmt.Errorf("restore %s from %s: %w", e.Path, e.Backup, err)
+		}
+		return ActionRestored, nil
+	}
+	return removeCreated(e.Path)
+}
+
+// removeCreated removes a file the satellite created and, for the per-host
+// files of certs.d directories, the host directory once it is empty.
+func removeCreated(path string) (string, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("remove %s: %w", path, err)
+	}
+	switch filepath.Base(path) {
+	case "hosts.toml", "ca.crt", "client.cert", "client.key":
+		// Fails, as intended, while the directory holds other files.
+		_ = os.Remove(filepath.Dir(path))
+	}
+	return ActionRemoved, nil
+}
+
+// cleanContainerdConfig points the registry plugin back at the config path
+// it had before, or drops the setting when the satellite added it. A config
+// path changed by hand since is kept.
+func cleanContainerdConfig(e JournalEntry, certsDir string) (string, error) {
+	var original any
+	if e.Backup != "" {
+		orig, err := loadToml(e.Backup)
+		if err != nil {
+			return "", err
+		}
+		original = nestedValue(orig, "plugins", "io.containerd.cri.v1.images", "registry", "config_path")
+	}
+
+	return cleanTOML(e, func(cfg map[string]any) {
+		registry, ok := nestedValue(cfg, "plugins", "io.containerd.cri.v1.images", "registry").(map[string]any)
+		if !ok || registry["config_path"] != certsDir {
+			return
+		}
+		if original != nil {
+			registry["config_path"] = original
+			return
+		}
+		delete(registry, "config_path")
+		pruneEmpty(cfg, "plugins", "io.containerd.cri.v1.images", "registry")
+	})
+}
+
+// cleanRegistriesConf drops the mirrors the satellite added from
+// registries.conf, and the registry entries it added for them.
+func cleanRegistriesConf(j *Journal, e JournalEntry) (string, error) {
+	var originalLocations []string
+	if e.Backup != "" {
+		orig, err := loadToml(e.Backup)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range tableSlice(orig["registry"]) {
+			if location, ok := r["location"].(string); ok {
+				originalLocations = append(originalLocations, location)
+			}
+		}
+	}
+
+	return cleanTOML(e, func(cfg map[string]any) {
+		var registries []map[string]any
+		for _, r := range tableSlice(cfg["registry"]) {
+			mirrors := slices.DeleteFunc(tableSlice(r["mirror"]), func(m map[string]any) bool {
+				location, _ := m["location"].(string)
+				return j.isMirror(location)
+			})
+			if len(mirrors) > 0 {
+				r["mirror"] = mirrors
+			} else {
+				delete(r, "mirror")
+			}
+			location, _ := r["location"].(string)
+			if len(r) == 1 && !slices.Contains(originalLocations, location) {
+				continue
+			}
+			registries = append(registries, r)
+		}
+		if len(registries) > 0 {
+			cfg["registry"] = registries
+		} else {
+			delete(cfg, "registry")
+		}
+	})
+}
+
+// cleanTOML applies clean to the TOML file of e and writes the result back,
+// or removes the file when the satellite created it and nothing is left.
+func cleanTOML(e JournalEntry, clean func(map[string]any)) (string, error) {
+	return cleanFile(e, func(data []byte) (map[string]any, error) {
+		cfg := map[string]any{}
+		if _, err := toml.Decode(string(data), &cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", e.Path, err)
+		}
+		return cfg, nil
+	}, func(cfg map[string]any) ([]byte, error) {
+		var b strings.Builder
+		if err := toml.NewEncoder(&b).Encode(cfg); err != nil {
+			return nil, err
+		}
+		return []byte(b.String()), nil
+	}, clean)
+}
+
+// cleanJSON is cleanTOML for JSON files.
+func cleanJSON(e JournalEntry, clean func(map[string]any)) (string, error) {
+	return cleanFile(e, func(data []byte) (map[string]any, error) {
+		cfg := map[string]any{}
+		if len(strings.TrimSpace(string(data))) == 0 {
+			return cfg, nil
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", e.Path, err)
+		}
+		return cfg, nil
+	}, func(cfg map[string]any) ([]byte, error) {
+		return json.MarshalIndent(cfg, "", "  ")
+	}, clean)
+}
+
+// cleanYAML is cleanTOML for YAML files.
+func cleanYAML(e JournalEntry, clean func(map[string]any)) (string, error) {
+	return cleanFile(e, func(data []byte) (map[string]any, error) {
+		cfg := map[string]any{}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", e.Path, err)
+		}
+		if cfg == nil {
+			cfg = map[string]any{}
+		}
+		return cfg, nil
+	}, func(cfg map[string]any) ([]byte, error) {
+		var b bytes.Buffer
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}, clean)
+}
+
+func cleanFile(e JournalEntry, decode func([]byte) (map[string]any, error), encode func(map[string]any) ([]byte, error), clean func(map[string]any)) (string, error) {
+	info, err := os.Stat(e.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ActionRemoved, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Clean(e.Path))
+	if err != nil {
+		return "", err
+	}
+	cfg, err := decode(data)
+	if err != nil {
+		return "", err
+	}
+
+	clean(cfg)
+	if len(cfg) == 0 && e.Backup == "" {
+		return removeCreated(e.Path)
+	}
+	out, err := encode(cfg)
+	if err != nil {
+		return "", fmt.Errorf("encode %s: %w", e.Path, err)
+	}
+	if err := os.WriteFile(e.Path, out, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("write %s: %w", e.Path, err)
+	}
+	return ActionCleaned, nil
+}
+
+// nestedValue returns the value at keys in nested tables, or nil.
+func nestedValue(m map[string]any, keys ...string) any {
+	var v any = m
+	for _, key := range keys {
+		table, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = table[key]
+	}
+	return v
+}
+
+// pruneEmpty removes the tables at keys, innermost first, that are left
+// empty.
+func pruneEmpty(m map[string]any, keys ...string) {
+	for i := len(keys); i > 0; i-- {
+		parent, ok := m, true
+		if i > 1 {
+			parent, ok = nestedValue(m, keys[:i-1]...).(map[string]any)
+		}
+		if !ok {
+			return
+		}
+		if table, ok := parent[keys[i-1]].(map[string]any); ok && len(table) == 0 {
+			delete(parent, keys[i-1])
+		}
+	}
+}
+
+// tableSlice returns an array of tables decoded from TOML or JSON.
+func tableSlice(v any) []map[string]any {
+	switch s := v.(type) {
+	case []map[string]any:
+		return s
+	case []any:
+		var out []map[string]any
+		for _, item := range s {
+			if m, ok := item.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func anySlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
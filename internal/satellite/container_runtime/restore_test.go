@@ -0,0 +1,254 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMirror = "127.0.0.1:8585"
+
+var testEndpoints = mirrorEndpoints(testMirror, nil, MirrorOptions{})
+
+func testCRIPaths(dir string) criPaths {
+	return criPaths{
+		containerdConfig:   filepath.Join(dir, "containerd", "config.toml"),
+		containerdCertsDir: filepath.Join(dir, "containerd", "certs.d"),
+		registriesConf:     filepath.Join(dir, "containers", "registries.conf"),
+		containersCertsDir: filepath.Join(dir, "containers", "certs.d"),
+		containersAuthFile: filepath.Join(dir, "containers", "auth.json"),
+		crioAuthDropIn:     filepath.Join(dir, "crio", "10-harbor-satellite-auth.conf"),
+		dockerConfig:       filepath.Join(dir, "docker", "daemon.json"),
+		dockerCertsDir:     filepath.Join(dir, "docker", "certs.d"),
+		k3sRegistries:      filepath.Join(dir, "rancher", "k3s", "registries.yaml"),
+		rke2Registries:     filepath.Join(dir, "rancher", "rke2", "registries.yaml"),
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func requireNotExist(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s still exists (err = %v)", path, err)
+	}
+}
+
+func TestRecordCRIChanges(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	journalPath := filepath.Join(dir, "journal.json")
+	writeTestFile(t, paths.dockerConfig, `{"log-driver":"json-file"}`)
+
+	docker := CRIConfig{CRI: CRIDocker, Registries: []string{"true"}}
+	if _, err := recordCRIChanges(journalPath, paths, docker, testEndpoints, nil); err != nil {
+		t.Fatalf("recordCRIChanges() error = %v", err)
+	}
+	// A second apply must keep the backup of the original file.
+	writeTestFile(t, paths.dockerConfig, `{"registry-mirrors":["http://127.0.0.1:8585"]}`)
+	if _, err := recordCRIChanges(journalPath, paths, docker, testEndpoints, nil); err != nil {
+		t.Fatalf("recordCRIChanges() error = %v", err)
+	}
+	disabled := CRIConfig{CRI: CRIDocker, Registries: []string{"false"}}
+	if _, err := recordCRIChanges(journalPath, paths, disabled, testEndpoints, nil); err != nil {
+		t.Fatalf("recordCRIChanges() error = %v", err)
+	}
+
+	j, err := LoadJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Entries) != 1 {
+		t.Fatalf("journal entries = %+v, want one", j.Entries)
+	}
+	if got := readTestFile(t, j.Entries[0].Backup); got != `{"log-driver":"json-file"}` {
+		t.Errorf("backup = %q, want the original content", got)
+	}
+	if len(j.Mirrors) != 1 || j.Mirrors[0] != testMirror {
+		t.Errorf("mirrors = %v, want [%s]", j.Mirrors, testMirror)
+	}
+}
+
+func TestRestoreCRIConfigs(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	journalPath := filepath.Join(dir, "journal.json")
+	original := `{"log-driver":"json-file"}`
+	writeTestFile(t, paths.dockerConfig, original)
+
+	for _, cfg := range []CRIConfig{
+		{CRI: CRIDocker, Registries: []string{"true"}},
+		{CRI: CRICrio, Registries: []string{"docker.io"}},
+	} {
+		if _, err := recordCRIChanges(journalPath, paths, cfg, testEndpoints, nil); err != nil {
+			t.Fatalf("recordCRIChanges() error = %v", err)
+		}
+	}
+	writeTestFile(t, paths.dockerConfig, `{"log-driver":"json-file","registry-mirrors":["http://127.0.0.1:8585"]}`)
+	writeTestFile(t, paths.registriesConf, "[[registry]]\nlocation = \"docker.io\"\n")
+
+	results, err := restoreCRIConfigs(journalPath, []CRIType{CRIDocker})
+	if err != nil {
+		t.Fatalf("restoreCRIConfigs() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionRestored {
+		t.Fatalf("results = %+v, want docker restored", results)
+	}
+	if got := readTestFile(t, paths.dockerConfig); got != original {
+		t.Errorf("daemon.json = %q, want %q", got, original)
+	}
+
+	j, err := LoadJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(j.Entries) != 1 || j.Entries[0].CRI != CRICrio {
+		t.Fatalf("journal entries = %+v, want the crio entry kept", j.Entries)
+	}
+
+	results, err = restoreCRIConfigs(journalPath, nil)
+	if err != nil {
+		t.Fatalf("restoreCRIConfigs() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionRemoved {
+		t.Fatalf("results = %+v, want registries.conf removed", results)
+	}
+	requireNotExist(t, paths.registriesConf)
+	requireNotExist(t, journalPath)
+}
+
+func TestUninstallCRIConfigs(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	journalPath := filepath.Join(dir, "journal.json")
+
+	writeTestFile(t, paths.containerdConfig, "version = 3\n")
+	writeTestFile(t, paths.registriesConf, "unqualified-search-registries = [\"docker.io\"]\n\n[[registry]]\nlocation = \"docker.io\"\n\n[[registry.mirror]]\nlocation = \"mirror.corp\"\n")
+
+	for _, cfg := range []CRIConfig{
+		{CRI: CRIContainerd, Registries: []string{"docker.io", "quay.io"}},
+		{CRI: CRICrio, Registries: []string{"docker.io", "quay.io"}},
+		{CRI: CRIDocker, Registries: []string{"true"}},
+	} {
+		if _, err := recordCRIChanges(journalPath, paths, cfg, testEndpoints, nil); err != nil {
+			t.Fatalf("recordCRIChanges() error = %v", err)
+		}
+	}
+
+	// What the satellite wrote, plus edits made by hand afterwards.
+	writeTestFile(t, paths.containerdConfig, "version = 3\n\n[plugins.\"io.containerd.cri.v1.images\".registry]\nconfig_path = \""+paths.containerdCertsDir+"\"\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\nsandbox_image = \"pause:3.9\"\n")
+	dockerHosts := filepath.Join(paths.containerdCertsDir, "docker.io", "hosts.toml")
+	writeTestFile(t, dockerHosts, "server = \"https://docker.io\"\n\n[host.\"http://127.0.0.1:8585\"]\ncapabilities = [\"pull\", \"resolve\"]\n")
+	quayHosts := filepath.Join(paths.containerdCertsDir, "quay.io", "hosts.toml")
+	writeTestFile(t, quayHosts, "server = \"https://quay.io\"\n\n[host.\"http://127.0.0.1:8585\"]\ncapabilities = [\"pull\", \"resolve\"]\n\n[host.\"https://mirror.corp\"]\ncapabilities = [\"pull\"]\n")
+	writeTestFile(t, paths.registriesConf, "unqualified-search-registries = [\"docker.io\"]\n\n[[registry]]\nlocation = \"docker.io\"\n\n[[registry.mirror]]\nlocation = \"mirror.corp\"\n\n[[registry.mirror]]\nlocation = \"127.0.0.1:8585\"\ninsecure = true\n\n[[registry]]\nlocation = \"quay.io\"\n\n[[registry.mirror]]\nlocation = \"127.0.0.1:8585\"\ninsecure = true\n")
+	writeTestFile(t, paths.dockerConfig, `{"registry-mirrors":["http://127.0.0.1:8585"]}`)
+
+	results, err := uninstallCRIConfigs(journalPath, paths)
+	if err != nil {
+		t.Fatalf("uninstallCRIConfigs() error = %v", err)
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			t.Errorf("%s: %s", r.Path, r.Error)
+		}
+	}
+
+	containerdConfig := readTestFile(t, paths.containerdConfig)
+	if strings.Contains(containerdConfig, "config_path") || strings.Contains(containerdConfig, "io.containerd.cri.v1.images") {
+		t.Errorf("containerd config still points at the certs directory:\n%s", containerdConfig)
+	}
+	if !strings.Contains(containerdConfig, "version = 3") || !strings.Contains(containerdConfig, "sandbox_image") {
+		t.Errorf("containerd config lost content:\n%s", containerdConfig)
+	}
+
+	requireNotExist(t, filepath.Dir(dockerHosts))
+	quay := readTestFile(t, quayHosts)
+	if strings.Contains(quay, testMirror) || !strings.Contains(quay, "mirror.corp") || !strings.Contains(quay, "https://quay.io") {
+		t.Errorf("quay.io hosts.toml = \n%s\nwant only the hand-added mirror", quay)
+	}
+
+	registries := readTestFile(t, paths.registriesConf)
+	if strings.Contains(registries, testMirror) || strings.Contains(registries, "quay.io") {
+		t.Errorf("registries.conf still holds satellite entries:\n%s", registries)
+	}
+	if !strings.Contains(registries, "mirror.corp") || !strings.Contains(registries, "unqualified-search-registries") {
+		t.Errorf("registries.conf lost content:\n%s", registries)
+	}
+
+	requireNotExist(t, paths.dockerConfig)
+	requireNotExist(t, journalPath)
+}
+
+func TestUninstallCRIConfigs_ContainerdConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	journalPath := filepath.Join(dir, "journal.json")
+	original := "[plugins.\"io.containerd.cri.v1.images\".registry]\nconfig_path = \"/etc/containerd/certs.custom\"\n"
+	writeTestFile(t, paths.containerdConfig, original)
+
+	cfg := CRIConfig{CRI: CRIContainerd, Registries: nil}
+	if _, err := recordCRIChanges(journalPath, paths, cfg, testEndpoints, nil); err != nil {
+		t.Fatalf("recordCRIChanges() error = %v", err)
+	}
+	writeTestFile(t, paths.containerdConfig, "[plugins.\"io.containerd.cri.v1.images\".registry]\nconfig_path = \""+paths.containerdCertsDir+"\"\n")
+
+	if _, err := uninstallCRIConfigs(journalPath, paths); err != nil {
+		t.Fatalf("uninstallCRIConfigs() error = %v", err)
+	}
+	if got := readTestFile(t, paths.containerdConfig); !strings.Contains(got, "/etc/containerd/certs.custom") {
+		t.Errorf("containerd config = \n%s\nwant the original config_path back", got)
+	}
+}
+
+func TestReadCRIStatus(t *testing.T) {
+	dir := t.TempDir()
+	paths := testCRIPaths(dir)
+	journalPath := filepath.Join(dir, "journal.json")
+
+	cfg := CRIConfig{CRI: CRIContainerd, Registries: []string{"docker.io"}}
+	if _, err := recordCRIChanges(journalPath, paths, cfg, testEndpoints, nil); err != nil {
+		t.Fatalf("recordCRIChanges() error = %v", err)
+	}
+	writeTestFile(t, filepath.Join(paths.containerdCertsDir, "docker.io", "hosts.toml"), "server = \"https://docker.io\"\n\n[host.\"http://127.0.0.1:8585\"]\ncapabilities = [\"pull\", \"resolve\"]\n")
+	writeTestFile(t, paths.dockerConfig, `{"registry-mirrors":["https://mirror.gcr.io"]}`)
+
+	status, err := readCRIStatus(journalPath, paths)
+	if err != nil {
+		t.Fatalf("readCRIStatus() error = %v", err)
+	}
+	if len(status.Wiring) != 2 {
+		t.Fatalf("wiring = %+v, want containerd and docker", status.Wiring)
+	}
+	if w := status.Wiring[0]; w.CRI != CRIContainerd || w.Registry != "docker.io" || !w.Satellite {
+		t.Errorf("containerd wiring = %+v", w)
+	}
+	if w := status.Wiring[1]; w.CRI != CRIDocker || w.Satellite {
+		t.Errorf("docker wiring = %+v", w)
+	}
+	// config.toml does not point containerd at the certs directory.
+	if len(status.Warnings) != 1 || !strings.Contains(status.Warnings[0], "not used") {
+		t.Errorf("warnings = %v", status.Warnings)
+	}
+	if len(status.Journal.Entries) != 2 {
+		t.Errorf("journal entries = %+v, want config.toml and hosts.toml", status.Journal.Entries)
+	}
+}
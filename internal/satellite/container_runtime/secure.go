@@ -0,0 +1,141 @@
+package runtime
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	containersCertsDir = "/etc/containers/certs.d"
+	containersAuthFile = "/etc/containers/auth.json"
+	crioAuthDropIn     = "/etc/crio/crio.conf.d/10-harbor-satellite-auth.conf"
+	dockerCertsDir     = "/etc/docker/certs.d"
+)
+
+// MirrorAuth is what a container runtime needs to pull from a secured local
+// registry: the CA that signed its certificate and, unless anonymous pulls
+// are allowed, read-only credentials.
+type MirrorAuth struct {
+	CAFile   string
+	Username string
+	Password string
+}
+
+func (a *MirrorAuth) hasCredentials() bool {
+	return a != nil && a.Username != ""
+}
+
+func (a *MirrorAuth) basicAuth() string {
+	return base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+}
+
+// mirrorHost strips the scheme from a local mirror address.
+func mirrorHost(localMirror string) string {
+	localMirror = strings.TrimPrefix(localMirror, "https://")
+	return strings.TrimPrefix(localMirror, "http://")
+}
+
+// installCA copies a mirror CA to certsDir/<host>/ca.crt, where the Docker
+// daemon and containers/image look for per-registry CAs.
+func installCA(certsDir, host, caFile string) error {
+	ca, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return fmt.Errorf("failed to read registry CA: %w", err)
+	}
+	dir := filepath.Join(certsDir, host)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(path, ca, 0o644); err != nil { //nolint:gosec // CA certificates are public
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// installCerts installs the CA and the client certificate of a mirror in
+// certsDir/<host>, as ca.crt, client.cert and client.key. A client
+// certificate without a key file holds its key.
+func installCerts(certsDir string, m MirrorEndpoint) error {
+	if m.CAFile != "" {
+		if err := installCA(certsDir, m.host(), m.CAFile); err != nil {
+			return err
+		}
+	}
+	if m.ClientCertFile == "" {
+		return nil
+	}
+	keyFile := m.ClientKeyFile
+	if keyFile == "" {
+		keyFile = m.ClientCertFile
+	}
+	dir := filepath.Join(certsDir, m.host())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	for _, f := range []struct{ src, name string }{{m.ClientCertFile, "client.cert"}, {keyFile, "client.key"}} {
+		data, err := os.ReadFile(filepath.Clean(f.src))
+		if err != nil {
+			return fmt.Errorf("failed to read mirror client certificate: %w", err)
+		}
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// writeContainersAuth stores the pull credentials of the local mirror in the
+// containers auth file, keeping the entries of other registries.
+func writeContainersAuth(path, host string, auth *MirrorAuth) error {
+	cfg := map[string]any{}
+	data, err := os.ReadFile(filepath.Clean(path))
+	switch {
+	case err == nil:
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	auths, ok := cfg["auths"].(map[string]any)
+	if !ok {
+		auths = map[string]any{}
+		cfg["auths"] = auths
+	}
+	auths[host] = map[string]any{"auth": auth.basicAuth()}
+
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, out, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeCrioAuthDropIn points CRI-O at the containers auth file, so it pulls
+// from the local mirror with its credentials.
+func writeCrioAuthDropIn(path, authFile string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	data := fmt.Sprintf("# Written by harbor-satellite\n[crio.image]\nglobal_auth_file = %q\n", authFile)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil { //nolint:gosec // holds no secrets
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
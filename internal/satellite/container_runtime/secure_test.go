@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallCA(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, []byte("ca"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	certsDir := filepath.Join(dir, "certs.d")
+	if err := installCA(certsDir, "127.0.0.1:8585", caFile); err != nil {
+		t.Fatalf("installCA() error = %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(certsDir, "127.0.0.1:8585", "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "ca" {
+		t.Errorf("installed CA = %q, want %q", got, "ca")
+	}
+}
+
+func TestWriteContainersAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	existing := `{"auths":{"registry.example.com":{"auth":"b3RoZXI6c2VjcmV0"}},"credHelpers":{"gcr.io":"gcloud"}}`
+	if err := os.WriteFile(path, []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &MirrorAuth{Username: "satellite-pull", Password: "secret"}
+	if err := writeContainersAuth(path, "127.0.0.1:8585", auth); err != nil {
+		t.Fatalf("writeContainersAuth() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Auths       map[string]map[string]string `json:"auths"`
+		CredHelpers map[string]string            `json:"credHelpers"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Auths["127.0.0.1:8585"]["auth"]; got != auth.basicAuth() {
+		t.Errorf("mirror auth = %q, want %q", got, auth.basicAuth())
+	}
+	if _, ok := cfg.Auths["registry.example.com"]; !ok {
+		t.Error("existing registry auth was dropped")
+	}
+	if cfg.CredHelpers["gcr.io"] != "gcloud" {
+		t.Error("existing credHelpers were dropped")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("auth file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
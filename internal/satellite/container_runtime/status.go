@@ -0,0 +1,159 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MirrorWiring is a registry a runtime resolves through mirrors.
+type MirrorWiring struct {
+	CRI      CRIType  `json:"cri"`
+	File     string   `json:"file"`
+	Registry string   `json:"registry"`
+	Mirrors  []string `json:"mirrors"`
+	// Satellite is set when one of the mirrors was written by the satellite.
+	Satellite bool `json:"satellite"`
+}
+
+// CRIStatus is the mirror configuration of the runtimes on the node and what
+// the satellite recorded changing in it.
+type CRIStatus struct {
+	Wiring  []MirrorWiring `json:"wiring"`
+	Journal *Journal       `json:"journal"`
+	// Warnings lists config files that could not be read and mirror config
+	// the runtime does not use.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ReadCRIStatus reads the mirror configuration of containerd, CRI-O and
+// Podman, Docker, k3s and RKE2.
+func ReadCRIStatus() (*CRIStatus, error) {
+	return readCRIStatus(criJournalPath, systemCRIPaths)
+}
+
+func readCRIStatus(journalPath string, paths criPaths) (*CRIStatus, error) {
+	j, err := LoadJournal(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	status := &CRIStatus{Journal: j}
+	warn := func(format string, args ...any) {
+		status.Warnings = append(status.Warnings, fmt.Sprintf(format, args...))
+	}
+	wire := func(cri CRIType, file, registry string, mirrors []string) {
+		w := MirrorWiring{CRI: cri, File: file, Registry: registry, Mirrors: mirrors}
+		for _, m := range mirrors {
+			w.Satellite = w.Satellite || j.isMirror(m)
+		}
+		status.Wiring = append(status.Wiring, w)
+	}
+
+	// containerd: one hosts.toml per registry under the certs directory.
+	hostFiles, _ := filepath.Glob(filepath.Join(paths.containerdCertsDir, "*", "hosts.toml"))
+	for _, path := range hostFiles {
+		cfg, err := loadToml(path)
+		if err != nil {
+			warn("%v", err)
+			continue
+		}
+		hosts, _ := cfg["host"].(map[string]any)
+		mirrors := make([]string, 0, len(hosts))
+		for location := range hosts {
+			mirrors = append(mirrors, location)
+		}
+		sort.Strings(mirrors)
+		wire(CRIContainerd, path, filepath.Base(filepath.Dir(path)), mirrors)
+	}
+	if len(hostFiles) > 0 {
+		if cfg, err := loadToml(paths.containerdConfig); err != nil {
+			warn("%v", err)
+		} else if configPath := nestedValue(cfg, "plugins", "io.containerd.cri.v1.images", "registry", "config_path"); configPath != paths.containerdCertsDir {
+			warn("containerd registry config_path is %v, the hosts.toml files under %s are not used", orUnset(configPath), paths.containerdCertsDir)
+		}
+	}
+
+	// CRI-O and Podman: registries.conf.
+	if _, err := os.Stat(paths.registriesConf); err == nil {
+		cfg, err := loadToml(paths.registriesConf)
+		if err != nil {
+			warn("%v", err)
+		}
+		for _, r := range tableSlice(cfg["registry"]) {
+			var mirrors []string
+			for _, m := range tableSlice(r["mirror"]) {
+				if location, ok := m["location"].(string); ok {
+					mirrors = append(mirrors, location)
+				}
+			}
+			if len(mirrors) == 0 {
+				continue
+			}
+			location, _ := r["location"].(string)
+			wire(CRICrio, paths.registriesConf, location, mirrors)
+		}
+	}
+
+	// Docker: registry-mirrors in daemon.json, used for Docker Hub only.
+	data, err := os.ReadFile(paths.dockerConfig)
+	switch {
+	case err == nil:
+		var cfg struct {
+			RegistryMirrors []string `json:"registry-mirrors"`
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			warn("parse %s: %v", paths.dockerConfig, err)
+		} else if len(cfg.RegistryMirrors) > 0 {
+			wire(CRIDocker, paths.dockerConfig, "docker.io", cfg.RegistryMirrors)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		warn("read %s: %v", paths.dockerConfig, err)
+	}
+
+	// k3s and RKE2: registries.yaml, from which they generate the config of
+	// their embedded containerd.
+	for _, cri := range []CRIType{CRIK3s, CRIRKE2} {
+		path := paths.registriesPath(cri)
+		data, err := os.ReadFile(filepath.Clean(path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			warn("read %s: %v", path, err)
+			continue
+		}
+		var cfg struct {
+			Mirrors map[string]struct {
+				Endpoint []string `yaml:"endpoint"`
+			} `yaml:"mirrors"`
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			warn("parse %s: %v", path, err)
+			continue
+		}
+		registries := make([]string, 0, len(cfg.Mirrors))
+		for registry := range cfg.Mirrors {
+			registries = append(registries, registry)
+		}
+		sort.Strings(registries)
+		for _, registry := range registries {
+			if endpoints := cfg.Mirrors[registry].Endpoint; len(endpoints) > 0 {
+				wire(cri, path, registry, endpoints)
+			}
+		}
+	}
+
+	return status, nil
+}
+
+func orUnset(v any) any {
+	if v == nil {
+		return "unset"
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	mirrorVerifyTimeout = time.Minute
+	crioSocket          = "/var/run/crio/crio.sock"
+	containerdSocket    = "/run/containerd/containerd.sock"
+	rancherSocket       = "/run/k3s/containerd/containerd.sock"
+	dockerSocket        = "/var/run/docker.sock"
+)
+
+// MirrorProbe is an image tag only the local registry serves. A runtime can
+// only pull it under the name of an upstream registry through its mirror, so
+// a successful pull shows that the pulls of the runtime reach the satellite.
+type MirrorProbe struct {
+	Repository string
+	Tag        string
+}
+
+// pullThroughRuntime pulls ref with cri and removes the image again.
+// Replaced in tests.
+var pullThroughRuntime = func(ctx context.Context, cri CRIType, ref string) error {
+	switch cri {
+	case CRIContainerd:
+		return pullThroughCRI(ctx, containerdSocket, ref)
+	case CRICrio:
+		return pullThroughCRI(ctx, crioSocket, ref)
+	case CRIK3s, CRIRKE2:
+		return pullThroughCRI(ctx, rancherSocket, ref)
+	case CRIDocker:
+		return pullThroughDocker(ctx, dockerSocket, ref)
+	case CRIPodman:
+		if err := runCommand(ctx, "podman", "pull", "--quiet", ref); err != nil {
+			return err
+		}
+		_ = runCommand(ctx, "podman", "rmi", ref)
+		return nil
+	}
+	return fmt.Errorf("unsupported CRI: %s", cri)
+}
+
+// VerifyCRIMirrors pulls probe through every runtime configured in results,
+// under the name of each upstream registry the runtime mirrors, and records
+// in the results whether all the pulls went through the mirror.
+func VerifyCRIMirrors(ctx context.Context, results []CRIConfigResult, probe MirrorProbe) []CRIConfigResult {
+	verified := make([]CRIConfigResult, len(results))
+	for i, r := range results {
+		verified[i] = r
+		if !r.Success {
+			continue
+		}
+		var errs []error
+		for _, registry := range r.Registries {
+			if r.CRI == CRIDocker {
+				// The daemon only mirrors Docker Hub.
+				registry = "docker.io"
+			}
+			ref := registry + "/" + probe.Repository + ":" + probe.Tag
+			pullCtx, cancel := context.WithTimeout(ctx, mirrorVerifyTimeout)
+			if err := pullThroughRuntime(pullCtx, r.CRI, ref); err != nil {
+				errs = append(errs, fmt.Errorf("pull %s: %w", ref, err))
+			}
+			cancel()
+			if r.CRI == CRIDocker {
+				break
+			}
+		}
+		if len(errs) > 0 {
+			verified[i].VerifyError = errors.Join(errs...).Error()
+		} else {
+			verified[i].Verified = true
+		}
+	}
+	return verified
+}
+
+// pullThroughCRI pulls ref through the image service of the CRI runtime
+// listening on socket.
+func pullThroughCRI(ctx context.Context, socket, ref string) error {
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("CRI endpoint %s: %w", socket, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	images := runtimeapi.NewImageServiceClient(conn)
+	spec := &runtimeapi.ImageSpec{Image: ref}
+	if _, err := images.PullImage(ctx, &runtimeapi.PullImageRequest{Image: spec}); err != nil {
+		return err
+	}
+	_, _ = images.RemoveImage(ctx, &runtimeapi.RemoveImageRequest{Image: spec})
+	return nil
+}
+
+// pullThroughDocker pulls ref through the images/create endpoint of the
+// Engine API, the same call `docker pull` makes.
+func pullThroughDocker(ctx context.Context, socket, ref string) error {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://docker/images/create?fromImage="+url.QueryEscape(ref), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pull through docker: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// The daemon reports pull failures in the JSON stream of a 200 response.
+	var msg struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("read docker pull progress: %w", err)
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker pull: %s %s", resp.Status, msg.Message)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodDelete, "http://docker/images/"+ref, nil)
+	if err == nil {
+		if resp, err := client.Do(req); err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+	return nil
+}
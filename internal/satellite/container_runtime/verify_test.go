@@ -0,0 +1,121 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVerifyCRIMirrors(t *testing.T) {
+	var pulled []string
+	orig := pullThroughRuntime
+	pullThroughRuntime = func(_ context.Context, cri CRIType, ref string) error {
+		pulled = append(pulled, string(cri)+" "+ref)
+		if strings.HasPrefix(ref, "quay.io/") {
+			return errors.New("not found")
+		}
+		return nil
+	}
+	t.Cleanup(func() { pullThroughRuntime = orig })
+
+	results := []CRIConfigResult{
+		{CRI: CRIContainerd, Registries: []string{"docker.io", "quay.io"}, Success: true},
+		{CRI: CRIDocker, Registries: []string{"true"}, Success: true},
+		{CRI: CRICrio, Registries: []string{"docker.io"}, Success: false, Error: "permission denied"},
+	}
+	probe := MirrorProbe{Repository: "harbor-satellite/mirror-probe", Tag: "probe-1"}
+	verified := VerifyCRIMirrors(context.Background(), results, probe)
+
+	want := []string{
+		"containerd docker.io/harbor-satellite/mirror-probe:probe-1",
+		"containerd quay.io/harbor-satellite/mirror-probe:probe-1",
+		"docker docker.io/harbor-satellite/mirror-probe:probe-1",
+	}
+	if !slices.Equal(pulled, want) {
+		t.Errorf("pulled = %q, want %q", pulled, want)
+	}
+	if verified[0].Verified || !strings.Contains(verified[0].VerifyError, "quay.io") {
+		t.Errorf("containerd = %+v, want unverified for quay.io", verified[0])
+	}
+	if !verified[1].Verified {
+		t.Errorf("docker = %+v, want verified", verified[1])
+	}
+	if verified[2].Verified || verified[2].VerifyError != "" {
+		t.Errorf("crio = %+v, want skipped after the failed apply", verified[2])
+	}
+	if results[0].VerifyError != "" {
+		t.Error("results were modified")
+	}
+}
+
+func TestPullThroughDocker(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:   "pulled",
+			status: http.StatusOK,
+			body:   `{"status":"Pulling from harbor-satellite/mirror-probe"}` + "\n" + `{"status":"Digest: sha256:abc"}`,
+		},
+		{
+			name:    "failure in the progress stream",
+			status:  http.StatusOK,
+			body:    `{"status":"Pulling"}` + "\n" + `{"error":"manifest unknown"}`,
+			wantErr: "manifest unknown",
+		},
+		{
+			name:    "error response",
+			status:  http.StatusNotFound,
+			body:    `{"message":"pull access denied"}`,
+			wantErr: "pull access denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			socket := filepath.Join(t.TempDir(), "docker.sock")
+			ln, err := net.Listen("unix", socket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.RequestURI())
+				if r.Method == http.MethodDelete {
+					return
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})}
+			go func() { _ = srv.Serve(ln) }()
+			t.Cleanup(func() { _ = srv.Close() })
+
+			err = pullThroughDocker(context.Background(), socket, "docker.io/harbor-satellite/mirror-probe:probe-1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{
+				"POST /images/create?fromImage=docker.io%2Fharbor-satellite%2Fmirror-probe%3Aprobe-1",
+				"DELETE /images/docker.io/harbor-satellite/mirror-probe:probe-1",
+			}
+			if !slices.Equal(requests, want) {
+				t.Errorf("requests = %q, want %q", requests, want)
+			}
+		})
+	}
+}
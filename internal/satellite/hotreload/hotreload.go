@@ -3,25 +3,88 @@ package hotreload
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/satellite/registry"
 	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/satellite/state"
+	satTLS "github.com/container-registry/harbor-satellite/internal/satellite/tls"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/rs/zerolog"
 	cfg "zotregistry.dev/zot/v2/pkg/api/config"
 	"zotregistry.dev/zot/v2/pkg/cli/server"
 )
 
+// CRIApplier re-resolves and applies the container runtime mirror configs
+// from the current config.
+type CRIApplier func() ([]runtime.CRIConfigResult, error)
+
+// NodeConfigPublisher publishes the node config for node agents from the
+// current config.
+type NodeConfigPublisher func() error
+
+// PendingChange is a config change that was accepted but only takes effect
+// after the satellite restarts.
+type PendingChange struct {
+	Type   config.ConfigChangeType `json:"type"`
+	Reason string                  `json:"reason"`
+}
+
+// Report lists which changes of a reload took effect immediately and which
+// are waiting for a restart.
+type Report struct {
+	Applied        []config.ConfigChangeType
+	PendingRestart []PendingChange
+}
+
+// restartRequiredError is returned by a change handler when the change
+// cannot be applied to the running satellite.
+type restartRequiredError struct {
+	reason string
+}
+
+func (e *restartRequiredError) Error() string {
+	return "restart required: " + e.reason
+}
+
+func restartRequired(format string, args ...any) error {
+	return &restartRequiredError{reason: fmt.Sprintf(format, args...)}
+}
+
+// pendingRestart is a change waiting for a restart, with the value the
+// running satellite was started with.
+type pendingRestart struct {
+	reason  string
+	startup any
+}
+
 type HotReloadManager struct {
-	cm                        *config.ConfigManager
-	log                       *zerolog.Logger
-	ctx                       context.Context
-	zotTempPath               string
+	cm          *config.ConfigManager
+	log         *zerolog.Logger
+	ctx         context.Context
+	zotTempPath string
+
+	// mu guards the dependencies below, which are set while config changes
+	// may already be processed.
+	mu                        sync.RWMutex
 	stateReplicationScheduler *scheduler.Scheduler
+	registrationScheduler     *scheduler.Scheduler
+	statusScheduler           *scheduler.Scheduler
+	statusProcess             *state.StatusReportingProcess
+	applyCRI                  CRIApplier
+	publishNodeConfig         NodeConfigPublisher
+	zotRestarter              ZotRestarter
+	zotOverlay                ZotConfigOverlay
 	changeCallbacks           map[config.ConfigChangeType][]config.ConfigChangeCallback
 	callbackMu                sync.RWMutex
+	pendingMu                 sync.Mutex
+	pending                   map[config.ConfigChangeType]pendingRestart
 }
 
 func NewHotReloadManager(
@@ -38,6 +101,7 @@ func NewHotReloadManager(
 		zotTempPath:               zotTempPath,
 		stateReplicationScheduler: stateReplicationScheduler,
 		changeCallbacks:           make(map[config.ConfigChangeType][]config.ConfigChangeCallback),
+		pending:                   make(map[config.ConfigChangeType]pendingRestart),
 	}
 
 	manager.registerCallbacks()
@@ -49,6 +113,19 @@ func (hrm *HotReloadManager) registerCallbacks() {
 	hrm.registerChangeCallback(config.IntervalsChanged, hrm.handleIntervalsChange)
 	hrm.registerChangeCallback(config.ZotConfigChanged, hrm.handleZotConfigChange)
 	hrm.registerChangeCallback(config.LogLevelChanged, hrm.handleLogLevelChange)
+	hrm.registerChangeCallback(config.RegistrationIntervalChanged, hrm.handleRegistrationIntervalChange)
+	hrm.registerChangeCallback(config.HeartbeatIntervalChanged, hrm.handleHeartbeatIntervalChange)
+	hrm.registerChangeCallback(config.ScheduleChanged, hrm.handleScheduleChange)
+	hrm.registerChangeCallback(config.AuditConfigChanged, hrm.handleAuditChange)
+	hrm.registerChangeCallback(config.MetricsConfigChanged, hrm.handleMetricsChange)
+	hrm.registerChangeCallback(config.LocalRegistryChanged, hrm.handleLocalRegistryChange)
+	hrm.registerChangeCallback(config.TLSConfigChanged, hrm.handleTLSChange)
+	hrm.registerChangeCallback(config.SPIFFEConfigChanged, hrm.handleSPIFFEChange)
+	hrm.registerChangeCallback(config.RegistryFallbackChanged, hrm.handleRegistryFallbackChange)
+	hrm.registerChangeCallback(config.DirectDeliveryChanged, hrm.handleDirectDeliveryChange)
+	hrm.registerChangeCallback(config.PrewarmChanged, hrm.handlePrewarmChange)
+	hrm.registerChangeCallback(config.NodeConfigChanged, hrm.handleNodeConfigChange)
+	hrm.registerChangeCallback(config.EncryptConfigChanged, hrm.handleEncryptConfigChange)
 }
 
 func (hrm *HotReloadManager) notifyChangeCallbacks(change config.ConfigChange) []error {
@@ -57,7 +134,7 @@ func (hrm *HotReloadManager) notifyChangeCallbacks(change config.ConfigChange) [
 
 	callbacks, exists := hrm.changeCallbacks[change.Type]
 	if !exists {
-		return nil
+		return []error{restartRequired("no live handler for %s", change.Type)}
 	}
 
 	var errors []error
@@ -124,18 +201,51 @@ func (hrm *HotReloadManager) handleLogLevelChange(change config.ConfigChange) er
 	return nil
 }
 
+// ZotRestarter restarts the embedded Zot registry with a new config.
+type ZotRestarter interface {
+	Restart(ctx context.Context, zotConfig json.RawMessage, reason string) error
+}
+
+// ZotConfigOverlay rewrites a Zot config before the embedded registry uses it.
+type ZotConfigOverlay func(zotConfig json.RawMessage) (json.RawMessage, error)
+
 func (hrm *HotReloadManager) handleZotConfigChange(change config.ConfigChange) error {
 	hrm.log.Info().
 		Str("type", string(change.Type)).
 		Msg("Handling Zot configuration change")
 
-	hrm.log.Warn().
-		Str("type", string(change.Type)).
-		Msg("Some Zot configuration may require to restart")
+	if hrm.cm.GetOwnRegistry() {
+		hrm.log.Info().Msg("Zot configuration changed, but the embedded registry is not in use")
+		return nil
+	}
+
+	oldRaw, _ := change.OldValue.(json.RawMessage)
+	newRaw := hrm.cm.GetRawZotConfig()
+	if hrm.zotOverlay != nil {
+		if len(oldRaw) > 0 && zotEndpoint(oldRaw) != zotEndpoint(newRaw) {
+			return restartRequired("the endpoint of the secured registry changed")
+		}
+		var err error
+		if len(oldRaw) > 0 {
+			if oldRaw, err = hrm.zotOverlay(oldRaw); err != nil {
+				return fmt.Errorf("unable to secure previous zot configuration: %w", err)
+			}
+		}
+		if newRaw, err = hrm.zotOverlay(newRaw); err != nil {
+			return fmt.Errorf("unable to secure zot configuration: %w", err)
+		}
+	}
+	reason, err := registry.ZotRestartReason(oldRaw, newRaw)
+	if err != nil {
+		return fmt.Errorf("unable to compare zot configurations: %w", err)
+	}
+	if reason != "" {
+		return hrm.restartZot(oldRaw, newRaw, reason)
+	}
 
 	// verify the zot configuration before apply
 	var cfg cfg.Config
-	if err := json.Unmarshal(hrm.cm.GetRawZotConfig(), &cfg); err != nil {
+	if err := json.Unmarshal(newRaw, &cfg); err != nil {
 		return fmt.Errorf("unable to unmarshal zot config: %w, defaulting to previous zot configuration", err)
 	}
 
@@ -145,22 +255,339 @@ func (hrm *HotReloadManager) handleZotConfigChange(change config.ConfigChange) e
 		return err
 	}
 
-	err := os.WriteFile(hrm.zotTempPath, hrm.cm.GetRawZotConfig(), 0o600)
-	if err != nil {
+	if err := os.WriteFile(hrm.zotTempPath, newRaw, 0o600); err != nil {
 		return fmt.Errorf("unable to change zot configuration: %w", err)
 	}
 
 	return nil
 }
 
+// restartZot restarts the embedded registry for a change Zot cannot hot
+// reload, and rewrites the runtime mirror configs when its endpoint moved.
+func (hrm *HotReloadManager) restartZot(oldRaw, newRaw json.RawMessage, reason string) error {
+	if hrm.zotRestarter == nil {
+		return restartRequired("%s", reason)
+	}
+	if err := hrm.zotRestarter.Restart(hrm.ctx, newRaw, reason); err != nil {
+		return fmt.Errorf("unable to restart zot registry: %w", err)
+	}
+	hrm.log.Info().Str("reason", reason).Msg("Zot registry restarted with the new configuration")
+
+	if zotEndpoint(oldRaw) != zotEndpoint(newRaw) && hrm.applyCRI != nil {
+		return hrm.reapplyCRI()
+	}
+	return nil
+}
+
+// zotEndpoint returns the address and port Zot listens on.
+func zotEndpoint(raw json.RawMessage) string {
+	var cfg registry.ZotConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return ""
+	}
+	return cfg.HTTP.Address + ":" + cfg.HTTP.Port
+}
+
+func (hrm *HotReloadManager) handleRegistrationIntervalChange(change config.ConfigChange) error {
+	hrm.log.Info().
+		Str("type", string(change.Type)).
+		Interface("old_value", change.OldValue).
+		Interface("new_value", change.NewValue).
+		Msg("Handling registration interval change")
+
+	// Once registration is done its scheduler has stopped and the interval
+	// no longer matters.
+	if hrm.registrationScheduler != nil && !hrm.cm.IsZTRDone() {
+		if err := hrm.registrationScheduler.ResetIntervalFromExpr(hrm.cm.GetRegistrationInterval()); err != nil {
+			return fmt.Errorf("unable to restart registration scheduler: %w", err)
+		}
+	}
+	return nil
+}
+
+func (hrm *HotReloadManager) handleHeartbeatIntervalChange(change config.ConfigChange) error {
+	hrm.log.Info().
+		Str("type", string(change.Type)).
+		Interface("old_value", change.OldValue).
+		Interface("new_value", change.NewValue).
+		Msg("Handling heartbeat interval change")
+
+	if hrm.statusScheduler != nil {
+		if err := hrm.statusScheduler.ResetIntervalFromExpr(hrm.cm.GetHeartbeatInterval()); err != nil {
+			return fmt.Errorf("unable to restart status report scheduler: %w", err)
+		}
+	}
+	return nil
+}
+
+func (hrm *HotReloadManager) handleScheduleChange(change config.ConfigChange) error {
+	return restartRequired("jitter and spread are fixed when the schedulers are created")
+}
+
+// handleAuditChange only acknowledges the change: the caller swaps the audit
+// logger before the changes are processed so the config.changed event is
+// recorded with the right settings.
+func (hrm *HotReloadManager) handleAuditChange(change config.ConfigChange) error {
+	return nil
+}
+
+func (hrm *HotReloadManager) handleMetricsChange(change config.ConfigChange) error {
+	oldCfg, _ := change.OldValue.(config.MetricsConfig)
+	newCfg, _ := change.NewValue.(config.MetricsConfig)
+
+	// The collect toggles are read on every heartbeat.
+	hrm.log.Info().
+		Bool("collect_cpu", newCfg.CollectCPU).
+		Bool("collect_memory", newCfg.CollectMemory).
+		Bool("collect_storage", newCfg.CollectStorage).
+		Msg("Metrics collection settings updated")
+
+	if oldCfg.ListenAddress != newCfg.ListenAddress {
+		return restartRequired("metrics listener is bound at startup")
+	}
+	return nil
+}
+
+func (hrm *HotReloadManager) handleLocalRegistryChange(change config.ConfigChange) error {
+	oldCfg, _ := change.OldValue.(config.LocalRegistryChange)
+	newCfg, _ := change.NewValue.(config.LocalRegistryChange)
+
+	if oldCfg.BringOwnRegistry != newCfg.BringOwnRegistry {
+		return restartRequired("switching between the embedded and an external registry needs the registry to be set up again")
+	}
+
+	// URL and credentials are read on every sync, so only the runtime mirror
+	// configs that point at the registry need to be rewritten.
+	hrm.log.Info().Str("url", newCfg.URL).Msg("Local registry settings updated, used from the next sync")
+	if oldCfg.URL != newCfg.URL {
+		return hrm.reapplyCRI()
+	}
+	return nil
+}
+
+func (hrm *HotReloadManager) handleTLSChange(change config.ConfigChange) error {
+	tlsCfg := hrm.cm.GetTLSConfig()
+
+	// Ground Control clients are built per request, so a valid config takes
+	// effect immediately. Loading it here surfaces a bad path now rather than
+	// on the next heartbeat.
+	if tlsCfg.CertFile != "" || tlsCfg.CAFile != "" {
+		_, err := satTLS.LoadClientTLSConfig(&satTLS.Config{
+			CertFile:   tlsCfg.CertFile,
+			KeyFile:    tlsCfg.KeyFile,
+			CAFile:     tlsCfg.CAFile,
+			SkipVerify: tlsCfg.SkipVerify,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid TLS config: %w", err)
+		}
+	}
+	hrm.log.Info().Msg("TLS settings updated")
+	return nil
+}
+
+func (hrm *HotReloadManager) handleSPIFFEChange(change config.ConfigChange) error {
+	oldCfg, _ := change.OldValue.(config.SPIFFEConfig)
+	newCfg, _ := change.NewValue.(config.SPIFFEConfig)
+
+	if !hrm.cm.IsZTRDone() && oldCfg.Enabled != newCfg.Enabled {
+		return restartRequired("the registration flow is chosen at startup")
+	}
+	if hrm.statusProcess != nil {
+		if err := hrm.statusProcess.ReloadSPIFFE(); err != nil {
+			return fmt.Errorf("unable to rebuild SPIFFE client: %w", err)
+		}
+	}
+	hrm.log.Info().Bool("enabled", newCfg.Enabled).Msg("SPIFFE settings updated")
+	return nil
+}
+
+func (hrm *HotReloadManager) handleRegistryFallbackChange(change config.ConfigChange) error {
+	newCfg, _ := change.NewValue.(config.RegistryFallbackConfig)
+	// Node agents follow the fallback settings, including disabling it.
+	publishErr := hrm.republishNodeConfig()
+	if !newCfg.Enabled {
+		hrm.log.Warn().Msg("Registry fallback disabled, existing container runtime mirror configs are left in place")
+		return publishErr
+	}
+	return errors.Join(hrm.reapplyCRI(), publishErr)
+}
+
+func (hrm *HotReloadManager) handleDirectDeliveryChange(change config.ConfigChange) error {
+	newCfg, _ := change.NewValue.(config.DirectDeliveryConfig)
+
+	// The deliverer is rebuilt from the config at the start of every sync.
+	hrm.log.Info().
+		Bool("enabled", newCfg.Enabled).
+		Str("backend", newCfg.BackendOrDefault()).
+		Str("image_dir", newCfg.ImageDir).
+		Msg("Direct delivery settings updated, used from the next sync")
+	return nil
+}
+
+func (hrm *HotReloadManager) handlePrewarmChange(change config.ConfigChange) error {
+	oldCfg, _ := change.OldValue.(config.PrewarmConfig)
+	newCfg, _ := change.NewValue.(config.PrewarmConfig)
+
+	// Everything but the agent listener is read at the end of every sync.
+	hrm.log.Info().
+		Bool("enabled", newCfg.Enabled).
+		Str("cri_endpoint", newCfg.CRIEndpoint).
+		Msg("Prewarm settings updated, used from the next sync")
+
+	if oldCfg.ListenAddress != newCfg.ListenAddress {
+		return restartRequired("node agent listener is bound at startup")
+	}
+	return nil
+}
+
+func (hrm *HotReloadManager) handleNodeConfigChange(change config.ConfigChange) error {
+	newCfg, _ := change.NewValue.(config.NodeConfigConfig)
+	if !newCfg.Enabled {
+		hrm.log.Warn().Msg("Node config disabled, the published ConfigMap and the runtime configs of the nodes are left in place")
+		return nil
+	}
+	return hrm.republishNodeConfig()
+}
+
+// republishNodeConfig publishes the node config again, so node agents apply
+// the current settings.
+func (hrm *HotReloadManager) republishNodeConfig() error {
+	if hrm.publishNodeConfig == nil || !hrm.cm.GetNodeConfigConfig().Enabled {
+		return nil
+	}
+	if err := hrm.publishNodeConfig(); err != nil {
+		return fmt.Errorf("unable to publish node config: %w", err)
+	}
+	hrm.log.Info().Msg("Node config published")
+	return nil
+}
+
+func (hrm *HotReloadManager) handleEncryptConfigChange(change config.ConfigChange) error {
+	return restartRequired("config encryption is set up at startup")
+}
+
+// reapplyCRI rewrites the container runtime mirror configs and queues the
+// results for the next heartbeat.
+func (hrm *HotReloadManager) reapplyCRI() error {
+	if hrm.applyCRI == nil {
+		return restartRequired("container runtime configs are applied at startup")
+	}
+
+	results, err := hrm.applyCRI()
+	if err != nil {
+		return fmt.Errorf("unable to re-apply container runtime configs: %w", err)
+	}
+	var errs []error
+	for _, r := range results {
+		if r.Success {
+			hrm.log.Info().Str("cri", string(r.CRI)).Str("backup", r.BackupPath).Msg("Container runtime mirror config re-applied")
+		} else {
+			errs = append(errs, fmt.Errorf("%s: %s", r.CRI, r.Error))
+		}
+		for _, w := range r.Warnings {
+			hrm.log.Warn().Str("cri", string(r.CRI)).Msg(w)
+		}
+		if r.RestartRequired {
+			hrm.log.Warn().Str("cri", string(r.CRI)).Msg("Container runtime needs a restart to apply its mirror config")
+		}
+	}
+	if hrm.statusProcess != nil && len(results) > 0 {
+		hrm.statusProcess.SetPendingCRIResults(results)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to re-apply container runtime configs: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
 func (hrm *HotReloadManager) SetStateReplicationScheduler(stateReplicationScheduler *scheduler.Scheduler) {
+	hrm.mu.Lock()
+	defer hrm.mu.Unlock()
 	hrm.stateReplicationScheduler = stateReplicationScheduler
 }
 
-func (hrm *HotReloadManager) ProcessConfigChanges(changes []config.ConfigChange) error {
+// SetRegistrationScheduler sets the zero-touch registration scheduler whose
+// interval follows register_satellite_interval.
+func (hrm *HotReloadManager) SetRegistrationScheduler(registrationScheduler *scheduler.Scheduler) {
+	hrm.mu.Lock()
+	defer hrm.mu.Unlock()
+	hrm.registrationScheduler = registrationScheduler
+}
+
+// SetStatusReporting sets the heartbeat scheduler and process, so heartbeat
+// interval and SPIFFE changes reach them and re-applied CRI results are
+// reported.
+func (hrm *HotReloadManager) SetStatusReporting(statusScheduler *scheduler.Scheduler, statusProcess *state.StatusReportingProcess) {
+	hrm.mu.Lock()
+	defer hrm.mu.Unlock()
+	hrm.statusScheduler = statusScheduler
+	hrm.statusProcess = statusProcess
+}
+
+// SetCRIApplier sets the function used to re-apply container runtime mirror
+// configs when the local registry or the registry fallback changes.
+func (hrm *HotReloadManager) SetCRIApplier(applyCRI CRIApplier) {
+	hrm.mu.Lock()
+	defer hrm.mu.Unlock()
+	hrm.applyCRI = applyCRI
+}
+
+// SetNodeConfigPublisher sets the function used to publish the node config
+// when it or the registry fallback changes.
+func (hrm *HotReloadManager) SetNodeConfigPublisher(publish NodeConfigPublisher) {
+	hrm.mu.Lock()
+	defer hrm.mu.Unlock()
+	hrm.publishNodeConfig = publish
+}
+
+// SetZotRestarter sets the supervisor of the embedded registry, so changes
+// Zot cannot hot reload restart it instead of waiting for a satellite
+// restart.
+func (hrm *HotReloadManager) SetZotRestarter(zotRestarter ZotRestarter) {
+	hrm.mu.Lock()
+	defer hrm.mu.Unlock()
+	hrm.zotRestarter = zotRestarter
+}
+
+// SetZotConfigOverlay sets the transformation applied to the Zot config
+// before it is used, such as the TLS and auth settings of a secured registry.
+// The endpoint of an overlaid registry only changes on a restart.
+func (hrm *HotReloadManager) SetZotConfigOverlay(overlay ZotConfigOverlay) {
+	hrm.mu.Lock()
+	defer hrm.mu.Unlock()
+	hrm.zotOverlay = overlay
+}
+
+// PendingRestart returns every change accepted since startup that is still
+// waiting for a restart, ordered by type.
+func (hrm *HotReloadManager) PendingRestart() []PendingChange {
+	hrm.pendingMu.Lock()
+	defer hrm.pendingMu.Unlock()
+
+	pending := make([]PendingChange, 0, len(hrm.pending))
+	for t, p := range hrm.pending {
+		pending = append(pending, PendingChange{Type: t, Reason: p.reason})
+	}
+	slices.SortFunc(pending, func(a, b PendingChange) int {
+		return strings.Compare(string(a.Type), string(b.Type))
+	})
+	return pending
+}
+
+// ProcessConfigChanges applies changes to the running satellite. The report
+// lists the changes that took effect and those that need a restart; the
+// error covers changes that failed to apply. A change of a type already
+// waiting for a restart is judged against the value the satellite started
+// with, so applying or reverting it clears the pending restart.
+func (hrm *HotReloadManager) ProcessConfigChanges(changes []config.ConfigChange) (Report, error) {
 	hrm.log.Info().Int("change_count", len(changes)).Msg("Processing configuration changes")
 
-	var errors []error
+	hrm.mu.RLock()
+	defer hrm.mu.RUnlock()
+
+	var report Report
+	var errs []error
 
 	for _, change := range changes {
 		hrm.log.Debug().
@@ -169,14 +596,51 @@ func (hrm *HotReloadManager) ProcessConfigChanges(changes []config.ConfigChange)
 			Interface("new_value", change.NewValue).
 			Msg("Processing configuration change")
 
-		errors = append(errors, hrm.notifyChangeCallbacks(change)...)
+		if startup, ok := hrm.pendingStartup(change.Type); ok {
+			change.OldValue = startup
+		}
+
+		var failed bool
+		for _, err := range hrm.notifyChangeCallbacks(change) {
+			var restartErr *restartRequiredError
+			if errors.As(err, &restartErr) {
+				report.PendingRestart = append(report.PendingRestart, PendingChange{Type: change.Type, Reason: restartErr.reason})
+				hrm.setPending(change.Type, restartErr.reason, change.OldValue)
+				continue
+			}
+			failed = true
+			errs = append(errs, err)
+		}
+		if !failed && !slices.ContainsFunc(report.PendingRestart, func(p PendingChange) bool { return p.Type == change.Type }) {
+			report.Applied = append(report.Applied, change.Type)
+			hrm.clearPending(change.Type)
+		}
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("errors occurred while processing configuration changes: %v", errors)
+	if len(errs) > 0 {
+		return report, fmt.Errorf("errors occurred while processing configuration changes: %v", errs)
 	}
 
 	hrm.log.Info().Msg("All configuration changes processed successfully")
 
-	return nil
+	return report, nil
+}
+
+func (hrm *HotReloadManager) setPending(changeType config.ConfigChangeType, reason string, startup any) {
+	hrm.pendingMu.Lock()
+	defer hrm.pendingMu.Unlock()
+	hrm.pending[changeType] = pendingRestart{reason: reason, startup: startup}
+}
+
+func (hrm *HotReloadManager) clearPending(changeType config.ConfigChangeType) {
+	hrm.pendingMu.Lock()
+	defer hrm.pendingMu.Unlock()
+	delete(hrm.pending, changeType)
+}
+
+func (hrm *HotReloadManager) pendingStartup(changeType config.ConfigChangeType) (any, bool) {
+	hrm.pendingMu.Lock()
+	defer hrm.pendingMu.Unlock()
+	p, ok := hrm.pending[changeType]
+	return p.startup, ok
 }
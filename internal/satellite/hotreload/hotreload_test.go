@@ -0,0 +1,226 @@
+package hotreload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-registry/harbor-satellite/internal/crypto"
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) *HotReloadManager {
+	t.Helper()
+	dir := t.TempDir()
+	cm, err := config.NewConfigManager(
+		filepath.Join(dir, "config.json"),
+		filepath.Join(dir, "prev.json"),
+		"token", "http://gc", false,
+		&config.Config{},
+		crypto.NewAESProvider(),
+	)
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	return NewHotReloadManager(context.Background(), cm, &log, filepath.Join(dir, "zot.json"), nil)
+}
+
+func TestProcessConfigChanges_Report(t *testing.T) {
+	hrm := newTestManager(t)
+
+	report, err := hrm.ProcessConfigChanges([]config.ConfigChange{
+		{Type: config.MetricsConfigChanged, OldValue: config.MetricsConfig{}, NewValue: config.MetricsConfig{CollectCPU: true}},
+		{Type: config.ScheduleChanged},
+		{Type: config.DirectDeliveryChanged, NewValue: config.DirectDeliveryConfig{Enabled: true}},
+		{Type: config.MetricsConfigChanged, OldValue: config.MetricsConfig{}, NewValue: config.MetricsConfig{ListenAddress: ":9464"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []config.ConfigChangeType{config.MetricsConfigChanged, config.DirectDeliveryChanged}, report.Applied)
+	require.Len(t, report.PendingRestart, 2)
+	require.Equal(t, config.ScheduleChanged, report.PendingRestart[0].Type)
+	require.Equal(t, config.MetricsConfigChanged, report.PendingRestart[1].Type)
+
+	// Pending changes are remembered until the satellite restarts.
+	_, err = hrm.ProcessConfigChanges([]config.ConfigChange{{Type: config.LogLevelChanged, NewValue: "info"}})
+	require.NoError(t, err)
+	pending := hrm.PendingRestart()
+	require.Len(t, pending, 2)
+	require.Equal(t, config.MetricsConfigChanged, pending[0].Type)
+	require.Equal(t, config.ScheduleChanged, pending[1].Type)
+}
+
+func TestProcessConfigChanges_ClearsPendingRestart(t *testing.T) {
+	hrm := newTestManager(t)
+	startup := config.MetricsConfig{ListenAddress: ":9464"}
+	changed := config.MetricsConfig{ListenAddress: ":9465"}
+
+	_, err := hrm.ProcessConfigChanges([]config.ConfigChange{{Type: config.MetricsConfigChanged, OldValue: startup, NewValue: changed}})
+	require.NoError(t, err)
+	require.Len(t, hrm.PendingRestart(), 1)
+
+	// A later change is judged against the startup value, so the listener
+	// change is still pending.
+	withCPU := config.MetricsConfig{ListenAddress: ":9465", CollectCPU: true}
+	report, err := hrm.ProcessConfigChanges([]config.ConfigChange{{Type: config.MetricsConfigChanged, OldValue: changed, NewValue: withCPU}})
+	require.NoError(t, err)
+	require.Len(t, report.PendingRestart, 1)
+	require.Len(t, hrm.PendingRestart(), 1)
+
+	// Reverting the listener clears it.
+	reverted := config.MetricsConfig{ListenAddress: ":9464", CollectCPU: true}
+	report, err = hrm.ProcessConfigChanges([]config.ConfigChange{{Type: config.MetricsConfigChanged, OldValue: withCPU, NewValue: reverted}})
+	require.NoError(t, err)
+	require.Equal(t, []config.ConfigChangeType{config.MetricsConfigChanged}, report.Applied)
+	require.Empty(t, hrm.PendingRestart())
+}
+
+func TestProcessConfigChanges_Prewarm(t *testing.T) {
+	hrm := newTestManager(t)
+
+	report, err := hrm.ProcessConfigChanges([]config.ConfigChange{
+		{Type: config.PrewarmChanged, OldValue: config.PrewarmConfig{}, NewValue: config.PrewarmConfig{Enabled: true, NodeName: "edge-1"}},
+		{Type: config.PrewarmChanged, OldValue: config.PrewarmConfig{Enabled: true}, NewValue: config.PrewarmConfig{Enabled: true, ListenAddress: ":9465"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []config.ConfigChangeType{config.PrewarmChanged}, report.Applied)
+	require.Len(t, report.PendingRestart, 1)
+	require.Equal(t, config.PrewarmChanged, report.PendingRestart[0].Type)
+}
+
+func TestProcessConfigChanges_UnknownTypeNeedsRestart(t *testing.T) {
+	hrm := newTestManager(t)
+
+	report, err := hrm.ProcessConfigChanges([]config.ConfigChange{{Type: "unknown"}})
+	require.NoError(t, err)
+	require.Empty(t, report.Applied)
+	require.Len(t, report.PendingRestart, 1)
+}
+
+func TestProcessConfigChanges_ReappliesCRI(t *testing.T) {
+	hrm := newTestManager(t)
+
+	fallback := config.ConfigChange{
+		Type:     config.RegistryFallbackChanged,
+		OldValue: config.RegistryFallbackConfig{},
+		NewValue: config.RegistryFallbackConfig{Enabled: true, Registries: []string{"docker.io"}},
+	}
+
+	t.Run("without an applier the change waits for a restart", func(t *testing.T) {
+		report, err := hrm.ProcessConfigChanges([]config.ConfigChange{fallback})
+		require.NoError(t, err)
+		require.Len(t, report.PendingRestart, 1)
+	})
+
+	t.Run("applier runs on registry fallback and URL changes", func(t *testing.T) {
+		calls := 0
+		hrm.SetCRIApplier(func() ([]runtime.CRIConfigResult, error) {
+			calls++
+			return []runtime.CRIConfigResult{{CRI: runtime.CRIContainerd, Success: true}}, nil
+		})
+
+		report, err := hrm.ProcessConfigChanges([]config.ConfigChange{
+			fallback,
+			{
+				Type:     config.LocalRegistryChanged,
+				OldValue: config.LocalRegistryChange{URL: "http://a:5000"},
+				NewValue: config.LocalRegistryChange{URL: "http://b:5000"},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, calls)
+		require.Equal(t, []config.ConfigChangeType{config.RegistryFallbackChanged, config.LocalRegistryChanged}, report.Applied)
+	})
+
+	t.Run("failed CRI results are reported as errors", func(t *testing.T) {
+		hrm.SetCRIApplier(func() ([]runtime.CRIConfigResult, error) {
+			return nil, errors.New("no zot http section")
+		})
+		report, err := hrm.ProcessConfigChanges([]config.ConfigChange{fallback})
+		require.Error(t, err)
+		require.Empty(t, report.Applied)
+	})
+}
+
+type fakeZotRestarter struct {
+	reasons []string
+	configs []json.RawMessage
+}
+
+func (f *fakeZotRestarter) Restart(_ context.Context, zotConfig json.RawMessage, reason string) error {
+	f.reasons = append(f.reasons, reason)
+	f.configs = append(f.configs, zotConfig)
+	return nil
+}
+
+func TestProcessConfigChanges_RestartsZot(t *testing.T) {
+	hrm := newTestManager(t)
+	oldZot := json.RawMessage(`{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/zot"}}`)
+	newZot := json.RawMessage(`{"http":{"address":"0.0.0.0","port":"8586"},"storage":{"rootDirectory":"/zot"}}`)
+	hrm.cm.With(config.SetZotConfigRaw(newZot))
+	change := config.ConfigChange{Type: config.ZotConfigChanged, OldValue: oldZot, NewValue: newZot}
+
+	t.Run("without a supervisor the change waits for a restart", func(t *testing.T) {
+		report, err := hrm.ProcessConfigChanges([]config.ConfigChange{change})
+		require.NoError(t, err)
+		require.Len(t, report.PendingRestart, 1)
+		require.Equal(t, "zot setting http.port changed", report.PendingRestart[0].Reason)
+	})
+
+	t.Run("the supervisor restarts zot and the runtime configs follow", func(t *testing.T) {
+		restarter := &fakeZotRestarter{}
+		hrm.SetZotRestarter(restarter)
+		calls := 0
+		hrm.SetCRIApplier(func() ([]runtime.CRIConfigResult, error) {
+			calls++
+			return nil, nil
+		})
+
+		report, err := hrm.ProcessConfigChanges([]config.ConfigChange{change})
+		require.NoError(t, err)
+		require.Equal(t, []config.ConfigChangeType{config.ZotConfigChanged}, report.Applied)
+		require.Equal(t, []string{"zot setting http.port changed"}, restarter.reasons)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestProcessConfigChanges_SecuredZot(t *testing.T) {
+	hrm := newTestManager(t)
+	restarter := &fakeZotRestarter{}
+	hrm.SetZotRestarter(restarter)
+	hrm.SetZotConfigOverlay(func(raw json.RawMessage) (json.RawMessage, error) {
+		var cfg map[string]any
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, err
+		}
+		cfg["http"].(map[string]any)["tls"] = map[string]any{"cert": "/secure/tls.crt", "key": "/secure/tls.key"}
+		return json.Marshal(cfg)
+	})
+	oldZot := json.RawMessage(`{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/zot"},"log":{"level":"info"}}`)
+
+	t.Run("the overlay is kept across restarts", func(t *testing.T) {
+		newZot := json.RawMessage(`{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/zot"},"log":{"level":"debug"}}`)
+		hrm.cm.With(config.SetZotConfigRaw(newZot))
+		change := config.ConfigChange{Type: config.ZotConfigChanged, OldValue: oldZot, NewValue: newZot}
+
+		report, err := hrm.ProcessConfigChanges([]config.ConfigChange{change})
+		require.NoError(t, err)
+		require.Equal(t, []config.ConfigChangeType{config.ZotConfigChanged}, report.Applied)
+		require.Len(t, restarter.configs, 1)
+		require.Contains(t, string(restarter.configs[0]), "/secure/tls.crt")
+	})
+
+	t.Run("an endpoint change waits for a restart", func(t *testing.T) {
+		newZot := json.RawMessage(`{"http":{"address":"0.0.0.0","port":"8586"},"storage":{"rootDirectory":"/zot"},"log":{"level":"info"}}`)
+		hrm.cm.With(config.SetZotConfigRaw(newZot))
+		change := config.ConfigChange{Type: config.ZotConfigChanged, OldValue: oldZot, NewValue: newZot}
+
+		report, err := hrm.ProcessConfigChanges([]config.ConfigChange{change})
+		require.NoError(t, err)
+		require.Len(t, report.PendingRestart, 1)
+		require.Equal(t, "the endpoint of the secured registry changed", report.PendingRestart[0].Reason)
+	})
+}
@@ -0,0 +1,298 @@
+// Package metrics defines the Prometheus metrics exported by the satellite and
+// the listener that serves them on /metrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+)
+
+const namespace = "satellite"
+
+// Outcome label values.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeSkipped = "skipped"
+)
+
+// Registry holds every satellite metric. A dedicated registry keeps the
+// satellite's metrics separate from anything the embedded registry registers
+// on the global default.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// ProcessRuns counts scheduled process executions by outcome. For the
+	// replicate_state process this is the sync cycle outcome.
+	ProcessRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "process_runs_total",
+		Help:      "Scheduled process executions by process and outcome.",
+	}, []string{"process", "outcome"})
+
+	// ProcessDuration observes the wall time of scheduled process executions.
+	ProcessDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "process_duration_seconds",
+		Help:      "Duration of scheduled process executions.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"process"})
+
+	// ProcessLastSuccess records when a process last completed without error.
+	ProcessLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "process_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful execution of a process.",
+	}, []string{"process"})
+
+	// Images counts images replicated to or deleted from the local registry.
+	Images = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "images_total",
+		Help:      "Images replicated to or deleted from the local registry by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	// Bytes counts the manifest-declared bytes of images replicated to or
+	// deleted from the local registry.
+	Bytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_bytes_total",
+		Help:      "Config and layer bytes of images replicated to or deleted from the local registry by operation.",
+	}, []string{"operation"})
+
+	// DirectDelivery counts tarballs written to or removed from the image directory.
+	DirectDelivery = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "direct_delivery_tarballs_total",
+		Help:      "Direct delivery tarballs written or removed by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	// StateFetches counts state artifact fetches from the upstream registry.
+	StateFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "state_fetches_total",
+		Help:      "State artifact fetches by kind (digest or artifact) and outcome.",
+	}, []string{"kind", "outcome"})
+
+	// StateFetchDuration observes the latency of state artifact fetches.
+	StateFetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "state_fetch_duration_seconds",
+		Help:      "Latency of state artifact fetches.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"kind"})
+
+	// GroupSynced is 1 when a group's latest state was fully replicated.
+	GroupSynced = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "group_synced",
+		Help:      "Whether the group's latest state was fully replicated (1) or not (0).",
+	}, []string{"group"})
+
+	// GroupEntities reports the number of entities tracked for a group.
+	GroupEntities = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "group_entities",
+		Help:      "Number of image tags tracked for the group.",
+	}, []string{"group"})
+
+	// Heartbeats counts status reports sent to Ground Control by outcome.
+	Heartbeats = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeats_total",
+		Help:      "Status reports sent to Ground Control by outcome.",
+	}, []string{"outcome"})
+
+	// ProcessConsecutiveFailures is the number of consecutive failed runs of
+	// each process; the scheduler backs off exponentially while it grows.
+	ProcessConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "process_consecutive_failures",
+		Help:      "Consecutive failed runs of the process.",
+	}, []string{"process"})
+
+	// BreakerState is the circuit breaker state per upstream dependency:
+	// 0 closed, 1 half-open, 2 open.
+	BreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dependency_breaker_state",
+		Help:      "Circuit breaker state per dependency (0 closed, 1 half-open, 2 open).",
+	}, []string{"dependency"})
+
+	// ConfigRollbacks counts configs from Ground Control rolled back after
+	// failing their probation.
+	ConfigRollbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_rollbacks_total",
+		Help:      "Configs from Ground Control rolled back after failing their probation.",
+	})
+
+	digests = newDigestAgeCollector()
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ProcessRuns,
+		ProcessDuration,
+		ProcessLastSuccess,
+		Images,
+		Bytes,
+		DirectDelivery,
+		StateFetches,
+		StateFetchDuration,
+		GroupSynced,
+		GroupEntities,
+		Heartbeats,
+		ProcessConsecutiveFailures,
+		BreakerState,
+		ConfigRollbacks,
+		digests,
+	)
+}
+
+// ObserveConfigDigest records the config digest currently applied. The age
+// gauge restarts whenever the digest changes.
+func ObserveConfigDigest(digest string) {
+	digests.observe("", digest)
+}
+
+// ObserveGroupState records the state digest currently applied for a group.
+func ObserveGroupState(group, digest string) {
+	digests.observe(group, digest)
+}
+
+// ForgetGroup removes all per-group series for a group that is no longer
+// assigned to the satellite.
+func ForgetGroup(group string) {
+	GroupSynced.DeleteLabelValues(group)
+	GroupEntities.DeleteLabelValues(group)
+	digests.forget(group)
+}
+
+// Handler returns the HTTP handler exposing the satellite registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Serve exposes /metrics on address until the context is cancelled.
+func Serve(ctx context.Context, address string, log zerolog.Logger) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", address, err)
+	}
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info().Str("address", address).Msg("Metrics endpoint listening")
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("Metrics endpoint shutdown failed")
+		}
+		return nil
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("metrics server: %w", err)
+	}
+}
+
+// digestAgeCollector reports how long the current config and group state
+// digests have been in effect, computed at scrape time.
+type digestAgeCollector struct {
+	mu         sync.Mutex
+	config     digestSeen
+	groups     map[string]digestSeen
+	configDesc *prometheus.Desc
+	groupDesc  *prometheus.Desc
+}
+
+type digestSeen struct {
+	digest string
+	since  time.Time
+}
+
+func newDigestAgeCollector() *digestAgeCollector {
+	return &digestAgeCollector{
+		groups: make(map[string]digestSeen),
+		configDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "config_digest_age_seconds"),
+			"Seconds since the currently applied config digest was first observed.",
+			nil, nil,
+		),
+		groupDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "state_digest_age_seconds"),
+			"Seconds since the currently applied group state digest was first observed.",
+			[]string{"group"}, nil,
+		),
+	}
+}
+
+// observe records digest for a group, or for the config when group is empty.
+func (c *digestAgeCollector) observe(group, digest string) {
+	if digest == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if group == "" {
+		if c.config.digest != digest {
+			c.config = digestSeen{digest: digest, since: time.Now()}
+		}
+		return
+	}
+	if c.groups[group].digest != digest {
+		c.groups[group] = digestSeen{digest: digest, since: time.Now()}
+	}
+}
+
+func (c *digestAgeCollector) forget(group string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.groups, group)
+}
+
+func (c *digestAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.configDesc
+	ch <- c.groupDesc
+}
+
+func (c *digestAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.config.digest != "" {
+		ch <- prometheus.MustNewConstMetric(c.configDesc, prometheus.GaugeValue, now.Sub(c.config.since).Seconds())
+	}
+	for group, seen := range c.groups {
+		ch <- prometheus.MustNewConstMetric(c.groupDesc, prometheus.GaugeValue, now.Sub(seen.since).Seconds(), group)
+	}
+}
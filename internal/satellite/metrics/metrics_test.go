@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestAgeCollector(t *testing.T) {
+	c := newDigestAgeCollector()
+	require.Equal(t, 0, testutil.CollectAndCount(c))
+
+	c.observe("", "sha256:config")
+	c.observe("edge", "sha256:a")
+	c.observe("core", "sha256:b")
+	require.Equal(t, 3, testutil.CollectAndCount(c))
+
+	since := c.groups["edge"].since
+	c.observe("edge", "sha256:a")
+	require.Equal(t, since, c.groups["edge"].since, "unchanged digest must keep its age")
+
+	c.observe("edge", "sha256:c")
+	require.Equal(t, "sha256:c", c.groups["edge"].digest)
+
+	c.forget("core")
+	require.Equal(t, 2, testutil.CollectAndCount(c))
+
+	c.observe("", "")
+	require.Equal(t, "sha256:config", c.config.digest, "empty digest must be ignored")
+}
+
+func TestForgetGroup(t *testing.T) {
+	GroupSynced.WithLabelValues("forget-me").Set(1)
+	GroupEntities.WithLabelValues("forget-me").Set(3)
+
+	ForgetGroup("forget-me")
+
+	require.Equal(t, 0, testutil.CollectAndCount(GroupSynced))
+	require.Equal(t, 0, testutil.CollectAndCount(GroupEntities))
+}
+
+func TestHandlerExposesSatelliteMetrics(t *testing.T) {
+	Heartbeats.WithLabelValues(OutcomeSuccess).Inc()
+
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(body), `satellite_heartbeats_total{outcome="success"}`))
+	require.True(t, strings.Contains(string(body), "go_goroutines"))
+}
@@ -0,0 +1,122 @@
+package nodeconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/rs/zerolog"
+)
+
+// Agent applies the node config of a satellite to the runtime configs of
+// the node it runs on and reports the result back to the satellite.
+type Agent struct {
+	// ConfigPath is where the ConfigMap key is mounted, e.g.
+	// "/etc/harbor-satellite/node-config/node-config.json".
+	ConfigPath string
+	Node       string
+	Interval   time.Duration
+	// Apply writes the mirror configs of cfg to the runtimes of the node.
+	Apply func(cfg Config) ([]runtime.CRIConfigResult, error)
+	// Token authenticates the agent to the node agent API.
+	Token  string
+	Client *http.Client
+	Log    zerolog.Logger
+
+	// report is the result of the last apply, sent on every round so the
+	// satellite keeps the node.
+	report *NodeReport
+	// applied is the digest of the config last applied without errors.
+	applied string
+}
+
+// Run applies the node config every Interval until the context is
+// cancelled. Failed rounds are logged and retried on the next tick.
+func (a *Agent) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		if err := a.RunOnce(ctx); err != nil {
+			a.Log.Warn().Err(err).Msg("Node config round failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce reads the node config, applies it when it changed or the last
+// apply failed, and reports the result. The kubelet updates the mounted
+// ConfigMap when the satellite publishes a new config.
+func (a *Agent) RunOnce(ctx context.Context) error {
+	cfg, err := ReadConfig(a.ConfigPath)
+	if err != nil {
+		return err
+	}
+	digest, err := cfg.Digest()
+	if err != nil {
+		return err
+	}
+
+	if digest != a.applied {
+		report := &NodeReport{ConfigDigest: digest, AppliedAt: time.Now().UTC()}
+		results, err := a.Apply(cfg)
+		report.Runtimes = RuntimeResults(results)
+		failed := err != nil
+		if err != nil {
+			report.Error = err.Error()
+			a.Log.Warn().Err(err).Msg("Unable to apply node config")
+		}
+		for _, r := range results {
+			log := a.Log.With().Str("cri", string(r.CRI)).Logger()
+			if !r.Success {
+				failed = true
+				log.Warn().Str("error", r.Error).Msg("Container runtime mirror config failed")
+				continue
+			}
+			log.Info().Bool("reloaded", r.Reloaded).Bool("restart_required", r.RestartRequired).Msg("Container runtime mirror config applied")
+		}
+		a.report = report
+		if !failed {
+			a.applied = digest
+		}
+	}
+
+	if cfg.ReportURL == "" {
+		return nil
+	}
+	return a.send(ctx, cfg.ReportURL)
+}
+
+func (a *Agent) send(ctx context.Context, reportURL string) error {
+	payload, err := json.Marshal(a.report)
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(reportURL, "/") + "/v1/nodeconfig/nodes/" + url.PathEscape(a.Node)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("report node config result: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("report node config result: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
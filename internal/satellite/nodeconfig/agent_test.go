@@ -0,0 +1,105 @@
+package nodeconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, path string, cfg Config) {
+	t.Helper()
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestAgentRunOnce(t *testing.T) {
+	c := NewCollector()
+	handler := Handler(c)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), ConfigKey)
+	cfg := Config{
+		Mirror:    "10.43.0.10:8585",
+		ReportURL: srv.URL + "/",
+		RegistryFallback: config.RegistryFallbackConfig{
+			Enabled:    true,
+			Registries: []string{"docker.io"},
+		},
+	}
+	writeConfig(t, path, cfg)
+	digest, err := cfg.Digest()
+	require.NoError(t, err)
+	c.SetDigest(digest)
+
+	var applied []Config
+	applyErr := errors.New("no runtime found")
+	agent := &Agent{
+		ConfigPath: path,
+		Node:       "worker-1",
+		Apply: func(cfg Config) ([]runtime.CRIConfigResult, error) {
+			applied = append(applied, cfg)
+			if applyErr != nil {
+				return nil, applyErr
+			}
+			return []runtime.CRIConfigResult{{CRI: runtime.CRIContainerd, Success: true}}, nil
+		},
+		Token:  "s3cret",
+		Client: srv.Client(),
+		Log:    zerolog.Nop(),
+	}
+
+	// A failed apply is reported and retried on the next round.
+	require.NoError(t, agent.RunOnce(context.Background()))
+	statuses := c.Status()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "no runtime found", statuses[0].Error)
+
+	applyErr = nil
+	require.NoError(t, agent.RunOnce(context.Background()))
+	require.NoError(t, agent.RunOnce(context.Background()))
+	require.Len(t, applied, 2, "an applied config is not applied again")
+	require.Equal(t, "10.43.0.10:8585", applied[1].Mirror)
+
+	statuses = c.Status()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "worker-1", statuses[0].Node)
+	require.True(t, statuses[0].Current)
+	require.Empty(t, statuses[0].Error)
+	require.Equal(t, []RuntimeResult{{CRI: "containerd", Success: true}}, statuses[0].Runtimes)
+
+	// A new config is applied once the kubelet updates the mounted file.
+	cfg.RegistryFallback.Registries = append(cfg.RegistryFallback.Registries, "quay.io")
+	writeConfig(t, path, cfg)
+	require.NoError(t, agent.RunOnce(context.Background()))
+	require.Len(t, applied, 3)
+	require.False(t, c.Status()[0].Current)
+}
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ReadConfig(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+
+	path := filepath.Join(dir, ConfigKey)
+	writeConfig(t, path, Config{ReportURL: "http://satellite:9465"})
+	_, err = ReadConfig(path)
+	require.ErrorContains(t, err, "no mirror")
+}
@@ -0,0 +1,24 @@
+package nodeconfig
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/container-registry/harbor-satellite/internal/kube"
+)
+
+// Publish writes cfg to the ConfigMap namespace/name and returns its digest.
+func Publish(ctx context.Context, k *kube.Client, namespace, name string, cfg Config) (string, error) {
+	digest, err := cfg.Digest()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := k.ApplyConfigMap(ctx, namespace, name, map[string]string{ConfigKey: string(data)}); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
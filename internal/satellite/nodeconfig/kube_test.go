@@ -0,0 +1,57 @@
+package nodeconfig
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-registry/harbor-satellite/internal/kube"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	var gotPath, gotQuery, gotType, gotAuth string
+	var gotBody map[string]any
+	status := http.StatusCreated
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPatch, r.Method)
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		gotType, gotAuth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &gotBody))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"message":"configmaps is forbidden"}`))
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret-token\n"), 0o600))
+	k := &kube.Client{Server: srv.URL, TokenFile: tokenFile, Namespace: "edge", Client: srv.Client()}
+
+	cfg := Config{Mirror: "10.43.0.10:8585", ReportURL: "http://harbor-satellite.edge.svc:9465"}
+	digest, err := Publish(context.Background(), k, "edge", "harbor-satellite-node-config", cfg)
+	require.NoError(t, err)
+	want, err := cfg.Digest()
+	require.NoError(t, err)
+	require.Equal(t, want, digest)
+
+	require.Equal(t, "/api/v1/namespaces/edge/configmaps/harbor-satellite-node-config", gotPath)
+	require.Equal(t, "fieldManager=harbor-satellite&force=true", gotQuery)
+	require.Equal(t, "application/apply-patch+yaml", gotType)
+	require.Equal(t, "Bearer secret-token", gotAuth)
+	require.Equal(t, "ConfigMap", gotBody["kind"])
+	data, _ := gotBody["data"].(map[string]any)
+	var published Config
+	require.NoError(t, json.Unmarshal([]byte(data[ConfigKey].(string)), &published))
+	require.Equal(t, cfg, published)
+
+	status = http.StatusForbidden
+	_, err = Publish(context.Background(), k, "edge", "harbor-satellite-node-config", cfg)
+	require.ErrorContains(t, err, "configmaps is forbidden")
+}
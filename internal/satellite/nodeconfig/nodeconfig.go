@@ -0,0 +1,212 @@
+// Package nodeconfig configures the container runtimes of every node in a
+// cluster to pull through the satellite. The satellite publishes its registry
+// fallback settings to a ConfigMap, node agents running as a DaemonSet apply
+// them to the runtime configs of their node and report the result back
+// through the node agent API.
+package nodeconfig
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// ConfigKey is the key of the node config in the data of the ConfigMap.
+const ConfigKey = "node-config.json"
+
+// agentTTL is how long the result of a node agent is kept after its last
+// report. Nodes whose agent stopped reporting are dropped from the heartbeat.
+const agentTTL = 10 * time.Minute
+
+// maxAgentNodes bounds the number of nodes whose results are kept.
+const maxAgentNodes = 1000
+
+// ErrTooManyNodes is returned by Report for a new node when maxAgentNodes
+// nodes already report.
+var ErrTooManyNodes = errors.New("too many nodes report")
+
+// Config is what the satellite publishes for the node agents.
+type Config struct {
+	// Mirror is where the runtimes of the nodes reach the local registry.
+	Mirror string `json:"mirror"`
+	// ReportURL is where node agents report their results, empty when they
+	// do not report.
+	ReportURL        string                        `json:"report_url,omitempty"`
+	RegistryFallback config.RegistryFallbackConfig `json:"registry_fallback"`
+	// MirrorCA is the PEM encoded CA of a secured local registry, empty when
+	// it is served without TLS.
+	MirrorCA string `json:"mirror_ca,omitempty"`
+}
+
+// MirrorAuth writes the CA of a secured local registry to caFile and returns
+// the settings runtimes reach the registry with, or nil when it is served
+// without TLS. Nodes pull anonymously: the pull credentials stay with the
+// satellite.
+func (c Config) MirrorAuth(caFile string) (*runtime.MirrorAuth, error) {
+	if c.MirrorCA == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(caFile), 0o755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", filepath.Dir(caFile), err)
+	}
+	if err := os.WriteFile(caFile, []byte(c.MirrorCA), 0o644); err != nil { //nolint:gosec // CA certificates are public
+		return nil, fmt.Errorf("write mirror CA: %w", err)
+	}
+	return &runtime.MirrorAuth{CAFile: caFile}, nil
+}
+
+// Digest identifies a published config, so results can be matched to the
+// config they were applied from.
+func (c Config) Digest() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// ReadConfig reads the node config from path, the ConfigMap key mounted
+// into the node agent pod.
+func ReadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return cfg, fmt.Errorf("read node config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse node config %s: %w", path, err)
+	}
+	if cfg.Mirror == "" {
+		return cfg, fmt.Errorf("node config %s has no mirror", path)
+	}
+	return cfg, nil
+}
+
+// RuntimeResult is the outcome of configuring one runtime of a node.
+type RuntimeResult struct {
+	CRI             string   `json:"cri"`
+	Success         bool     `json:"success"`
+	Error           string   `json:"error,omitempty"`
+	Warnings        []string `json:"warnings,omitempty"`
+	Reloaded        bool     `json:"reloaded,omitempty"`
+	ReloadError     string   `json:"reload_error,omitempty"`
+	RestartRequired bool     `json:"restart_required,omitempty"`
+}
+
+// RuntimeResults converts the results of applying the mirror configs for
+// reporting.
+func RuntimeResults(results []runtime.CRIConfigResult) []RuntimeResult {
+	out := make([]RuntimeResult, 0, len(results))
+	for _, r := range results {
+		out = append(out, RuntimeResult{
+			CRI:             string(r.CRI),
+			Success:         r.Success,
+			Error:           r.Error,
+			Warnings:        r.Warnings,
+			Reloaded:        r.Reloaded,
+			ReloadError:     r.ReloadError,
+			RestartRequired: r.RestartRequired,
+		})
+	}
+	return out
+}
+
+// NodeReport is what a node agent sends with PUT /v1/nodeconfig/nodes/{node}.
+type NodeReport struct {
+	// ConfigDigest is the digest of the config the results were applied
+	// from.
+	ConfigDigest string          `json:"config_digest"`
+	AppliedAt    time.Time       `json:"applied_at"`
+	Runtimes     []RuntimeResult `json:"runtimes"`
+	// Error is set when the config could not be applied at all, e.g. when
+	// no runtime was found on the node.
+	Error string `json:"error,omitempty"`
+}
+
+// NodeStatus is the last result a node agent reported, for the heartbeat.
+type NodeStatus struct {
+	Node string `json:"node"`
+	NodeReport
+	// Current is set when the node applied the config published last.
+	Current    bool      `json:"current"`
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// Collector keeps the results node agents report.
+type Collector struct {
+	mu     sync.Mutex
+	digest string
+	nodes  map[string]NodeStatus
+	now    func() time.Time
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		nodes: make(map[string]NodeStatus),
+		now:   time.Now,
+	}
+}
+
+// SetDigest records the digest of the config published last.
+func (c *Collector) SetDigest(digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.digest = digest
+}
+
+// Report records the result a node agent reported for node. A new node is
+// refused with ErrTooManyNodes once maxAgentNodes nodes report.
+func (c *Collector) Report(node string, report NodeReport) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[node]; !ok {
+		c.expireLocked()
+		if len(c.nodes) >= maxAgentNodes {
+			return ErrTooManyNodes
+		}
+	}
+	c.nodes[node] = NodeStatus{Node: node, NodeReport: report, ReportedAt: c.now().UTC()}
+	return nil
+}
+
+// expireLocked drops the nodes whose agent stopped reporting. Must be called
+// with c.mu held.
+func (c *Collector) expireLocked() {
+	now := c.now()
+	for node, s := range c.nodes {
+		if now.Sub(s.ReportedAt) > agentTTL {
+			delete(c.nodes, node)
+		}
+	}
+}
+
+// Status returns the last result of every node, for the heartbeat. Nodes
+// whose agent stopped reporting are forgotten. It returns nil when no node
+// reported.
+func (c *Collector) Status() []NodeStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expireLocked()
+	var out []NodeStatus
+	for _, s := range c.nodes {
+		s.Current = c.digest != "" && s.ConfigDigest == c.digest
+		out = append(out, s)
+	}
+	slices.SortFunc(out, func(a, b NodeStatus) int {
+		return cmp.Compare(a.Node, b.Node)
+	})
+	return out
+}
@@ -0,0 +1,99 @@
+package nodeconfig
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectorStatus(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.now = func() time.Time { return now }
+	require.Nil(t, c.Status())
+
+	c.SetDigest("sha256:new")
+	require.NoError(t, c.Report("worker-2", NodeReport{ConfigDigest: "sha256:old"}))
+	require.NoError(t, c.Report("worker-1", NodeReport{ConfigDigest: "sha256:new", Runtimes: []RuntimeResult{{CRI: "containerd", Success: true}}}))
+	now = now.Add(agentTTL / 2)
+	require.NoError(t, c.Report("worker-3", NodeReport{ConfigDigest: "sha256:new"}))
+
+	statuses := c.Status()
+	require.Len(t, statuses, 3)
+	require.Equal(t, "worker-1", statuses[0].Node)
+	require.True(t, statuses[0].Current)
+	require.Equal(t, "containerd", statuses[0].Runtimes[0].CRI)
+	require.Equal(t, "worker-2", statuses[1].Node)
+	require.False(t, statuses[1].Current)
+
+	// worker-1 and worker-2 stopped reporting.
+	now = now.Add(agentTTL)
+	statuses = c.Status()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "worker-3", statuses[0].Node)
+}
+
+func TestCollectorCapsNodes(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCollector()
+	c.now = func() time.Time { return now }
+	for i := range maxAgentNodes {
+		require.NoError(t, c.Report(fmt.Sprintf("worker-%d", i), NodeReport{}))
+	}
+	require.ErrorIs(t, c.Report("one-too-many", NodeReport{}), ErrTooManyNodes)
+	require.NoError(t, c.Report("worker-0", NodeReport{}), "known nodes keep reporting")
+
+	// Nodes whose agent stopped reporting make room.
+	now = now.Add(agentTTL + time.Second)
+	require.NoError(t, c.Report("one-too-many", NodeReport{}))
+	require.Len(t, c.Status(), 1)
+}
+
+func TestHandler(t *testing.T) {
+	c := NewCollector()
+	srv := httptest.NewServer(Handler(c))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/v1/nodeconfig/nodes/worker-1", `{"config_digest":"sha256:abc","runtimes":[]}`, http.StatusNoContent},
+		{"/v1/nodeconfig/nodes/-bad", `{}`, http.StatusBadRequest},
+		{"/v1/nodeconfig/nodes/worker-1", `{"runtimes":`, http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(http.MethodPut, srv.URL+tc.path, strings.NewReader(tc.body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, tc.want, resp.StatusCode, tc.path)
+	}
+
+	statuses := c.Status()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "sha256:abc", statuses[0].ConfigDigest)
+}
+
+func TestConfigMirrorAuth(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "node-config", "mirror-ca.crt")
+	auth, err := Config{}.MirrorAuth(caFile)
+	require.NoError(t, err)
+	require.Nil(t, auth, "a registry without TLS needs no auth")
+
+	ca := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	auth, err = Config{MirrorCA: ca}.MirrorAuth(caFile)
+	require.NoError(t, err)
+	require.Equal(t, &runtime.MirrorAuth{CAFile: caFile}, auth)
+	data, err := os.ReadFile(caFile)
+	require.NoError(t, err)
+	require.Equal(t, ca, string(data))
+}
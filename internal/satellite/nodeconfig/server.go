@@ -0,0 +1,37 @@
+package nodeconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// maxReportBytes bounds the body of a node report.
+const maxReportBytes = 1 << 20
+
+// nodeNamePattern accepts Kubernetes node names and hostnames.
+var nodeNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,252}$`)
+
+// Handler returns the HTTP handler node agents report their results to.
+func Handler(c *Collector) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /v1/nodeconfig/nodes/{node}", func(w http.ResponseWriter, r *http.Request) {
+		node := r.PathValue("node")
+		if !nodeNamePattern.MatchString(node) {
+			http.Error(w, "invalid node name", http.StatusBadRequest)
+			return
+		}
+		var report NodeReport
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportBytes)).Decode(&report); err != nil {
+			http.Error(w, fmt.Sprintf("invalid report: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := c.Report(node, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
@@ -0,0 +1,122 @@
+package prewarm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Agent warms the images of a satellite on the node it runs on, for nodes
+// that pull from the satellite but do not run it, and reports their status
+// back to the satellite.
+type Agent struct {
+	// SatelliteURL is where the satellite serves the node agent API, e.g.
+	// "http://satellite.edge:9465".
+	SatelliteURL string
+	// Token authenticates the agent to the node agent API.
+	Token       string
+	Node        string
+	Puller      Puller
+	Interval    time.Duration
+	PullTimeout time.Duration
+	Client      *http.Client
+	Log         zerolog.Logger
+}
+
+// Run warms images every Interval until the context is cancelled. Failed
+// rounds are logged and retried on the next tick.
+func (a *Agent) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		if err := a.RunOnce(ctx); err != nil {
+			a.Log.Warn().Err(err).Msg("Prewarm round failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce fetches the images from the satellite, warms them in the local
+// runtime and reports the result.
+func (a *Agent) RunOnce(ctx context.Context) error {
+	images, err := a.fetchImages(ctx)
+	if err != nil {
+		return err
+	}
+	statuses := WarmImages(ctx, a.Node, a.Puller, images, a.PullTimeout, time.Now)
+	warm := 0
+	for _, s := range statuses {
+		if s.State == StateWarm {
+			warm++
+		}
+	}
+	a.Log.Info().Int("images", len(images)).Int("warm", warm).Msg("Prewarm round completed")
+	return a.report(ctx, statuses)
+}
+
+func (a *Agent) fetchImages(ctx context.Context) ([]Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.endpoint("/v1/prewarm/images"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch images: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch images: %s", statusError(resp))
+	}
+	var body ImagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode images: %w", err)
+	}
+	return body.Images, nil
+}
+
+func (a *Agent) report(ctx context.Context, statuses []Status) error {
+	payload, err := json.Marshal(NodeReport{Statuses: statuses})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.endpoint("/v1/prewarm/nodes/"+url.PathEscape(a.Node)), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("report status: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("report status: %s", statusError(resp))
+	}
+	return nil
+}
+
+func (a *Agent) endpoint(path string) string {
+	return strings.TrimSuffix(a.SatelliteURL, "/") + path
+}
+
+func statusError(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return fmt.Sprintf("%s: %s", resp.Status, msg)
+	}
+	return resp.Status
+}
@@ -0,0 +1,103 @@
+package prewarm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRunOnce(t *testing.T) {
+	p := NewPrewarmer()
+	p.Warm(context.Background(), "satellite-node", nil, []Image{nginx, alpine}, time.Minute)
+	srv := httptest.NewServer(RequireToken("s3cret", Handler(p)))
+	defer srv.Close()
+
+	agent := &Agent{
+		SatelliteURL: srv.URL + "/",
+		Token:        "s3cret",
+		Node:         "worker-1",
+		Puller:       newFakePuller(map[string]string{nginx.Ref: nginx.Digest}),
+		PullTimeout:  time.Minute,
+		Client:       srv.Client(),
+		Log:          zerolog.Nop(),
+	}
+	require.NoError(t, agent.RunOnce(context.Background()))
+
+	statuses := p.Status()
+	require.Len(t, statuses, 2)
+	require.Equal(t, "worker-1", statuses[0].Node)
+	require.Equal(t, alpine.Ref, statuses[0].Ref)
+	require.Equal(t, StateFailed, statuses[0].State)
+	require.Equal(t, nginx.Ref, statuses[1].Ref)
+	require.Equal(t, StateWarm, statuses[1].State)
+
+	agent.Token = "wrong"
+	require.ErrorContains(t, agent.RunOnce(context.Background()), "401")
+}
+
+func TestRequireToken(t *testing.T) {
+	srv := httptest.NewServer(RequireToken("s3cret", Handler(NewPrewarmer())))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		header string
+		want   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusOK},
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/prewarm/images", nil)
+		require.NoError(t, err)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, tc.want, resp.StatusCode, tc.header)
+	}
+
+	t.Run("an empty token refuses everything", func(t *testing.T) {
+		srv := httptest.NewServer(RequireToken("", Handler(NewPrewarmer())))
+		defer srv.Close()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/prewarm/images", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer ")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(Handler(NewPrewarmer()))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/prewarm/images")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, tc := range []struct {
+		path, body string
+	}{
+		{"/v1/prewarm/nodes/-bad", `{"statuses":[]}`},
+		{"/v1/prewarm/nodes/worker-1", `{"statuses":`},
+	} {
+		req, err := http.NewRequest(http.MethodPut, srv.URL+tc.path, strings.NewReader(tc.body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, tc.path)
+	}
+}
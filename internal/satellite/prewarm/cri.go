@@ -0,0 +1,92 @@
+package prewarm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const unixPrefix = "unix://"
+
+// criSockets are the CRI sockets tried, in order, when no endpoint is
+// configured: k3s and RKE2, containerd, CRI-O and cri-dockerd.
+var criSockets = []string{
+	"/run/k3s/containerd/containerd.sock",
+	"/run/containerd/containerd.sock",
+	"/run/crio/crio.sock",
+	"/run/cri-dockerd.sock",
+}
+
+// DetectCRIEndpoint returns the first well-known CRI socket present on the
+// host.
+func DetectCRIEndpoint() (string, error) {
+	for _, socket := range criSockets {
+		if fi, err := os.Stat(socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return unixPrefix + socket, nil
+		}
+	}
+	return "", errors.New("no CRI socket found, set prewarm.cri_endpoint")
+}
+
+// CRIPuller pulls images through the image service of a CRI runtime. Pulls
+// go through the registry mirrors of the runtime, so with the satellite
+// configured as a mirror the layers come from the local registry.
+type CRIPuller struct {
+	conn   *grpc.ClientConn
+	images runtimeapi.ImageServiceClient
+}
+
+// NewCRIPuller connects to the CRI endpoint, a unix socket path with or
+// without the unix:// scheme, or the detected socket when empty. The
+// connection is made on first use.
+func NewCRIPuller(endpoint string) (*CRIPuller, error) {
+	if endpoint == "" {
+		detected, err := DetectCRIEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		endpoint = detected
+	}
+	if !strings.HasPrefix(endpoint, unixPrefix) {
+		endpoint = unixPrefix + endpoint
+	}
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("CRI endpoint %s: %w", endpoint, err)
+	}
+	return &CRIPuller{conn: conn, images: runtimeapi.NewImageServiceClient(conn)}, nil
+}
+
+func (c *CRIPuller) Pull(ctx context.Context, ref string) error {
+	_, err := c.images.PullImage(ctx, &runtimeapi.PullImageRequest{
+		Image: &runtimeapi.ImageSpec{Image: ref},
+	})
+	return err
+}
+
+func (c *CRIPuller) Present(ctx context.Context, ref, digest string) (bool, error) {
+	resp, err := c.images.ImageStatus(ctx, &runtimeapi.ImageStatusRequest{
+		Image: &runtimeapi.ImageSpec{Image: ref},
+	})
+	if err != nil {
+		return false, err
+	}
+	if resp.GetImage() == nil {
+		return false, nil
+	}
+	// Repo digests have the form "repository@sha256:...".
+	return slices.ContainsFunc(resp.GetImage().GetRepoDigests(), func(d string) bool {
+		return strings.HasSuffix(d, "@"+digest)
+	}), nil
+}
+
+func (c *CRIPuller) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,71 @@
+package prewarm
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// fakeImageService is a CRI image service holding the images it pulled.
+type fakeImageService struct {
+	runtimeapi.UnimplementedImageServiceServer
+	mu     sync.Mutex
+	images map[string]*runtimeapi.Image
+}
+
+func (f *fakeImageService) PullImage(_ context.Context, req *runtimeapi.PullImageRequest) (*runtimeapi.PullImageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ref := req.GetImage().GetImage()
+	f.images[ref] = &runtimeapi.Image{
+		Id:          "sha256:config",
+		RepoTags:    []string{ref},
+		RepoDigests: []string{"harbor.example/library/nginx@sha256:aaa"},
+	}
+	return &runtimeapi.PullImageResponse{ImageRef: "sha256:config"}, nil
+}
+
+func (f *fakeImageService) ImageStatus(_ context.Context, req *runtimeapi.ImageStatusRequest) (*runtimeapi.ImageStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &runtimeapi.ImageStatusResponse{Image: f.images[req.GetImage().GetImage()]}, nil
+}
+
+func newFakeCRI(t *testing.T) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "cri.sock")
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	runtimeapi.RegisterImageServiceServer(srv, &fakeImageService{images: map[string]*runtimeapi.Image{}})
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(srv.Stop)
+	return socket
+}
+
+func TestCRIPuller(t *testing.T) {
+	ctx := context.Background()
+	puller, err := NewCRIPuller(newFakeCRI(t))
+	require.NoError(t, err)
+	defer func() { _ = puller.Close() }()
+
+	present, err := puller.Present(ctx, nginx.Ref, nginx.Digest)
+	require.NoError(t, err)
+	require.False(t, present)
+
+	require.NoError(t, puller.Pull(ctx, nginx.Ref))
+
+	present, err = puller.Present(ctx, nginx.Ref, nginx.Digest)
+	require.NoError(t, err)
+	require.True(t, present)
+
+	present, err = puller.Present(ctx, nginx.Ref, "sha256:other")
+	require.NoError(t, err)
+	require.False(t, present)
+}
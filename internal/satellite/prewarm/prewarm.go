@@ -0,0 +1,237 @@
+// Package prewarm pulls the images a satellite replicates into the container
+// runtime of its node, and of the nodes running a prewarm agent, so the first
+// pod start after a rollout finds them on disk.
+package prewarm
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// agentTTL is how long the status of a node agent is kept after its last
+// report. Nodes whose agent stopped reporting are dropped from the heartbeat.
+const agentTTL = 10 * time.Minute
+
+// maxAgentNodes bounds the number of nodes whose reports are kept.
+const maxAgentNodes = 1000
+
+// ErrTooManyNodes is returned by Report for a new node when maxAgentNodes
+// nodes already report.
+var ErrTooManyNodes = errors.New("too many nodes report")
+
+// Warm states of an image on a node.
+const (
+	StateWarm    = "warm"
+	StatePending = "pending"
+	StateFailed  = "failed"
+)
+
+// Image is an image to keep warm: the reference pods use and the manifest
+// digest it must resolve to.
+type Image struct {
+	Ref    string `json:"ref"`
+	Digest string `json:"digest"`
+}
+
+// Status is whether an image is in the image store of the runtime of a node.
+type Status struct {
+	Node   string `json:"node"`
+	Ref    string `json:"ref"`
+	Digest string `json:"digest"`
+	// State is warm when the runtime holds the image at Digest, failed when
+	// the last pull did not get it there and pending until it is checked.
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// Puller pulls images through the CRI of a node's runtime.
+type Puller interface {
+	// Pull asks the runtime to pull ref.
+	Pull(ctx context.Context, ref string) error
+	// Present reports whether the runtime holds ref at digest.
+	Present(ctx context.Context, ref, digest string) (bool, error)
+	Close() error
+}
+
+// WarmImages pulls each image the runtime does not hold at its digest yet
+// and returns the status of every image on node. A pull gets at most
+// timeout.
+func WarmImages(ctx context.Context, node string, puller Puller, images []Image, timeout time.Duration, now func() time.Time) []Status {
+	statuses := make([]Status, 0, len(images))
+	for _, img := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		status := Status{Node: node, Ref: img.Ref, Digest: img.Digest, State: StateWarm}
+		if err := warmImage(ctx, puller, img, timeout); err != nil {
+			status.State = StateFailed
+			status.Error = err.Error()
+		}
+		checkedAt := now()
+		status.CheckedAt = &checkedAt
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func warmImage(ctx context.Context, puller Puller, img Image, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	present, err := puller.Present(ctx, img.Ref, img.Digest)
+	if err != nil {
+		return fmt.Errorf("image status: %w", err)
+	}
+	if present {
+		return nil
+	}
+	if err := puller.Pull(ctx, img.Ref); err != nil {
+		return fmt.Errorf("pull: %w", err)
+	}
+	present, err = puller.Present(ctx, img.Ref, img.Digest)
+	if err != nil {
+		return fmt.Errorf("image status: %w", err)
+	}
+	if !present {
+		return fmt.Errorf("runtime holds %s at a digest other than %s", img.Ref, img.Digest)
+	}
+	return nil
+}
+
+// Prewarmer keeps the images the satellite replicated warm on its own node
+// and collects the status node agents report for theirs.
+type Prewarmer struct {
+	mu     sync.Mutex
+	images []Image
+	// statuses holds the last status of each image, by node and reference.
+	statuses map[string]map[string]Status
+	// reportedAt is when each node agent last reported.
+	reportedAt map[string]time.Time
+	now        func() time.Time
+}
+
+func NewPrewarmer() *Prewarmer {
+	return &Prewarmer{
+		statuses:   make(map[string]map[string]Status),
+		reportedAt: make(map[string]time.Time),
+		now:        time.Now,
+	}
+}
+
+// Warm makes images the set every node should hold and pulls those the
+// local runtime does not hold yet: images new since the last call, changed
+// to another digest, or that failed before. Images found warm are not
+// checked again. With a nil puller only node agents are served the images.
+func (p *Prewarmer) Warm(ctx context.Context, node string, puller Puller, images []Image, timeout time.Duration) {
+	p.mu.Lock()
+	p.images = slices.Clone(images)
+	warm := p.statuses[node]
+	var pending []Image
+	for _, img := range images {
+		if s, ok := warm[img.Ref]; !ok || s.Digest != img.Digest || s.State != StateWarm {
+			pending = append(pending, img)
+		}
+	}
+	p.mu.Unlock()
+	if puller == nil || len(pending) == 0 {
+		return
+	}
+
+	statuses := WarmImages(ctx, node, puller, pending, timeout, p.now)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	byRef := make(map[string]Status, len(images))
+	for _, img := range images {
+		if s, ok := warm[img.Ref]; ok && s.Digest == img.Digest && s.State == StateWarm {
+			byRef[img.Ref] = s
+		}
+	}
+	for _, s := range statuses {
+		byRef[s.Ref] = s
+	}
+	p.statuses[node] = byRef
+}
+
+// Disable forgets the images and every status, for when prewarming is
+// turned off.
+func (p *Prewarmer) Disable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.images = nil
+	p.statuses = make(map[string]map[string]Status)
+	p.reportedAt = make(map[string]time.Time)
+}
+
+// Images returns the images every node should hold.
+func (p *Prewarmer) Images() []Image {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.images)
+}
+
+// Report records the statuses a node agent reported for node. A new node is
+// refused with ErrTooManyNodes once maxAgentNodes nodes report.
+func (p *Prewarmer) Report(node string, statuses []Status) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.statuses[node]; !ok {
+		p.expireLocked()
+		if len(p.statuses) >= maxAgentNodes {
+			return ErrTooManyNodes
+		}
+	}
+	byRef := make(map[string]Status, len(statuses))
+	for _, s := range statuses {
+		s.Node = node
+		byRef[s.Ref] = s
+	}
+	p.statuses[node] = byRef
+	p.reportedAt[node] = p.now()
+	return nil
+}
+
+// expireLocked drops the nodes whose agent stopped reporting. Must be called
+// with p.mu held.
+func (p *Prewarmer) expireLocked() {
+	now := p.now()
+	for node, at := range p.reportedAt {
+		if now.Sub(at) > agentTTL {
+			delete(p.statuses, node)
+			delete(p.reportedAt, node)
+		}
+	}
+}
+
+// Status returns the status of every image on every node, for the heartbeat.
+// Images a node has not reported yet, or reported at another digest, are
+// pending. It returns nil when there is nothing to prewarm.
+func (p *Prewarmer) Status() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.images) == 0 {
+		return nil
+	}
+	p.expireLocked()
+	var out []Status
+	for node, byRef := range p.statuses {
+		for _, img := range p.images {
+			s, ok := byRef[img.Ref]
+			if !ok || s.Digest != img.Digest {
+				s = Status{Node: node, Ref: img.Ref, Digest: img.Digest, State: StatePending}
+			}
+			out = append(out, s)
+		}
+	}
+	slices.SortFunc(out, func(a, b Status) int {
+		return cmp.Or(cmp.Compare(a.Node, b.Node), cmp.Compare(a.Ref, b.Ref))
+	})
+	return out
+}
@@ -0,0 +1,140 @@
+package prewarm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakePuller is a runtime image store keyed by reference.
+type fakePuller struct {
+	store    map[string]string
+	registry map[string]string
+	pulls    []string
+}
+
+func newFakePuller(registry map[string]string) *fakePuller {
+	return &fakePuller{store: map[string]string{}, registry: registry}
+}
+
+func (f *fakePuller) Pull(_ context.Context, ref string) error {
+	f.pulls = append(f.pulls, ref)
+	digest, ok := f.registry[ref]
+	if !ok {
+		return errors.New("not found")
+	}
+	f.store[ref] = digest
+	return nil
+}
+
+func (f *fakePuller) Present(_ context.Context, ref, digest string) (bool, error) {
+	return f.store[ref] == digest, nil
+}
+
+func (f *fakePuller) Close() error { return nil }
+
+var (
+	nginx  = Image{Ref: "harbor.example/library/nginx:1.27", Digest: "sha256:aaa"}
+	alpine = Image{Ref: "harbor.example/library/alpine:3.20", Digest: "sha256:bbb"}
+)
+
+func TestWarmImages(t *testing.T) {
+	puller := newFakePuller(map[string]string{nginx.Ref: nginx.Digest, alpine.Ref: "sha256:old"})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	statuses := WarmImages(context.Background(), "edge-1", puller, []Image{nginx, alpine}, time.Minute, func() time.Time { return now })
+	require.Len(t, statuses, 2)
+	require.Equal(t, Status{Node: "edge-1", Ref: nginx.Ref, Digest: nginx.Digest, State: StateWarm, CheckedAt: &now}, statuses[0])
+	require.Equal(t, StateFailed, statuses[1].State)
+	require.Contains(t, statuses[1].Error, "at a digest other than sha256:bbb")
+
+	// Images already in the runtime are not pulled again.
+	puller.pulls = nil
+	WarmImages(context.Background(), "edge-1", puller, []Image{nginx}, time.Minute, time.Now)
+	require.Empty(t, puller.pulls)
+}
+
+func TestPrewarmer(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	p := NewPrewarmer()
+	p.now = func() time.Time { return now }
+	require.Nil(t, p.Status())
+
+	puller := newFakePuller(map[string]string{nginx.Ref: nginx.Digest})
+	p.Warm(context.Background(), "edge-1", puller, []Image{nginx, alpine}, time.Minute)
+	require.Equal(t, []Image{nginx, alpine}, p.Images())
+
+	require.NoError(t, p.Report("edge-2", []Status{{Ref: nginx.Ref, Digest: nginx.Digest, State: StateWarm}, {Ref: alpine.Ref, Digest: "sha256:old", State: StateWarm}}))
+
+	states := func() map[string]string {
+		out := map[string]string{}
+		for _, s := range p.Status() {
+			out[s.Node+" "+s.Ref] = s.State
+		}
+		return out
+	}
+	require.Equal(t, map[string]string{
+		"edge-1 " + nginx.Ref:  StateWarm,
+		"edge-1 " + alpine.Ref: StateFailed,
+		"edge-2 " + nginx.Ref:  StateWarm,
+		// Reported at a digest the satellite no longer serves.
+		"edge-2 " + alpine.Ref: StatePending,
+	}, states())
+
+	// Agents that stop reporting are dropped, the local node is kept.
+	now = now.Add(agentTTL + time.Second)
+	require.Equal(t, map[string]string{
+		"edge-1 " + nginx.Ref:  StateWarm,
+		"edge-1 " + alpine.Ref: StateFailed,
+	}, states())
+
+	// Without a local runtime only the image list is updated.
+	p.Warm(context.Background(), "edge-1", nil, []Image{nginx}, time.Minute)
+	require.Equal(t, []Image{nginx}, p.Images())
+	require.Equal(t, map[string]string{"edge-1 " + nginx.Ref: StateWarm}, states())
+
+	p.Disable()
+	require.Nil(t, p.Status())
+	require.Empty(t, p.Images())
+}
+
+func TestPrewarmerWarmsOnlyNewImages(t *testing.T) {
+	p := NewPrewarmer()
+	puller := newFakePuller(map[string]string{nginx.Ref: nginx.Digest})
+	p.Warm(context.Background(), "edge-1", puller, []Image{nginx, alpine}, time.Minute)
+	require.Equal(t, []string{nginx.Ref, alpine.Ref}, puller.pulls)
+
+	// Warm images are not checked again, failed ones are retried.
+	puller.pulls = nil
+	puller.store = map[string]string{}
+	p.Warm(context.Background(), "edge-1", puller, []Image{nginx, alpine}, time.Minute)
+	require.Equal(t, []string{alpine.Ref}, puller.pulls)
+
+	// A new digest is pulled again.
+	puller.pulls = nil
+	changed := Image{Ref: nginx.Ref, Digest: "sha256:ccc"}
+	puller.registry[nginx.Ref] = changed.Digest
+	p.Warm(context.Background(), "edge-1", puller, []Image{changed}, time.Minute)
+	require.Equal(t, []string{nginx.Ref}, puller.pulls)
+	require.Len(t, p.Status(), 1)
+	require.Equal(t, StateWarm, p.Status()[0].State)
+}
+
+func TestPrewarmerCapsNodes(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	p := NewPrewarmer()
+	p.now = func() time.Time { return now }
+	for i := range maxAgentNodes {
+		require.NoError(t, p.Report(fmt.Sprintf("node-%d", i), nil))
+	}
+	require.ErrorIs(t, p.Report("one-too-many", nil), ErrTooManyNodes)
+	require.NoError(t, p.Report("node-0", nil), "known nodes keep reporting")
+
+	// Nodes whose agent stopped reporting make room.
+	now = now.Add(agentTTL + time.Second)
+	require.NoError(t, p.Report("one-too-many", nil))
+}
@@ -0,0 +1,118 @@
+package prewarm
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maxReportBytes bounds the body of a node report.
+const maxReportBytes = 4 << 20
+
+// nodeNamePattern accepts Kubernetes node names and hostnames.
+var nodeNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,252}$`)
+
+// ImagesResponse is returned by GET /v1/prewarm/images.
+type ImagesResponse struct {
+	Images []Image `json:"images"`
+}
+
+// NodeReport is what a node agent sends with PUT /v1/prewarm/nodes/{node}.
+type NodeReport struct {
+	Statuses []Status `json:"statuses"`
+}
+
+// Handler returns the HTTP handler node agents fetch images from and report
+// their status to.
+func Handler(p *Prewarmer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/prewarm/images", func(w http.ResponseWriter, r *http.Request) {
+		images := p.Images()
+		if images == nil {
+			images = []Image{}
+		}
+		writeJSON(w, http.StatusOK, ImagesResponse{Images: images})
+	})
+	mux.HandleFunc("PUT /v1/prewarm/nodes/{node}", func(w http.ResponseWriter, r *http.Request) {
+		node := r.PathValue("node")
+		if !nodeNamePattern.MatchString(node) {
+			http.Error(w, "invalid node name", http.StatusBadRequest)
+			return
+		}
+		var report NodeReport
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportBytes)).Decode(&report); err != nil {
+			http.Error(w, fmt.Sprintf("invalid report: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := p.Report(node, report.Statuses); err != nil {
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// RequireToken serves next only to requests carrying token as a bearer
+// token, the shared secret of the satellite and its node agents.
+func RequireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if token == "" || subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve serves the node agent API on address until the context is
+// cancelled. handler holds the prewarm routes, along with any other routes
+// node agents use.
+func Serve(ctx context.Context, address string, handler http.Handler, log zerolog.Logger) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", address, err)
+	}
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info().Str("address", address).Msg("Node agent API listening")
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("Node agent API shutdown failed")
+		}
+		return nil
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("node agent API server: %w", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
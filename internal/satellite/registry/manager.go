@@ -28,32 +28,6 @@ func NewZotManager(log zerolog.Logger, zotConfig json.RawMessage, tempConfPath s
 	}
 }
 
-func (zm *ZotManager) HandleRegistrySetup(ctx context.Context) error {
-	err := zm.WriteTempZotConfig()
-	if err != nil {
-		return fmt.Errorf("error writing temp zot config to disk: %w", err)
-	}
-
-	defer func() {
-		err := zm.RemoveTempZotConfig(zm.tempConfPath)
-		if err != nil {
-			zm.log.Warn().Err(err).Msg("Failed to remove temp zot config")
-		} else {
-			zm.log.Debug().Str("path", zm.tempConfPath).Msg("Temp zot config removed")
-		}
-	}()
-
-	if err := zm.VerifyRegistryConfig(zm.tempConfPath); err != nil {
-		return fmt.Errorf("error verifying registry config: %w", err)
-	}
-
-	if err := zm.LaunchZotRegistry(ctx, zm.tempConfPath); err != nil {
-		return fmt.Errorf("error launching default zot registry: %w", err)
-	}
-
-	return nil
-}
-
 // WriteTempZotConfig creates a temp file and writes the zot config to it.
 func (zm *ZotManager) WriteTempZotConfig() error {
 	zm.log.Debug().Msg("Creating temporary zot config file")
@@ -0,0 +1,381 @@
+package registry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Accounts of a secured registry.
+const (
+	// PushUser is the account the satellite replicates images with.
+	PushUser = "satellite"
+	// PullUser is the read-only account of the container runtimes.
+	PullUser = "satellite-pull"
+)
+
+// Files of a secured registry, inside SecureOptions.Dir.
+const (
+	secureCAFile          = "ca.crt"
+	secureCAKeyFile       = "ca.key"
+	secureCertFile        = "tls.crt"
+	secureKeyFile         = "tls.key"
+	secureHtpasswdFile    = "htpasswd"
+	secureCredentialsFile = "credentials.json"
+)
+
+const (
+	localCAValidity   = 10 * 365 * 24 * time.Hour
+	localCertValidity = 365 * 24 * time.Hour
+)
+
+// SecureOptions configures ProvisionSecureRegistry.
+type SecureOptions struct {
+	// Dir holds the CA, the serving certificate and the accounts.
+	Dir string
+	// Hosts are the DNS names and IP addresses the local CA issues the
+	// certificate for, on top of localhost.
+	Hosts []string
+	// SPIFFEID is added to the certificate as a URI SAN, so SPIFFE aware
+	// clients can check the identity of the registry as well.
+	SPIFFEID string
+	// AnonymousPull lets clients without credentials pull.
+	AnonymousPull bool
+}
+
+// SecureMaterial is a provisioned secure registry: the files Zot and its
+// clients are configured with, and the passwords of its accounts.
+type SecureMaterial struct {
+	CAFile        string
+	CertFile      string
+	KeyFile       string
+	HtpasswdFile  string
+	PushPassword  string
+	PullPassword  string
+	AnonymousPull bool
+	// NotBefore and NotAfter bound the validity of the serving certificate.
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// NeedsRenewal reports whether the serving certificate is in the last third
+// of its validity.
+func (m *SecureMaterial) NeedsRenewal(now time.Time) bool {
+	lifetime := m.NotAfter.Sub(m.NotBefore)
+	return now.After(m.NotBefore.Add(lifetime * 2 / 3))
+}
+
+type secureCredentials struct {
+	PushPassword string `json:"push_password"`
+	PullPassword string `json:"pull_password"`
+}
+
+// ProvisionSecureRegistry makes sure opts.Dir holds a valid serving
+// certificate and the accounts of a secured registry. The local CA, the
+// certificate and the passwords are kept across calls, so runtime configs
+// written earlier stay valid; the certificate is reissued when it nears
+// expiry or its hosts change.
+func ProvisionSecureRegistry(opts SecureOptions) (*SecureMaterial, error) {
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create %s: %w", opts.Dir, err)
+	}
+	m := &SecureMaterial{
+		CAFile:        filepath.Join(opts.Dir, secureCAFile),
+		CertFile:      filepath.Join(opts.Dir, secureCertFile),
+		KeyFile:       filepath.Join(opts.Dir, secureKeyFile),
+		HtpasswdFile:  filepath.Join(opts.Dir, secureHtpasswdFile),
+		AnonymousPull: opts.AnonymousPull,
+	}
+
+	if err := issueLocalCert(opts.Dir, opts.Hosts, opts.SPIFFEID, m); err != nil {
+		return nil, err
+	}
+
+	creds, err := loadOrCreateCredentials(filepath.Join(opts.Dir, secureCredentialsFile))
+	if err != nil {
+		return nil, err
+	}
+	m.PushPassword = creds.PushPassword
+	m.PullPassword = creds.PullPassword
+	if err := writeHtpasswd(m.HtpasswdFile, creds); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SecureZotConfig returns zotConfig serving TLS with m's certificate and
+// requiring the accounts of m: the push account may do anything, the pull
+// account, and anonymous clients when allowed, may only read. Any TLS, auth
+// or access control settings of zotConfig are replaced.
+func SecureZotConfig(zotConfig json.RawMessage, m *SecureMaterial) (json.RawMessage, error) {
+	var cfg map[string]any
+	if err := json.Unmarshal(zotConfig, &cfg); err != nil {
+		return nil, fmt.Errorf("could not unmarshal zot config: %w", err)
+	}
+	httpCfg, ok := cfg["http"].(map[string]any)
+	if !ok {
+		return nil, errors.New("zot config has no http section")
+	}
+
+	repoPolicy := map[string]any{
+		"policies":      []any{map[string]any{"users": []string{PullUser}, "actions": []string{"read"}}},
+		"defaultPolicy": []string{},
+	}
+	if m.AnonymousPull {
+		repoPolicy["anonymousPolicy"] = []string{"read"}
+	}
+	httpCfg["tls"] = map[string]any{"cert": m.CertFile, "key": m.KeyFile}
+	httpCfg["auth"] = map[string]any{"htpasswd": map[string]any{"path": m.HtpasswdFile}}
+	httpCfg["accessControl"] = map[string]any{
+		"repositories": map[string]any{"**": repoPolicy},
+		"adminPolicy": map[string]any{
+			"users":   []string{PushUser},
+			"actions": []string{"read", "create", "update", "delete"},
+		},
+	}
+
+	out, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal zot config: %w", err)
+	}
+	return out, nil
+}
+
+// issueLocalCert makes sure dir holds a CA and a certificate it issued for
+// hosts and spiffeID, creating or reissuing them as needed.
+func issueLocalCert(dir string, hosts []string, spiffeID string, m *SecureMaterial) error {
+	now := time.Now()
+	ca, caKey, err := loadCertAndKey(m.CAFile, filepath.Join(dir, secureCAKeyFile))
+	if err != nil || now.Add(localCertValidity).After(ca.NotAfter) {
+		ca, caKey, err = createLocalCA(dir, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	hosts = append([]string{"localhost", "127.0.0.1", "::1"}, hosts...)
+	dnsNames, ips := splitHosts(hosts)
+	var uris []*url.URL
+	if spiffeID != "" {
+		id, err := url.Parse(spiffeID)
+		if err != nil || id.Scheme != "spiffe" {
+			return fmt.Errorf("invalid SPIFFE ID %q", spiffeID)
+		}
+		uris = append(uris, id)
+	}
+
+	cert, _, err := loadCertAndKey(m.CertFile, m.KeyFile)
+	if err == nil && cert.CheckSignatureFrom(ca) == nil && sameSANs(cert, dnsNames, ips, uris) {
+		m.NotBefore, m.NotAfter = cert.NotBefore, cert.NotAfter
+		if !m.NeedsRenewal(now) {
+			return nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate registry key: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: "harbor-satellite registry"},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+		URIs:         uris,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(localCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("issue registry certificate: %w", err)
+	}
+	if err := writeCertAndKey(m.CertFile, m.KeyFile, der, key); err != nil {
+		return err
+	}
+	m.NotBefore, m.NotAfter = tmpl.NotBefore, tmpl.NotAfter
+	return nil
+}
+
+func createLocalCA(dir string, now time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate CA key: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "harbor-satellite local CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(localCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create CA: %w", err)
+	}
+	if err := writeCertAndKey(filepath.Join(dir, secureCAFile), filepath.Join(dir, secureCAKeyFile), der, key); err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CA: %w", err)
+	}
+	return ca, key, nil
+}
+
+func loadCertAndKey(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(filepath.Clean(certFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Clean(keyFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, fmt.Errorf("%s or %s is not PEM encoded", certFile, keyFile)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, nil, fmt.Errorf("%s does not match %s", keyFile, certFile)
+	}
+	return cert, key, nil
+}
+
+func writeCertAndKey(certFile, keyFile string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("marshal key: %w", err)
+	}
+	if err := writeSecureFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})); err != nil {
+		return err
+	}
+	return writeSecureFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// loadOrCreateCredentials returns the account passwords stored at path,
+// generating and storing them on first use.
+func loadOrCreateCredentials(path string) (secureCredentials, error) {
+	var creds secureCredentials
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err == nil {
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return creds, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if creds.PushPassword != "" && creds.PullPassword != "" {
+			return creds, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return creds, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	if creds.PushPassword, err = randomPassword(); err != nil {
+		return creds, err
+	}
+	if creds.PullPassword, err = randomPassword(); err != nil {
+		return creds, err
+	}
+	data, err = json.Marshal(creds)
+	if err != nil {
+		return creds, fmt.Errorf("marshal credentials: %w", err)
+	}
+	return creds, writeSecureFile(path, data)
+}
+
+func writeHtpasswd(path string, creds secureCredentials) error {
+	var b strings.Builder
+	for _, account := range []struct{ user, password string }{
+		{PushUser, creds.PushPassword},
+		{PullUser, creds.PullPassword},
+	} {
+		hash, err := bcrypt.GenerateFromPassword([]byte(account.password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("hash password of %s: %w", account.user, err)
+		}
+		fmt.Fprintf(&b, "%s:%s\n", account.user, hash)
+	}
+	return writeSecureFile(path, []byte(b.String()))
+}
+
+func writeSecureFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
+
+// splitHosts sorts hosts into DNS names and IP addresses, dropping
+// duplicates and the unspecified address.
+func splitHosts(hosts []string) ([]string, []net.IP) {
+	var dnsNames []string
+	var ips []net.IP
+	for _, h := range hosts {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			if !ip.IsUnspecified() && !slices.ContainsFunc(ips, ip.Equal) {
+				ips = append(ips, ip)
+			}
+			continue
+		}
+		if !slices.Contains(dnsNames, h) {
+			dnsNames = append(dnsNames, h)
+		}
+	}
+	return dnsNames, ips
+}
+
+func sameSANs(cert *x509.Certificate, dnsNames []string, ips []net.IP, uris []*url.URL) bool {
+	return slices.Equal(cert.DNSNames, dnsNames) &&
+		slices.EqualFunc(cert.IPAddresses, ips, net.IP.Equal) &&
+		slices.EqualFunc(cert.URIs, uris, func(a, b *url.URL) bool { return a.String() == b.String() })
+}
@@ -0,0 +1,179 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func readCert(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestProvisionSecureRegistry(t *testing.T) {
+	opts := SecureOptions{Dir: t.TempDir(), Hosts: []string{"satellite.edge", "10.0.0.5", "0.0.0.0"}}
+	m, err := ProvisionSecureRegistry(opts)
+	require.NoError(t, err)
+	require.NotEmpty(t, m.PushPassword)
+	require.NotEmpty(t, m.PullPassword)
+	require.NotEqual(t, m.PushPassword, m.PullPassword)
+
+	ca := readCert(t, m.CAFile)
+	cert := readCert(t, m.CertFile)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	for _, host := range []string{"satellite.edge", "10.0.0.5", "localhost", "127.0.0.1"} {
+		_, err := cert.Verify(x509.VerifyOptions{Roots: pool, DNSName: host})
+		require.NoError(t, err, host)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{Roots: pool, DNSName: "0.0.0.0"})
+	require.Error(t, err)
+
+	info, err := os.Stat(m.KeyFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	htpasswd, err := os.ReadFile(m.HtpasswdFile)
+	require.NoError(t, err)
+	hashes := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(htpasswd)), "\n") {
+		user, hash, ok := strings.Cut(line, ":")
+		require.True(t, ok)
+		hashes[user] = hash
+	}
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(hashes[PushUser]), []byte(m.PushPassword)))
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(hashes[PullUser]), []byte(m.PullPassword)))
+
+	t.Run("provisioning again keeps the certificate and passwords", func(t *testing.T) {
+		again, err := ProvisionSecureRegistry(opts)
+		require.NoError(t, err)
+		require.Equal(t, m.PushPassword, again.PushPassword)
+		require.Equal(t, m.PullPassword, again.PullPassword)
+		require.Equal(t, cert.SerialNumber, readCert(t, again.CertFile).SerialNumber)
+	})
+
+	t.Run("a new host reissues the certificate from the same CA", func(t *testing.T) {
+		moved := opts
+		moved.Hosts = []string{"satellite.other"}
+		again, err := ProvisionSecureRegistry(moved)
+		require.NoError(t, err)
+		reissued := readCert(t, again.CertFile)
+		require.NotEqual(t, cert.SerialNumber, reissued.SerialNumber)
+		require.Equal(t, ca.SerialNumber, readCert(t, again.CAFile).SerialNumber)
+		_, err = reissued.Verify(x509.VerifyOptions{Roots: pool, DNSName: "satellite.other"})
+		require.NoError(t, err)
+	})
+}
+
+func TestProvisionSecureRegistrySPIFFE(t *testing.T) {
+	const id = "spiffe://example.org/satellite/edge-01"
+	opts := SecureOptions{Dir: t.TempDir(), Hosts: []string{"satellite.edge"}, SPIFFEID: id}
+	m, err := ProvisionSecureRegistry(opts)
+	require.NoError(t, err)
+
+	cert := readCert(t, m.CertFile)
+	require.Len(t, cert.URIs, 1)
+	require.Equal(t, id, cert.URIs[0].String())
+	require.Contains(t, cert.DNSNames, "satellite.edge")
+	require.WithinDuration(t, time.Now().Add(localCertValidity), cert.NotAfter, time.Hour)
+
+	serving, err := tls.LoadX509KeyPair(m.CertFile, m.KeyFile)
+	require.NoError(t, err)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serving}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		_ = conn.Close()
+	}()
+
+	caPEM, err := os.ReadFile(m.CAFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM))
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"})
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	require.Equal(t, id, conn.ConnectionState().PeerCertificates[0].URIs[0].String())
+
+	t.Run("provisioning again keeps the certificate", func(t *testing.T) {
+		again, err := ProvisionSecureRegistry(opts)
+		require.NoError(t, err)
+		require.Equal(t, cert.SerialNumber, readCert(t, again.CertFile).SerialNumber)
+	})
+
+	t.Run("an invalid SPIFFE ID is refused", func(t *testing.T) {
+		invalid := opts
+		invalid.SPIFFEID = "https://example.org/satellite"
+		_, err := ProvisionSecureRegistry(invalid)
+		require.Error(t, err)
+	})
+}
+
+func TestSecureMaterialNeedsRenewal(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &SecureMaterial{NotBefore: start, NotAfter: start.Add(30 * time.Hour)}
+	require.False(t, m.NeedsRenewal(start.Add(time.Hour)))
+	require.False(t, m.NeedsRenewal(start.Add(20*time.Hour)))
+	require.True(t, m.NeedsRenewal(start.Add(21*time.Hour)))
+}
+
+func TestSecureZotConfig(t *testing.T) {
+	m := &SecureMaterial{CertFile: "/secure/tls.crt", KeyFile: "/secure/tls.key", HtpasswdFile: "/secure/htpasswd"}
+	zotConfig := json.RawMessage(`{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/zot"}}`)
+
+	secured, err := SecureZotConfig(zotConfig, m)
+	require.NoError(t, err)
+	var cfg ZotConfig
+	require.NoError(t, json.Unmarshal(secured, &cfg))
+	require.Equal(t, "8585", cfg.HTTP.Port)
+
+	var raw struct {
+		HTTP struct {
+			TLS           map[string]string `json:"tls"`
+			Auth          map[string]any    `json:"auth"`
+			AccessControl struct {
+				Repositories map[string]map[string]any `json:"repositories"`
+				AdminPolicy  map[string][]string       `json:"adminPolicy"`
+			} `json:"accessControl"`
+		} `json:"http"`
+	}
+	require.NoError(t, json.Unmarshal(secured, &raw))
+	require.Equal(t, map[string]string{"cert": m.CertFile, "key": m.KeyFile}, raw.HTTP.TLS)
+	require.Equal(t, map[string]any{"path": m.HtpasswdFile}, raw.HTTP.Auth["htpasswd"])
+	require.Equal(t, []string{PushUser}, raw.HTTP.AccessControl.AdminPolicy["users"])
+	require.NotContains(t, raw.HTTP.AccessControl.Repositories["**"], "anonymousPolicy")
+
+	t.Run("anonymous pull", func(t *testing.T) {
+		anonymous := *m
+		anonymous.AnonymousPull = true
+		secured, err := SecureZotConfig(zotConfig, &anonymous)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(secured, &raw))
+		require.Equal(t, []any{"read"}, raw.HTTP.AccessControl.Repositories["**"]["anonymousPolicy"])
+	})
+
+	t.Run("missing http section", func(t *testing.T) {
+		_, err := SecureZotConfig(json.RawMessage(`{"storage":{}}`), m)
+		require.Error(t, err)
+	})
+}
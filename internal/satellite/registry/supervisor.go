@@ -0,0 +1,429 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultProbeInterval is how often the supervisor probes Zot's /v2/.
+	DefaultProbeInterval = 15 * time.Second
+	// DefaultProbeFailures is the number of failed probes in a row after
+	// which Zot is restarted.
+	DefaultProbeFailures = 3
+	// DefaultMinBackoff is the delay before the first restart after a crash.
+	DefaultMinBackoff = time.Second
+	// DefaultMaxBackoff caps the delay between restarts of a crashing Zot.
+	DefaultMaxBackoff = 2 * time.Minute
+)
+
+// ErrSupervisorNotRunning is returned by Restart before the supervisor has
+// started Zot or after it stopped.
+var ErrSupervisorNotRunning = errors.New("zot supervisor is not running")
+
+// SupervisorOption configures a Supervisor.
+type SupervisorOption func(*Supervisor)
+
+// WithProbe sets the health probe interval and the number of failed probes
+// in a row that trigger a restart.
+func WithProbe(interval time.Duration, failures int) SupervisorOption {
+	return func(s *Supervisor) {
+		if interval > 0 {
+			s.probeInterval = interval
+		}
+		if failures > 0 {
+			s.probeFailures = failures
+		}
+	}
+}
+
+// WithBackoff sets the bounds of the exponential backoff between restarts
+// after a crash.
+func WithBackoff(minBackoff, maxBackoff time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		if minBackoff > 0 {
+			s.minBackoff = minBackoff
+		}
+		if maxBackoff >= s.minBackoff {
+			s.maxBackoff = maxBackoff
+		}
+	}
+}
+
+// restartRequest asks the run loop to restart Zot with a new config.
+type restartRequest struct {
+	config json.RawMessage
+	reason string
+	done   chan error
+}
+
+// Supervisor runs the embedded Zot registry. It restarts Zot with backoff
+// when it exits or stops answering its health probe, and restarts it on
+// request when a setting Zot cannot hot reload changes.
+type Supervisor struct {
+	zm            *ZotManager
+	log           zerolog.Logger
+	launch        func(ctx context.Context, zotConfigPath string) error
+	probe         func(ctx context.Context) error
+	probeInterval time.Duration
+	probeFailures int
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	restartCh     chan restartRequest
+
+	mu          sync.Mutex
+	running     bool
+	startedAt   time.Time
+	restarts    int
+	lastRestart string
+}
+
+// NewSupervisor returns a Supervisor for the Zot managed by zm.
+func NewSupervisor(zm *ZotManager, log zerolog.Logger, opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		zm:            zm,
+		log:           log,
+		launch:        zm.LaunchZotRegistry,
+		probeInterval: DefaultProbeInterval,
+		probeFailures: DefaultProbeFailures,
+		minBackoff:    DefaultMinBackoff,
+		maxBackoff:    DefaultMaxBackoff,
+		restartCh:     make(chan restartRequest),
+	}
+	s.probe = s.probeZot
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run writes and verifies the Zot config, then keeps Zot running until ctx
+// is cancelled. Only a config that fails verification at startup is returned
+// as an error; later failures are retried.
+func (s *Supervisor) Run(ctx context.Context) error {
+	if err := s.zm.WriteTempZotConfig(); err != nil {
+		return fmt.Errorf("error writing temp zot config to disk: %w", err)
+	}
+	defer func() {
+		if err := s.zm.RemoveTempZotConfig(s.zm.tempConfPath); err != nil {
+			s.log.Warn().Err(err).Msg("Failed to remove temp zot config")
+		}
+	}()
+	if err := s.zm.VerifyRegistryConfig(s.zm.tempConfPath); err != nil {
+		return fmt.Errorf("error verifying registry config: %w", err)
+	}
+
+	s.setRunning(true)
+	defer s.setRunning(false)
+
+	backoff := s.minBackoff
+	for {
+		started := time.Now()
+		req, err := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			if req != nil {
+				req.done <- ErrSupervisorNotRunning
+			}
+			return nil
+		}
+
+		if req != nil {
+			s.restartWith(*req)
+			backoff = s.minBackoff
+			continue
+		}
+
+		// Zot ran long enough to count as recovered: start over from the
+		// shortest backoff.
+		if time.Since(started) > s.maxBackoff {
+			backoff = s.minBackoff
+		}
+		s.recordRestart(err.Error())
+		s.log.Error().Err(err).Dur("backoff", backoff).Msg("Zot registry stopped, restarting")
+		// A restart request does not wait out the backoff: it brings a new
+		// config, which may be what Zot needs to come up.
+		select {
+		case <-ctx.Done():
+			return nil
+		case req := <-s.restartCh:
+			s.restartWith(req)
+			backoff = s.minBackoff
+			continue
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
+
+// restartWith applies the config of req before Zot is started again.
+func (s *Supervisor) restartWith(req restartRequest) {
+	req.done <- s.applyConfig(req.config)
+	s.recordRestart(req.reason)
+	s.log.Info().Str("reason", req.reason).Msg("Restarting zot registry to apply config change")
+}
+
+// runOnce runs Zot until it exits, fails its health probe, a restart is
+// requested or ctx is cancelled. It returns the restart request, if any, and
+// otherwise why Zot stopped.
+func (s *Supervisor) runOnce(ctx context.Context) (*restartRequest, error) {
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() { done <- s.launch(runCtx, s.zm.tempConfPath) }()
+	s.setStarted(time.Now())
+	defer s.setStarted(time.Time{})
+
+	ticker := time.NewTicker(s.probeInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				err = errors.New("zot registry exited")
+			}
+			return nil, err
+		case <-ctx.Done():
+			stop()
+			<-done
+			return nil, ctx.Err()
+		case req := <-s.restartCh:
+			stop()
+			<-done
+			return &req, nil
+		case <-ticker.C:
+			probeCtx, cancel := context.WithTimeout(runCtx, s.probeInterval)
+			err := s.probe(probeCtx)
+			cancel()
+			if err == nil {
+				failures = 0
+				continue
+			}
+			failures++
+			s.log.Warn().Err(err).Int("failures", failures).Msg("Zot registry health probe failed")
+			if failures >= s.probeFailures {
+				stop()
+				<-done
+				return nil, fmt.Errorf("health probe failed %d times: %w", failures, err)
+			}
+		}
+	}
+}
+
+// Restart restarts Zot with zotConfig and waits until the new config is in
+// place. reason is logged and reported as the last restart reason.
+func (s *Supervisor) Restart(ctx context.Context, zotConfig json.RawMessage, reason string) error {
+	if err := verifyZotConfig(s.zm, zotConfig); err != nil {
+		return err
+	}
+	req := restartRequest{config: zotConfig, reason: reason, done: make(chan error, 1)}
+	if !s.isRunning() {
+		return ErrSupervisorNotRunning
+	}
+	select {
+	case s.restartCh <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// applyConfig writes a new Zot config to the path Zot is launched from.
+func (s *Supervisor) applyConfig(zotConfig json.RawMessage) error {
+	prev := s.zm.zotConfig
+	s.zm.zotConfig = zotConfig
+	if err := s.zm.WriteTempZotConfig(); err != nil {
+		s.zm.zotConfig = prev
+		return fmt.Errorf("error writing temp zot config to disk: %w", err)
+	}
+	return nil
+}
+
+// verifyZotConfig checks zotConfig the way Zot does at startup, from a file
+// next to the config in use.
+func verifyZotConfig(zm *ZotManager, zotConfig json.RawMessage) error {
+	f, err := os.CreateTemp(filepath.Dir(zm.tempConfPath), "zot-verify-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create zot config for verification: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(zotConfig); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write zot config for verification: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write zot config for verification: %w", err)
+	}
+	return zm.VerifyRegistryConfig(f.Name())
+}
+
+// Uptime returns how long Zot has been running since it last started, or
+// zero while it is down.
+func (s *Supervisor) Uptime() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(s.startedAt)
+}
+
+// Restarts returns the number of times Zot was restarted, for any reason.
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// LastRestartReason returns why Zot was last restarted, empty if it never was.
+func (s *Supervisor) LastRestartReason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastRestart
+}
+
+func (s *Supervisor) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = running
+}
+
+func (s *Supervisor) isRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+func (s *Supervisor) setStarted(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startedAt = t
+}
+
+func (s *Supervisor) recordRestart(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restarts++
+	s.lastRestart = reason
+}
+
+// probeZot checks that Zot serves the distribution API. Any answer below
+// 500, including 401 from a registry with auth, counts as healthy.
+func (s *Supervisor) probeZot(ctx context.Context) error {
+	url, insecureTLS, err := zotProbeURL(s.zm.zotConfig)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{}
+	if insecureTLS {
+		// The probe only checks that Zot answers; its certificate may be
+		// issued for a name other than the listen address.
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} //nolint:gosec // liveness probe only
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create registry probe: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("registry probe: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("registry probe returned %s", resp.Status)
+	}
+	return nil
+}
+
+// zotProbeURL returns the /v2/ URL of the Zot described by zotConfig and
+// whether it is served over TLS.
+func zotProbeURL(zotConfig json.RawMessage) (string, bool, error) {
+	var cfg struct {
+		HTTP struct {
+			Address string          `json:"address"`
+			Port    string          `json:"port"`
+			TLS     json.RawMessage `json:"tls"`
+		} `json:"http"`
+	}
+	if err := json.Unmarshal(zotConfig, &cfg); err != nil {
+		return "", false, fmt.Errorf("could not unmarshal zot config: %w", err)
+	}
+	if cfg.HTTP.Port == "" {
+		return "", false, errors.New("zot config has no http port")
+	}
+	host := cfg.HTTP.Address
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	scheme := "http"
+	hasTLS := len(cfg.HTTP.TLS) > 0 && string(cfg.HTTP.TLS) != "null"
+	if hasTLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/v2/", scheme, net.JoinHostPort(host, cfg.HTTP.Port)), hasTLS, nil
+}
+
+// restartSettings are the Zot settings read only when Zot starts, by their
+// path in the config.
+var restartSettings = [][]string{
+	{"http", "address"},
+	{"http", "port"},
+	{"http", "tls"},
+	{"http", "auth"},
+	{"storage", "rootDirectory"},
+	{"storage", "storageDriver"},
+	{"storage", "subPaths"},
+	{"log"},
+}
+
+// ZotRestartReason returns why moving Zot from oldConfig to newConfig needs
+// a restart, or "" when Zot can hot reload the change.
+func ZotRestartReason(oldConfig, newConfig json.RawMessage) (string, error) {
+	var oldCfg, newCfg map[string]any
+	if len(oldConfig) > 0 {
+		if err := json.Unmarshal(oldConfig, &oldCfg); err != nil {
+			return "", fmt.Errorf("could not unmarshal previous zot config: %w", err)
+		}
+	}
+	if err := json.Unmarshal(newConfig, &newCfg); err != nil {
+		return "", fmt.Errorf("could not unmarshal zot config: %w", err)
+	}
+	for _, path := range restartSettings {
+		if !reflect.DeepEqual(lookup(oldCfg, path), lookup(newCfg, path)) {
+			return fmt.Sprintf("zot setting %s changed", strings.Join(path, ".")), nil
+		}
+	}
+	return "", nil
+}
+
+func lookup(cfg map[string]any, path []string) any {
+	var v any = cfg
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSupervisor(t *testing.T, opts ...SupervisorOption) *Supervisor {
+	t.Helper()
+	zm := NewZotManager(zerolog.Nop(), validZotConfig, filepath.Join(t.TempDir(), "zot.json"))
+	opts = append([]SupervisorOption{WithProbe(5*time.Millisecond, 2), WithBackoff(time.Millisecond, 10*time.Millisecond)}, opts...)
+	s := NewSupervisor(zm, zerolog.Nop(), opts...)
+	s.probe = func(context.Context) error { return nil }
+	return s
+}
+
+// runSupervisor runs s until the test ends.
+func runSupervisor(t *testing.T, s *Supervisor) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+}
+
+func TestSupervisorRestartsCrashedZot(t *testing.T) {
+	s := newTestSupervisor(t)
+	var launches atomic.Int32
+	s.launch = func(ctx context.Context, _ string) error {
+		if launches.Add(1) < 3 {
+			return errors.New("zot crashed")
+		}
+		<-ctx.Done()
+		return nil
+	}
+	runSupervisor(t, s)
+
+	require.Eventually(t, func() bool { return launches.Load() == 3 }, time.Second, time.Millisecond)
+	require.Equal(t, 2, s.Restarts())
+	require.Equal(t, "zot crashed", s.LastRestartReason())
+	require.Eventually(t, func() bool { return s.Uptime() > 0 }, time.Second, time.Millisecond)
+}
+
+func TestSupervisorRestartsUnhealthyZot(t *testing.T) {
+	s := newTestSupervisor(t)
+	var launches, probes atomic.Int32
+	s.launch = func(ctx context.Context, _ string) error {
+		launches.Add(1)
+		<-ctx.Done()
+		return nil
+	}
+	s.probe = func(context.Context) error {
+		if probes.Add(1) <= 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	runSupervisor(t, s)
+
+	require.Eventually(t, func() bool { return launches.Load() == 2 }, time.Second, time.Millisecond)
+	require.Equal(t, 1, s.Restarts())
+	require.Contains(t, s.LastRestartReason(), "health probe failed 2 times")
+}
+
+func TestSupervisorRestartWithNewConfig(t *testing.T) {
+	s := newTestSupervisor(t)
+	var launches atomic.Int32
+	s.launch = func(ctx context.Context, _ string) error {
+		launches.Add(1)
+		<-ctx.Done()
+		return nil
+	}
+
+	newConfig := json.RawMessage(`{"storage":{"rootDirectory":"./zot"},"http":{"address":"127.0.0.1","port":"8586"},"log":{"level":"info"}}`)
+	require.ErrorIs(t, s.Restart(context.Background(), newConfig, "port changed"), ErrSupervisorNotRunning)
+
+	runSupervisor(t, s)
+	require.Eventually(t, func() bool { return launches.Load() == 1 }, time.Second, time.Millisecond)
+
+	require.NoError(t, s.Restart(context.Background(), newConfig, "zot setting http.port changed"))
+	data, err := os.ReadFile(s.zm.tempConfPath)
+	require.NoError(t, err)
+	require.JSONEq(t, string(newConfig), string(data))
+
+	require.Eventually(t, func() bool { return launches.Load() == 2 }, time.Second, time.Millisecond)
+	require.Equal(t, 1, s.Restarts())
+	require.Equal(t, "zot setting http.port changed", s.LastRestartReason())
+}
+
+func TestZotRestartReason(t *testing.T) {
+	base := `{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/zot","gc":true},"log":{"level":"info"}}`
+	tests := []struct {
+		name   string
+		old    string
+		new    string
+		reason string
+	}{
+		{"unchanged", base, base, ""},
+		{"gc toggled", base, `{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/zot","gc":false},"log":{"level":"info"}}`, ""},
+		{"extensions added", base, `{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/zot","gc":true},"log":{"level":"info"},"extensions":{"search":{"enable":true}}}`, ""},
+		{"port", base, `{"http":{"address":"0.0.0.0","port":"8586"},"storage":{"rootDirectory":"/zot","gc":true},"log":{"level":"info"}}`, "zot setting http.port changed"},
+		{"tls", base, `{"http":{"address":"0.0.0.0","port":"8585","tls":{"cert":"c","key":"k"}},"storage":{"rootDirectory":"/zot","gc":true},"log":{"level":"info"}}`, "zot setting http.tls changed"},
+		{"storage root", base, `{"http":{"address":"0.0.0.0","port":"8585"},"storage":{"rootDirectory":"/data","gc":true},"log":{"level":"info"}}`, "zot setting storage.rootDirectory changed"},
+		{"no previous config", "", base, "zot setting http.address changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := ZotRestartReason(json.RawMessage(tt.old), json.RawMessage(tt.new))
+			require.NoError(t, err)
+			require.Equal(t, tt.reason, reason)
+		})
+	}
+
+	_, err := ZotRestartReason(json.RawMessage(base), json.RawMessage(`{`))
+	require.Error(t, err)
+}
+
+func TestZotProbeURL(t *testing.T) {
+	tests := []struct {
+		config string
+		url    string
+		tls    bool
+	}{
+		{`{"http":{"address":"0.0.0.0","port":"8585"}}`, "http://127.0.0.1:8585/v2/", false},
+		{`{"http":{"address":"10.0.0.5","port":"5000"}}`, "http://10.0.0.5:5000/v2/", false},
+		{`{"http":{"address":"::1","port":"5000","tls":{"cert":"c","key":"k"}}}`, "https://[::1]:5000/v2/", true},
+	}
+	for _, tt := range tests {
+		url, tls, err := zotProbeURL(json.RawMessage(tt.config))
+		require.NoError(t, err, tt.config)
+		require.Equal(t, tt.url, url)
+		require.Equal(t, tt.tls, tls)
+	}
+
+	_, _, err := zotProbeURL(json.RawMessage(`{"http":{"address":"0.0.0.0"}}`))
+	require.Error(t, err)
+}
+
+func TestSupervisorRestartDuringBackoff(t *testing.T) {
+	s := newTestSupervisor(t, WithBackoff(time.Hour, time.Hour))
+	var launches atomic.Int32
+	s.launch = func(context.Context, string) error {
+		launches.Add(1)
+		return errors.New("zot crashed")
+	}
+	runSupervisor(t, s)
+	require.Eventually(t, func() bool { return s.Restarts() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	newConfig := json.RawMessage(`{"storage":{"rootDirectory":"./zot"},"http":{"address":"127.0.0.1","port":"8586"},"log":{"level":"info"}}`)
+	require.NoError(t, s.Restart(ctx, newConfig, "zot setting http.port changed"))
+	require.Eventually(t, func() bool { return launches.Load() == 2 }, time.Second, time.Millisecond)
+}
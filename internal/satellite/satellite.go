@@ -2,6 +2,7 @@ package satellite
 
 import (
 	"context"
+	"os"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
 	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
@@ -16,6 +17,8 @@ type Satellite struct {
 	schedulers    []*scheduler.Scheduler
 	stateFilePath string
 	stateProcess  *state.FetchAndReplicateStateProcess
+	statusProcess *state.StatusReportingProcess
+	breakers      *scheduler.Breakers
 }
 
 func NewSatellite(cm *config.ConfigManager, criResults []runtime.CRIConfigResult, stateFilePath string) *Satellite {
@@ -24,6 +27,7 @@ func NewSatellite(cm *config.ConfigManager, criResults []runtime.CRIConfigResult
 		criResults:    criResults,
 		schedulers:    make([]*scheduler.Scheduler, 0),
 		stateFilePath: stateFilePath,
+		breakers:      scheduler.NewBreakers(),
 	}
 }
 
@@ -34,6 +38,8 @@ func (s *Satellite) Run(ctx context.Context) error {
 	fetchAndReplicateStateProcess := state.NewFetchAndReplicateStateProcess(s.cm, s.stateFilePath, log)
 	s.stateProcess = fetchAndReplicateStateProcess
 
+	opts := s.schedulerOptions()
+
 	// Create ZTR scheduler if not already done
 	if !s.cm.IsZTRDone() {
 		var ztrScheduler *scheduler.Scheduler
@@ -50,6 +56,7 @@ func (s *Satellite) Run(ctx context.Context) error {
 				s.cm.GetRegistrationInterval(),
 				spiffeZtrProcess,
 				log,
+				opts...,
 			)
 		} else {
 			log.Info().Msg("Using token-based ZTR")
@@ -58,6 +65,7 @@ func (s *Satellite) Run(ctx context.Context) error {
 				s.cm.GetRegistrationInterval(),
 				ztrProcess,
 				log,
+				opts...,
 			)
 		}
 
@@ -74,6 +82,7 @@ func (s *Satellite) Run(ctx context.Context) error {
 		s.cm.GetStateReplicationInterval(),
 		fetchAndReplicateStateProcess,
 		log,
+		opts...,
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create state replication scheduler")
@@ -87,10 +96,17 @@ func (s *Satellite) Run(ctx context.Context) error {
 	if len(s.criResults) > 0 {
 		statusReportProcess.SetPendingCRIResults(s.criResults)
 	}
+	statusReportProcess.SetBreakers(s.breakers)
+	statusReportProcess.SetConfigProbation(fetchAndReplicateStateProcess.ConfigProbation())
+	statusReportProcess.SetReplicationStatus(fetchAndReplicateStateProcess)
+	statusReportProcess.SetInventory(fetchAndReplicateStateProcess.Inventory())
+	statusReportProcess.SetPrewarmer(fetchAndReplicateStateProcess.Prewarmer())
+	s.statusProcess = statusReportProcess
 	statusScheduler, err := scheduler.NewSchedulerWithInterval(
 		s.cm.GetHeartbeatInterval(),
 		statusReportProcess,
 		log,
+		opts...,
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create status report scheduler")
@@ -102,10 +118,51 @@ func (s *Satellite) Run(ctx context.Context) error {
 	return ctx.Err()
 }
 
+// schedulerOptions applies the configured jitter and spreads the satellite's
+// runs by an offset derived from its name, so a fleet sharing one schedule
+// does not hit Ground Control and Harbor at the same instant. Before
+// registration the name is not known yet and the hostname is used instead.
+// All schedulers share one set of dependency breakers.
+func (s *Satellite) schedulerOptions() []scheduler.Option {
+	key, err := state.SatelliteNameFromURL(s.cm.GetStateURL())
+	if err != nil {
+		key, _ = os.Hostname()
+	}
+	return []scheduler.Option{
+		scheduler.WithJitter(s.cm.GetScheduleJitter()),
+		scheduler.WithSpread(key, s.cm.GetScheduleSpread()),
+		scheduler.WithBreakers(s.breakers),
+	}
+}
+
 func (s *Satellite) GetSchedulers() []*scheduler.Scheduler {
 	return s.schedulers
 }
 
+// SchedulerNames returns the names of the schedulers started by Run.
+func (s *Satellite) SchedulerNames() []string {
+	names := make([]string, 0, len(s.schedulers))
+	for _, sched := range s.schedulers {
+		names = append(names, sched.Name())
+	}
+	return names
+}
+
+// Breakers returns the dependency circuit breakers shared by the schedulers.
+func (s *Satellite) Breakers() *scheduler.Breakers {
+	return s.breakers
+}
+
+// StateProcess returns the state replication process, or nil before Run.
+func (s *Satellite) StateProcess() *state.FetchAndReplicateStateProcess {
+	return s.stateProcess
+}
+
+// StatusProcess returns the heartbeat process, or nil before Run.
+func (s *Satellite) StatusProcess() *state.StatusReportingProcess {
+	return s.statusProcess
+}
+
 // PersistState writes the current in-memory state to disk.
 // Called during graceful shutdown to ensure no state is lost.
 func (s *Satellite) PersistState() error {
@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+)
+
+// Dependency names an upstream service a process relies on.
+type Dependency string
+
+const (
+	DependencyGroundControl Dependency = "ground_control"
+	DependencyHarbor        Dependency = "harbor"
+	DependencyLocalRegistry Dependency = "local_registry"
+)
+
+// BreakerState is the state of a dependency's circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets processes run normally.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen skips processes that need the dependency until the
+	// cooldown elapses.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single trial run through after the cooldown. Its
+	// outcome closes the breaker or reopens it with a longer cooldown.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// Breaker defaults.
+const (
+	DefaultBreakerThreshold   = 3
+	DefaultBreakerCooldown    = 30 * time.Second
+	DefaultBreakerMaxCooldown = 10 * time.Minute
+)
+
+// DependencyError attributes a process failure to the dependency that caused
+// it, so the scheduler can trip that dependency's breaker. The message is the
+// wrapped error's, unchanged.
+type DependencyError struct {
+	Dependency Dependency
+	Err        error
+}
+
+func (e *DependencyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// DependencyFailure wraps err as a failure of dep. It returns nil for a nil
+// err so call sites can wrap unconditionally.
+func DependencyFailure(dep Dependency, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &DependencyError{Dependency: dep, Err: err}
+}
+
+// FailedDependencies returns every dependency blamed anywhere in err's tree,
+// including errors combined with errors.Join.
+func FailedDependencies(err error) []Dependency {
+	var deps []Dependency
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		if de, ok := err.(*DependencyError); ok && !slices.Contains(deps, de.Dependency) {
+			deps = append(deps, de.Dependency)
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				walk(e)
+			}
+		}
+	}
+	walk(err)
+	return deps
+}
+
+// BreakerStatus is a point-in-time view of one breaker, reported in the
+// heartbeat so Ground Control can tell a degraded upstream from a dead
+// satellite.
+type BreakerStatus struct {
+	Dependency          Dependency   `json:"dependency"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// Breaker is a circuit breaker for one dependency. It opens after threshold
+// consecutive failures and lets a trial run through once the cooldown has
+// elapsed. Each failed trial doubles the cooldown up to maxCooldown.
+type Breaker struct {
+	dependency  Dependency
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+
+	mu          sync.Mutex
+	state       BreakerState
+	failures    int
+	openedAt    time.Time
+	retryAt     time.Time
+	curCooldown time.Duration
+	lastErr     string
+	// trialInFlight is set while the half-open trial runs.
+	trialInFlight bool
+}
+
+// NewBreaker creates a closed breaker for dep. Non-positive values fall back
+// to the defaults.
+func NewBreaker(dep Dependency, threshold int, cooldown, maxCooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	if maxCooldown < cooldown {
+		maxCooldown = max(cooldown, DefaultBreakerMaxCooldown)
+	}
+	b := &Breaker{
+		dependency:  dep,
+		threshold:   threshold,
+		cooldown:    cooldown,
+		maxCooldown: maxCooldown,
+		state:       BreakerClosed,
+	}
+	b.publish()
+	return b
+}
+
+// Allow reports whether a process needing the dependency may run at now. When
+// it may not, it returns how long until the breaker lets a trial through.
+// While half-open only one trial is let through; other callers wait another
+// cooldown, after which a trial that never reported back is replaced.
+func (b *Breaker) Allow(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerClosed {
+		return true, 0
+	}
+	if (b.state == BreakerOpen || b.trialInFlight) && now.Before(b.retryAt) {
+		return false, b.retryAt.Sub(now)
+	}
+	b.state = BreakerHalfOpen
+	b.trialInFlight = true
+	b.retryAt = now.Add(b.curCooldown)
+	b.publish()
+	return true, 0
+}
+
+// Success closes the breaker and clears its failure count.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.curCooldown = 0
+	b.openedAt = time.Time{}
+	b.retryAt = time.Time{}
+	b.lastErr = ""
+	b.trialInFlight = false
+	b.publish()
+}
+
+// Failure records a failed call at now and opens the breaker once the
+// threshold is reached or a half-open trial fails.
+func (b *Breaker) Failure(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if err != nil {
+		b.lastErr = err.Error()
+	}
+	b.trialInFlight = false
+
+	switch {
+	case b.state == BreakerHalfOpen:
+		b.curCooldown = min(b.curCooldown*2, b.maxCooldown)
+	case b.state == BreakerClosed && b.failures >= b.threshold:
+		b.curCooldown = b.cooldown
+		b.openedAt = now
+	default:
+		return
+	}
+	b.state = BreakerOpen
+	b.retryAt = now.Add(b.curCooldown)
+	b.publish()
+}
+
+// Status returns a snapshot of the breaker.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Dependency:          b.dependency,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastErr,
+	}
+	if !b.openedAt.IsZero() {
+		openedAt := b.openedAt.UTC()
+		status.OpenedAt = &openedAt
+	}
+	if b.state == BreakerOpen {
+		retryAt := b.retryAt.UTC()
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// publish must be called with b.mu held.
+func (b *Breaker) publish() {
+	var v float64
+	switch b.state {
+	case BreakerHalfOpen:
+		v = 1
+	case BreakerOpen:
+		v = 2
+	}
+	metrics.BreakerState.WithLabelValues(string(b.dependency)).Set(v)
+}
+
+// Breakers holds one breaker per dependency, shared by every scheduler so a
+// Ground Control outage seen by the heartbeat also pauses registration.
+type Breakers struct {
+	breakers map[Dependency]*Breaker
+}
+
+// NewBreakers creates default breakers for Ground Control, Harbor and the
+// local registry.
+func NewBreakers() *Breakers {
+	b := &Breakers{breakers: make(map[Dependency]*Breaker)}
+	for _, dep := range []Dependency{DependencyGroundControl, DependencyHarbor, DependencyLocalRegistry} {
+		b.breakers[dep] = NewBreaker(dep, DefaultBreakerThreshold, DefaultBreakerCooldown, DefaultBreakerMaxCooldown)
+	}
+	return b
+}
+
+// Get returns the breaker for dep, or nil if there is none.
+func (b *Breakers) Get(dep Dependency) *Breaker {
+	if b == nil {
+		return nil
+	}
+	return b.breakers[dep]
+}
+
+// Status returns a snapshot of every breaker, ordered by dependency.
+func (b *Breakers) Status() []BreakerStatus {
+	if b == nil {
+		return nil
+	}
+	statuses := make([]BreakerStatus, 0, len(b.breakers))
+	for _, br := range b.breakers {
+		statuses = append(statuses, br.Status())
+	}
+	slices.SortFunc(statuses, func(a, b BreakerStatus) int {
+		return cmp.Compare(a.Dependency, b.Dependency)
+	})
+	return statuses
+}
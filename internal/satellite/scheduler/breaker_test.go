@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker_Lifecycle(t *testing.T) {
+	b := NewBreaker(DependencyHarbor, 2, time.Minute, 3*time.Minute)
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	errDown := errors.New("connection refused")
+
+	b.Failure(errDown, now)
+	ok, _ := b.Allow(now)
+	require.True(t, ok, "one failure stays below the threshold")
+
+	b.Failure(errDown, now)
+	ok, wait := b.Allow(now.Add(10 * time.Second))
+	require.False(t, ok)
+	require.Equal(t, 50*time.Second, wait)
+
+	status := b.Status()
+	require.Equal(t, BreakerOpen, status.State)
+	require.Equal(t, 2, status.ConsecutiveFailures)
+	require.Equal(t, "connection refused", status.LastError)
+	require.NotNil(t, status.OpenedAt)
+	require.NotNil(t, status.RetryAt)
+
+	// After the cooldown a trial is let through; its failure doubles the
+	// cooldown.
+	now = now.Add(time.Minute)
+	ok, _ = b.Allow(now)
+	require.True(t, ok)
+	require.Equal(t, BreakerHalfOpen, b.Status().State)
+	ok, wait = b.Allow(now.Add(time.Second))
+	require.False(t, ok, "only one trial runs while half-open")
+	require.Equal(t, 59*time.Second, wait)
+	b.Failure(errDown, now)
+	_, wait = b.Allow(now)
+	require.Equal(t, 2*time.Minute, wait)
+
+	// The cooldown is capped.
+	now = now.Add(2 * time.Minute)
+	ok, _ = b.Allow(now)
+	require.True(t, ok)
+	b.Failure(errDown, now)
+	_, wait = b.Allow(now)
+	require.Equal(t, 3*time.Minute, wait)
+
+	// A trial that never reports back is replaced after another cooldown.
+	now = now.Add(3 * time.Minute)
+	ok, _ = b.Allow(now)
+	require.True(t, ok)
+	ok, _ = b.Allow(now.Add(3 * time.Minute))
+	require.True(t, ok)
+
+	// A successful trial closes the breaker and clears the history.
+	now = now.Add(6 * time.Minute)
+	ok, _ = b.Allow(now)
+	require.True(t, ok)
+	b.Success()
+	status = b.Status()
+	require.Equal(t, BreakerClosed, status.State)
+	require.Zero(t, status.ConsecutiveFailures)
+	require.Nil(t, status.OpenedAt)
+	require.Empty(t, status.LastError)
+}
+
+func TestFailedDependencies(t *testing.T) {
+	base := errors.New("boom")
+	harbor := DependencyFailure(DependencyHarbor, base)
+	local := DependencyFailure(DependencyLocalRegistry, base)
+
+	require.Nil(t, DependencyFailure(DependencyHarbor, nil))
+	require.Empty(t, FailedDependencies(base))
+	require.Equal(t, []Dependency{DependencyHarbor}, FailedDependencies(fmt.Errorf("sync: %w", harbor)))
+	require.Equal(t, "boom", harbor.Error(), "wrapping keeps the message")
+
+	joined := errors.Join(fmt.Errorf("group a: %w", harbor), local, harbor)
+	require.ElementsMatch(t, []Dependency{DependencyHarbor, DependencyLocalRegistry}, FailedDependencies(joined))
+
+	ra := &RetryAfterError{Err: DependencyFailure(DependencyGroundControl, base), After: time.Second}
+	require.Equal(t, []Dependency{DependencyGroundControl}, FailedDependencies(ra))
+}
+
+func TestBreakers_Status(t *testing.T) {
+	breakers := NewBreakers()
+	breakers.Get(DependencyHarbor).Failure(errors.New("down"), time.Now())
+
+	statuses := breakers.Status()
+	require.Len(t, statuses, 3)
+	require.Equal(t, DependencyGroundControl, statuses[0].Dependency)
+	require.Equal(t, DependencyHarbor, statuses[1].Dependency)
+	require.Equal(t, 1, statuses[1].ConsecutiveFailures)
+	require.Equal(t, DependencyLocalRegistry, statuses[2].Dependency)
+
+	var none *Breakers
+	require.Nil(t, none.Get(DependencyHarbor))
+	require.Nil(t, none.Status())
+}
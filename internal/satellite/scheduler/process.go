@@ -17,4 +17,16 @@ type Process interface {
 
 	// ShouldStop returns true if the process scheduling should be stopped
 	IsComplete() bool
+
+	// Dependencies lists the upstreams the process needs. The scheduler
+	// skips runs while one of their breakers is open and closes them when a
+	// run succeeds. Failures are attributed by wrapping the returned error
+	// with DependencyFailure.
+	Dependencies() []Dependency
+}
+
+// SkipObserver is implemented by processes that need to know about runs the
+// scheduler skipped because the breaker of dep is open.
+type SkipObserver interface {
+	Skipped(ctx context.Context, dep Dependency)
 }
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryAfter caps how long a single Retry-After header can hold a process.
+const maxRetryAfter = time.Hour
+
+// RetryAfterError reports that an upstream rejected a request with 429 and
+// asked to be retried later. A process returning it (possibly wrapped) has its
+// next run held until After has elapsed.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns the longest hold requested by a RetryAfterError in err's
+// chain.
+func RetryAfter(err error) (time.Duration, bool) {
+	var ra *RetryAfterError
+	if !errors.As(err, &ra) {
+		return 0, false
+	}
+	return ra.After, true
+}
+
+// ParseRetryAfter parses a Retry-After header value given either as delay
+// seconds or as an HTTP date. The result is capped at one hour.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+	} else {
+		return 0, false
+	}
+	return min(d, maxRetryAfter), true
+}
+
+// CheckRetryAfter returns a RetryAfterError for a 429 response carrying a
+// valid Retry-After header, and nil otherwise.
+func CheckRetryAfter(resp *http.Response) error {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	after, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return nil
+	}
+	err := fmt.Errorf("upstream returned %s", resp.Status)
+	if resp.Request != nil {
+		err = fmt.Errorf("%s %s: %s", resp.Request.Method, resp.Request.URL.Redacted(), resp.Status)
+	}
+	return &RetryAfterError{Err: err, After: after}
+}
+
+// RetryAfterTransport turns 429 responses with a Retry-After header into a
+// RetryAfterError, so registry clients that only surface status codes still
+// let the scheduler back off for as long as the registry asked.
+type RetryAfterTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *RetryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if raErr := CheckRetryAfter(resp); raErr != nil {
+		_ = resp.Body.Close()
+		return nil, raErr
+	}
+	return resp, nil
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "http date", value: now.Add(45 * time.Second).Format(http.TimeFormat), want: 45 * time.Second, wantOK: true},
+		{name: "past date", value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+		{name: "capped", value: "86400", want: time.Hour, wantOK: true},
+		{name: "empty", value: ""},
+		{name: "negative", value: "-5"},
+		{name: "garbage", value: "later"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetryAfterTransport(t *testing.T) {
+	var limited atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited.Load() {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &RetryAfterTransport{}}
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	limited.Store(true)
+	_, err = client.Get(srv.URL)
+	require.Error(t, err)
+
+	// The hold survives wrapping by http.Client and by callers.
+	after, ok := RetryAfter(fmt.Errorf("fetch state: %w", err))
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, after)
+}
+
+func TestRetryAfter_NotRateLimited(t *testing.T) {
+	_, ok := RetryAfter(errors.New("boom"))
+	require.False(t, ok)
+	_, ok = RetryAfter(nil)
+	require.False(t, ok)
+	require.NoError(t, CheckRetryAfter(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}))
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule computes the next activation after a given time.
+type Schedule = cron.Schedule
+
+// everySchedule fires at a constant interval. It is used for "@every"
+// expressions instead of cron's own, which rounds to whole seconds.
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(e.interval)
+}
+
+// ParseSchedule parses "@every <duration>", a descriptor such as "@hourly" or
+// "@daily", or a standard 5-field cron expression.
+func ParseSchedule(expr string) (Schedule, error) {
+	const prefix = "@every "
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, errors.New("empty expression provided")
+	}
+	if strings.HasPrefix(expr, prefix) {
+		d, err := time.ParseDuration(strings.TrimPrefix(expr, prefix))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("interval must be positive, got %v", d)
+		}
+		return everySchedule{interval: d}, nil
+	}
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+// Interval returns the time between activations of expr. For cron
+// expressions this is the gap between the next two activations, which is
+// exact for regular schedules and an approximation otherwise.
+func Interval(expr string) (time.Duration, error) {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return 0, err
+	}
+	return scheduleInterval(schedule, time.Now()), nil
+}
+
+func scheduleInterval(schedule Schedule, now time.Time) time.Duration {
+	if every, ok := schedule.(everySchedule); ok {
+		return every.interval
+	}
+	next := schedule.Next(now)
+	return schedule.Next(next).Sub(next)
+}
+
+// isConstant reports whether the schedule fires relative to the previous run
+// rather than at fixed wall-clock times.
+func isConstant(schedule Schedule) bool {
+	_, ok := schedule.(everySchedule)
+	return ok
+}
+
+// Offset derives a stable offset in [0, window) from key, typically the
+// satellite name, so that a fleet sharing one schedule spreads its runs
+// without coordination.
+func Offset(key string, window time.Duration) time.Duration {
+	if key == "" || window <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return time.Duration(h.Sum64() % uint64(window))
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expr     string
+		wantNext time.Time
+		wantErr  bool
+	}{
+		{name: "every", expr: "@every 10s", wantNext: now.Add(10 * time.Second)},
+		{name: "every sub-second", expr: "@every 250ms", wantNext: now.Add(250 * time.Millisecond)},
+		{name: "hourly", expr: "@hourly", wantNext: time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC)},
+		{name: "daily", expr: "@daily", wantNext: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{name: "five field cron", expr: "*/15 * * * *", wantNext: time.Date(2025, 3, 10, 12, 15, 0, 0, time.UTC)},
+		{name: "empty", expr: "", wantErr: true},
+		{name: "zero interval", expr: "@every 0s", wantErr: true},
+		{name: "bad duration", expr: "@every soon", wantErr: true},
+		{name: "bad cron", expr: "* * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.expr)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantNext, schedule.Next(now))
+		})
+	}
+}
+
+func TestInterval(t *testing.T) {
+	d, err := Interval("@every 1m30s")
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Second, d)
+
+	d, err = Interval("0 */6 * * *")
+	require.NoError(t, err)
+	require.Equal(t, 6*time.Hour, d)
+}
+
+func TestOffset(t *testing.T) {
+	window := 5 * time.Minute
+
+	a := Offset("edge-berlin-01", window)
+	require.Equal(t, a, Offset("edge-berlin-01", window), "offset must be deterministic")
+	require.GreaterOrEqual(t, a, time.Duration(0))
+	require.Less(t, a, window)
+
+	// Different names should spread out rather than collide.
+	seen := map[time.Duration]bool{}
+	for _, name := range []string{"edge-1", "edge-2", "edge-3", "edge-4", "edge-5"} {
+		seen[Offset(name, window)] = true
+	}
+	require.Greater(t, len(seen), 1)
+
+	require.Zero(t, Offset("", window))
+	require.Zero(t, Offset("edge-1", 0))
+}
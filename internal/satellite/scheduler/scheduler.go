@@ -4,39 +4,97 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"math/rand/v2"
 	"sync"
 	"time"
 
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
 	"github.com/rs/zerolog"
 )
 
-// Scheduler manages the execution of processes with configurable intervals.
+// Scheduler manages the execution of processes on an "@every" or cron
+// schedule. Runs can be spread across a fleet with a per-satellite offset and
+// a random jitter. Failed runs back off exponentially, runs are held back when
+// a process reports a Retry-After, and runs are skipped while the circuit
+// breaker of a dependency the process needs is open.
 type Scheduler struct {
-	name     string
-	ticker   *time.Ticker
-	process  Process
-	log      *zerolog.Logger
-	interval time.Duration
-	mu       sync.Mutex
-	wg       sync.WaitGroup
-}
-
-// NewSchedulerWithInterval creates a new scheduler with a parsed interval string.
-func NewSchedulerWithInterval(intervalExpr string, process Process, log *zerolog.Logger) (*Scheduler, error) {
-	duration, err := parseEveryExpr(intervalExpr)
+	name       string
+	process    Process
+	log        *zerolog.Logger
+	schedule   Schedule
+	interval   time.Duration
+	jitter     time.Duration
+	offsetKey  string
+	spread     time.Duration
+	offset     time.Duration
+	maxBackoff time.Duration
+	breakers   *Breakers
+	failures   int
+	holdUntil  time.Time
+	holdReason string
+	reset      chan struct{}
+	mu         sync.Mutex
+	wg         sync.WaitGroup
+}
+
+// DefaultMaxBackoff caps the exponential backoff after consecutive failures.
+const DefaultMaxBackoff = 5 * time.Minute
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithJitter adds a random delay in [0, jitter) to every run.
+func WithJitter(jitter time.Duration) Option {
+	return func(s *Scheduler) {
+		s.jitter = max(jitter, 0)
+	}
+}
+
+// WithSpread delays runs by a stable offset in [0, spread) derived from key,
+// usually the satellite name. The offset is capped at the schedule interval.
+func WithSpread(key string, spread time.Duration) Option {
+	return func(s *Scheduler) {
+		s.offsetKey = key
+		s.spread = spread
+	}
+}
+
+// WithMaxBackoff caps the exponential backoff after consecutive failures.
+func WithMaxBackoff(maxBackoff time.Duration) Option {
+	return func(s *Scheduler) {
+		if maxBackoff > 0 {
+			s.maxBackoff = maxBackoff
+		}
+	}
+}
+
+// WithBreakers shares the dependency circuit breakers between schedulers.
+func WithBreakers(breakers *Breakers) Option {
+	return func(s *Scheduler) {
+		s.breakers = breakers
+	}
+}
+
+// NewSchedulerWithInterval creates a new scheduler from a schedule expression:
+// "@every <duration>", a descriptor such as "@hourly", or a 5-field cron
+// expression.
+func NewSchedulerWithInterval(intervalExpr string, process Process, log *zerolog.Logger, opts ...Option) (*Scheduler, error) {
+	schedule, err := ParseSchedule(intervalExpr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse interval: %w", err)
 	}
 
-	ticker := time.NewTicker(duration)
 	scheduler := &Scheduler{
-		name:     process.Name(),
-		ticker:   ticker,
-		process:  process,
-		log:      log,
-		interval: duration,
+		name:       process.Name(),
+		process:    process,
+		log:        log,
+		maxBackoff: DefaultMaxBackoff,
+		reset:      make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(scheduler)
 	}
+	scheduler.setSchedule(schedule)
 
 	return scheduler, nil
 }
@@ -51,15 +109,23 @@ func (s *Scheduler) Start(ctx context.Context) {
 // run starts the scheduler and blocks until context is cancelled.
 func (s *Scheduler) run(ctx context.Context) {
 	defer s.wg.Done()
-	defer s.ticker.Stop()
 
+	s.mu.Lock()
+	// The first run only waits for the fleet offset and jitter. The offset
+	// falls within the default 30s spread unless schedule_spread is set, and
+	// only a spread and jitter of "0s" run the process immediately.
+	delay := s.offset + s.randomJitter()
 	s.log.Info().
 		Str("Process", s.process.Name()).
 		Dur("interval", s.interval).
+		Dur("offset", s.offset).
+		Dur("jitter", s.jitter).
+		Dur("first_run_in", delay).
 		Msg("Starting scheduler")
+	s.mu.Unlock()
 
-	// Run once immediately
-	s.launchProcess(ctx)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 
 	for {
 		select {
@@ -67,47 +133,191 @@ func (s *Scheduler) run(ctx context.Context) {
 			s.log.Info().
 				Str("Process", s.process.Name()).
 				Msg("Scheduler received cancellation signal. Exiting...")
-
 			return
-
-		case <-s.ticker.C:
+		case <-s.reset:
+			timer.Reset(s.nextDelay(time.Now()))
+		case now := <-timer.C:
 			if s.process.IsComplete() {
 				s.log.Info().
 					Str("Process", s.process.Name()).
 					Msg("Process marked as complete. Stopping scheduling.")
-
 				return
 			}
+			if hold, reason := s.heldFor(now); hold > 0 {
+				s.log.Info().
+					Str("Process", s.process.Name()).
+					Str("reason", reason).
+					Dur("hold", hold).
+					Msg("Holding next run")
+				timer.Reset(hold)
+				continue
+			}
+			if dep, wait := s.blockedFor(now); wait > 0 {
+				s.log.Warn().
+					Str("Process", s.process.Name()).
+					Str("dependency", string(dep)).
+					Dur("retry_in", wait).
+					Msg("Circuit breaker open, skipping run")
+				if o, ok := s.process.(SkipObserver); ok {
+					o.Skipped(ctx, dep)
+				}
+				timer.Reset(wait)
+				continue
+			}
 			s.launchProcess(ctx)
+			timer.Reset(s.nextDelay(now))
 		}
 	}
 }
 
-// ResetInterval changes the ticker interval dynamically.
-func (s *Scheduler) ResetInterval(newInterval time.Duration) {
+// nextDelay returns the wait until the next run. Cron schedules fire at fixed
+// wall-clock times, so the fleet offset shifts each of their slots; "@every"
+// schedules keep the offset from their first run.
+func (s *Scheduler) nextDelay(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	if isConstant(s.schedule) {
+		next = s.schedule.Next(now)
+	} else {
+		next = s.schedule.Next(now.Add(-s.offset)).Add(s.offset)
+	}
+	return max(next.Sub(now)+s.randomJitter(), 0)
+}
+
+// heldFor returns how long the next run must still wait because of a
+// Retry-After or a failure backoff, and which of the two applies.
+func (s *Scheduler) heldFor(now time.Time) (time.Duration, string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if now.Before(s.holdUntil) {
+		return s.holdUntil.Sub(now), s.holdReason
+	}
+	return 0, ""
+}
+
+// blockedFor returns the first dependency whose breaker refuses the run and
+// how long until it lets a trial through.
+func (s *Scheduler) blockedFor(now time.Time) (Dependency, time.Duration) {
+	for _, dep := range s.process.Dependencies() {
+		if br := s.breakers.Get(dep); br != nil {
+			if ok, wait := br.Allow(now); !ok {
+				return dep, wait
+			}
+		}
+	}
+	return "", 0
+}
+
+// backoff returns the minimum wait after the given number of consecutive
+// failures: nothing for the first, then twice the interval, four times, and so
+// on up to maxBackoff. It must be called with s.mu held.
+func (s *Scheduler) backoff(failures int) time.Duration {
+	if failures <= 1 || s.interval <= 0 {
+		return 0
+	}
+	d := s.interval
+	for i := 1; i < failures && d < s.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, s.maxBackoff)
+}
+
+// recordResult feeds a run's outcome back into the failure backoff and the
+// breakers of the process's dependencies.
+func (s *Scheduler) recordResult(err error) {
+	now := time.Now()
+	if err == nil {
+		for _, dep := range s.process.Dependencies() {
+			if br := s.breakers.Get(dep); br != nil {
+				br.Success()
+			}
+		}
+		s.mu.Lock()
+		s.failures = 0
+		s.mu.Unlock()
+		metrics.ProcessConsecutiveFailures.WithLabelValues(s.name).Set(0)
+		return
+	}
+	// A run interrupted by shutdown says nothing about the upstreams.
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	for _, dep := range FailedDependencies(err) {
+		if br := s.breakers.Get(dep); br != nil {
+			br.Failure(err, now)
+		}
+	}
+
+	s.mu.Lock()
+	s.failures++
+	failures := s.failures
+	hold, reason := s.backoff(failures), "backoff"
+	if after, ok := RetryAfter(err); ok && after >= hold {
+		hold, reason = after, "retry_after"
+	}
+	if until := now.Add(hold); hold > 0 && until.After(s.holdUntil) {
+		s.holdUntil, s.holdReason = until, reason
+	}
+	s.mu.Unlock()
+	metrics.ProcessConsecutiveFailures.WithLabelValues(s.name).Set(float64(failures))
+}
 
-	s.ticker.Reset(newInterval)
-	s.interval = newInterval
+// randomJitter must be called with s.mu held.
+func (s *Scheduler) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(s.jitter)))
+}
+
+// setSchedule must be called with s.mu held or before the scheduler starts.
+func (s *Scheduler) setSchedule(schedule Schedule) {
+	s.schedule = schedule
+	s.interval = scheduleInterval(schedule, time.Now())
+	s.offset = Offset(s.offsetKey, min(s.spread, s.interval))
+}
+
+// ResetInterval changes the interval dynamically.
+func (s *Scheduler) ResetInterval(newInterval time.Duration) {
+	s.mu.Lock()
+	s.setSchedule(everySchedule{interval: newInterval})
+	s.mu.Unlock()
+	s.signalReset()
 	s.log.Info().
 		Str("Process", s.process.Name()).
 		Dur("newInterval", newInterval).
 		Msg("Scheduler interval reset")
 }
 
-// ResetIntervalFromExpr changes the ticker interval using an expression string.
+// ResetIntervalFromExpr changes the schedule using an expression string.
 func (s *Scheduler) ResetIntervalFromExpr(intervalExpr string) error {
-	duration, err := parseEveryExpr(intervalExpr)
+	schedule, err := ParseSchedule(intervalExpr)
 	if err != nil {
 		return fmt.Errorf("failed to parse interval: %w", err)
 	}
-
-	s.ResetInterval(duration)
-
+	s.mu.Lock()
+	s.setSchedule(schedule)
+	interval := s.interval
+	s.mu.Unlock()
+	s.signalReset()
+	s.log.Info().
+		Str("Process", s.process.Name()).
+		Str("schedule", intervalExpr).
+		Dur("newInterval", interval).
+		Msg("Scheduler interval reset")
 	return nil
 }
 
+func (s *Scheduler) signalReset() {
+	select {
+	case s.reset <- struct{}{}:
+	default:
+	}
+}
+
 // GetInterval returns the current interval.
 func (s *Scheduler) GetInterval() time.Duration {
 	s.mu.Lock()
@@ -149,7 +359,11 @@ func (s *Scheduler) launchProcess(ctx context.Context) {
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			if err := s.process.Execute(ctx); err != nil {
+			started := time.Now()
+			err := s.process.Execute(ctx)
+			s.observe(started, err)
+			s.recordResult(err)
+			if err != nil {
 				s.log.Warn().
 					Str("Process", s.process.Name()).
 					Err(err).
@@ -163,14 +377,13 @@ func (s *Scheduler) launchProcess(ctx context.Context) {
 	}
 }
 
-func parseEveryExpr(expr string) (time.Duration, error) {
-	const prefix = "@every "
-	if expr == "" {
-		return 0, errors.New("empty expression provided")
-	}
-	if !strings.HasPrefix(expr, prefix) {
-		return 0, fmt.Errorf("unsupported format: must start with %q", prefix)
+// observe records the duration and outcome of a process execution.
+func (s *Scheduler) observe(started time.Time, err error) {
+	metrics.ProcessDuration.WithLabelValues(s.name).Observe(time.Since(started).Seconds())
+	if err != nil {
+		metrics.ProcessRuns.WithLabelValues(s.name, metrics.OutcomeFailure).Inc()
+		return
 	}
-
-	return time.ParseDuration(strings.TrimPrefix(expr, prefix))
+	metrics.ProcessRuns.WithLabelValues(s.name, metrics.OutcomeSuccess).Inc()
+	metrics.ProcessLastSuccess.WithLabelValues(s.name).SetToCurrentTime()
 }
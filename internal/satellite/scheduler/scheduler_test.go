@@ -2,11 +2,14 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
@@ -20,12 +23,15 @@ type mockProcess struct {
 	execDelay time.Duration
 	execErr   error
 	execFn    func(ctx context.Context) error
+	deps      []Dependency
 }
 
 func (m *mockProcess) Name() string     { return m.name }
 func (m *mockProcess) IsRunning() bool  { return m.running.Load() }
 func (m *mockProcess) IsComplete() bool { return m.complete.Load() }
 
+func (m *mockProcess) Dependencies() []Dependency { return m.deps }
+
 func (m *mockProcess) Execute(ctx context.Context) error {
 	m.running.Store(true)
 	defer m.running.Store(false)
@@ -225,3 +231,195 @@ func TestMultipleSchedulers_GracefulShutdown(t *testing.T) {
 		t.Fatal("timed out waiting for all schedulers to stop")
 	}
 }
+
+func TestLaunchProcess_RecordsMetrics(t *testing.T) {
+	proc := &mockProcess{name: "metrics-task", execErr: errors.New("boom")}
+
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Start(ctx)
+	require.Eventually(t, func() bool { return proc.execCount.Load() == 1 && !proc.IsRunning() }, time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.ProcessRuns.WithLabelValues("metrics-task", metrics.OutcomeFailure)))
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.ProcessRuns.WithLabelValues("metrics-task", metrics.OutcomeSuccess)))
+}
+
+func TestSpread_DelaysFirstRun(t *testing.T) {
+	proc := &mockProcess{name: "spread-task"}
+
+	const key = "edge-1"
+	spread := 200 * time.Millisecond
+	offset := Offset(key, spread)
+
+	sched, err := NewSchedulerWithInterval("@every 1h", proc, nopLogger(), WithSpread(key, spread))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := time.Now()
+	sched.Start(ctx)
+
+	require.Eventually(t, func() bool { return proc.execCount.Load() == 1 }, time.Second, 5*time.Millisecond)
+	require.GreaterOrEqual(t, time.Since(started), offset)
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
+
+func TestSpread_CappedAtInterval(t *testing.T) {
+	sched, err := NewSchedulerWithInterval("@every 50ms", &mockProcess{name: "capped"}, nopLogger(), WithSpread("edge-1", time.Hour))
+	require.NoError(t, err)
+	require.Less(t, sched.offset, 50*time.Millisecond)
+}
+
+func TestJitter_BoundsNextDelay(t *testing.T) {
+	jitter := 100 * time.Millisecond
+	sched, err := NewSchedulerWithInterval("@every 1s", &mockProcess{name: "jittered"}, nopLogger(), WithJitter(jitter))
+	require.NoError(t, err)
+
+	now := time.Now()
+	for range 50 {
+		d := sched.nextDelay(now)
+		require.GreaterOrEqual(t, d, time.Second)
+		require.Less(t, d, time.Second+jitter)
+	}
+}
+
+func TestNextDelay_CronOffsetShiftsSlots(t *testing.T) {
+	sched, err := NewSchedulerWithInterval("*/5 * * * *", &mockProcess{name: "cron"}, nopLogger())
+	require.NoError(t, err)
+	sched.offset = 90 * time.Second
+
+	now := time.Date(2025, 3, 10, 12, 0, 30, 0, time.Local)
+	// The 12:00 slot shifted by 90s is still ahead.
+	require.Equal(t, time.Minute, sched.nextDelay(now))
+
+	now = time.Date(2025, 3, 10, 12, 2, 0, 0, time.Local)
+	require.Equal(t, 4*time.Minute+30*time.Second, sched.nextDelay(now))
+}
+
+func TestRetryAfter_HoldsNextRun(t *testing.T) {
+	proc := &mockProcess{
+		name:    "rate-limited",
+		execErr: &RetryAfterError{Err: errors.New("429 Too Many Requests"), After: 300 * time.Millisecond},
+	}
+
+	sched, err := NewSchedulerWithInterval("@every 20ms", proc, nopLogger())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched.Start(ctx)
+
+	require.Eventually(t, func() bool { return proc.execCount.Load() == 1 }, time.Second, 5*time.Millisecond)
+	// Without the hold the 20ms interval would have run several more times.
+	time.Sleep(150 * time.Millisecond)
+	require.Equal(t, int32(1), proc.execCount.Load())
+
+	require.Eventually(t, func() bool { return proc.execCount.Load() >= 2 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
+
+func TestBackoff_ExponentialWithCap(t *testing.T) {
+	sched, err := NewSchedulerWithInterval("@every 10s", &mockProcess{name: "backoff"}, nopLogger(), WithMaxBackoff(time.Minute))
+	require.NoError(t, err)
+
+	require.Zero(t, sched.backoff(1))
+	require.Equal(t, 20*time.Second, sched.backoff(2))
+	require.Equal(t, 40*time.Second, sched.backoff(3))
+	require.Equal(t, time.Minute, sched.backoff(4))
+	require.Equal(t, time.Minute, sched.backoff(50))
+}
+
+func TestRecordResult_BacksOffAndResets(t *testing.T) {
+	breakers := NewBreakers()
+	proc := &mockProcess{name: "sync", deps: []Dependency{DependencyHarbor, DependencyLocalRegistry}}
+	sched, err := NewSchedulerWithInterval("@every 10s", proc, nopLogger(), WithBreakers(breakers))
+	require.NoError(t, err)
+
+	harborDown := DependencyFailure(DependencyHarbor, errors.New("503 Service Unavailable"))
+	for range DefaultBreakerThreshold {
+		sched.recordResult(harborDown)
+	}
+
+	hold, reason := sched.heldFor(time.Now())
+	require.Equal(t, "backoff", reason)
+	require.Greater(t, hold, 30*time.Second)
+	require.Equal(t, BreakerOpen, breakers.Get(DependencyHarbor).Status().State)
+	require.Equal(t, BreakerClosed, breakers.Get(DependencyLocalRegistry).Status().State)
+
+	dep, wait := sched.blockedFor(time.Now())
+	require.Equal(t, DependencyHarbor, dep)
+	require.Greater(t, wait, time.Duration(0))
+
+	// Shutdown does not count as a failure.
+	sched.recordResult(context.Canceled)
+	require.Equal(t, DefaultBreakerThreshold, sched.failures)
+
+	sched.recordResult(nil)
+	require.Zero(t, sched.failures)
+	require.Equal(t, BreakerClosed, breakers.Get(DependencyHarbor).Status().State)
+}
+
+func TestOpenBreaker_SkipsRuns(t *testing.T) {
+	breakers := NewBreakers()
+	for range DefaultBreakerThreshold {
+		breakers.Get(DependencyGroundControl).Failure(errors.New("down"), time.Now())
+	}
+
+	heartbeat := &mockProcess{name: "heartbeat", deps: []Dependency{DependencyGroundControl}}
+	sync := &mockProcess{name: "sync", deps: []Dependency{DependencyHarbor}}
+
+	hbSched, err := NewSchedulerWithInterval("@every 10ms", heartbeat, nopLogger(), WithBreakers(breakers))
+	require.NoError(t, err)
+	syncSched, err := NewSchedulerWithInterval("@every 10ms", sync, nopLogger(), WithBreakers(breakers))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hbSched.Start(ctx)
+	syncSched.Start(ctx)
+
+	require.Eventually(t, func() bool { return sync.execCount.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	require.Zero(t, heartbeat.execCount.Load(), "open Ground Control breaker must skip the heartbeat")
+
+	cancel()
+	require.NoError(t, hbSched.Stop(context.Background()))
+	require.NoError(t, syncSched.Stop(context.Background()))
+}
+
+// skipObservingProcess is a mockProcess that counts skipped runs.
+type skipObservingProcess struct {
+	mockProcess
+	skipped atomic.Int32
+}
+
+func (p *skipObservingProcess) Skipped(_ context.Context, dep Dependency) {
+	if dep == DependencyHarbor {
+		p.skipped.Add(1)
+	}
+}
+
+func TestOpenBreaker_ReportsSkippedRuns(t *testing.T) {
+	breakers := NewBreakers()
+	for range DefaultBreakerThreshold {
+		breakers.Get(DependencyHarbor).Failure(errors.New("down"), time.Now())
+	}
+
+	sync := &skipObservingProcess{mockProcess: mockProcess{name: "sync", deps: []Dependency{DependencyHarbor}}}
+	sched, err := NewSchedulerWithInterval("@every 10ms", sync, nopLogger(), WithBreakers(breakers))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Start(ctx)
+	require.Eventually(t, func() bool { return sync.skipped.Load() >= 1 }, time.Second, 5*time.Millisecond)
+	require.Zero(t, sync.execCount.Load())
+
+	cancel()
+	require.NoError(t, sched.Stop(context.Background()))
+}
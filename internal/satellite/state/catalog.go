@@ -6,20 +6,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 
+	"github.com/container-registry/harbor-satellite/internal/inventory"
 	"github.com/container-registry/harbor-satellite/internal/logger"
 )
 
-type CachedImage struct {
-	Reference string `json:"reference"`
-	SizeBytes int64  `json:"size_bytes"`
-}
-
 type catalogResponse struct {
 	Repositories []string `json:"repositories"`
 }
@@ -28,92 +30,151 @@ type tagsResponse struct {
 	Tags []string `json:"tags"`
 }
 
-func collectCachedImages(ctx context.Context, registryHost string, insecure bool) ([]CachedImage, error) {
+// collectCachedImages walks the catalog of the local registry and returns
+// every tag it holds.
+func collectCachedImages(ctx context.Context, reg localRegistry) ([]inventory.Image, error) {
 	log := logger.FromContext(ctx)
-	client := &http.Client{Timeout: 30 * time.Second}
+	client, err := reg.httpClient(30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	craneOpts, err := reg.craneOptions()
+	if err != nil {
+		return nil, err
+	}
+	craneOpts = append(craneOpts, crane.WithContext(ctx))
 
-	repos, err := fetchCatalog(ctx, client, registryHost, insecure)
+	repos, err := fetchCatalog(ctx, client, reg.host, reg.insecure)
 	if err != nil {
 		return nil, fmt.Errorf("fetch catalog: %w", err)
 	}
 
-	var images []CachedImage
+	images := []inventory.Image{}
 	for _, repo := range repos {
-		tags, err := fetchTags(ctx, client, registryHost, repo, insecure)
+		tags, err := fetchTags(ctx, client, reg.host, repo, reg.insecure)
 		if err != nil {
 			log.Warn().Err(err).Str("repo", repo).Msg("Skipping repo: failed to fetch tags")
 			continue
 		}
 		for _, tag := range tags {
-			ref := fmt.Sprintf("%s/%s:%s", registryHost, repo, tag)
-			img, err := collectImageInfo(ref, crane.WithContext(ctx), insecure)
+			ref := inventory.Ref{Repository: repo, Tag: tag}
+			img, err := collectImageInfo(reg.host, ref, craneOpts...)
 			if err != nil {
-				log.Warn().Err(err).Str("ref", ref).Msg("Skipping image: failed to collect info")
+				log.Warn().Err(err).Str("ref", ref.String()).Msg("Skipping image: failed to collect info")
 				continue
 			}
 			images = append(images, img)
 		}
 	}
 
-	if images == nil {
-		return []CachedImage{}, nil
-	}
-
 	return images, nil
 }
 
-func collectImageInfo(ref string, ctxOpt crane.Option, insecure bool) (CachedImage, error) {
-	opts := []crane.Option{ctxOpt}
-	if insecure {
-		opts = append(opts, crane.Insecure)
-	}
-
-	raw, err := crane.Manifest(ref, opts...)
+// collectImageInfo resolves a tag of the registry at host to its manifest
+// digest and size.
+func collectImageInfo(host string, ref inventory.Ref, opts ...crane.Option) (inventory.Image, error) {
+	tagRef := fmt.Sprintf("%s/%s:%s", host, ref.Repository, ref.Tag)
+	raw, err := crane.Manifest(tagRef, opts...)
 	if err != nil {
-		return CachedImage{}, fmt.Errorf("get manifest for %s: %w", ref, err)
+		return inventory.Image{}, fmt.Errorf("get manifest for %s: %w", tagRef, err)
 	}
 
-	size, err := computeManifestSize(raw)
+	size, err := imageSize(raw, func(digest string) ([]byte, error) {
+		return crane.Manifest(fmt.Sprintf("%s/%s@%s", host, ref.Repository, digest), opts...)
+	})
 	if err != nil {
-		return CachedImage{}, fmt.Errorf("compute size for %s: %w", ref, err)
+		return inventory.Image{}, fmt.Errorf("compute size for %s: %w", tagRef, err)
 	}
 
-	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(raw))
-
-	return CachedImage{
-		Reference: ref + "@" + digest,
+	return inventory.Image{
+		Ref:       ref,
+		Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(raw)),
 		SizeBytes: size,
 	}, nil
 }
 
-func computeManifestSize(raw []byte) (int64, error) {
-	var probe struct {
-		MediaType string `json:"mediaType"`
-	}
-	if err := json.Unmarshal(raw, &probe); err != nil {
-		return 0, fmt.Errorf("unmarshal manifest: %w", err)
-	}
-
-	switch probe.MediaType {
-	case "application/vnd.oci.image.index.v1+json",
-		"application/vnd.docker.distribution.manifest.list.v2+json":
-		return 0, errors.New("multi-arch manifest index not supported for size computation")
-	}
+const (
+	// pageSize is the number of repositories or tags asked for per page.
+	pageSize = 1000
+	// maxPages bounds pagination against a registry that keeps linking.
+	maxPages = 10000
+	// maxIndexDepth bounds how deep image indexes are followed.
+	maxIndexDepth = 3
+)
 
-	var m v1.Manifest
-	if err := json.Unmarshal(raw, &m); err != nil {
-		return 0, fmt.Errorf("unmarshal manifest: %w", err)
-	}
+func isIndex(mediaType types.MediaType) bool {
+	return mediaType == types.OCIImageIndex || mediaType == types.DockerManifestList
+}
 
+// imageSize returns the size of the blobs a manifest references. For an
+// image index it sums the blobs of every child manifest, fetched by digest
+// with fetch, counting blobs shared between platforms once. Every image size
+// is computed with it, so sizes agree whichever kind of manifest a tag holds.
+func imageSize(raw []byte, fetch func(digest string) ([]byte, error)) (int64, error) {
+	seen := map[v1.Hash]bool{}
 	var total int64
-	total += m.Config.Size
-	for _, layer := range m.Layers {
-		total += layer.Size
+	var walk func(raw []byte, depth int) error
+	walk = func(raw []byte, depth int) error {
+		var probe struct {
+			MediaType types.MediaType `json:"mediaType"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return fmt.Errorf("unmarshal manifest: %w", err)
+		}
+		if !isIndex(probe.MediaType) {
+			var m v1.Manifest
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return fmt.Errorf("unmarshal manifest: %w", err)
+			}
+			for _, desc := range append([]v1.Descriptor{m.Config}, m.Layers...) {
+				if desc.Digest.Hex == "" || !seen[desc.Digest] {
+					seen[desc.Digest] = true
+					total += desc.Size
+				}
+			}
+			return nil
+		}
+
+		// Indexes only nest a level or two in practice.
+		if depth >= maxIndexDepth {
+			return errors.New("image index nested too deeply")
+		}
+		var index v1.IndexManifest
+		if err := json.Unmarshal(raw, &index); err != nil {
+			return fmt.Errorf("unmarshal index: %w", err)
+		}
+		for _, child := range index.Manifests {
+			if !child.MediaType.IsImage() && !child.MediaType.IsIndex() {
+				continue
+			}
+			childRaw, err := fetch(child.Digest.String())
+			if err != nil {
+				return fmt.Errorf("get child manifest %s: %w", child.Digest, err)
+			}
+			if err := walk(childRaw, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
+	if err := walk(raw, 0); err != nil {
+		return 0, err
+	}
 	return total, nil
 }
 
+// remoteManifests fetches the manifests of repo by digest, for imageSize.
+func remoteManifests(repo name.Repository, opts ...remote.Option) func(digest string) ([]byte, error) {
+	return func(digest string) ([]byte, error) {
+		desc, err := remote.Get(repo.Digest(digest), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return desc.Manifest, nil
+	}
+}
+
 func registryScheme(insecure bool) string {
 	if insecure {
 		return "http"
@@ -122,52 +183,86 @@ func registryScheme(insecure bool) string {
 	return "https"
 }
 
-func fetchCatalog(ctx context.Context, client *http.Client, registryHost string, insecure bool) (_ []string, retErr error) {
-	url := fmt.Sprintf("%s://%s/v2/_catalog", registryScheme(insecure), registryHost)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create catalog request: %w", err)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("catalog request: %w", err)
-	}
-	defer func() { retErr = errors.Join(retErr, resp.Body.Close()) }()
+func fetchCatalog(ctx context.Context, client *http.Client, registryHost string, insecure bool) ([]string, error) {
+	pageURL := fmt.Sprintf("%s://%s/v2/_catalog?n=%d", registryScheme(insecure), registryHost, pageSize)
+	var repos []string
+	err := fetchPages(ctx, client, pageURL, "catalog", func(body io.Reader) error {
+		var catalog catalogResponse
+		if err := json.NewDecoder(body).Decode(&catalog); err != nil {
+			return fmt.Errorf("decode catalog: %w", err)
+		}
+		repos = append(repos, catalog.Repositories...)
+		return nil
+	})
+	return repos, err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("catalog request returned %s", resp.Status)
-	}
+func fetchTags(ctx context.Context, client *http.Client, registryHost, repo string, insecure bool) ([]string, error) {
+	pageURL := fmt.Sprintf("%s://%s/v2/%s/tags/list?n=%d", registryScheme(insecure), registryHost, repo, pageSize)
+	var tags []string
+	err := fetchPages(ctx, client, pageURL, "tags", func(body io.Reader) error {
+		var page tagsResponse
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return fmt.Errorf("decode tags: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+		return nil
+	})
+	return tags, err
+}
 
-	var catalog catalogResponse
-	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
-		return nil, fmt.Errorf("decode catalog: %w", err)
+// fetchPages GETs pageURL and every page after it, following the Link headers of
+// the distribution spec, and hands each body to decode.
+func fetchPages(ctx context.Context, client *http.Client, pageURL, what string, decode func(io.Reader) error) error {
+	for page := 0; pageURL != ""; page++ {
+		if page >= maxPages {
+			return fmt.Errorf("%s has more than %d pages", what, maxPages)
+		}
+		next, err := fetchPage(ctx, client, pageURL, what, decode)
+		if err != nil {
+			return err
+		}
+		pageURL = next
 	}
-
-	return catalog.Repositories, nil
+	return nil
 }
 
-func fetchTags(ctx context.Context, client *http.Client, registryHost, repo string, insecure bool) (_ []string, retErr error) {
-	url := fmt.Sprintf("%s://%s/v2/%s/tags/list", registryScheme(insecure), registryHost, repo)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func fetchPage(ctx context.Context, client *http.Client, pageURL, what string, decode func(io.Reader) error) (_ string, retErr error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create tags request: %w", err)
+		return "", fmt.Errorf("create %s request: %w", what, err)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("tags request: %w", err)
+		return "", fmt.Errorf("%s request: %w", what, err)
 	}
 	defer func() { retErr = errors.Join(retErr, resp.Body.Close()) }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("tags request returned %s", resp.Status)
+		return "", fmt.Errorf("%s request returned %s", what, resp.Status)
 	}
-
-	var tags tagsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
-		return nil, fmt.Errorf("decode tags: %w", err)
+	if err := decode(resp.Body); err != nil {
+		return "", err
 	}
+	return nextPageURL(req.URL, resp.Header.Get("Link"))
+}
 
-	return tags.Tags, nil
+// nextPageURL returns the URL of the rel="next" link of a Link header,
+// resolved against the URL of the current page, or "" on the last page.
+func nextPageURL(current *url.URL, link string) (string, error) {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+		target = strings.TrimSpace(target)
+		target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+		next, err := current.Parse(target)
+		if err != nil {
+			return "", fmt.Errorf("parse next page link %q: %w", target, err)
+		}
+		return next.String(), nil
+	}
+	return "", nil
 }
@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/container-registry/harbor-satellite/internal/inventory"
 	"github.com/container-registry/harbor-satellite/internal/logger"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/rs/zerolog"
@@ -27,7 +29,7 @@ func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
 	require.NoError(t, json.NewEncoder(w).Encode(v))
 }
 
-func TestComputeManifestSize(t *testing.T) {
+func TestImageSizeOfManifest(t *testing.T) {
 	tests := []struct {
 		name     string
 		manifest string
@@ -65,20 +67,20 @@ func TestComputeManifestSize(t *testing.T) {
 			wantSize: 42,
 		},
 		{
-			name: "multi-arch OCI index returns error",
+			name: "OCI index with a missing child returns error",
 			manifest: `{
 				"schemaVersion": 2,
 				"mediaType": "application/vnd.oci.image.index.v1+json",
-				"manifests": [{"digest": "sha256:aaa", "size": 500}]
+				"manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "size": 500}]
 			}`,
 			wantErr: true,
 		},
 		{
-			name: "docker manifest list returns error",
+			name: "docker manifest list with a missing child returns error",
 			manifest: `{
 				"schemaVersion": 2,
 				"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
-				"manifests": [{"digest": "sha256:bbb", "size": 600}]
+				"manifests": [{"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "digest": "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "size": 600}]
 			}`,
 			wantErr: true,
 		},
@@ -91,7 +93,9 @@ func TestComputeManifestSize(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			size, err := computeManifestSize([]byte(tt.manifest))
+			size, err := imageSize([]byte(tt.manifest), func(digest string) ([]byte, error) {
+				return nil, fmt.Errorf("manifest %s not found", digest)
+			})
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -117,6 +121,26 @@ func TestFetchCatalog(t *testing.T) {
 		require.Equal(t, []string{"library/nginx", "library/alpine"}, repos)
 	})
 
+	t.Run("follows pagination links", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("last") {
+			case "":
+				w.Header().Set("Link", `</v2/_catalog?last=library%2Fnginx&n=2>; rel="next"`)
+				writeJSON(t, w, catalogResponse{Repositories: []string{"library/alpine", "library/nginx"}})
+			case "library/nginx":
+				writeJSON(t, w, catalogResponse{Repositories: []string{"library/redis"}})
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer srv.Close()
+
+		addr := strings.TrimPrefix(srv.URL, "http://")
+		repos, err := fetchCatalog(context.Background(), srv.Client(), addr, true)
+		require.NoError(t, err)
+		require.Equal(t, []string{"library/alpine", "library/nginx", "library/redis"}, repos)
+	})
+
 	t.Run("empty catalog", func(t *testing.T) {
 		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 			writeJSON(t, w, catalogResponse{Repositories: []string{}})
@@ -191,6 +215,71 @@ func TestFetchTags(t *testing.T) {
 	})
 }
 
+func TestNextPageURL(t *testing.T) {
+	current, err := url.Parse("https://registry:5000/v2/_catalog?n=100")
+	require.NoError(t, err)
+
+	next, err := nextPageURL(current, `</v2/_catalog?last=b&n=100>; rel="next"`)
+	require.NoError(t, err)
+	require.Equal(t, "https://registry:5000/v2/_catalog?last=b&n=100", next)
+
+	next, err = nextPageURL(current, `<https://other/prev>; rel="prev", <https://registry:5000/v2/_catalog?last=c>; rel=next`)
+	require.NoError(t, err)
+	require.Equal(t, "", next)
+
+	next, err = nextPageURL(current, "")
+	require.NoError(t, err)
+	require.Equal(t, "", next)
+}
+
+func TestImageSize(t *testing.T) {
+	digest := func(c string) string { return "sha256:" + strings.Repeat(c, 64) }
+	manifest := func(configSize int64, config string, layers ...any) string {
+		var descs []string
+		for i := 0; i < len(layers); i += 2 {
+			descs = append(descs, fmt.Sprintf(`{"size": %d, "digest": %q}`, layers[i], layers[i+1]))
+		}
+		return fmt.Sprintf(`{
+			"schemaVersion": 2,
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"config": {"size": %d, "digest": %q},
+			"layers": [%s]
+		}`, configSize, config, strings.Join(descs, ","))
+	}
+	amd64 := manifest(100, digest("c"), 1000, digest("e"), 500, digest("a"))
+	arm64 := manifest(200, digest("d"), 1000, digest("e"), 700, digest("b"))
+	index := fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.oci.image.index.v1+json",
+		"manifests": [
+			{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": %q, "size": 300},
+			{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": %q, "size": 300}
+		]
+	}`, digest("1"), digest("2"))
+
+	children := map[string]string{digest("1"): amd64, digest("2"): arm64}
+	fetch := func(d string) ([]byte, error) {
+		raw, ok := children[d]
+		if !ok {
+			return nil, fmt.Errorf("manifest %s not found", d)
+		}
+		return []byte(raw), nil
+	}
+
+	size, err := imageSize([]byte(index), fetch)
+	require.NoError(t, err)
+	// The layer shared by both platforms counts once.
+	require.Equal(t, int64(100+1000+500+200+700), size)
+
+	size, err = imageSize([]byte(amd64), fetch)
+	require.NoError(t, err)
+	require.Equal(t, int64(1600), size)
+
+	delete(children, digest("2"))
+	_, err = imageSize([]byte(index), fetch)
+	require.Error(t, err)
+}
+
 func TestCollectCachedImages(t *testing.T) {
 	t.Run("empty catalog returns empty slice", func(t *testing.T) {
 		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -200,7 +289,7 @@ func TestCollectCachedImages(t *testing.T) {
 
 		addr := strings.TrimPrefix(srv.URL, "http://")
 		ctx := testContext()
-		images, err := collectCachedImages(ctx, addr, true)
+		images, err := collectCachedImages(ctx, localRegistry{host: addr, insecure: true})
 		require.NoError(t, err)
 		require.Empty(t, images)
 		require.NotNil(t, images)
@@ -218,14 +307,14 @@ func TestCollectCachedImages(t *testing.T) {
 
 		addr := strings.TrimPrefix(srv.URL, "http://")
 		ctx := testContext()
-		images, err := collectCachedImages(ctx, addr, true)
+		images, err := collectCachedImages(ctx, localRegistry{host: addr, insecure: true})
 		require.NoError(t, err)
 		require.Empty(t, images)
 	})
 
 	t.Run("unreachable registry returns error", func(t *testing.T) {
 		ctx := testContext()
-		_, err := collectCachedImages(ctx, "127.0.0.1:1", true)
+		_, err := collectCachedImages(ctx, localRegistry{host: "127.0.0.1:1", insecure: true})
 		require.Error(t, err)
 	})
 }
@@ -242,8 +331,9 @@ func TestCollectImageInfo_MissingManifest(t *testing.T) {
 	defer srv.Close()
 
 	addr := strings.TrimPrefix(srv.URL, "https://")
-	_, err := collectImageInfo(addr+"/library/nginx:latest", crane.WithContext(context.Background()), true)
+	_, err := collectImageInfo(addr, inventory.Ref{Repository: "library/nginx", Tag: "latest"}, crane.WithContext(context.Background()), crane.Insecure)
 	require.Error(t, err)
+	require.True(t, isNotFound(err))
 }
 
 func TestCollectImageInfo_FullFlow(t *testing.T) {
@@ -279,8 +369,10 @@ func TestCollectImageInfo_FullFlow(t *testing.T) {
 
 	addr := strings.TrimPrefix(srv.URL, "https://")
 
-	img, err := collectImageInfo(addr+"/library/nginx:latest", crane.WithContext(context.Background()), true)
+	img, err := collectImageInfo(addr, inventory.Ref{Repository: "library/nginx", Tag: "latest"}, crane.WithContext(context.Background()), crane.Insecure)
 	require.NoError(t, err)
-	require.Contains(t, img.Reference, "library/nginx:latest@"+expectedDigest)
+	require.Equal(t, "library/nginx", img.Repository)
+	require.Equal(t, "latest", img.Tag)
+	require.Equal(t, expectedDigest, img.Digest)
 	require.Equal(t, int64(9000), img.SizeBytes)
 }
@@ -0,0 +1,310 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// ProbationState is the persisted part of a config probation.
+type ProbationState struct {
+	// Digest is the config on probation, empty when none is.
+	Digest string `json:"digest,omitempty"`
+	// PreviousDigest is the config restored on rollback.
+	PreviousDigest string `json:"previous_digest,omitempty"`
+	// PreviousRevision is the Ground Control revision of that config.
+	PreviousRevision int32 `json:"previous_revision,omitempty"`
+	Cycles           int   `json:"cycles,omitempty"`
+	Failures         int   `json:"failures,omitempty"`
+	// RejectedDigest is the last config rolled back. It is not applied again
+	// until Ground Control publishes a different config.
+	RejectedDigest string `json:"rejected_digest,omitempty"`
+}
+
+// ConfigRollback describes an automatic rollback of a config delivered by
+// Ground Control.
+type ConfigRollback struct {
+	RejectedDigest string `json:"rejected_digest"`
+	RestoredDigest string `json:"restored_digest,omitempty"`
+	// RestoredRevision is zero when the restored config carries no revision.
+	RestoredRevision int32     `json:"restored_revision,omitempty"`
+	Reason           string    `json:"reason"`
+	Time             time.Time `json:"time"`
+}
+
+// ConfigProbation watches the satellite's health after a config from Ground
+// Control is applied. Every state replication cycle on probation is judged
+// healthy or not; enough consecutive unhealthy cycles call for a rollback.
+// It is shared by the replication and heartbeat processes.
+type ConfigProbation struct {
+	mu    sync.Mutex
+	state ProbationState
+	// gcErr is the last failure to reach Ground Control. It holds until a
+	// heartbeat gets through, so cycles whose heartbeats the Ground Control
+	// breaker holds back count as unhealthy too.
+	gcErr error
+	// fresh is set for the cycle that applied the config; that cycle ran
+	// under the old config and is not judged.
+	fresh bool
+	// unreported is the last rollback not yet sent to Ground Control.
+	unreported *ConfigRollback
+}
+
+// NewConfigProbation restores a probation from its persisted state, which
+// may be nil.
+func NewConfigProbation(persisted *ProbationState) *ConfigProbation {
+	p := &ConfigProbation{}
+	if persisted != nil {
+		p.state = *persisted
+	}
+	return p
+}
+
+// State returns the state to persist. Like the other accessors it is safe to
+// call on a nil probation.
+func (p *ConfigProbation) State() *ProbationState {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state == (ProbationState{}) {
+		return nil
+	}
+	s := p.state
+	return &s
+}
+
+// Active reports whether a config is on probation.
+func (p *ConfigProbation) Active() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.Digest != ""
+}
+
+// Rejected returns the digest of the last config rolled back, if any.
+func (p *ConfigProbation) Rejected() string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.RejectedDigest
+}
+
+// Start puts digest on probation. previous and previousRevision identify the
+// config it replaced. Starting a probation for a new config also lifts the
+// rejection of an older one.
+func (p *ConfigProbation) Start(digest, previous string, previousRevision int32) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = ProbationState{Digest: digest, PreviousDigest: previous, PreviousRevision: previousRevision}
+	p.fresh = true
+}
+
+// RecordGroundControl records the outcome of a call to Ground Control. Only
+// failures attributed to Ground Control count against the probation, and a
+// later success clears them.
+func (p *ConfigProbation) RecordGroundControl(err error) {
+	if err != nil && !slices.Contains(scheduler.FailedDependencies(err), scheduler.DependencyGroundControl) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gcErr = err
+}
+
+// EndCycle judges one replication cycle. syncErr and registryErr are the
+// outcomes of the sync and of the local registry health check. It returns a
+// non-empty reason when the config on probation should be rolled back. Once
+// the probation has lasted its configured number of cycles without a
+// rollback, the config is accepted.
+func (p *ConfigProbation) EndCycle(cfg config.ConfigRollbackConfig, syncErr, registryErr error) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state.Digest == "" {
+		return ""
+	}
+	if p.fresh {
+		p.fresh = false
+		return ""
+	}
+
+	var problems []string
+	if p.gcErr != nil {
+		problems = append(problems, "ground control unreachable: "+p.gcErr.Error())
+	}
+	if syncErr != nil {
+		problems = append(problems, "sync failed: "+syncErr.Error())
+	}
+	if registryErr != nil {
+		problems = append(problems, "local registry unhealthy: "+registryErr.Error())
+	}
+
+	p.state.Cycles++
+	if len(problems) == 0 {
+		p.state.Failures = 0
+	} else {
+		p.state.Failures++
+	}
+
+	if p.state.Failures >= cfg.FailureThresholdOrDefault() {
+		return fmt.Sprintf("%d consecutive unhealthy cycles, last: %s", p.state.Failures, strings.Join(problems, "; "))
+	}
+	if p.state.Cycles >= cfg.ProbationCyclesOrDefault() && p.state.Failures == 0 {
+		p.state = ProbationState{RejectedDigest: p.state.RejectedDigest}
+	}
+	return ""
+}
+
+// RolledBack records that the config on probation was rolled back and
+// returns the rollback to report.
+func (p *ConfigProbation) RolledBack(reason string, now time.Time) ConfigRollback {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rb := ConfigRollback{
+		RejectedDigest:   p.state.Digest,
+		RestoredDigest:   p.state.PreviousDigest,
+		RestoredRevision: p.state.PreviousRevision,
+		Reason:           reason,
+		Time:             now.UTC(),
+	}
+	p.state = ProbationState{RejectedDigest: p.state.Digest}
+	p.unreported = &rb
+	return rb
+}
+
+// Unreported returns the last rollback not yet sent to Ground Control.
+func (p *ConfigProbation) Unreported() *ConfigRollback {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.unreported == nil {
+		return nil
+	}
+	rb := *p.unreported
+	return &rb
+}
+
+// Reported marks rb as sent, unless a newer rollback happened meanwhile.
+func (p *ConfigProbation) Reported(rb *ConfigRollback) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rb != nil && p.unreported != nil && *p.unreported == *rb {
+		p.unreported = nil
+	}
+}
+
+// ConfigProbation returns the probation shared with the heartbeat process.
+func (f *FetchAndReplicateStateProcess) ConfigProbation() *ConfigProbation {
+	return f.probation
+}
+
+// Skipped judges a replication cycle the scheduler skipped because the
+// breaker of dep is open. A config that cuts the satellite off from its
+// upstreams must still be rolled back.
+func (f *FetchAndReplicateStateProcess) Skipped(ctx context.Context, dep scheduler.Dependency) {
+	log := logger.FromContext(ctx).With().Str("process", f.name).Logger()
+	f.judgeConfigProbation(ctx, fmt.Errorf("skipped, %s circuit breaker open", dep), &log)
+}
+
+// judgeConfigProbation ends a replication cycle for a config on probation and
+// restores the previous config when the satellite has been unhealthy for too
+// long under the new one.
+func (f *FetchAndReplicateStateProcess) judgeConfigProbation(ctx context.Context, syncErr error, log *zerolog.Logger) {
+	if !f.probation.Active() || ctx.Err() != nil {
+		return
+	}
+
+	registryErr := probeLocalRegistry(ctx, newLocalRegistry(f.cm))
+	reason := f.probation.EndCycle(f.cm.GetConfigRollbackConfig(), syncErr, registryErr)
+	if reason == "" {
+		f.persistProbation(log)
+		return
+	}
+
+	rejected := f.probation.State().Digest
+	log.Warn().Str("rejected_digest", rejected).Str("reason", reason).Msg("Config on probation is unhealthy, rolling back to the previous config")
+	if err := f.cm.RollbackToPrevConfig(); err != nil {
+		// The probation stays over the threshold, so the next cycle retries.
+		log.Error().Err(err).Msg("Failed to roll back to the previous config")
+		return
+	}
+
+	rb := f.probation.RolledBack(reason, time.Now())
+	metrics.ConfigRollbacks.Inc()
+	logger.AuditFromContext(ctx).Log(logger.AuditEvent{
+		Operation:    logger.OpUpdate,
+		ResourceType: logger.ResConfig,
+		Outcome:      logger.OutcomeFailure,
+		ActorType:    logger.ActorSatellite,
+		Reason:       logger.ReasonConfigRolledBack,
+		Details: map[string]any{
+			"rejected_digest": rb.RejectedDigest,
+			"restored_digest": rb.RestoredDigest,
+			"error":           rb.Reason,
+		},
+	})
+
+	f.mu.Lock()
+	f.currentConfigDigest = rb.RestoredDigest
+	f.currentConfigRevision = rb.RestoredRevision
+	f.mu.Unlock()
+	metrics.ObserveConfigDigest(rb.RestoredDigest)
+	f.persistProbation(log)
+}
+
+func (f *FetchAndReplicateStateProcess) persistProbation(log *zerolog.Logger) {
+	if f.stateFilePath == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest, f.currentConfigRevision, f.probation.State()); err != nil {
+		log.Warn().Err(err).Msg("Failed to persist config probation")
+	}
+}
+
+// probeLocalRegistry checks that the local registry serves the distribution
+// API. Any answer below 500, including 401 from a registry with auth, counts
+// as healthy.
+func probeLocalRegistry(ctx context.Context, reg localRegistry) error {
+	if reg.host == "" {
+		return errors.New("local registry URL is not configured")
+	}
+	client, err := reg.httpClient(10 * time.Second)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/v2/", registryScheme(reg.insecure), reg.host), nil)
+	if err != nil {
+		return fmt.Errorf("create registry probe: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("registry probe: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("registry probe returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/crypto"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigProbation(t *testing.T) {
+	cfg := config.ConfigRollbackConfig{ProbationCycles: 3, FailureThreshold: 2}
+	syncErr := errors.New("push failed")
+
+	t.Run("healthy cycles accept the config", func(t *testing.T) {
+		p := NewConfigProbation(nil)
+		p.Start("sha256:new", "sha256:old", 0)
+		require.Empty(t, p.EndCycle(cfg, syncErr, nil), "the cycle that applied the config is not judged")
+		for range 3 {
+			require.Empty(t, p.EndCycle(cfg, nil, nil))
+		}
+		require.False(t, p.Active())
+		require.Nil(t, p.State())
+	})
+
+	t.Run("consecutive unhealthy cycles call for a rollback", func(t *testing.T) {
+		p := NewConfigProbation(nil)
+		p.Start("sha256:new", "sha256:old", 3)
+		p.EndCycle(cfg, nil, nil)
+
+		require.Empty(t, p.EndCycle(cfg, syncErr, nil))
+		require.Empty(t, p.EndCycle(cfg, nil, nil), "a healthy cycle resets the count")
+		require.Empty(t, p.EndCycle(cfg, nil, errors.New("connection refused")))
+		reason := p.EndCycle(cfg, syncErr, nil)
+		require.Contains(t, reason, "2 consecutive unhealthy cycles")
+		require.Contains(t, reason, "push failed")
+
+		rb := p.RolledBack(reason, time.Now())
+		require.Equal(t, "sha256:new", rb.RejectedDigest)
+		require.Equal(t, "sha256:old", rb.RestoredDigest)
+		require.Equal(t, int32(3), rb.RestoredRevision)
+		require.Equal(t, "sha256:new", p.Rejected())
+		require.False(t, p.Active())
+
+		unreported := p.Unreported()
+		require.NotNil(t, unreported)
+		p.Reported(unreported)
+		require.Nil(t, p.Unreported())
+
+		// A newer config lifts the rejection.
+		p.Start("sha256:newer", "sha256:old", 0)
+		require.Empty(t, p.Rejected())
+	})
+
+	t.Run("only Ground Control failures count", func(t *testing.T) {
+		p := NewConfigProbation(nil)
+		p.Start("sha256:new", "sha256:old", 0)
+		p.EndCycle(cfg, nil, nil)
+
+		p.RecordGroundControl(errors.New("status report failed: 400 Bad Request"))
+		require.Empty(t, p.EndCycle(cfg, nil, nil))
+		require.Equal(t, 0, p.State().Failures)
+
+		p.RecordGroundControl(scheduler.DependencyFailure(scheduler.DependencyGroundControl, errors.New("connection refused")))
+		require.Empty(t, p.EndCycle(cfg, nil, nil))
+		require.Equal(t, 1, p.State().Failures)
+
+		p.RecordGroundControl(scheduler.DependencyFailure(scheduler.DependencyGroundControl, errors.New("connection refused")))
+		p.RecordGroundControl(nil)
+		require.Empty(t, p.EndCycle(cfg, nil, nil), "a later success clears the failure")
+	})
+
+	t.Run("a Ground Control failure holds until a heartbeat succeeds", func(t *testing.T) {
+		p := NewConfigProbation(nil)
+		p.Start("sha256:new", "sha256:old", 0)
+		p.EndCycle(cfg, nil, nil)
+
+		p.RecordGroundControl(scheduler.DependencyFailure(scheduler.DependencyGroundControl, errors.New("connection refused")))
+		require.Empty(t, p.EndCycle(cfg, nil, nil))
+		// No heartbeat is sent while the breaker is open.
+		reason := p.EndCycle(cfg, nil, nil)
+		require.Contains(t, reason, "ground control unreachable")
+	})
+
+	t.Run("persisted state survives a restart", func(t *testing.T) {
+		p := NewConfigProbation(nil)
+		p.Start("sha256:new", "sha256:old", 0)
+		p.EndCycle(cfg, nil, nil)
+		p.EndCycle(cfg, syncErr, nil)
+
+		restored := NewConfigProbation(p.State())
+		require.True(t, restored.Active())
+		require.NotEmpty(t, restored.EndCycle(cfg, syncErr, nil))
+	})
+}
+
+func TestProbeLocalRegistry(t *testing.T) {
+	status := http.StatusUnauthorized
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v2/", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	require.NoError(t, probeLocalRegistry(testContext(), localRegistry{host: host, insecure: true}))
+
+	status = http.StatusServiceUnavailable
+	require.Error(t, probeLocalRegistry(testContext(), localRegistry{host: host, insecure: true}))
+
+	require.Error(t, probeLocalRegistry(testContext(), localRegistry{host: "", insecure: true}))
+}
+
+func TestExecuteJudgesCyclesThatCannotReachHarbor(t *testing.T) {
+	// Harbor refuses every state fetch; the local registry answers /v2/.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	dir := t.TempDir()
+	cm, err := config.NewConfigManager(
+		filepath.Join(dir, "config.json"),
+		filepath.Join(dir, "prev.json"),
+		"token", "http://gc", false,
+		&config.Config{
+			StateConfig: config.StateConfig{
+				RegistryCredentials: config.RegistryCredentials{URL: config.URL(srv.URL), Username: "robot", Password: "secret"},
+				StateURL:            host + "/satellite/satellite-state/edge-1/state:latest",
+			},
+			AppConfig: config.AppConfig{
+				UseUnsecure:              true,
+				LocalRegistryCredentials: config.RegistryCredentials{URL: config.URL(srv.URL)},
+			},
+			ZotConfigRaw: json.RawMessage(`{}`),
+		},
+		crypto.NewAESProvider(),
+	)
+	require.NoError(t, err)
+
+	log := zerolog.Nop()
+	f := NewFetchAndReplicateStateProcess(cm, "", &log)
+	f.probation.Start("sha256:new", "sha256:old", 0)
+	f.probation.EndCycle(cm.GetConfigRollbackConfig(), nil, nil)
+
+	require.Error(t, f.Execute(testContext()))
+	require.Equal(t, 1, f.probation.State().Failures)
+
+	f.Skipped(testContext(), scheduler.DependencyHarbor)
+	require.Equal(t, 2, f.probation.State().Failures)
+}
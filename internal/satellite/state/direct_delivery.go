@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	goruntime "runtime"
 	"strings"
 	"sync"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -21,31 +24,76 @@ import (
 // written and their source digests, to avoid redundant writes.
 const digestMapFile = ".satellite-digests.json"
 
-// DirectDeliverer writes Docker-save format tarballs into a directory that
-// k3s/RKE2 watches for automatic import into the containerd image store.
+// deliveryTarget is where a DirectDeliverer puts images: a directory a
+// runtime imports tarballs from, or the image store of the runtime itself.
+type deliveryTarget interface {
+	// Load stores img for entity e under ref.
+	Load(ctx context.Context, e Entity, ref name.Tag, img v1.Image) error
+	// Remove drops what Load stored for entity e. Removing an image that is
+	// not there is not an error.
+	Remove(ctx context.Context, e Entity, ref name.Tag) error
+	// Close releases connections held by the target. Load and Remove may be
+	// called concurrently, and connect again after Close.
+	Close() error
+}
+
+// DirectDeliverer pulls images from the source registry and hands them to a
+// delivery target, so nodes get them without pulling from a registry. The
+// source digest of every delivered image is kept in a digest map, to skip
+// images that are already up to date.
 type DirectDeliverer struct {
 	mu          sync.Mutex
-	imageDir    string
+	target      deliveryTarget
+	digestPath  string
 	useUnsecure bool
 	srcUsername string
 	srcPassword string
 	srcRegistry string
 }
 
-// NewDirectDeliverer creates a deliverer that writes tarballs to imageDir.
-func NewDirectDeliverer(imageDir, srcUsername, srcPassword, srcRegistry string, useUnsecure bool) *DirectDeliverer {
-	return &DirectDeliverer{
-		imageDir:    imageDir,
+// NewDirectDeliverer creates a deliverer for the backend of cfg. The digest
+// map of the image directory backend lives in the image directory, next to
+// the tarballs; the other backends keep theirs in stateDir.
+func NewDirectDeliverer(cfg config.DirectDeliveryConfig, stateDir, srcUsername, srcPassword, srcRegistry string, useUnsecure bool) (*DirectDeliverer, error) {
+	d := &DirectDeliverer{
 		useUnsecure: useUnsecure,
 		srcUsername: srcUsername,
 		srcPassword: srcPassword,
 		srcRegistry: srcRegistry,
 	}
+
+	backend := cfg.BackendOrDefault()
+	switch backend {
+	case config.DeliveryBackendImageDir:
+		if cfg.ImageDir == "" {
+			return nil, fmt.Errorf("direct delivery to an image directory needs image_dir")
+		}
+		d.target = imageDirTarget{dir: cfg.ImageDir}
+		d.digestPath = filepath.Join(cfg.ImageDir, digestMapFile)
+		return d, nil
+	case config.DeliveryBackendContainerd:
+		d.target = newContainerdTarget(cfg.Socket, cfg.Namespace, cfg.Snapshotter)
+	case config.DeliveryBackendDocker:
+		d.target = newDockerTarget(cfg.Socket)
+	case config.DeliveryBackendContainersStorage:
+		target, err := newContainersStorageTarget(cfg.StorageRoot)
+		if err != nil {
+			return nil, err
+		}
+		d.target = target
+	default:
+		return nil, fmt.Errorf("unknown direct delivery backend %q", cfg.Backend)
+	}
+	if stateDir == "" {
+		return nil, fmt.Errorf("direct delivery to %s needs a state directory", backend)
+	}
+	d.digestPath = filepath.Join(stateDir, "direct-delivery-"+backend+".json")
+	return d, nil
 }
 
-// Deliver writes a Docker-save tarball for each entity into the image directory.
-// Existing tarballs with matching digests are skipped. Errors for individual
-// entities are logged and skipped so that one failure does not block the rest.
+// Deliver hands each entity to the delivery target. Entities delivered with
+// a matching digest before are skipped. Errors for individual entities are
+// logged and skipped so that one failure does not block the rest.
 func (d *DirectDeliverer) Deliver(ctx context.Context, entities []Entity) error {
 	if len(entities) == 0 {
 		return nil
@@ -63,11 +111,6 @@ func (d *DirectDeliverer) Deliver(ctx context.Context, entities []Entity) error
 		Password: d.srcPassword,
 	})
 
-	var nameOpts []name.Option
-	if d.useUnsecure {
-		nameOpts = append(nameOpts, name.Insecure)
-	}
-
 	// Collect successful writes to merge atomically at the end.
 	updates := make(map[string]string)
 
@@ -80,34 +123,37 @@ func (d *DirectDeliverer) Deliver(ctx context.Context, entities []Entity) error
 
 		filename := tarballFilename(entity)
 
-		// Skip if digest matches what we already wrote.
+		// Skip if digest matches what we already delivered.
 		if prev, ok := currentDigests[filename]; ok && prev == entity.Digest {
-			log.Debug().Str("file", filename).Msg("Direct delivery: tarball up-to-date, skipping")
+			log.Debug().Str("image", filename).Msg("Direct delivery: image up-to-date, skipping")
+			metrics.DirectDelivery.WithLabelValues("write", metrics.OutcomeSkipped).Inc()
 			continue
 		}
 
-		srcRef := fmt.Sprintf("%s/%s/%s:%s", d.srcRegistry, entity.Repository, entity.Name, entity.Tag)
-		ref, err := name.ParseReference(srcRef, nameOpts...)
+		ref, err := d.sourceRef(entity)
 		if err != nil {
-			log.Warn().Err(err).Str("ref", srcRef).Msg("Direct delivery: failed to parse reference, skipping")
+			log.Warn().Err(err).Str("image", filename).Msg("Direct delivery: failed to parse reference, skipping")
+			metrics.DirectDelivery.WithLabelValues("write", metrics.OutcomeFailure).Inc()
 			continue
 		}
 
-		opts := []remote.Option{remote.WithAuth(auth), remote.WithContext(ctx)}
+		opts := []remote.Option{remote.WithAuth(auth), remote.WithContext(ctx), remote.WithPlatform(hostPlatform())}
 		img, err := remote.Image(ref, opts...)
 		if err != nil {
-			log.Warn().Err(err).Str("ref", srcRef).Msg("Direct delivery: failed to pull image, skipping")
+			log.Warn().Err(err).Str("ref", ref.String()).Msg("Direct delivery: failed to pull image, skipping")
+			metrics.DirectDelivery.WithLabelValues("write", metrics.OutcomeFailure).Inc()
 			continue
 		}
 
-		dstPath := filepath.Join(d.imageDir, filename)
-		if err := d.writeAtomically(dstPath, ref, img); err != nil {
-			log.Warn().Err(err).Str("file", filename).Msg("Direct delivery: failed to write tarball, skipping")
+		if err := d.target.Load(ctx, entity, ref, img); err != nil {
+			log.Warn().Err(err).Str("ref", ref.String()).Msg("Direct delivery: failed to deliver image, skipping")
+			metrics.DirectDelivery.WithLabelValues("write", metrics.OutcomeFailure).Inc()
 			continue
 		}
 
 		updates[filename] = entity.Digest
-		log.Info().Str("file", filename).Str("ref", srcRef).Msg("Direct delivery: tarball written")
+		metrics.DirectDelivery.WithLabelValues("write", metrics.OutcomeSuccess).Inc()
+		log.Info().Str("ref", ref.String()).Msg("Direct delivery: image delivered")
 	}
 
 	if len(updates) == 0 {
@@ -125,7 +171,24 @@ func (d *DirectDeliverer) Deliver(ctx context.Context, entities []Entity) error
 	return d.saveDigestMap(digests)
 }
 
-// Delete removes tarballs for entities no longer in the desired state.
+// Pending returns the entities Deliver would write, skipping those whose
+// tarball is already up to date. It only reads the digest map.
+func (d *DirectDeliverer) Pending(entities []Entity) []Entity {
+	d.mu.Lock()
+	current := d.loadDigestMap()
+	d.mu.Unlock()
+
+	var pending []Entity
+	for _, e := range entities {
+		if prev, ok := current[tarballFilename(e)]; ok && prev == e.Digest {
+			continue
+		}
+		pending = append(pending, e)
+	}
+	return pending
+}
+
+// Delete removes the images of entities no longer in the desired state.
 func (d *DirectDeliverer) Delete(ctx context.Context, entities []Entity) error {
 	if len(entities) == 0 {
 		return nil
@@ -144,15 +207,19 @@ func (d *DirectDeliverer) Delete(ctx context.Context, entities []Entity) error {
 		}
 
 		filename := tarballFilename(entity)
-		path := filepath.Join(d.imageDir, filename)
-
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-			log.Warn().Err(err).Str("file", filename).Msg("Direct delivery: failed to remove tarball")
+		ref, err := d.sourceRef(entity)
+		if err == nil {
+			err = d.target.Remove(ctx, entity, ref)
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("image", filename).Msg("Direct delivery: failed to remove image")
+			metrics.DirectDelivery.WithLabelValues("delete", metrics.OutcomeFailure).Inc()
 			continue
 		}
 
 		removed = append(removed, filename)
-		log.Info().Str("file", filename).Msg("Direct delivery: tarball removed")
+		metrics.DirectDelivery.WithLabelValues("delete", metrics.OutcomeSuccess).Inc()
+		log.Info().Str("image", filename).Msg("Direct delivery: image removed")
 	}
 
 	if len(removed) == 0 {
@@ -170,10 +237,54 @@ func (d *DirectDeliverer) Delete(ctx context.Context, entities []Entity) error {
 	return d.saveDigestMap(digests)
 }
 
+// sourceRef is the reference of an entity in the source registry. Images are
+// delivered under it, as that is the name pods refer to them by.
+func (d *DirectDeliverer) sourceRef(e Entity) (name.Tag, error) {
+	var opts []name.Option
+	if d.useUnsecure {
+		opts = append(opts, name.Insecure)
+	}
+	return name.NewTag(fmt.Sprintf("%s/%s/%s:%s", d.srcRegistry, e.Repository, e.Name, e.Tag), opts...)
+}
+
+// Close releases the connections of the delivery target. Deliver and Delete
+// run concurrently for every group of a replication cycle and share the
+// target, so it is closed once, after the cycle.
+func (d *DirectDeliverer) Close(ctx context.Context) {
+	if err := d.target.Close(); err != nil {
+		logger.FromContext(ctx).Debug().Err(err).Msg("Direct delivery: failed to close target")
+	}
+}
+
+// hostPlatform is the platform images are delivered for, picked from image
+// indexes.
+func hostPlatform() v1.Platform {
+	return v1.Platform{OS: goruntime.GOOS, Architecture: goruntime.GOARCH}
+}
+
+// imageDirTarget writes Docker-save format tarballs into a directory that
+// k3s/RKE2 watches for automatic import into the containerd image store.
+type imageDirTarget struct {
+	dir string
+}
+
+func (t imageDirTarget) Load(_ context.Context, e Entity, ref name.Tag, img v1.Image) error {
+	return t.writeAtomically(filepath.Join(t.dir, tarballFilename(e)), ref, img)
+}
+
+func (t imageDirTarget) Remove(_ context.Context, e Entity, _ name.Tag) error {
+	if err := os.Remove(filepath.Join(t.dir, tarballFilename(e))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (imageDirTarget) Close() error { return nil }
+
 // writeAtomically writes the tarball to a temp file then renames it,
 // preventing k3s from importing a partial file.
-func (d *DirectDeliverer) writeAtomically(dstPath string, ref name.Reference, img v1.Image) error {
-	tmp, err := os.CreateTemp(d.imageDir, ".satellite-*.tar.tmp")
+func (t imageDirTarget) writeAtomically(dstPath string, ref name.Reference, img v1.Image) error {
+	tmp, err := os.CreateTemp(t.dir, ".satellite-*.tar.tmp")
 	if err != nil {
 		return fmt.Errorf("create temp file: %w", err)
 	}
@@ -198,7 +309,8 @@ func (d *DirectDeliverer) writeAtomically(dstPath string, ref name.Reference, im
 	return nil
 }
 
-// tarballFilename produces a filesystem-safe filename for an entity.
+// tarballFilename produces a filesystem-safe filename for an entity. It also
+// keys the entity in the digest map of every backend.
 // Format: {repository}--{name}--{tag}.tar with path separators replaced by _.
 // The -- delimiter is unambiguous because _ is used within fields for /.
 func tarballFilename(e Entity) string {
@@ -206,12 +318,8 @@ func tarballFilename(e Entity) string {
 	return fmt.Sprintf("%s--%s--%s.tar", safe.Replace(e.Repository), safe.Replace(e.Name), safe.Replace(e.Tag))
 }
 
-func (d *DirectDeliverer) digestMapPath() string {
-	return filepath.Join(d.imageDir, digestMapFile)
-}
-
 func (d *DirectDeliverer) loadDigestMap() map[string]string {
-	data, err := os.ReadFile(d.digestMapPath())
+	data, err := os.ReadFile(d.digestPath)
 	if err != nil {
 		return make(map[string]string)
 	}
@@ -227,5 +335,5 @@ func (d *DirectDeliverer) saveDigestMap(m map[string]string) error {
 	if err != nil {
 		return fmt.Errorf("marshal digest map: %w", err)
 	}
-	return os.WriteFile(d.digestMapPath(), data, 0o600)
+	return os.WriteFile(d.digestPath, data, 0o600)
 }
@@ -0,0 +1,206 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	containerd "github.com/containerd/containerd/v2/client"
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/pkg/namespaces"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/platforms"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// criImageLabel marks images the CRI plugin of containerd manages, as it
+// does for the images it pulls itself.
+const criImageLabel = "io.cri-containerd.image"
+
+// containerdTarget writes images into the content store of containerd and
+// registers them with its image service, then unpacks them so containers
+// can start from them right away. The original manifest is stored as is,
+// so the image keeps its digest.
+type containerdTarget struct {
+	socket      string
+	namespace   string
+	snapshotter string
+
+	// mu guards client, shared by the deliveries of all groups.
+	mu     sync.Mutex
+	client *containerd.Client
+}
+
+func newContainerdTarget(socket, namespace, snapshotter string) *containerdTarget {
+	if socket == "" {
+		socket = config.DefaultContainerdSocket
+	}
+	if namespace == "" {
+		namespace = config.DefaultContainerdNamespace
+	}
+	if snapshotter == "" {
+		snapshotter = config.DefaultContainerdSnapshotter
+	}
+	return &containerdTarget{socket: socket, namespace: namespace, snapshotter: snapshotter}
+}
+
+func (t *containerdTarget) connect() (*containerd.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	client, err := containerd.New(t.socket, containerd.WithDefaultNamespace(t.namespace))
+	if err != nil {
+		return nil, fmt.Errorf("connect to containerd at %s: %w", t.socket, err)
+	}
+	t.client = client
+	return client, nil
+}
+
+func (t *containerdTarget) Load(ctx context.Context, _ Entity, ref name.Tag, img v1.Image) error {
+	client, err := t.connect()
+	if err != nil {
+		return err
+	}
+	ctx = namespaces.WithNamespace(ctx, t.namespace)
+	// The lease keeps the blobs from being garbage collected before the image
+	// record references them.
+	ctx, done, err := client.WithLease(ctx)
+	if err != nil {
+		return fmt.Errorf("create lease: %w", err)
+	}
+	defer func() { _ = done(ctx) }()
+
+	target, err := writeImageContent(ctx, client.ContentStore(), img)
+	if err != nil {
+		return err
+	}
+
+	record := images.Image{
+		Name:   ref.Name(),
+		Target: target,
+		Labels: map[string]string{criImageLabel: "managed"},
+	}
+	is := client.ImageService()
+	if _, err := is.Update(ctx, record, "target", "labels."+criImageLabel); err != nil {
+		if !errdefs.IsNotFound(err) {
+			return fmt.Errorf("update image %s: %w", record.Name, err)
+		}
+		if _, err := is.Create(ctx, record); err != nil {
+			return fmt.Errorf("create image %s: %w", record.Name, err)
+		}
+	}
+
+	if err := containerd.NewImageWithPlatform(client, record, platforms.All).Unpack(ctx, t.snapshotter); err != nil {
+		return fmt.Errorf("unpack image %s: %w", record.Name, err)
+	}
+	return nil
+}
+
+func (t *containerdTarget) Remove(ctx context.Context, _ Entity, ref name.Tag) error {
+	client, err := t.connect()
+	if err != nil {
+		return err
+	}
+	ctx = namespaces.WithNamespace(ctx, t.namespace)
+	// Content no other image refers to is left to the garbage collector of
+	// containerd.
+	if err := client.ImageService().Delete(ctx, ref.Name()); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("delete image %s: %w", ref.Name(), err)
+	}
+	return nil
+}
+
+func (t *containerdTarget) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
+
+// writeImageContent writes the config, layers and manifest of img into the
+// content store and returns the descriptor of the manifest. The manifest
+// carries the garbage collection labels that keep its config and layers.
+func writeImageContent(ctx context.Context, cs content.Store, img v1.Image) (ocispec.Descriptor, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("read manifest: %w", err)
+	}
+	rawManifest, err := img.RawManifest()
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("read manifest: %w", err)
+	}
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("read manifest media type: %w", err)
+	}
+	manifestDigest, err := img.Digest()
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("compute manifest digest: %w", err)
+	}
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("read config: %w", err)
+	}
+
+	openConfig := func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(rawConfig)), nil }
+	if err := writeBlob(ctx, cs, manifest.Config, openConfig); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("write config: %w", err)
+	}
+	labels := map[string]string{"containerd.io/gc.ref.content.config": manifest.Config.Digest.String()}
+
+	for i, desc := range manifest.Layers {
+		layer, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("get layer %s: %w", desc.Digest, err)
+		}
+		if err := writeBlob(ctx, cs, desc, layer.Compressed); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("write layer %s: %w", desc.Digest, err)
+		}
+		labels[fmt.Sprintf("containerd.io/gc.ref.content.l.%d", i)] = desc.Digest.String()
+	}
+
+	target := ocispec.Descriptor{
+		MediaType: string(mediaType),
+		Digest:    digest.Digest(manifestDigest.String()),
+		Size:      int64(len(rawManifest)),
+	}
+	if err := content.WriteBlob(ctx, cs, target.Digest.String(), bytes.NewReader(rawManifest), target, content.WithLabels(labels)); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("write manifest: %w", err)
+	}
+	return target, nil
+}
+
+// writeBlob writes a blob unless the content store has it already, in which
+// case it is not downloaded at all.
+func writeBlob(ctx context.Context, cs content.Store, desc v1.Descriptor, open func() (io.ReadCloser, error)) error {
+	dgst := digest.Digest(desc.Digest.String())
+	if _, err := cs.Info(ctx, dgst); err == nil {
+		return nil
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+	return content.WriteBlob(ctx, cs, desc.Digest.String(), rc, ocispec.Descriptor{
+		MediaType: string(desc.MediaType),
+		Digest:    dgst,
+		Size:      desc.Size,
+	})
+}
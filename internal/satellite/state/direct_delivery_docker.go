@@ -0,0 +1,131 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// dockerTarget loads images into the Docker daemon through the images/load
+// endpoint of the Engine API, the same call `docker load` makes.
+type dockerTarget struct {
+	client *http.Client
+}
+
+func newDockerTarget(socket string) *dockerTarget {
+	if socket == "" {
+		socket = config.DefaultDockerSocket
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &dockerTarget{client: &http.Client{Transport: transport}}
+}
+
+// dockerMessage is a line of the JSON stream the Engine API answers a load
+// with, or the body of an error response.
+type dockerMessage struct {
+	Stream      string `json:"stream"`
+	Error       string `json:"error"`
+	Message     string `json:"message"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+func (m dockerMessage) err() error {
+	switch {
+	case m.ErrorDetail != nil && m.ErrorDetail.Message != "":
+		return errors.New(m.ErrorDetail.Message)
+	case m.Error != "":
+		return errors.New(m.Error)
+	}
+	return nil
+}
+
+func (t *dockerTarget) Load(ctx context.Context, _ Entity, ref name.Tag, img v1.Image) error {
+	pr, pw := io.Pipe()
+	defer func() { _ = pr.Close() }()
+	go func() {
+		pw.CloseWithError(tarball.Write(ref, img, pw))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://docker/images/load?quiet=1", pr)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("load image into docker: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("load image into docker: %s", dockerError(resp))
+	}
+	// The daemon reports failures that happen after it started reading the
+	// archive in the message stream, with a 200 status.
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg dockerMessage
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read docker load response: %w", err)
+		}
+		if err := msg.err(); err != nil {
+			return fmt.Errorf("load image into docker: %w", err)
+		}
+	}
+}
+
+func (t *dockerTarget) Remove(ctx context.Context, _ Entity, ref name.Tag) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, "http://docker/images/"+ref.Name(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remove image from docker: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("remove image from docker: %s", dockerError(resp))
+	}
+}
+
+func (t *dockerTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// dockerError describes a failed Engine API response.
+func dockerError(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var msg dockerMessage
+	if json.Unmarshal(body, &msg) == nil && msg.Message != "" {
+		return fmt.Sprintf("%s: %s", resp.Status, msg.Message)
+	}
+	if text := strings.TrimSpace(string(body)); text != "" {
+		return fmt.Sprintf("%s: %s", resp.Status, text)
+	}
+	return resp.Status
+}
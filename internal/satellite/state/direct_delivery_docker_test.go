@@ -0,0 +1,92 @@
+package state
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+)
+
+// newDockerDaemon serves handler on a unix socket, like the Docker daemon.
+func newDockerDaemon(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: handler}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return socket
+}
+
+func TestDockerTarget(t *testing.T) {
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	ref, err := name.NewTag("harbor.example/library/nginx:1.27")
+	require.NoError(t, err)
+
+	t.Run("load posts a docker-save archive", func(t *testing.T) {
+		var files []string
+		socket := newDockerDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "/images/load", r.URL.Path)
+			require.Equal(t, "application/x-tar", r.Header.Get("Content-Type"))
+			tr := tar.NewReader(r.Body)
+			for {
+				hdr, err := tr.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				files = append(files, hdr.Name)
+			}
+			_, _ = io.WriteString(w, `{"stream":"Loaded image: harbor.example/library/nginx:1.27\n"}`)
+		})
+
+		target := newDockerTarget(socket)
+		defer func() { _ = target.Close() }()
+		require.NoError(t, target.Load(testContext(), Entity{}, ref, img))
+		require.Contains(t, files, "manifest.json")
+	})
+
+	t.Run("load reports errors from the message stream", func(t *testing.T) {
+		socket := newDockerDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+			_, _ = io.WriteString(w, `{"errorDetail":{"message":"no space left on device"},"error":"no space left on device"}`)
+		})
+
+		err := newDockerTarget(socket).Load(testContext(), Entity{}, ref, img)
+		require.ErrorContains(t, err, "no space left on device")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		status := http.StatusOK
+		var path string
+		socket := newDockerDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodDelete, r.Method)
+			path = r.URL.Path
+			w.WriteHeader(status)
+			if status == http.StatusConflict {
+				_, _ = io.WriteString(w, `{"message":"image is being used by running container"}`)
+			}
+		})
+		target := newDockerTarget(socket)
+
+		require.NoError(t, target.Remove(testContext(), Entity{}, ref))
+		require.Equal(t, "/images/harbor.example/library/nginx:1.27", path)
+
+		status = http.StatusNotFound
+		require.NoError(t, target.Remove(testContext(), Entity{}, ref))
+
+		status = http.StatusConflict
+		err := target.Remove(testContext(), Entity{}, ref)
+		require.ErrorContains(t, err, "image is being used by running container")
+	})
+}
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// podmanRunner runs podman with args, feeding it stdin, and returns its
+// combined output.
+type podmanRunner func(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error)
+
+// lookPodman finds the podman binary, replaced in tests.
+var lookPodman = func() (string, error) { return exec.LookPath("podman") }
+
+func runPodman(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "podman", args...)
+	cmd.Stdin = stdin
+	return cmd.CombinedOutput()
+}
+
+// containersStorageTarget loads images into containers/storage, the image
+// store CRI-O and Podman share. It goes through podman, which takes the
+// storage locks CRI-O expects, so images can be loaded while CRI-O runs.
+type containersStorageTarget struct {
+	root string
+	run  podmanRunner
+}
+
+// newContainersStorageTarget fails when podman is not installed, as nothing
+// could be loaded.
+func newContainersStorageTarget(root string) (*containersStorageTarget, error) {
+	if _, err := lookPodman(); err != nil {
+		return nil, fmt.Errorf("direct delivery to containers_storage needs podman: %w", err)
+	}
+	return &containersStorageTarget{root: root, run: runPodman}, nil
+}
+
+func (t *containersStorageTarget) args(args ...string) []string {
+	if t.root == "" {
+		return args
+	}
+	return append([]string{"--root", t.root}, args...)
+}
+
+func (t *containersStorageTarget) Load(ctx context.Context, _ Entity, ref name.Tag, img v1.Image) error {
+	pr, pw := io.Pipe()
+	defer func() { _ = pr.Close() }()
+	go func() {
+		pw.CloseWithError(tarball.Write(ref, img, pw))
+	}()
+
+	if out, err := t.run(ctx, pr, t.args("load", "--quiet")...); err != nil {
+		return fmt.Errorf("podman load: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (t *containersStorageTarget) Remove(ctx context.Context, _ Entity, ref name.Tag) error {
+	out, err := t.run(ctx, nil, t.args("rmi", ref.Name())...)
+	if err != nil && !strings.Contains(string(out), "image not known") {
+		return fmt.Errorf("podman rmi: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (*containersStorageTarget) Close() error { return nil }
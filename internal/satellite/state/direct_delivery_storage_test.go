@@ -0,0 +1,56 @@
+package state
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainersStorageTarget(t *testing.T) {
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	ref, err := name.NewTag("harbor.example/library/nginx:1.27")
+	require.NoError(t, err)
+
+	var calls [][]string
+	var archive []string
+	output := ""
+	failure := error(nil)
+	target := &containersStorageTarget{
+		root: "/var/lib/containers/storage",
+		run: func(_ context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+			calls = append(calls, args)
+			if stdin != nil {
+				tr := tar.NewReader(stdin)
+				for {
+					hdr, err := tr.Next()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					require.NoError(t, err)
+					archive = append(archive, hdr.Name)
+				}
+			}
+			return []byte(output), failure
+		},
+	}
+
+	require.NoError(t, target.Load(testContext(), Entity{}, ref, img))
+	require.Equal(t, []string{"--root", "/var/lib/containers/storage", "load", "--quiet"}, calls[0])
+	require.Contains(t, archive, "manifest.json")
+
+	require.NoError(t, target.Remove(testContext(), Entity{}, ref))
+	require.Equal(t, []string{"--root", "/var/lib/containers/storage", "rmi", "harbor.example/library/nginx:1.27"}, calls[1])
+
+	output, failure = "Error: harbor.example/library/nginx:1.27: image not known", errors.New("exit status 1")
+	require.NoError(t, target.Remove(testContext(), Entity{}, ref))
+
+	output = "Error: image used by 3f2a: image is in use by a container"
+	require.ErrorContains(t, target.Remove(testContext(), Entity{}, ref), "image is in use")
+}
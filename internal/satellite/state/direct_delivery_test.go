@@ -1,12 +1,75 @@
 package state
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/require"
 )
 
+func newImageDirDeliverer(dir string) *DirectDeliverer {
+	return &DirectDeliverer{target: imageDirTarget{dir: dir}, digestPath: filepath.Join(dir, digestMapFile)}
+}
+
+// recordingTarget records what a DirectDeliverer hands it, and whether it
+// was closed while a load was still running.
+type recordingTarget struct {
+	mu             sync.Mutex
+	loaded         map[string]string
+	removed        []string
+	closed         int
+	loading        int
+	closedInFlight bool
+}
+
+func (r *recordingTarget) Load(_ context.Context, _ Entity, ref name.Tag, img v1.Image) error {
+	r.mu.Lock()
+	r.loading++
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.loading--
+		r.mu.Unlock()
+	}()
+
+	d, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	time.Sleep(10 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loaded[ref.Name()] = d.String()
+	return nil
+}
+
+func (r *recordingTarget) Remove(_ context.Context, _ Entity, ref name.Tag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = append(r.removed, ref.Name())
+	return nil
+}
+
+func (r *recordingTarget) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed++
+	if r.loading > 0 {
+		r.closedInFlight = true
+	}
+	return nil
+}
+
 func TestTarballFilename(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -47,7 +110,7 @@ func TestTarballFilename(t *testing.T) {
 
 func TestDigestMapPersistence(t *testing.T) {
 	dir := t.TempDir()
-	d := &DirectDeliverer{imageDir: dir}
+	d := newImageDirDeliverer(dir)
 
 	// Initially empty
 	m := d.loadDigestMap()
@@ -82,7 +145,7 @@ func TestDigestMapPersistence(t *testing.T) {
 
 func TestDeleteRemovesFileAndDigest(t *testing.T) {
 	dir := t.TempDir()
-	d := &DirectDeliverer{imageDir: dir}
+	d := newImageDirDeliverer(dir)
 
 	// Create a fake tarball file and digest entry
 	filename := tarballFilename(Entity{Repository: "lib", Name: "app", Tag: "v1"})
@@ -117,7 +180,7 @@ func TestDeleteRemovesFileAndDigest(t *testing.T) {
 
 func TestDeleteNonexistentFileNoError(t *testing.T) {
 	dir := t.TempDir()
-	d := &DirectDeliverer{imageDir: dir}
+	d := newImageDirDeliverer(dir)
 
 	ctx := testContext()
 	err := d.Delete(ctx, []Entity{{Repository: "lib", Name: "gone", Tag: "v1"}})
@@ -128,7 +191,7 @@ func TestDeleteNonexistentFileNoError(t *testing.T) {
 
 func TestDeliverEmptyEntitiesIsNoop(t *testing.T) {
 	dir := t.TempDir()
-	d := &DirectDeliverer{imageDir: dir}
+	d := newImageDirDeliverer(dir)
 
 	ctx := testContext()
 	err := d.Deliver(ctx, nil)
@@ -140,3 +203,144 @@ func TestDeliverEmptyEntitiesIsNoop(t *testing.T) {
 		t.Fatalf("Deliver([]): %v", err)
 	}
 }
+
+func TestNewDirectDeliverer(t *testing.T) {
+	stateDir := t.TempDir()
+	imageDir := t.TempDir()
+	origLookPodman := lookPodman
+	t.Cleanup(func() { lookPodman = origLookPodman })
+	lookPodman = func() (string, error) { return "/usr/bin/podman", nil }
+
+	tests := []struct {
+		cfg        config.DirectDeliveryConfig
+		target     deliveryTarget
+		digestPath string
+	}{
+		{config.DirectDeliveryConfig{ImageDir: imageDir}, imageDirTarget{}, filepath.Join(imageDir, digestMapFile)},
+		{config.DirectDeliveryConfig{Backend: "containerd"}, &containerdTarget{}, filepath.Join(stateDir, "direct-delivery-containerd.json")},
+		{config.DirectDeliveryConfig{Backend: "docker"}, &dockerTarget{}, filepath.Join(stateDir, "direct-delivery-docker.json")},
+		{config.DirectDeliveryConfig{Backend: "containers_storage"}, &containersStorageTarget{}, filepath.Join(stateDir, "direct-delivery-containers_storage.json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cfg.BackendOrDefault(), func(t *testing.T) {
+			d, err := NewDirectDeliverer(tt.cfg, stateDir, "robot", "secret", "harbor.example", false)
+			require.NoError(t, err)
+			require.IsType(t, tt.target, d.target)
+			require.Equal(t, tt.digestPath, d.digestPath)
+		})
+	}
+
+	t.Run("containerd defaults", func(t *testing.T) {
+		d, err := NewDirectDeliverer(config.DirectDeliveryConfig{Backend: "containerd"}, stateDir, "", "", "harbor.example", false)
+		require.NoError(t, err)
+		target := d.target.(*containerdTarget)
+		require.Equal(t, "/run/containerd/containerd.sock", target.socket)
+		require.Equal(t, "k8s.io", target.namespace)
+		require.Equal(t, "overlayfs", target.snapshotter)
+	})
+
+	t.Run("image dir backend needs a directory", func(t *testing.T) {
+		_, err := NewDirectDeliverer(config.DirectDeliveryConfig{}, stateDir, "", "", "harbor.example", false)
+		require.Error(t, err)
+	})
+
+	t.Run("runtime backends need a state directory", func(t *testing.T) {
+		_, err := NewDirectDeliverer(config.DirectDeliveryConfig{Backend: "docker"}, "", "", "", "harbor.example", false)
+		require.Error(t, err)
+	})
+
+	t.Run("containers_storage needs podman", func(t *testing.T) {
+		lookPodman = func() (string, error) { return "", &exec.Error{Name: "podman", Err: exec.ErrNotFound} }
+		_, err := NewDirectDeliverer(config.DirectDeliveryConfig{Backend: "containers_storage"}, stateDir, "", "", "harbor.example", false)
+		require.ErrorIs(t, err, exec.ErrNotFound)
+		require.ErrorContains(t, err, "needs podman")
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		_, err := NewDirectDeliverer(config.DirectDeliveryConfig{Backend: "nfs"}, stateDir, "", "", "harbor.example", false)
+		require.Error(t, err)
+	})
+}
+
+func TestDeliverToTarget(t *testing.T) {
+	addr := newTestRegistry(t)
+	img := pushImage(t, addr, "nginx", "1.27", 2)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	target := &recordingTarget{loaded: map[string]string{}}
+	d := &DirectDeliverer{
+		target:      target,
+		digestPath:  filepath.Join(t.TempDir(), "digests.json"),
+		srcRegistry: addr,
+		useUnsecure: true,
+	}
+	entity := Entity{Repository: "library", Name: "nginx", Tag: "1.27", Digest: digest.String()}
+
+	require.NoError(t, d.Deliver(testContext(), []Entity{entity}))
+	require.Equal(t, map[string]string{addr + "/library/nginx:1.27": digest.String()}, target.loaded)
+	require.Zero(t, target.closed, "the target stays open for the other groups of the cycle")
+	require.Empty(t, d.Pending([]Entity{entity}))
+
+	// Delivered images with the same digest are not loaded again.
+	target.loaded = map[string]string{}
+	require.NoError(t, d.Deliver(testContext(), []Entity{entity}))
+	require.Empty(t, target.loaded)
+
+	require.NoError(t, d.Delete(testContext(), []Entity{entity}))
+	require.Equal(t, []string{addr + "/library/nginx:1.27"}, target.removed)
+	require.Equal(t, []Entity{entity}, d.Pending([]Entity{entity}))
+
+	d.Close(testContext())
+	require.Equal(t, 1, target.closed)
+}
+
+// TestDeliverConcurrently delivers the groups of a cycle at the same time,
+// as Execute does. Run it with -race.
+func TestDeliverConcurrently(t *testing.T) {
+	addr := newTestRegistry(t)
+	target := &recordingTarget{loaded: map[string]string{}}
+	d := &DirectDeliverer{
+		target:      target,
+		digestPath:  filepath.Join(t.TempDir(), "digests.json"),
+		srcRegistry: addr,
+		useUnsecure: true,
+	}
+
+	var groups [][]Entity
+	for i := range 4 {
+		var entities []Entity
+		for j := range 3 {
+			imgName := fmt.Sprintf("app-%d-%d", i, j)
+			img := pushImage(t, addr, imgName, "v1", 1)
+			digest, err := img.Digest()
+			require.NoError(t, err)
+			entities = append(entities, Entity{Repository: "library", Name: imgName, Tag: "v1", Digest: digest.String()})
+		}
+		groups = append(groups, entities)
+	}
+
+	errs := make(chan error, 2*len(groups))
+	var wg sync.WaitGroup
+	for _, entities := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- d.Deliver(testContext(), entities)
+			errs <- d.Delete(testContext(), entities[:1])
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	d.Close(testContext())
+
+	require.Len(t, target.loaded, 12)
+	require.Len(t, target.removed, 4)
+	require.Equal(t, 1, target.closed)
+	require.False(t, target.closedInFlight)
+	// Every delivery and deletion made it into the digest map.
+	require.Len(t, d.loadDigestMap(), 8)
+}
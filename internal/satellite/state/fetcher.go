@@ -9,12 +9,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/rs/zerolog"
 
 	satTLS "github.com/container-registry/harbor-satellite/internal/satellite/tls"
@@ -23,6 +28,9 @@ import (
 type StateFetcher interface {
 	FetchStateArtifact(ctx context.Context, state any, log *zerolog.Logger) error
 	FetchDigest(ctx context.Context, log *zerolog.Logger) (string, error)
+	// PulledDigest returns the digest of the artifact FetchStateArtifact
+	// pulled last, empty before the first pull.
+	PulledDigest() string
 }
 
 type baseStateFetcher struct {
@@ -36,6 +44,7 @@ type URLStateFetcher struct {
 	insecure bool
 	useHTTP  bool
 	tlsCfg   config.TLSConfig
+	pulled   string
 }
 
 func NewURLStateFetcher(stateURL, userName, password string, insecure bool) StateFetcher {
@@ -79,6 +88,9 @@ func (f *URLStateFetcher) FetchStateArtifact(ctx context.Context, state any, log
 	case *config.Config:
 		return f.fetchConfigState(ctx, s, log)
 
+	case *ConfigArtifact:
+		return f.fetchConfigArtifact(ctx, s, log)
+
 	default:
 		return fmt.Errorf("unexpected state type: %T", s)
 	}
@@ -111,22 +123,85 @@ func (f *URLStateFetcher) fetchConfigState(ctx context.Context, config *config.C
 	return f.extractArtifactJSON(f.url, img, config, log)
 }
 
-func (f *URLStateFetcher) FetchDigest(ctx context.Context, log *zerolog.Logger) (string, error) {
+// ConfigArtifact is a config state artifact with the revision Ground Control
+// recorded on it, zero when it carries none.
+type ConfigArtifact struct {
+	Config   config.Config
+	Revision int32
+}
+
+func (f *URLStateFetcher) fetchConfigArtifact(ctx context.Context, artifact *ConfigArtifact, log *zerolog.Logger) error {
+	log.Info().Msgf("Fetching config state artifact: %s", f.url)
+	img, err := f.pullImage(ctx, log)
+	if err != nil {
+		return err
+	}
+	if err := f.extractArtifactJSON(f.url, img, &artifact.Config, log); err != nil {
+		return err
+	}
+	artifact.Revision, err = configRevision(img)
+	return err
+}
+
+// configRevision reads the config revision annotation of a config state
+// artifact.
+func configRevision(img v1.Image) (int32, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return 0, fmt.Errorf("read config artifact manifest: %w", err)
+	}
+	v, ok := manifest.Annotations[config.ConfigRevisionAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid config revision annotation %q", v)
+	}
+	return int32(n), nil
+}
+
+func (f *URLStateFetcher) FetchDigest(ctx context.Context, log *zerolog.Logger) (digest string, err error) {
 	log.Debug().Msgf("Fetching digest for state artifact: %s", f.url)
+	defer observeStateFetch("digest", time.Now(), &err)
 	options, err := f.buildCraneOptions(ctx)
 	if err != nil {
 		return "", fmt.Errorf("build crane options: %w", err)
 	}
-	return crane.Digest(f.url, options...)
+	digest, err = crane.Digest(f.url, options...)
+	return digest, scheduler.DependencyFailure(scheduler.DependencyHarbor, err)
 }
 
-func (f *URLStateFetcher) pullImage(ctx context.Context, log *zerolog.Logger) (v1.Image, error) {
+func (f *URLStateFetcher) pullImage(ctx context.Context, log *zerolog.Logger) (img v1.Image, err error) {
 	log.Debug().Msgf("Pulling state artifact: %s", f.url)
+	defer observeStateFetch("artifact", time.Now(), &err)
 	options, err := f.buildCraneOptions(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("build crane options: %w", err)
 	}
-	return crane.Pull(f.url, options...)
+	img, err = crane.Pull(f.url, options...)
+	if err != nil {
+		return nil, scheduler.DependencyFailure(scheduler.DependencyHarbor, err)
+	}
+	// The manifest is already pulled, so this sends no request.
+	if digest, err := img.Digest(); err == nil {
+		f.pulled = digest.String()
+	}
+	return img, nil
+}
+
+func (f *URLStateFetcher) PulledDigest() string {
+	return f.pulled
+}
+
+// observeStateFetch records the latency and outcome of a state fetch.
+func observeStateFetch(kind string, started time.Time, err *error) {
+	metrics.StateFetchDuration.WithLabelValues(kind).Observe(time.Since(started).Seconds())
+	outcome := metrics.OutcomeSuccess
+	if *err != nil {
+		outcome = metrics.OutcomeFailure
+	}
+	metrics.StateFetches.WithLabelValues(kind, outcome).Inc()
 }
 
 func (f *URLStateFetcher) buildCraneOptions(ctx context.Context) ([]crane.Option, error) {
@@ -135,26 +210,34 @@ func (f *URLStateFetcher) buildCraneOptions(ctx context.Context) ([]crane.Option
 		Password: f.password,
 	})
 
-	var options []crane.Option
-	if f.useHTTP {
+	options := []crane.Option{crane.WithAuth(auth), crane.WithContext(ctx)}
+
+	var base http.RoundTripper
+	switch {
+	case f.useHTTP:
 		// Force HTTP scheme by wrapping the default transport
-		transport := &httpTransport{base: http.DefaultTransport}
-		options = []crane.Option{crane.Insecure, crane.WithAuth(auth), crane.WithContext(ctx), crane.WithTransport(transport)}
-		return options, nil
+		base = &httpTransport{base: http.DefaultTransport}
+		options = append(options, crane.Insecure)
+	case f.insecure:
+		// crane only skips certificate verification when it builds the
+		// transport itself, so mirror that here.
+		transport := remote.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+		base = transport
+		options = append(options, crane.Insecure)
+	default:
+		transport, err := f.buildTLSTransport()
+		if err != nil {
+			return nil, err
+		}
+		base = transport
 	}
-	if f.insecure {
-		options = []crane.Option{crane.Insecure, crane.WithAuth(auth), crane.WithContext(ctx)}
-		return options, nil
+	if base == nil {
+		base = remote.DefaultTransport
 	}
-	options = []crane.Option{crane.WithAuth(auth), crane.WithContext(ctx)}
 
-	transport, err := f.buildTLSTransport()
-	if err != nil {
-		return nil, err
-	}
-	if transport != nil {
-		options = append(options, crane.WithTransport(transport))
-	}
+	// Surface 429 + Retry-After so the scheduler waits as long as asked.
+	options = append(options, crane.WithTransport(&scheduler.RetryAfterTransport{Base: base}))
 
 	return options, nil
 }
@@ -0,0 +1,65 @@
+package state
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRevision(t *testing.T) {
+	img, err := crane.Image(map[string][]byte{"artifacts.json": []byte(`{}`)})
+	require.NoError(t, err)
+
+	rev, err := configRevision(img)
+	require.NoError(t, err)
+	require.Zero(t, rev, "artifacts without the annotation have no revision")
+
+	annotated := mutate.Annotations(img, map[string]string{config.ConfigRevisionAnnotation: "12"}).(v1.Image)
+	rev, err = configRevision(annotated)
+	require.NoError(t, err)
+	require.Equal(t, int32(12), rev)
+
+	invalid := mutate.Annotations(img, map[string]string{config.ConfigRevisionAnnotation: "twelve"}).(v1.Image)
+	_, err = configRevision(invalid)
+	require.Error(t, err)
+}
+
+func TestFetchGroupStateRecordsPulledDigest(t *testing.T) {
+	var heads atomic.Int32
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	img, err := crane.Image(map[string][]byte{"artifacts.json": []byte(`{"registry":"harbor","artifacts":[]}`)})
+	require.NoError(t, err)
+	ref := host + "/satellite/group-state/edge/state:latest"
+	require.NoError(t, crane.Push(img, ref, crane.Insecure))
+	want, err := img.Digest()
+	require.NoError(t, err)
+	heads.Store(0)
+
+	log := zerolog.Nop()
+	fetcher := NewURLStateFetcher("http://"+ref, "", "", true)
+	require.Empty(t, fetcher.PulledDigest())
+	state := NewState()
+	require.NoError(t, fetcher.FetchStateArtifact(context.Background(), state, &log))
+	require.Equal(t, want.String(), fetcher.PulledDigest())
+	require.Zero(t, heads.Load(), "the digest comes from the pulled manifest")
+}
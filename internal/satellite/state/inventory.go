@@ -0,0 +1,173 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/inventory"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// inventoryRescanInterval is how often the whole local registry is walked
+// again, to pick up images pushed or removed by anything but the replicator.
+const inventoryRescanInterval = time.Hour
+
+// Inventory tracks the images cached in the local registry. It is walked in
+// full once, then kept current from the replicate and delete events of the
+// state process, so a heartbeat only looks up the tags that changed. It also
+// remembers what Ground Control last accepted, to report only the changes.
+type Inventory struct {
+	mu        sync.Mutex
+	images    map[inventory.Ref]inventory.Image
+	scannedAt time.Time
+	changed   map[inventory.Ref]struct{}
+	// reported is the inventory Ground Control accepted last, nil until the
+	// first report is accepted.
+	reported   map[inventory.Ref]inventory.Image
+	resyncNext bool
+	now        func() time.Time
+}
+
+func NewInventory() *Inventory {
+	return &Inventory{
+		changed: make(map[inventory.Ref]struct{}),
+		now:     time.Now,
+	}
+}
+
+// Changed marks a tag as replicated or deleted, so it is looked up again on
+// the next refresh.
+func (inv *Inventory) Changed(repository, tag string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.changed[inventory.Ref{Repository: repository, Tag: tag}] = struct{}{}
+}
+
+// Refresh brings the inventory up to date with the local registry: a full
+// walk on first use and every inventoryRescanInterval, otherwise a lookup of
+// the changed tags only.
+func (inv *Inventory) Refresh(ctx context.Context, reg localRegistry) error {
+	inv.mu.Lock()
+	full := inv.images == nil || inv.now().Sub(inv.scannedAt) >= inventoryRescanInterval
+	changed := slices.Collect(maps.Keys(inv.changed))
+	inv.mu.Unlock()
+
+	if full {
+		started := inv.now()
+		images, err := collectCachedImages(ctx, reg)
+		if err != nil {
+			return err
+		}
+		inv.mu.Lock()
+		defer inv.mu.Unlock()
+		inv.images = make(map[inventory.Ref]inventory.Image, len(images))
+		for _, img := range images {
+			inv.images[img.Ref] = img
+		}
+		inv.scannedAt = started
+		for _, ref := range changed {
+			delete(inv.changed, ref)
+		}
+		return nil
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+	craneOpts, err := reg.craneOptions()
+	if err != nil {
+		return err
+	}
+	craneOpts = append(craneOpts, crane.WithContext(ctx))
+
+	var errs []error
+	for _, ref := range changed {
+		img, err := collectImageInfo(reg.host, ref, craneOpts...)
+		gone := isNotFound(err)
+		if err != nil && !gone {
+			errs = append(errs, err)
+			continue
+		}
+		inv.mu.Lock()
+		if gone {
+			delete(inv.images, ref)
+		} else {
+			inv.images[ref] = img
+		}
+		delete(inv.changed, ref)
+		inv.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Size returns the size of a cached image, if it is in the inventory.
+func (inv *Inventory) Size(repository, tag string) (int64, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	img, ok := inv.images[inventory.Ref{Repository: repository, Tag: tag}]
+	return img.SizeBytes, ok
+}
+
+// Len returns the number of cached images.
+func (inv *Inventory) Len() int {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return len(inv.images)
+}
+
+// Report returns the inventory section of the next heartbeat: every image
+// the first time or when Ground Control asked for it, the changes since the
+// last accepted report otherwise. It returns nil before the first walk.
+func (inv *Inventory) Report() *inventory.Report {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.images == nil {
+		return nil
+	}
+	images := slices.Collect(maps.Values(inv.images))
+	report := &inventory.Report{SnapshotHash: inventory.Hash(images)}
+	if inv.reported == nil || inv.resyncNext {
+		inventory.Sort(images)
+		report.Full = true
+		report.Upserted = images
+		return report
+	}
+	report.Upserted, report.Removed = inventory.Diff(inv.reported, inv.images)
+	return report
+}
+
+// Acknowledge records that Ground Control accepted report. When it asks for
+// a resync, because its copy no longer matches the snapshot hash, the next
+// report lists every image.
+func (inv *Inventory) Acknowledge(report *inventory.Report, resync bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.resyncNext = resync
+	if report == nil {
+		return
+	}
+	if report.Full || inv.reported == nil {
+		inv.reported = make(map[inventory.Ref]inventory.Image, len(report.Upserted))
+	}
+	for _, img := range report.Upserted {
+		inv.reported[img.Ref] = img
+	}
+	for _, ref := range report.Removed {
+		delete(inv.reported, ref)
+	}
+}
+
+// isNotFound reports whether a registry request failed because the manifest
+// does not exist.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/inventory"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	addr := newTestRegistry(t)
+	pushImage(t, addr, "nginx", "latest", 2)
+	pushImage(t, addr, "alpine", "3", 1)
+	reg := localRegistry{host: addr, insecure: true}
+	ctx := testContext()
+
+	inv := NewInventory()
+	require.Nil(t, inv.Report())
+	require.NoError(t, inv.Refresh(ctx, reg))
+	require.Equal(t, 2, inv.Len())
+
+	first := inv.Report()
+	require.True(t, first.Full)
+	require.Len(t, first.Upserted, 2)
+	require.Equal(t, inventory.Hash(first.Upserted), first.SnapshotHash)
+	inv.Acknowledge(first, false)
+
+	unchanged := inv.Report()
+	require.False(t, unchanged.Full)
+	require.Empty(t, unchanged.Upserted)
+	require.Empty(t, unchanged.Removed)
+	require.Equal(t, first.SnapshotHash, unchanged.SnapshotHash)
+
+	// Replicate and delete events are looked up on the next refresh.
+	pushImage(t, addr, "redis", "7", 1)
+	alpine, err := name.ParseReference(addr+"/library/alpine:3", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Delete(alpine))
+	inv.Changed("library/redis", "7")
+	inv.Changed("library/alpine", "3")
+	require.NoError(t, inv.Refresh(ctx, reg))
+
+	delta := inv.Report()
+	require.False(t, delta.Full)
+	require.Len(t, delta.Upserted, 1)
+	require.Equal(t, inventory.Ref{Repository: "library/redis", Tag: "7"}, delta.Upserted[0].Ref)
+	require.Equal(t, []inventory.Ref{{Repository: "library/alpine", Tag: "3"}}, delta.Removed)
+
+	t.Run("a lost heartbeat is covered by the next delta", func(t *testing.T) {
+		require.Equal(t, delta, inv.Report())
+	})
+
+	t.Run("a resync request sends every image", func(t *testing.T) {
+		inv.Acknowledge(delta, true)
+		full := inv.Report()
+		require.True(t, full.Full)
+		require.Len(t, full.Upserted, 2)
+		inv.Acknowledge(full, false)
+		require.False(t, inv.Report().Full)
+	})
+
+	t.Run("images pushed by others are found on the next rescan", func(t *testing.T) {
+		pushImage(t, addr, "busybox", "1", 1)
+		require.NoError(t, inv.Refresh(ctx, reg))
+		require.Equal(t, 2, inv.Len())
+
+		inv.now = func() time.Time { return time.Now().Add(inventoryRescanInterval) }
+		require.NoError(t, inv.Refresh(ctx, reg))
+		require.Equal(t, 3, inv.Len())
+		require.Equal(t, []inventory.Image{inv.images[inventory.Ref{Repository: "library/busybox", Tag: "1"}]}, inv.Report().Upserted)
+	})
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+)
+
+// localRegistry is how the satellite talks to the registry it serves images
+// from. A secured embedded registry is reached over HTTPS with its CA and
+// the push account, whatever use_unsecure says about the upstream registry.
+type localRegistry struct {
+	host     string
+	insecure bool
+	username string
+	password string
+	caFile   string
+}
+
+func newLocalRegistry(cm *config.ConfigManager) localRegistry {
+	reg := localRegistry{
+		host:     utils.FormatRegistryURL(cm.GetLocalRegistryURL()),
+		insecure: cm.UseUnsecure(),
+	}
+	if access, ok := cm.GetSecureRegistryAccess(); ok {
+		reg.insecure = false
+		reg.username = access.PushUsername
+		reg.password = access.PushPassword
+		reg.caFile = access.CAFile
+	}
+	return reg
+}
+
+// transport returns the transport for requests to the registry, trusting
+// its CA on top of the system roots.
+func (l localRegistry) transport() (http.RoundTripper, error) {
+	if l.caFile == "" {
+		return http.DefaultTransport, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if err := appendCAFile(pool, l.caFile); err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return t, nil
+}
+
+// httpClient returns a client for direct API calls to the registry.
+func (l localRegistry) httpClient(timeout time.Duration) (*http.Client, error) {
+	rt, err := l.transport()
+	if err != nil {
+		return nil, err
+	}
+	if l.username != "" {
+		rt = &basicAuthTransport{base: rt, username: l.username, password: l.password}
+	}
+	return &http.Client{Timeout: timeout, Transport: rt}, nil
+}
+
+// craneOptions returns the crane options to reach the registry.
+func (l localRegistry) craneOptions() ([]crane.Option, error) {
+	var opts []crane.Option
+	if l.insecure {
+		opts = append(opts, crane.Insecure)
+	}
+	if l.caFile != "" {
+		rt, err := l.transport()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, crane.WithTransport(rt))
+	}
+	if l.username != "" {
+		opts = append(opts, crane.WithAuth(&authn.Basic{Username: l.username, Password: l.password}))
+	}
+	return opts, nil
+}
+
+// appendCAFile adds the certificates of caFile to pool.
+func appendCAFile(pool *x509.CertPool, caFile string) error {
+	data, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return fmt.Errorf("read local registry CA: %w", err)
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("local registry CA %s holds no certificates", caFile)
+	}
+	return nil
+}
+
+// basicAuthTransport sends basic auth credentials with every request.
+type basicAuthTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.base.RoundTrip(req)
+}
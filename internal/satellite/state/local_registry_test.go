@@ -0,0 +1,51 @@
+package state
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecuredLocalRegistry(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "satellite" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/v2/_catalog" {
+			writeJSON(t, w, catalogResponse{Repositories: []string{}})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	reg := localRegistry{host: host, username: "satellite", password: "secret", caFile: caFile}
+	require.NoError(t, probeLocalRegistry(testContext(), reg))
+	images, err := collectCachedImages(testContext(), reg)
+	require.NoError(t, err)
+	require.Empty(t, images)
+
+	t.Run("without the CA the certificate is not trusted", func(t *testing.T) {
+		untrusted := reg
+		untrusted.caFile = ""
+		require.Error(t, probeLocalRegistry(testContext(), untrusted))
+	})
+
+	t.Run("without credentials the catalog is refused", func(t *testing.T) {
+		anonymous := reg
+		anonymous.username = ""
+		_, err := collectCachedImages(testContext(), anonymous)
+		require.Error(t, err)
+	})
+}
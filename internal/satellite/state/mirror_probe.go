@@ -0,0 +1,90 @@
+package state
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	goruntime "runtime"
+
+	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// mirrorProbeRepository holds the probes pushed to verify the runtime
+// mirrors. Upstream registries do not serve the random tags pushed to it.
+const mirrorProbeRepository = "harbor-satellite/mirror-probe"
+
+// PushMirrorProbe pushes an image with a single empty layer under a random
+// tag to the local registry, for runtimes to pull through their mirror. The
+// returned function deletes it again.
+func PushMirrorProbe(ctx context.Context, cm *config.ConfigManager) (runtime.MirrorProbe, func(), error) {
+	reg := newLocalRegistry(cm)
+	opts, err := reg.craneOptions()
+	if err != nil {
+		return runtime.MirrorProbe{}, nil, err
+	}
+	opts = append(opts, crane.WithContext(ctx))
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return runtime.MirrorProbe{}, nil, fmt.Errorf("generate mirror probe tag: %w", err)
+	}
+	probe := runtime.MirrorProbe{Repository: mirrorProbeRepository, Tag: "probe-" + hex.EncodeToString(suffix)}
+
+	img, err := mirrorProbeImage(probe.Tag)
+	if err != nil {
+		return runtime.MirrorProbe{}, nil, err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return runtime.MirrorProbe{}, nil, err
+	}
+	ref := fmt.Sprintf("%s/%s:%s", reg.host, probe.Repository, probe.Tag)
+	if err := crane.Push(img, ref, opts...); err != nil {
+		return runtime.MirrorProbe{}, nil, fmt.Errorf("push mirror probe %s: %w", ref, err)
+	}
+
+	remove := func() {
+		// Registries differ in whether they delete by tag, by digest or
+		// both. The digest is unique to the probe, so both are safe.
+		_ = crane.Delete(ref, opts...)
+		_ = crane.Delete(fmt.Sprintf("%s/%s@%s", reg.host, probe.Repository, digest), opts...)
+	}
+	return probe, remove, nil
+}
+
+// mirrorProbeImage returns an image runtimes can pull and unpack on this
+// platform, with a single layer holding an empty tar archive. The tag is
+// kept in a label, so every probe has its own digest.
+func mirrorProbeImage(tag string) (v1.Image, error) {
+	var archive bytes.Buffer
+	if err := tar.NewWriter(&archive).Close(); err != nil {
+		return nil, err
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(archive.Bytes())), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build mirror probe layer: %w", err)
+	}
+
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{
+		OS:           goruntime.GOOS,
+		Architecture: goruntime.GOARCH,
+		Config:       v1.Config{Labels: map[string]string{"dev.harbor-satellite.mirror-probe": tag}},
+		RootFS:       v1.RootFS{Type: "layers"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mutate.AppendLayers(img, layer)
+}
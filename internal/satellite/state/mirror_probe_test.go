@@ -0,0 +1,59 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/container-registry/harbor-satellite/internal/crypto"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushMirrorProbe(t *testing.T) {
+	addr := newTestRegistry(t)
+
+	dir := t.TempDir()
+	cm, err := config.NewConfigManager(
+		filepath.Join(dir, "config.json"),
+		filepath.Join(dir, "prev.json"),
+		"token", "http://gc", false,
+		&config.Config{
+			AppConfig: config.AppConfig{
+				UseUnsecure:              true,
+				LocalRegistryCredentials: config.RegistryCredentials{URL: config.URL("http://" + addr)},
+			},
+			ZotConfigRaw: json.RawMessage(`{}`),
+		},
+		crypto.NewAESProvider(),
+	)
+	require.NoError(t, err)
+
+	probe, remove, err := PushMirrorProbe(context.Background(), cm)
+	require.NoError(t, err)
+	require.Equal(t, mirrorProbeRepository, probe.Repository)
+	require.Regexp(t, `^probe-[0-9a-f]{12}$`, probe.Tag)
+
+	ref := addr + "/" + probe.Repository + ":" + probe.Tag
+	img, err := crane.Pull(ref, crane.Insecure)
+	require.NoError(t, err)
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	require.Equal(t, runtime.GOOS, cfg.OS)
+	require.Equal(t, runtime.GOARCH, cfg.Architecture)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+
+	other, otherRemove, err := PushMirrorProbe(context.Background(), cm)
+	require.NoError(t, err)
+	defer otherRemove()
+	require.NotEqual(t, probe.Tag, other.Tag)
+
+	remove()
+	_, err = crane.Head(ref, crane.Insecure)
+	require.Error(t, err)
+}
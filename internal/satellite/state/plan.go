@@ -0,0 +1,369 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rs/zerolog"
+)
+
+// PlannedImage is a single image the reconcile loop would act on. SizeBytes is
+// the config plus layer size declared by the upstream manifest, or -1 when the
+// manifest could not be resolved.
+type PlannedImage struct {
+	Repository string `json:"repository"`
+	Name       string `json:"name"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty"`
+	SizeBytes  int64  `json:"size_bytes"`
+}
+
+// GroupPlan lists the changes a sync cycle would make for one group. A removed
+// group is dropped from the satellite's state without deleting its images.
+type GroupPlan struct {
+	Name    string         `json:"name"`
+	URL     string         `json:"url"`
+	New     bool           `json:"new,omitempty"`
+	Removed bool           `json:"removed,omitempty"`
+	Pull    []PlannedImage `json:"pull,omitempty"`
+	Delete  []PlannedImage `json:"delete,omitempty"`
+	Deliver []PlannedImage `json:"deliver,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// ConfigPlan compares the applied config digest with the upstream one.
+type ConfigPlan struct {
+	URL           string   `json:"url"`
+	CurrentDigest string   `json:"current_digest,omitempty"`
+	RemoteDigest  string   `json:"remote_digest,omitempty"`
+	Changed       bool     `json:"changed"`
+	Warnings      []string `json:"warnings,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// PlanTotals summarises a plan across all groups. Bytes only count images
+// whose manifest could be resolved.
+type PlanTotals struct {
+	Pull        int   `json:"pull"`
+	PullBytes   int64 `json:"pull_bytes"`
+	Delete      int   `json:"delete"`
+	DeleteBytes int64 `json:"delete_bytes"`
+	Deliver     int   `json:"deliver"`
+}
+
+// Plan is the result of a dry run of the reconcile loop.
+type Plan struct {
+	StateURL string      `json:"state_url"`
+	Config   ConfigPlan  `json:"config"`
+	Groups   []GroupPlan `json:"groups"`
+	Totals   PlanTotals  `json:"totals"`
+}
+
+// Plan fetches the satellite, group and config state artifacts and computes
+// the pulls, deletes and direct deliveries the next sync cycle would perform
+// against the persisted entities. It only reads from the upstream registry and
+// the local state; the local registry, the CRI configs and the persisted state
+// are left untouched.
+func (f *FetchAndReplicateStateProcess) Plan(ctx context.Context, directDelivery config.DirectDeliveryConfig, log *zerolog.Logger) (*Plan, error) {
+	_, sourceURL, srcUsername, srcPassword, _, useUnsecure, satelliteStateURL := f.setupReplication()
+	if satelliteStateURL == "" {
+		return nil, fmt.Errorf("satellite has no state URL; complete zero-touch registration first")
+	}
+
+	satelliteState, err := f.fetchSatelliteRootState(ctx, satelliteStateURL, srcUsername, srcPassword, useUnsecure, log)
+	if err != nil {
+		return nil, fmt.Errorf("fetch satellite state: %w", err)
+	}
+	if override := f.cm.GetHarborRegistryURL(); override != "" {
+		satelliteState, err = applyHarborOverrideToSatelliteState(satelliteState, override)
+		if err != nil {
+			return nil, fmt.Errorf("apply harbor registry URL override: %w", err)
+		}
+	}
+
+	f.mu.Lock()
+	persisted := make(map[string][]Entity, len(f.stateMap))
+	persistedURLs := make([]string, 0, len(f.stateMap))
+	for _, sm := range f.stateMap {
+		persisted[sm.url] = sm.Entities
+		persistedURLs = append(persistedURLs, sm.url)
+	}
+	currentDigest := f.currentConfigDigest
+	f.mu.Unlock()
+
+	sizer := newManifestSizer(ctx, sourceURL, srcUsername, srcPassword, useUnsecure)
+	deliverer := f.newDirectDeliverer(directDelivery, sourceURL, srcUsername, srcPassword, useUnsecure, log)
+	if deliverer != nil {
+		defer deliverer.Close(ctx)
+	}
+
+	plan := &Plan{
+		StateURL: satelliteStateURL,
+		Config:   f.planConfig(ctx, satelliteState.Config, currentDigest, srcUsername, srcPassword, useUnsecure, log),
+	}
+
+	for _, url := range satelliteState.States {
+		oldEntities, known := persisted[url]
+		gp := GroupPlan{Name: GroupNameFromURL(url), URL: url, New: !known}
+
+		fetcher, err := getStateFetcherForInput(url, srcUsername, srcPassword, useUnsecure, log)
+		if err != nil {
+			gp.Error = err.Error()
+			plan.Groups = append(plan.Groups, gp)
+			continue
+		}
+		newState, err := f.FetchAndProcessState(ctx, fetcher, log)
+		if err != nil {
+			gp.Error = err.Error()
+			plan.Groups = append(plan.Groups, gp)
+			continue
+		}
+
+		deleteEntities, replicateEntities, _ := f.GetChanges(*newState, log, oldEntities)
+		gp.Pull = sizer.plan(replicateEntities, false)
+		gp.Delete = sizer.plan(deleteEntities, true)
+		if deliverer != nil {
+			gp.Deliver = sizer.plan(deliverer.Pending(replicateEntities), false)
+		}
+		plan.Groups = append(plan.Groups, gp)
+	}
+
+	for _, url := range persistedURLs {
+		if !contains(satelliteState.States, url) {
+			plan.Groups = append(plan.Groups, GroupPlan{Name: GroupNameFromURL(url), URL: url, Removed: true})
+		}
+	}
+
+	plan.Totals = plan.totals()
+	return plan, nil
+}
+
+// planConfig fetches the upstream config digest and, when it differs from the
+// applied one, validates the new config without writing it anywhere.
+func (f *FetchAndReplicateStateProcess) planConfig(
+	ctx context.Context,
+	configURL, currentDigest, srcUsername, srcPassword string,
+	useUnsecure bool,
+	log *zerolog.Logger,
+) ConfigPlan {
+	if override := f.cm.GetHarborRegistryURL(); override != "" {
+		if replaced, err := config.ReplaceURLHost(configURL, override); err == nil {
+			configURL = replaced
+		}
+	}
+	cp := ConfigPlan{URL: configURL, CurrentDigest: currentDigest}
+
+	fetcher, err := getStateFetcherForInput(configURL, srcUsername, srcPassword, useUnsecure, log)
+	if err != nil {
+		cp.Error = err.Error()
+		return cp
+	}
+	cp.RemoteDigest, err = fetcher.FetchDigest(ctx, log)
+	if err != nil {
+		cp.Error = err.Error()
+		return cp
+	}
+	cp.Changed = cp.RemoteDigest != currentDigest
+	if !cp.Changed {
+		return cp
+	}
+
+	remoteConfig := config.Config{}
+	if err := fetcher.FetchStateArtifact(ctx, &remoteConfig, log); err != nil {
+		cp.Error = err.Error()
+		return cp
+	}
+	remoteConfig.StateConfig = f.cm.GetStateConfig()
+	remoteConfig.AppConfig.HarborRegistryURL = f.cm.GetHarborRegistryURL()
+	_, warnings, err := config.ValidateAndEnforceDefaults(&remoteConfig, f.cm.DefaultGroundControlURL)
+	if err != nil {
+		cp.Error = fmt.Sprintf("remote config is invalid and would be rejected: %v", err)
+		return cp
+	}
+	cp.Warnings = warnings
+	return cp
+}
+
+func (p *Plan) totals() PlanTotals {
+	var t PlanTotals
+	for _, g := range p.Groups {
+		t.Pull += len(g.Pull)
+		t.Delete += len(g.Delete)
+		t.Deliver += len(g.Deliver)
+		for _, img := range g.Pull {
+			if img.SizeBytes > 0 {
+				t.PullBytes += img.SizeBytes
+			}
+		}
+		for _, img := range g.Delete {
+			if img.SizeBytes > 0 {
+				t.DeleteBytes += img.SizeBytes
+			}
+		}
+	}
+	return t
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// WriteText writes a human-readable summary of the plan.
+func (p *Plan) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Config %s\n", p.Config.URL)
+	switch {
+	case p.Config.Error != "":
+		fmt.Fprintf(tw, "  error: %s\n", p.Config.Error)
+	case p.Config.Changed:
+		fmt.Fprintf(tw, "  would apply %s (current %s)\n", p.Config.RemoteDigest, orNone(p.Config.CurrentDigest))
+	default:
+		fmt.Fprintf(tw, "  up to date (%s)\n", p.Config.RemoteDigest)
+	}
+	for _, warning := range p.Config.Warnings {
+		fmt.Fprintf(tw, "  warning: %s\n", warning)
+	}
+
+	for _, g := range p.Groups {
+		label := ""
+		switch {
+		case g.New:
+			label = " (new)"
+		case g.Removed:
+			label = " (removed, images are kept)"
+		}
+		fmt.Fprintf(tw, "\nGroup %s%s\n", g.Name, label)
+		if g.Error != "" {
+			fmt.Fprintf(tw, "  error: %s\n", g.Error)
+			continue
+		}
+		if len(g.Pull)+len(g.Delete)+len(g.Deliver) == 0 && !g.Removed {
+			fmt.Fprintln(tw, "  no changes")
+		}
+		writeImages(tw, "pull", g.Pull)
+		writeImages(tw, "delete", g.Delete)
+		writeImages(tw, "deliver", g.Deliver)
+	}
+
+	t := p.Totals
+	fmt.Fprintf(tw, "\nTotal: %d to pull (%s), %d to delete (%s), %d to deliver\n",
+		t.Pull, formatBytes(t.PullBytes), t.Delete, formatBytes(t.DeleteBytes), t.Deliver)
+	return tw.Flush()
+}
+
+func writeImages(w io.Writer, action string, images []PlannedImage) {
+	for _, img := range images {
+		size := "unknown"
+		if img.SizeBytes >= 0 {
+			size = formatBytes(img.SizeBytes)
+		}
+		fmt.Fprintf(w, "  %s\t%s/%s:%s\t%s\n", action, img.Repository, img.Name, img.Tag, size)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// manifestSizer resolves image sizes from upstream manifests, caching by
+// reference. It resolves the same platform image the replicator copies.
+type manifestSizer struct {
+	registry string
+	nameOpts []name.Option
+	opts     []remote.Option
+	cache    map[string]int64
+}
+
+func newManifestSizer(ctx context.Context, registry, username, password string, useUnsecure bool) *manifestSizer {
+	s := &manifestSizer{
+		registry: registry,
+		opts: []remote.Option{
+			remote.WithAuth(authn.FromConfig(authn.AuthConfig{Username: username, Password: password})),
+			remote.WithContext(ctx),
+		},
+		cache: make(map[string]int64),
+	}
+	if useUnsecure {
+		s.nameOpts = append(s.nameOpts, name.Insecure)
+	}
+	return s
+}
+
+// plan converts entities into planned images. Deleted entities are sized by
+// digest, since their tag may already point at a newer image upstream.
+func (s *manifestSizer) plan(entities []Entity, byDigest bool) []PlannedImage {
+	images := make([]PlannedImage, 0, len(entities))
+	for _, e := range entities {
+		ref := fmt.Sprintf("%s/%s/%s:%s", s.registry, e.Repository, e.Name, e.Tag)
+		if byDigest && e.Digest != "" {
+			ref = fmt.Sprintf("%s/%s/%s@%s", s.registry, e.Repository, e.Name, e.Digest)
+		}
+		images = append(images, PlannedImage{
+			Repository: e.Repository,
+			Name:       e.Name,
+			Tag:        e.Tag,
+			Digest:     e.Digest,
+			SizeBytes:  s.size(ref),
+		})
+	}
+	return images
+}
+
+func (s *manifestSizer) size(ref string) int64 {
+	if size, ok := s.cache[ref]; ok {
+		return size
+	}
+	size, err := s.resolve(ref)
+	if err != nil {
+		size = -1
+	}
+	s.cache[ref] = size
+	return size
+}
+
+func (s *manifestSizer) resolve(ref string) (int64, error) {
+	r, err := name.ParseReference(ref, s.nameOpts...)
+	if err != nil {
+		return 0, err
+	}
+	desc, err := remote.Get(r, s.opts...)
+	if err != nil {
+		return 0, err
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return 0, err
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		return 0, err
+	}
+	return imageSize(raw, remoteManifests(r.Context(), s.opts...))
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-registry/harbor-satellite/internal/crypto"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// pushArtifact pushes a state artifact whose artifacts.json holds v.
+func pushArtifact(t *testing.T, ref string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	img, err := crane.Image(map[string][]byte{"artifacts.json": data})
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, ref, crane.Insecure))
+}
+
+func TestPlan(t *testing.T) {
+	addr := newTestRegistry(t)
+
+	alpine := pushImage(t, addr, "alpine", "latest", 2)
+	nginx := pushImage(t, addr, "nginx", "1.25", 3)
+	alpineDigest, err := alpine.Digest()
+	require.NoError(t, err)
+	nginxDigest, err := nginx.Digest()
+	require.NoError(t, err)
+	nginxManifest, err := nginx.RawManifest()
+	require.NoError(t, err)
+	nginxSize, err := imageSize(nginxManifest, nil)
+	require.NoError(t, err)
+
+	groupURL := "http://" + addr + "/satellite/group-state/edge/state:latest"
+	configURL := "http://" + addr + "/satellite/config-state/default/state:latest"
+	stateURL := "http://" + addr + "/satellite/satellite-state/edge-1/state:latest"
+	removedURL := "http://" + addr + "/satellite/group-state/retired/state:latest"
+
+	pushArtifact(t, addr+"/satellite/group-state/edge/state:latest", State{
+		Registry: addr,
+		Artifacts: []Artifact{
+			{Repository: "library/alpine", Tags: []string{"latest"}, Digest: alpineDigest.String()},
+			{Repository: "library/nginx", Tags: []string{"1.25"}, Digest: nginxDigest.String()},
+		},
+	})
+	pushArtifact(t, addr+"/satellite/config-state/default/state:latest", config.Config{
+		AppConfig: config.AppConfig{StateReplicationInterval: "@every 00h00m10s"},
+	})
+	pushArtifact(t, addr+"/satellite/satellite-state/edge-1/state:latest", SatelliteState{
+		States: []string{groupURL},
+		Config: configURL,
+	})
+
+	dir := t.TempDir()
+	cm, err := config.NewConfigManager(
+		filepath.Join(dir, "config.json"),
+		filepath.Join(dir, "prev.json"),
+		"token", "http://gc", false,
+		&config.Config{
+			StateConfig: config.StateConfig{
+				RegistryCredentials: config.RegistryCredentials{URL: config.URL("http://" + addr), Username: "robot"},
+				StateURL:            stateURL,
+			},
+			AppConfig:    config.AppConfig{UseUnsecure: true},
+			ZotConfigRaw: json.RawMessage(`{}`),
+		},
+		crypto.NewAESProvider(),
+	)
+	require.NoError(t, err)
+
+	process := &FetchAndReplicateStateProcess{
+		cm: cm,
+		stateMap: []StateMap{
+			{url: groupURL, Entities: []Entity{
+				{Repository: "library", Name: "alpine", Tag: "latest", Digest: alpineDigest.String()},
+				{Repository: "library", Name: "busybox", Tag: "1.36", Digest: "sha256:0000000000000000000000000000000000000000000000000000000000000000"},
+			}},
+			{url: removedURL, Entities: []Entity{{Repository: "library", Name: "redis", Tag: "7"}}},
+		},
+	}
+
+	log := zerolog.Nop()
+	imageDir := t.TempDir()
+	plan, err := process.Plan(testContext(), config.DirectDeliveryConfig{Enabled: true, ImageDir: imageDir}, &log)
+	require.NoError(t, err)
+
+	require.True(t, plan.Config.Changed)
+	require.NotEmpty(t, plan.Config.RemoteDigest)
+	require.Empty(t, plan.Config.Error)
+
+	require.Len(t, plan.Groups, 2)
+	edge := plan.Groups[0]
+	require.Equal(t, "edge", edge.Name)
+	require.False(t, edge.New)
+	require.Empty(t, edge.Error)
+
+	require.Len(t, edge.Pull, 1)
+	require.Equal(t, "nginx", edge.Pull[0].Name)
+	require.Equal(t, nginxSize, edge.Pull[0].SizeBytes)
+
+	require.Len(t, edge.Delete, 1)
+	require.Equal(t, "busybox", edge.Delete[0].Name)
+	require.Equal(t, int64(-1), edge.Delete[0].SizeBytes, "deleted image is not upstream")
+
+	require.Len(t, edge.Deliver, 1)
+	require.Equal(t, "nginx", edge.Deliver[0].Name)
+
+	require.Equal(t, "retired", plan.Groups[1].Name)
+	require.True(t, plan.Groups[1].Removed)
+
+	require.Equal(t, PlanTotals{Pull: 1, PullBytes: nginxSize, Delete: 1, Deliver: 1}, plan.Totals)
+
+	// The plan must not change the persisted entities.
+	require.Len(t, process.stateMap, 2)
+	require.Len(t, process.stateMap[0].Entities, 2)
+
+	var out bytes.Buffer
+	require.NoError(t, plan.WriteText(&out))
+	require.Contains(t, out.String(), "pull     library/nginx:1.25")
+	require.Contains(t, out.String(), "Group retired (removed, images are kept)")
+
+	out.Reset()
+	require.NoError(t, plan.WriteJSON(&out))
+	var decoded Plan
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	require.Equal(t, plan.Totals, decoded.Totals)
+}
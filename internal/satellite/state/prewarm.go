@@ -0,0 +1,79 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/container-registry/harbor-satellite/internal/satellite/prewarm"
+	"github.com/rs/zerolog"
+)
+
+// Prewarmer returns the prewarmer fed by this process, which also serves the
+// node agents.
+func (f *FetchAndReplicateStateProcess) Prewarmer() *prewarm.Prewarmer {
+	return f.prewarmer
+}
+
+// prewarm pulls the images of the current state the runtime of the node
+// does not hold yet through the registry mirror. Images are referenced as
+// pods reference them, under the upstream registry.
+func (f *FetchAndReplicateStateProcess) prewarm(ctx context.Context, sourceURL string, log *zerolog.Logger) {
+	cfg := f.cm.GetPrewarmConfig()
+	if !cfg.Enabled {
+		f.prewarmer.Disable()
+		f.closePrewarmPuller()
+		return
+	}
+
+	node := cfg.NodeName
+	if node == "" {
+		node, _ = os.Hostname()
+	}
+	images := f.prewarmImages(sourceURL)
+
+	if f.prewarmPuller != nil && f.prewarmEndpoint != cfg.CRIEndpoint {
+		f.closePrewarmPuller()
+	}
+	if f.prewarmPuller == nil {
+		if p, err := prewarm.NewCRIPuller(cfg.CRIEndpoint); err != nil {
+			log.Warn().Err(err).Msg("Prewarm: local runtime unavailable, serving node agents only")
+		} else {
+			f.prewarmPuller, f.prewarmEndpoint = p, cfg.CRIEndpoint
+		}
+	}
+	f.prewarmer.Warm(ctx, node, f.prewarmPuller, images, cfg.PullTimeoutOrDefault())
+
+	for _, s := range f.prewarmer.Status() {
+		if s.Node == node && s.State == prewarm.StateFailed {
+			log.Warn().Str("image", s.Ref).Str("error", s.Error).Msg("Prewarm: image not warm")
+		}
+	}
+}
+
+func (f *FetchAndReplicateStateProcess) closePrewarmPuller() {
+	if f.prewarmPuller != nil {
+		_ = f.prewarmPuller.Close()
+		f.prewarmPuller = nil
+	}
+}
+
+// prewarmImages lists the images of every group once.
+func (f *FetchAndReplicateStateProcess) prewarmImages(sourceURL string) []prewarm.Image {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var images []prewarm.Image
+	for _, group := range f.stateMap {
+		for _, e := range group.Entities {
+			ref := fmt.Sprintf("%s/%s/%s:%s", sourceURL, e.Repository, e.Name, e.Tag)
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			images = append(images, prewarm.Image{Ref: ref, Digest: e.Digest})
+		}
+	}
+	return images
+}
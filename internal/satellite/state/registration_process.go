@@ -11,9 +11,13 @@ import (
 	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
 	satTLS "github.com/container-registry/harbor-satellite/internal/satellite/tls"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -171,6 +175,10 @@ func (z *ZtrProcess) IsComplete() bool {
 	return z.cm.IsZTRDone()
 }
 
+func (z *ZtrProcess) Dependencies() []scheduler.Dependency {
+	return []scheduler.Dependency{scheduler.DependencyGroundControl}
+}
+
 func (z *ZtrProcess) start() bool {
 	z.mu.Lock()
 	defer z.mu.Unlock()
@@ -200,7 +208,13 @@ func sanitizeAuditReason(err error, token string) string {
 	return s
 }
 
-func registerSatellite(groundControlURL, path, token string, tlsCfg config.TLSConfig, useUnsecure bool, ctx context.Context) (config.StateConfig, error) {
+func registerSatellite(groundControlURL, path, token string, tlsCfg config.TLSConfig, useUnsecure bool, ctx context.Context) (_ config.StateConfig, err error) {
+	ctx, span := tracer.Start(ctx, "satellite.ztr", trace.WithAttributes(attribute.String("satellite.ztr.flow", "token")))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	ztrURL := fmt.Sprintf("%s/%s/%s", groundControlURL, path, token)
 
 	client, err := createHTTPClient(tlsCfg, useUnsecure)
@@ -212,9 +226,10 @@ func registerSatellite(groundControlURL, path, token string, tlsCfg config.TLSCo
 	if err != nil {
 		return config.StateConfig{}, fmt.Errorf("failed to create request: %w", err)
 	}
+	tracing.InjectHeaders(ctx, req.Header)
 	response, err := client.Do(req)
 	if err != nil {
-		return config.StateConfig{}, fmt.Errorf("failed to send request: %w", err)
+		return config.StateConfig{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("failed to send request: %w", err))
 	}
 	defer func() {
 		if err := response.Body.Close(); err != nil {
@@ -222,6 +237,12 @@ func registerSatellite(groundControlURL, path, token string, tlsCfg config.TLSCo
 		}
 	}()
 
+	if err := scheduler.CheckRetryAfter(response); err != nil {
+		return config.StateConfig{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("failed to register satellite: %w", err))
+	}
+	if response.StatusCode >= http.StatusInternalServerError {
+		return config.StateConfig{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("failed to register satellite: %s", response.Status))
+	}
 	if response.StatusCode != http.StatusOK {
 		return config.StateConfig{}, fmt.Errorf("failed to register satellite: %s", response.Status)
 	}
@@ -3,11 +3,17 @@ package state
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
 	satTLS "github.com/container-registry/harbor-satellite/internal/satellite/tls"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
@@ -15,7 +21,10 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Replicator interface {
@@ -34,6 +43,10 @@ type BasicReplicator struct {
 	remoteUsername    string
 	remotePassword    string
 	tlsCfg            config.TLSConfig
+	localCAFile       string
+	// cachedSize returns the size of a cached image known from the
+	// inventory, for deleted images whose manifest can not be read.
+	cachedSize func(repository, tag string) (int64, bool)
 }
 
 func NewBasicReplicator(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword string, useUnsecure bool) Replicator {
@@ -53,6 +66,14 @@ func NewBasicReplicatorWithTLS(sourceUsername, sourcePassword, sourceRegistry, r
 	}
 }
 
+// NewBasicReplicatorWithLocalCA returns a replicator that also trusts
+// localCAFile, the CA of a secured local registry.
+func NewBasicReplicatorWithLocalCA(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword string, useUnsecure bool, localCAFile string) Replicator {
+	r := NewBasicReplicator(sourceUsername, sourcePassword, sourceRegistry, remoteURL, remoteUsername, remotePassword, useUnsecure).(*BasicReplicator)
+	r.localCAFile = localCAFile
+	return r
+}
+
 // Entity represents an image or artifact which needs to be handled by the replicator.
 type Entity struct {
 	Name       string `json:"name"`
@@ -91,18 +112,23 @@ func (r *BasicReplicator) Replicate(ctx context.Context, replicationEntities []E
 	pullOpts := []remote.Option{remote.WithAuth(pullAuth), remote.WithContext(ctx)}
 	pushOpts := []remote.Option{remote.WithAuth(pushAuth), remote.WithContext(ctx)}
 
+	transport := remote.DefaultTransport
 	if r.useUnsecure {
 		nameOpts = append(nameOpts, name.Insecure)
-	} else {
-		transport, err := r.buildTLSTransport()
+	}
+	if !r.useUnsecure || r.localCAFile != "" {
+		tlsTransport, err := r.buildTLSTransport()
 		if err != nil {
 			return fmt.Errorf("build TLS transport: %w", err)
 		}
-		if transport != nil {
-			pullOpts = append(pullOpts, remote.WithTransport(transport))
-			pushOpts = append(pushOpts, remote.WithTransport(transport))
+		if tlsTransport != nil {
+			transport = tlsTransport
 		}
 	}
+	// Surface 429 + Retry-After so the scheduler waits as long as asked.
+	transport = &scheduler.RetryAfterTransport{Base: transport}
+	pullOpts = append(pullOpts, remote.WithTransport(transport))
+	pushOpts = append(pushOpts, remote.WithTransport(transport))
 
 	for _, entity := range replicationEntities {
 		// Check context cancellation before processing each image
@@ -113,66 +139,94 @@ func (r *BasicReplicator) Replicate(ctx context.Context, replicationEntities []E
 		default:
 		}
 
-		srcRef := fmt.Sprintf("%s/%s/%s:%s", r.sourceRegistry, entity.GetRepository(), entity.GetName(), entity.GetTag())
-		dstRef := fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
-
-		src, err := name.ParseReference(srcRef, nameOpts...)
-		if err != nil {
-			return fmt.Errorf("parse source ref %s: %w", srcRef, err)
+		if err := r.replicateEntity(ctx, entity, nameOpts, pullOpts, pushOpts); err != nil {
+			return err
 		}
+	}
 
-		dst, err := name.ParseReference(dstRef, nameOpts...)
-		if err != nil {
-			return fmt.Errorf("parse dest ref %s: %w", dstRef, err)
-		}
+	return nil
+}
 
-		// Lazy fetch: only the manifest is downloaded, no layer data yet
-		desc, err := remote.Get(src, pullOpts...)
-		if err != nil {
-			log.Error().Msgf("Failed to fetch image descriptor: %v", err)
-			return err
-		}
+// replicateEntity copies a single image to the local registry inside its own
+// span, so slow or failing images stand out in a sync trace.
+func (r *BasicReplicator) replicateEntity(ctx context.Context, entity Entity, nameOpts []name.Option, pullOpts, pushOpts []remote.Option) (err error) {
+	log := logger.FromContext(ctx)
+	_, span := tracer.Start(ctx, "satellite.replicate.image", trace.WithAttributes(
+		attribute.String("image.repository", entity.GetRepository()),
+		attribute.String("image.name", entity.GetName()),
+		attribute.String("image.tag", entity.GetTag()),
+	))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	srcRef := fmt.Sprintf("%s/%s/%s:%s", r.sourceRegistry, entity.GetRepository(), entity.GetName(), entity.GetTag())
+	dstRef := fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag())
+
+	src, err := name.ParseReference(srcRef, nameOpts...)
+	if err != nil {
+		return fmt.Errorf("parse source ref %s: %w", srcRef, err)
+	}
 
-		img, err := desc.Image()
-		if err != nil {
-			log.Error().Msgf("Failed to resolve image: %v", err)
-			return err
-		}
+	dst, err := name.ParseReference(dstRef, nameOpts...)
+	if err != nil {
+		return fmt.Errorf("parse dest ref %s: %w", dstRef, err)
+	}
 
-		// Lazy OCI conversion, no data materialized
-		ociImage := mutate.MediaType(img, types.OCIManifestSchema1)
+	// Lazy fetch: only the manifest is downloaded, no layer data yet
+	desc, err := remote.Get(src, pullOpts...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch image descriptor: %v", err)
+		return scheduler.DependencyFailure(scheduler.DependencyHarbor, err)
+	}
 
-		// Check if image already exists at destination with same digest
-		srcDigest, err := ociImage.Digest()
-		if err != nil {
-			return fmt.Errorf("compute source digest: %w", err)
-		}
+	img, err := desc.Image()
+	if err != nil {
+		log.Error().Msgf("Failed to resolve image: %v", err)
+		return scheduler.DependencyFailure(scheduler.DependencyHarbor, err)
+	}
 
-		dstDesc, dstErr := remote.Head(dst, pushOpts...)
-		if dstErr == nil && dstDesc.Digest == srcDigest {
-			log.Info().Msgf("Image %s already up-to-date at destination, skipping", entity.GetName())
-			continue
-		}
+	// Lazy OCI conversion, no data materialized
+	ociImage := mutate.MediaType(img, types.OCIManifestSchema1)
 
-		// Log which layers need pulling vs already present
-		srcLayers, err := ociImage.Layers()
-		if err != nil {
-			return fmt.Errorf("get source layers: %w", err)
-		}
+	// Check if image already exists at destination with same digest
+	srcDigest, err := ociImage.Digest()
+	if err != nil {
+		return fmt.Errorf("compute source digest: %w", err)
+	}
 
-		missing := r.countMissingLayers(dst, srcLayers, pushOpts)
-		log.Info().Msgf("Replicating image %s: %d/%d layers to pull", entity.GetName(), missing, len(srcLayers))
+	dstDesc, dstErr := remote.Head(dst, pushOpts...)
+	if dstErr == nil && dstDesc.Digest == srcDigest {
+		log.Info().Msgf("Image %s already up-to-date at destination, skipping", entity.GetName())
+		span.SetAttributes(attribute.Bool("image.up_to_date", true))
+		return nil
+	}
 
-		// remote.Write streams layers one-by-one. For each layer it HEAD-checks
-		// the destination first; only missing blobs are pulled from source.
-		// Manifest is pushed last.
-		if err := remote.Write(dst, ociImage, pushOpts...); err != nil {
-			log.Error().Msgf("Failed to replicate image: %v", err)
-			return err
-		}
-		log.Info().Msgf("Image %s replicated successfully", entity.GetName())
+	// Log which layers need pulling vs already present
+	srcLayers, err := ociImage.Layers()
+	if err != nil {
+		return fmt.Errorf("get source layers: %w", err)
 	}
 
+	missing := r.countMissingLayers(dst, srcLayers, pushOpts)
+	log.Info().Msgf("Replicating image %s: %d/%d layers to pull", entity.GetName(), missing, len(srcLayers))
+
+	// remote.Write streams layers one-by-one. For each layer it HEAD-checks
+	// the destination first; only missing blobs are pulled from source.
+	// Manifest is pushed last.
+	if err := remote.Write(dst, ociImage, pushOpts...); err != nil {
+		log.Error().Msgf("Failed to replicate image: %v", err)
+		metrics.Images.WithLabelValues("replicate", metrics.OutcomeFailure).Inc()
+		return blameRegistry(err, dst)
+	}
+	log.Info().Msgf("Image %s replicated successfully", entity.GetName())
+	metrics.Images.WithLabelValues("replicate", metrics.OutcomeSuccess).Inc()
+	if raw, err := ociImage.RawManifest(); err == nil {
+		if size, err := imageSize(raw, remoteManifests(src.Context(), pullOpts...)); err == nil {
+			metrics.Bytes.WithLabelValues("replicate").Add(float64(size))
+		}
+	}
 	return nil
 }
 
@@ -214,6 +268,27 @@ func (r *BasicReplicator) countMissingLayers(dst name.Reference, srcLayers []v1.
 	return missing
 }
 
+// blameRegistry attributes a failed copy to the local registry when the
+// failing request went to dst's registry, and to Harbor otherwise. remote.Write
+// both pulls and pushes, so the error alone does not say which side failed.
+func blameRegistry(err error, dst name.Reference) error {
+	var host string
+	var terr *transport.Error
+	var uerr *url.Error
+	switch {
+	case errors.As(err, &terr) && terr.Request != nil:
+		host = terr.Request.URL.Host
+	case errors.As(err, &uerr):
+		if u, perr := url.Parse(uerr.URL); perr == nil {
+			host = u.Host
+		}
+	}
+	if host != "" && host == dst.Context().RegistryStr() {
+		return scheduler.DependencyFailure(scheduler.DependencyLocalRegistry, err)
+	}
+	return scheduler.DependencyFailure(scheduler.DependencyHarbor, err)
+}
+
 func (r *BasicReplicator) DeleteReplicationEntity(ctx context.Context, replicationEntity []Entity) error {
 	log := logger.FromContext(ctx)
 	auth := authn.FromConfig(authn.AuthConfig{
@@ -225,6 +300,13 @@ func (r *BasicReplicator) DeleteReplicationEntity(ctx context.Context, replicati
 	if r.useUnsecure {
 		options = append(options, crane.Insecure)
 	}
+	if r.localCAFile != "" {
+		tlsTransport, err := r.buildTLSTransport()
+		if err != nil {
+			return fmt.Errorf("build TLS transport: %w", err)
+		}
+		options = append(options, crane.WithTransport(tlsTransport))
+	}
 
 	for _, entity := range replicationEntity {
 		// Check context cancellation before processing each image
@@ -237,19 +319,43 @@ func (r *BasicReplicator) DeleteReplicationEntity(ctx context.Context, replicati
 
 		log.Info().Msgf("Deleting image %s from repository %s at registry %s with tag %s", entity.GetName(), entity.GetRepository(), r.remoteRegistryURL, entity.GetTag())
 
-		err := crane.Delete(fmt.Sprintf("%s/%s/%s:%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName(), entity.GetTag()), options...)
+		repo := fmt.Sprintf("%s/%s/%s", r.remoteRegistryURL, entity.GetRepository(), entity.GetName())
+		ref := repo + ":" + entity.GetTag()
+		size, sized := r.deletedSize(entity, repo, ref, options)
+
+		err := crane.Delete(ref, options...)
 		if err != nil {
 			log.Error().Msgf("Failed to delete image: %v", err)
-			return err
+			metrics.Images.WithLabelValues("delete", metrics.OutcomeFailure).Inc()
+			return scheduler.DependencyFailure(scheduler.DependencyLocalRegistry, err)
 		}
 		log.Info().Msgf("Image %s deleted successfully", entity.GetName())
+		metrics.Images.WithLabelValues("delete", metrics.OutcomeSuccess).Inc()
+		if sized {
+			metrics.Bytes.WithLabelValues("delete").Add(float64(size))
+		}
 	}
 
 	return nil
 }
 
+// deletedSize returns the size of the image about to be deleted, read from
+// its manifest in the local registry, or else from the inventory.
+func (r *BasicReplicator) deletedSize(entity Entity, repo, ref string, options []crane.Option) (int64, bool) {
+	if raw, err := crane.Manifest(ref, options...); err == nil {
+		fetch := func(digest string) ([]byte, error) { return crane.Manifest(repo+"@"+digest, options...) }
+		if size, err := imageSize(raw, fetch); err == nil {
+			return size, true
+		}
+	}
+	if r.cachedSize != nil {
+		return r.cachedSize(entity.GetRepository()+"/"+entity.GetName(), entity.GetTag())
+	}
+	return 0, false
+}
+
 func (r *BasicReplicator) buildTLSTransport() (http.RoundTripper, error) {
-	if r.tlsCfg.CertFile == "" && r.tlsCfg.CAFile == "" {
+	if r.tlsCfg.CertFile == "" && r.tlsCfg.CAFile == "" && r.localCAFile == "" {
 		return nil, nil
 	}
 
@@ -265,6 +371,18 @@ func (r *BasicReplicator) buildTLSTransport() (http.RoundTripper, error) {
 	if err != nil {
 		return nil, fmt.Errorf("load TLS config: %w", err)
 	}
+	if r.localCAFile != "" {
+		// Trust the secured local registry on top of the roots the upstream
+		// registry is verified with.
+		if tlsConfig.RootCAs == nil {
+			if tlsConfig.RootCAs, err = x509.SystemCertPool(); err != nil {
+				tlsConfig.RootCAs = x509.NewCertPool()
+			}
+		}
+		if err := appendCAFile(tlsConfig.RootCAs, r.localCAFile); err != nil {
+			return nil, err
+		}
+	}
 
 	return &http.Transport{
 		TLSClientConfig: tlsConfig,
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -13,6 +14,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -230,15 +232,21 @@ func TestCountMissingLayers_PartialOverlap(t *testing.T) {
 func TestDeleteReplicationEntity(t *testing.T) {
 	dstAddr := newTestRegistry(t)
 
-	pushImage(t, dstAddr, "alpine", "latest", 1)
+	img := pushImage(t, dstAddr, "alpine", "latest", 1)
+	m, err := img.Manifest()
+	require.NoError(t, err)
+	size := m.Config.Size + m.Layers[0].Size
 
 	r := NewBasicReplicator("", "", "", dstAddr, "", "", true)
 	ctx := testContext()
+	deleted := metrics.Bytes.WithLabelValues("delete")
+	before := testutil.ToFloat64(deleted)
 
-	err := r.DeleteReplicationEntity(ctx, []Entity{
+	err = r.DeleteReplicationEntity(ctx, []Entity{
 		{Name: "alpine", Repository: "library", Tag: "latest"},
 	})
 	require.NoError(t, err)
+	require.Equal(t, float64(size), testutil.ToFloat64(deleted)-before)
 }
 
 // TestReplicate_LayerResume simulates crash mid-replication and verifies that
@@ -2,13 +2,16 @@ package state
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/container-registry/harbor-satellite/internal/inventory"
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/nodeconfig"
+	"github.com/container-registry/harbor-satellite/internal/satellite/prewarm"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
@@ -16,21 +19,50 @@ import (
 )
 
 type StatusReportParams struct {
-	Name                string        `json:"name"`
-	Activity            string        `json:"activity"`
-	StateReportInterval string        `json:"state_report_interval"`
-	LatestStateDigest   string        `json:"latest_state_digest"`
-	LatestConfigDigest  string        `json:"latest_config_digest"`
-	MemoryUsedBytes     uint64        `json:"memory_used_bytes"`
-	StorageUsedBytes    uint64        `json:"storage_used_bytes"`
-	CPUPercent          float64       `json:"cpu_percent"`
-	RequestCreatedTime  time.Time     `json:"request_created_time"`
-	LastSyncDurationMs  int64         `json:"last_sync_duration_ms"`
-	ImageCount          int           `json:"image_count"`
-	CachedImages        []CachedImage `json:"cached_images,omitempty"`
+	Name                string                    `json:"name"`
+	Activity            string                    `json:"activity"`
+	StateReportInterval string                    `json:"state_report_interval"`
+	LatestStateDigest   string                    `json:"latest_state_digest"`
+	LatestConfigDigest  string                    `json:"latest_config_digest"`
+	MemoryUsedBytes     uint64                    `json:"memory_used_bytes"`
+	StorageUsedBytes    uint64                    `json:"storage_used_bytes"`
+	CPUPercent          float64                   `json:"cpu_percent"`
+	RequestCreatedTime  time.Time                 `json:"request_created_time"`
+	LastSyncDurationMs  int64                     `json:"last_sync_duration_ms"`
+	ImageCount          int                       `json:"image_count"`
+	Breakers            []scheduler.BreakerStatus `json:"breakers,omitempty"`
+	// RejectedConfigDigest is the config the satellite rolled back and will
+	// not apply again until Ground Control publishes a newer one.
+	RejectedConfigDigest string `json:"rejected_config_digest,omitempty"`
+	// LastSyncError is the error of the last replication cycle, empty when it
+	// succeeded.
+	LastSyncError string `json:"last_sync_error,omitempty"`
+	// ConfigRevision is the Ground Control revision of the applied config,
+	// omitted when the config artifact carried none.
+	ConfigRevision int32 `json:"config_revision,omitempty"`
+	// RegistryUptimeSeconds is how long the embedded registry has run since
+	// it last started, omitted when the satellite uses its own registry.
+	RegistryUptimeSeconds *int64 `json:"registry_uptime_seconds,omitempty"`
+	// RegistryRestarts is how often the embedded registry was restarted.
+	RegistryRestarts *int32 `json:"registry_restarts,omitempty"`
+	// Inventory lists the images of the local registry that changed since
+	// the last accepted report, or all of them when Full is set.
+	Inventory *inventory.Report `json:"inventory,omitempty"`
+	// Prewarm is whether each image of the state is in the image store of
+	// the runtime of each node, omitted when prewarming is off.
+	Prewarm []prewarm.Status `json:"prewarm,omitempty"`
+	// NodeConfig is the result of applying the published node config on
+	// each node running a node agent, omitted when no agent reported.
+	NodeConfig []nodeconfig.NodeStatus `json:"node_config,omitempty"`
 }
 
-func collectStatusReportParams(ctx context.Context, heartbeatInterval time.Duration, req *StatusReportParams, cfg config.MetricsConfig, registryURL string, insecure bool) {
+// statusReportResponse is what Ground Control answers a heartbeat with.
+type statusReportResponse struct {
+	// InventoryResync asks for a full inventory in the next heartbeat.
+	InventoryResync bool `json:"inventory_resync,omitempty"`
+}
+
+func collectStatusReportParams(ctx context.Context, heartbeatInterval time.Duration, req *StatusReportParams, cfg config.MetricsConfig, reg localRegistry, inv *Inventory) {
 	log := logger.FromContext(ctx)
 
 	if cfg.CollectCPU {
@@ -43,14 +75,12 @@ func collectStatusReportParams(ctx context.Context, heartbeatInterval time.Durat
 		req.StorageUsedBytes = getStorageUsedBytes(ctx, "/")
 	}
 
-	if registryURL != "" {
-		cached, err := collectCachedImages(ctx, registryURL, insecure)
-		if err != nil {
-			log.Warn().Err(err).Msg("Failed to collect cached images")
-		} else {
-			req.CachedImages = cached
-			req.ImageCount = len(cached)
+	if reg.host != "" && inv != nil {
+		if err := inv.Refresh(ctx, reg); err != nil {
+			log.Warn().Err(err).Msg("Failed to refresh cached image inventory")
 		}
+		req.Inventory = inv.Report()
+		req.ImageCount = inv.Len()
 	}
 }
 
@@ -102,9 +132,9 @@ func getStorageUsedBytes(ctx context.Context, path string) uint64 {
 	return usage.Used
 }
 
-// extractSatelliteNameFromURL parses a state URL and returns the satellite name.
+// SatelliteNameFromURL parses a state URL and returns the satellite name.
 // Supports: "hostname/satellite/satellite-state/<name>/state:latest".
-func extractSatelliteNameFromURL(stateURL string) (string, error) {
+func SatelliteNameFromURL(stateURL string) (string, error) {
 	parsed, err := url.Parse(stateURL)
 	if err != nil {
 		return "", fmt.Errorf("parse state URL: %w", err)
@@ -121,14 +151,14 @@ func extractSatelliteNameFromURL(stateURL string) (string, error) {
 	return "", fmt.Errorf("could not extract satellite name from URL path: %s", parsed.Path)
 }
 
-func parseEveryExpr(expr string) (time.Duration, error) {
-	const prefix = "@every "
-	if expr == "" {
-		return 0, errors.New("empty expression provided")
-	}
-	if !strings.HasPrefix(expr, prefix) {
-		return 0, fmt.Errorf("unsupported format: must start with %q", prefix)
+// reportInterval resolves a schedule expression to the heartbeat interval and
+// its "@every" form. Ground Control only understands "@every", so cron
+// schedules are reported by their approximate period, rounded to seconds.
+func reportInterval(expr string) (time.Duration, string, error) {
+	d, err := scheduler.Interval(expr)
+	if err != nil {
+		return 0, "", err
 	}
-
-	return time.ParseDuration(strings.TrimPrefix(expr, prefix))
+	d = max(d.Truncate(time.Second), time.Second)
+	return d, "@every " + d.String(), nil
 }
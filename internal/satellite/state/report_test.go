@@ -13,9 +13,9 @@ func TestCollectStatusReportParams_EmptyRegistryURL(t *testing.T) {
 	req := &StatusReportParams{}
 	cfg := config.MetricsConfig{}
 
-	collectStatusReportParams(ctx, 30*time.Second, req, cfg, "", false)
+	collectStatusReportParams(ctx, 30*time.Second, req, cfg, localRegistry{}, NewInventory())
 
-	require.Nil(t, req.CachedImages)
+	require.Nil(t, req.Inventory)
 	require.Equal(t, 0, req.ImageCount)
 }
 
@@ -24,10 +24,10 @@ func TestCollectStatusReportParams_UnreachableRegistry(t *testing.T) {
 	req := &StatusReportParams{}
 	cfg := config.MetricsConfig{}
 
-	collectStatusReportParams(ctx, 30*time.Second, req, cfg, "127.0.0.1:1", true)
+	collectStatusReportParams(ctx, 30*time.Second, req, cfg, localRegistry{host: "127.0.0.1:1", insecure: true}, NewInventory())
 
-	// Should gracefully handle the error - no cached images, image count stays 0
-	require.Nil(t, req.CachedImages)
+	// Should gracefully handle the error - no inventory, image count stays 0
+	require.Nil(t, req.Inventory)
 	require.Equal(t, 0, req.ImageCount)
 }
 
@@ -62,7 +62,7 @@ func TestExtractSatelliteNameFromURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := extractSatelliteNameFromURL(tt.url)
+			got, err := SatelliteNameFromURL(tt.url)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -73,22 +73,43 @@ func TestExtractSatelliteNameFromURL(t *testing.T) {
 	}
 }
 
-func TestParseEveryExpr(t *testing.T) {
+func TestReportInterval(t *testing.T) {
 	tests := []struct {
 		name    string
 		expr    string
 		wantDur time.Duration
+		wantRep string
 		wantErr bool
 	}{
 		{
 			name:    "valid 30s",
 			expr:    "@every 30s",
 			wantDur: 30 * time.Second,
+			wantRep: "@every 30s",
 		},
 		{
 			name:    "valid complex",
 			expr:    "@every 00h01m30s",
 			wantDur: 90 * time.Second,
+			wantRep: "@every 1m30s",
+		},
+		{
+			name:    "sub-second rounds up",
+			expr:    "@every 200ms",
+			wantDur: time.Second,
+			wantRep: "@every 1s",
+		},
+		{
+			name:    "cron",
+			expr:    "*/5 * * * *",
+			wantDur: 5 * time.Minute,
+			wantRep: "@every 5m0s",
+		},
+		{
+			name:    "descriptor",
+			expr:    "@hourly",
+			wantDur: time.Hour,
+			wantRep: "@every 1h0m0s",
 		},
 		{
 			name:    "empty",
@@ -104,13 +125,14 @@ func TestParseEveryExpr(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			d, err := parseEveryExpr(tt.expr)
+			d, rep, err := reportInterval(tt.expr)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
 			require.Equal(t, tt.wantDur, d)
+			require.Equal(t, tt.wantRep, rep)
 		})
 	}
 }
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -12,8 +14,12 @@ import (
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
 	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/container-registry/harbor-satellite/internal/satellite/nodeconfig"
+	"github.com/container-registry/harbor-satellite/internal/satellite/prewarm"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
 	"github.com/container-registry/harbor-satellite/internal/spiffe"
-	"github.com/container-registry/harbor-satellite/internal/utils"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 )
 
@@ -27,6 +33,28 @@ type StatusReportingProcess struct {
 	spiffeClient *spiffe.Client
 	pendingCRI   []runtime.CRIConfigResult
 	criReported  bool
+	breakers     *scheduler.Breakers
+	probation    *ConfigProbation
+	replication  ReplicationStatus
+	registry     RegistryStatus
+	inventory    *Inventory
+	prewarmer    *prewarm.Prewarmer
+	nodeConfigs  *nodeconfig.Collector
+}
+
+// ReplicationStatus is the view of the state replication process reported in
+// every heartbeat.
+type ReplicationStatus interface {
+	ConfigDigest() string
+	ConfigRevision() int32
+	LastSync() SyncResult
+}
+
+// RegistryStatus is the view of the supervised local registry reported in
+// every heartbeat.
+type RegistryStatus interface {
+	Uptime() time.Duration
+	Restarts() int
 }
 
 func NewStatusReportingProcess(cm *config.ConfigManager) *StatusReportingProcess {
@@ -35,30 +63,113 @@ func NewStatusReportingProcess(cm *config.ConfigManager) *StatusReportingProcess
 		mu:   &sync.Mutex{},
 		cm:   cm,
 	}
-
-	if cm.IsSPIFFEEnabled() {
-		spiffeCfg := cm.GetSPIFFEConfig()
-		client, err := spiffe.NewClient(spiffe.Config{
-			Enabled:          spiffeCfg.Enabled,
-			EndpointSocket:   spiffeCfg.EndpointSocket,
-			ExpectedServerID: spiffeCfg.ExpectedServerID,
-		})
-		if err == nil {
-			p.spiffeClient = client
-		}
+	if client, err := newSPIFFEClient(cm); err == nil {
+		p.spiffeClient = client
 	}
 
 	return p
 }
 
-// SetPendingCRIResults stores CRI config results to be sent in the first heartbeat.
+// newSPIFFEClient returns a SPIFFE client for the current config, or nil when
+// SPIFFE is disabled.
+func newSPIFFEClient(cm *config.ConfigManager) (*spiffe.Client, error) {
+	if !cm.IsSPIFFEEnabled() {
+		return nil, nil
+	}
+	spiffeCfg := cm.GetSPIFFEConfig()
+	return spiffe.NewClient(spiffe.Config{
+		Enabled:          spiffeCfg.Enabled,
+		EndpointSocket:   spiffeCfg.EndpointSocket,
+		ExpectedServerID: spiffeCfg.ExpectedServerID,
+	})
+}
+
+// ReloadSPIFFE replaces the SPIFFE client with one built from the current
+// config, so heartbeats switch between SPIFFE and token auth without a
+// restart.
+func (s *StatusReportingProcess) ReloadSPIFFE() error {
+	client, err := newSPIFFEClient(s.cm)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	old := s.spiffeClient
+	s.spiffeClient = client
+	s.mu.Unlock()
+
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// SetBreakers sets the dependency breakers whose state is reported in every
+// heartbeat.
+func (s *StatusReportingProcess) SetBreakers(breakers *scheduler.Breakers) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakers = breakers
+}
+
+// SetConfigProbation sets the config probation that heartbeat failures count
+// against and whose rollbacks are reported.
+func (s *StatusReportingProcess) SetConfigProbation(probation *ConfigProbation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.probation = probation
+}
+
+// SetReplicationStatus sets the replication process whose applied config and
+// last sync are reported, so Ground Control can follow config rollouts.
+func (s *StatusReportingProcess) SetReplicationStatus(replication ReplicationStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replication = replication
+}
+
+// SetRegistryStatus sets the supervisor of the local registry whose uptime
+// and restart count are reported.
+func (s *StatusReportingProcess) SetRegistryStatus(registry RegistryStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registry = registry
+}
+
+// SetInventory sets the inventory of cached images reported in every
+// heartbeat.
+func (s *StatusReportingProcess) SetInventory(inv *Inventory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inventory = inv
+}
+
+// SetPrewarmer sets the prewarmer whose per-node warm status is reported in
+// every heartbeat.
+func (s *StatusReportingProcess) SetPrewarmer(p *prewarm.Prewarmer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prewarmer = p
+}
+
+// SetNodeConfigCollector sets the collector whose per-node node config
+// results are reported in every heartbeat.
+func (s *StatusReportingProcess) SetNodeConfigCollector(c *nodeconfig.Collector) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodeConfigs = c
+}
+
+// SetPendingCRIResults stores CRI config results to be sent in the next
+// successful heartbeat.
 func (s *StatusReportingProcess) SetPendingCRIResults(results []runtime.CRIConfigResult) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.pendingCRI = results
+	s.criReported = false
 }
 
-func (s *StatusReportingProcess) Execute(ctx context.Context) error {
+func (s *StatusReportingProcess) Execute(ctx context.Context) (err error) {
 	s.start()
 	defer s.stop()
 
@@ -70,45 +181,108 @@ func (s *StatusReportingProcess) Execute(ctx context.Context) error {
 		return nil
 	}
 
-	satelliteName, err := extractSatelliteNameFromURL(stateURL)
+	ctx, span := tracer.Start(ctx, "satellite.heartbeat")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	satelliteName, err := SatelliteNameFromURL(stateURL)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to extract satellite name from state URL")
 		return err
 	}
 
 	heartbeatExpr := s.cm.GetHeartbeatInterval()
-	heartbeatDuration, err := parseEveryExpr(heartbeatExpr)
+	heartbeatDuration, reportedInterval, err := reportInterval(heartbeatExpr)
 	if err != nil {
 		log.Warn().Err(err).Msgf("Failed to parse heartbeat interval %q, using 30s", heartbeatExpr)
-		heartbeatDuration = 30 * time.Second
+		heartbeatDuration, reportedInterval = 30*time.Second, "@every 30s"
 	}
 
 	metricsCfg := s.cm.GetMetricsConfig()
 
 	req := &StatusReportParams{
 		Name:                satelliteName,
-		StateReportInterval: heartbeatExpr,
+		StateReportInterval: reportedInterval,
 		RequestCreatedTime:  time.Now().UTC(),
 	}
 
 	// Include pending CRI results until successfully sent
 	s.mu.Lock()
+	req.Breakers = s.breakers.Status()
+	var activity []string
 	hasPendingCRI := !s.criReported && len(s.pendingCRI) > 0
 	if hasPendingCRI {
-		req.Activity = formatCRIActivity(s.pendingCRI)
-		log.Info().Str("activity", req.Activity).Msg("Reporting CRI config results")
+		activity = append(activity, formatCRIActivity(s.pendingCRI))
 	}
+	probation := s.probation
+	replication := s.replication
+	registry := s.registry
+	inv := s.inventory
+	prewarmer := s.prewarmer
+	nodeConfigs := s.nodeConfigs
 	s.mu.Unlock()
 
-	registryURL := utils.FormatRegistryURL(s.cm.GetLocalRegistryURL())
-	insecure := s.cm.UseUnsecure()
-	collectStatusReportParams(ctx, heartbeatDuration, req, metricsCfg, registryURL, insecure)
+	if prewarmer != nil {
+		req.Prewarm = prewarmer.Status()
+	}
+	if nodeConfigs != nil {
+		req.NodeConfig = nodeConfigs.Status()
+	}
+
+	if registry != nil {
+		uptime := int64(registry.Uptime().Seconds())
+		restarts := int32(registry.Restarts())
+		req.RegistryUptimeSeconds = &uptime
+		req.RegistryRestarts = &restarts
+	}
+
+	if replication != nil {
+		req.LatestConfigDigest = replication.ConfigDigest()
+		req.ConfigRevision = replication.ConfigRevision()
+		if last := replication.LastSync(); !last.Time.IsZero() {
+			req.LastSyncDurationMs = last.Duration.Milliseconds()
+			req.LastSyncError = last.Error
+		}
+	}
+
+	var rollback *ConfigRollback
+	if probation != nil {
+		req.RejectedConfigDigest = probation.Rejected()
+		if rollback = probation.Unreported(); rollback != nil {
+			activity = append(activity, formatRollbackActivity(rollback))
+		}
+	}
+	if len(activity) > 0 {
+		req.Activity = strings.Join(activity, "; ")
+		log.Info().Str("activity", req.Activity).Msg("Reporting activity")
+	}
+
+	collectStatusReportParams(ctx, heartbeatDuration, req, metricsCfg, newLocalRegistry(s.cm), inv)
 
 	groundControlURL := s.cm.ResolveGroundControlURL()
-	if err := s.sendStatusReport(ctx, groundControlURL, req); err != nil {
+	resp, err := s.sendStatusReport(ctx, groundControlURL, req)
+	if probation != nil {
+		probation.RecordGroundControl(err)
+	}
+	if err != nil {
 		log.Error().Err(err).Msg("Failed to send status report")
+		metrics.Heartbeats.WithLabelValues(metrics.OutcomeFailure).Inc()
 		return err
 	}
+	metrics.Heartbeats.WithLabelValues(metrics.OutcomeSuccess).Inc()
+
+	if inv != nil {
+		inv.Acknowledge(req.Inventory, resp.InventoryResync)
+		if resp.InventoryResync {
+			log.Info().Msg("Ground Control asked for a full image inventory")
+		}
+	}
+
+	if rollback != nil {
+		probation.Reported(rollback)
+	}
 
 	// Clear CRI results only after successful send
 	if hasPendingCRI {
@@ -122,6 +296,11 @@ func (s *StatusReportingProcess) Execute(ctx context.Context) error {
 	return nil
 }
 
+// formatRollbackActivity formats a config rollback for the Activity field.
+func formatRollbackActivity(rb *ConfigRollback) string {
+	return fmt.Sprintf("config_rolled_back: rejected=%s, restored=%s, reason=%s", rb.RejectedDigest, rb.RestoredDigest, rb.Reason)
+}
+
 // formatCRIActivity formats CRI config results into a structured string for the Activity field.
 func formatCRIActivity(results []runtime.CRIConfigResult) string {
 	var parts []string
@@ -134,45 +313,64 @@ func formatCRIActivity(results []runtime.CRIConfigResult) string {
 		if r.BackupPath != "" {
 			entry += ", backup:" + r.BackupPath
 		}
+		switch {
+		case r.Reloaded:
+			entry += ", reloaded"
+		case r.ReloadError != "":
+			entry += ", reload_err:" + r.ReloadError
+		case r.RestartRequired:
+			entry += ", restart_required"
+		}
+		switch {
+		case r.Verified:
+			entry += ", verified"
+		case r.VerifyError != "":
+			entry += ", unverified:" + r.VerifyError
+		}
 		entry += ")"
 		parts = append(parts, entry)
 	}
 	return "cri_fallback_configured: " + strings.Join(parts, ", ")
 }
 
-func (s *StatusReportingProcess) sendStatusReport(ctx context.Context, groundControlURL string, req *StatusReportParams) error {
+func (s *StatusReportingProcess) sendStatusReport(ctx context.Context, groundControlURL string, req *StatusReportParams) (statusReportResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("marshal status report: %w", err)
+		return statusReportResponse{}, fmt.Errorf("marshal status report: %w", err)
 	}
 
 	syncURL := fmt.Sprintf("%s/%s", groundControlURL, StatusReportRoute)
 
+	s.mu.Lock()
+	spiffeClient := s.spiffeClient
+	s.mu.Unlock()
+
 	var client *http.Client
-	if s.spiffeClient != nil {
-		if err := s.spiffeClient.Connect(ctx); err != nil {
-			return fmt.Errorf("connect to SPIRE agent: %w", err)
+	if spiffeClient != nil {
+		if err := spiffeClient.Connect(ctx); err != nil {
+			return statusReportResponse{}, fmt.Errorf("connect to SPIRE agent: %w", err)
 		}
-		client, err = s.spiffeClient.CreateHTTPClient()
+		client, err = spiffeClient.CreateHTTPClient()
 		if err != nil {
-			return fmt.Errorf("create SPIFFE HTTP client: %w", err)
+			return statusReportResponse{}, fmt.Errorf("create SPIFFE HTTP client: %w", err)
 		}
 	} else {
 		client, err = createHTTPClient(s.cm.GetTLSConfig(), s.cm.UseUnsecure())
 		if err != nil {
-			return fmt.Errorf("create HTTP client: %w", err)
+			return statusReportResponse{}, fmt.Errorf("create HTTP client: %w", err)
 		}
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, syncURL, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return statusReportResponse{}, fmt.Errorf("create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	tracing.InjectHeaders(ctx, httpReq.Header)
 
-	if s.spiffeClient == nil {
+	if spiffeClient == nil {
 		if !s.cm.UseUnsecure() && !strings.HasPrefix(syncURL, "https://") {
-			return fmt.Errorf("insecure connection: sync URL %q must use HTTPS when use_unsecure is false", syncURL)
+			return statusReportResponse{}, fmt.Errorf("insecure connection: sync URL %q must use HTTPS when use_unsecure is false", syncURL)
 		}
 		username := s.cm.GetSourceRegistryUsername()
 		password := s.cm.GetSourceRegistryPassword()
@@ -183,7 +381,7 @@ func (s *StatusReportingProcess) sendStatusReport(ctx context.Context, groundCon
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return fmt.Errorf("send request: %w", err)
+		return statusReportResponse{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("send request: %w", err))
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -191,11 +389,23 @@ func (s *StatusReportingProcess) sendStatusReport(ctx context.Context, groundCon
 		}
 	}()
 
+	if err := scheduler.CheckRetryAfter(resp); err != nil {
+		return statusReportResponse{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("status report failed: %w", err))
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return statusReportResponse{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("status report failed: %s", resp.Status))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("status report failed: %s", resp.Status)
+		return statusReportResponse{}, fmt.Errorf("status report failed: %s", resp.Status)
 	}
 
-	return nil
+	// Ground Control versions before the image inventory answer with an
+	// empty body.
+	var out statusReportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil && !errors.Is(err, io.EOF) {
+		logger.FromContext(ctx).Warn().Err(err).Msg("Failed to decode status report response")
+	}
+	return out, nil
 }
 
 func (s *StatusReportingProcess) Name() string {
@@ -214,6 +424,10 @@ func (s *StatusReportingProcess) IsComplete() bool {
 	return false
 }
 
+func (s *StatusReportingProcess) Dependencies() []scheduler.Dependency {
+	return []scheduler.Dependency{scheduler.DependencyGroundControl}
+}
+
 func (s *StatusReportingProcess) start() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
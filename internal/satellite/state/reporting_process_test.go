@@ -2,14 +2,17 @@ package state
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/crypto"
 	runtime "github.com/container-registry/harbor-satellite/internal/satellite/container_runtime"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/stretchr/testify/require"
 )
@@ -50,6 +53,22 @@ func TestFormatCRIActivity(t *testing.T) {
 			},
 			want: "cri_fallback_configured: docker(ok, backup:/etc/docker/daemon.json.bak.20250129T100000), containerd(ok), crio(err:permission denied)",
 		},
+		{
+			name: "reload and verification",
+			results: []runtime.CRIConfigResult{
+				{CRI: runtime.CRICrio, Success: true, Reloaded: true, Verified: true},
+				{CRI: runtime.CRIContainerd, Success: true, VerifyError: "pull failed"},
+				{CRI: runtime.CRIDocker, Success: true, ReloadError: "no dockerd process found"},
+			},
+			want: "cri_fallback_configured: crio(ok, reloaded, verified), containerd(ok, unverified:pull failed), docker(ok, reload_err:no dockerd process found)",
+		},
+		{
+			name: "restart required",
+			results: []runtime.CRIConfigResult{
+				{CRI: runtime.CRIK3s, Success: true, RestartRequired: true},
+			},
+			want: "cri_fallback_configured: k3s(ok, restart_required)",
+		},
 		{
 			name:    "empty results",
 			results: []runtime.CRIConfigResult{},
@@ -257,3 +276,95 @@ func TestExecute_CRIReporting(t *testing.T) {
 		p.mu.Unlock()
 	})
 }
+
+func TestExecute_ReportsBreakers(t *testing.T) {
+	var received StatusReportParams
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	breakers := scheduler.NewBreakers()
+	breakers.Get(scheduler.DependencyHarbor).Failure(errors.New("harbor unreachable"), time.Now())
+
+	cm := newReportingTestCM(t, srv.URL)
+	p := &StatusReportingProcess{name: "test", mu: &sync.Mutex{}, cm: cm}
+	p.SetBreakers(breakers)
+
+	err := p.Execute(testContext())
+	require.Error(t, err)
+	require.Equal(t, []scheduler.Dependency{scheduler.DependencyGroundControl}, scheduler.FailedDependencies(err))
+
+	fail = false
+	require.NoError(t, p.Execute(testContext()))
+	require.Len(t, received.Breakers, 3)
+	require.Equal(t, scheduler.DependencyHarbor, received.Breakers[1].Dependency)
+	require.Equal(t, 1, received.Breakers[1].ConsecutiveFailures)
+	require.Equal(t, "harbor unreachable", received.Breakers[1].LastError)
+}
+
+func TestExecute_ReportsConfigRollback(t *testing.T) {
+	var received StatusReportParams
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	probation := NewConfigProbation(nil)
+	probation.Start("sha256:bad", "sha256:good", 0)
+	probation.RolledBack("local registry unhealthy", time.Now())
+
+	cm := newReportingTestCM(t, srv.URL)
+	p := &StatusReportingProcess{name: "test", mu: &sync.Mutex{}, cm: cm}
+	p.SetConfigProbation(probation)
+
+	require.NoError(t, p.Execute(testContext()))
+	require.Equal(t, "sha256:bad", received.RejectedConfigDigest)
+	require.Contains(t, received.Activity, "config_rolled_back: rejected=sha256:bad, restored=sha256:good")
+	require.Nil(t, probation.Unreported())
+
+	// The rejection is reported in every heartbeat, the rollback only once.
+	require.NoError(t, p.Execute(testContext()))
+	require.Equal(t, "sha256:bad", received.RejectedConfigDigest)
+	require.Empty(t, received.Activity)
+}
+
+type fakeReplication struct {
+	digest   string
+	revision int32
+	last     SyncResult
+}
+
+func (f fakeReplication) ConfigDigest() string  { return f.digest }
+func (f fakeReplication) ConfigRevision() int32 { return f.revision }
+func (f fakeReplication) LastSync() SyncResult  { return f.last }
+
+func TestExecute_ReportsReplicationStatus(t *testing.T) {
+	var received StatusReportParams
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cm := newReportingTestCM(t, srv.URL)
+	p := &StatusReportingProcess{name: "test", mu: &sync.Mutex{}, cm: cm}
+	p.SetReplicationStatus(fakeReplication{
+		digest:   "sha256:cfg",
+		revision: 7,
+		last:     SyncResult{Time: time.Now(), Duration: 1500 * time.Millisecond, Error: "harbor unreachable"},
+	})
+
+	require.NoError(t, p.Execute(testContext()))
+	require.Equal(t, "sha256:cfg", received.LatestConfigDigest)
+	require.Equal(t, int32(7), received.ConfigRevision)
+	require.Equal(t, int64(1500), received.LastSyncDurationMs)
+	require.Equal(t, "harbor unreachable", received.LastSyncError)
+}
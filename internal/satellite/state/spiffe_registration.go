@@ -9,9 +9,13 @@ import (
 	"sync"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
 	"github.com/container-registry/harbor-satellite/internal/spiffe"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const SPIFFEZeroTouchRegistrationRoute = "satellites/spiffe-ztr"
@@ -100,7 +104,13 @@ func (s *SpiffeZtrProcess) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (s *SpiffeZtrProcess) registerWithSPIFFE(ctx context.Context, log *zerolog.Logger) (config.StateConfig, error) {
+func (s *SpiffeZtrProcess) registerWithSPIFFE(ctx context.Context, log *zerolog.Logger) (_ config.StateConfig, err error) {
+	ctx, span := tracer.Start(ctx, "satellite.ztr", trace.WithAttributes(attribute.String("satellite.ztr.flow", "spiffe")))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	gcURL := s.cm.ResolveGroundControlURL()
 	ztrURL := fmt.Sprintf("%s/%s", gcURL, SPIFFEZeroTouchRegistrationRoute)
 
@@ -113,11 +123,12 @@ func (s *SpiffeZtrProcess) registerWithSPIFFE(ctx context.Context, log *zerolog.
 	if err != nil {
 		return config.StateConfig{}, fmt.Errorf("create request: %w", err)
 	}
+	tracing.InjectHeaders(ctx, req.Header)
 
 	log.Debug().Str("url", ztrURL).Msg("Sending SPIFFE-authenticated ZTR request")
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return config.StateConfig{}, fmt.Errorf("send request: %w", err)
+		return config.StateConfig{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("send request: %w", err))
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -125,6 +136,12 @@ func (s *SpiffeZtrProcess) registerWithSPIFFE(ctx context.Context, log *zerolog.
 		}
 	}()
 
+	if err := scheduler.CheckRetryAfter(resp); err != nil {
+		return config.StateConfig{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("registration failed: %w", err))
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return config.StateConfig{}, scheduler.DependencyFailure(scheduler.DependencyGroundControl, fmt.Errorf("registration failed: %s", resp.Status))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return config.StateConfig{}, fmt.Errorf("registration failed: %s", resp.Status)
 	}
@@ -172,6 +189,10 @@ func (s *SpiffeZtrProcess) IsComplete() bool {
 	return s.cm.IsZTRDone()
 }
 
+func (s *SpiffeZtrProcess) Dependencies() []scheduler.Dependency {
+	return []scheduler.Dependency{scheduler.DependencyGroundControl}
+}
+
 func (s *SpiffeZtrProcess) start() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
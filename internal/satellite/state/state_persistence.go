@@ -16,15 +16,20 @@ type PersistedGroupState struct {
 
 // PersistedState is the top-level struct written to state.json.
 type PersistedState struct {
-	ConfigDigest string                `json:"config_digest,omitempty"`
-	Groups       []PersistedGroupState `json:"groups"`
+	ConfigDigest   string                `json:"config_digest,omitempty"`
+	ConfigRevision int32                 `json:"config_revision,omitempty"`
+	Probation      *ProbationState       `json:"config_probation,omitempty"`
+	Groups         []PersistedGroupState `json:"groups"`
 }
 
-// SaveState writes the current stateMap and configDigest to disk.
-func SaveState(path string, stateMap []StateMap, configDigest string) error {
+// SaveState writes the current stateMap, the applied config's digest and
+// revision, and the config probation to disk. probation may be nil.
+func SaveState(path string, stateMap []StateMap, configDigest string, configRevision int32, probation *ProbationState) error {
 	persisted := PersistedState{
-		ConfigDigest: configDigest,
-		Groups:       make([]PersistedGroupState, 0, len(stateMap)),
+		ConfigDigest:   configDigest,
+		ConfigRevision: configRevision,
+		Probation:      probation,
+		Groups:         make([]PersistedGroupState, 0, len(stateMap)),
 	}
 	for _, sm := range stateMap {
 		persisted.Groups = append(persisted.Groups, PersistedGroupState{
@@ -27,7 +27,7 @@ func TestSaveAndLoadRoundTrip(t *testing.T) {
 	}
 	configDigest := "sha256:config123"
 
-	if err := SaveState(path, stateMap, configDigest); err != nil {
+	if err := SaveState(path, stateMap, configDigest, 4, nil); err != nil {
 		t.Fatalf("SaveState failed: %v", err)
 	}
 
@@ -42,6 +42,9 @@ func TestSaveAndLoadRoundTrip(t *testing.T) {
 	if loaded.ConfigDigest != configDigest {
 		t.Errorf("ConfigDigest = %q, want %q", loaded.ConfigDigest, configDigest)
 	}
+	if loaded.ConfigRevision != 4 {
+		t.Errorf("ConfigRevision = %d, want 4", loaded.ConfigRevision)
+	}
 
 	if len(loaded.Groups) != len(stateMap) {
 		t.Fatalf("Groups count = %d, want %d", len(loaded.Groups), len(stateMap))
@@ -79,7 +82,7 @@ func TestSaveEmptyState(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "state.json")
 
-	if err := SaveState(path, nil, ""); err != nil {
+	if err := SaveState(path, nil, "", 0, nil); err != nil {
 		t.Fatalf("SaveState failed for empty state: %v", err)
 	}
 
@@ -3,24 +3,47 @@ package state
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/logger"
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+	"github.com/container-registry/harbor-satellite/internal/satellite/prewarm"
+	"github.com/container-registry/harbor-satellite/internal/satellite/scheduler"
+	"github.com/container-registry/harbor-satellite/internal/tracing"
 	"github.com/container-registry/harbor-satellite/internal/utils"
 	"github.com/container-registry/harbor-satellite/pkg/config"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("github.com/container-registry/harbor-satellite/internal/satellite/state")
+
 type FetchAndReplicateStateProcess struct {
 	name                string
 	isRunning           bool
 	stateMap            []StateMap
 	currentConfigDigest string
-	cm                  *config.ConfigManager
-	mu                  sync.Mutex
-	stateFilePath       string
-	directDeliverer     *DirectDeliverer
+	// currentConfigRevision is the Ground Control revision of the applied
+	// config, zero when unknown.
+	currentConfigRevision int32
+	cm                    *config.ConfigManager
+	mu                    sync.Mutex
+	stateFilePath         string
+	directDeliverer       *DirectDeliverer
+	lastSync              SyncResult
+	groupSync             map[string]groupSyncStatus
+	probation             *ConfigProbation
+	inventory             *Inventory
+	prewarmer             *prewarm.Prewarmer
+	// prewarmPuller is kept across cycles; prewarmEndpoint is the CRI
+	// endpoint it was created for.
+	prewarmPuller   prewarm.Puller
+	prewarmEndpoint string
 }
 
 // Define result types for channels
@@ -42,6 +65,9 @@ func NewFetchAndReplicateStateProcess(cm *config.ConfigManager, stateFilePath st
 		name:          config.ReplicateStateJobName,
 		cm:            cm,
 		stateFilePath: stateFilePath,
+		probation:     NewConfigProbation(nil),
+		inventory:     NewInventory(),
+		prewarmer:     prewarm.NewPrewarmer(),
 	}
 
 	if stateFilePath != "" {
@@ -50,6 +76,9 @@ func NewFetchAndReplicateStateProcess(cm *config.ConfigManager, stateFilePath st
 			log.Warn().Err(err).Str("path", stateFilePath).Msg("Corrupted state file, starting fresh")
 		} else if persisted != nil {
 			p.currentConfigDigest = persisted.ConfigDigest
+			p.currentConfigRevision = persisted.ConfigRevision
+			p.probation = NewConfigProbation(persisted.Probation)
+			metrics.ObserveConfigDigest(persisted.ConfigDigest)
 			for _, g := range persisted.Groups {
 				p.stateMap = append(p.stateMap, StateMap{
 					url:      g.URL,
@@ -76,7 +105,7 @@ func NewStateMap(url []string) []StateMap {
 	return stateMap
 }
 
-func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
+func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) (err error) {
 	f.start()
 	defer f.stop()
 
@@ -91,6 +120,11 @@ func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 	}
 
 	replicator, sourceURL, srcUsername, srcPassword, remoteURL, useUnsecure, satelliteStateURL := f.setupReplication()
+	// Set up direct delivery if enabled, clear if disabled
+	f.directDeliverer = f.newDirectDeliverer(f.cm.GetDirectDeliveryConfig(), sourceURL, srcUsername, srcPassword, useUnsecure, &log)
+	if f.directDeliverer != nil {
+		defer f.directDeliverer.Close(ctx)
+	}
 
 	canExecute, reason := f.CanExecute(satelliteStateURL, remoteURL, sourceURL, srcUsername, srcPassword)
 	if !canExecute {
@@ -98,6 +132,21 @@ func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 		return nil
 	}
 	log.Info().Msg(reason)
+	// Every cycle past this point is judged, including those that fail to
+	// reach Harbor: a config that breaks access must be rolled back.
+	defer func() {
+		f.judgeConfigProbation(ctx, err, &log)
+	}()
+
+	ctx, span := tracer.Start(ctx, "satellite.sync", trace.WithAttributes(
+		attribute.String("satellite.state_url", satelliteStateURL),
+	))
+	started := time.Now()
+	defer func() {
+		f.recordSyncResult(started, err)
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
 	satelliteState, err := f.fetchSatelliteRootState(ctx, satelliteStateURL, srcUsername, srcPassword, useUnsecure, &log)
 	if err != nil {
@@ -115,23 +164,28 @@ func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 
 	changed := f.updateStateMap(satelliteState.States)
 
+	// The group goroutines work on a snapshot; the admin API reads the state
+	// map under f.mu while they run.
+	f.mu.Lock()
 	// Persist state if groups were added, removed, or swapped
 	if f.stateFilePath != "" && changed {
-		if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest); err != nil {
+		if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest, f.currentConfigRevision, f.probation.State()); err != nil {
 			log.Warn().Err(err).Msg("Failed to persist state after group changes")
 		}
 	}
+	groups := slices.Clone(f.stateMap)
+	f.mu.Unlock()
 
 	// Create channels for results
-	stateFetcherResults := make(chan StateFetcherResult, len(f.stateMap))
+	stateFetcherResults := make(chan StateFetcherResult, len(groups))
 	configFetcherResult := make(chan ConfigFetcherResult, 1)
 
 	// Launch state fetcher goroutines
-	for i := range f.stateMap {
-		go func(index int) {
-			result := f.processGroupState(ctx, index, srcUsername, srcPassword, useUnsecure, replicator, &log)
+	for i, group := range groups {
+		go func(index int, group StateMap) {
+			result := f.processGroupState(ctx, index, group, srcUsername, srcPassword, useUnsecure, replicator, &log)
 			stateFetcherResults <- result
-		}(i)
+		}(i, group)
 	}
 
 	// Launch config fetcher goroutine
@@ -140,10 +194,15 @@ func (f *FetchAndReplicateStateProcess) Execute(ctx context.Context) error {
 		configFetcherResult <- result
 	}()
 
-	return f.collectResults(ctx, stateFetcherResults, configFetcherResult, len(f.stateMap), &log)
+	err = f.collectResults(ctx, stateFetcherResults, configFetcherResult, len(groups), &log)
+	f.prewarm(ctx, sourceURL, &log)
+	return err
 }
 
 func (f *FetchAndReplicateStateProcess) updateStateMap(states []string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	var newStates []string
 	for _, state := range states {
 		found := false
@@ -166,6 +225,8 @@ func (f *FetchAndReplicateStateProcess) updateStateMap(states []string) bool {
 			updatedStateMap = append(updatedStateMap, stateMap)
 		} else {
 			removed++
+			delete(f.groupSync, stateMap.url)
+			metrics.ForgetGroup(GroupNameFromURL(stateMap.url))
 		}
 	}
 
@@ -244,6 +305,10 @@ func (f *FetchAndReplicateStateProcess) IsComplete() bool {
 	return false
 }
 
+func (f *FetchAndReplicateStateProcess) Dependencies() []scheduler.Dependency {
+	return []scheduler.Dependency{scheduler.DependencyHarbor, scheduler.DependencyLocalRegistry}
+}
+
 func (f *FetchAndReplicateStateProcess) CanExecute(satelliteStateURL, remoteURL, srcURL, srcUsername, srcPassword string) (bool, string) {
 	checks := []struct {
 		condition bool
@@ -278,6 +343,12 @@ func (f *FetchAndReplicateStateProcess) collectResults(
 	log *zerolog.Logger,
 ) error {
 	var allErrors []string
+	// The longest Retry-After any upstream asked for; the scheduler holds the
+	// next sync for that long.
+	var retryAfter time.Duration
+	// Every dependency blamed by a group or the config, so the scheduler can
+	// trip their breakers.
+	var failed []scheduler.Dependency
 	receivedStateFetchers := 0
 	receivedConfigFetcher := false
 
@@ -289,12 +360,19 @@ func (f *FetchAndReplicateStateProcess) collectResults(
 
 		case stateResult := <-stateFetcherResults:
 			receivedStateFetchers++
+			if !stateResult.Cancelled {
+				f.recordGroupResult(stateResult.URL, stateResult.Error)
+			}
 
 			switch {
 			case stateResult.Cancelled:
 				log.Debug().Int("goroutine-id", stateResult.Index).Str("group", stateResult.URL).Msg("State fetcher cancelled")
 			case stateResult.Error != nil:
 				allErrors = append(allErrors, stateResult.Error.Error())
+				if after, ok := scheduler.RetryAfter(stateResult.Error); ok {
+					retryAfter = max(retryAfter, after)
+				}
+				failed = append(failed, scheduler.FailedDependencies(stateResult.Error)...)
 				log.Error().Err(stateResult.Error).Int("goroutine-id", stateResult.Index).Str("group", stateResult.URL).Msg("State fetcher failed")
 			default:
 				log.Info().Int("goroutine-id", stateResult.Index).Str("group", stateResult.URL).Msgf("State fetcher completed successfully for %s", stateResult.URL)
@@ -308,6 +386,10 @@ func (f *FetchAndReplicateStateProcess) collectResults(
 				log.Debug().Msg("Config fetcher cancelled")
 			case configResult.Error != nil:
 				allErrors = append(allErrors, configResult.Error.Error())
+				if after, ok := scheduler.RetryAfter(configResult.Error); ok {
+					retryAfter = max(retryAfter, after)
+				}
+				failed = append(failed, scheduler.FailedDependencies(configResult.Error)...)
 				log.Error().Err(configResult.Error).Msg("Config fetcher failed")
 			default:
 				log.Info().Str("digest", configResult.ConfigDigest).Msg("Config fetcher completed successfully")
@@ -320,7 +402,14 @@ func (f *FetchAndReplicateStateProcess) collectResults(
 	}
 
 	if len(allErrors) > 0 {
-		return fmt.Errorf("the following errors occurred while reconciling satellite state: %s", strings.Join(allErrors, "; "))
+		var err error = fmt.Errorf("the following errors occurred while reconciling satellite state: %s", strings.Join(allErrors, "; "))
+		for _, dep := range slices.Compact(slices.Sorted(slices.Values(failed))) {
+			err = scheduler.DependencyFailure(dep, err)
+		}
+		if retryAfter > 0 {
+			return &scheduler.RetryAfterError{Err: err, After: retryAfter}
+		}
+		return err
 	}
 
 	return nil
@@ -358,17 +447,25 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 		return result
 	}
 
+	if rejected := f.probation.Rejected(); rejected != "" && configDigest == rejected {
+		configFetcherLog.Warn().Str("Rejected Digest", rejected).
+			Msgf("The upstream config was rolled back earlier, keeping the config with digest %s until Ground Control publishes a new one", f.currentConfigDigest)
+		result.ConfigDigest = f.currentConfigDigest
+		return result
+	}
+
 	if configDigest != f.currentConfigDigest {
 		configFetcherLog.Info().Str("Current Digest", f.currentConfigDigest).Str("Remote Digest", configDigest).Msgf("The upstream config has changes, reconciling the satellite accordingly")
 
-		remoteConfig := config.Config{}
-		if err := configStateFetcher.FetchStateArtifact(ctx, &remoteConfig, &configFetcherLog); err != nil {
+		artifact := ConfigArtifact{}
+		if err := configStateFetcher.FetchStateArtifact(ctx, &artifact, &configFetcherLog); err != nil {
 			configFetcherLog.Error().Err(err).
 				Msgf("Error fetching new config's state artifact from url: %s, continuing execution with the previous config with digest %s", configURL, f.currentConfigDigest)
 			result.Error = fmt.Errorf("failed to fetch config artifact from %s: %w", configURL, err)
 			return result
 		}
 
+		remoteConfig := artifact.Config
 		remoteConfig.StateConfig = f.cm.GetStateConfig()
 		remoteConfig.AppConfig.HarborRegistryURL = f.cm.GetHarborRegistryURL()
 		validatedRemoteConfig, warnings, err := config.ValidateAndEnforceDefaults(&remoteConfig, f.cm.DefaultGroundControlURL)
@@ -397,15 +494,20 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 			return result
 		}
 		f.mu.Lock()
+		if !f.cm.GetConfigRollbackConfig().Disabled {
+			f.probation.Start(configDigest, f.currentConfigDigest, f.currentConfigRevision)
+		}
 		f.currentConfigDigest = configDigest
+		f.currentConfigRevision = artifact.Revision
 		if f.stateFilePath != "" {
-			if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest); err != nil {
+			if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest, f.currentConfigRevision, f.probation.State()); err != nil {
 				configFetcherLog.Warn().Err(err).Msg("Failed to persist state to disk")
 			}
 		}
 		f.mu.Unlock()
 	}
 
+	metrics.ObserveConfigDigest(configDigest)
 	result.ConfigDigest = configDigest
 	return result
 }
@@ -413,6 +515,7 @@ func (f *FetchAndReplicateStateProcess) reconcileRemoteConfig(
 func (f *FetchAndReplicateStateProcess) processGroupState(
 	ctx context.Context,
 	index int,
+	group StateMap,
 	srcUsername, srcPassword string,
 	useUnsecure bool,
 	replicator Replicator,
@@ -420,69 +523,91 @@ func (f *FetchAndReplicateStateProcess) processGroupState(
 ) StateFetcherResult {
 	stateFetcherLog := log.With().
 		Str("sub-process", "state-fetcher").
-		Str("group", f.stateMap[index].url).
+		Str("group", group.url).
 		Int("goroutine-id", index).
 		Logger()
 
 	result := StateFetcherResult{
 		Index: index,
-		URL:   f.stateMap[index].url,
+		URL:   group.url,
 	}
 
-	groupURL := f.stateMap[index].url
+	groupURL := group.url
 	if override := f.cm.GetHarborRegistryURL(); override != "" {
 		if replaced, err := config.ReplaceURLHost(groupURL, override); err == nil {
 			groupURL = replaced
 		}
 	}
 
+	ctx, span := tracer.Start(ctx, "satellite.sync.group", trace.WithAttributes(
+		attribute.String("satellite.group", GroupNameFromURL(group.url)),
+		attribute.String("satellite.state_url", groupURL),
+	))
+	defer func() {
+		tracing.RecordError(span, result.Error)
+		span.End()
+	}()
+
 	stateFetcherLog.Info().Msgf("Processing state for %s", groupURL)
 
 	groupStateFetcher, err := getStateFetcherForInput(groupURL, srcUsername, srcPassword, useUnsecure, &stateFetcherLog)
 	if err != nil {
 		stateFetcherLog.Error().Err(err).Msg("Error processing input")
-		result.Error = fmt.Errorf("failed to create state fetcher for %s: %w", f.stateMap[index].url, err)
+		result.Error = fmt.Errorf("failed to create state fetcher for %s: %w", group.url, err)
 		return result
 	}
 
 	newStateFetched, err := f.FetchAndProcessState(ctx, groupStateFetcher, &stateFetcherLog)
 	if err != nil {
 		stateFetcherLog.Error().Err(err).Msg("Error fetching state")
-		result.Error = fmt.Errorf("failed to fetch state for %s: %w", f.stateMap[index].url, err)
+		result.Error = fmt.Errorf("failed to fetch state for %s: %w", group.url, err)
 		return result
 	}
-	stateFetcherLog.Info().Msgf("State fetched successfully for %s", f.stateMap[index].url)
+	stateFetcherLog.Info().Msgf("State fetched successfully for %s", group.url)
 
-	deleteEntity, replicateEntity, newState := f.GetChanges(*newStateFetched, &stateFetcherLog, f.stateMap[index].Entities)
+	if digest := groupStateFetcher.PulledDigest(); digest != "" {
+		metrics.ObserveGroupState(GroupNameFromURL(group.url), digest)
+	}
+
+	deleteEntity, replicateEntity, newState := f.GetChanges(*newStateFetched, &stateFetcherLog, group.Entities)
 	f.LogChanges(deleteEntity, replicateEntity, &stateFetcherLog)
 
+	// Whatever the outcome, the tags touched below are looked up again for the
+	// next heartbeat.
+	defer f.markChanged(deleteEntity, replicateEntity)
+
 	if err := replicator.DeleteReplicationEntity(ctx, deleteEntity); err != nil {
 		stateFetcherLog.Error().Err(err).Msg("Error deleting entities")
-		result.Error = fmt.Errorf("failed to delete entities for %s: %w", f.stateMap[index].url, err)
+		result.Error = fmt.Errorf("failed to delete entities for %s: %w", group.url, err)
 		return result
 	}
 
 	if err := replicator.Replicate(ctx, replicateEntity); err != nil {
 		stateFetcherLog.Error().Err(err).Msg("Error replicating state")
-		result.Error = fmt.Errorf("failed to replicate entities for %s: %w", f.stateMap[index].url, err)
+		result.Error = fmt.Errorf("failed to replicate entities for %s: %w", group.url, err)
 		return result
 	}
 
-	// Direct delivery: write tarballs to k3s/RKE2 image dir after registry push
+	// Direct delivery: hand images to the node after the registry push
 	if f.directDeliverer != nil {
 		if err := f.directDeliverer.Delete(ctx, deleteEntity); err != nil {
-			stateFetcherLog.Warn().Err(err).Msg("Direct delivery: failed to remove old tarballs")
+			stateFetcherLog.Warn().Err(err).Msg("Direct delivery: failed to remove old images")
 		}
 		if err := f.directDeliverer.Deliver(ctx, replicateEntity); err != nil {
-			stateFetcherLog.Warn().Err(err).Msg("Direct delivery: failed to write tarballs")
+			stateFetcherLog.Warn().Err(err).Msg("Direct delivery: failed to deliver images")
 		}
 	}
 
 	f.mu.Lock()
-	f.stateMap[index].State = newState
-	f.stateMap[index].Entities = FetchEntitiesFromState(newState)
+	for i := range f.stateMap {
+		if f.stateMap[i].url == group.url {
+			f.stateMap[i].State = newState
+			f.stateMap[i].Entities = FetchEntitiesFromState(newState)
+			break
+		}
+	}
 	if f.stateFilePath != "" {
-		if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest); err != nil {
+		if err := SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest, f.currentConfigRevision, f.probation.State()); err != nil {
 			stateFetcherLog.Warn().Err(err).Msg("Failed to persist state to disk")
 		}
 	}
@@ -491,6 +616,24 @@ func (f *FetchAndReplicateStateProcess) processGroupState(
 	return result
 }
 
+// Inventory returns the inventory of cached images, kept current by the
+// replicate and delete events of this process.
+func (f *FetchAndReplicateStateProcess) Inventory() *Inventory {
+	return f.inventory
+}
+
+// markChanged marks the local tags of entities as changed in the inventory.
+func (f *FetchAndReplicateStateProcess) markChanged(entities ...[]Entity) {
+	if f.inventory == nil {
+		return
+	}
+	for _, list := range entities {
+		for _, e := range list {
+			f.inventory.Changed(e.GetRepository()+"/"+e.GetName(), e.GetTag())
+		}
+	}
+}
+
 func (f *FetchAndReplicateStateProcess) fetchSatelliteRootState(
 	ctx context.Context,
 	satelliteStateURL, srcUsername, srcPassword string,
@@ -530,19 +673,36 @@ func (f *FetchAndReplicateStateProcess) setupReplication() (Replicator, string,
 		}
 	}
 
-	replicator := NewBasicReplicator(srcUsername, srcPassword, sourceURL, remoteURL, remoteUsername, remotePassword, useUnsecure)
-
-	// Set up direct delivery if enabled, clear if disabled
-	dd := f.cm.GetDirectDeliveryConfig()
-	if dd.Enabled && dd.ImageDir != "" {
-		f.directDeliverer = NewDirectDeliverer(dd.ImageDir, srcUsername, srcPassword, sourceURL, useUnsecure)
-	} else {
-		f.directDeliverer = nil
+	var localCAFile string
+	if access, ok := f.cm.GetSecureRegistryAccess(); ok {
+		localCAFile = access.CAFile
+	}
+	replicator := NewBasicReplicatorWithLocalCA(srcUsername, srcPassword, sourceURL, remoteURL, remoteUsername, remotePassword, useUnsecure, localCAFile)
+	if f.inventory != nil {
+		replicator.(*BasicReplicator).cachedSize = f.inventory.Size
 	}
 
 	return replicator, sourceURL, srcUsername, srcPassword, remoteURL, useUnsecure, satelliteStateURL
 }
 
+// newDirectDeliverer builds the deliverer for dd, nil when direct delivery is
+// off or cannot be set up.
+func (f *FetchAndReplicateStateProcess) newDirectDeliverer(dd config.DirectDeliveryConfig, sourceURL, srcUsername, srcPassword string, useUnsecure bool, log *zerolog.Logger) *DirectDeliverer {
+	if !dd.Enabled {
+		return nil
+	}
+	stateDir := ""
+	if f.stateFilePath != "" {
+		stateDir = filepath.Dir(f.stateFilePath)
+	}
+	deliverer, err := NewDirectDeliverer(dd, stateDir, srcUsername, srcPassword, sourceURL, useUnsecure)
+	if err != nil {
+		log.Warn().Err(err).Msg("Direct delivery disabled")
+		return nil
+	}
+	return deliverer
+}
+
 func (f *FetchAndReplicateStateProcess) start() {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -565,7 +725,7 @@ func (f *FetchAndReplicateStateProcess) PersistState() error {
 	if f.stateFilePath == "" {
 		return nil
 	}
-	return SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest)
+	return SaveState(f.stateFilePath, f.stateMap, f.currentConfigDigest, f.currentConfigRevision, f.probation.State())
 }
 
 func (f *FetchAndReplicateStateProcess) RemoveNullTagArtifacts(state StateReader) StateReader {
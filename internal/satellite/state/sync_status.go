@@ -0,0 +1,176 @@
+package state
+
+import (
+	"strings"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/satellite/metrics"
+)
+
+// SyncResult describes the outcome of the most recent state replication cycle.
+type SyncResult struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// OK reports whether a cycle has completed and it finished without errors.
+func (r SyncResult) OK() bool {
+	return !r.Time.IsZero() && r.Error == ""
+}
+
+// GroupStatus describes the replication status of a single group.
+type GroupStatus struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	EntityCount int       `json:"entity_count"`
+	Synced      bool      `json:"synced"`
+	LastSync    time.Time `json:"last_sync,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// groupSyncStatus is the per-group bookkeeping kept by the replication process.
+type groupSyncStatus struct {
+	lastSync  time.Time
+	lastError string
+}
+
+// GroupNameFromURL extracts the group name from a group state artifact URL of
+// the form <registry>/satellite/group-state/<group>/state:<tag>. URLs that do
+// not follow that layout are returned unchanged.
+func GroupNameFromURL(url string) string {
+	const marker = "/group-state/"
+	i := strings.Index(url, marker)
+	if i < 0 {
+		return url
+	}
+	rest := url[i+len(marker):]
+	if j := strings.Index(rest, "/"); j >= 0 {
+		return rest[:j]
+	}
+	return rest
+}
+
+// Snapshot returns the current groups, entities, config digest and revision in
+// the same shape that is persisted to disk.
+func (f *FetchAndReplicateStateProcess) Snapshot() PersistedState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	snapshot := PersistedState{
+		ConfigDigest:   f.currentConfigDigest,
+		ConfigRevision: f.currentConfigRevision,
+		Groups:         make([]PersistedGroupState, 0, len(f.stateMap)),
+	}
+	for _, sm := range f.stateMap {
+		entities := make([]Entity, len(sm.Entities))
+		copy(entities, sm.Entities)
+		snapshot.Groups = append(snapshot.Groups, PersistedGroupState{
+			URL:      sm.url,
+			Entities: entities,
+		})
+	}
+	return snapshot
+}
+
+// ConfigDigest returns the digest of the config currently applied.
+func (f *FetchAndReplicateStateProcess) ConfigDigest() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.currentConfigDigest
+}
+
+// ConfigRevision returns the Ground Control revision of the config currently
+// applied, zero when unknown.
+func (f *FetchAndReplicateStateProcess) ConfigRevision() int32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.currentConfigRevision
+}
+
+// LastSync returns the outcome of the most recent replication cycle.
+func (f *FetchAndReplicateStateProcess) LastSync() SyncResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastSync
+}
+
+// GroupStatuses returns the replication status of every known group.
+func (f *FetchAndReplicateStateProcess) GroupStatuses() []GroupStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	statuses := make([]GroupStatus, 0, len(f.stateMap))
+	for _, sm := range f.stateMap {
+		statuses = append(statuses, f.groupStatusLocked(sm))
+	}
+	return statuses
+}
+
+// GroupStatus returns the replication status of the named group. The name is
+// matched against the group name embedded in the state URL.
+func (f *FetchAndReplicateStateProcess) GroupStatus(name string) (GroupStatus, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, sm := range f.stateMap {
+		if GroupNameFromURL(sm.url) == name {
+			return f.groupStatusLocked(sm), true
+		}
+	}
+	return GroupStatus{}, false
+}
+
+// groupStatusLocked builds the status for a group. A group counts as synced once
+// its latest state was replicated without errors. Must be called with f.mu held.
+func (f *FetchAndReplicateStateProcess) groupStatusLocked(sm StateMap) GroupStatus {
+	gs := f.groupSync[sm.url]
+	return GroupStatus{
+		Name:        GroupNameFromURL(sm.url),
+		URL:         sm.url,
+		EntityCount: len(sm.Entities),
+		Synced:      !gs.lastSync.IsZero() && gs.lastError == "",
+		LastSync:    gs.lastSync,
+		LastError:   gs.lastError,
+	}
+}
+
+func (f *FetchAndReplicateStateProcess) recordGroupResult(url string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.groupSync == nil {
+		f.groupSync = make(map[string]groupSyncStatus)
+	}
+	gs := groupSyncStatus{lastSync: time.Now()}
+	if err != nil {
+		gs.lastError = err.Error()
+	}
+	f.groupSync[url] = gs
+
+	group := GroupNameFromURL(url)
+	synced := 0.0
+	if err == nil {
+		synced = 1
+	}
+	metrics.GroupSynced.WithLabelValues(group).Set(synced)
+	for _, sm := range f.stateMap {
+		if sm.url == url {
+			metrics.GroupEntities.WithLabelValues(group).Set(float64(len(sm.Entities)))
+			break
+		}
+	}
+}
+
+func (f *FetchAndReplicateStateProcess) recordSyncResult(started time.Time, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastSync = SyncResult{
+		Time:     time.Now(),
+		Duration: time.Since(started),
+	}
+	if err != nil {
+		f.lastSync.Error = err.Error()
+	}
+}
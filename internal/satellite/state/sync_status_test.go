@@ -0,0 +1,104 @@
+package state
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupNameFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"harbor.example.com/satellite/group-state/edge/state:latest", "edge"},
+		{"http://10.0.0.1:8080/satellite/group-state/group-a/state:latest", "group-a"},
+		{"harbor.example.com/satellite/group-state/edge", "edge"},
+		{"harbor.example.com/other/state:latest", "harbor.example.com/other/state:latest"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, GroupNameFromURL(tt.url))
+	}
+}
+
+func TestGroupStatus(t *testing.T) {
+	edge := "harbor/satellite/group-state/edge/state:latest"
+	core := "harbor/satellite/group-state/core/state:latest"
+	f := &FetchAndReplicateStateProcess{
+		stateMap: []StateMap{
+			{url: edge, Entities: []Entity{{Name: "nginx", Tag: "latest"}}},
+			{url: core},
+		},
+	}
+
+	status, ok := f.GroupStatus("edge")
+	require.True(t, ok)
+	require.False(t, status.Synced, "group without a completed cycle must not be synced")
+	require.Equal(t, 1, status.EntityCount)
+
+	f.recordGroupResult(edge, nil)
+	f.recordGroupResult(core, errors.New("pull failed"))
+
+	status, ok = f.GroupStatus("edge")
+	require.True(t, ok)
+	require.True(t, status.Synced)
+
+	status, ok = f.GroupStatus("core")
+	require.True(t, ok)
+	require.False(t, status.Synced)
+	require.Equal(t, "pull failed", status.LastError)
+
+	_, ok = f.GroupStatus("missing")
+	require.False(t, ok)
+	require.Len(t, f.GroupStatuses(), 2)
+}
+
+func TestSnapshotIsIndependentCopy(t *testing.T) {
+	f := &FetchAndReplicateStateProcess{
+		currentConfigDigest: "sha256:abc",
+		stateMap:            []StateMap{{url: "u", Entities: []Entity{{Name: "a"}}}},
+	}
+
+	snap := f.Snapshot()
+	require.Equal(t, "sha256:abc", snap.ConfigDigest)
+	snap.Groups[0].Entities[0].Name = "mutated"
+	require.Equal(t, "a", f.stateMap[0].Entities[0].Name)
+}
+
+func TestUpdateStateMapWhileReportingStatus(t *testing.T) {
+	edge := "harbor/satellite/group-state/edge/state:latest"
+	core := "harbor/satellite/group-state/core/state:latest"
+	f := &FetchAndReplicateStateProcess{stateMap: NewStateMap([]string{edge})}
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		<-start
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				f.updateStateMap([]string{edge, core})
+			} else {
+				f.updateStateMap([]string{edge})
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		<-start
+		for i := 0; i < 1000; i++ {
+			f.recordGroupResult(core, nil)
+			f.GroupStatuses()
+			f.Snapshot()
+		}
+	}()
+	close(start)
+	wg.Wait()
+
+	require.Len(t, f.GroupStatuses(), 1)
+	_, ok := f.GroupStatus("core")
+	require.False(t, ok)
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
 	"github.com/spiffe/go-spiffe/v2/spiffeid"
 	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
 	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
@@ -104,6 +105,23 @@ func (c *Client) GetSVID() (*x509svid.SVID, error) {
 	return c.x509Source.GetX509SVID()
 }
 
+// GetX509Bundle returns the X.509 trust bundle of this workload's trust
+// domain.
+func (c *Client) GetX509Bundle() (*x509bundle.Bundle, error) {
+	svid, err := c.GetSVID()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed || c.x509Source == nil {
+		return nil, fmt.Errorf("client not connected")
+	}
+	return c.x509Source.GetX509BundleForTrustDomain(svid.ID.TrustDomain())
+}
+
 // GetSPIFFEID returns the SPIFFE ID of this workload.
 func (c *Client) GetSPIFFEID() (spiffeid.ID, error) {
 	svid, err := c.GetSVID()
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
 	"github.com/spiffe/go-spiffe/v2/spiffeid"
 	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
 )
@@ -40,6 +41,10 @@ func (c *Client) GetSVID() (*x509svid.SVID, error) {
 	return nil, ErrSPIFFENotAvailable
 }
 
+func (c *Client) GetX509Bundle() (*x509bundle.Bundle, error) {
+	return nil, ErrSPIFFENotAvailable
+}
+
 func (c *Client) GetSPIFFEID() (spiffeid.ID, error) {
 	return spiffeid.ID{}, ErrSPIFFENotAvailable
 }
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware continues the caller's trace for every request handled by a mux
+// router. Spans are named after the matched route template rather than the raw
+// path. It must run after the request ID middleware: the correlation ID that
+// middleware echoes on the response is recorded on the span, so an audit event
+// and its trace can be joined.
+func Middleware(tracerName string) mux.MiddlewareFunc {
+	tracer := Tracer(tracerName)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := ExtractHeaders(r.Context(), r.Header)
+
+			route := r.URL.Path
+			if cur := mux.CurrentRoute(r); cur != nil {
+				if tmpl, err := cur.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", r.Method, route),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", route),
+				),
+			)
+			defer span.End()
+
+			if rid := w.Header().Get(RequestIDHeader); rid != "" {
+				span.SetAttributes(attribute.String("http.request_id", rid))
+			}
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+			if rec.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(rec.status))
+			}
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,121 @@
+// Package tracing wires OpenTelemetry tracing for the satellite and Ground
+// Control. Spans are exported over OTLP/HTTP to the same collector that
+// receives audit logs, and W3C trace context is propagated on every
+// satellite-to-Ground-Control request so a sync cycle can be followed into the
+// handlers it calls.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// otlpTracesPath is appended to the collector base URL when the
+	// configured endpoint carries no path of its own.
+	otlpTracesPath = "/v1/traces"
+
+	// RequestIDHeader carries the correlation ID Ground Control stores on
+	// the request context and in audit events.
+	RequestIDHeader = "X-Request-ID"
+)
+
+// Config enables trace export to an OTLP/HTTP collector. Endpoint is the
+// collector base URL (e.g. "http://127.0.0.1:4318"), matching the audit otel
+// endpoint.
+type Config struct {
+	Enabled     bool
+	Endpoint    string
+	ServiceName string
+}
+
+// ShutdownFunc flushes buffered spans and stops the exporter.
+type ShutdownFunc func(context.Context) error
+
+// Setup installs the global tracer provider and W3C propagator. When tracing
+// is disabled the propagator is still installed so inbound trace context is
+// honoured, and spans are recorded by the no-op provider.
+func Setup(ctx context.Context, cfg Config) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint, err := tracesEndpoint(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP trace exporter: %w", err)
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(5*time.Second)),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns a tracer from the global provider.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// InjectHeaders writes the W3C trace context of ctx into h. When ctx carries a
+// sampled span its trace ID is also sent as X-Request-ID, so Ground Control's
+// audit events and the trace share one correlation ID.
+func InjectHeaders(ctx context.Context, h http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() && h.Get(RequestIDHeader) == "" {
+		h.Set(RequestIDHeader, sc.TraceID().String())
+	}
+}
+
+// ExtractHeaders returns ctx extended with the trace context found in h.
+func ExtractHeaders(ctx context.Context, h http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// RecordError marks the span as failed when err is non-nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func tracesEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse otel endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("otel endpoint %q must be an http(s) URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+	return u.String(), nil
+}
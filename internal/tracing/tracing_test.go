@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	_, err := Setup(context.Background(), Config{})
+	require.NoError(t, err)
+
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func TestInjectHeadersPropagatesTraceContext(t *testing.T) {
+	newRecorder(t)
+
+	ctx, span := Tracer("test").Start(context.Background(), "client")
+	defer span.End()
+
+	h := http.Header{}
+	InjectHeaders(ctx, h)
+
+	require.NotEmpty(t, h.Get("traceparent"))
+	require.Equal(t, span.SpanContext().TraceID().String(), h.Get(RequestIDHeader))
+
+	extracted := trace.SpanContextFromContext(ExtractHeaders(context.Background(), h))
+	require.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+}
+
+func TestInjectHeadersKeepsExistingRequestID(t *testing.T) {
+	newRecorder(t)
+
+	ctx, span := Tracer("test").Start(context.Background(), "client")
+	defer span.End()
+
+	h := http.Header{}
+	h.Set(RequestIDHeader, "caller-id")
+	InjectHeaders(ctx, h)
+	require.Equal(t, "caller-id", h.Get(RequestIDHeader))
+}
+
+func TestInjectHeadersWithoutSpan(t *testing.T) {
+	newRecorder(t)
+
+	h := http.Header{}
+	InjectHeaders(context.Background(), h)
+	require.Empty(t, h.Get("traceparent"))
+	require.Empty(t, h.Get(RequestIDHeader))
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	rec := newRecorder(t)
+
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(RequestIDHeader, r.Header.Get(RequestIDHeader))
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.Use(Middleware("test"))
+	r.HandleFunc("/satellites/{satellite}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods("POST")
+
+	ctx, parent := Tracer("test").Start(context.Background(), "client")
+	req := httptest.NewRequest(http.MethodPost, "/satellites/edge-1", nil)
+	InjectHeaders(ctx, req.Header)
+	parent.End()
+
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	require.Len(t, spans, 2)
+	server := spans[1]
+	require.Equal(t, "POST /satellites/{satellite}", server.Name())
+	require.Equal(t, trace.SpanKindServer, server.SpanKind())
+	require.Equal(t, parent.SpanContext().TraceID(), server.SpanContext().TraceID())
+	require.Equal(t, parent.SpanContext().SpanID(), server.Parent().SpanID())
+
+	attrs := map[string]string{}
+	for _, kv := range server.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	require.Equal(t, parent.SpanContext().TraceID().String(), attrs["http.request_id"])
+	require.Equal(t, "500", attrs["http.response.status_code"])
+}
+
+func TestTracesEndpoint(t *testing.T) {
+	for in, want := range map[string]string{
+		"http://127.0.0.1:4318":           "http://127.0.0.1:4318/v1/traces",
+		"http://127.0.0.1:4318/":          "http://127.0.0.1:4318/v1/traces",
+		"https://collector/custom/traces": "https://collector/custom/traces",
+	} {
+		got, err := tracesEndpoint(in)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+
+	_, err := tracesEndpoint("127.0.0.1:4318")
+	require.Error(t, err)
+}
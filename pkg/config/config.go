@@ -2,6 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -33,12 +37,70 @@ type MetricsConfig struct {
 	CollectCPU     bool `json:"collect_cpu,omitempty"`
 	CollectMemory  bool `json:"collect_memory,omitempty"`
 	CollectStorage bool `json:"collect_storage,omitempty"`
+	// ListenAddress serves Prometheus metrics on /metrics when set (e.g. ":9464").
+	ListenAddress string `json:"listen_address,omitempty"`
 }
 
 type RegistryFallbackConfig struct {
 	Enabled    bool     `json:"enabled,omitempty"`
 	Registries []string `json:"registries,omitempty"`
 	Runtimes   []string `json:"runtimes,omitempty"`
+	// LocalMirror tunes how runtimes reach the local registry. Its URL is
+	// ignored, the satellite knows the address of its registry.
+	LocalMirror MirrorEndpointConfig `json:"local_mirror,omitempty"`
+	// FallbackMirrors are tried in order after the local registry and before
+	// the upstream registry.
+	FallbackMirrors []MirrorEndpointConfig `json:"fallback_mirrors,omitempty"`
+	// Reload makes the runtimes pick up their mirror configs right after
+	// they are written, by reloading or restarting their daemons.
+	Reload bool `json:"reload,omitempty"`
+	// Verify pulls a probe image through every configured runtime once the
+	// local registry is up, to check that the runtime uses the mirror.
+	Verify bool `json:"verify,omitempty"`
+}
+
+// Equal reports whether two fallback configs list the same registries,
+// runtimes and mirrors in the same order, with the same reload and verify
+// settings.
+func (r RegistryFallbackConfig) Equal(o RegistryFallbackConfig) bool {
+	return r.Enabled == o.Enabled &&
+		r.Reload == o.Reload &&
+		r.Verify == o.Verify &&
+		slices.Equal(r.Registries, o.Registries) &&
+		slices.Equal(r.Runtimes, o.Runtimes) &&
+		r.LocalMirror.Equal(o.LocalMirror) &&
+		slices.EqualFunc(r.FallbackMirrors, o.FallbackMirrors, MirrorEndpointConfig.Equal)
+}
+
+// MirrorEndpointConfig is how container runtimes reach a registry mirror.
+// File paths are read by the runtimes, so they are paths on the node.
+type MirrorEndpointConfig struct {
+	// URL is the scheme and host of the mirror, e.g. "https://mirror.corp:5000".
+	URL string `json:"url,omitempty"`
+	// PathPrefix is the namespace the mirror serves the upstream registry
+	// under, below /v2, e.g. "/dockerhub" for mirrors that serve several
+	// registries. Images are pulled from /v2/dockerhub/<repository>.
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// CAFile is the CA bundle the certificate of the mirror is verified with.
+	CAFile string `json:"ca_file,omitempty"`
+	// ClientCertFile and ClientKeyFile are the certificate runtimes present
+	// to a mirror that requires mutual TLS.
+	ClientCertFile string `json:"client_cert_file,omitempty"`
+	ClientKeyFile  string `json:"client_key_file,omitempty"`
+	SkipVerify     bool   `json:"skip_verify,omitempty"`
+	// Headers are sent with every request to the mirror. Only containerd
+	// supports them.
+	Headers map[string][]string `json:"headers,omitempty"`
+}
+
+func (m MirrorEndpointConfig) Equal(o MirrorEndpointConfig) bool {
+	return m.URL == o.URL &&
+		m.PathPrefix == o.PathPrefix &&
+		m.CAFile == o.CAFile &&
+		m.ClientCertFile == o.ClientCertFile &&
+		m.ClientKeyFile == o.ClientKeyFile &&
+		m.SkipVerify == o.SkipVerify &&
+		maps.EqualFunc(m.Headers, o.Headers, slices.Equal[[]string])
 }
 
 // AuditConfig controls the security-event audit log. When Enabled is false
@@ -52,10 +114,13 @@ type AuditConfig struct {
 
 // OtelAudit configures the OTLP/HTTP log export transport. Endpoint is the
 // collector base URL (e.g. "http://127.0.0.1:4318"); the standard /v1/logs
-// path is appended when the URL carries no path of its own.
+// path is appended when the URL carries no path of its own. Traces exports
+// sync and heartbeat spans to the same collector (on /v1/traces) and does not
+// depend on audit logging being enabled.
 type OtelAudit struct {
 	Enabled  bool   `json:"enabled,omitempty"`
 	Endpoint string `json:"endpoint,omitempty"`
+	Traces   bool   `json:"traces,omitempty"`
 }
 
 // SyslogAudit configures the syslog transport. Target picks one of three sinks
@@ -178,13 +243,188 @@ func (f SyslogAuditFile) equal(o SyslogAuditFile) bool {
 		f.CompressOrDefault() == o.CompressOrDefault()
 }
 
-// DirectDeliveryConfig holds settings for writing image tarballs directly
-// to a Kubernetes node's image directory (e.g. k3s/RKE2 agent images dir).
-// This is an experimental feature that enables satellite to deliver images
-// without requiring pods to pull from a registry.
+// DirectDeliveryConfig holds settings for delivering images straight to a
+// Kubernetes node, either as tarballs in an image directory (e.g. the
+// k3s/RKE2 agent images dir) or into the image store of the container
+// runtime. This is an experimental feature that enables satellite to deliver
+// images without requiring pods to pull from a registry.
 type DirectDeliveryConfig struct {
-	Enabled  bool   `json:"enabled,omitempty"`
+	Enabled bool `json:"enabled,omitempty"`
+	// Backend is where images go: "image_dir" (the default) writes tarballs
+	// for k3s/RKE2 to import, "containerd" and "docker" load images into the
+	// runtime over its socket, and "containers_storage" loads them into the
+	// store CRI-O and Podman share, which needs podman in the PATH.
+	Backend  string `json:"backend,omitempty"`
 	ImageDir string `json:"image_dir,omitempty"` // auto-detected if empty
+	// Socket overrides the runtime socket of the containerd and docker
+	// backends.
+	Socket string `json:"socket,omitempty"`
+	// Namespace is the containerd namespace images are loaded into, "k8s.io"
+	// (the one the kubelet uses) by default.
+	Namespace string `json:"namespace,omitempty"`
+	// Snapshotter is the containerd snapshotter images are unpacked with,
+	// "overlayfs" by default.
+	Snapshotter string `json:"snapshotter,omitempty"`
+	// StorageRoot is the graph root of containers/storage, the default of
+	// the host's storage.conf when empty.
+	StorageRoot string `json:"storage_root,omitempty"`
+}
+
+// BackendOrDefault returns the configured backend, or the image directory
+// when unset.
+func (d DirectDeliveryConfig) BackendOrDefault() string {
+	if d.Backend == "" {
+		return DeliveryBackendImageDir
+	}
+
+	return d.Backend
+}
+
+// PrewarmConfig controls pulling replicated images into the container
+// runtime through its CRI socket, so the first pod start after a rollout
+// finds them on disk instead of waiting on a pull from the satellite. Node
+// agents on other nodes fetch the same image list from the satellite and
+// report back what their runtime holds.
+type PrewarmConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// CRIEndpoint is the CRI socket of the local runtime, e.g.
+	// "unix:///run/containerd/containerd.sock". The well-known containerd,
+	// k3s and CRI-O sockets are tried when empty.
+	CRIEndpoint string `json:"cri_endpoint,omitempty"`
+	// NodeName names the local node in warm status reports, the hostname by
+	// default.
+	NodeName string `json:"node_name,omitempty"`
+	// ListenAddress serves the node agent API when set (e.g. ":9465").
+	ListenAddress string `json:"listen_address,omitempty"`
+	// PullTimeout bounds a single image pull, 10m by default.
+	PullTimeout string `json:"pull_timeout,omitempty"`
+}
+
+// PullTimeoutOrDefault returns the configured pull timeout, or the default
+// when unset or unparsable.
+func (p PrewarmConfig) PullTimeoutOrDefault() time.Duration {
+	if d, err := time.ParseDuration(p.PullTimeout); err == nil && d > 0 {
+		return d
+	}
+
+	return DefaultPrewarmPullTimeout
+}
+
+// NodeConfigConfig publishes the registry fallback settings of the satellite
+// to a ConfigMap, from which node agents running as a DaemonSet configure the
+// container runtimes of every node in the cluster and report back through
+// the node agent API.
+type NodeConfigConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// ConfigMap is the "namespace/name" of the ConfigMap, or only its name
+	// in the namespace of the satellite pod. "harbor-satellite-node-config"
+	// by default.
+	ConfigMap string `json:"config_map,omitempty"`
+	// MirrorAddress is where the runtimes of the nodes reach the local
+	// registry, e.g. the ClusterIP and port of its Service. Runtimes do not
+	// resolve cluster DNS names.
+	MirrorAddress string `json:"mirror_address,omitempty"`
+	// ReportURL is where node agents reach the node agent API served on
+	// prewarm.listen_address, e.g. "http://harbor-satellite.edge.svc:9465".
+	ReportURL string `json:"report_url,omitempty"`
+}
+
+// ConfigMapOrDefault returns the namespace, empty for the namespace of the
+// satellite pod, and the name of the ConfigMap.
+func (n NodeConfigConfig) ConfigMapOrDefault() (string, string) {
+	if namespace, name, ok := strings.Cut(n.ConfigMap, "/"); ok {
+		return namespace, name
+	}
+	if n.ConfigMap != "" {
+		return "", n.ConfigMap
+	}
+
+	return "", DefaultNodeConfigMap
+}
+
+// ConfigRollbackConfig controls the probation period that follows a config
+// delivered by Ground Control. If the satellite turns unhealthy during
+// probation it restores the previous config and refuses the rejected one
+// until Ground Control publishes a newer config.
+type ConfigRollbackConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// ProbationCycles is the number of state replication cycles a new config
+	// stays on probation.
+	ProbationCycles int `json:"probation_cycles,omitempty"`
+	// FailureThreshold is the number of consecutive unhealthy cycles that
+	// trigger a rollback.
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+}
+
+// ProbationCyclesOrDefault returns the configured probation length, or the
+// default when unset.
+func (c ConfigRollbackConfig) ProbationCyclesOrDefault() int {
+	if c.ProbationCycles <= 0 {
+		return DefaultConfigProbationCycles
+	}
+
+	return c.ProbationCycles
+}
+
+// FailureThresholdOrDefault returns the configured failure threshold, or the
+// default when unset.
+func (c ConfigRollbackConfig) FailureThresholdOrDefault() int {
+	if c.FailureThreshold <= 0 {
+		return DefaultConfigRollbackThreshold
+	}
+
+	return c.FailureThreshold
+}
+
+// SecureRegistryConfig turns on TLS and authentication for the embedded
+// registry. The satellite provisions a serving certificate and two accounts:
+// one that can push, used by the satellite itself, and a read-only one that
+// is written into the container runtime mirror configs.
+type SecureRegistryConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// CertSource is where the serving certificate comes from: "local" (the
+	// default) issues it from a CA generated on the satellite, "spiffe" from
+	// the same CA with the SPIFFE ID of the satellite added as a URI SAN.
+	CertSource string `json:"cert_source,omitempty"`
+	// Hosts are extra DNS names and IP addresses the local CA issues the
+	// certificate for, e.g. the address other nodes reach the satellite at.
+	Hosts []string `json:"hosts,omitempty"`
+	// AnonymousPull lets clients without credentials pull. Docker needs it,
+	// as the daemon cannot authenticate to registry mirrors.
+	AnonymousPull bool `json:"anonymous_pull,omitempty"`
+}
+
+// CertSourceOrDefault returns the configured certificate source, or the
+// local CA when unset.
+func (s SecureRegistryConfig) CertSourceOrDefault() string {
+	if s.CertSource == "" {
+		return SecureRegistryCertSourceLocal
+	}
+
+	return s.CertSource
+}
+
+// Equal reports whether two secure registry configs resolve to the same
+// settings.
+func (s SecureRegistryConfig) Equal(o SecureRegistryConfig) bool {
+	return s.Enabled == o.Enabled &&
+		s.CertSourceOrDefault() == o.CertSourceOrDefault() &&
+		slices.Equal(s.Hosts, o.Hosts) &&
+		s.AnonymousPull == o.AnonymousPull
+}
+
+// SecureRegistryAccess is how the satellite and the container runtimes reach
+// the secured embedded registry. It is provisioned at startup and never
+// written to the config file.
+type SecureRegistryAccess struct {
+	URL          string
+	CAFile       string
+	PushUsername string
+	PushPassword string
+	// PullUsername and PullPassword are empty when anonymous pulls are
+	// allowed.
+	PullUsername string
+	PullPassword string
 }
 
 type AppConfig struct {
@@ -194,6 +434,8 @@ type AppConfig struct {
 	StateReplicationInterval  string                 `json:"state_replication_interval,omitempty"`
 	RegisterSatelliteInterval string                 `json:"register_satellite_interval,omitempty"`
 	HeartbeatInterval         string                 `json:"heartbeat_interval,omitempty"`
+	ScheduleJitter            string                 `json:"schedule_jitter,omitempty"`
+	ScheduleSpread            string                 `json:"schedule_spread,omitempty"`
 	Metrics                   MetricsConfig          `json:"metrics,omitempty"`
 	BringOwnRegistry          bool                   `json:"bring_own_registry,omitempty"`
 	LocalRegistryCredentials  RegistryCredentials    `json:"local_registry,omitempty"`
@@ -203,7 +445,11 @@ type AppConfig struct {
 	RegistryFallback          RegistryFallbackConfig `json:"registry_fallback,omitempty"`
 	HarborRegistryURL         string                 `json:"harbor_registry_url,omitempty"`
 	DirectDelivery            DirectDeliveryConfig   `json:"direct_delivery,omitempty"`
+	Prewarm                   PrewarmConfig          `json:"prewarm,omitempty"`
+	NodeConfig                NodeConfigConfig       `json:"node_config,omitempty"`
 	Audit                     AuditConfig            `json:"audit,omitempty"`
+	ConfigRollback            ConfigRollbackConfig   `json:"config_rollback,omitempty"`
+	SecureRegistry            SecureRegistryConfig   `json:"secure_registry,omitempty"`
 }
 
 type StateConfig struct {
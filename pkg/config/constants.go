@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // Job names that the user is expected to provide in the config.json file
 const (
 	ReplicateStateJobName  string = "replicate_state"
@@ -47,6 +49,46 @@ const (
 	DefaultGroundControlURL  = "http://127.0.0.1:8080"
 )
 
+// Serving certificate sources of the secure local registry.
+const (
+	SecureRegistryCertSourceLocal  string = "local"
+	SecureRegistryCertSourceSPIFFE string = "spiffe"
+)
+
+// Direct delivery backends.
+const (
+	DeliveryBackendImageDir          string = "image_dir"
+	DeliveryBackendContainerd        string = "containerd"
+	DeliveryBackendDocker            string = "docker"
+	DeliveryBackendContainersStorage string = "containers_storage"
+)
+
+// Defaults of the direct delivery backends that load into a runtime.
+const (
+	DefaultContainerdSocket      string = "/run/containerd/containerd.sock"
+	DefaultContainerdNamespace   string = "k8s.io"
+	DefaultContainerdSnapshotter string = "overlayfs"
+	DefaultDockerSocket          string = "/var/run/docker.sock"
+)
+
+// DefaultScheduleSpread is the window of the per-satellite schedule offset
+// when schedule_spread is unset, so a fleet sharing one config does not run
+// in lockstep. "0s" turns the spread off.
+const DefaultScheduleSpread = 30 * time.Second
+
+// DefaultPrewarmPullTimeout bounds a single CRI pull of the prewarmer.
+const DefaultPrewarmPullTimeout = 10 * time.Minute
+
+// DefaultNodeConfigMap is the ConfigMap the node config for node agents is
+// published to.
+const DefaultNodeConfigMap = "harbor-satellite-node-config"
+
+// Default probation settings for configs delivered by Ground Control.
+const (
+	DefaultConfigProbationCycles   int = 5
+	DefaultConfigRollbackThreshold int = 3
+)
+
 // Default audit settings, applied when audit is enabled but the user does not
 // specify a value.
 const (
@@ -59,3 +101,7 @@ const (
 	DefaultAuditSyslogTag    string = "harbor-audit"
 	DefaultAuditSyslogSocket string = "/dev/log"
 )
+
+// ConfigRevisionAnnotation is the manifest annotation Ground Control sets on a
+// config state artifact to record the config revision it holds.
+const ConfigRevisionAnnotation string = "io.goharbor.satellite.config.revision"
@@ -1,6 +1,9 @@
 package config
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Threadsafe getter functions to fetch config data.
 
@@ -74,10 +77,16 @@ func (cm *ConfigManager) GetStateURL() string {
 	return cm.config.StateConfig.StateURL
 }
 
+// GetRemoteRegistryUsername returns the user the satellite pushes to its
+// local registry as. The push account of a secured embedded registry takes
+// precedence over the config file.
 func (cm *ConfigManager) GetRemoteRegistryUsername() string {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
+	if cm.secureRegistry != nil {
+		return cm.secureRegistry.PushUsername
+	}
 	return cm.config.AppConfig.LocalRegistryCredentials.Username
 }
 
@@ -85,6 +94,9 @@ func (cm *ConfigManager) GetRemoteRegistryPassword() string {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
+	if cm.secureRegistry != nil {
+		return cm.secureRegistry.PushPassword
+	}
 	return cm.config.AppConfig.LocalRegistryCredentials.Password
 }
 
@@ -92,6 +104,9 @@ func (cm *ConfigManager) GetLocalRegistryURL() string {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
+	if cm.secureRegistry != nil {
+		return cm.secureRegistry.URL
+	}
 	return string(cm.config.AppConfig.LocalRegistryCredentials.URL)
 }
 
@@ -123,6 +138,29 @@ func (cm *ConfigManager) GetHeartbeatInterval() string {
 	return cm.config.AppConfig.HeartbeatInterval
 }
 
+// GetScheduleJitter returns the maximum random delay added to each scheduled
+// run, or 0 when unset.
+func (cm *ConfigManager) GetScheduleJitter() time.Duration {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	d, _ := time.ParseDuration(cm.config.AppConfig.ScheduleJitter)
+	return d
+}
+
+// GetScheduleSpread returns the window over which the satellite's
+// deterministic schedule offset is chosen, DefaultScheduleSpread when unset.
+func (cm *ConfigManager) GetScheduleSpread() time.Duration {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.config.AppConfig.ScheduleSpread == "" {
+		return DefaultScheduleSpread
+	}
+	d, _ := time.ParseDuration(cm.config.AppConfig.ScheduleSpread)
+	return d
+}
+
 func (cm *ConfigManager) GetMetricsConfig() MetricsConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -219,9 +257,58 @@ func (cm *ConfigManager) GetHarborRegistryURL() string {
 	return cm.config.AppConfig.HarborRegistryURL
 }
 
+func (cm *ConfigManager) GetSecureRegistryConfig() SecureRegistryConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.config.AppConfig.SecureRegistry
+}
+
+// GetSecureRegistryAccess returns how to reach the secured embedded registry,
+// and false while it is not in use.
+func (cm *ConfigManager) GetSecureRegistryAccess() (SecureRegistryAccess, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.secureRegistry == nil {
+		return SecureRegistryAccess{}, false
+	}
+	return *cm.secureRegistry, true
+}
+
+// SetSecureRegistryAccess records how to reach the secured embedded registry
+// once its certificate and accounts are provisioned.
+func (cm *ConfigManager) SetSecureRegistryAccess(access SecureRegistryAccess) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.secureRegistry = &access
+}
+
 func (cm *ConfigManager) GetDirectDeliveryConfig() DirectDeliveryConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
 	return cm.config.AppConfig.DirectDelivery
 }
+
+func (cm *ConfigManager) GetPrewarmConfig() PrewarmConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.config.AppConfig.Prewarm
+}
+
+func (cm *ConfigManager) GetNodeConfigConfig() NodeConfigConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.config.AppConfig.NodeConfig
+}
+
+func (cm *ConfigManager) GetConfigRollbackConfig() ConfigRollbackConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.config.AppConfig.ConfigRollback
+}
@@ -18,10 +18,23 @@ import (
 type ConfigChangeType string
 
 const (
-	LogLevelChanged    ConfigChangeType = "log_level"
-	IntervalsChanged   ConfigChangeType = "intervals"
-	ZotConfigChanged   ConfigChangeType = "zot_config"
-	AuditConfigChanged ConfigChangeType = "audit"
+	LogLevelChanged             ConfigChangeType = "log_level"
+	IntervalsChanged            ConfigChangeType = "intervals"
+	RegistrationIntervalChanged ConfigChangeType = "register_satellite_interval"
+	HeartbeatIntervalChanged    ConfigChangeType = "heartbeat_interval"
+	ScheduleChanged             ConfigChangeType = "schedule"
+	ZotConfigChanged            ConfigChangeType = "zot_config"
+	AuditConfigChanged          ConfigChangeType = "audit"
+	MetricsConfigChanged        ConfigChangeType = "metrics"
+	LocalRegistryChanged        ConfigChangeType = "local_registry"
+	TLSConfigChanged            ConfigChangeType = "tls"
+	SPIFFEConfigChanged         ConfigChangeType = "spiffe"
+	RegistryFallbackChanged     ConfigChangeType = "registry_fallback"
+	DirectDeliveryChanged       ConfigChangeType = "direct_delivery"
+	PrewarmChanged              ConfigChangeType = "prewarm"
+	NodeConfigChanged           ConfigChangeType = "node_config"
+	EncryptConfigChanged        ConfigChangeType = "encrypt_config"
+	SecureRegistryChanged       ConfigChangeType = "secure_registry"
 )
 
 type ConfigChange struct {
@@ -30,6 +43,13 @@ type ConfigChange struct {
 	NewValue any
 }
 
+// LocalRegistryChange is the value carried by a LocalRegistryChanged change.
+// It leaves out the credentials so changes can be logged safely.
+type LocalRegistryChange struct {
+	BringOwnRegistry bool   `json:"bring_own_registry"`
+	URL              string `json:"url,omitempty"`
+}
+
 type ConfigChangeCallback func(change ConfigChange) error
 
 type ConfigManager struct {
@@ -43,6 +63,7 @@ type ConfigManager struct {
 	encryptor               *secure.ConfigEncryptor
 	encryptEnabled          bool
 	cryptoProvider          crypto.Provider
+	secureRegistry          *SecureRegistryAccess
 }
 
 // Add cryptoProvider crypto.Provider to the arguments
@@ -115,6 +136,30 @@ func (cm *ConfigManager) WritePrevConfigToDisk(config *Config) error {
 	return cm.writeConfigUnlocked(config, cm.prevConfigPath)
 }
 
+// RollbackToPrevConfig replaces the config file with the previous config
+// saved by WritePrevConfigToDisk. The state config and the Harbor registry
+// override are kept from the current config, since they come from
+// registration and the command line rather than from Ground Control. The
+// file watcher picks up the restored file and hot-reloads it.
+func (cm *ConfigManager) RollbackToPrevConfig() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	prev, err := readAndReturnConfig(cm.prevConfigPath, cm.cryptoProvider)
+	if err != nil {
+		return fmt.Errorf("read previous config: %w", err)
+	}
+	prev.StateConfig = cm.config.StateConfig
+	prev.AppConfig.HarborRegistryURL = cm.config.AppConfig.HarborRegistryURL
+
+	validated, _, err := ValidateAndEnforceDefaults(prev, cm.DefaultGroundControlURL)
+	if err != nil {
+		return fmt.Errorf("validate previous config: %w", err)
+	}
+
+	return cm.writeConfigUnlocked(validated, cm.configPath)
+}
+
 func (cm *ConfigManager) detectChanges(oldConfig *Config, newConfig *Config) []ConfigChange {
 	var changes []ConfigChange
 
@@ -134,11 +179,36 @@ func (cm *ConfigManager) detectChanges(oldConfig *Config, newConfig *Config) []C
 		})
 	}
 
+	if oldConfig.AppConfig.RegisterSatelliteInterval != newConfig.AppConfig.RegisterSatelliteInterval {
+		changes = append(changes, ConfigChange{
+			Type:     RegistrationIntervalChanged,
+			OldValue: oldConfig.AppConfig.RegisterSatelliteInterval,
+			NewValue: newConfig.AppConfig.RegisterSatelliteInterval,
+		})
+	}
+
+	if oldConfig.AppConfig.HeartbeatInterval != newConfig.AppConfig.HeartbeatInterval {
+		changes = append(changes, ConfigChange{
+			Type:     HeartbeatIntervalChanged,
+			OldValue: oldConfig.AppConfig.HeartbeatInterval,
+			NewValue: newConfig.AppConfig.HeartbeatInterval,
+		})
+	}
+
+	if oldConfig.AppConfig.ScheduleJitter != newConfig.AppConfig.ScheduleJitter ||
+		oldConfig.AppConfig.ScheduleSpread != newConfig.AppConfig.ScheduleSpread {
+		changes = append(changes, ConfigChange{
+			Type:     ScheduleChanged,
+			OldValue: [2]string{oldConfig.AppConfig.ScheduleJitter, oldConfig.AppConfig.ScheduleSpread},
+			NewValue: [2]string{newConfig.AppConfig.ScheduleJitter, newConfig.AppConfig.ScheduleSpread},
+		})
+	}
+
 	if string(oldConfig.ZotConfigRaw) != string(newConfig.ZotConfigRaw) {
 		changes = append(changes, ConfigChange{
 			Type:     ZotConfigChanged,
-			OldValue: "zot_config_changed",
-			NewValue: "zot_config_changed",
+			OldValue: oldConfig.ZotConfigRaw,
+			NewValue: newConfig.ZotConfigRaw,
 		})
 	}
 
@@ -150,6 +220,88 @@ func (cm *ConfigManager) detectChanges(oldConfig *Config, newConfig *Config) []C
 		})
 	}
 
+	if oldConfig.AppConfig.Metrics != newConfig.AppConfig.Metrics {
+		changes = append(changes, ConfigChange{
+			Type:     MetricsConfigChanged,
+			OldValue: oldConfig.AppConfig.Metrics,
+			NewValue: newConfig.AppConfig.Metrics,
+		})
+	}
+
+	// Credentials are not carried in the change so they never reach the logs.
+	if oldConfig.AppConfig.BringOwnRegistry != newConfig.AppConfig.BringOwnRegistry ||
+		oldConfig.AppConfig.LocalRegistryCredentials != newConfig.AppConfig.LocalRegistryCredentials {
+		changes = append(changes, ConfigChange{
+			Type:     LocalRegistryChanged,
+			OldValue: LocalRegistryChange{BringOwnRegistry: oldConfig.AppConfig.BringOwnRegistry, URL: string(oldConfig.AppConfig.LocalRegistryCredentials.URL)},
+			NewValue: LocalRegistryChange{BringOwnRegistry: newConfig.AppConfig.BringOwnRegistry, URL: string(newConfig.AppConfig.LocalRegistryCredentials.URL)},
+		})
+	}
+
+	if oldConfig.AppConfig.TLS != newConfig.AppConfig.TLS {
+		changes = append(changes, ConfigChange{
+			Type:     TLSConfigChanged,
+			OldValue: oldConfig.AppConfig.TLS,
+			NewValue: newConfig.AppConfig.TLS,
+		})
+	}
+
+	if oldConfig.AppConfig.SPIFFE != newConfig.AppConfig.SPIFFE {
+		changes = append(changes, ConfigChange{
+			Type:     SPIFFEConfigChanged,
+			OldValue: oldConfig.AppConfig.SPIFFE,
+			NewValue: newConfig.AppConfig.SPIFFE,
+		})
+	}
+
+	if !oldConfig.AppConfig.RegistryFallback.Equal(newConfig.AppConfig.RegistryFallback) {
+		changes = append(changes, ConfigChange{
+			Type:     RegistryFallbackChanged,
+			OldValue: oldConfig.AppConfig.RegistryFallback,
+			NewValue: newConfig.AppConfig.RegistryFallback,
+		})
+	}
+
+	if oldConfig.AppConfig.DirectDelivery != newConfig.AppConfig.DirectDelivery {
+		changes = append(changes, ConfigChange{
+			Type:     DirectDeliveryChanged,
+			OldValue: oldConfig.AppConfig.DirectDelivery,
+			NewValue: newConfig.AppConfig.DirectDelivery,
+		})
+	}
+
+	if oldConfig.AppConfig.Prewarm != newConfig.AppConfig.Prewarm {
+		changes = append(changes, ConfigChange{
+			Type:     PrewarmChanged,
+			OldValue: oldConfig.AppConfig.Prewarm,
+			NewValue: newConfig.AppConfig.Prewarm,
+		})
+	}
+
+	if oldConfig.AppConfig.NodeConfig != newConfig.AppConfig.NodeConfig {
+		changes = append(changes, ConfigChange{
+			Type:     NodeConfigChanged,
+			OldValue: oldConfig.AppConfig.NodeConfig,
+			NewValue: newConfig.AppConfig.NodeConfig,
+		})
+	}
+
+	if oldConfig.AppConfig.EncryptConfig != newConfig.AppConfig.EncryptConfig {
+		changes = append(changes, ConfigChange{
+			Type:     EncryptConfigChanged,
+			OldValue: oldConfig.AppConfig.EncryptConfig,
+			NewValue: newConfig.AppConfig.EncryptConfig,
+		})
+	}
+
+	if !oldConfig.AppConfig.SecureRegistry.Equal(newConfig.AppConfig.SecureRegistry) {
+		changes = append(changes, ConfigChange{
+			Type:     SecureRegistryChanged,
+			OldValue: oldConfig.AppConfig.SecureRegistry,
+			NewValue: newConfig.AppConfig.SecureRegistry,
+		})
+	}
+
 	return changes
 }
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/crypto"
 	"github.com/stretchr/testify/require"
@@ -51,6 +52,67 @@ func TestConfigManager_detectChangesAudit(t *testing.T) {
 	})
 }
 
+func TestConfigManager_detectChangesSections(t *testing.T) {
+	cm := &ConfigManager{}
+
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   ConfigChangeType
+	}{
+		{"registration interval", func(c *Config) { c.AppConfig.RegisterSatelliteInterval = "@every 00h00m30s" }, RegistrationIntervalChanged},
+		{"heartbeat interval", func(c *Config) { c.AppConfig.HeartbeatInterval = "@every 00h01m00s" }, HeartbeatIntervalChanged},
+		{"schedule jitter", func(c *Config) { c.AppConfig.ScheduleJitter = "5s" }, ScheduleChanged},
+		{"schedule spread", func(c *Config) { c.AppConfig.ScheduleSpread = "1m" }, ScheduleChanged},
+		{"metrics toggle", func(c *Config) { c.AppConfig.Metrics.CollectCPU = true }, MetricsConfigChanged},
+		{"bring own registry", func(c *Config) { c.AppConfig.BringOwnRegistry = true }, LocalRegistryChanged},
+		{"local registry password", func(c *Config) { c.AppConfig.LocalRegistryCredentials.Password = "secret" }, LocalRegistryChanged},
+		{"tls", func(c *Config) { c.AppConfig.TLS.CAFile = "/ca.pem" }, TLSConfigChanged},
+		{"spiffe", func(c *Config) { c.AppConfig.SPIFFE.Enabled = true }, SPIFFEConfigChanged},
+		{"registry fallback", func(c *Config) { c.AppConfig.RegistryFallback.Registries = []string{"docker.io"} }, RegistryFallbackChanged},
+		{"direct delivery", func(c *Config) { c.AppConfig.DirectDelivery.Enabled = true }, DirectDeliveryChanged},
+		{"encrypt config", func(c *Config) { c.AppConfig.EncryptConfig = true }, EncryptConfigChanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &Config{}
+			tt.mutate(next)
+			changes := cm.detectChanges(&Config{}, next)
+			require.Len(t, changes, 1)
+			require.Equal(t, tt.want, changes[0].Type)
+		})
+	}
+
+	t.Run("local registry change does not carry credentials", func(t *testing.T) {
+		next := &Config{}
+		next.AppConfig.LocalRegistryCredentials = RegistryCredentials{URL: "http://registry:5000", Username: "admin", Password: "secret"}
+		changes := cm.detectChanges(&Config{}, next)
+		require.Len(t, changes, 1)
+		require.Equal(t, LocalRegistryChange{URL: "http://registry:5000"}, changes[0].NewValue)
+	})
+
+	t.Run("identical fallback lists yield no change", func(t *testing.T) {
+		base := func() *Config {
+			c := &Config{}
+			c.AppConfig.RegistryFallback = RegistryFallbackConfig{Enabled: true, Registries: []string{"docker.io"}, Runtimes: []string{"containerd"}}
+			return c
+		}
+		require.Empty(t, cm.detectChanges(base(), base()))
+	})
+}
+
+func TestConfigManager_GetScheduleSpread(t *testing.T) {
+	for spread, want := range map[string]time.Duration{
+		"":   DefaultScheduleSpread,
+		"0s": 0,
+		"5m": 5 * time.Minute,
+	} {
+		cm := &ConfigManager{config: &Config{AppConfig: AppConfig{ScheduleSpread: spread}}}
+		require.Equal(t, want, cm.GetScheduleSpread(), spread)
+	}
+}
+
 func writeTempConfig(t *testing.T, data any) string {
 	tempDir := t.TempDir()
 	path := filepath.Join(tempDir, "config.json")
@@ -126,3 +188,41 @@ func TestConfigManager_WriteConfig(t *testing.T) {
 		require.Equal(t, "warn", saved.AppConfig.LogLevel)
 	})
 }
+
+func TestConfigManager_RollbackToPrevConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	prevPath := filepath.Join(dir, "prev_config.json")
+
+	current := &Config{
+		StateConfig: StateConfig{StateURL: "registry/satellite/satellite-state/edge-1/state:latest"},
+		AppConfig: AppConfig{
+			LogLevel:          "debug",
+			HarborRegistryURL: "https://harbor.local",
+		},
+		ZotConfigRaw: json.RawMessage(DefaultZotConfigJSON),
+	}
+	cm, err := NewConfigManager(path, prevPath, "", "http://groundcontrol", false, current, crypto.NewAESProvider())
+	require.NoError(t, err)
+
+	t.Run("missing previous config is an error", func(t *testing.T) {
+		require.Error(t, cm.RollbackToPrevConfig())
+	})
+
+	t.Run("restores the previous config and keeps the state config", func(t *testing.T) {
+		require.NoError(t, cm.WritePrevConfigToDisk(&Config{
+			AppConfig:    AppConfig{LogLevel: "warn"},
+			ZotConfigRaw: json.RawMessage(DefaultZotConfigJSON),
+		}))
+		require.NoError(t, cm.RollbackToPrevConfig())
+
+		data, err := os.ReadFile(filepath.Clean(path))
+		require.NoError(t, err)
+
+		var restored Config
+		require.NoError(t, json.Unmarshal(data, &restored))
+		require.Equal(t, "warn", restored.AppConfig.LogLevel)
+		require.Equal(t, current.StateConfig.StateURL, restored.StateConfig.StateURL)
+		require.Equal(t, "https://harbor.local", restored.AppConfig.HarborRegistryURL)
+	})
+}
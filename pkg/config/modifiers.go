@@ -64,6 +64,12 @@ func SetBringOwnRegistry(flag bool) func(*Config) {
 	}
 }
 
+func SetMetricsListenAddress(address string) func(*Config) {
+	return func(cfg *Config) {
+		cfg.AppConfig.Metrics.ListenAddress = address
+	}
+}
+
 func SetLocalRegistryURL(url string) func(*Config) {
 	return func(cfg *Config) {
 		cfg.AppConfig.LocalRegistryCredentials.URL = URL(url)
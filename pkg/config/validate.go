@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/container-registry/harbor-satellite/internal/satellite/registry"
 	"github.com/robfig/cron/v3"
@@ -72,11 +74,57 @@ func ValidateAndEnforceDefaults(config *Config, defaultGroundControlURL string)
 
 	warnings = append(warnings, validateRegistryFallbackConfig(config)...)
 
+	warnings = append(warnings, validateSecureRegistryConfig(config)...)
+
+	warnings = append(warnings, validateDirectDeliveryConfig(&config.AppConfig.DirectDelivery)...)
+
 	warnings = append(warnings, validateAndEnforceAuditConfig(config)...)
 
+	warnings = append(warnings, validateMetricsConfig(&config.AppConfig.Metrics)...)
+
+	warnings = append(warnings, validatePrewarmConfig(&config.AppConfig.Prewarm)...)
+
+	warnings = append(warnings, validateNodeConfigConfig(config)...)
+
 	return config, warnings, nil
 }
 
+// validateMetricsConfig clears an unparsable metrics listen address so the
+// satellite starts without the endpoint instead of failing later.
+func validateMetricsConfig(m *MetricsConfig) []string {
+	if m.ListenAddress == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(m.ListenAddress); err != nil {
+		addr := m.ListenAddress
+		m.ListenAddress = ""
+		return []string{fmt.Sprintf("invalid metrics.listen_address %q (%v), metrics endpoint disabled", addr, err)}
+	}
+
+	return nil
+}
+
+// validatePrewarmConfig clears an unparsable agent listen address or pull
+// timeout, so the satellite starts without the agent API or with the default
+// timeout.
+func validatePrewarmConfig(p *PrewarmConfig) []string {
+	var warnings []string
+	if p.ListenAddress != "" {
+		if _, _, err := net.SplitHostPort(p.ListenAddress); err != nil {
+			warnings = append(warnings, fmt.Sprintf("invalid prewarm.listen_address %q (%v), node agent API disabled", p.ListenAddress, err))
+			p.ListenAddress = ""
+		}
+	}
+	if p.PullTimeout != "" {
+		if d, err := time.ParseDuration(p.PullTimeout); err != nil || d <= 0 {
+			warnings = append(warnings, fmt.Sprintf("invalid prewarm.pull_timeout %q, using %s", p.PullTimeout, DefaultPrewarmPullTimeout))
+			p.PullTimeout = ""
+		}
+	}
+
+	return warnings
+}
+
 // validateAndEnforceAuditConfig fills in defaults for the audit syslog transport
 // when audit logging is enabled, applying only the defaults relevant to the
 // chosen target.
@@ -103,6 +151,9 @@ func validateOtelConfig(o *OtelAudit) []string {
 	if o.Enabled && o.Endpoint == "" {
 		return []string{"audit.otel.enabled but audit.otel.endpoint is empty; the audit logger will fail to start"}
 	}
+	if o.Traces && o.Endpoint == "" {
+		return []string{"audit.otel.traces is set but audit.otel.endpoint is empty; traces will not be exported"}
+	}
 
 	return nil
 }
@@ -271,9 +322,29 @@ func validateAndEnforceCronSchedules(config *Config) []string {
 		warnings = append(warnings, fmt.Sprintf("invalid schedule provided for heartbeat_interval, using default schedule %s", DefaultHeartbeatCronExpr))
 	}
 
+	if !isValidScheduleDuration(config.AppConfig.ScheduleJitter) {
+		warnings = append(warnings, fmt.Sprintf("invalid duration %q provided for schedule_jitter, jitter disabled", config.AppConfig.ScheduleJitter))
+		config.AppConfig.ScheduleJitter = ""
+	}
+
+	if !isValidScheduleDuration(config.AppConfig.ScheduleSpread) {
+		warnings = append(warnings, fmt.Sprintf("invalid duration %q provided for schedule_spread, using default spread %s", config.AppConfig.ScheduleSpread, DefaultScheduleSpread))
+		config.AppConfig.ScheduleSpread = ""
+	}
+
 	return warnings
 }
 
+// isValidScheduleDuration reports whether s is empty or a non-negative
+// duration such as "30s" or "5m".
+func isValidScheduleDuration(s string) bool {
+	if s == "" {
+		return true
+	}
+	d, err := time.ParseDuration(s)
+	return err == nil && d >= 0
+}
+
 // validateAndEnforceZotConfig validates and defaults zot registry configuration.
 func validateAndEnforceZotConfig(config *Config, bringOwnRegistry bool) ([]string, error) {
 	var warnings []string
@@ -314,6 +385,38 @@ func validateAndEnforceZotConfig(config *Config, bringOwnRegistry bool) ([]strin
 	return warnings, nil
 }
 
+// validateNodeConfigConfig disables publishing the node config without a
+// mirror address or when nodes could not pull from the secured registry,
+// and warns about node agents that cannot report back.
+func validateNodeConfigConfig(config *Config) []string {
+	n := &config.AppConfig.NodeConfig
+	if !n.Enabled {
+		return nil
+	}
+	if n.MirrorAddress == "" {
+		n.Enabled = false
+		return []string{"node_config is enabled but no mirror_address specified, node config not published"}
+	}
+	if sr := config.AppConfig.SecureRegistry; sr.Enabled && !sr.AnonymousPull {
+		n.Enabled = false
+		return []string{"node_config needs secure_registry.anonymous_pull, as nodes pull without the credentials of the secured registry, node config not published"}
+	}
+
+	var warnings []string
+	if _, name := n.ConfigMapOrDefault(); name == "" || strings.Count(n.ConfigMap, "/") > 1 {
+		warnings = append(warnings, fmt.Sprintf("invalid node_config.config_map %q, using %s", n.ConfigMap, DefaultNodeConfigMap))
+		n.ConfigMap = ""
+	}
+	if !config.AppConfig.RegistryFallback.Enabled {
+		warnings = append(warnings, "node_config is enabled but registry_fallback is not, node agents have no mirrors to apply")
+	}
+	if n.ReportURL == "" || config.AppConfig.Prewarm.ListenAddress == "" {
+		warnings = append(warnings, "node_config needs report_url and prewarm.listen_address for node agents to report their results")
+	}
+
+	return warnings
+}
+
 // validateRegistryFallbackConfig validates registry fallback settings when enabled.
 func validateRegistryFallbackConfig(config *Config) []string {
 	validRuntimes := map[string]bool{
@@ -321,9 +424,12 @@ func validateRegistryFallbackConfig(config *Config) []string {
 		"containerd": true,
 		"crio":       true,
 		"podman":     true,
+		"k3s":        true,
+		"rke2":       true,
 	}
 
-	var warnings []string
+	// The mirror settings also apply to mirrors configured with --mirrors.
+	warnings := validateMirrorEndpoints(&config.AppConfig.RegistryFallback)
 	fb := config.AppConfig.RegistryFallback
 	if !fb.Enabled {
 		return warnings
@@ -343,7 +449,7 @@ func validateRegistryFallbackConfig(config *Config) []string {
 	for _, rt := range config.AppConfig.RegistryFallback.Runtimes {
 		if !validRuntimes[rt] {
 			warnings = append(warnings, fmt.Sprintf(
-				"registry_fallback contains unknown runtime %q, valid values: docker, containerd, crio, podman", rt,
+				"registry_fallback contains unknown runtime %q, valid values: docker, containerd, crio, podman, k3s, rke2", rt,
 			))
 		}
 	}
@@ -351,6 +457,99 @@ func validateRegistryFallbackConfig(config *Config) []string {
 	return warnings
 }
 
+// validateMirrorEndpoints drops fallback mirrors runtimes could not reach
+// and normalizes path prefixes.
+func validateMirrorEndpoints(fb *RegistryFallbackConfig) []string {
+	var warnings []string
+	if fb.LocalMirror.URL != "" {
+		warnings = append(warnings, "registry_fallback.local_mirror.url is ignored, the local registry address is used")
+		fb.LocalMirror.URL = ""
+	}
+	warnings = append(warnings, normalizeMirrorEndpoint("registry_fallback.local_mirror", &fb.LocalMirror)...)
+
+	var mirrors []MirrorEndpointConfig
+	for i, m := range fb.FallbackMirrors {
+		field := fmt.Sprintf("registry_fallback.fallback_mirrors[%d]", i)
+		u, err := url.Parse(m.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			warnings = append(warnings, fmt.Sprintf("invalid %s.url %q, expected scheme and host with the path in path_prefix. Mirror ignored", field, m.URL))
+			continue
+		}
+		m.URL = u.Scheme + "://" + u.Host
+		warnings = append(warnings, normalizeMirrorEndpoint(field, &m)...)
+		mirrors = append(mirrors, m)
+	}
+	fb.FallbackMirrors = mirrors
+
+	return warnings
+}
+
+func normalizeMirrorEndpoint(field string, m *MirrorEndpointConfig) []string {
+	var warnings []string
+	if m.PathPrefix != "" {
+		m.PathPrefix = "/" + strings.Trim(m.PathPrefix, "/")
+		if m.PathPrefix == "/v2" || strings.HasPrefix(m.PathPrefix, "/v2/") {
+			warnings = append(warnings, fmt.Sprintf("%s.path_prefix %q includes the /v2 API root, which runtimes add themselves. Using %q", field, m.PathPrefix, strings.TrimPrefix(m.PathPrefix, "/v2")))
+			m.PathPrefix = strings.TrimPrefix(m.PathPrefix, "/v2")
+		}
+	}
+	if m.ClientKeyFile != "" && m.ClientCertFile == "" {
+		warnings = append(warnings, fmt.Sprintf("%s.client_key_file is set without client_cert_file, ignored", field))
+		m.ClientKeyFile = ""
+	}
+
+	return warnings
+}
+
+// validateSecureRegistryConfig turns the secure registry mode off where it
+// cannot apply and falls back to the local CA for an unusable cert source.
+func validateSecureRegistryConfig(config *Config) []string {
+	sr := &config.AppConfig.SecureRegistry
+	if !sr.Enabled {
+		return nil
+	}
+	if config.AppConfig.BringOwnRegistry {
+		sr.Enabled = false
+		return []string{"secure_registry only applies to the embedded registry, ignored for bring_own_registry: `true`."}
+	}
+
+	var warnings []string
+	switch sr.CertSourceOrDefault() {
+	case SecureRegistryCertSourceLocal:
+	case SecureRegistryCertSourceSPIFFE:
+		if !config.AppConfig.SPIFFE.Enabled {
+			warnings = append(warnings, "secure_registry.cert_source is spiffe but spiffe is not enabled, using the local CA")
+			sr.CertSource = SecureRegistryCertSourceLocal
+		}
+	default:
+		warnings = append(warnings, fmt.Sprintf(
+			"invalid secure_registry.cert_source %q, valid values: local, spiffe. Using the local CA", sr.CertSource,
+		))
+		sr.CertSource = SecureRegistryCertSourceLocal
+	}
+
+	return warnings
+}
+
+// validateDirectDeliveryConfig turns direct delivery off when its backend is
+// unknown, rather than guessing where images should go.
+func validateDirectDeliveryConfig(dd *DirectDeliveryConfig) []string {
+	if !dd.Enabled {
+		return nil
+	}
+
+	switch dd.BackendOrDefault() {
+	case DeliveryBackendImageDir, DeliveryBackendContainerd, DeliveryBackendDocker, DeliveryBackendContainersStorage:
+		return nil
+	default:
+		dd.Enabled = false
+		return []string{fmt.Sprintf(
+			"invalid direct_delivery.backend %q, valid values: image_dir, containerd, docker, containers_storage. Direct delivery disabled",
+			dd.Backend,
+		)}
+	}
+}
+
 // validateTLSConfig validates TLS configuration.
 func validateTLSConfig(tls *TLSConfig) ([]string, error) {
 	var warnings []string
@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
@@ -280,6 +282,27 @@ func TestValidateAndEnforceDefaults(t *testing.T) {
 	}
 }
 
+func TestValidateScheduleJitterAndSpread(t *testing.T) {
+	cfg := &Config{
+		AppConfig: AppConfig{
+			GroundControlURL:         URL("https://example.com"),
+			StateReplicationInterval: "@hourly",
+			HeartbeatInterval:        "*/5 * * * *",
+			ScheduleJitter:           "30s",
+			ScheduleSpread:           "-1m",
+		},
+		ZotConfigRaw: []byte(DefaultZotConfigJSON),
+	}
+
+	got, warnings, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+	require.NoError(t, err)
+	require.Equal(t, "@hourly", got.AppConfig.StateReplicationInterval)
+	require.Equal(t, "*/5 * * * *", got.AppConfig.HeartbeatInterval)
+	require.Equal(t, "30s", got.AppConfig.ScheduleJitter)
+	require.Empty(t, got.AppConfig.ScheduleSpread)
+	require.Contains(t, strings.Join(warnings, "\n"), "schedule_spread")
+}
+
 func TestValidateTLSConfig(t *testing.T) {
 	t.Run("valid TLS with cert and key files", func(t *testing.T) {
 		tmpDir := t.TempDir()
@@ -471,7 +494,7 @@ func TestValidateRegistryFallbackConfig(t *testing.T) {
 		require.NoError(t, err)
 		found := false
 		for _, w := range warnings {
-			if w == `registry_fallback contains unknown runtime "badruntime", valid values: docker, containerd, crio, podman` {
+			if w == `registry_fallback contains unknown runtime "badruntime", valid values: docker, containerd, crio, podman, k3s, rke2` {
 				found = true
 			}
 		}
@@ -483,7 +506,7 @@ func TestValidateRegistryFallbackConfig(t *testing.T) {
 		cfg.AppConfig.RegistryFallback = RegistryFallbackConfig{
 			Enabled:    true,
 			Registries: []string{"docker.io"},
-			Runtimes:   []string{"docker", "containerd", "crio", "podman"},
+			Runtimes:   []string{"docker", "containerd", "crio", "podman", "k3s", "rke2"},
 		}
 		_, warnings, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
 		require.NoError(t, err)
@@ -491,6 +514,291 @@ func TestValidateRegistryFallbackConfig(t *testing.T) {
 			require.NotContains(t, w, "unknown runtime")
 		}
 	})
+
+	t.Run("fallback mirrors are normalized and invalid ones dropped", func(t *testing.T) {
+		cfg := baseConfig()
+		cfg.AppConfig.RegistryFallback = RegistryFallbackConfig{
+			Enabled:     true,
+			Registries:  []string{"docker.io"},
+			LocalMirror: MirrorEndpointConfig{URL: "https://ignored:5000", ClientKeyFile: "/client.key"},
+			FallbackMirrors: []MirrorEndpointConfig{
+				{URL: "https://mirror.example.com/", PathPrefix: "v2/docker.io/"},
+				{URL: "https://other.example.com", PathPrefix: "quay.io"},
+				{URL: "mirror.example.com"},
+				{URL: "https://mirror.example.com/docker"},
+			},
+		}
+		result, warnings, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+		require.NoError(t, err)
+
+		fb := result.AppConfig.RegistryFallback
+		require.Empty(t, fb.LocalMirror.URL)
+		require.Empty(t, fb.LocalMirror.ClientKeyFile)
+		require.Equal(t, []MirrorEndpointConfig{
+			{URL: "https://mirror.example.com", PathPrefix: "/docker.io"},
+			{URL: "https://other.example.com", PathPrefix: "/quay.io"},
+		}, fb.FallbackMirrors)
+		require.Contains(t, warnings, "registry_fallback.local_mirror.url is ignored, the local registry address is used")
+		require.Contains(t, warnings, "registry_fallback.local_mirror.client_key_file is set without client_cert_file, ignored")
+		require.Contains(t, warnings, `registry_fallback.fallback_mirrors[0].path_prefix "/v2/docker.io" includes the /v2 API root, which runtimes add themselves. Using "/docker.io"`)
+		require.Contains(t, warnings, `invalid registry_fallback.fallback_mirrors[2].url "mirror.example.com", expected scheme and host with the path in path_prefix. Mirror ignored`)
+		require.Contains(t, warnings, `invalid registry_fallback.fallback_mirrors[3].url "https://mirror.example.com/docker", expected scheme and host with the path in path_prefix. Mirror ignored`)
+	})
+
+	t.Run("fallback mirror order is part of equality", func(t *testing.T) {
+		a := RegistryFallbackConfig{FallbackMirrors: []MirrorEndpointConfig{{URL: "https://a"}, {URL: "https://b"}}}
+		b := RegistryFallbackConfig{FallbackMirrors: []MirrorEndpointConfig{{URL: "https://b"}, {URL: "https://a"}}}
+		require.True(t, a.Equal(a))
+		require.False(t, a.Equal(b))
+
+		c := RegistryFallbackConfig{LocalMirror: MirrorEndpointConfig{Headers: map[string][]string{"X-Tenant": {"edge"}}}}
+		d := RegistryFallbackConfig{LocalMirror: MirrorEndpointConfig{Headers: map[string][]string{"X-Tenant": {"core"}}}}
+		require.False(t, c.Equal(d))
+	})
+
+	t.Run("reload and verify are part of equality", func(t *testing.T) {
+		require.False(t, RegistryFallbackConfig{Reload: true}.Equal(RegistryFallbackConfig{}))
+		require.False(t, RegistryFallbackConfig{Verify: true}.Equal(RegistryFallbackConfig{}))
+	})
+}
+
+func TestValidateSecureRegistryConfig(t *testing.T) {
+	baseConfig := func() *Config {
+		return &Config{
+			AppConfig: AppConfig{
+				GroundControlURL: URL("https://example.com"),
+				SecureRegistry:   SecureRegistryConfig{Enabled: true},
+			},
+			ZotConfigRaw: []byte(DefaultZotConfigJSON),
+		}
+	}
+
+	t.Run("local CA by default", func(t *testing.T) {
+		result, warnings, err := ValidateAndEnforceDefaults(baseConfig(), DefaultGroundControlURL)
+		require.NoError(t, err)
+		for _, w := range warnings {
+			require.NotContains(t, w, "secure_registry")
+		}
+		require.True(t, result.AppConfig.SecureRegistry.Enabled)
+		require.Equal(t, SecureRegistryCertSourceLocal, result.AppConfig.SecureRegistry.CertSourceOrDefault())
+	})
+
+	t.Run("spiffe without spiffe enabled falls back to the local CA", func(t *testing.T) {
+		cfg := baseConfig()
+		cfg.AppConfig.SecureRegistry.CertSource = SecureRegistryCertSourceSPIFFE
+		result, warnings, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+		require.NoError(t, err)
+		require.Contains(t, warnings, "secure_registry.cert_source is spiffe but spiffe is not enabled, using the local CA")
+		require.Equal(t, SecureRegistryCertSourceLocal, result.AppConfig.SecureRegistry.CertSource)
+	})
+
+	t.Run("spiffe with spiffe enabled", func(t *testing.T) {
+		cfg := baseConfig()
+		cfg.AppConfig.SecureRegistry.CertSource = SecureRegistryCertSourceSPIFFE
+		cfg.AppConfig.SPIFFE.Enabled = true
+		result, _, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+		require.NoError(t, err)
+		require.Equal(t, SecureRegistryCertSourceSPIFFE, result.AppConfig.SecureRegistry.CertSource)
+	})
+
+	t.Run("unknown cert source warns", func(t *testing.T) {
+		cfg := baseConfig()
+		cfg.AppConfig.SecureRegistry.CertSource = "acme"
+		result, warnings, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+		require.NoError(t, err)
+		require.Contains(t, warnings, `invalid secure_registry.cert_source "acme", valid values: local, spiffe. Using the local CA`)
+		require.Equal(t, SecureRegistryCertSourceLocal, result.AppConfig.SecureRegistry.CertSource)
+	})
+
+	t.Run("ignored for bring your own registry", func(t *testing.T) {
+		cfg := baseConfig()
+		cfg.AppConfig.BringOwnRegistry = true
+		cfg.AppConfig.LocalRegistryCredentials = RegistryCredentials{URL: "http://registry:5000", Username: "u", Password: "p"}
+		cfg.ZotConfigRaw = nil
+		result, _, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+		require.NoError(t, err)
+		require.False(t, result.AppConfig.SecureRegistry.Enabled)
+	})
+}
+
+func TestValidateDirectDeliveryConfig(t *testing.T) {
+	baseConfig := func(backend string) *Config {
+		return &Config{
+			AppConfig: AppConfig{
+				GroundControlURL: URL("https://example.com"),
+				DirectDelivery:   DirectDeliveryConfig{Enabled: true, Backend: backend},
+			},
+			ZotConfigRaw: []byte(DefaultZotConfigJSON),
+		}
+	}
+
+	for _, backend := range []string{"", DeliveryBackendImageDir, DeliveryBackendContainerd, DeliveryBackendDocker, DeliveryBackendContainersStorage} {
+		result, warnings, err := ValidateAndEnforceDefaults(baseConfig(backend), DefaultGroundControlURL)
+		require.NoError(t, err)
+		for _, w := range warnings {
+			require.NotContains(t, w, "direct_delivery")
+		}
+		require.True(t, result.AppConfig.DirectDelivery.Enabled, backend)
+	}
+
+	t.Run("unknown backend disables direct delivery", func(t *testing.T) {
+		result, warnings, err := ValidateAndEnforceDefaults(baseConfig("nfs"), DefaultGroundControlURL)
+		require.NoError(t, err)
+		require.Contains(t, warnings, `invalid direct_delivery.backend "nfs", valid values: image_dir, containerd, docker, containers_storage. Direct delivery disabled`)
+		require.False(t, result.AppConfig.DirectDelivery.Enabled)
+	})
+}
+
+func TestValidatePrewarmConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		prewarm     PrewarmConfig
+		wantListen  string
+		wantTimeout time.Duration
+		wantWarning string
+	}{
+		{
+			name:        "valid",
+			prewarm:     PrewarmConfig{Enabled: true, ListenAddress: ":9465", PullTimeout: "2m"},
+			wantListen:  ":9465",
+			wantTimeout: 2 * time.Minute,
+		},
+		{
+			name:        "defaults",
+			prewarm:     PrewarmConfig{Enabled: true},
+			wantTimeout: DefaultPrewarmPullTimeout,
+		},
+		{
+			name:        "invalid listen address",
+			prewarm:     PrewarmConfig{Enabled: true, ListenAddress: "9465"},
+			wantTimeout: DefaultPrewarmPullTimeout,
+			wantWarning: "invalid prewarm.listen_address",
+		},
+		{
+			name:        "invalid pull timeout",
+			prewarm:     PrewarmConfig{Enabled: true, PullTimeout: "-1m"},
+			wantTimeout: DefaultPrewarmPullTimeout,
+			wantWarning: `invalid prewarm.pull_timeout "-1m", using 10m0s`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				AppConfig: AppConfig{
+					GroundControlURL: URL("https://example.com"),
+					Prewarm:          tt.prewarm,
+				},
+				ZotConfigRaw: []byte(DefaultZotConfigJSON),
+			}
+			result, warnings, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+			require.NoError(t, err)
+
+			var prewarmWarnings []string
+			for _, w := range warnings {
+				if strings.Contains(w, "prewarm") {
+					prewarmWarnings = append(prewarmWarnings, w)
+				}
+			}
+			if tt.wantWarning == "" {
+				require.Empty(t, prewarmWarnings)
+			} else {
+				require.Len(t, prewarmWarnings, 1)
+				require.Contains(t, prewarmWarnings[0], tt.wantWarning)
+			}
+			require.Equal(t, tt.wantListen, result.AppConfig.Prewarm.ListenAddress)
+			require.Equal(t, tt.wantTimeout, result.AppConfig.Prewarm.PullTimeoutOrDefault())
+		})
+	}
+}
+
+func TestValidateNodeConfigConfig(t *testing.T) {
+	fallback := RegistryFallbackConfig{Enabled: true, Registries: []string{"docker.io"}}
+	tests := []struct {
+		name          string
+		nodeConfig    NodeConfigConfig
+		fallback      RegistryFallbackConfig
+		listen        string
+		secure        SecureRegistryConfig
+		wantEnabled   bool
+		wantNamespace string
+		wantName      string
+		wantWarnings  []string
+	}{
+		{
+			name:          "valid",
+			nodeConfig:    NodeConfigConfig{Enabled: true, ConfigMap: "edge/mirrors", MirrorAddress: "10.43.0.10:8585", ReportURL: "http://harbor-satellite.edge.svc:9465"},
+			fallback:      fallback,
+			listen:        ":9465",
+			wantEnabled:   true,
+			wantNamespace: "edge",
+			wantName:      "mirrors",
+		},
+		{
+			name:         "no mirror address",
+			nodeConfig:   NodeConfigConfig{Enabled: true},
+			fallback:     fallback,
+			wantName:     DefaultNodeConfigMap,
+			wantWarnings: []string{"no mirror_address specified"},
+		},
+		{
+			name:         "invalid config map, no fallback and no report URL",
+			nodeConfig:   NodeConfigConfig{Enabled: true, ConfigMap: "edge/", MirrorAddress: "10.43.0.10:8585"},
+			wantEnabled:  true,
+			wantName:     DefaultNodeConfigMap,
+			wantWarnings: []string{`invalid node_config.config_map "edge/"`, "registry_fallback is not", "report_url"},
+		},
+		{
+			name:         "secured registry without anonymous pull",
+			nodeConfig:   NodeConfigConfig{Enabled: true, MirrorAddress: "10.43.0.10:8585", ReportURL: "http://harbor-satellite.edge.svc:9465"},
+			fallback:     fallback,
+			listen:       ":9465",
+			secure:       SecureRegistryConfig{Enabled: true},
+			wantName:     DefaultNodeConfigMap,
+			wantWarnings: []string{"needs secure_registry.anonymous_pull"},
+		},
+		{
+			name:        "secured registry with anonymous pull",
+			nodeConfig:  NodeConfigConfig{Enabled: true, MirrorAddress: "10.43.0.10:8585", ReportURL: "http://harbor-satellite.edge.svc:9465"},
+			fallback:    fallback,
+			listen:      ":9465",
+			secure:      SecureRegistryConfig{Enabled: true, AnonymousPull: true},
+			wantEnabled: true,
+			wantName:    DefaultNodeConfigMap,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				AppConfig: AppConfig{
+					GroundControlURL: URL("https://example.com"),
+					NodeConfig:       tt.nodeConfig,
+					RegistryFallback: tt.fallback,
+					Prewarm:          PrewarmConfig{ListenAddress: tt.listen},
+					SecureRegistry:   tt.secure,
+				},
+				ZotConfigRaw: []byte(DefaultZotConfigJSON),
+			}
+			result, warnings, err := ValidateAndEnforceDefaults(cfg, DefaultGroundControlURL)
+			require.NoError(t, err)
+
+			var nodeWarnings []string
+			for _, w := range warnings {
+				if strings.Contains(w, "node_config") {
+					nodeWarnings = append(nodeWarnings, w)
+				}
+			}
+			require.Len(t, nodeWarnings, len(tt.wantWarnings), "warnings: %v", nodeWarnings)
+			for i, want := range tt.wantWarnings {
+				require.Contains(t, nodeWarnings[i], want)
+			}
+			require.Equal(t, tt.wantEnabled, result.AppConfig.NodeConfig.Enabled)
+			namespace, name := result.AppConfig.NodeConfig.ConfigMapOrDefault()
+			require.Equal(t, tt.wantNamespace, namespace)
+			require.Equal(t, tt.wantName, name)
+		})
+	}
 }
 
 func TestUseUnsecureEnvVar(t *testing.T) {
@@ -0,0 +1,312 @@
+// Package gcclient is a typed Go client for the Ground Control API.
+//
+// A Client authenticates in one of three ways: as a user with a session it
+// logs in for (WithCredentials) or was handed (WithBearerToken), or as a
+// satellite with its robot account (WithBasicAuth), which users may use with
+// their password too. Error responses are returned as *Error and match the
+// sentinel errors of this package with errors.Is. Idempotent requests are
+// retried on network errors and on 429, 502, 503 and 504 responses.
+//
+// The list endpoints of Ground Control do not page yet; the List methods
+// return every item.
+package gcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client calls the Ground Control API. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	retry   RetryPolicy
+
+	username string
+	password string
+	robot    bool
+
+	mu    sync.Mutex
+	token string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client requests are sent with, for instance
+// one presenting the SVID of a satellite for SPIFFE authentication.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithBearerToken authenticates user requests with a session token.
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithCredentials authenticates user requests with a session the client logs
+// in for on the first request, and logs in for again when it expires.
+func WithCredentials(username, password string) Option {
+	return func(c *Client) {
+		c.username, c.password, c.robot = username, password, false
+	}
+}
+
+// WithBasicAuth authenticates every request with basic auth: satellites
+// with their robot account, users with their password on the /api routes.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username, c.password, c.robot = username, password, true
+	}
+}
+
+// WithRetry sets the retry policy. The zero RetryPolicy disables retries.
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// RetryPolicy controls the retries of idempotent requests.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; 0 and 1 disable retries.
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry, doubled for every
+	// retry after it up to MaxBackoff. A Retry-After header overrides it.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy retries three times, starting at 200ms.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  200 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// New returns a client for the Ground Control at baseURL.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token returns the session token of user requests, empty before login.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Login starts a session for username and uses it for the requests after.
+func (c *Client) Login(ctx context.Context, username, password string) (*LoginResponse, error) {
+	var resp LoginResponse
+	req := LoginRequest{Username: username, Password: password}
+	if err := c.send(ctx, http.MethodPost, "/login", nil, authNone, req, &resp); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.token = resp.Token
+	c.mu.Unlock()
+	return &resp, nil
+}
+
+// Logout ends the session of the client.
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, "/api/logout", nil, nil, nil); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+	return nil
+}
+
+// Ping checks that Ground Control is up.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.send(ctx, http.MethodGet, "/ping", nil, authNone, nil, nil)
+}
+
+// Health checks that Ground Control and its database are healthy. An
+// unhealthy Ground Control answers with ErrUnavailable.
+func (c *Client) Health(ctx context.Context) error {
+	return c.send(ctx, http.MethodGet, "/health", nil, authNone, nil, nil)
+}
+
+// auth is how a request authenticates.
+type auth int
+
+const (
+	authNone auth = iota
+	authUser
+	authBasic
+)
+
+// do sends a user request, logging in first when the client has credentials
+// and no session, and once more when the session expired.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	if c.robot {
+		return c.send(ctx, method, path, query, authBasic, body, out)
+	}
+	token, err := c.session(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.send(ctx, method, path, query, authUser, body, out)
+	if c.username == "" || !isStatus(err, http.StatusUnauthorized) {
+		return err
+	}
+	c.mu.Lock()
+	if c.token == token {
+		c.token = ""
+	}
+	c.mu.Unlock()
+	if _, err := c.session(ctx); err != nil {
+		return err
+	}
+	return c.send(ctx, method, path, query, authUser, body, out)
+}
+
+// session returns the session token, logging in when there is none.
+func (c *Client) session(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token != "" || c.username == "" {
+		return token, nil
+	}
+	resp, err := c.Login(ctx, c.username, c.password)
+	if err != nil {
+		return "", fmt.Errorf("login: %w", err)
+	}
+	return resp.Token, nil
+}
+
+// send sends a request, retrying idempotent ones, and decodes the response
+// into out when it is not nil.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, a auth, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if raw, ok := body.(json.RawMessage); ok {
+			data = raw
+		} else if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	attempts := 1
+	if idempotent(method) && c.retry.MaxAttempts > 1 {
+		attempts = c.retry.MaxAttempts
+	}
+	backoff := c.retry.MinBackoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, target, a, data, out)
+		if err == nil || attempt == attempts || !retryable(err) {
+			return err
+		}
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if c.retry.MaxBackoff > 0 && wait > c.retry.MaxBackoff {
+			wait = c.retry.MaxBackoff
+		}
+		backoff *= 2
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt sends a request once. It returns the Retry-After of the response
+// with an error status.
+func (c *Client) attempt(ctx context.Context, method, target string, a auth, body []byte, out any) (time.Duration, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	switch a {
+	case authUser:
+		if token := c.Token(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	case authBasic:
+		if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, &transportError{err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseRetryAfter(resp.Header.Get("Retry-After")), newError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return 0, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("decode %s %s response: %w", method, req.URL.Path, err)
+	}
+	return 0, nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// apiPath joins escaped segments to an API path.
+func apiPath(segments ...string) string {
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(s))
+	}
+	return b.String()
+}
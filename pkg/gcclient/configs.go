@@ -0,0 +1,144 @@
+package gcclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+func (c *Client) ListConfigs(ctx context.Context) ([]Config, error) {
+	var configs []Config
+	if err := c.do(ctx, http.MethodGet, "/api/configs", nil, nil, &configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+func (c *Client) CreateConfig(ctx context.Context, cfg ConfigObject) error {
+	return c.do(ctx, http.MethodPost, "/api/configs", nil, cfg, nil)
+}
+
+func (c *Client) GetConfig(ctx context.Context, name string) (*Config, error) {
+	var cfg Config
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "configs", name), nil, nil, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// UpdateConfig replaces a config, recording a new revision.
+func (c *Client) UpdateConfig(ctx context.Context, name string, cfg config.Config) (*Config, error) {
+	var updated Config
+	if err := c.do(ctx, http.MethodPatch, apiPath("api", "configs", name), nil, cfg, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *Client) DeleteConfig(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("api", "configs", name), nil, nil, nil)
+}
+
+// SetSatelliteConfig assigns a config to a satellite, or to the satellites
+// matching a selector, which it returns.
+func (c *Client) SetSatelliteConfig(ctx context.Context, params SatelliteConfigParams) ([]string, error) {
+	var resp struct {
+		Satellites []string `json:"satellites"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/configs/satellite", nil, params, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Satellites, nil
+}
+
+// ListConfigRevisions returns the revisions of a config without their
+// configs.
+func (c *Client) ListConfigRevisions(ctx context.Context, name string) ([]ConfigRevision, error) {
+	var revisions []ConfigRevision
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "configs", name, "revisions"), nil, nil, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (c *Client) GetConfigRevision(ctx context.Context, name string, revision int32) (*ConfigRevision, error) {
+	var rev ConfigRevision
+	p := apiPath("api", "configs", name, "revisions", strconv.Itoa(int(revision)))
+	if err := c.do(ctx, http.MethodGet, p, nil, nil, &rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// RollbackConfig restores the config of revision as a new revision, which
+// it returns.
+func (c *Client) RollbackConfig(ctx context.Context, name string, revision int32, message string) (*ConfigRevision, error) {
+	var rev ConfigRevision
+	p := apiPath("api", "configs", name, "revisions", strconv.Itoa(int(revision)), "rollback")
+	if err := c.do(ctx, http.MethodPost, p, nil, ConfigRollbackRequest{Message: message}, &rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// DiffConfigRevisions compares two revisions of a config. A to of 0 is the
+// latest revision.
+func (c *Client) DiffConfigRevisions(ctx context.Context, name string, from, to int32) (*ConfigRevisionDiff, error) {
+	query := url.Values{"from": {strconv.Itoa(int(from))}}
+	if to != 0 {
+		query.Set("to", strconv.Itoa(int(to)))
+	}
+	var diff ConfigRevisionDiff
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "configs", name, "diff"), query, nil, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+func (c *Client) ListConfigRollouts(ctx context.Context, name string) ([]ConfigRollout, error) {
+	var rollouts []ConfigRollout
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "configs", name, "rollouts"), nil, nil, &rollouts); err != nil {
+		return nil, err
+	}
+	return rollouts, nil
+}
+
+// CreateConfigRollout starts rolling out a new version of a config to its
+// satellites in waves, starting with the canary.
+func (c *Client) CreateConfigRollout(ctx context.Context, name string, req ConfigRolloutRequest) (*ConfigRollout, error) {
+	var rollout ConfigRollout
+	if err := c.do(ctx, http.MethodPost, apiPath("api", "configs", name, "rollouts"), nil, req, &rollout); err != nil {
+		return nil, err
+	}
+	return &rollout, nil
+}
+
+func (c *Client) GetConfigRollout(ctx context.Context, name string, id int32) (*ConfigRollout, error) {
+	return c.rollout(ctx, http.MethodGet, name, id, "")
+}
+
+// PromoteConfigRollout moves a rollout on to its next wave.
+func (c *Client) PromoteConfigRollout(ctx context.Context, name string, id int32) (*ConfigRollout, error) {
+	return c.rollout(ctx, http.MethodPost, name, id, "promote")
+}
+
+// AbortConfigRollout stops a rollout and returns its satellites to the
+// current version of the config.
+func (c *Client) AbortConfigRollout(ctx context.Context, name string, id int32) (*ConfigRollout, error) {
+	return c.rollout(ctx, http.MethodPost, name, id, "abort")
+}
+
+func (c *Client) rollout(ctx context.Context, method, name string, id int32, action string) (*ConfigRollout, error) {
+	segments := []string{"api", "configs", name, "rollouts", strconv.Itoa(int(id))}
+	if action != "" {
+		segments = append(segments, action)
+	}
+	var rollout ConfigRollout
+	if err := c.do(ctx, method, apiPath(segments...), nil, nil, &rollout); err != nil {
+		return nil, err
+	}
+	return &rollout, nil
+}
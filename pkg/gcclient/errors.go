@@ -0,0 +1,103 @@
+package gcclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors an *Error matches with errors.Is, by status code.
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrUnavailable     = errors.New("unavailable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:         ErrBadRequest,
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusConflict:           ErrConflict,
+	http.StatusTooManyRequests:    ErrTooManyRequests,
+	http.StatusServiceUnavailable: ErrUnavailable,
+}
+
+// Error is an error response of Ground Control. Message is the message of
+// the AppError in the body, or the body itself when it is not one.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ground control: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("ground control: %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches the sentinel error of the status code.
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// newError reads the error response resp.
+func newError(resp *http.Response) *Error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	// Handlers answer with an AppError; the rate limiter with an "error" key.
+	var body struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil {
+		switch {
+		case body.Message != "":
+			msg = body.Message
+		case body.Error != "":
+			msg = body.Error
+		}
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: msg}
+}
+
+// isStatus reports whether err is an *Error with code.
+func isStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// transportError is a request that got no response.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+
+func (e *transportError) Unwrap() error { return e.err }
+
+// retryable reports whether a request failing with err may succeed when
+// sent again.
+func retryable(err error) bool {
+	var te *transportError
+	if errors.As(err, &te) {
+		return true
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package gcclient
+
+import (
+	"context"
+	"net/http"
+)
+
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	var groups []Group
+	if err := c.do(ctx, http.MethodGet, "/api/groups", nil, nil, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// SyncGroup creates or updates a group and its state artifact.
+func (c *Client) SyncGroup(ctx context.Context, state StateArtifact) (*Group, error) {
+	var group Group
+	if err := c.do(ctx, http.MethodPost, "/api/groups/sync", nil, state, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (c *Client) GetGroup(ctx context.Context, name string) (*Group, error) {
+	var group Group
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "groups", name), nil, nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteGroup deletes a group. It needs the system_admin role.
+func (c *Client) DeleteGroup(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("api", "groups", name), nil, nil, nil)
+}
+
+func (c *Client) ListGroupSatellites(ctx context.Context, group string) ([]GroupMember, error) {
+	var members []GroupMember
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "groups", group, "satellites"), nil, nil, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddSatelliteToGroup adds a satellite, or the satellites matching a
+// selector, to a group.
+func (c *Client) AddSatelliteToGroup(ctx context.Context, params SatelliteGroupParams) (*GroupMembership, error) {
+	var resp GroupMembership
+	if err := c.do(ctx, http.MethodPost, "/api/groups/satellite", nil, params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) RemoveSatelliteFromGroup(ctx context.Context, params SatelliteGroupParams) error {
+	return c.do(ctx, http.MethodDelete, "/api/groups/satellite", nil, params, nil)
+}
+
+func (c *Client) GetGroupConfigLayer(ctx context.Context, group string) (*GroupConfigLayer, error) {
+	var layer GroupConfigLayer
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "groups", group, "config-layer"), nil, nil, &layer); err != nil {
+		return nil, err
+	}
+	return &layer, nil
+}
+
+// SetGroupConfigLayer sets the config patch merged into the config of the
+// satellites of a group.
+func (c *Client) SetGroupConfigLayer(ctx context.Context, group string, req GroupConfigLayerRequest) (*GroupConfigLayer, error) {
+	var layer GroupConfigLayer
+	if err := c.do(ctx, http.MethodPut, apiPath("api", "groups", group, "config-layer"), nil, req, &layer); err != nil {
+		return nil, err
+	}
+	return &layer, nil
+}
+
+func (c *Client) DeleteGroupConfigLayer(ctx context.Context, group string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("api", "groups", group, "config-layer"), nil, nil, nil)
+}
+
+func (c *Client) GetGroupSelector(ctx context.Context, group string) (*GroupSelector, error) {
+	var sel GroupSelector
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "groups", group, "selector"), nil, nil, &sel); err != nil {
+		return nil, err
+	}
+	return &sel, nil
+}
+
+// SetGroupSelector makes the membership of a group follow a label selector.
+// The response lists the satellites it added and removed.
+func (c *Client) SetGroupSelector(ctx context.Context, group, selector string) (*GroupSelector, error) {
+	var sel GroupSelector
+	req := GroupSelectorRequest{Selector: selector}
+	if err := c.do(ctx, http.MethodPut, apiPath("api", "groups", group, "selector"), nil, req, &sel); err != nil {
+		return nil, err
+	}
+	return &sel, nil
+}
+
+// DeleteGroupSelector makes a group static again, keeping its satellites.
+func (c *Client) DeleteGroupSelector(ctx context.Context, group string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("api", "groups", group, "selector"), nil, nil, nil)
+}
@@ -0,0 +1,138 @@
+package gcclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// selectorQuery filters a list by a label selector, such as "env=prod".
+func selectorQuery(selector string) url.Values {
+	if selector == "" {
+		return nil
+	}
+	return url.Values{"selector": {selector}}
+}
+
+// ListSatellites returns the satellites matching selector, or all of them
+// when it is empty.
+func (c *Client) ListSatellites(ctx context.Context, selector string) ([]Satellite, error) {
+	var satellites []Satellite
+	if err := c.do(ctx, http.MethodGet, "/api/satellites", selectorQuery(selector), nil, &satellites); err != nil {
+		return nil, err
+	}
+	return satellites, nil
+}
+
+// RegisterSatellite registers a satellite and returns its ZTR token.
+func (c *Client) RegisterSatellite(ctx context.Context, params RegisterSatelliteParams) (string, error) {
+	var resp RegisterSatelliteResponse
+	if err := c.do(ctx, http.MethodPost, "/api/satellites", nil, params, &resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// ListActiveSatellites returns the satellites that reported within their
+// heartbeat interval.
+func (c *Client) ListActiveSatellites(ctx context.Context, selector string) ([]ActiveSatellite, error) {
+	var satellites []ActiveSatellite
+	if err := c.do(ctx, http.MethodGet, "/api/satellites/active", selectorQuery(selector), nil, &satellites); err != nil {
+		return nil, err
+	}
+	return satellites, nil
+}
+
+// ListStaleSatellites returns the satellites that stopped reporting.
+func (c *Client) ListStaleSatellites(ctx context.Context, selector string) ([]StaleSatellite, error) {
+	var satellites []StaleSatellite
+	if err := c.do(ctx, http.MethodGet, "/api/satellites/stale", selectorQuery(selector), nil, &satellites); err != nil {
+		return nil, err
+	}
+	return satellites, nil
+}
+
+func (c *Client) GetSatellite(ctx context.Context, name string) (*SatelliteDetails, error) {
+	var satellite SatelliteDetails
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "satellites", name), nil, nil, &satellite); err != nil {
+		return nil, err
+	}
+	return &satellite, nil
+}
+
+// DeleteSatellite deletes a satellite with its robot account and state
+// artifact.
+func (c *Client) DeleteSatellite(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("api", "satellites", name), nil, nil, nil)
+}
+
+// GetSatelliteStatus returns the latest status report of a satellite, or
+// ErrNotFound before its first one.
+func (c *Client) GetSatelliteStatus(ctx context.Context, name string) (*SatelliteStatus, error) {
+	var status SatelliteStatus
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "satellites", name, "status"), nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListCachedImages returns the images a satellite reported it cached.
+func (c *Client) ListCachedImages(ctx context.Context, name string) ([]CachedImage, error) {
+	var images []CachedImage
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "satellites", name, "images"), nil, nil, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (c *Client) GetEffectiveConfig(ctx context.Context, name string) (*EffectiveConfig, error) {
+	var cfg EffectiveConfig
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "satellites", name, "config"), nil, nil, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Client) GetSatelliteConfigOverride(ctx context.Context, name string) (*SatelliteConfigOverride, error) {
+	var override SatelliteConfigOverride
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "satellites", name, "config-override"), nil, nil, &override); err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+// SetSatelliteConfigOverride sets the config patch merged last into the
+// config of a satellite. patch must be a JSON object.
+func (c *Client) SetSatelliteConfigOverride(ctx context.Context, name string, patch json.RawMessage) (*SatelliteConfigOverride, error) {
+	var override SatelliteConfigOverride
+	if err := c.do(ctx, http.MethodPut, apiPath("api", "satellites", name, "config-override"), nil, patch, &override); err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func (c *Client) DeleteSatelliteConfigOverride(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("api", "satellites", name, "config-override"), nil, nil, nil)
+}
+
+func (c *Client) GetSatelliteLabels(ctx context.Context, name string) (map[string]string, error) {
+	var resp SatelliteLabels
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "satellites", name, "labels"), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Labels, nil
+}
+
+// SetSatelliteLabels replaces the labels of a satellite.
+func (c *Client) SetSatelliteLabels(ctx context.Context, name string, labels map[string]string) (map[string]string, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	var resp SatelliteLabels
+	req := SatelliteLabelsRequest{Labels: labels}
+	if err := c.do(ctx, http.MethodPut, apiPath("api", "satellites", name, "labels"), nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Labels, nil
+}
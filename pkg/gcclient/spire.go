@@ -0,0 +1,43 @@
+package gcclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// The SPIRE routes need the system_admin role.
+
+func (c *Client) GetSPIREStatus(ctx context.Context) (*SPIREStatus, error) {
+	var status SPIREStatus
+	if err := c.do(ctx, http.MethodGet, "/api/spire/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListSpireAgents returns the attested SPIRE agents, of attestationType
+// when it is not empty.
+func (c *Client) ListSpireAgents(ctx context.Context, attestationType string) ([]SpireAgent, error) {
+	var query url.Values
+	if attestationType != "" {
+		query = url.Values{"attestation_type": {attestationType}}
+	}
+	var resp struct {
+		Agents []SpireAgent `json:"agents"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/spire/agents", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Agents, nil
+}
+
+// RegisterSatelliteWithSPIFFE registers a satellite that authenticates with
+// its SPIFFE ID instead of a ZTR token.
+func (c *Client) RegisterSatelliteWithSPIFFE(ctx context.Context, req RegisterSatelliteWithSPIFFERequest) (*RegisterSatelliteWithSPIFFEResponse, error) {
+	var resp RegisterSatelliteWithSPIFFEResponse
+	if err := c.do(ctx, http.MethodPost, "/api/satellites/register", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
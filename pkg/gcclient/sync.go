@@ -0,0 +1,41 @@
+package gcclient
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// The routes below are called by satellites.
+
+// ZeroTouchRegister redeems the single use ZTR token of a satellite for the
+// credentials of its robot account and the URL of its state artifact.
+func (c *Client) ZeroTouchRegister(ctx context.Context, token string) (*config.StateConfig, error) {
+	var state config.StateConfig
+	if err := c.send(ctx, http.MethodGet, apiPath("satellites", "ztr", token), nil, authNone, nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SPIFFEZeroTouchRegister is ZeroTouchRegister for a satellite
+// authenticating with its SVID, which the HTTP client of WithHTTPClient must
+// present.
+func (c *Client) SPIFFEZeroTouchRegister(ctx context.Context) (*config.StateConfig, error) {
+	var state config.StateConfig
+	if err := c.send(ctx, http.MethodGet, "/satellites/spiffe-ztr", nil, authNone, nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Sync sends the heartbeat of a satellite, authenticated with the robot
+// account of WithBasicAuth or the SVID of the HTTP client.
+func (c *Client) Sync(ctx context.Context, status SatelliteStatusParams) (*SyncResponse, error) {
+	var resp SyncResponse
+	if err := c.send(ctx, http.MethodPost, "/satellites/sync", nil, authBasic, status, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
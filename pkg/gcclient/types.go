@@ -0,0 +1,413 @@
+package gcclient
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/container-registry/harbor-satellite/internal/groundcontrol/models"
+	"github.com/container-registry/harbor-satellite/internal/inventory"
+	"github.com/container-registry/harbor-satellite/pkg/config"
+)
+
+// Types shared with the server.
+type (
+	StateArtifact   = models.StateArtifact
+	Artifact        = models.Artifact
+	ConfigObject    = models.ConfigObject
+	InventoryReport = inventory.Report
+)
+
+// The types below mirror the request and response types of the handlers of
+// Ground Control. Rows of its database have no JSON tags and are encoded
+// with their Go field names, nullable columns as sql.Null* objects.
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type User struct {
+	ID       int32  `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	// CreatedAt is formatted as RFC 3339.
+	CreatedAt string `json:"created_at"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangeUserPasswordRequest struct {
+	NewPassword string `json:"new_password"`
+}
+
+type Group struct {
+	ID          int32     `json:"ID"`
+	GroupName   string    `json:"GroupName"`
+	RegistryURL string    `json:"RegistryUrl"`
+	Projects    []string  `json:"Projects"`
+	CreatedAt   time.Time `json:"CreatedAt"`
+	UpdatedAt   time.Time `json:"UpdatedAt"`
+}
+
+// GroupMember is a satellite of a group.
+type GroupMember struct {
+	ID        int32     `json:"ID"`
+	Name      string    `json:"Name"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+// SatelliteGroupParams adds a satellite to a group, or with Selector, every
+// satellite it matches. Selectors only add.
+type SatelliteGroupParams struct {
+	Satellite string `json:"satellite"`
+	Group     string `json:"group"`
+	Selector  string `json:"selector,omitempty"`
+}
+
+// GroupMembership answers SatelliteGroupParams: Added for a selector,
+// Message for a single satellite.
+type GroupMembership struct {
+	Group   string   `json:"group,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+type GroupConfigLayerRequest struct {
+	Patch    json.RawMessage `json:"patch"`
+	Priority int32           `json:"priority"`
+}
+
+type GroupConfigLayer struct {
+	Group     string          `json:"group"`
+	Patch     json.RawMessage `json:"patch"`
+	Priority  int32           `json:"priority"`
+	UpdatedBy string          `json:"updated_by"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type GroupSelectorRequest struct {
+	Selector string `json:"selector"`
+}
+
+type GroupSelector struct {
+	Group     string    `json:"group"`
+	Selector  string    `json:"selector"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Added     []string  `json:"added,omitempty"`
+	Removed   []string  `json:"removed,omitempty"`
+}
+
+type Config struct {
+	ID          int32           `json:"ID"`
+	ConfigName  string          `json:"ConfigName"`
+	RegistryURL string          `json:"RegistryUrl"`
+	Config      json.RawMessage `json:"Config"`
+	CreatedAt   time.Time       `json:"CreatedAt"`
+	UpdatedAt   time.Time       `json:"UpdatedAt"`
+}
+
+// SatelliteConfigParams assigns a config to a satellite, or with Selector,
+// to every satellite it matches.
+type SatelliteConfigParams struct {
+	Satellite  string `json:"satellite,omitempty"`
+	Selector   string `json:"selector,omitempty"`
+	ConfigName string `json:"config_name"`
+}
+
+type ConfigRevision struct {
+	Revision  int32     `json:"revision"`
+	Author    string    `json:"author"`
+	Source    string    `json:"source"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Config is left out of lists.
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type ConfigRevisionDiff struct {
+	From    int32          `json:"from"`
+	To      int32          `json:"to"`
+	Changed map[string]any `json:"changed"`
+}
+
+type ConfigRollbackRequest struct {
+	Message string `json:"message,omitempty"`
+}
+
+type ConfigRolloutRequest struct {
+	Config      config.Config `json:"config"`
+	Canary      RolloutCanary `json:"canary"`
+	Waves       []int32       `json:"waves,omitempty"`
+	AutoPromote *bool         `json:"auto_promote,omitempty"`
+	BakeTime    string        `json:"bake_time,omitempty"`
+	MaxFailures int32         `json:"max_failures,omitempty"`
+}
+
+type RolloutCanary struct {
+	Satellites []string `json:"satellites,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	Selector   string   `json:"selector,omitempty"`
+	Percentage int32    `json:"percentage,omitempty"`
+}
+
+type ConfigRollout struct {
+	ID            int32              `json:"id"`
+	ConfigName    string             `json:"config_name"`
+	ConfigDigest  string             `json:"config_digest"`
+	Status        string             `json:"status"`
+	Message       string             `json:"message,omitempty"`
+	Wave          int32              `json:"wave"`
+	Waves         []int32            `json:"waves"`
+	AutoPromote   bool               `json:"auto_promote"`
+	BakeTime      string             `json:"bake_time"`
+	MaxFailures   int32              `json:"max_failures"`
+	CreatedBy     string             `json:"created_by"`
+	WaveStartedAt time.Time          `json:"wave_started_at"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	Summary       *RolloutSummary    `json:"summary,omitempty"`
+	Satellites    []RolloutSatellite `json:"satellites,omitempty"`
+}
+
+type RolloutSummary struct {
+	Pending  int `json:"pending"`
+	Healthy  int `json:"healthy"`
+	Failing  int `json:"failing"`
+	Rejected int `json:"rejected"`
+}
+
+type RolloutSatellite struct {
+	Name          string     `json:"name"`
+	Wave          int32      `json:"wave"`
+	State         string     `json:"state"`
+	ConfigDigest  string     `json:"config_digest,omitempty"`
+	LastSyncError string     `json:"last_sync_error,omitempty"`
+	ReportedAt    *time.Time `json:"reported_at,omitempty"`
+}
+
+type Satellite struct {
+	ID                int32          `json:"ID"`
+	Name              string         `json:"Name"`
+	CreatedAt         time.Time      `json:"CreatedAt"`
+	UpdatedAt         time.Time      `json:"UpdatedAt"`
+	LastSeen          sql.NullTime   `json:"LastSeen"`
+	HeartbeatInterval sql.NullString `json:"HeartbeatInterval"`
+	// Labels is only set in lists.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// SatelliteDetails is a satellite with the groups it is a member of and the
+// name of its config.
+type SatelliteDetails struct {
+	Satellite
+	Groups     []string `json:"groups"`
+	ConfigName string   `json:"config_name,omitempty"`
+}
+
+type ActiveSatellite struct {
+	ID                int32          `json:"ID"`
+	Name              string         `json:"Name"`
+	CreatedAt         time.Time      `json:"CreatedAt"`
+	UpdatedAt         time.Time      `json:"UpdatedAt"`
+	LastSeen          sql.NullTime   `json:"LastSeen"`
+	HeartbeatInterval sql.NullString `json:"HeartbeatInterval"`
+	LastActivity      string         `json:"LastActivity"`
+	LastStatusTime    time.Time      `json:"LastStatusTime"`
+}
+
+type StaleSatellite struct {
+	ID                int32          `json:"ID"`
+	Name              string         `json:"Name"`
+	CreatedAt         time.Time      `json:"CreatedAt"`
+	UpdatedAt         time.Time      `json:"UpdatedAt"`
+	LastSeen          sql.NullTime   `json:"LastSeen"`
+	HeartbeatInterval sql.NullString `json:"HeartbeatInterval"`
+	SecondsSinceSeen  int64          `json:"SecondsSinceSeen"`
+}
+
+type RegisterSatelliteParams struct {
+	Name       string            `json:"name"`
+	Groups     *[]string         `json:"groups,omitempty"`
+	ConfigName string            `json:"config_name"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+type RegisterSatelliteResponse struct {
+	// Token is the single use ZTR token of the satellite.
+	Token string `json:"token"`
+}
+
+// SatelliteStatus is the latest status report of a satellite.
+type SatelliteStatus struct {
+	ID                    int32           `json:"ID"`
+	SatelliteID           int32           `json:"SatelliteID"`
+	Activity              string          `json:"Activity"`
+	LatestStateDigest     sql.NullString  `json:"LatestStateDigest"`
+	LatestConfigDigest    sql.NullString  `json:"LatestConfigDigest"`
+	CPUPercent            sql.NullString  `json:"CpuPercent"`
+	MemoryUsedBytes       sql.NullInt64   `json:"MemoryUsedBytes"`
+	StorageUsedBytes      sql.NullInt64   `json:"StorageUsedBytes"`
+	LastSyncDurationMs    sql.NullInt64   `json:"LastSyncDurationMs"`
+	ImageCount            sql.NullInt32   `json:"ImageCount"`
+	ReportedAt            time.Time       `json:"ReportedAt"`
+	CreatedAt             time.Time       `json:"CreatedAt"`
+	Breakers              json.RawMessage `json:"Breakers"`
+	RejectedConfigDigest  sql.NullString  `json:"RejectedConfigDigest"`
+	LastSyncError         sql.NullString  `json:"LastSyncError"`
+	ConfigRevision        sql.NullInt32   `json:"ConfigRevision"`
+	RegistryUptimeSeconds sql.NullInt64   `json:"RegistryUptimeSeconds"`
+	RegistryRestarts      sql.NullInt32   `json:"RegistryRestarts"`
+	Prewarm               json.RawMessage `json:"Prewarm"`
+	ImagesStaged          sql.NullBool    `json:"ImagesStaged"`
+}
+
+type CachedImage struct {
+	Repository string    `json:"repository"`
+	Tag        string    `json:"tag"`
+	Digest     string    `json:"digest"`
+	SizeBytes  int64     `json:"size_bytes"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// EffectiveConfig is the config of a satellite with its layers applied.
+type EffectiveConfig struct {
+	Satellite  string          `json:"satellite"`
+	ConfigName string          `json:"config_name"`
+	Revision   int32           `json:"revision,omitempty"`
+	RolloutID  int32           `json:"rollout_id,omitempty"`
+	Layers     []ConfigLayer   `json:"layers"`
+	Warnings   []string        `json:"warnings,omitempty"`
+	Config     json.RawMessage `json:"config"`
+}
+
+type ConfigLayer struct {
+	// Source is "group:<name>" for a group layer and "satellite" for the
+	// override of the satellite.
+	Source   string          `json:"source"`
+	Priority int32           `json:"priority,omitempty"`
+	Patch    json.RawMessage `json:"patch"`
+}
+
+type SatelliteConfigOverride struct {
+	Satellite string          `json:"satellite"`
+	Patch     json.RawMessage `json:"patch"`
+	UpdatedBy string          `json:"updated_by"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type SatelliteLabelsRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type SatelliteLabels struct {
+	Satellite string            `json:"satellite"`
+	Labels    map[string]string `json:"labels"`
+}
+
+type SPIREStatus struct {
+	Enabled     bool   `json:"enabled"`
+	TrustDomain string `json:"trust_domain,omitempty"`
+	Provider    string `json:"provider,omitempty"`
+	Connected   bool   `json:"connected"`
+}
+
+type SpireAgent struct {
+	SpiffeID        string     `json:"spiffe_id"`
+	AttestationType string     `json:"attestation_type"`
+	Selectors       []string   `json:"selectors,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+}
+
+type RegisterSatelliteWithSPIFFERequest struct {
+	SatelliteName     string            `json:"satellite_name"`
+	Region            string            `json:"region,omitempty"`
+	Selectors         []string          `json:"selectors"`
+	AttestationMethod string            `json:"attestation_method"`
+	TTLSeconds        int               `json:"ttl_seconds,omitempty"`
+	ParentAgentID     string            `json:"parent_agent_id,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+}
+
+type RegisterSatelliteWithSPIFFEResponse struct {
+	Satellite          string     `json:"satellite"`
+	Region             string     `json:"region"`
+	SpiffeID           string     `json:"spiffe_id"`
+	ParentAgentID      string     `json:"parent_agent_id,omitempty"`
+	JoinToken          string     `json:"join_token,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	SpireServerAddress string     `json:"spire_server_address"`
+	SpireServerPort    int        `json:"spire_server_port"`
+	TrustDomain        string     `json:"trust_domain"`
+}
+
+// SatelliteStatusParams is the heartbeat of a satellite.
+type SatelliteStatusParams struct {
+	Name                  string              `json:"name"`
+	Activity              string              `json:"activity"`
+	StateReportInterval   string              `json:"state_report_interval"`
+	LatestStateDigest     string              `json:"latest_state_digest"`
+	LatestConfigDigest    string              `json:"latest_config_digest"`
+	MemoryUsedBytes       uint64              `json:"memory_used_bytes"`
+	StorageUsedBytes      uint64              `json:"storage_used_bytes"`
+	CPUPercent            float64             `json:"cpu_percent"`
+	RequestCreatedTime    time.Time           `json:"request_created_time"`
+	LastSyncDurationMs    int64               `json:"last_sync_duration_ms"`
+	ImageCount            int                 `json:"image_count"`
+	Breakers              []DependencyBreaker `json:"breakers,omitempty"`
+	RejectedConfigDigest  string              `json:"rejected_config_digest,omitempty"`
+	LastSyncError         string              `json:"last_sync_error,omitempty"`
+	ConfigRevision        int32               `json:"config_revision,omitempty"`
+	RegistryUptimeSeconds *int64              `json:"registry_uptime_seconds,omitempty"`
+	RegistryRestarts      *int32              `json:"registry_restarts,omitempty"`
+	Inventory             *InventoryReport    `json:"inventory,omitempty"`
+	// CachedImages is sent instead of Inventory by older satellites.
+	//
+	// Deprecated: use Inventory.
+	CachedImages []LegacyCachedImage `json:"cached_images,omitempty"`
+	Prewarm      []ImageWarmStatus   `json:"prewarm,omitempty"`
+}
+
+type DependencyBreaker struct {
+	Dependency          string     `json:"dependency"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+type ImageWarmStatus struct {
+	Node      string     `json:"node"`
+	Ref       string     `json:"ref"`
+	Digest    string     `json:"digest"`
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+type LegacyCachedImage struct {
+	Reference string `json:"reference"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+type SyncResponse struct {
+	// InventoryResync asks the satellite to report its whole inventory in
+	// the next heartbeat.
+	InventoryResync bool `json:"inventory_resync,omitempty"`
+}
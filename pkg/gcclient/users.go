@@ -0,0 +1,47 @@
+package gcclient
+
+import (
+	"context"
+	"net/http"
+)
+
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var users []User
+	if err := c.do(ctx, http.MethodGet, "/api/users", nil, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, username string) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, apiPath("api", "users", username), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates a user. It needs the system_admin role.
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, "/api/users", nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteUser deletes a user. It needs the system_admin role.
+func (c *Client) DeleteUser(ctx context.Context, username string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("api", "users", username), nil, nil, nil)
+}
+
+// ChangePassword changes the password of the user of the client.
+func (c *Client) ChangePassword(ctx context.Context, req ChangePasswordRequest) error {
+	return c.do(ctx, http.MethodPatch, "/api/users/password", nil, req, nil)
+}
+
+// ChangeUserPassword sets the password of another user. It needs the
+// system_admin role.
+func (c *Client) ChangeUserPassword(ctx context.Context, username string, req ChangeUserPasswordRequest) error {
+	return c.do(ctx, http.MethodPatch, apiPath("api", "users", username, "password"), nil, req, nil)
+}